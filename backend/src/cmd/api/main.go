@@ -12,8 +12,11 @@ import (
 
 	"github.com/orientallines/beesbiz/internal/config"
 	"github.com/orientallines/beesbiz/internal/database"
+	"github.com/orientallines/beesbiz/internal/notify"
 	"github.com/orientallines/beesbiz/internal/rabbitmq"
+	"github.com/orientallines/beesbiz/internal/ratelimit"
 	"github.com/orientallines/beesbiz/internal/server"
+	"github.com/orientallines/beesbiz/internal/tikv"
 )
 
 func main() {
@@ -64,9 +67,27 @@ func main() {
 	// 	zap.S().Fatal("Failed to connect to TiKV: ", err)
 	// }
 
+	// Rate limit counters live in memory unless replicas need to share them
+	var limiterStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if config.GlobalConfig.API.LimitEnabled && config.GlobalConfig.API.LimitStorage == "tikv" {
+		limiterKV, err := tikv.New(config.GlobalConfig.TiKV.PDEndpoints)
+		if err != nil {
+			zap.S().Fatal("Failed to connect to TiKV: ", err)
+		}
+		defer limiterKV.Close()
+		limiterStore = tikv.NewRateLimitStore(limiterKV.GetClient())
+	}
+
+	// Notifications (password resets, incidents, ...) are emailed over SMTP, or go to the log or a file during development
+	notifier, err := notify.New(config.GlobalConfig.Notify)
+	if err != nil {
+		zap.S().Fatal("Failed to create notifier: ", err)
+	}
+
 	// Create the server
 	// srv, err := server.NewServer(db, rmq, tikv)
-	srv, err := server.NewServer(db, rmq)
+	escalateAfter := time.Duration(config.GlobalConfig.Notify.EscalationMinutes) * time.Minute
+	srv, err := server.NewServer(db, rmq, limiterStore, notifier, escalateAfter)
 	if err != nil {
 		zap.S().Fatal("Failed to create server: ", err)
 	}
@@ -18,16 +18,34 @@ type Config struct {
 	RabbitMQ         RabbitMQConfig
 	App              AppConfig
 	API              APIConfig
+	Notify           NotifyConfig
 }
 
 type AppConfig struct {
 	Environment string `mapstructure:"APP_ENV"`
+	PublicURL   string `mapstructure:"APP_PUBLIC_URL"`
+}
+
+type NotifyConfig struct {
+	Sink              string `mapstructure:"NOTIFY_SINK"`
+	FilePath          string `mapstructure:"NOTIFY_FILE_PATH"`
+	SMTPHost          string `mapstructure:"SMTP_HOST"`
+	SMTPPort          int    `mapstructure:"SMTP_PORT"`
+	SMTPUsername      string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword      string `mapstructure:"SMTP_PASSWORD"`
+	SMTPFrom          string `mapstructure:"SMTP_FROM"`
+	EscalationMinutes int    `mapstructure:"NOTIFY_ESCALATION_MINUTES"`
 }
 
 type APIConfig struct {
-	LimitEnabled    bool `mapstructure:"API_LIMIT_ENABLED"`
-	LimitAmount     int  `mapstructure:"API_LIMIT_AMOUNT"`
-	LimitExpiration int  `mapstructure:"API_LIMIT_EXPIRATION"`
+	LimitEnabled        bool   `mapstructure:"API_LIMIT_ENABLED"`
+	LimitAmount         int    `mapstructure:"API_LIMIT_AMOUNT"`
+	LimitExpiration     int    `mapstructure:"API_LIMIT_EXPIRATION"`
+	IPLimitAmount       int    `mapstructure:"API_IP_LIMIT_AMOUNT"`
+	AuthLimitAmount     int    `mapstructure:"API_AUTH_LIMIT_AMOUNT"`
+	AuthLimitExpiration int    `mapstructure:"API_AUTH_LIMIT_EXPIRATION"`
+	LimitStorage        string `mapstructure:"API_LIMIT_STORAGE"`
+	ProxyHeader         string `mapstructure:"API_PROXY_HEADER"`
 }
 
 type TiKVConfig struct {
@@ -56,6 +74,18 @@ func LoadConfig() error {
 	v.AutomaticEnv()
 	v.ReadInConfig()
 
+	// Rate limiting defaults, expirations are in seconds
+	v.SetDefault("API_LIMIT_AMOUNT", 100)
+	v.SetDefault("API_LIMIT_EXPIRATION", 60)
+	v.SetDefault("API_IP_LIMIT_AMOUNT", 300)
+	v.SetDefault("API_AUTH_LIMIT_AMOUNT", 10)
+	v.SetDefault("API_AUTH_LIMIT_EXPIRATION", 900)
+	v.SetDefault("API_LIMIT_STORAGE", "memory")
+	v.SetDefault("NOTIFY_SINK", "log")
+	v.SetDefault("SMTP_PORT", 587)
+	// Unacknowledged incidents are escalated to the apiary manager after this many minutes
+	v.SetDefault("NOTIFY_ESCALATION_MINUTES", 30)
+
 	// Populate GlobalConfig using Viper
 	GlobalConfig = Config{
 		PostgresUser:     v.GetString("POSTGRES_USER"),
@@ -78,11 +108,27 @@ func LoadConfig() error {
 		},
 		App: AppConfig{
 			Environment: v.GetString("APP_ENV"),
+			PublicURL:   v.GetString("APP_PUBLIC_URL"),
 		},
 		API: APIConfig{
-			LimitAmount:     v.GetInt("API_LIMIT_AMOUNT"),
-			LimitExpiration: v.GetInt("API_LIMIT_EXPIRATION"),
-			LimitEnabled:    v.GetBool("API_LIMIT_ENABLED"),
+			LimitAmount:         v.GetInt("API_LIMIT_AMOUNT"),
+			LimitExpiration:     v.GetInt("API_LIMIT_EXPIRATION"),
+			LimitEnabled:        v.GetBool("API_LIMIT_ENABLED"),
+			IPLimitAmount:       v.GetInt("API_IP_LIMIT_AMOUNT"),
+			AuthLimitAmount:     v.GetInt("API_AUTH_LIMIT_AMOUNT"),
+			AuthLimitExpiration: v.GetInt("API_AUTH_LIMIT_EXPIRATION"),
+			LimitStorage:        v.GetString("API_LIMIT_STORAGE"),
+			ProxyHeader:         v.GetString("API_PROXY_HEADER"),
+		},
+		Notify: NotifyConfig{
+			Sink:              v.GetString("NOTIFY_SINK"),
+			FilePath:          v.GetString("NOTIFY_FILE_PATH"),
+			SMTPHost:          v.GetString("SMTP_HOST"),
+			SMTPPort:          v.GetInt("SMTP_PORT"),
+			SMTPUsername:      v.GetString("SMTP_USERNAME"),
+			SMTPPassword:      v.GetString("SMTP_PASSWORD"),
+			SMTPFrom:          v.GetString("SMTP_FROM"),
+			EscalationMinutes: v.GetInt("NOTIFY_ESCALATION_MINUTES"),
 		},
 	}
 
@@ -1,12 +1,19 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/lib/pq"
+
 	types "github.com/orientallines/beesbiz/internal/types/db"
 	"go.uber.org/zap"
 )
 
+// communityColumns selects a bee community, taking the queen age from its
+// registered queen when it has one
+const communityColumns = "community_id, hive_id, COALESCE(community_queen_age(community_id), queen_age) AS queen_age, population_estimate, health_status, dissolved_date, merged_into"
+
 func (db *DB) GetApiary(id int) (types.Apiary, error) {
 	var apiary types.Apiary
 	err := db.Get(&apiary, "SELECT * FROM apiary WHERE apiary_id = $1", id)
@@ -19,7 +26,7 @@ func (db *DB) GetApiary(id int) (types.Apiary, error) {
 
 func (db *DB) CreateApiary(apiary types.Apiary) (types.Apiary, error) {
 	var createdApiary types.Apiary
-	err := db.Get(&createdApiary, "INSERT INTO apiary (location, manager_id, establishment_date) VALUES ($1, $2, $3) RETURNING *", apiary.Location, apiary.ManagerID, apiary.EstablishmentDate)
+	err := db.Get(&createdApiary, "INSERT INTO apiary (location, manager_id, establishment_date, latitude, longitude, site_polygon) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *", apiary.Location, apiary.ManagerID, apiary.EstablishmentDate, apiary.Latitude, apiary.Longitude, apiary.SitePolygon)
 	if err != nil {
 		zap.S().Error("Error creating apiary: ", err)
 		return types.Apiary{}, fmt.Errorf("error creating apiary: %w", err)
@@ -29,7 +36,7 @@ func (db *DB) CreateApiary(apiary types.Apiary) (types.Apiary, error) {
 
 func (db *DB) UpdateApiary(apiary types.Apiary) (types.Apiary, error) {
 	var updatedApiary types.Apiary
-	err := db.Get(&updatedApiary, "UPDATE apiary SET location = $1, manager_id = $2, establishment_date = $3 WHERE id = $4 RETURNING *", apiary.Location, apiary.ManagerID, apiary.EstablishmentDate, apiary.ApiaryID)
+	err := db.Get(&updatedApiary, "UPDATE apiary SET location = $1, manager_id = $2, establishment_date = $3, latitude = $4, longitude = $5, site_polygon = $6 WHERE apiary_id = $7 RETURNING *", apiary.Location, apiary.ManagerID, apiary.EstablishmentDate, apiary.Latitude, apiary.Longitude, apiary.SitePolygon, apiary.ApiaryID)
 	if err != nil {
 		zap.S().Error("Error updating apiary: ", err)
 		return types.Apiary{}, fmt.Errorf("error updating apiary: %w", err)
@@ -68,7 +75,7 @@ func (db *DB) GetAllHives() ([]types.Hive, error) {
 
 func (db *DB) CreateHive(hive types.Hive) (types.Hive, error) {
 	var createdHive types.Hive
-	err := db.Get(&createdHive, "INSERT INTO hive (apiary_id, hive_type, installation_date, current_status) VALUES ($1, $2, $3, $4) RETURNING *", hive.ApiaryID, hive.HiveType, hive.InstallationDate, hive.CurrentStatus)
+	err := db.Get(&createdHive, "INSERT INTO hive (apiary_id, hive_type, installation_date, current_status, tare_weight_kg, colony_allowance_kg) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *", hive.ApiaryID, hive.HiveType, hive.InstallationDate, hive.CurrentStatus, hive.TareWeightKg, hive.ColonyAllowanceKg)
 	if err != nil {
 		zap.S().Error("Error creating hive: ", err)
 		return types.Hive{}, fmt.Errorf("error creating hive: %w", err)
@@ -78,7 +85,7 @@ func (db *DB) CreateHive(hive types.Hive) (types.Hive, error) {
 
 func (db *DB) UpdateHive(hive types.Hive) (types.Hive, error) {
 	var updatedHive types.Hive
-	err := db.Get(&updatedHive, "UPDATE hive SET apiary_id = $1, hive_type = $2, installation_date = $3, current_status = $4 WHERE hive_id = $5 RETURNING *", hive.ApiaryID, hive.HiveType, hive.InstallationDate, hive.CurrentStatus, hive.HiveID)
+	err := db.Get(&updatedHive, "UPDATE hive SET apiary_id = $1, hive_type = $2, installation_date = $3, current_status = $4, tare_weight_kg = $5, colony_allowance_kg = $6 WHERE hive_id = $7 RETURNING *", hive.ApiaryID, hive.HiveType, hive.InstallationDate, hive.CurrentStatus, hive.TareWeightKg, hive.ColonyAllowanceKg, hive.HiveID)
 	if err != nil {
 		zap.S().Error("Error updating hive: ", err)
 		return types.Hive{}, fmt.Errorf("error updating hive: %w", err)
@@ -107,7 +114,7 @@ func (db *DB) GetAllHivesByApiaryID(apiaryID int) ([]types.Hive, error) {
 
 func (db *DB) GetAllBeeCommunities() ([]types.BeeCommunity, error) {
 	var beeCommunities []types.BeeCommunity
-	err := db.Select(&beeCommunities, "SELECT * FROM bee_community")
+	err := db.Select(&beeCommunities, "SELECT "+communityColumns+" FROM bee_community")
 	if err != nil {
 		zap.S().Error("Error getting all bee communities: ", err)
 		return []types.BeeCommunity{}, fmt.Errorf("error getting all bee communities: %w", err)
@@ -117,7 +124,11 @@ func (db *DB) GetAllBeeCommunities() ([]types.BeeCommunity, error) {
 
 func (db *DB) CreateBeeCommunity(beeCommunity types.BeeCommunity) (types.BeeCommunity, error) {
 	var createdBeeCommunity types.BeeCommunity
-	err := db.Get(&createdBeeCommunity, "INSERT INTO bee_community (hive_id, queen_age, population_estimate, health_status) VALUES ($1, $2, $3, $4) RETURNING *", beeCommunity.HiveID, beeCommunity.QueenAge, beeCommunity.PopulationEstimate, beeCommunity.HealthStatus)
+	err := db.Get(&createdBeeCommunity, "INSERT INTO bee_community (hive_id, queen_age, population_estimate, health_status) VALUES ($1, $2, $3, $4) RETURNING "+communityColumns, beeCommunity.HiveID, beeCommunity.QueenAge, beeCommunity.PopulationEstimate, beeCommunity.HealthStatus)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return types.BeeCommunity{}, ErrHiveOccupied
+	}
 	if err != nil {
 		zap.S().Error("Error creating bee community: ", err)
 		return types.BeeCommunity{}, fmt.Errorf("error creating bee community: %w", err)
@@ -127,7 +138,11 @@ func (db *DB) CreateBeeCommunity(beeCommunity types.BeeCommunity) (types.BeeComm
 
 func (db *DB) UpdateBeeCommunity(beeCommunity types.BeeCommunity) (types.BeeCommunity, error) {
 	var updatedBeeCommunity types.BeeCommunity
-	err := db.Get(&updatedBeeCommunity, "UPDATE bee_community SET hive_id = $1, queen_age = $2, population_estimate = $3, health_status = $4 WHERE community_id = $5 RETURNING *", beeCommunity.HiveID, beeCommunity.QueenAge, beeCommunity.PopulationEstimate, beeCommunity.HealthStatus, beeCommunity.CommunityID)
+	err := db.Get(&updatedBeeCommunity, "UPDATE bee_community SET hive_id = $1, queen_age = $2, population_estimate = $3, health_status = $4 WHERE community_id = $5 RETURNING "+communityColumns, beeCommunity.HiveID, beeCommunity.QueenAge, beeCommunity.PopulationEstimate, beeCommunity.HealthStatus, beeCommunity.CommunityID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return types.BeeCommunity{}, ErrHiveOccupied
+	}
 	if err != nil {
 		zap.S().Error("Error updating bee community: ", err)
 		return types.BeeCommunity{}, fmt.Errorf("error updating bee community: %w", err)
@@ -146,7 +161,7 @@ func (db *DB) DeleteBeeCommunity(id int) error {
 
 func (db *DB) GetAllBeeCommunitiesByHiveID(hiveID int) ([]types.BeeCommunity, error) {
 	var beeCommunities []types.BeeCommunity
-	err := db.Select(&beeCommunities, "SELECT * FROM bee_community WHERE hive_id = $1", hiveID)
+	err := db.Select(&beeCommunities, "SELECT "+communityColumns+" FROM bee_community WHERE hive_id = $1", hiveID)
 	if err != nil {
 		zap.S().Error("Error getting all bee communities by hive id: ", err)
 		return []types.BeeCommunity{}, fmt.Errorf("error getting all bee communities by hive id: %w", err)
@@ -166,7 +181,7 @@ func (db *DB) GetHoneyHarvest(id int) (types.HoneyHarvest, error) {
 
 func (db *DB) CreateHoneyHarvest(harvest types.HoneyHarvest) (types.HoneyHarvest, error) {
 	var createdHarvest types.HoneyHarvest
-	err := db.Get(&createdHarvest, "INSERT INTO honey_harvest (hive_id, harvest_date, quantity, quality_grade) VALUES ($1, $2, $3, $4) RETURNING *", harvest.HiveID, harvest.HarvestDate, harvest.Quantity, harvest.QualityGrade)
+	err := db.Get(&createdHarvest, "INSERT INTO honey_harvest (hive_id, harvest_date, quantity, quality_grade, withdrawal_override_reason, overridden_by) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *", harvest.HiveID, harvest.HarvestDate, harvest.Quantity, harvest.QualityGrade, harvest.WithdrawalOverrideReason, harvest.OverriddenBy)
 	if err != nil {
 		zap.S().Error("Error creating honey harvest: ", err)
 		return types.HoneyHarvest{}, fmt.Errorf("error creating honey harvest: %w", err)
@@ -174,13 +189,49 @@ func (db *DB) CreateHoneyHarvest(harvest types.HoneyHarvest) (types.HoneyHarvest
 	return createdHarvest, nil
 }
 
+// UpdateHoneyHarvest updates a harvest, refusing to move a harvest allocated to
+// batches to another hive or date or to lower its quantity below what is allocated
 func (db *DB) UpdateHoneyHarvest(harvest types.HoneyHarvest) (types.HoneyHarvest, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.HoneyHarvest{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current struct {
+		Moved     bool    `db:"moved"`
+		Allocated float64 `db:"allocated"`
+	}
+	err = tx.Get(&current, `
+		SELECT (hh.hive_id <> $2 OR hh.harvest_date IS DISTINCT FROM $3::date) AS moved,
+			(SELECT COALESCE(SUM(bh.quantity), 0) FROM batch_harvest bh WHERE bh.harvest_id = hh.harvest_id) AS allocated
+		FROM honey_harvest hh
+		WHERE hh.harvest_id = $1
+		FOR UPDATE`,
+		harvest.HarvestID, harvest.HiveID, harvest.HarvestDate)
+	if err != nil {
+		zap.S().Error("Error locking honey harvest: ", err)
+		return types.HoneyHarvest{}, fmt.Errorf("error locking honey harvest: %w", err)
+	}
+	if current.Allocated > 0 && current.Moved {
+		return types.HoneyHarvest{}, ErrHarvestAllocated
+	}
+	if float64(harvest.Quantity) < current.Allocated-quantityTolerance {
+		return types.HoneyHarvest{}, fmt.Errorf("%w: %.2f is allocated", ErrHarvestBelowAllocated, current.Allocated)
+	}
+
 	var updatedHarvest types.HoneyHarvest
-	err := db.Get(&updatedHarvest, "UPDATE honey_harvest SET hive_id = $1, harvest_date = $2, quantity = $3, quality_grade = $4 WHERE harvest_id = $5 RETURNING *", harvest.HiveID, harvest.HarvestDate, harvest.Quantity, harvest.QualityGrade, harvest.HarvestID)
+	err = tx.Get(&updatedHarvest, "UPDATE honey_harvest SET hive_id = $1, harvest_date = $2, quantity = $3, quality_grade = $4, withdrawal_override_reason = $5, overridden_by = $6 WHERE harvest_id = $7 RETURNING *", harvest.HiveID, harvest.HarvestDate, harvest.Quantity, harvest.QualityGrade, harvest.WithdrawalOverrideReason, harvest.OverriddenBy, harvest.HarvestID)
 	if err != nil {
 		zap.S().Error("Error updating honey harvest: ", err)
 		return types.HoneyHarvest{}, fmt.Errorf("error updating honey harvest: %w", err)
 	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.HoneyHarvest{}, fmt.Errorf("error committing transaction: %w", err)
+	}
 	return updatedHarvest, nil
 }
 
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/guregu/null"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// ErrPlanNotOpen is returned when completing a plan that is already completed or skipped
+var ErrPlanNotOpen = errors.New("maintenance plan is not open")
+
+// AssignMaintenancePlan assigns a plan to a user, or to a worker group in which
+// case the least loaded member with access to the apiary's region takes it
+func (db *DB) AssignMaintenancePlan(planID int, userID, groupID null.Int) (types.MaintenancePlan, error) {
+	var plan types.MaintenancePlan
+	err := db.Get(&plan, `
+		UPDATE maintenance_plan
+		SET assigned_to = COALESCE($2, pick_group_assignee($3, apiary_id)),
+			assigned_group_id = $3
+		WHERE plan_id = $1
+		RETURNING *`,
+		planID, userID, groupID)
+	if err != nil {
+		zap.S().Error("Error assigning maintenance plan: ", err)
+		return types.MaintenancePlan{}, fmt.Errorf("error assigning maintenance plan: %w", err)
+	}
+	return plan, nil
+}
+
+// DistributeGroupPlans hands open group plans without an assignee to group
+// members, one at a time so every pick sees the workload of the previous ones
+//
+// Plans for which no member qualifies stay unassigned until the next run.
+// It returns how many plans were assigned.
+func (db *DB) DistributeGroupPlans() (int, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var planIDs []int
+	err = tx.Select(&planIDs, `
+		SELECT plan_id FROM maintenance_plan
+		WHERE assigned_to IS NULL
+			AND assigned_group_id IS NOT NULL
+			AND status IN ('Pending', 'In Progress')
+		ORDER BY planned_date, plan_id
+		FOR UPDATE SKIP LOCKED`)
+	if err != nil {
+		zap.S().Error("Error getting unassigned group plans: ", err)
+		return 0, fmt.Errorf("error getting unassigned group plans: %w", err)
+	}
+
+	assigned := 0
+	for _, planID := range planIDs {
+		var assignee null.Int
+		err := tx.Get(&assignee, `
+			UPDATE maintenance_plan SET assigned_to = pick_group_assignee(assigned_group_id, apiary_id)
+			WHERE plan_id = $1
+			RETURNING assigned_to`,
+			planID)
+		if err != nil {
+			zap.S().Error("Error distributing group plan: ", err)
+			return 0, fmt.Errorf("error distributing group plan: %w", err)
+		}
+		if assignee.Valid {
+			assigned++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return assigned, nil
+}
+
+// GetMyTasks retrieves a worker's open plans and the not yet distributed plans
+// of their groups, earliest first
+func (db *DB) GetMyTasks(userID int) ([]types.MaintenancePlan, error) {
+	plans := []types.MaintenancePlan{}
+	err := db.Select(&plans, `
+		SELECT * FROM maintenance_plan
+		WHERE status IN ('Pending', 'In Progress')
+			AND (
+				assigned_to = $1
+				OR (assigned_to IS NULL AND assigned_group_id IN (
+					SELECT group_id FROM worker_group_member WHERE worker_id = $1
+				))
+			)
+		ORDER BY planned_date, plan_id`,
+		userID)
+	if err != nil {
+		zap.S().Error("Error getting tasks: ", err)
+		return nil, fmt.Errorf("error getting tasks: %w", err)
+	}
+	return plans, nil
+}
+
+// CompleteMaintenancePlan marks an open plan completed and records who did it
+//
+// It returns ErrPlanNotOpen if the plan is already completed or skipped
+func (db *DB) CompleteMaintenancePlan(completion types.MaintenanceCompletion) (types.MaintenanceCompletion, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.MaintenanceCompletion{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE maintenance_plan SET status = 'Completed', overdue_at = NULL
+		WHERE plan_id = $1 AND status IN ('Pending', 'In Progress')`,
+		completion.PlanID)
+	if err != nil {
+		zap.S().Error("Error completing maintenance plan: ", err)
+		return types.MaintenanceCompletion{}, fmt.Errorf("error completing maintenance plan: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return types.MaintenanceCompletion{}, ErrPlanNotOpen
+	}
+
+	var createdCompletion types.MaintenanceCompletion
+	err = tx.Get(&createdCompletion, `
+		INSERT INTO maintenance_completion (plan_id, completed_by, duration_minutes, notes)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (plan_id) DO UPDATE SET
+			completed_by = EXCLUDED.completed_by,
+			completed_at = CURRENT_TIMESTAMP,
+			duration_minutes = EXCLUDED.duration_minutes,
+			notes = EXCLUDED.notes
+		RETURNING *`,
+		completion.PlanID, completion.CompletedBy, completion.DurationMinutes, completion.Notes)
+	if err != nil {
+		zap.S().Error("Error recording maintenance completion: ", err)
+		return types.MaintenanceCompletion{}, fmt.Errorf("error recording maintenance completion: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.MaintenanceCompletion{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return createdCompletion, nil
+}
+
+// GetMaintenanceCompletion returns the completion record of a plan
+func (db *DB) GetMaintenanceCompletion(planID int) (types.MaintenanceCompletion, error) {
+	var completion types.MaintenanceCompletion
+	err := db.Get(&completion, "SELECT * FROM maintenance_completion WHERE plan_id = $1", planID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			zap.S().Error("Error getting maintenance completion: ", err)
+		}
+		return types.MaintenanceCompletion{}, fmt.Errorf("error getting maintenance completion: %w", err)
+	}
+	return completion, nil
+}
+
+// IsGroupMember reports whether the user belongs to the worker group
+func (db *DB) IsGroupMember(groupID, userID int) (bool, error) {
+	var member bool
+	err := db.Get(&member, "SELECT EXISTS (SELECT 1 FROM worker_group_member WHERE group_id = $1 AND worker_id = $2)", groupID, userID)
+	if err != nil {
+		zap.S().Error("Error checking group membership: ", err)
+		return false, fmt.Errorf("error checking group membership: %w", err)
+	}
+	return member, nil
+}
@@ -0,0 +1,286 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// ErrInvalidResetToken is returned when a reset token is unknown, expired or already used
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// ErrInvalidEmailToken is returned when an email confirmation token is unknown, expired or already used
+var ErrInvalidEmailToken = errors.New("invalid or expired email confirmation token")
+
+// ErrEmailTaken is returned when confirming an email that another account took in the meantime
+var ErrEmailTaken = errors.New("email is already in use")
+
+// GetLoginFailure returns the failed login state of a user, or a zero value if there is none
+func (db *DB) GetLoginFailure(userID int) (types.LoginFailure, error) {
+	var failure types.LoginFailure
+	err := db.Get(&failure, "SELECT * FROM login_failure WHERE user_id = $1", userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.LoginFailure{UserID: userID}, nil
+	}
+	if err != nil {
+		zap.S().Error("Error getting login failure: ", err)
+		return types.LoginFailure{}, fmt.Errorf("error getting login failure: %w", err)
+	}
+	return failure, nil
+}
+
+// RecordLoginFailure counts a failed login and locks the account once lockAfter
+// consecutive failures are reached
+//
+// The lock lasts baseLock and doubles with every further failure, up to maxLock.
+// Failures older than resetAfter no longer count towards the streak.
+func (db *DB) RecordLoginFailure(userID, lockAfter int, baseLock, maxLock, resetAfter time.Duration) (types.LoginFailure, error) {
+	var failure types.LoginFailure
+	err := db.Get(&failure, `
+		INSERT INTO login_failure (user_id, failed_attempts, last_failed_at)
+		VALUES ($1, 1, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id) DO UPDATE SET
+			failed_attempts = CASE
+				WHEN login_failure.last_failed_at < CURRENT_TIMESTAMP - make_interval(secs => $2) THEN 1
+				ELSE login_failure.failed_attempts + 1
+			END,
+			last_failed_at = CURRENT_TIMESTAMP
+		RETURNING *`,
+		userID, resetAfter.Seconds())
+	if err != nil {
+		zap.S().Error("Error recording login failure: ", err)
+		return types.LoginFailure{}, fmt.Errorf("error recording login failure: %w", err)
+	}
+
+	if failure.FailedAttempts < lockAfter {
+		return failure, nil
+	}
+
+	lock := baseLock
+	for i := lockAfter; i < failure.FailedAttempts && lock < maxLock; i++ {
+		lock *= 2
+	}
+	if lock > maxLock {
+		lock = maxLock
+	}
+
+	err = db.Get(&failure, `
+		UPDATE login_failure SET locked_until = CURRENT_TIMESTAMP + make_interval(secs => $2)
+		WHERE user_id = $1
+		RETURNING *`,
+		userID, lock.Seconds())
+	if err != nil {
+		zap.S().Error("Error locking account: ", err)
+		return types.LoginFailure{}, fmt.Errorf("error locking account: %w", err)
+	}
+	return failure, nil
+}
+
+// ClearLoginFailures resets the failed login streak and lifts any lock
+func (db *DB) ClearLoginFailures(userID int) error {
+	_, err := db.Exec("DELETE FROM login_failure WHERE user_id = $1", userID)
+	if err != nil {
+		zap.S().Error("Error clearing login failures: ", err)
+		return fmt.Errorf("error clearing login failures: %w", err)
+	}
+	return nil
+}
+
+// UpdateUserPassword replaces the password hash of a user and revokes their
+// password reset tokens, so a reset requested before the change cannot undo it
+func (db *DB) UpdateUserPassword(userID int, passwordHash string) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE \"user\" SET password = $1 WHERE user_id = $2", passwordHash, userID)
+	if err != nil {
+		zap.S().Error("Error updating user password: ", err)
+		return fmt.Errorf("error updating user password: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("error updating user password: %w", sql.ErrNoRows)
+	}
+	if _, err := tx.Exec("DELETE FROM password_reset_token WHERE user_id = $1", userID); err != nil {
+		zap.S().Error("Error revoking password reset tokens: ", err)
+		return fmt.Errorf("error revoking password reset tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// CreatePasswordResetToken stores a reset token hash, revoking older unused tokens of the user
+func (db *DB) CreatePasswordResetToken(userID int, tokenHash string, expiresAt time.Time) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM password_reset_token WHERE user_id = $1 AND used_at IS NULL", userID); err != nil {
+		zap.S().Error("Error revoking password reset tokens: ", err)
+		return fmt.Errorf("error revoking password reset tokens: %w", err)
+	}
+	if _, err := tx.Exec("INSERT INTO password_reset_token (user_id, token_hash, expires_at) VALUES ($1, $2, $3)", userID, tokenHash, expiresAt); err != nil {
+		zap.S().Error("Error creating password reset token: ", err)
+		return fmt.Errorf("error creating password reset token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// GetPasswordResetUser returns the user of a reset token without consuming it
+//
+// It returns ErrInvalidResetToken if the token is unknown, expired or already used.
+func (db *DB) GetPasswordResetUser(tokenHash string) (int, error) {
+	var userID int
+	err := db.Get(&userID, `
+		SELECT user_id FROM password_reset_token
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP`,
+		tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidResetToken
+	}
+	if err != nil {
+		zap.S().Error("Error getting password reset token: ", err)
+		return 0, fmt.Errorf("error getting password reset token: %w", err)
+	}
+	return userID, nil
+}
+
+// ResetPassword consumes a reset token and sets the new password hash of its user
+//
+// It returns ErrInvalidResetToken if the token is unknown, expired or already used.
+// A successful reset also lifts any login lock on the account.
+func (db *DB) ResetPassword(tokenHash, passwordHash string) (int, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return 0, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.Get(&userID, `
+		UPDATE password_reset_token SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id`,
+		tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidResetToken
+	}
+	if err != nil {
+		zap.S().Error("Error consuming password reset token: ", err)
+		return 0, fmt.Errorf("error consuming password reset token: %w", err)
+	}
+
+	if _, err := tx.Exec("UPDATE \"user\" SET password = $1 WHERE user_id = $2", passwordHash, userID); err != nil {
+		zap.S().Error("Error updating user password: ", err)
+		return 0, fmt.Errorf("error updating user password: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM login_failure WHERE user_id = $1", userID); err != nil {
+		zap.S().Error("Error clearing login failures: ", err)
+		return 0, fmt.Errorf("error clearing login failures: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return userID, nil
+}
+
+// CreateEmailChangeRequest stores a pending email change, replacing any earlier unconfirmed one
+func (db *DB) CreateEmailChangeRequest(userID int, newEmail, tokenHash string, expiresAt time.Time) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM email_change_request WHERE user_id = $1 AND used_at IS NULL", userID); err != nil {
+		zap.S().Error("Error revoking email change requests: ", err)
+		return fmt.Errorf("error revoking email change requests: %w", err)
+	}
+	if _, err := tx.Exec("INSERT INTO email_change_request (user_id, new_email, token_hash, expires_at) VALUES ($1, $2, $3, $4)", userID, newEmail, tokenHash, expiresAt); err != nil {
+		zap.S().Error("Error creating email change request: ", err)
+		return fmt.Errorf("error creating email change request: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// ConfirmEmailChange consumes a confirmation token and applies the new email of its request
+//
+// It returns ErrInvalidEmailToken if the token is unknown, expired or already used,
+// and ErrEmailTaken if another account took the address since the change was requested
+func (db *DB) ConfirmEmailChange(tokenHash string) (types.EmailChangeRequest, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.EmailChangeRequest{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var request types.EmailChangeRequest
+	err = tx.Get(&request, `
+		UPDATE email_change_request SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING *`,
+		tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.EmailChangeRequest{}, ErrInvalidEmailToken
+	}
+	if err != nil {
+		zap.S().Error("Error consuming email change request: ", err)
+		return types.EmailChangeRequest{}, fmt.Errorf("error consuming email change request: %w", err)
+	}
+
+	var taken bool
+	if err := tx.Get(&taken, "SELECT EXISTS (SELECT 1 FROM \"user\" WHERE email = $1 AND user_id <> $2)", request.NewEmail, request.UserID); err != nil {
+		zap.S().Error("Error checking email availability: ", err)
+		return types.EmailChangeRequest{}, fmt.Errorf("error checking email availability: %w", err)
+	}
+	if taken {
+		return types.EmailChangeRequest{}, ErrEmailTaken
+	}
+	_, err = tx.Exec("UPDATE \"user\" SET email = $1 WHERE user_id = $2", request.NewEmail, request.UserID)
+	// An account taking the address concurrently still trips the unique constraint
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return types.EmailChangeRequest{}, ErrEmailTaken
+	}
+	if err != nil {
+		zap.S().Error("Error updating user email: ", err)
+		return types.EmailChangeRequest{}, fmt.Errorf("error updating user email: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.EmailChangeRequest{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return request, nil
+}
@@ -0,0 +1,410 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+var (
+	// ErrHarvestOverAllocated is returned when blending more of a harvest than is left unallocated
+	ErrHarvestOverAllocated = errors.New("harvest quantity is already allocated to batches")
+	// ErrHarvestAllocated is returned when moving a harvest allocated to batches to another hive or date
+	ErrHarvestAllocated = errors.New("harvest is allocated to batches, its hive and date cannot change")
+	// ErrHarvestBelowAllocated is returned when lowering a harvest's quantity below what is allocated to batches
+	ErrHarvestBelowAllocated = errors.New("harvest quantity is below what is allocated to batches")
+	// ErrBatchBottled is returned when changing the contributions of a batch that was already bottled
+	ErrBatchBottled = errors.New("batch was already bottled")
+	// ErrBatchOverBottled is returned when bottling more honey than a batch has left
+	ErrBatchOverBottled = errors.New("jars exceed the honey left in the batch")
+)
+
+// quantityTolerance absorbs float rounding when comparing honey quantities
+const quantityTolerance = 1e-6
+
+// batchColumns selects a batch with its blended quantity and its grade, from the
+// latest graded lab result of the batch or else the worst grade of its harvests
+const batchColumns = `
+	b.*,
+	COALESCE((SELECT SUM(bh.quantity) FROM batch_harvest bh WHERE bh.batch_id = b.batch_id), 0) AS quantity,
+	COALESCE(
+		(SELECT lr.grade FROM lab_result lr
+			WHERE lr.batch_id = b.batch_id AND lr.grade IS NOT NULL
+			ORDER BY lr.sample_date DESC, lr.result_id DESC LIMIT 1),
+		(SELECT MAX(hh.quality_grade) FROM batch_harvest bh
+			JOIN honey_harvest hh ON hh.harvest_id = bh.harvest_id
+			WHERE bh.batch_id = b.batch_id)
+	) AS quality_grade`
+
+// addContributions blends parts of harvests into a batch, refusing to allocate more than a harvest has left
+func addContributions(tx *sqlx.Tx, batchID int, contributions []types.BatchContribution) error {
+	for _, contribution := range contributions {
+		var unallocated float64
+		err := tx.Get(&unallocated, "SELECT COALESCE(harvest_unallocated(harvest_id), 0) FROM honey_harvest WHERE harvest_id = $1 FOR UPDATE", contribution.HarvestID)
+		if err != nil {
+			zap.S().Error("Error locking harvest: ", err)
+			return fmt.Errorf("error locking harvest %d: %w", contribution.HarvestID, err)
+		}
+		if contribution.Quantity > unallocated+quantityTolerance {
+			return fmt.Errorf("%w: harvest %d has %.2f left", ErrHarvestOverAllocated, contribution.HarvestID, unallocated)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO batch_harvest (batch_id, harvest_id, quantity)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (batch_id, harvest_id) DO UPDATE SET quantity = batch_harvest.quantity + EXCLUDED.quantity`,
+			batchID, contribution.HarvestID, contribution.Quantity)
+		if err != nil {
+			zap.S().Error("Error adding batch harvest: ", err)
+			return fmt.Errorf("error adding batch harvest: %w", err)
+		}
+	}
+	return nil
+}
+
+// lockBatch locks a batch, failing with ErrBatchBottled if it has jar lots and unbottled is set
+func lockBatch(tx *sqlx.Tx, id int, unbottled bool) error {
+	var bottled bool
+	err := tx.Get(&bottled, `
+		SELECT EXISTS (SELECT 1 FROM jar_lot WHERE batch_id = $1)
+		FROM honey_batch WHERE batch_id = $1 FOR UPDATE`,
+		id)
+	if err != nil {
+		zap.S().Error("Error locking honey batch: ", err)
+		return fmt.Errorf("error locking honey batch: %w", err)
+	}
+	if unbottled && bottled {
+		return ErrBatchBottled
+	}
+	return nil
+}
+
+// CreateHoneyBatch blends parts of harvests into a new batch
+func (db *DB) CreateHoneyBatch(request types.HoneyBatchRequest, createdBy int) (types.HoneyBatch, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.HoneyBatch{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var batchID int
+	err = tx.Get(&batchID, `
+		INSERT INTO honey_batch (lot_number, created_date, notes, created_by)
+		VALUES ($1, $2, $3, NULLIF($4, 0))
+		RETURNING batch_id`,
+		request.LotNumber, request.CreatedDate, request.Notes, createdBy)
+	if err != nil {
+		zap.S().Error("Error creating honey batch: ", err)
+		return types.HoneyBatch{}, fmt.Errorf("error creating honey batch: %w", err)
+	}
+	if err := addContributions(tx, batchID, request.Harvests); err != nil {
+		return types.HoneyBatch{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.HoneyBatch{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetHoneyBatch(batchID)
+}
+
+// AddBatchHarvests blends more harvests into a batch that was not bottled yet
+func (db *DB) AddBatchHarvests(batchID int, contributions []types.BatchContribution) (types.HoneyBatch, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.HoneyBatch{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockBatch(tx, batchID, true); err != nil {
+		return types.HoneyBatch{}, err
+	}
+	if err := addContributions(tx, batchID, contributions); err != nil {
+		return types.HoneyBatch{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.HoneyBatch{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetHoneyBatch(batchID)
+}
+
+// GetHoneyBatch returns a batch with its contributions, steps and jar lots
+func (db *DB) GetHoneyBatch(id int) (types.HoneyBatch, error) {
+	var batch types.HoneyBatch
+	err := db.Get(&batch, "SELECT "+batchColumns+" FROM honey_batch b WHERE b.batch_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting honey batch: ", err)
+		return types.HoneyBatch{}, fmt.Errorf("error getting honey batch: %w", err)
+	}
+
+	batch.Contributions = []types.BatchContribution{}
+	err = db.Select(&batch.Contributions, `
+		SELECT bh.*, hh.hive_id, hh.harvest_date, hh.quality_grade
+		FROM batch_harvest bh
+		JOIN honey_harvest hh ON hh.harvest_id = bh.harvest_id
+		WHERE bh.batch_id = $1
+		ORDER BY hh.harvest_date, bh.harvest_id`,
+		id)
+	if err != nil {
+		zap.S().Error("Error getting batch harvests: ", err)
+		return types.HoneyBatch{}, fmt.Errorf("error getting batch harvests: %w", err)
+	}
+	batch.Steps = []types.BatchStep{}
+	if err := db.Select(&batch.Steps, "SELECT * FROM batch_step WHERE batch_id = $1 ORDER BY step_date, step_id", id); err != nil {
+		zap.S().Error("Error getting batch steps: ", err)
+		return types.HoneyBatch{}, fmt.Errorf("error getting batch steps: %w", err)
+	}
+	batch.JarLots = []types.JarLot{}
+	if err := db.Select(&batch.JarLots, "SELECT * FROM jar_lot WHERE batch_id = $1 ORDER BY bottled_date, jar_lot_id", id); err != nil {
+		zap.S().Error("Error getting jar lots: ", err)
+		return types.HoneyBatch{}, fmt.Errorf("error getting jar lots: %w", err)
+	}
+	return batch, nil
+}
+
+// GetHoneyBatches returns every batch without its details, the latest first
+func (db *DB) GetHoneyBatches() ([]types.HoneyBatch, error) {
+	batches := []types.HoneyBatch{}
+	err := db.Select(&batches, "SELECT "+batchColumns+" FROM honey_batch b ORDER BY b.created_date DESC, b.batch_id DESC")
+	if err != nil {
+		zap.S().Error("Error getting honey batches: ", err)
+		return nil, fmt.Errorf("error getting honey batches: %w", err)
+	}
+	return batches, nil
+}
+
+// DeleteHoneyBatch deletes a batch that was not bottled, releasing its harvests
+func (db *DB) DeleteHoneyBatch(id int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockBatch(tx, id, true); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM honey_batch WHERE batch_id = $1", id); err != nil {
+		zap.S().Error("Error deleting honey batch: ", err)
+		return fmt.Errorf("error deleting honey batch: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// AddBatchStep records a processing step of a batch
+func (db *DB) AddBatchStep(step types.BatchStep) (types.BatchStep, error) {
+	var createdStep types.BatchStep
+	err := db.Get(&createdStep, `
+		INSERT INTO batch_step (batch_id, step, step_date, output_quantity, performed_by, notes)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING *`,
+		step.BatchID, step.Step, step.StepDate, step.OutputQuantity, step.PerformedBy, step.Notes)
+	if err != nil {
+		zap.S().Error("Error adding batch step: ", err)
+		return types.BatchStep{}, fmt.Errorf("error adding batch step: %w", err)
+	}
+	return createdStep, nil
+}
+
+// batchAvailableKg returns the honey of a batch not yet bottled or stocked in bulk
+//
+// That is the output of the batch's latest processing step, or its blended
+// quantity before any, minus the jar lots and the bulk stock received from it
+func batchAvailableKg(tx *sqlx.Tx, batchID int) (float64, error) {
+	var available float64
+	err := tx.Get(&available, `
+		SELECT COALESCE(
+			(SELECT bs.output_quantity FROM batch_step bs
+				WHERE bs.batch_id = $1 AND bs.step <> $2 AND bs.output_quantity IS NOT NULL
+				ORDER BY bs.step_date DESC, bs.step_id DESC LIMIT 1),
+			(SELECT SUM(bh.quantity) FROM batch_harvest bh WHERE bh.batch_id = $1),
+			0
+		)
+		- COALESCE((SELECT SUM(jl.jar_size_g * jl.jar_count) / 1000.0 FROM jar_lot jl WHERE jl.batch_id = $1), 0)
+		- COALESCE((SELECT SUM(sm.quantity_change * si.unit_weight_kg)
+			FROM stock_movement sm
+			JOIN stock_item si ON si.stock_id = sm.stock_id
+			WHERE si.batch_id = $1 AND si.jar_lot_id IS NULL AND sm.reason = $3), 0)`,
+		batchID, types.StepBottling, types.ReasonReceipt)
+	if err != nil {
+		zap.S().Error("Error getting batch availability: ", err)
+		return 0, fmt.Errorf("error getting batch availability: %w", err)
+	}
+	return available, nil
+}
+
+// CreateJarLot bottles part of a batch into jars, recording the bottling step
+func (db *DB) CreateJarLot(lot types.JarLot, performedBy int) (types.JarLot, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.JarLot{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockBatch(tx, lot.BatchID, false); err != nil {
+		return types.JarLot{}, err
+	}
+	available, err := batchAvailableKg(tx, lot.BatchID)
+	if err != nil {
+		return types.JarLot{}, err
+	}
+	bottled := float64(lot.JarSizeG*lot.JarCount) / 1000
+	if bottled > available+quantityTolerance {
+		return types.JarLot{}, fmt.Errorf("%w: %.2f left, %.2f requested", ErrBatchOverBottled, available, bottled)
+	}
+
+	var createdLot types.JarLot
+	err = tx.Get(&createdLot, `
+		INSERT INTO jar_lot (batch_id, lot_number, jar_size_g, jar_count, bottled_date, best_before)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING *`,
+		lot.BatchID, lot.LotNumber, lot.JarSizeG, lot.JarCount, lot.BottledDate, lot.BestBefore)
+	if err != nil {
+		zap.S().Error("Error creating jar lot: ", err)
+		return types.JarLot{}, fmt.Errorf("error creating jar lot: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO batch_step (batch_id, step, step_date, output_quantity, performed_by, notes)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6)`,
+		lot.BatchID, types.StepBottling, lot.BottledDate, bottled, performedBy,
+		fmt.Sprintf("Jar lot %s: %d x %d g", lot.LotNumber, lot.JarCount, lot.JarSizeG))
+	if err != nil {
+		zap.S().Error("Error recording bottling step: ", err)
+		return types.JarLot{}, fmt.Errorf("error recording bottling step: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.JarLot{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return createdLot, nil
+}
+
+// TraceLot follows a batch or jar lot number back to the harvests, hives and apiaries
+// it came from, the treatments of those hives in the lookbackDays before each
+// harvest, their last inspections, the lab results and any outbreak quarantining
+// them at the time
+func (db *DB) TraceLot(lotNumber string, lookbackDays int) (types.LotTrace, error) {
+	trace := types.LotTrace{LotNumber: lotNumber}
+
+	var jarLot types.JarLot
+	batchID := 0
+	err := db.Get(&jarLot, "SELECT * FROM jar_lot WHERE lot_number = $1", lotNumber)
+	if err == nil {
+		trace.JarLot = &jarLot
+		batchID = jarLot.BatchID
+	} else if err := db.Get(&batchID, "SELECT batch_id FROM honey_batch WHERE lot_number = $1", lotNumber); err != nil {
+		zap.S().Error("Error finding lot: ", err)
+		return types.LotTrace{}, fmt.Errorf("error finding lot %s: %w", lotNumber, err)
+	}
+
+	trace.Batch, err = db.GetHoneyBatch(batchID)
+	if err != nil {
+		return types.LotTrace{}, err
+	}
+
+	trace.Harvests = []types.TracedHarvest{}
+	err = db.Select(&trace.Harvests, `
+		SELECT hh.harvest_id, hh.hive_id, a.apiary_id, a.location, hh.harvest_date, bh.quantity,
+			hh.quality_grade, hh.withdrawal_override_reason
+		FROM batch_harvest bh
+		JOIN honey_harvest hh ON hh.harvest_id = bh.harvest_id
+		JOIN apiary a ON a.apiary_id = hive_apiary_on(hh.hive_id, hh.harvest_date)
+		WHERE bh.batch_id = $1
+		ORDER BY hh.harvest_date, hh.harvest_id`,
+		batchID)
+	if err != nil {
+		zap.S().Error("Error tracing harvests: ", err)
+		return types.LotTrace{}, fmt.Errorf("error tracing harvests: %w", err)
+	}
+	trace.Apiaries = []int{}
+	seen := map[int]bool{}
+	for _, harvest := range trace.Harvests {
+		if !seen[harvest.ApiaryID] {
+			seen[harvest.ApiaryID] = true
+			trace.Apiaries = append(trace.Apiaries, harvest.ApiaryID)
+		}
+	}
+
+	trace.Treatments = []types.TracedTreatment{}
+	err = db.Select(&trace.Treatments, `
+		SELECT `+applicationColumns+`, tp.name AS product_name, tp.active_substance
+		FROM treatment_application ta
+		JOIN treatment_product tp ON tp.product_id = ta.product_id
+		WHERE ta.application_id IN (
+			SELECT ta2.application_id
+			FROM batch_harvest bh
+			JOIN honey_harvest hh ON hh.harvest_id = bh.harvest_id
+			JOIN treatment_application ta2 ON ta2.hive_id = hh.hive_id
+			WHERE bh.batch_id = $1
+				AND ta2.start_date <= hh.harvest_date
+				AND COALESCE(treatment_withdrawal_end(ta2.application_id), hh.harvest_date) >= hh.harvest_date - $2::integer
+		)
+		ORDER BY ta.hive_id, ta.start_date`,
+		batchID, lookbackDays)
+	if err != nil {
+		zap.S().Error("Error tracing treatments: ", err)
+		return types.LotTrace{}, fmt.Errorf("error tracing treatments: %w", err)
+	}
+
+	trace.Inspections = []types.TracedInspection{}
+	err = db.Select(&trace.Inspections, `
+		SELECT hh.hive_id, hh.harvest_date, i.inspection_id, i.inspection_date,
+			hh.harvest_date - i.inspection_date AS days_before_harvest, i.notes
+		FROM batch_harvest bh
+		JOIN honey_harvest hh ON hh.harvest_id = bh.harvest_id
+		LEFT JOIN LATERAL (
+			SELECT * FROM inspection
+			WHERE hive_id = hh.hive_id AND inspection_date <= hh.harvest_date
+			ORDER BY inspection_date DESC, inspection_id DESC
+			LIMIT 1
+		) i ON TRUE
+		WHERE bh.batch_id = $1
+		ORDER BY hh.hive_id, hh.harvest_date`,
+		batchID)
+	if err != nil {
+		zap.S().Error("Error tracing inspections: ", err)
+		return types.LotTrace{}, fmt.Errorf("error tracing inspections: %w", err)
+	}
+
+	trace.LabResults, err = db.GetLabResults(LabResultFilter{BatchID: batchID})
+	if err != nil {
+		return types.LotTrace{}, err
+	}
+
+	trace.Outbreaks = []types.Outbreak{}
+	err = db.Select(&trace.Outbreaks, `
+		SELECT `+outbreakColumns+`
+		FROM outbreak o
+		JOIN disease d ON d.disease_id = o.disease_id
+		WHERE EXISTS (
+			SELECT 1
+			FROM batch_harvest bh
+			JOIN honey_harvest hh ON hh.harvest_id = bh.harvest_id
+			WHERE bh.batch_id = $1
+				AND o.declared_date <= hh.harvest_date
+				AND (o.lifted_date IS NULL OR o.lifted_date >= hh.harvest_date)
+				AND hive_apiary_on(hh.hive_id, hh.harvest_date) IN (SELECT outbreak_zone_apiaries(o.outbreak_id))
+		)
+		ORDER BY o.declared_date`,
+		batchID)
+	if err != nil {
+		zap.S().Error("Error tracing outbreaks: ", err)
+		return types.LotTrace{}, fmt.Errorf("error tracing outbreaks: %w", err)
+	}
+	return trace, nil
+}
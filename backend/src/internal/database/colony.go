@@ -0,0 +1,407 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+var (
+	// ErrCommunityDissolved is returned when operating on a community that was merged away
+	ErrCommunityDissolved = errors.New("bee community was dissolved")
+	// ErrInsufficientPopulation is returned when a split takes more bees than the community has
+	ErrInsufficientPopulation = errors.New("split exceeds the community's population")
+	// ErrHiveOccupied is returned when splitting into a hive that already has a community
+	ErrHiveOccupied = errors.New("target hive already has a bee community")
+	// ErrInvalidRelocation is returned when a relocation does not move the hive or predates its current placement
+	ErrInvalidRelocation = errors.New("hive is already at that apiary or was placed there after the relocation date")
+)
+
+// insertColonyEvent records a colony operation inside its transaction
+func insertColonyEvent(tx *sqlx.Tx, event types.ColonyEvent) (types.ColonyEvent, error) {
+	var createdEvent types.ColonyEvent
+	err := tx.Get(&createdEvent, `
+		INSERT INTO colony_event (
+			kind, event_date, source_hive_id, target_hive_id, source_community_id, target_community_id,
+			from_apiary_id, to_apiary_id, population_moved, performed_by, notes
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING *`,
+		event.Kind, event.EventDate, event.SourceHiveID, event.TargetHiveID, event.SourceCommunityID, event.TargetCommunityID,
+		event.FromApiaryID, event.ToApiaryID, event.PopulationMoved, event.PerformedBy, event.Notes)
+	if err != nil {
+		zap.S().Error("Error recording colony event: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error recording colony event: %w", err)
+	}
+	return createdEvent, nil
+}
+
+// lockCommunity locks a community that has not been dissolved
+func lockCommunity(tx *sqlx.Tx, id int) (types.BeeCommunity, error) {
+	var community types.BeeCommunity
+	err := tx.Get(&community, "SELECT "+communityColumns+" FROM bee_community WHERE community_id = $1 FOR UPDATE", id)
+	if err != nil {
+		zap.S().Error("Error locking bee community: ", err)
+		return types.BeeCommunity{}, fmt.Errorf("error locking bee community %d: %w", id, err)
+	}
+	if community.DissolvedDate.Valid {
+		return types.BeeCommunity{}, ErrCommunityDissolved
+	}
+	return community, nil
+}
+
+// SplitColony moves part of a community into a new community, creating its hive if needed
+func (db *DB) SplitColony(split types.ColonySplitRequest, performedBy int) (types.ColonyEvent, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	source, err := lockCommunity(tx, split.SourceCommunityID)
+	if err != nil {
+		return types.ColonyEvent{}, err
+	}
+	if source.PopulationEstimate < split.PopulationMoved {
+		return types.ColonyEvent{}, ErrInsufficientPopulation
+	}
+
+	var sourceApiaryID int
+	if err := tx.Get(&sourceApiaryID, "SELECT apiary_id FROM hive WHERE hive_id = $1", source.HiveID); err != nil {
+		zap.S().Error("Error getting source hive: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error getting source hive: %w", err)
+	}
+
+	targetHiveID := int(split.TargetHiveID.Int64)
+	if split.TargetHiveID.Valid {
+		var occupied bool
+		err := tx.Get(&occupied, `
+			SELECT EXISTS (SELECT 1 FROM bee_community WHERE hive_id = $1 AND dissolved_date IS NULL)
+			FROM hive WHERE hive_id = $1 FOR UPDATE`,
+			targetHiveID)
+		if err != nil {
+			zap.S().Error("Error checking target hive: ", err)
+			return types.ColonyEvent{}, fmt.Errorf("error checking target hive: %w", err)
+		}
+		if occupied {
+			return types.ColonyEvent{}, ErrHiveOccupied
+		}
+		var targetApiaryID int
+		if err := tx.Get(&targetApiaryID, "SELECT apiary_id FROM hive WHERE hive_id = $1", targetHiveID); err != nil {
+			zap.S().Error("Error getting target hive: ", err)
+			return types.ColonyEvent{}, fmt.Errorf("error getting target hive: %w", err)
+		}
+		if err := checkQuarantine(tx, sourceApiaryID, targetApiaryID); err != nil {
+			return types.ColonyEvent{}, err
+		}
+	} else {
+		apiaryID := split.ApiaryID.ValueOrZero()
+		if apiaryID == 0 {
+			apiaryID = int64(sourceApiaryID)
+		}
+		if err := checkQuarantine(tx, sourceApiaryID, int(apiaryID)); err != nil {
+			return types.ColonyEvent{}, err
+		}
+		err := tx.Get(&targetHiveID, `
+			INSERT INTO hive (apiary_id, hive_type, installation_date, current_status)
+			VALUES ($1, $2, $3, $4)
+			RETURNING hive_id`,
+			apiaryID, split.HiveType, split.EventDate, types.HiveActive)
+		if err != nil {
+			zap.S().Error("Error creating split hive: ", err)
+			return types.ColonyEvent{}, fmt.Errorf("error creating split hive: %w", err)
+		}
+	}
+
+	var targetCommunityID int
+	err = tx.Get(&targetCommunityID, `
+		INSERT INTO bee_community (hive_id, queen_age, population_estimate, health_status)
+		VALUES ($1, 0, $2, $3)
+		RETURNING community_id`,
+		targetHiveID, split.PopulationMoved, source.HealthStatus)
+	if err != nil {
+		zap.S().Error("Error creating split community: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error creating split community: %w", err)
+	}
+	_, err = tx.Exec("UPDATE bee_community SET population_estimate = population_estimate - $2 WHERE community_id = $1", source.CommunityID, split.PopulationMoved)
+	if err != nil {
+		zap.S().Error("Error updating source community: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error updating source community: %w", err)
+	}
+
+	event, err := insertColonyEvent(tx, types.ColonyEvent{
+		Kind:              types.ColonySplit,
+		EventDate:         split.EventDate,
+		SourceHiveID:      null.IntFrom(int64(source.HiveID)),
+		TargetHiveID:      null.IntFrom(int64(targetHiveID)),
+		SourceCommunityID: null.IntFrom(int64(source.CommunityID)),
+		TargetCommunityID: null.IntFrom(int64(targetCommunityID)),
+		PopulationMoved:   null.IntFrom(int64(split.PopulationMoved)),
+		PerformedBy:       null.NewInt(int64(performedBy), performedBy > 0),
+		Notes:             split.Notes,
+	})
+	if err != nil {
+		return types.ColonyEvent{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return event, nil
+}
+
+// MergeColonies combines the source community into the target one
+//
+// The source community is dissolved and the queen that is not kept is recorded
+// as replaced. A source hive left without communities becomes inactive.
+func (db *DB) MergeColonies(merge types.ColonyMergeRequest, performedBy int) (types.ColonyEvent, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock in a fixed order so opposite concurrent merges cannot deadlock
+	first, second := merge.SourceCommunityID, merge.TargetCommunityID
+	if first > second {
+		first, second = second, first
+	}
+	locked := map[int]types.BeeCommunity{}
+	for _, id := range []int{first, second} {
+		community, err := lockCommunity(tx, id)
+		if err != nil {
+			return types.ColonyEvent{}, err
+		}
+		locked[id] = community
+	}
+	source, target := locked[merge.SourceCommunityID], locked[merge.TargetCommunityID]
+
+	var apiaries struct {
+		Source int `db:"source"`
+		Target int `db:"target"`
+	}
+	err = tx.Get(&apiaries, `
+		SELECT s.apiary_id AS source, t.apiary_id AS target
+		FROM hive s, hive t
+		WHERE s.hive_id = $1 AND t.hive_id = $2`,
+		source.HiveID, target.HiveID)
+	if err != nil {
+		zap.S().Error("Error getting merged hives: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error getting merged hives: %w", err)
+	}
+	if err := checkQuarantine(tx, apiaries.Source, apiaries.Target); err != nil {
+		return types.ColonyEvent{}, err
+	}
+
+	replacedCommunity := source.CommunityID
+	if merge.KeepQueen == types.KeepSourceQueen {
+		replacedCommunity = target.CommunityID
+	}
+	_, err = tx.Exec(`
+		WITH replaced AS (
+			UPDATE queen_introduction
+			SET removed_date = GREATEST($2, introduced_date), removal_reason = 'replaced'
+			WHERE community_id = $1 AND removed_date IS NULL
+			RETURNING queen_id
+		)
+		UPDATE queen SET status = 'replaced' WHERE queen_id IN (SELECT queen_id FROM replaced)`,
+		replacedCommunity, merge.EventDate)
+	if err != nil {
+		zap.S().Error("Error replacing merged queen: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error replacing merged queen: %w", err)
+	}
+	if merge.KeepQueen == types.KeepSourceQueen {
+		_, err = tx.Exec(`
+			WITH moved AS (
+				UPDATE queen_introduction
+				SET removed_date = GREATEST($3, introduced_date), removal_reason = $4
+				WHERE community_id = $1 AND removed_date IS NULL
+				RETURNING queen_id
+			)
+			INSERT INTO queen_introduction (queen_id, community_id, introduced_date)
+			SELECT queen_id, $2, $3 FROM moved`,
+			source.CommunityID, target.CommunityID, merge.EventDate, types.QueenMoved)
+		if err != nil {
+			zap.S().Error("Error moving merged queen: ", err)
+			return types.ColonyEvent{}, fmt.Errorf("error moving merged queen: %w", err)
+		}
+	}
+
+	_, err = tx.Exec("UPDATE bee_community SET population_estimate = population_estimate + $2 WHERE community_id = $1", target.CommunityID, source.PopulationEstimate)
+	if err != nil {
+		zap.S().Error("Error updating merged community: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error updating merged community: %w", err)
+	}
+	_, err = tx.Exec("UPDATE bee_community SET dissolved_date = $2, merged_into = $3 WHERE community_id = $1", source.CommunityID, merge.EventDate, target.CommunityID)
+	if err != nil {
+		zap.S().Error("Error dissolving merged community: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error dissolving merged community: %w", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE hive SET current_status = $2
+		WHERE hive_id = $1 AND NOT EXISTS (
+			SELECT 1 FROM bee_community WHERE hive_id = $1 AND dissolved_date IS NULL
+		)`,
+		source.HiveID, types.HiveInactive)
+	if err != nil {
+		zap.S().Error("Error updating source hive status: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error updating source hive status: %w", err)
+	}
+
+	event, err := insertColonyEvent(tx, types.ColonyEvent{
+		Kind:              types.ColonyMerge,
+		EventDate:         merge.EventDate,
+		SourceHiveID:      null.IntFrom(int64(source.HiveID)),
+		TargetHiveID:      null.IntFrom(int64(target.HiveID)),
+		SourceCommunityID: null.IntFrom(int64(source.CommunityID)),
+		TargetCommunityID: null.IntFrom(int64(target.CommunityID)),
+		PopulationMoved:   null.IntFrom(int64(source.PopulationEstimate)),
+		PerformedBy:       null.NewInt(int64(performedBy), performedBy > 0),
+		Notes:             merge.Notes,
+	})
+	if err != nil {
+		return types.ColonyEvent{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return event, nil
+}
+
+// RelocateHive moves a hive to another apiary on the given date, keeping its placement history
+func (db *DB) RelocateHive(hiveID int, relocation types.HiveRelocationRequest, performedBy int) (types.ColonyEvent, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	event, err := relocateHive(tx, hiveID, relocation, null.Int{}, performedBy)
+	if err != nil {
+		return types.ColonyEvent{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return event, nil
+}
+
+// relocateHive moves a hive within tx, closing its current placement; placements
+// at a pollination field carry the contract the hive was rented out under
+func relocateHive(tx *sqlx.Tx, hiveID int, relocation types.HiveRelocationRequest, contractID null.Int, performedBy int) (types.ColonyEvent, error) {
+	var fromApiaryID int
+	if err := tx.Get(&fromApiaryID, "SELECT apiary_id FROM hive WHERE hive_id = $1 FOR UPDATE", hiveID); err != nil {
+		zap.S().Error("Error locking hive: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error locking hive: %w", err)
+	}
+	if fromApiaryID == relocation.ToApiaryID {
+		return types.ColonyEvent{}, ErrInvalidRelocation
+	}
+	if err := checkQuarantine(tx, fromApiaryID, relocation.ToApiaryID); err != nil {
+		return types.ColonyEvent{}, err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE hive_placement SET removed_date = $2
+		WHERE hive_id = $1 AND removed_date IS NULL AND placed_date <= $2`,
+		hiveID, relocation.EventDate)
+	if err != nil {
+		zap.S().Error("Error closing hive placement: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error closing hive placement: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		var placed bool
+		if err := tx.Get(&placed, "SELECT EXISTS (SELECT 1 FROM hive_placement WHERE hive_id = $1 AND removed_date IS NULL)", hiveID); err != nil {
+			zap.S().Error("Error checking hive placement: ", err)
+			return types.ColonyEvent{}, fmt.Errorf("error checking hive placement: %w", err)
+		}
+		if placed {
+			return types.ColonyEvent{}, ErrInvalidRelocation
+		}
+	}
+
+	_, err = tx.Exec("INSERT INTO hive_placement (hive_id, apiary_id, placed_date, contract_id) VALUES ($1, $2, $3, $4)", hiveID, relocation.ToApiaryID, relocation.EventDate, contractID)
+	if err != nil {
+		zap.S().Error("Error creating hive placement: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error creating hive placement: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE hive SET apiary_id = $2 WHERE hive_id = $1", hiveID, relocation.ToApiaryID); err != nil {
+		zap.S().Error("Error relocating hive: ", err)
+		return types.ColonyEvent{}, fmt.Errorf("error relocating hive: %w", err)
+	}
+
+	event, err := insertColonyEvent(tx, types.ColonyEvent{
+		Kind:         types.ColonyRelocate,
+		EventDate:    relocation.EventDate,
+		SourceHiveID: null.IntFrom(int64(hiveID)),
+		FromApiaryID: null.IntFrom(int64(fromApiaryID)),
+		ToApiaryID:   null.IntFrom(int64(relocation.ToApiaryID)),
+		PerformedBy:  null.NewInt(int64(performedBy), performedBy > 0),
+		Notes:        relocation.Notes,
+	})
+	return event, err
+}
+
+// GetHivePlacements returns where a hive stood over time, the latest first
+func (db *DB) GetHivePlacements(hiveID int) ([]types.HivePlacement, error) {
+	placements := []types.HivePlacement{}
+	err := db.Select(&placements, `
+		SELECT hp.*, a.location
+		FROM hive_placement hp
+		JOIN apiary a ON a.apiary_id = hp.apiary_id
+		WHERE hp.hive_id = $1
+		ORDER BY hp.placed_date DESC, hp.placement_id DESC`,
+		hiveID)
+	if err != nil {
+		zap.S().Error("Error getting hive placements: ", err)
+		return nil, fmt.Errorf("error getting hive placements: %w", err)
+	}
+	return placements, nil
+}
+
+// GetColonyEvents returns the colony events involving a hive, or all of them for hive 0, the latest first
+func (db *DB) GetColonyEvents(hiveID int) ([]types.ColonyEvent, error) {
+	events := []types.ColonyEvent{}
+	err := db.Select(&events, `
+		SELECT * FROM colony_event
+		WHERE $1 = 0 OR source_hive_id = $1 OR target_hive_id = $1
+		ORDER BY event_date DESC, event_id DESC`,
+		hiveID)
+	if err != nil {
+		zap.S().Error("Error getting colony events: ", err)
+		return nil, fmt.Errorf("error getting colony events: %w", err)
+	}
+	return events, nil
+}
+
+// GetHarvestTotalsByApiary sums harvests per apiary, attributing each one to
+// the apiary its hive stood at on the harvest date
+func (db *DB) GetHarvestTotalsByApiary(from, to null.Time) ([]types.ApiaryHarvestTotal, error) {
+	totals := []types.ApiaryHarvestTotal{}
+	err := db.Select(&totals, `
+		SELECT a.apiary_id, a.location, COALESCE(SUM(hh.quantity), 0) AS total_quantity, COUNT(*) AS harvest_count
+		FROM honey_harvest hh
+		JOIN apiary a ON a.apiary_id = hive_apiary_on(hh.hive_id, hh.harvest_date)
+		WHERE ($1::date IS NULL OR hh.harvest_date >= $1)
+			AND ($2::date IS NULL OR hh.harvest_date <= $2)
+		GROUP BY a.apiary_id, a.location
+		ORDER BY a.apiary_id`,
+		from, to)
+	if err != nil {
+		zap.S().Error("Error getting harvest totals by apiary: ", err)
+		return nil, fmt.Errorf("error getting harvest totals by apiary: %w", err)
+	}
+	return totals, nil
+}
@@ -0,0 +1,536 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+var (
+	// ErrInsufficientEquipment is returned when taking more items from a location or a hive than it has
+	ErrInsufficientEquipment = errors.New("not enough equipment")
+	// ErrNotHiveComponent is returned when putting equipment that does not go on hives on a hive
+	ErrNotHiveComponent = errors.New("equipment is not a hive component")
+	// ErrCheckedIn is returned when checking in equipment twice
+	ErrCheckedIn = errors.New("equipment was already checked in")
+)
+
+// EquipmentStockFilter narrows down the stock returned by GetEquipmentStock
+type EquipmentStockFilter struct {
+	TypeID      int
+	ApiaryID    int
+	WarehouseID int
+}
+
+// CheckoutFilter narrows down the check-outs returned by GetEquipmentCheckouts
+type CheckoutFilter struct {
+	UserID   int
+	OpenOnly bool
+}
+
+// equipmentStockColumns selects stock es with its type et and what is checked out of it
+const equipmentStockColumns = `
+	es.*, et.name AS type_name, et.category,
+	(SELECT COALESCE(SUM(ec.quantity), 0) FROM equipment_checkout ec
+		WHERE ec.equipment_stock_id = es.equipment_stock_id AND ec.checked_in_at IS NULL) AS checked_out`
+
+// checkoutColumns selects check-out ec with the type et of its stock es
+const checkoutColumns = `ec.*, es.type_id, et.name AS type_name`
+
+const checkoutFrom = `
+	FROM equipment_checkout ec
+	JOIN equipment_stock es ON es.equipment_stock_id = ec.equipment_stock_id
+	JOIN equipment_type et ON et.type_id = es.type_id`
+
+// Equipment types
+
+func (db *DB) GetEquipmentType(id int) (types.EquipmentType, error) {
+	var equipmentType types.EquipmentType
+	err := db.Get(&equipmentType, "SELECT * FROM equipment_type WHERE type_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting equipment type: ", err)
+		return types.EquipmentType{}, fmt.Errorf("error getting equipment type: %w", err)
+	}
+	return equipmentType, nil
+}
+
+func (db *DB) CreateEquipmentType(equipmentType types.EquipmentType) (types.EquipmentType, error) {
+	var createdType types.EquipmentType
+	err := db.Get(&createdType, `
+		INSERT INTO equipment_type (name, category, description, min_stock)
+		VALUES ($1, $2, $3, $4)
+		RETURNING *`,
+		equipmentType.Name, equipmentType.Category, equipmentType.Description, equipmentType.MinStock)
+	if err != nil {
+		zap.S().Error("Error creating equipment type: ", err)
+		return types.EquipmentType{}, fmt.Errorf("error creating equipment type: %w", err)
+	}
+	return createdType, nil
+}
+
+func (db *DB) UpdateEquipmentType(equipmentType types.EquipmentType) (types.EquipmentType, error) {
+	var updatedType types.EquipmentType
+	err := db.Get(&updatedType, `
+		UPDATE equipment_type
+		SET name = $1,
+			category = $2,
+			description = $3,
+			min_stock = $4
+		WHERE type_id = $5
+		RETURNING *`,
+		equipmentType.Name, equipmentType.Category, equipmentType.Description, equipmentType.MinStock, equipmentType.TypeID)
+	if err != nil {
+		zap.S().Error("Error updating equipment type: ", err)
+		return types.EquipmentType{}, fmt.Errorf("error updating equipment type: %w", err)
+	}
+	return updatedType, nil
+}
+
+func (db *DB) DeleteEquipmentType(id int) error {
+	_, err := db.Exec("DELETE FROM equipment_type WHERE type_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error deleting equipment type: ", err)
+		return fmt.Errorf("error deleting equipment type: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetAllEquipmentTypes() ([]types.EquipmentType, error) {
+	equipmentTypes := []types.EquipmentType{}
+	err := db.Select(&equipmentTypes, "SELECT * FROM equipment_type ORDER BY category, name")
+	if err != nil {
+		zap.S().Error("Error getting all equipment types: ", err)
+		return nil, fmt.Errorf("error getting all equipment types: %w", err)
+	}
+	return equipmentTypes, nil
+}
+
+// Warehouses
+
+func (db *DB) GetWarehouse(id int) (types.Warehouse, error) {
+	var warehouse types.Warehouse
+	err := db.Get(&warehouse, "SELECT * FROM warehouse WHERE warehouse_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting warehouse: ", err)
+		return types.Warehouse{}, fmt.Errorf("error getting warehouse: %w", err)
+	}
+	return warehouse, nil
+}
+
+func (db *DB) CreateWarehouse(warehouse types.Warehouse) (types.Warehouse, error) {
+	var createdWarehouse types.Warehouse
+	err := db.Get(&createdWarehouse, `
+		INSERT INTO warehouse (name, address)
+		VALUES ($1, $2)
+		RETURNING *`,
+		warehouse.Name, warehouse.Address)
+	if err != nil {
+		zap.S().Error("Error creating warehouse: ", err)
+		return types.Warehouse{}, fmt.Errorf("error creating warehouse: %w", err)
+	}
+	return createdWarehouse, nil
+}
+
+func (db *DB) UpdateWarehouse(warehouse types.Warehouse) (types.Warehouse, error) {
+	var updatedWarehouse types.Warehouse
+	err := db.Get(&updatedWarehouse, `
+		UPDATE warehouse
+		SET name = $1,
+			address = $2
+		WHERE warehouse_id = $3
+		RETURNING *`,
+		warehouse.Name, warehouse.Address, warehouse.WarehouseID)
+	if err != nil {
+		zap.S().Error("Error updating warehouse: ", err)
+		return types.Warehouse{}, fmt.Errorf("error updating warehouse: %w", err)
+	}
+	return updatedWarehouse, nil
+}
+
+func (db *DB) DeleteWarehouse(id int) error {
+	_, err := db.Exec("DELETE FROM warehouse WHERE warehouse_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error deleting warehouse: ", err)
+		return fmt.Errorf("error deleting warehouse: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetAllWarehouses() ([]types.Warehouse, error) {
+	warehouses := []types.Warehouse{}
+	err := db.Select(&warehouses, "SELECT * FROM warehouse ORDER BY name")
+	if err != nil {
+		zap.S().Error("Error getting all warehouses: ", err)
+		return nil, fmt.Errorf("error getting all warehouses: %w", err)
+	}
+	return warehouses, nil
+}
+
+// Equipment stock
+
+// lockEquipmentStock locks the stock of a type at a location, creating it empty if needed
+func lockEquipmentStock(tx *sqlx.Tx, typeID int, location types.EquipmentLocation) (types.EquipmentStock, error) {
+	_, err := tx.Exec(`
+		INSERT INTO equipment_stock (type_id, apiary_id, warehouse_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`,
+		typeID, location.ApiaryID, location.WarehouseID)
+	if err != nil {
+		zap.S().Error("Error creating equipment stock: ", err)
+		return types.EquipmentStock{}, fmt.Errorf("error creating equipment stock: %w", err)
+	}
+	var stock types.EquipmentStock
+	err = tx.Get(&stock, `
+		SELECT `+equipmentStockColumns+`
+		FROM equipment_stock es
+		JOIN equipment_type et ON et.type_id = es.type_id
+		WHERE es.type_id = $1
+			AND es.apiary_id IS NOT DISTINCT FROM $2
+			AND es.warehouse_id IS NOT DISTINCT FROM $3
+		FOR UPDATE OF es`,
+		typeID, location.ApiaryID, location.WarehouseID)
+	if err != nil {
+		zap.S().Error("Error locking equipment stock: ", err)
+		return types.EquipmentStock{}, fmt.Errorf("error locking equipment stock: %w", err)
+	}
+	return stock, nil
+}
+
+// changeEquipmentStock adds change items to a location, failing with
+// ErrInsufficientEquipment if that would leave fewer than none
+func changeEquipmentStock(tx *sqlx.Tx, typeID int, location types.EquipmentLocation, change int) (types.EquipmentStock, error) {
+	stock, err := lockEquipmentStock(tx, typeID, location)
+	if err != nil {
+		return types.EquipmentStock{}, err
+	}
+	if stock.Quantity+change < 0 {
+		return types.EquipmentStock{}, fmt.Errorf("%w: %d %s in stock", ErrInsufficientEquipment, stock.Quantity, stock.TypeName)
+	}
+	_, err = tx.Exec("UPDATE equipment_stock SET quantity = quantity + $1 WHERE equipment_stock_id = $2", change, stock.EquipmentStockID)
+	if err != nil {
+		zap.S().Error("Error changing equipment stock: ", err)
+		return types.EquipmentStock{}, fmt.Errorf("error changing equipment stock: %w", err)
+	}
+	stock.Quantity += change
+	return stock, nil
+}
+
+// GetEquipmentStock returns the stock matching the filter by location
+func (db *DB) GetEquipmentStock(filter EquipmentStockFilter) ([]types.EquipmentStock, error) {
+	stock := []types.EquipmentStock{}
+	err := db.Select(&stock, `
+		SELECT `+equipmentStockColumns+`
+		FROM equipment_stock es
+		JOIN equipment_type et ON et.type_id = es.type_id
+		WHERE ($1 = 0 OR es.type_id = $1)
+			AND ($2 = 0 OR es.apiary_id = $2)
+			AND ($3 = 0 OR es.warehouse_id = $3)
+		ORDER BY es.warehouse_id NULLS LAST, es.apiary_id, et.category, et.name`,
+		filter.TypeID, filter.ApiaryID, filter.WarehouseID)
+	if err != nil {
+		zap.S().Error("Error getting equipment stock: ", err)
+		return nil, fmt.Errorf("error getting equipment stock: %w", err)
+	}
+	return stock, nil
+}
+
+// ChangeEquipmentStock adds items to or removes them from a location
+func (db *DB) ChangeEquipmentStock(change types.EquipmentStockChange) (types.EquipmentStock, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.EquipmentStock{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stock, err := changeEquipmentStock(tx, change.TypeID, change.EquipmentLocation, change.QuantityChange)
+	if err != nil {
+		return types.EquipmentStock{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.EquipmentStock{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return stock, nil
+}
+
+// TransferEquipment moves items between locations, returning the stock at both ends
+func (db *DB) TransferEquipment(transfer types.EquipmentTransfer) ([]types.EquipmentStock, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	from, err := changeEquipmentStock(tx, transfer.TypeID, transfer.From, -transfer.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	to, err := changeEquipmentStock(tx, transfer.TypeID, transfer.To, transfer.Quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return []types.EquipmentStock{from, to}, nil
+}
+
+// Hive components
+
+// GetHiveComponents returns the components on a hive, or on every hive of an
+// apiary when hiveID is 0
+func (db *DB) GetHiveComponents(hiveID, apiaryID int) ([]types.HiveComponent, error) {
+	components := []types.HiveComponent{}
+	err := db.Select(&components, `
+		SELECT hc.*, et.name AS type_name, et.category
+		FROM hive_component hc
+		JOIN equipment_type et ON et.type_id = hc.type_id
+		JOIN hive h ON h.hive_id = hc.hive_id
+		WHERE ($1 = 0 OR hc.hive_id = $1) AND ($2 = 0 OR h.apiary_id = $2)
+		ORDER BY hc.hive_id, et.category, et.name`,
+		hiveID, apiaryID)
+	if err != nil {
+		zap.S().Error("Error getting hive components: ", err)
+		return nil, fmt.Errorf("error getting hive components: %w", err)
+	}
+	return components, nil
+}
+
+// ChangeHiveComponents puts components on a hive from its apiary's stock, or takes
+// them off back into it, and returns what is on the hive afterwards
+func (db *DB) ChangeHiveComponents(hiveID int, change types.HiveComponentChange) ([]types.HiveComponent, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var category types.EquipmentCategory
+	if err := tx.Get(&category, "SELECT category FROM equipment_type WHERE type_id = $1", change.TypeID); err != nil {
+		zap.S().Error("Error getting equipment type: ", err)
+		return nil, fmt.Errorf("error getting equipment type: %w", err)
+	}
+	if !category.IsHiveComponent() {
+		return nil, fmt.Errorf("%w: %s", ErrNotHiveComponent, category)
+	}
+	var apiaryID int
+	if err := tx.Get(&apiaryID, "SELECT apiary_id FROM hive WHERE hive_id = $1 FOR UPDATE", hiveID); err != nil {
+		zap.S().Error("Error locking hive: ", err)
+		return nil, fmt.Errorf("error locking hive: %w", err)
+	}
+
+	var onHive int
+	err = tx.Get(&onHive, `
+		SELECT COALESCE((SELECT quantity FROM hive_component WHERE hive_id = $1 AND type_id = $2), 0)`,
+		hiveID, change.TypeID)
+	if err != nil {
+		zap.S().Error("Error getting hive components: ", err)
+		return nil, fmt.Errorf("error getting hive components: %w", err)
+	}
+	if onHive+change.QuantityChange < 0 {
+		return nil, fmt.Errorf("%w: %d on hive %d", ErrInsufficientEquipment, onHive, hiveID)
+	}
+	location := types.EquipmentLocation{ApiaryID: null.IntFrom(int64(apiaryID))}
+	if _, err := changeEquipmentStock(tx, change.TypeID, location, -change.QuantityChange); err != nil {
+		return nil, err
+	}
+
+	if onHive+change.QuantityChange == 0 {
+		_, err = tx.Exec("DELETE FROM hive_component WHERE hive_id = $1 AND type_id = $2", hiveID, change.TypeID)
+	} else {
+		_, err = tx.Exec(`
+			INSERT INTO hive_component (hive_id, type_id, quantity)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (hive_id, type_id) DO UPDATE
+			SET quantity = hive_component.quantity + EXCLUDED.quantity,
+				updated_at = CURRENT_TIMESTAMP`,
+			hiveID, change.TypeID, change.QuantityChange)
+	}
+	if err != nil {
+		zap.S().Error("Error changing hive components: ", err)
+		return nil, fmt.Errorf("error changing hive components: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetHiveComponents(hiveID, 0)
+}
+
+// Check-outs
+
+func (db *DB) GetEquipmentCheckout(id int) (types.EquipmentCheckout, error) {
+	var checkout types.EquipmentCheckout
+	err := db.Get(&checkout, "SELECT "+checkoutColumns+checkoutFrom+" WHERE ec.checkout_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting equipment checkout: ", err)
+		return types.EquipmentCheckout{}, fmt.Errorf("error getting equipment checkout: %w", err)
+	}
+	return checkout, nil
+}
+
+// GetEquipmentCheckouts returns the check-outs matching the filter, the latest first
+func (db *DB) GetEquipmentCheckouts(filter CheckoutFilter) ([]types.EquipmentCheckout, error) {
+	checkouts := []types.EquipmentCheckout{}
+	err := db.Select(&checkouts, `
+		SELECT `+checkoutColumns+checkoutFrom+`
+		WHERE ($1 = 0 OR ec.user_id = $1) AND (NOT $2 OR ec.checked_in_at IS NULL)
+		ORDER BY ec.checked_out_at DESC, ec.checkout_id DESC`,
+		filter.UserID, filter.OpenOnly)
+	if err != nil {
+		zap.S().Error("Error getting equipment checkouts: ", err)
+		return nil, fmt.Errorf("error getting equipment checkouts: %w", err)
+	}
+	return checkouts, nil
+}
+
+// CheckOutEquipment takes equipment from stores for a worker
+func (db *DB) CheckOutEquipment(request types.CheckoutRequest) (types.EquipmentCheckout, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.EquipmentCheckout{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stock, err := changeEquipmentStock(tx, request.TypeID, request.EquipmentLocation, -request.Quantity)
+	if err != nil {
+		return types.EquipmentCheckout{}, err
+	}
+	var checkoutID int
+	err = tx.Get(&checkoutID, `
+		INSERT INTO equipment_checkout (equipment_stock_id, user_id, quantity, due_date, notes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING checkout_id`,
+		stock.EquipmentStockID, request.UserID, request.Quantity, request.DueDate, request.Notes)
+	if err != nil {
+		zap.S().Error("Error checking out equipment: ", err)
+		return types.EquipmentCheckout{}, fmt.Errorf("error checking out equipment: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.EquipmentCheckout{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetEquipmentCheckout(checkoutID)
+}
+
+// CheckInEquipment returns checked out equipment to where it was taken from;
+// items not returned are written off
+func (db *DB) CheckInEquipment(id int, returned null.Int) (types.EquipmentCheckout, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.EquipmentCheckout{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var checkout types.EquipmentCheckout
+	err = tx.Get(&checkout, "SELECT "+checkoutColumns+checkoutFrom+" WHERE ec.checkout_id = $1 FOR UPDATE OF ec", id)
+	if err != nil {
+		zap.S().Error("Error locking equipment checkout: ", err)
+		return types.EquipmentCheckout{}, fmt.Errorf("error locking equipment checkout: %w", err)
+	}
+	if checkout.CheckedInAt.Valid {
+		return types.EquipmentCheckout{}, ErrCheckedIn
+	}
+	if !returned.Valid {
+		returned = null.IntFrom(int64(checkout.Quantity))
+	}
+	if returned.Int64 > int64(checkout.Quantity) {
+		return types.EquipmentCheckout{}, fmt.Errorf("%w: only %d checked out", ErrInsufficientEquipment, checkout.Quantity)
+	}
+	_, err = tx.Exec("UPDATE equipment_stock SET quantity = quantity + $1 WHERE equipment_stock_id = $2", returned, checkout.EquipmentStockID)
+	if err != nil {
+		zap.S().Error("Error returning equipment: ", err)
+		return types.EquipmentCheckout{}, fmt.Errorf("error returning equipment: %w", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE equipment_checkout
+		SET checked_in_at = CURRENT_TIMESTAMP,
+			returned_quantity = $1
+		WHERE checkout_id = $2`,
+		returned, id)
+	if err != nil {
+		zap.S().Error("Error checking in equipment: ", err)
+		return types.EquipmentCheckout{}, fmt.Errorf("error checking in equipment: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.EquipmentCheckout{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetEquipmentCheckout(id)
+}
+
+// Low stock
+
+// GetEquipmentShortages returns the equipment types with fewer items in stores
+// than their minimum stock; with notAlertedSince set, only those not alerted
+// about since that date
+func (db *DB) GetEquipmentShortages(notAlertedSince null.Time) ([]types.EquipmentShortage, error) {
+	shortages := []types.EquipmentShortage{}
+	err := db.Select(&shortages, `
+		SELECT s.*, s.min_stock - s.in_stock AS shortfall
+		FROM (
+			SELECT et.type_id, et.name, et.category, et.min_stock,
+				(SELECT COALESCE(SUM(es.quantity), 0) FROM equipment_stock es
+					WHERE es.type_id = et.type_id) AS in_stock,
+				(SELECT COALESCE(SUM(hc.quantity), 0) FROM hive_component hc
+					WHERE hc.type_id = et.type_id) AS on_hives,
+				(SELECT COALESCE(SUM(ec.quantity), 0) FROM equipment_checkout ec
+					JOIN equipment_stock es ON es.equipment_stock_id = ec.equipment_stock_id
+					WHERE es.type_id = et.type_id AND ec.checked_in_at IS NULL) AS checked_out
+			FROM equipment_type et
+			WHERE et.min_stock > 0
+				AND ($1::date IS NULL OR et.low_stock_alerted_on IS NULL OR et.low_stock_alerted_on < $1)
+		) s
+		WHERE s.in_stock < s.min_stock
+		ORDER BY shortfall DESC, s.name`,
+		notAlertedSince)
+	if err != nil {
+		zap.S().Error("Error getting equipment shortages: ", err)
+		return nil, fmt.Errorf("error getting equipment shortages: %w", err)
+	}
+	return shortages, nil
+}
+
+// MarkLowStockAlerted records that a low-stock alert went out for the given types
+func (db *DB) MarkLowStockAlerted(typeIDs []int, date time.Time) error {
+	_, err := db.Exec("UPDATE equipment_type SET low_stock_alerted_on = $1 WHERE type_id = ANY($2)", date, pq.Array(typeIDs))
+	if err != nil {
+		zap.S().Error("Error marking low stock alerted: ", err)
+		return fmt.Errorf("error marking low stock alerted: %w", err)
+	}
+	return nil
+}
+
+// GetEquipmentRecipients returns the admins and managers, who order equipment
+func (db *DB) GetEquipmentRecipients() ([]types.NotificationRecipient, error) {
+	recipients := []types.NotificationRecipient{}
+	err := db.Select(&recipients, `
+		SELECT `+recipientColumns+`
+		FROM "user" u
+		LEFT JOIN notification_preference np ON np.user_id = u.user_id
+		WHERE u.role IN ($1, $2)`,
+		types.Admin, types.Manager)
+	if err != nil {
+		zap.S().Error("Error getting equipment recipients: ", err)
+		return nil, fmt.Errorf("error getting equipment recipients: %w", err)
+	}
+	return recipients, nil
+}
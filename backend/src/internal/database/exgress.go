@@ -21,8 +21,9 @@ func (db *DB) CreateProductionReport(report types.ProductionReport) (types.Produ
 	var createdReport types.ProductionReport
 	query := `
 		INSERT INTO production_report
-		(apiary_id, start_date, end_date, total_honey_produced, total_expenses, curated_by)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		(apiary_id, start_date, end_date, total_honey_produced, total_expenses, curated_by, feed_used_kg, feed_cost, total_revenue)
+		SELECT $1, $2, $3, $4, $5, $6, fu.quantity_kg, fu.cost, apiary_revenue($1, $2, $3)
+		FROM apiary_feed_usage($1, $2, $3) fu
 		RETURNING *
 	`
 	err := db.Get(&createdReport, query,
@@ -50,9 +51,13 @@ func (db *DB) UpdateProductionReport(report types.ProductionReport) (types.Produ
 			end_date = $3,
 			total_honey_produced = $4,
 			total_expenses = $5,
-			curated_by = $6
+			curated_by = $6,
+			feed_used_kg = fu.quantity_kg,
+			feed_cost = fu.cost,
+			total_revenue = apiary_revenue($1, $2, $3)
+		FROM apiary_feed_usage($1, $2, $3) fu
 		WHERE report_id = $7
-		RETURNING *
+		RETURNING production_report.*
 	`
 	err := db.Get(&updatedReport, query,
 		report.ApiaryID,
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/guregu/null"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// FeedingFilter narrows down the feedings returned by GetFeedings
+type FeedingFilter struct {
+	HiveID   int
+	ApiaryID int
+	From     null.Time
+	To       null.Time
+}
+
+// storesColumns selects the estimated stores of hive h, with the feed given since $1
+const storesColumns = `
+	h.hive_id, h.apiary_id, h.tare_weight_kg, h.colony_allowance_kg, w.weight_kg, w.measured_at,
+	hive_estimated_stores(h.hive_id) AS stores_kg,
+	(SELECT COALESCE(SUM(f.quantity_kg), 0) FROM feeding f
+		WHERE f.hive_id = h.hive_id AND ($1::date IS NULL OR f.feeding_date >= $1)) AS fed_kg`
+
+func (db *DB) GetFeeding(id int) (types.Feeding, error) {
+	var feeding types.Feeding
+	err := db.Get(&feeding, "SELECT * FROM feeding WHERE feeding_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting feeding: ", err)
+		return types.Feeding{}, fmt.Errorf("error getting feeding: %w", err)
+	}
+	return feeding, nil
+}
+
+func (db *DB) CreateFeeding(feeding types.Feeding) (types.Feeding, error) {
+	var createdFeeding types.Feeding
+	err := db.Get(&createdFeeding, `
+		INSERT INTO feeding (hive_id, feed_type, quantity_kg, cost_per_kg, feeding_date, fed_by, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING *`,
+		feeding.HiveID, feeding.FeedType, feeding.QuantityKg, feeding.CostPerKg, feeding.FeedingDate, feeding.FedBy, feeding.Notes)
+	if err != nil {
+		zap.S().Error("Error creating feeding: ", err)
+		return types.Feeding{}, fmt.Errorf("error creating feeding: %w", err)
+	}
+	return createdFeeding, nil
+}
+
+func (db *DB) UpdateFeeding(feeding types.Feeding) (types.Feeding, error) {
+	var updatedFeeding types.Feeding
+	err := db.Get(&updatedFeeding, `
+		UPDATE feeding
+		SET hive_id = $1,
+			feed_type = $2,
+			quantity_kg = $3,
+			cost_per_kg = $4,
+			feeding_date = $5,
+			notes = $6
+		WHERE feeding_id = $7
+		RETURNING *`,
+		feeding.HiveID, feeding.FeedType, feeding.QuantityKg, feeding.CostPerKg, feeding.FeedingDate, feeding.Notes, feeding.FeedingID)
+	if err != nil {
+		zap.S().Error("Error updating feeding: ", err)
+		return types.Feeding{}, fmt.Errorf("error updating feeding: %w", err)
+	}
+	return updatedFeeding, nil
+}
+
+func (db *DB) DeleteFeeding(id int) error {
+	_, err := db.Exec("DELETE FROM feeding WHERE feeding_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error deleting feeding: ", err)
+		return fmt.Errorf("error deleting feeding: %w", err)
+	}
+	return nil
+}
+
+// GetFeedings returns the feedings matching the filter, the latest first
+//
+// Feedings are attributed to the apiary their hive stood at on the feeding date
+func (db *DB) GetFeedings(filter FeedingFilter) ([]types.Feeding, error) {
+	feedings := []types.Feeding{}
+	err := db.Select(&feedings, `
+		SELECT * FROM feeding f
+		WHERE ($1 = 0 OR f.hive_id = $1)
+			AND ($2 = 0 OR hive_apiary_on(f.hive_id, f.feeding_date) = $2)
+			AND ($3::date IS NULL OR f.feeding_date >= $3)
+			AND ($4::date IS NULL OR f.feeding_date <= $4)
+		ORDER BY f.feeding_date DESC, f.feeding_id DESC`,
+		filter.HiveID, filter.ApiaryID, filter.From, filter.To)
+	if err != nil {
+		zap.S().Error("Error getting feedings: ", err)
+		return nil, fmt.Errorf("error getting feedings: %w", err)
+	}
+	return feedings, nil
+}
+
+// GetFeedUsage sums the feedings matching the filter per feed type
+func (db *DB) GetFeedUsage(filter FeedingFilter) ([]types.FeedUsage, error) {
+	usage := []types.FeedUsage{}
+	err := db.Select(&usage, `
+		SELECT f.feed_type, COUNT(*) AS feedings,
+			SUM(f.quantity_kg) AS quantity_kg,
+			COALESCE(SUM(f.quantity_kg * f.cost_per_kg), 0) AS cost
+		FROM feeding f
+		WHERE ($1 = 0 OR f.hive_id = $1)
+			AND ($2 = 0 OR hive_apiary_on(f.hive_id, f.feeding_date) = $2)
+			AND ($3::date IS NULL OR f.feeding_date >= $3)
+			AND ($4::date IS NULL OR f.feeding_date <= $4)
+		GROUP BY f.feed_type
+		ORDER BY f.feed_type`,
+		filter.HiveID, filter.ApiaryID, filter.From, filter.To)
+	if err != nil {
+		zap.S().Error("Error getting feed usage: ", err)
+		return nil, fmt.Errorf("error getting feed usage: %w", err)
+	}
+	return usage, nil
+}
+
+// GetHiveStores estimates the stores of a hive, counting the feed given since seasonStart
+func (db *DB) GetHiveStores(hiveID int, seasonStart null.Time) (types.HiveStores, error) {
+	var stores types.HiveStores
+	err := db.Get(&stores, `
+		SELECT `+storesColumns+`
+		FROM hive h
+		LEFT JOIN LATERAL hive_latest_weight(h.hive_id) w ON TRUE
+		WHERE h.hive_id = $2`,
+		seasonStart, hiveID)
+	if err != nil {
+		zap.S().Error("Error getting hive stores: ", err)
+		return types.HiveStores{}, fmt.Errorf("error getting hive stores: %w", err)
+	}
+	return stores, nil
+}
+
+// GetApiaryStores estimates the stores of the active hives of an apiary, the lowest first
+func (db *DB) GetApiaryStores(apiaryID int, seasonStart null.Time) ([]types.HiveStores, error) {
+	stores := []types.HiveStores{}
+	err := db.Select(&stores, `
+		SELECT `+storesColumns+`
+		FROM hive h
+		LEFT JOIN LATERAL hive_latest_weight(h.hive_id) w ON TRUE
+		WHERE h.apiary_id = $2 AND h.current_status = $3
+		ORDER BY stores_kg NULLS FIRST, h.hive_id`,
+		seasonStart, apiaryID, types.HiveActive)
+	if err != nil {
+		zap.S().Error("Error getting apiary stores: ", err)
+		return nil, fmt.Errorf("error getting apiary stores: %w", err)
+	}
+	return stores, nil
+}
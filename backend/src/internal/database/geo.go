@@ -0,0 +1,136 @@
+package database
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// apiaryDistanceColumns selects apiaries a with their distance from the point ($1, $2)
+const apiaryDistanceColumns = `
+	a.apiary_id, a.location, a.manager_id, a.latitude, a.longitude,
+	distance_km($1, $2, a.latitude, a.longitude) AS distance_km`
+
+// hiveLocation is a hive with the coordinates of its apiary
+type hiveLocation struct {
+	HiveID        int              `db:"hive_id"`
+	ApiaryID      int              `db:"apiary_id"`
+	HiveType      string           `db:"hive_type"`
+	CurrentStatus types.HiveStatus `db:"current_status"`
+	Latitude      float64          `db:"latitude"`
+	Longitude     float64          `db:"longitude"`
+}
+
+// GetApiaryFeatures gets every apiary with coordinates as a point, every site
+// outline as a polygon and every hive at its apiary's point
+func (db *DB) GetApiaryFeatures() (types.FeatureCollection, error) {
+	var apiaries []types.Apiary
+	err := db.Select(&apiaries, "SELECT * FROM apiary WHERE latitude IS NOT NULL OR site_polygon IS NOT NULL ORDER BY apiary_id")
+	if err != nil {
+		zap.S().Error("Error getting apiary features: ", err)
+		return types.FeatureCollection{}, fmt.Errorf("error getting apiary features: %w", err)
+	}
+	var hives []hiveLocation
+	err = db.Select(&hives, `
+		SELECT h.hive_id, h.apiary_id, h.hive_type, h.current_status, a.latitude, a.longitude
+		FROM hive h
+		JOIN apiary a ON a.apiary_id = h.apiary_id
+		WHERE a.latitude IS NOT NULL
+		ORDER BY h.hive_id`)
+	if err != nil {
+		zap.S().Error("Error getting hive features: ", err)
+		return types.FeatureCollection{}, fmt.Errorf("error getting hive features: %w", err)
+	}
+
+	features := []types.Feature{}
+	for _, apiary := range apiaries {
+		properties := map[string]interface{}{
+			"apiary_id":  apiary.ApiaryID,
+			"location":   apiary.Location,
+			"manager_id": apiary.ManagerID,
+		}
+		if apiary.Latitude.Valid && apiary.Longitude.Valid {
+			point := types.NewPoint(apiary.Longitude.Float64, apiary.Latitude.Float64)
+			features = append(features, types.NewFeature(types.FeatureApiary, point, properties))
+		}
+		if apiary.SitePolygon.Valid {
+			site := map[string]interface{}{"apiary_id": apiary.ApiaryID, "location": apiary.Location}
+			features = append(features, types.NewFeature(types.FeatureSite, apiary.SitePolygon, site))
+		}
+	}
+	for _, hive := range hives {
+		properties := map[string]interface{}{
+			"hive_id":        hive.HiveID,
+			"apiary_id":      hive.ApiaryID,
+			"hive_type":      hive.HiveType,
+			"current_status": hive.CurrentStatus,
+		}
+		point := types.NewPoint(hive.Longitude, hive.Latitude)
+		features = append(features, types.NewFeature(types.FeatureHive, point, properties))
+	}
+	return types.FeatureCollection{Type: "FeatureCollection", Features: features}, nil
+}
+
+// GetApiariesNear gets the apiaries within km of a point, nearest first
+func (db *DB) GetApiariesNear(latitude, longitude, km float64) ([]types.ApiaryDistance, error) {
+	apiaries := []types.ApiaryDistance{}
+	err := db.Select(&apiaries, `
+		SELECT `+apiaryDistanceColumns+`
+		FROM apiary a
+		WHERE a.latitude IS NOT NULL
+		AND distance_km($1, $2, a.latitude, a.longitude) <= $3
+		ORDER BY distance_km, a.apiary_id`,
+		latitude, longitude, km)
+	if err != nil {
+		zap.S().Error("Error getting apiaries nearby: ", err)
+		return nil, fmt.Errorf("error getting apiaries nearby: %w", err)
+	}
+	return apiaries, nil
+}
+
+// GetApiaryNeighbours gets the other apiaries within km of an apiary, nearest first
+//
+// An apiary without coordinates has no neighbours
+func (db *DB) GetApiaryNeighbours(apiaryID int, km float64) ([]types.ApiaryDistance, error) {
+	apiary, err := db.GetApiary(apiaryID)
+	if err != nil {
+		return nil, err
+	}
+	if !apiary.Latitude.Valid || !apiary.Longitude.Valid {
+		return []types.ApiaryDistance{}, nil
+	}
+	apiaries, err := db.GetApiariesNear(apiary.Latitude.Float64, apiary.Longitude.Float64, km)
+	if err != nil {
+		return nil, err
+	}
+	neighbours := apiaries[:0]
+	for _, neighbour := range apiaries {
+		if neighbour.ApiaryID != apiaryID {
+			neighbours = append(neighbours, neighbour)
+		}
+	}
+	return neighbours, nil
+}
+
+// GetOutbreakNeighbours gets the apiaries outside an outbreak's quarantine zone
+// but within km of one of its apiaries, nearest first
+func (db *DB) GetOutbreakNeighbours(outbreakID int, km float64) ([]types.OutbreakNeighbour, error) {
+	if _, err := db.GetOutbreak(outbreakID); err != nil {
+		return nil, err
+	}
+	neighbours := []types.OutbreakNeighbour{}
+	err := db.Select(&neighbours, `
+		SELECT a.apiary_id, a.location, a.manager_id, a.latitude, a.longitude,
+			n.distance_km, n.nearest_zone_apiary_id
+		FROM outbreak_neighbour_apiaries($1, $2) n
+		JOIN apiary a ON a.apiary_id = n.apiary_id
+		ORDER BY n.distance_km, a.apiary_id`,
+		outbreakID, km)
+	if err != nil {
+		zap.S().Error("Error getting outbreak neighbours: ", err)
+		return nil, fmt.Errorf("error getting outbreak neighbours: %w", err)
+	}
+	return neighbours, nil
+}
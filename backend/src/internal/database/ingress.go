@@ -1,12 +1,19 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	types "github.com/orientallines/beesbiz/internal/types/db"
 	"go.uber.org/zap"
 )
 
+// ErrInvalidTransition is returned when a status change is not allowed by the lifecycle
+var ErrInvalidTransition = errors.New("invalid status transition")
+
 func (db *DB) GetObservationLog(id int) (types.ObservationLog, error) {
 	var log types.ObservationLog
 	err := db.Get(&log, "SELECT * FROM observation_log WHERE log_id = $1", id)
@@ -70,10 +77,10 @@ func (db *DB) CreateMaintenancePlan(plan types.MaintenancePlan) (types.Maintenan
 	var createdPlan types.MaintenancePlan
 	err := db.Get(&createdPlan, `
 		INSERT INTO maintenance_plan (
-			apiary_id, planned_date, work_type, assigned_to, status
-		) VALUES ($1, $2, $3, $4, $5)
+			apiary_id, hive_id, planned_date, work_type, assigned_to, assigned_group_id, status
+		) VALUES ($1, $2, $3, $4, COALESCE($5, pick_group_assignee($6, $1)), $6, $7)
 		RETURNING *`,
-		plan.ApiaryID, plan.PlannedDate, plan.WorkType, plan.AssignedTo, plan.Status)
+		plan.ApiaryID, plan.HiveID, plan.PlannedDate, plan.WorkType, plan.AssignedTo, plan.AssignedGroupID, plan.Status)
 	if err != nil {
 		zap.S().Error("Error creating maintenance plan: ", err)
 		return types.MaintenancePlan{}, fmt.Errorf("error creating maintenance plan: %w", err)
@@ -86,13 +93,16 @@ func (db *DB) UpdateMaintenancePlan(plan types.MaintenancePlan) (types.Maintenan
 	err := db.Get(&updatedPlan, `
 		UPDATE maintenance_plan
 		SET apiary_id = $1,
-			planned_date = $2,
-			work_type = $3,
-			assigned_to = $4,
-			status = $5
-		WHERE plan_id = $6
+			hive_id = $2,
+			planned_date = $3,
+			work_type = $4,
+			assigned_to = COALESCE($5, pick_group_assignee($6, $1)),
+			assigned_group_id = $6,
+			status = $7,
+			overdue_at = CASE WHEN $3::date >= CURRENT_DATE THEN NULL ELSE overdue_at END
+		WHERE plan_id = $8
 		RETURNING *`,
-		plan.ApiaryID, plan.PlannedDate, plan.WorkType, plan.AssignedTo, plan.Status, plan.PlanID)
+		plan.ApiaryID, plan.HiveID, plan.PlannedDate, plan.WorkType, plan.AssignedTo, plan.AssignedGroupID, plan.Status, plan.PlanID)
 	if err != nil {
 		zap.S().Error("Error updating maintenance plan: ", err)
 		return types.MaintenancePlan{}, fmt.Errorf("error updating maintenance plan: %w", err)
@@ -119,6 +129,17 @@ func (db *DB) GetAllMaintenancePlans() ([]types.MaintenancePlan, error) {
 	return plans, nil
 }
 
+// GetMaintenancePlansByAssignee retrieves all maintenance plans assigned to a user
+func (db *DB) GetMaintenancePlansByAssignee(userID int) ([]types.MaintenancePlan, error) {
+	var plans []types.MaintenancePlan
+	err := db.Select(&plans, "SELECT * FROM maintenance_plan WHERE assigned_to = $1 ORDER BY planned_date", userID)
+	if err != nil {
+		zap.S().Error("Error getting maintenance plans by assignee: ", err)
+		return nil, fmt.Errorf("error getting maintenance plans by assignee: %w", err)
+	}
+	return plans, nil
+}
+
 func (db *DB) GetIncident(id int) (types.Incident, error) {
 	var incident types.Incident
 	err := db.Get(&incident, "SELECT * FROM incident WHERE incident_id = $1", id)
@@ -129,16 +150,38 @@ func (db *DB) GetIncident(id int) (types.Incident, error) {
 	return incident, nil
 }
 
-func (db *DB) CreateIncident(incident types.Incident) (types.Incident, error) {
+func (db *DB) CreateIncident(incident types.Incident, reporterID int) (types.Incident, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.Incident{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	var createdIncident types.Incident
-	err := db.Get(&createdIncident, "INSERT INTO incident (hive_id, incident_date, description, severity, actions_taken) VALUES ($1, $2, $3, $4, $5) RETURNING *", incident.HiveID, incident.IncidentDate, incident.Description, incident.Severity, incident.ActionsTaken)
+	err = tx.Get(&createdIncident, "INSERT INTO incident (hive_id, incident_date, description, severity, actions_taken) VALUES ($1, $2, $3, $4, $5) RETURNING *", incident.HiveID, incident.IncidentDate, incident.Description, incident.Severity, incident.ActionsTaken)
 	if err != nil {
 		zap.S().Error("Error creating incident: ", err)
 		return types.Incident{}, fmt.Errorf("error creating incident: %w", err)
 	}
+	if err := insertIncidentActivity(tx, types.IncidentActivity{
+		IncidentID: createdIncident.IncidentID,
+		UserID:     optionalID(reporterID),
+		Kind:       types.ActivityCreated,
+		ToStatus:   null.StringFrom(string(createdIncident.Status)),
+	}); err != nil {
+		return types.Incident{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.Incident{}, fmt.Errorf("error committing transaction: %w", err)
+	}
 	return createdIncident, nil
 }
 
+// UpdateIncident updates the reported details of an incident; status and
+// assignment only change through TransitionIncident and AssignIncident
 func (db *DB) UpdateIncident(incident types.Incident) (types.Incident, error) {
 	var updatedIncident types.Incident
 	err := db.Get(&updatedIncident, "UPDATE incident SET hive_id = $1, incident_date = $2, description = $3, severity = $4, actions_taken = $5 WHERE incident_id = $6 RETURNING *", incident.HiveID, incident.IncidentDate, incident.Description, incident.Severity, incident.ActionsTaken, incident.IncidentID)
@@ -158,12 +201,224 @@ func (db *DB) DeleteIncident(id int) error {
 	return nil
 }
 
-func (db *DB) GetAllIncidents() ([]types.Incident, error) {
-	var incidents []types.Incident
-	err := db.Select(&incidents, "SELECT * FROM incident")
+// GetAllIncidents retrieves the incidents matching the filter, newest first
+func (db *DB) GetAllIncidents(filter types.IncidentFilter) ([]types.Incident, error) {
+	query := "SELECT * FROM incident WHERE 1 = 1"
+	var args []interface{}
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
+		}
+		args = append(args, pq.Array(statuses))
+		query += fmt.Sprintf(" AND status = ANY($%d)", len(args))
+	}
+	if len(filter.Severities) > 0 {
+		severities := make([]string, len(filter.Severities))
+		for i, severity := range filter.Severities {
+			severities[i] = string(severity)
+		}
+		args = append(args, pq.Array(severities))
+		query += fmt.Sprintf(" AND severity = ANY($%d)", len(args))
+	}
+	if filter.HiveID > 0 {
+		args = append(args, filter.HiveID)
+		query += fmt.Sprintf(" AND hive_id = $%d", len(args))
+	}
+	if filter.AssigneeID > 0 {
+		args = append(args, filter.AssigneeID)
+		query += fmt.Sprintf(" AND assigned_user_id = $%d", len(args))
+	}
+	if filter.GroupID > 0 {
+		args = append(args, filter.GroupID)
+		query += fmt.Sprintf(" AND assigned_group_id = $%d", len(args))
+	}
+	query += " ORDER BY created_at DESC, incident_id DESC"
+
+	incidents := []types.Incident{}
+	err := db.Select(&incidents, query, args...)
 	if err != nil {
 		zap.S().Error("Error getting all incidents: ", err)
 		return []types.Incident{}, fmt.Errorf("error getting all incidents: %w", err)
 	}
 	return incidents, nil
 }
+
+// incidentTransitionColumns maps each status to the column recording when it was reached
+var incidentTransitionColumns = map[types.IncidentStatus]string{
+	types.IncidentAcknowledged: "acknowledged_at",
+	types.IncidentInProgress:   "started_at",
+	types.IncidentResolved:     "resolved_at",
+	types.IncidentClosed:       "closed_at",
+}
+
+// TransitionIncident moves an incident to a new status, recording the transition
+// time and an activity entry with the optional comment
+//
+// It returns ErrInvalidTransition if the lifecycle does not allow the move
+func (db *DB) TransitionIncident(id int, to types.IncidentStatus, userID int, comment string) (types.Incident, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.Incident{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var incident types.Incident
+	if err := tx.Get(&incident, "SELECT * FROM incident WHERE incident_id = $1 FOR UPDATE", id); err != nil {
+		zap.S().Error("Error getting incident: ", err)
+		return types.Incident{}, fmt.Errorf("error getting incident: %w", err)
+	}
+	if !incident.Status.CanTransitionTo(to) {
+		return types.Incident{}, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, incident.Status, to)
+	}
+
+	// Reopening clears the resolution so time-to-resolve reflects the final fix
+	query := fmt.Sprintf("UPDATE incident SET status = $1, %s = CURRENT_TIMESTAMP", incidentTransitionColumns[to])
+	if incident.Status == types.IncidentResolved && to == types.IncidentInProgress {
+		query += ", resolved_at = NULL"
+	}
+	query += " WHERE incident_id = $2 RETURNING *"
+
+	from := incident.Status
+	if err := tx.Get(&incident, query, to, id); err != nil {
+		zap.S().Error("Error updating incident status: ", err)
+		return types.Incident{}, fmt.Errorf("error updating incident status: %w", err)
+	}
+	if err := insertIncidentActivity(tx, types.IncidentActivity{
+		IncidentID: id,
+		UserID:     optionalID(userID),
+		Kind:       types.ActivityStatusChange,
+		FromStatus: null.StringFrom(string(from)),
+		ToStatus:   null.StringFrom(string(to)),
+		Body:       null.NewString(comment, comment != ""),
+	}); err != nil {
+		return types.Incident{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.Incident{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return incident, nil
+}
+
+// AssignIncident assigns an incident to a user or a worker group, or clears the
+// assignment when both are invalid, and records it in the activity thread
+func (db *DB) AssignIncident(id int, assigneeID, groupID null.Int, userID int) (types.Incident, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.Incident{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var incident types.Incident
+	err = tx.Get(&incident, "UPDATE incident SET assigned_user_id = $1, assigned_group_id = $2 WHERE incident_id = $3 RETURNING *", assigneeID, groupID, id)
+	if err != nil {
+		zap.S().Error("Error assigning incident: ", err)
+		return types.Incident{}, fmt.Errorf("error assigning incident: %w", err)
+	}
+
+	body := "Unassigned"
+	if assigneeID.Valid {
+		body = fmt.Sprintf("Assigned to user %d", assigneeID.Int64)
+	} else if groupID.Valid {
+		body = fmt.Sprintf("Assigned to worker group %d", groupID.Int64)
+	}
+	if err := insertIncidentActivity(tx, types.IncidentActivity{
+		IncidentID: id,
+		UserID:     optionalID(userID),
+		Kind:       types.ActivityAssignment,
+		Body:       null.StringFrom(body),
+	}); err != nil {
+		return types.Incident{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.Incident{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return incident, nil
+}
+
+// AddIncidentComment appends a comment to an incident's activity thread
+func (db *DB) AddIncidentComment(id, userID int, body string) (types.IncidentActivity, error) {
+	var activity types.IncidentActivity
+	err := db.Get(&activity, `
+		INSERT INTO incident_activity (incident_id, user_id, kind, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING *`,
+		id, optionalID(userID), types.ActivityComment, body)
+	if err != nil {
+		zap.S().Error("Error adding incident comment: ", err)
+		return types.IncidentActivity{}, fmt.Errorf("error adding incident comment: %w", err)
+	}
+	return activity, nil
+}
+
+// GetIncidentActivity retrieves the activity thread of an incident, oldest first
+func (db *DB) GetIncidentActivity(id int) ([]types.IncidentActivity, error) {
+	activity := []types.IncidentActivity{}
+	err := db.Select(&activity, "SELECT * FROM incident_activity WHERE incident_id = $1 ORDER BY created_at, activity_id", id)
+	if err != nil {
+		zap.S().Error("Error getting incident activity: ", err)
+		return nil, fmt.Errorf("error getting incident activity: %w", err)
+	}
+	return activity, nil
+}
+
+// GetIncidentMetrics computes incident counts and time-to-acknowledge and
+// time-to-resolve statistics for incidents created within [from, to)
+func (db *DB) GetIncidentMetrics(from, to null.Time) (types.IncidentMetrics, error) {
+	const scope = "($1::timestamp IS NULL OR created_at >= $1) AND ($2::timestamp IS NULL OR created_at < $2)"
+
+	var metrics types.IncidentMetrics
+	err := db.Get(&metrics, `
+		SELECT
+			COUNT(*) AS total,
+			AVG(EXTRACT(EPOCH FROM acknowledged_at - created_at)) AS avg_time_to_acknowledge,
+			PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM acknowledged_at - created_at)) AS median_time_to_acknowledge,
+			AVG(EXTRACT(EPOCH FROM resolved_at - created_at)) AS avg_time_to_resolve,
+			PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM resolved_at - created_at)) AS median_time_to_resolve
+		FROM incident
+		WHERE `+scope,
+		from, to)
+	if err != nil {
+		zap.S().Error("Error getting incident metrics: ", err)
+		return types.IncidentMetrics{}, fmt.Errorf("error getting incident metrics: %w", err)
+	}
+
+	var counts []struct {
+		Status string `db:"status"`
+		Count  int    `db:"count"`
+	}
+	err = db.Select(&counts, "SELECT status, COUNT(*) AS count FROM incident WHERE "+scope+" GROUP BY status", from, to)
+	if err != nil {
+		zap.S().Error("Error counting incidents by status: ", err)
+		return types.IncidentMetrics{}, fmt.Errorf("error counting incidents by status: %w", err)
+	}
+	metrics.ByStatus = make(map[string]int, len(counts))
+	for _, count := range counts {
+		metrics.ByStatus[count.Status] = count.Count
+	}
+	return metrics, nil
+}
+
+// insertIncidentActivity records an activity entry inside a transaction
+func insertIncidentActivity(tx *sqlx.Tx, activity types.IncidentActivity) error {
+	_, err := tx.Exec(`
+		INSERT INTO incident_activity (incident_id, user_id, kind, from_status, to_status, body)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		activity.IncidentID, activity.UserID, activity.Kind, activity.FromStatus, activity.ToStatus, activity.Body)
+	if err != nil {
+		zap.S().Error("Error recording incident activity: ", err)
+		return fmt.Errorf("error recording incident activity: %w", err)
+	}
+	return nil
+}
+
+// optionalID turns a zero ID, e.g. from a system caller, into NULL
+func optionalID(id int) null.Int {
+	return null.NewInt(int64(id), id > 0)
+}
@@ -0,0 +1,401 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+var (
+	// ErrTemplateInUse is returned when changing the questions of a template that already has inspections
+	ErrTemplateInUse = errors.New("inspection template already has inspections")
+	// ErrInvalidSearch is returned when an inspection search does not fit the searched question
+	ErrInvalidSearch = errors.New("invalid inspection search")
+)
+
+// InspectionFilter narrows down the inspections returned by GetInspections
+type InspectionFilter struct {
+	HiveID     int
+	TemplateID int
+	From       null.Time
+	To         null.Time
+}
+
+// InspectionSearch finds hives by their answer to a question
+//
+// Op is one of eq, ne, lt, lte, gt and gte; ordering operators only apply to
+// number answers. Value is read by the answer type of the question, so "1" is a
+// number for a number question and true for a boolean one. With LatestOnly, only
+// each hive's most recent answer counts.
+type InspectionSearch struct {
+	Key        string
+	Op         string
+	Value      string
+	LatestOnly bool
+}
+
+var searchOperators = map[string]string{
+	"eq":  "=",
+	"ne":  "<>",
+	"lt":  "<",
+	"lte": "<=",
+	"gt":  ">",
+	"gte": ">=",
+}
+
+// GetInspectionTemplate returns a template with its questions in order
+func (db *DB) GetInspectionTemplate(id int) (types.InspectionTemplate, error) {
+	var template types.InspectionTemplate
+	if err := db.Get(&template, "SELECT * FROM inspection_template WHERE template_id = $1", id); err != nil {
+		zap.S().Error("Error getting inspection template: ", err)
+		return types.InspectionTemplate{}, fmt.Errorf("error getting inspection template: %w", err)
+	}
+	template.Questions = []types.InspectionQuestion{}
+	if err := db.Select(&template.Questions, "SELECT * FROM inspection_question WHERE template_id = $1 ORDER BY position, question_id", id); err != nil {
+		zap.S().Error("Error getting inspection questions: ", err)
+		return types.InspectionTemplate{}, fmt.Errorf("error getting inspection questions: %w", err)
+	}
+	return template, nil
+}
+
+// GetAllInspectionTemplates returns every template without its questions
+func (db *DB) GetAllInspectionTemplates() ([]types.InspectionTemplate, error) {
+	templates := []types.InspectionTemplate{}
+	err := db.Select(&templates, "SELECT * FROM inspection_template ORDER BY name")
+	if err != nil {
+		zap.S().Error("Error getting all inspection templates: ", err)
+		return []types.InspectionTemplate{}, fmt.Errorf("error getting all inspection templates: %w", err)
+	}
+	return templates, nil
+}
+
+// CreateInspectionTemplate creates a template together with its questions
+func (db *DB) CreateInspectionTemplate(template types.InspectionTemplate) (types.InspectionTemplate, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.InspectionTemplate{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var templateID int
+	err = tx.Get(&templateID, `
+		INSERT INTO inspection_template (name, description, created_by, active)
+		VALUES ($1, $2, $3, $4)
+		RETURNING template_id`,
+		template.Name, template.Description, template.CreatedBy, template.Active)
+	if err != nil {
+		zap.S().Error("Error creating inspection template: ", err)
+		return types.InspectionTemplate{}, fmt.Errorf("error creating inspection template: %w", err)
+	}
+	if err := insertQuestions(tx, templateID, template.Questions); err != nil {
+		return types.InspectionTemplate{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.InspectionTemplate{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetInspectionTemplate(templateID)
+}
+
+// UpdateInspectionTemplate updates a template and replaces its questions
+//
+// It returns ErrTemplateInUse if the questions change while inspections already
+// answered them; such templates can only be renamed or (de)activated
+func (db *DB) UpdateInspectionTemplate(template types.InspectionTemplate) (types.InspectionTemplate, error) {
+	current, err := db.GetInspectionTemplate(template.TemplateID)
+	if err != nil {
+		return types.InspectionTemplate{}, err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.InspectionTemplate{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE inspection_template SET name = $1, description = $2, active = $3
+		WHERE template_id = $4`,
+		template.Name, template.Description, template.Active, template.TemplateID)
+	if err != nil {
+		zap.S().Error("Error updating inspection template: ", err)
+		return types.InspectionTemplate{}, fmt.Errorf("error updating inspection template: %w", err)
+	}
+
+	if !sameQuestions(current.Questions, template.Questions) {
+		var used bool
+		if err := tx.Get(&used, "SELECT EXISTS (SELECT 1 FROM inspection WHERE template_id = $1)", template.TemplateID); err != nil {
+			zap.S().Error("Error checking inspection template usage: ", err)
+			return types.InspectionTemplate{}, fmt.Errorf("error checking inspection template usage: %w", err)
+		}
+		if used {
+			return types.InspectionTemplate{}, ErrTemplateInUse
+		}
+		if _, err := tx.Exec("DELETE FROM inspection_question WHERE template_id = $1", template.TemplateID); err != nil {
+			zap.S().Error("Error deleting inspection questions: ", err)
+			return types.InspectionTemplate{}, fmt.Errorf("error deleting inspection questions: %w", err)
+		}
+		if err := insertQuestions(tx, template.TemplateID, template.Questions); err != nil {
+			return types.InspectionTemplate{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.InspectionTemplate{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetInspectionTemplate(template.TemplateID)
+}
+
+// DeleteInspectionTemplate deletes a template, failing with ErrTemplateInUse if it has inspections
+func (db *DB) DeleteInspectionTemplate(id int) error {
+	result, err := db.Exec(`
+		DELETE FROM inspection_template
+		WHERE template_id = $1 AND NOT EXISTS (SELECT 1 FROM inspection WHERE template_id = $1)`,
+		id)
+	if err != nil {
+		zap.S().Error("Error deleting inspection template: ", err)
+		return fmt.Errorf("error deleting inspection template: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrTemplateInUse
+	}
+	return nil
+}
+
+func insertQuestions(tx *sqlx.Tx, templateID int, questions []types.InspectionQuestion) error {
+	for i, question := range questions {
+		_, err := tx.Exec(`
+			INSERT INTO inspection_question (
+				template_id, key, label, answer_type, options, min_value, max_value, required, position
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			templateID, question.Key, question.Label, question.AnswerType, question.Options, question.MinValue, question.MaxValue, question.Required, i)
+		if err != nil {
+			zap.S().Error("Error creating inspection question: ", err)
+			return fmt.Errorf("error creating inspection question %q: %w", question.Key, err)
+		}
+	}
+	return nil
+}
+
+// sameQuestions reports whether two question lists define the same checklist
+func sameQuestions(a, b []types.InspectionQuestion) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || a[i].Label != b[i].Label || a[i].AnswerType != b[i].AnswerType ||
+			a[i].Required != b[i].Required || a[i].MinValue != b[i].MinValue || a[i].MaxValue != b[i].MaxValue ||
+			strings.Join(a[i].Options, "\x00") != strings.Join(b[i].Options, "\x00") {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateInspection records an inspection with answers already checked against its template
+func (db *DB) CreateInspection(inspection types.Inspection, answers []types.InspectionAnswer) (types.Inspection, error) {
+	values := make(map[string]interface{}, len(answers))
+	for _, answer := range answers {
+		values[answer.Key] = answer.Value()
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return types.Inspection{}, fmt.Errorf("error encoding inspection answers: %w", err)
+	}
+
+	var inspectionID int
+	err = db.Get(&inspectionID, "CALL record_inspection($1, $2, $3, $4, $5, $6)",
+		inspection.HiveID, inspection.TemplateID, inspection.InspectedBy, inspection.InspectionDate, inspection.Notes, string(encoded))
+	if err != nil {
+		zap.S().Error("Error recording inspection: ", err)
+		return types.Inspection{}, fmt.Errorf("error recording inspection: %w", err)
+	}
+	return db.GetInspection(inspectionID)
+}
+
+// GetInspection returns an inspection with its answers
+func (db *DB) GetInspection(id int) (types.Inspection, error) {
+	var inspection types.Inspection
+	if err := db.Get(&inspection, "SELECT * FROM inspection WHERE inspection_id = $1", id); err != nil {
+		zap.S().Error("Error getting inspection: ", err)
+		return types.Inspection{}, fmt.Errorf("error getting inspection: %w", err)
+	}
+	inspections := []types.Inspection{inspection}
+	if err := db.loadAnswers(inspections); err != nil {
+		return types.Inspection{}, err
+	}
+	return inspections[0], nil
+}
+
+// GetInspections returns the inspections matching the filter with their answers, latest first
+func (db *DB) GetInspections(filter InspectionFilter) ([]types.Inspection, error) {
+	inspections := []types.Inspection{}
+	err := db.Select(&inspections, `
+		SELECT * FROM inspection
+		WHERE ($1 = 0 OR hive_id = $1)
+			AND ($2 = 0 OR template_id = $2)
+			AND ($3::date IS NULL OR inspection_date >= $3)
+			AND ($4::date IS NULL OR inspection_date <= $4)
+		ORDER BY inspection_date DESC, inspection_id DESC`,
+		filter.HiveID, filter.TemplateID, filter.From, filter.To)
+	if err != nil {
+		zap.S().Error("Error getting inspections: ", err)
+		return nil, fmt.Errorf("error getting inspections: %w", err)
+	}
+	if err := db.loadAnswers(inspections); err != nil {
+		return nil, err
+	}
+	return inspections, nil
+}
+
+func (db *DB) DeleteInspection(id int) error {
+	_, err := db.Exec("DELETE FROM inspection WHERE inspection_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error deleting inspection: ", err)
+		return fmt.Errorf("error deleting inspection: %w", err)
+	}
+	return nil
+}
+
+// loadAnswers fills in the answers of the given inspections
+func (db *DB) loadAnswers(inspections []types.Inspection) error {
+	if len(inspections) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(inspections))
+	byID := make(map[int]*types.Inspection, len(inspections))
+	for i := range inspections {
+		ids[i] = int64(inspections[i].InspectionID)
+		inspections[i].Answers = map[string]interface{}{}
+		byID[inspections[i].InspectionID] = &inspections[i]
+	}
+
+	var answers []types.InspectionAnswer
+	err := db.Select(&answers, `
+		SELECT a.inspection_id, a.question_id, q.key, a.value_bool, a.value_number, a.value_text
+		FROM inspection_answer a
+		JOIN inspection_question q ON q.question_id = a.question_id
+		WHERE a.inspection_id = ANY($1)`,
+		pq.Array(ids))
+	if err != nil {
+		zap.S().Error("Error getting inspection answers: ", err)
+		return fmt.Errorf("error getting inspection answers: %w", err)
+	}
+	for _, answer := range answers {
+		byID[answer.InspectionID].Answers[answer.Key] = answer.Value()
+	}
+	return nil
+}
+
+// SearchInspections finds hives whose inspections answered a question as searched for
+func (db *DB) SearchInspections(search InspectionSearch) ([]types.InspectionMatch, error) {
+	operator, ok := searchOperators[search.Op]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported operator %q", ErrInvalidSearch, search.Op)
+	}
+
+	var answerTypes []types.AnswerType
+	err := db.Select(&answerTypes, "SELECT DISTINCT answer_type FROM inspection_question WHERE key = $1", search.Key)
+	if err != nil {
+		zap.S().Error("Error getting question answer types: ", err)
+		return nil, fmt.Errorf("error getting question answer types: %w", err)
+	}
+	if len(answerTypes) == 0 {
+		return []types.InspectionMatch{}, nil
+	}
+	if len(answerTypes) > 1 {
+		return nil, fmt.Errorf("%w: question %q has answers of several types", ErrInvalidSearch, search.Key)
+	}
+
+	var condition string
+	var value interface{}
+	switch answerTypes[0] {
+	case types.AnswerBoolean:
+		b, err := strconv.ParseBool(search.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be searched for with true or false", ErrInvalidSearch, search.Key)
+		}
+		condition, value = "value_bool "+operator+" $2", b
+	case types.AnswerNumber:
+		n, err := strconv.ParseFloat(search.Value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be searched for with a number", ErrInvalidSearch, search.Key)
+		}
+		condition, value = "value_number "+operator+" $2", n
+	default:
+		if operator != "=" && operator != "<>" {
+			return nil, fmt.Errorf("%w: operator %q only applies to numbers", ErrInvalidSearch, search.Op)
+		}
+		condition, value = "value_text "+operator+" $2", search.Value
+	}
+
+	matches := []types.InspectionMatch{}
+	err = db.Select(&matches, `
+		WITH answered AS (
+			SELECT i.hive_id, i.inspection_id, i.inspection_date,
+				a.question_id, q.key, a.value_bool, a.value_number, a.value_text,
+				ROW_NUMBER() OVER (PARTITION BY i.hive_id ORDER BY i.inspection_date DESC, i.inspection_id DESC) AS recency
+			FROM inspection i
+			JOIN inspection_answer a ON a.inspection_id = i.inspection_id
+			JOIN inspection_question q ON q.question_id = a.question_id
+			WHERE q.key = $1
+		)
+		SELECT hive_id, inspection_id, inspection_date, question_id, key, value_bool, value_number, value_text
+		FROM answered
+		WHERE (NOT $3 OR recency = 1) AND `+condition+`
+		ORDER BY hive_id, inspection_date DESC`,
+		search.Key, value, search.LatestOnly)
+	if err != nil {
+		zap.S().Error("Error searching inspections: ", err)
+		return nil, fmt.Errorf("error searching inspections: %w", err)
+	}
+	for i := range matches {
+		matches[i].Value = matches[i].InspectionAnswer.Value()
+	}
+	return matches, nil
+}
+
+// GetInspectionTrends returns a hive's boolean and number answers over time, per question key
+func (db *DB) GetInspectionTrends(hiveID int, key string, from, to null.Time) ([]types.InspectionTrend, error) {
+	var points []types.InspectionTrendPoint
+	err := db.Select(&points, `
+		SELECT i.inspection_id, i.inspection_date, a.question_id, q.key, a.value_bool, a.value_number, a.value_text
+		FROM inspection i
+		JOIN inspection_answer a ON a.inspection_id = i.inspection_id
+		JOIN inspection_question q ON q.question_id = a.question_id
+		WHERE i.hive_id = $1
+			AND q.answer_type IN ('boolean', 'number', 'choice')
+			AND ($2 = '' OR q.key = $2)
+			AND ($3::date IS NULL OR i.inspection_date >= $3)
+			AND ($4::date IS NULL OR i.inspection_date <= $4)
+		ORDER BY q.key, i.inspection_date, i.inspection_id`,
+		hiveID, key, from, to)
+	if err != nil {
+		zap.S().Error("Error getting inspection trends: ", err)
+		return nil, fmt.Errorf("error getting inspection trends: %w", err)
+	}
+
+	trends := []types.InspectionTrend{}
+	for _, point := range points {
+		point.Value = point.InspectionAnswer.Value()
+		if len(trends) == 0 || trends[len(trends)-1].Key != point.Key {
+			trends = append(trends, types.InspectionTrend{Key: point.Key})
+		}
+		last := &trends[len(trends)-1]
+		last.Points = append(last.Points, point)
+	}
+	return trends, nil
+}
@@ -0,0 +1,257 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/guregu/null"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+var (
+	// ErrBatchReleased is returned when releasing a batch twice
+	ErrBatchReleased = errors.New("batch was already released")
+	// ErrBatchOutOfSpec is returned when releasing or selling a batch whose latest lab results are out of spec
+	ErrBatchOutOfSpec = errors.New("batch has out-of-spec lab results")
+)
+
+// LabResultFilter narrows down the lab results returned by GetLabResults
+//
+// BatchID matches the results of the batch itself and of the harvests blended into it
+type LabResultFilter struct {
+	HarvestID     int
+	BatchID       int
+	OutOfSpecOnly bool
+	From          null.Time
+	To            null.Time
+}
+
+func (db *DB) GetGradeRules() ([]types.GradeRule, error) {
+	rules := []types.GradeRule{}
+	err := db.Select(&rules, "SELECT * FROM grade_rule ORDER BY grade")
+	if err != nil {
+		zap.S().Error("Error getting grade rules: ", err)
+		return nil, fmt.Errorf("error getting grade rules: %w", err)
+	}
+	return rules, nil
+}
+
+// SaveGradeRule creates or replaces the limits of a grade
+//
+// Existing lab results keep the grade they were given when recorded
+func (db *DB) SaveGradeRule(rule types.GradeRule) (types.GradeRule, error) {
+	var savedRule types.GradeRule
+	err := db.Get(&savedRule, `
+		INSERT INTO grade_rule (grade, max_moisture_percent, max_hmf_mg_kg, min_diastase_dn, description)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (grade) DO UPDATE SET
+			max_moisture_percent = EXCLUDED.max_moisture_percent,
+			max_hmf_mg_kg = EXCLUDED.max_hmf_mg_kg,
+			min_diastase_dn = EXCLUDED.min_diastase_dn,
+			description = EXCLUDED.description
+		RETURNING *`,
+		rule.Grade, rule.MaxMoisturePercent, rule.MaxHMFMgKg, rule.MinDiastaseDN, rule.Description)
+	if err != nil {
+		zap.S().Error("Error saving grade rule: ", err)
+		return types.GradeRule{}, fmt.Errorf("error saving grade rule: %w", err)
+	}
+	return savedRule, nil
+}
+
+func (db *DB) GetLabResult(id int) (types.LabResult, error) {
+	var result types.LabResult
+	err := db.Get(&result, "SELECT * FROM lab_result WHERE result_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting lab result: ", err)
+		return types.LabResult{}, fmt.Errorf("error getting lab result: %w", err)
+	}
+	return result, nil
+}
+
+// labResultInsert records a lab result; its grade and out_of_spec flag are derived by trigger
+const labResultInsert = `
+	INSERT INTO lab_result (
+		harvest_id, batch_id, lab_name, sample_reference, sample_date, result_date,
+		moisture_percent, hmf_mg_kg, diastase_dn, pollen_analysis, residue_detected, residue_notes, recorded_by
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	RETURNING *`
+
+func labResultArgs(result types.LabResult) []interface{} {
+	return []interface{}{
+		result.HarvestID, result.BatchID, result.LabName, result.SampleReference, result.SampleDate, result.ResultDate,
+		result.MoisturePercent, result.HMFMgKg, result.DiastaseDN, result.PollenAnalysis, result.ResidueDetected, result.ResidueNotes, result.RecordedBy,
+	}
+}
+
+func (db *DB) CreateLabResult(result types.LabResult) (types.LabResult, error) {
+	var createdResult types.LabResult
+	err := db.Get(&createdResult, labResultInsert, labResultArgs(result)...)
+	if err != nil {
+		zap.S().Error("Error creating lab result: ", err)
+		return types.LabResult{}, fmt.Errorf("error creating lab result: %w", err)
+	}
+	return createdResult, nil
+}
+
+// CreateLabResults records imported lab results all together, or none of them
+func (db *DB) CreateLabResults(results []types.LabResult) ([]types.LabResult, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	createdResults := make([]types.LabResult, 0, len(results))
+	for i, result := range results {
+		var createdResult types.LabResult
+		if err := tx.Get(&createdResult, labResultInsert, labResultArgs(result)...); err != nil {
+			zap.S().Error("Error creating lab result: ", err)
+			return nil, fmt.Errorf("error creating lab result %d: %w", i+1, err)
+		}
+		createdResults = append(createdResults, createdResult)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return createdResults, nil
+}
+
+func (db *DB) UpdateLabResult(result types.LabResult) (types.LabResult, error) {
+	var updatedResult types.LabResult
+	err := db.Get(&updatedResult, `
+		UPDATE lab_result
+		SET harvest_id = $1,
+			batch_id = $2,
+			lab_name = $3,
+			sample_reference = $4,
+			sample_date = $5,
+			result_date = $6,
+			moisture_percent = $7,
+			hmf_mg_kg = $8,
+			diastase_dn = $9,
+			pollen_analysis = $10,
+			residue_detected = $11,
+			residue_notes = $12
+		WHERE result_id = $13
+		RETURNING *`,
+		result.HarvestID, result.BatchID, result.LabName, result.SampleReference, result.SampleDate, result.ResultDate,
+		result.MoisturePercent, result.HMFMgKg, result.DiastaseDN, result.PollenAnalysis, result.ResidueDetected, result.ResidueNotes,
+		result.ResultID)
+	if err != nil {
+		zap.S().Error("Error updating lab result: ", err)
+		return types.LabResult{}, fmt.Errorf("error updating lab result: %w", err)
+	}
+	return updatedResult, nil
+}
+
+func (db *DB) DeleteLabResult(id int) error {
+	_, err := db.Exec("DELETE FROM lab_result WHERE result_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error deleting lab result: ", err)
+		return fmt.Errorf("error deleting lab result: %w", err)
+	}
+	return nil
+}
+
+// GetLabResults returns the lab results matching the filter, the latest sample first
+func (db *DB) GetLabResults(filter LabResultFilter) ([]types.LabResult, error) {
+	results := []types.LabResult{}
+	err := db.Select(&results, `
+		SELECT * FROM lab_result lr
+		WHERE ($1 = 0 OR lr.harvest_id = $1)
+			AND ($2 = 0 OR lr.batch_id = $2
+				OR lr.harvest_id IN (SELECT bh.harvest_id FROM batch_harvest bh WHERE bh.batch_id = $2))
+			AND (NOT $3 OR lr.out_of_spec)
+			AND ($4::date IS NULL OR lr.sample_date >= $4)
+			AND ($5::date IS NULL OR lr.sample_date <= $5)
+		ORDER BY lr.sample_date DESC, lr.result_id DESC`,
+		filter.HarvestID, filter.BatchID, filter.OutOfSpecOnly, filter.From, filter.To)
+	if err != nil {
+		zap.S().Error("Error getting lab results: ", err)
+		return nil, fmt.Errorf("error getting lab results: %w", err)
+	}
+	return results, nil
+}
+
+// GetBatchIDByLotNumber resolves a batch or jar lot number to its batch
+func (db *DB) GetBatchIDByLotNumber(lotNumber string) (int, error) {
+	var batchID int
+	err := db.Get(&batchID, `
+		SELECT batch_id FROM honey_batch WHERE lot_number = $1
+		UNION
+		SELECT batch_id FROM jar_lot WHERE lot_number = $1`,
+		lotNumber)
+	if err != nil {
+		zap.S().Error("Error finding lot: ", err)
+		return 0, fmt.Errorf("error finding lot %s: %w", lotNumber, err)
+	}
+	return batchID, nil
+}
+
+// GetLabResultRecipients returns the admins and the managers of the apiaries the
+// analysed honey came from
+func (db *DB) GetLabResultRecipients(id int) ([]types.NotificationRecipient, error) {
+	recipients := []types.NotificationRecipient{}
+	err := db.Select(&recipients, `
+		SELECT `+recipientColumns+`
+		FROM "user" u
+		LEFT JOIN notification_preference np ON np.user_id = u.user_id
+		WHERE u.role = $2 OR u.user_id IN (
+			SELECT a.manager_id
+			FROM lab_result lr
+			JOIN honey_harvest hh ON hh.harvest_id = lr.harvest_id
+				OR hh.harvest_id IN (SELECT bh.harvest_id FROM batch_harvest bh WHERE bh.batch_id = lr.batch_id)
+			JOIN apiary a ON a.apiary_id = hive_apiary_on(hh.hive_id, hh.harvest_date)
+			WHERE lr.result_id = $1
+		)`,
+		id, types.Admin)
+	if err != nil {
+		zap.S().Error("Error getting lab result recipients: ", err)
+		return nil, fmt.Errorf("error getting lab result recipients: %w", err)
+	}
+	return recipients, nil
+}
+
+// ReleaseHoneyBatch releases a batch for sale, refusing while the latest lab result
+// of the batch or of any of its harvests is out of spec
+func (db *DB) ReleaseHoneyBatch(id int, releasedDate null.Time, releasedBy int) (types.HoneyBatch, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.HoneyBatch{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var released null.Time
+	if err := tx.Get(&released, "SELECT released_date FROM honey_batch WHERE batch_id = $1 FOR UPDATE", id); err != nil {
+		zap.S().Error("Error locking honey batch: ", err)
+		return types.HoneyBatch{}, fmt.Errorf("error locking honey batch: %w", err)
+	}
+	if released.Valid {
+		return types.HoneyBatch{}, ErrBatchReleased
+	}
+	var blocking []int64
+	if err := tx.Select(&blocking, "SELECT batch_blocking_results($1)", id); err != nil {
+		zap.S().Error("Error checking batch lab results: ", err)
+		return types.HoneyBatch{}, fmt.Errorf("error checking batch lab results: %w", err)
+	}
+	if len(blocking) > 0 {
+		return types.HoneyBatch{}, fmt.Errorf("%w: lab results %v", ErrBatchOutOfSpec, blocking)
+	}
+	_, err = tx.Exec("UPDATE honey_batch SET released_date = $1, released_by = NULLIF($2, 0) WHERE batch_id = $3", releasedDate, releasedBy, id)
+	if err != nil {
+		zap.S().Error("Error releasing honey batch: ", err)
+		return types.HoneyBatch{}, fmt.Errorf("error releasing honey batch: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.HoneyBatch{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetHoneyBatch(id)
+}
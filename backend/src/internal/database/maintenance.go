@@ -0,0 +1,268 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"github.com/orientallines/beesbiz/internal/recurrence"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// upsertOccurrence inserts the plans of one schedule occurrence, one per hive of
+// the schedule or a single apiary-wide one when it has none
+//
+// Plans of group schedules are left unassigned for DistributeGroupPlans, which
+// accounts for the workload added by every plan it hands out
+const upsertOccurrence = `
+	INSERT INTO maintenance_plan (
+		apiary_id, hive_id, planned_date, work_type, assigned_to, assigned_group_id, status, schedule_id, occurrence_date
+	)
+	SELECT s.apiary_id, h.hive_id, COALESCE($3::date, d.day), s.work_type, s.assigned_to, s.assigned_group_id, $4, s.schedule_id, d.day
+	FROM maintenance_schedule s
+	CROSS JOIN unnest($2::date[]) AS d(day)
+	LEFT JOIN LATERAL unnest(s.hive_ids) AS h(hive_id) ON TRUE
+	WHERE s.schedule_id = $1
+	ON CONFLICT (schedule_id, occurrence_date, (COALESCE(hive_id, 0))) WHERE schedule_id IS NOT NULL`
+
+// deleteUpcomingPlans removes generated plans nobody touched yet, so they can be
+// regenerated from the current schedule
+const deleteUpcomingPlans = `
+	DELETE FROM maintenance_plan
+	WHERE schedule_id = $1
+		AND status = 'Pending'
+		AND planned_date = occurrence_date
+		AND occurrence_date >= CURRENT_DATE`
+
+func (db *DB) GetMaintenanceSchedule(id int) (types.MaintenanceSchedule, error) {
+	var schedule types.MaintenanceSchedule
+	err := db.Get(&schedule, "SELECT * FROM maintenance_schedule WHERE schedule_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting maintenance schedule: ", err)
+		return types.MaintenanceSchedule{}, fmt.Errorf("error getting maintenance schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+func (db *DB) CreateMaintenanceSchedule(schedule types.MaintenanceSchedule) (types.MaintenanceSchedule, error) {
+	var createdSchedule types.MaintenanceSchedule
+	err := db.Get(&createdSchedule, `
+		INSERT INTO maintenance_schedule (
+			apiary_id, hive_ids, work_type, assigned_to, assigned_group_id, rrule, start_date, horizon_days, active
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING *`,
+		schedule.ApiaryID, schedule.HiveIDs, schedule.WorkType, schedule.AssignedTo, schedule.AssignedGroupID, schedule.RRule, schedule.StartDate, schedule.HorizonDays, schedule.Active)
+	if err != nil {
+		zap.S().Error("Error creating maintenance schedule: ", err)
+		return types.MaintenanceSchedule{}, fmt.Errorf("error creating maintenance schedule: %w", err)
+	}
+	return createdSchedule, nil
+}
+
+// UpdateMaintenanceSchedule updates a schedule and drops its upcoming untouched
+// plans, which are regenerated from the new rule
+//
+// Plans that were started, completed, skipped or rescheduled are kept as they are
+func (db *DB) UpdateMaintenanceSchedule(schedule types.MaintenanceSchedule) (types.MaintenanceSchedule, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.MaintenanceSchedule{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var updatedSchedule types.MaintenanceSchedule
+	err = tx.Get(&updatedSchedule, `
+		UPDATE maintenance_schedule
+		SET apiary_id = $1,
+			hive_ids = $2,
+			work_type = $3,
+			assigned_to = $4,
+			assigned_group_id = $5,
+			rrule = $6,
+			start_date = $7,
+			horizon_days = $8,
+			active = $9
+		WHERE schedule_id = $10
+		RETURNING *`,
+		schedule.ApiaryID, schedule.HiveIDs, schedule.WorkType, schedule.AssignedTo, schedule.AssignedGroupID, schedule.RRule, schedule.StartDate, schedule.HorizonDays, schedule.Active, schedule.ScheduleID)
+	if err != nil {
+		zap.S().Error("Error updating maintenance schedule: ", err)
+		return types.MaintenanceSchedule{}, fmt.Errorf("error updating maintenance schedule: %w", err)
+	}
+
+	if _, err := tx.Exec(deleteUpcomingPlans, schedule.ScheduleID); err != nil {
+		zap.S().Error("Error deleting upcoming maintenance plans: ", err)
+		return types.MaintenanceSchedule{}, fmt.Errorf("error deleting upcoming maintenance plans: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.MaintenanceSchedule{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return updatedSchedule, nil
+}
+
+// DeleteMaintenanceSchedule deletes a schedule with its upcoming untouched plans
+//
+// Past and modified plans stay, detached from the schedule
+func (db *DB) DeleteMaintenanceSchedule(id int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(deleteUpcomingPlans, id); err != nil {
+		zap.S().Error("Error deleting upcoming maintenance plans: ", err)
+		return fmt.Errorf("error deleting upcoming maintenance plans: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM maintenance_schedule WHERE schedule_id = $1", id); err != nil {
+		zap.S().Error("Error deleting maintenance schedule: ", err)
+		return fmt.Errorf("error deleting maintenance schedule: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetAllMaintenanceSchedules() ([]types.MaintenanceSchedule, error) {
+	schedules := []types.MaintenanceSchedule{}
+	err := db.Select(&schedules, "SELECT * FROM maintenance_schedule ORDER BY schedule_id")
+	if err != nil {
+		zap.S().Error("Error getting all maintenance schedules: ", err)
+		return []types.MaintenanceSchedule{}, fmt.Errorf("error getting all maintenance schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// GetActiveMaintenanceSchedules retrieves the schedules the scheduler expands
+func (db *DB) GetActiveMaintenanceSchedules() ([]types.MaintenanceSchedule, error) {
+	schedules := []types.MaintenanceSchedule{}
+	err := db.Select(&schedules, "SELECT * FROM maintenance_schedule WHERE active ORDER BY schedule_id")
+	if err != nil {
+		zap.S().Error("Error getting active maintenance schedules: ", err)
+		return nil, fmt.Errorf("error getting active maintenance schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// GetSchedulePlans retrieves the plans generated from a schedule
+func (db *DB) GetSchedulePlans(scheduleID int) ([]types.MaintenancePlan, error) {
+	plans := []types.MaintenancePlan{}
+	err := db.Select(&plans, "SELECT * FROM maintenance_plan WHERE schedule_id = $1 ORDER BY occurrence_date, hive_id", scheduleID)
+	if err != nil {
+		zap.S().Error("Error getting schedule plans: ", err)
+		return nil, fmt.Errorf("error getting schedule plans: %w", err)
+	}
+	return plans, nil
+}
+
+// CountHivesInApiary counts how many of the given hives belong to the apiary
+func (db *DB) CountHivesInApiary(apiaryID int, hiveIDs []int64) (int, error) {
+	var count int
+	err := db.Get(&count, "SELECT COUNT(*) FROM hive WHERE apiary_id = $1 AND hive_id = ANY($2)", apiaryID, pq.Array(hiveIDs))
+	if err != nil {
+		zap.S().Error("Error counting hives in apiary: ", err)
+		return 0, fmt.Errorf("error counting hives in apiary: %w", err)
+	}
+	return count, nil
+}
+
+// GenerateSchedulePlans creates the missing plans for the schedule's occurrences
+// from today until its horizon, returning how many were created
+//
+// Occurrences that already have plans are left alone, so skipped and
+// rescheduled occurrences are never recreated
+func (db *DB) GenerateSchedulePlans(schedule types.MaintenanceSchedule, today time.Time) (int64, error) {
+	if !schedule.Active {
+		return 0, nil
+	}
+	rule, err := recurrence.Parse(schedule.RRule)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing recurrence rule of schedule %d: %w", schedule.ScheduleID, err)
+	}
+
+	occurrences := rule.Between(schedule.StartDate.Time, today, today.AddDate(0, 0, schedule.HorizonDays))
+	if len(occurrences) == 0 {
+		return 0, nil
+	}
+
+	result, err := db.Exec(upsertOccurrence+" DO NOTHING",
+		schedule.ScheduleID, pq.Array(formatDates(occurrences)), nil, types.MaintenancePending)
+	if err != nil {
+		zap.S().Error("Error generating maintenance plans: ", err)
+		return 0, fmt.Errorf("error generating maintenance plans: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ChangeOccurrence skips or reschedules every plan of one schedule occurrence,
+// creating them first if they were not generated yet
+//
+// Completed plans are not changed
+func (db *DB) ChangeOccurrence(scheduleID int, change types.OccurrenceChange) ([]types.MaintenancePlan, error) {
+	status := types.MaintenancePending
+	plannedDate := change.PlannedDate
+	if change.Action == types.OccurrenceSkip {
+		status = types.MaintenanceSkipped
+		plannedDate.Valid = false
+	}
+
+	plans := []types.MaintenancePlan{}
+	err := db.Select(&plans, upsertOccurrence+`
+		DO UPDATE SET
+			status = EXCLUDED.status,
+			planned_date = CASE WHEN $3::date IS NULL THEN maintenance_plan.planned_date ELSE EXCLUDED.planned_date END,
+			overdue_at = NULL
+		WHERE maintenance_plan.status <> 'Completed'
+		RETURNING *`,
+		scheduleID, pq.Array(formatDates([]time.Time{change.OccurrenceDate.Time})), plannedDate, status)
+	if err != nil {
+		zap.S().Error("Error changing schedule occurrence: ", err)
+		return nil, fmt.Errorf("error changing schedule occurrence: %w", err)
+	}
+	return plans, nil
+}
+
+// FlagOverduePlans marks open plans whose planned date has passed, returning how many were flagged
+func (db *DB) FlagOverduePlans() (int64, error) {
+	result, err := db.Exec(`
+		UPDATE maintenance_plan SET overdue_at = CURRENT_TIMESTAMP
+		WHERE planned_date < CURRENT_DATE
+			AND status IN ('Pending', 'In Progress')
+			AND overdue_at IS NULL`)
+	if err != nil {
+		zap.S().Error("Error flagging overdue maintenance plans: ", err)
+		return 0, fmt.Errorf("error flagging overdue maintenance plans: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetOverduePlans retrieves open plans flagged as overdue, the latest first
+func (db *DB) GetOverduePlans() ([]types.MaintenancePlan, error) {
+	plans := []types.MaintenancePlan{}
+	err := db.Select(&plans, `
+		SELECT * FROM maintenance_plan
+		WHERE overdue_at IS NOT NULL AND status IN ('Pending', 'In Progress')
+		ORDER BY planned_date`)
+	if err != nil {
+		zap.S().Error("Error getting overdue maintenance plans: ", err)
+		return nil, fmt.Errorf("error getting overdue maintenance plans: %w", err)
+	}
+	return plans, nil
+}
+
+func formatDates(dates []time.Time) []string {
+	formatted := make([]string, len(dates))
+	for i, date := range dates {
+		formatted[i] = date.Format("2006-01-02")
+	}
+	return formatted
+}
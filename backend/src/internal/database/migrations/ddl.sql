@@ -25,10 +25,120 @@ BEGIN
     PERFORM add_constraint_if_not_exists('honey_harvest', 'check_harvest_date', 'CHECK ("harvest_date" <= CURRENT_DATE)');
     PERFORM add_constraint_if_not_exists('honey_harvest', 'check_quantity', 'CHECK ("quantity" >= 0)');
     PERFORM add_constraint_if_not_exists('observation_log', 'check_observation_date', 'CHECK ("observation_date" <= CURRENT_DATE)');
-    PERFORM add_constraint_if_not_exists('maintenance_plan', 'check_planned_date', 'CHECK ("planned_date" >= CURRENT_DATE)');
     PERFORM add_constraint_if_not_exists('incident', 'check_incident_date', 'CHECK ("incident_date" <= CURRENT_DATE)');
     PERFORM add_constraint_if_not_exists('production_report', 'check_date_range', 'CHECK ("start_date" <= "end_date")');
     PERFORM add_constraint_if_not_exists('production_report', 'check_total_honey_produced', 'CHECK ("total_honey_produced" >= 0)');
     PERFORM add_constraint_if_not_exists('weather_data', 'check_weather_date', 'CHECK ("date" <= CURRENT_DATE)');
-    PERFORM add_constraint_if_not_exists('bee_community', 'unique_hive_id', 'UNIQUE ("hive_id")');
+END $$;
+
+-- Dissolved communities keep their hive_id, so only one live community per hive
+-- is enforced, by idx_bee_community_live_hive
+ALTER TABLE "bee_community" DROP CONSTRAINT IF EXISTS "unique_hive_id";
+
+-- Enumerations and payload rules mirrored from the API validation.
+-- Legacy rows are normalized where the intent is unambiguous; anything else is
+-- left in place (NOT VALID) but every new or updated row is checked.
+ALTER TABLE "maintenance_plan" ALTER COLUMN "status" SET DEFAULT 'Pending';
+
+-- Late plans must stay updatable (overdue flags, status, rescheduling), so the
+-- planned date is only checked by the API when a plan is created or moved.
+-- The status check is replaced by one that also allows skipped occurrences.
+ALTER TABLE "maintenance_plan" DROP CONSTRAINT IF EXISTS "check_planned_date";
+ALTER TABLE "maintenance_plan" DROP CONSTRAINT IF EXISTS "check_maintenance_status";
+
+UPDATE "maintenance_plan" SET "status" = 'Pending' WHERE lower("status") = 'pending' AND "status" <> 'Pending';
+UPDATE "maintenance_plan" SET "status" = 'In Progress' WHERE lower("status") IN ('in progress', 'in_progress') AND "status" <> 'In Progress';
+UPDATE "maintenance_plan" SET "status" = 'Completed' WHERE lower("status") = 'completed' AND "status" <> 'Completed';
+UPDATE "hive" SET "current_status" = lower("current_status") WHERE lower("current_status") IN ('active', 'inactive', 'maintenance') AND "current_status" <> lower("current_status");
+UPDATE "incident" SET "severity" = lower("severity") WHERE lower("severity") IN ('low', 'medium', 'high', 'critical') AND "severity" <> lower("severity");
+
+DO $$
+BEGIN
+    PERFORM add_constraint_if_not_exists('hive', 'check_hive_current_status', 'CHECK ("current_status" IN (''active'', ''inactive'', ''maintenance'')) NOT VALID');
+    PERFORM add_constraint_if_not_exists('maintenance_plan', 'check_maintenance_plan_status', 'CHECK ("status" IN (''Pending'', ''In Progress'', ''Completed'', ''Skipped'')) NOT VALID');
+    PERFORM add_constraint_if_not_exists('incident', 'check_incident_severity', 'CHECK ("severity" IN (''low'', ''medium'', ''high'', ''critical'')) NOT VALID');
+    PERFORM add_constraint_if_not_exists('honey_harvest', 'check_quality_grade', 'CHECK ("quality_grade" IN (''A'', ''B'', ''C'', ''D'')) NOT VALID');
+    PERFORM add_constraint_if_not_exists('apiary', 'check_location_not_empty', 'CHECK (btrim("location") <> '''') NOT VALID');
+    PERFORM add_constraint_if_not_exists('production_report', 'check_total_expenses', 'CHECK ("total_expenses" >= 0) NOT VALID');
+    PERFORM add_constraint_if_not_exists('weather_data', 'check_humidity', 'CHECK ("humidity" BETWEEN 0 AND 100) NOT VALID');
+    PERFORM add_constraint_if_not_exists('weather_data', 'check_wind_speed', 'CHECK ("wind_speed" >= 0) NOT VALID');
+    PERFORM add_constraint_if_not_exists('weather_data', 'check_precipitation', 'CHECK ("precipitation" >= 0) NOT VALID');
+    PERFORM add_constraint_if_not_exists('user', 'check_username_length', 'CHECK (char_length("username") BETWEEN 3 AND 50) NOT VALID');
+    PERFORM add_constraint_if_not_exists('incident', 'check_incident_status', 'CHECK ("status" IN (''open'', ''acknowledged'', ''in_progress'', ''resolved'', ''closed''))');
+    PERFORM add_constraint_if_not_exists('incident', 'check_single_assignee', 'CHECK ("assigned_user_id" IS NULL OR "assigned_group_id" IS NULL)');
+    PERFORM add_constraint_if_not_exists('incident_activity', 'check_activity_kind', 'CHECK ("kind" IN (''created'', ''comment'', ''status_change'', ''assignment''))');
+    PERFORM add_constraint_if_not_exists('notification_rule', 'check_rule_severity', 'CHECK ("min_severity" IN (''low'', ''medium'', ''high'', ''critical''))');
+    PERFORM add_constraint_if_not_exists('notification_rule', 'check_rule_recipient', 'CHECK (("user_id" IS NULL) <> ("group_id" IS NULL))');
+    PERFORM add_constraint_if_not_exists('notification_preference', 'check_preference_severity', 'CHECK ("min_severity" IN (''low'', ''medium'', ''high'', ''critical''))');
+    PERFORM add_constraint_if_not_exists('notification_preference', 'check_quiet_hours', 'CHECK (("quiet_hours_start" IS NULL) = ("quiet_hours_end" IS NULL))');
+    PERFORM add_constraint_if_not_exists('maintenance_schedule', 'check_horizon_days', 'CHECK ("horizon_days" BETWEEN 1 AND 365)');
+    PERFORM add_constraint_if_not_exists('maintenance_plan', 'check_plan_assignee', 'CHECK ("assigned_to" IS NOT NULL OR "assigned_group_id" IS NOT NULL) NOT VALID');
+    PERFORM add_constraint_if_not_exists('maintenance_schedule', 'check_schedule_assignee', 'CHECK (("assigned_to" IS NULL) <> ("assigned_group_id" IS NULL))');
+    PERFORM add_constraint_if_not_exists('maintenance_completion', 'check_duration_minutes', 'CHECK ("duration_minutes" >= 0)');
+    PERFORM add_constraint_if_not_exists('inspection_question', 'check_answer_type', 'CHECK ("answer_type" IN (''boolean'', ''number'', ''choice'', ''text''))');
+    PERFORM add_constraint_if_not_exists('inspection_question', 'check_choice_options', 'CHECK ("answer_type" <> ''choice'' OR cardinality("options") > 0)');
+    PERFORM add_constraint_if_not_exists('inspection_question', 'check_value_range', 'CHECK ("min_value" IS NULL OR "max_value" IS NULL OR "min_value" <= "max_value")');
+    PERFORM add_constraint_if_not_exists('inspection', 'check_inspection_date', 'CHECK ("inspection_date" <= CURRENT_DATE)');
+    PERFORM add_constraint_if_not_exists('inspection_answer', 'check_single_value', 'CHECK (num_nonnulls("value_bool", "value_number", "value_text") = 1)');
+    PERFORM add_constraint_if_not_exists('queen', 'check_queen_source', 'CHECK ("source" IN (''bred'', ''purchased'', ''swarm''))');
+    PERFORM add_constraint_if_not_exists('queen', 'check_queen_status', 'CHECK ("status" IN (''present'', ''superseded'', ''missing'', ''replaced''))');
+    PERFORM add_constraint_if_not_exists('queen', 'check_queen_marking_color', 'CHECK ("marking_color" IN (''white'', ''yellow'', ''red'', ''green'', ''blue''))');
+    PERFORM add_constraint_if_not_exists('queen', 'check_queen_not_own_mother', 'CHECK ("mother_id" <> "queen_id")');
+    PERFORM add_constraint_if_not_exists('queen_introduction', 'check_introduction_period', 'CHECK ("removed_date" IS NULL OR "removed_date" >= "introduced_date")');
+    PERFORM add_constraint_if_not_exists('queen_introduction', 'check_removal_reason', 'CHECK (("removed_date" IS NULL) = ("removal_reason" IS NULL) AND ("removal_reason" IS NULL OR "removal_reason" IN (''superseded'', ''missing'', ''replaced'', ''moved'')))');
+    PERFORM add_constraint_if_not_exists('hive_placement', 'check_placement_period', 'CHECK ("removed_date" IS NULL OR "removed_date" >= "placed_date")');
+    PERFORM add_constraint_if_not_exists('colony_event', 'check_colony_event_kind', 'CHECK ("kind" IN (''split'', ''merge'', ''relocate''))');
+    PERFORM add_constraint_if_not_exists('colony_event', 'check_colony_event_date', 'CHECK ("event_date" <= CURRENT_DATE)');
+    PERFORM add_constraint_if_not_exists('colony_event', 'check_population_moved', 'CHECK ("population_moved" >= 0)');
+    PERFORM add_constraint_if_not_exists('bee_community', 'check_not_merged_into_self', 'CHECK ("merged_into" <> "community_id")');
+    PERFORM add_constraint_if_not_exists('treatment_product', 'check_withdrawal_days', 'CHECK ("withdrawal_days" BETWEEN 0 AND 365)');
+    PERFORM add_constraint_if_not_exists('treatment_application', 'check_treatment_period', 'CHECK ("end_date" IS NULL OR "end_date" >= "start_date")');
+    PERFORM add_constraint_if_not_exists('treatment_application', 'check_mite_counts', 'CHECK ("mite_count_before" >= 0 AND "mite_count_after" >= 0)');
+    PERFORM add_constraint_if_not_exists('apiary', 'check_coordinates', 'CHECK (("latitude" IS NULL) = ("longitude" IS NULL) AND "latitude" BETWEEN -90 AND 90 AND "longitude" BETWEEN -180 AND 180)');
+    PERFORM add_constraint_if_not_exists('disease', 'check_default_radius', 'CHECK ("default_radius_km" > 0)');
+    PERFORM add_constraint_if_not_exists('outbreak', 'check_outbreak_zone', 'CHECK (("region_id" IS NULL) <> ("apiary_id" IS NULL) AND ("radius_km" IS NULL OR "apiary_id" IS NOT NULL))');
+    PERFORM add_constraint_if_not_exists('outbreak', 'check_radius', 'CHECK ("radius_km" >= 0)');
+    PERFORM add_constraint_if_not_exists('outbreak', 'check_outbreak_period', 'CHECK ("lifted_date" IS NULL OR "lifted_date" >= "declared_date")');
+    PERFORM add_constraint_if_not_exists('hive', 'check_tare_weight', 'CHECK ("tare_weight_kg" > 0)');
+    PERFORM add_constraint_if_not_exists('hive', 'check_colony_allowance', 'CHECK ("colony_allowance_kg" >= 0)');
+    PERFORM add_constraint_if_not_exists('feeding', 'check_feed_type', 'CHECK ("feed_type" IN (''syrup_1_1'', ''syrup_2_1'', ''fondant'', ''pollen_patty'', ''honey'', ''other''))');
+    PERFORM add_constraint_if_not_exists('feeding', 'check_feed_quantity', 'CHECK ("quantity_kg" > 0)');
+    PERFORM add_constraint_if_not_exists('feeding', 'check_feed_cost', 'CHECK ("cost_per_kg" >= 0)');
+    PERFORM add_constraint_if_not_exists('batch_harvest', 'check_batch_quantity', 'CHECK ("quantity" > 0)');
+    PERFORM add_constraint_if_not_exists('batch_step', 'check_batch_step', 'CHECK ("step" IN (''extraction'', ''filtering'', ''settling'', ''creaming'', ''bottling'', ''other''))');
+    PERFORM add_constraint_if_not_exists('batch_step', 'check_output_quantity', 'CHECK ("output_quantity" > 0)');
+    PERFORM add_constraint_if_not_exists('jar_lot', 'check_jars', 'CHECK ("jar_size_g" > 0 AND "jar_count" > 0)');
+    PERFORM add_constraint_if_not_exists('jar_lot', 'check_best_before', 'CHECK ("best_before" IS NULL OR "best_before" > "bottled_date")');
+    PERFORM add_constraint_if_not_exists('grade_rule', 'check_rule_grade', 'CHECK ("grade" IN (''A'', ''B'', ''C''))');
+    PERFORM add_constraint_if_not_exists('grade_rule', 'check_rule_limits', 'CHECK ("max_moisture_percent" BETWEEN 0 AND 100 AND "max_hmf_mg_kg" >= 0 AND "min_diastase_dn" >= 0)');
+    PERFORM add_constraint_if_not_exists('lab_result', 'check_lab_sample', 'CHECK (("harvest_id" IS NULL) <> ("batch_id" IS NULL))');
+    PERFORM add_constraint_if_not_exists('lab_result', 'check_lab_dates', 'CHECK ("result_date" IS NULL OR "result_date" >= "sample_date")');
+    PERFORM add_constraint_if_not_exists('lab_result', 'check_lab_measurements', 'CHECK ("moisture_percent" BETWEEN 0 AND 100 AND "hmf_mg_kg" >= 0 AND "diastase_dn" >= 0)');
+    PERFORM add_constraint_if_not_exists('stock_item', 'check_container_type', 'CHECK ("container_type" IN (''jar'', ''bucket'', ''drum'', ''comb'', ''other''))');
+    PERFORM add_constraint_if_not_exists('stock_item', 'check_unit_weight', 'CHECK ("unit_weight_kg" > 0)');
+    PERFORM add_constraint_if_not_exists('stock_item', 'check_stock_quantities', 'CHECK ("quantity_reserved" >= 0 AND "quantity_on_hand" >= "quantity_reserved")');
+    PERFORM add_constraint_if_not_exists('stock_item', 'check_unit_price', 'CHECK ("unit_price" >= 0)');
+    PERFORM add_constraint_if_not_exists('sales_order', 'check_order_status', 'CHECK ("status" IN (''reserved'', ''fulfilled'', ''cancelled''))');
+    PERFORM add_constraint_if_not_exists('sales_order', 'check_fulfilled_date', 'CHECK (("status" = ''fulfilled'') = ("fulfilled_date" IS NOT NULL) AND "fulfilled_date" >= "order_date")');
+    PERFORM add_constraint_if_not_exists('order_line', 'check_order_line', 'CHECK ("quantity" > 0 AND "unit_price" >= 0 AND "tax_rate_percent" BETWEEN 0 AND 100)');
+    PERFORM add_constraint_if_not_exists('stock_movement', 'check_movement_reason', 'CHECK ("reason" IN (''receipt'', ''sale'', ''adjustment''))');
+    PERFORM add_constraint_if_not_exists('stock_movement', 'check_movement_change', 'CHECK ("quantity_change" <> 0)');
+    PERFORM add_constraint_if_not_exists('invoice', 'check_invoice_dates', 'CHECK ("due_date" >= "issue_date" AND ("paid_date" IS NULL OR "paid_date" >= "issue_date"))');
+    PERFORM add_constraint_if_not_exists('equipment_type', 'check_equipment_category', 'CHECK ("category" IN (''brood_box'', ''super'', ''frame'', ''excluder'', ''feeder'', ''extractor'', ''protective_gear'', ''tool'', ''other''))');
+    PERFORM add_constraint_if_not_exists('equipment_type', 'check_min_stock', 'CHECK ("min_stock" >= 0)');
+    PERFORM add_constraint_if_not_exists('equipment_stock', 'check_stock_location', 'CHECK (("apiary_id" IS NULL) <> ("warehouse_id" IS NULL))');
+    PERFORM add_constraint_if_not_exists('equipment_stock', 'check_equipment_quantity', 'CHECK ("quantity" >= 0)');
+    PERFORM add_constraint_if_not_exists('hive_component', 'check_component_quantity', 'CHECK ("quantity" > 0)');
+    PERFORM add_constraint_if_not_exists('equipment_checkout', 'check_checkout_quantity', 'CHECK ("quantity" > 0)');
+    PERFORM add_constraint_if_not_exists('equipment_checkout', 'check_checkin', 'CHECK (("checked_in_at" IS NULL) = ("returned_quantity" IS NULL) AND "returned_quantity" BETWEEN 0 AND "quantity")');
+    PERFORM add_constraint_if_not_exists('pollination_contract', 'check_contract_status', 'CHECK ("status" IN (''planned'', ''active'', ''completed'', ''cancelled''))');
+    PERFORM add_constraint_if_not_exists('pollination_contract', 'check_contract_period', 'CHECK ("end_date" >= "start_date")');
+    PERFORM add_constraint_if_not_exists('pollination_contract', 'check_contract_terms', 'CHECK ("hive_count" > 0 AND "price" >= 0)');
+    PERFORM add_constraint_if_not_exists('contract_hive', 'check_contract_hive_dates', 'CHECK ("returned_date" IS NULL OR ("deployed_date" IS NOT NULL AND "returned_date" >= "deployed_date"))');
+    PERFORM add_constraint_if_not_exists('region', 'check_region_parent', 'CHECK ("parent_region_id" <> "region_id")');
+    PERFORM add_constraint_if_not_exists('weather_data', 'check_weather_hour', 'CHECK ("hour" BETWEEN 0 AND 23)');
+    PERFORM add_constraint_if_not_exists('weather_data', 'check_weather_source', 'CHECK ("source" IN (''manual'', ''csv'', ''metar'', ''synop'', ''station''))');
+    PERFORM add_constraint_if_not_exists('hive_weight_day', 'check_weight_day_readings', 'CHECK ("readings" > 0 AND "min_kg" <= "max_kg")');
+    PERFORM add_constraint_if_not_exists('hive_weight_event', 'check_weight_event_kind', 'CHECK ("kind" IN (''flow_start'', ''flow_end'', ''swarm'', ''robbing''))');
+    PERFORM add_constraint_if_not_exists('user', 'check_email_format', 'CHECK ("email" ~* ''^[^@[:space:]]+@[^@[:space:]]+\.[^@[:space:]]+$'') NOT VALID');
 END $$;
@@ -107,7 +107,7 @@ CREATE TABLE IF NOT EXISTS "maintenance_plan" (
 	"planned_date" DATE,
 	"work_type" VARCHAR,
 	"assigned_to" INTEGER,
-	"status" VARCHAR(50) NOT NULL DEFAULT 'pending',
+	"status" VARCHAR(50) NOT NULL DEFAULT 'Pending',
 	PRIMARY KEY("plan_id")
 );
 
@@ -309,3 +309,661 @@ ALTER TABLE
 	"worker_group_member"
 ADD
 	FOREIGN KEY ("worker_id") REFERENCES "user"("user_id") ON UPDATE NO ACTION ON DELETE CASCADE;
+
+CREATE TABLE IF NOT EXISTS "login_failure" (
+	"user_id" INTEGER NOT NULL REFERENCES "user"("user_id") ON DELETE CASCADE,
+	"failed_attempts" INTEGER NOT NULL DEFAULT 0,
+	"last_failed_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	"locked_until" TIMESTAMP,
+	PRIMARY KEY("user_id")
+);
+
+CREATE TABLE IF NOT EXISTS "password_reset_token" (
+	"token_id" SERIAL,
+	"user_id" INTEGER NOT NULL REFERENCES "user"("user_id") ON DELETE CASCADE,
+	"token_hash" VARCHAR(64) NOT NULL UNIQUE,
+	"expires_at" TIMESTAMP NOT NULL,
+	"used_at" TIMESTAMP,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("token_id")
+);
+
+CREATE TABLE IF NOT EXISTS "email_change_request" (
+	"request_id" SERIAL,
+	"user_id" INTEGER NOT NULL REFERENCES "user"("user_id") ON DELETE CASCADE,
+	"new_email" VARCHAR NOT NULL,
+	"token_hash" VARCHAR(64) NOT NULL UNIQUE,
+	"expires_at" TIMESTAMP NOT NULL,
+	"used_at" TIMESTAMP,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("request_id")
+);
+
+-- Incident lifecycle: status, assignee and a timestamp per transition
+ALTER TABLE "incident" ADD COLUMN IF NOT EXISTS "status" VARCHAR NOT NULL DEFAULT 'open';
+ALTER TABLE "incident" ADD COLUMN IF NOT EXISTS "assigned_user_id" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL;
+ALTER TABLE "incident" ADD COLUMN IF NOT EXISTS "assigned_group_id" INTEGER REFERENCES "worker_group"("group_id") ON DELETE SET NULL;
+-- Existing incidents take their creation time from the day they were reported, not from this migration
+ALTER TABLE "incident" ADD COLUMN IF NOT EXISTS "created_at" TIMESTAMP;
+UPDATE "incident" SET "created_at" = COALESCE("incident_date"::TIMESTAMP, CURRENT_TIMESTAMP) WHERE "created_at" IS NULL;
+ALTER TABLE "incident" ALTER COLUMN "created_at" SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE "incident" ALTER COLUMN "created_at" SET NOT NULL;
+ALTER TABLE "incident" ADD COLUMN IF NOT EXISTS "acknowledged_at" TIMESTAMP;
+ALTER TABLE "incident" ADD COLUMN IF NOT EXISTS "started_at" TIMESTAMP;
+ALTER TABLE "incident" ADD COLUMN IF NOT EXISTS "resolved_at" TIMESTAMP;
+ALTER TABLE "incident" ADD COLUMN IF NOT EXISTS "closed_at" TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS "incident_activity" (
+	"activity_id" SERIAL,
+	"incident_id" INTEGER NOT NULL REFERENCES "incident"("incident_id") ON DELETE CASCADE,
+	"user_id" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL,
+	"kind" VARCHAR NOT NULL,
+	"from_status" VARCHAR,
+	"to_status" VARCHAR,
+	"body" TEXT,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("activity_id")
+);
+
+-- Incident notifications: routing rules, per-user preferences and the in-app inbox
+-- Incidents that predate escalation count as escalated, so the first tick doesn't page managers about history
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name='incident' AND column_name='escalated_at'
+    ) THEN
+        ALTER TABLE "incident" ADD COLUMN "escalated_at" TIMESTAMP;
+        UPDATE "incident" SET "escalated_at" = CURRENT_TIMESTAMP;
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS "notification_rule" (
+	"rule_id" SERIAL,
+	"name" VARCHAR(100) NOT NULL,
+	"apiary_id" INTEGER REFERENCES "apiary"("apiary_id") ON DELETE CASCADE,
+	"region_id" INTEGER REFERENCES "region"("region_id") ON DELETE CASCADE,
+	"min_severity" VARCHAR NOT NULL DEFAULT 'low',
+	"user_id" INTEGER REFERENCES "user"("user_id") ON DELETE CASCADE,
+	"group_id" INTEGER REFERENCES "worker_group"("group_id") ON DELETE CASCADE,
+	"enabled" BOOLEAN NOT NULL DEFAULT TRUE,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("rule_id")
+);
+
+CREATE TABLE IF NOT EXISTS "notification_preference" (
+	"user_id" INTEGER NOT NULL REFERENCES "user"("user_id") ON DELETE CASCADE,
+	"email_enabled" BOOLEAN NOT NULL DEFAULT TRUE,
+	"inbox_enabled" BOOLEAN NOT NULL DEFAULT TRUE,
+	"webhook_url" VARCHAR(500),
+	"min_severity" VARCHAR NOT NULL DEFAULT 'low',
+	"quiet_hours_start" VARCHAR(5),
+	"quiet_hours_end" VARCHAR(5),
+	"timezone" VARCHAR NOT NULL DEFAULT 'UTC',
+	PRIMARY KEY("user_id")
+);
+
+CREATE TABLE IF NOT EXISTS "notification" (
+	"notification_id" SERIAL,
+	"user_id" INTEGER NOT NULL REFERENCES "user"("user_id") ON DELETE CASCADE,
+	"incident_id" INTEGER REFERENCES "incident"("incident_id") ON DELETE SET NULL,
+	"subject" VARCHAR NOT NULL,
+	"body" TEXT NOT NULL,
+	"read_at" TIMESTAMP,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("notification_id")
+);
+
+-- Email and webhook notifications held back by the recipient's quiet hours
+CREATE TABLE IF NOT EXISTS "deferred_notification" (
+	"deferred_id" SERIAL,
+	"user_id" INTEGER NOT NULL REFERENCES "user"("user_id") ON DELETE CASCADE,
+	"incident_id" INTEGER REFERENCES "incident"("incident_id") ON DELETE CASCADE,
+	"subject" VARCHAR NOT NULL,
+	"body" TEXT NOT NULL,
+	"deliver_after" TIMESTAMP NOT NULL,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("deferred_id")
+);
+
+-- Recurring maintenance schedules expanded into maintenance plans
+CREATE TABLE IF NOT EXISTS "maintenance_schedule" (
+	"schedule_id" SERIAL,
+	"apiary_id" INTEGER NOT NULL REFERENCES "apiary"("apiary_id") ON DELETE CASCADE,
+	"hive_ids" INTEGER[],
+	"work_type" VARCHAR(100) NOT NULL,
+	"assigned_to" INTEGER NOT NULL,
+	"rrule" VARCHAR(500) NOT NULL,
+	"start_date" DATE NOT NULL,
+	"horizon_days" INTEGER NOT NULL DEFAULT 28,
+	"active" BOOLEAN NOT NULL DEFAULT TRUE,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("schedule_id")
+);
+
+ALTER TABLE "maintenance_plan" ADD COLUMN IF NOT EXISTS "hive_id" INTEGER REFERENCES "hive"("hive_id") ON DELETE CASCADE;
+ALTER TABLE "maintenance_plan" ADD COLUMN IF NOT EXISTS "schedule_id" INTEGER REFERENCES "maintenance_schedule"("schedule_id") ON DELETE SET NULL;
+ALTER TABLE "maintenance_plan" ADD COLUMN IF NOT EXISTS "occurrence_date" DATE;
+ALTER TABLE "maintenance_plan" ADD COLUMN IF NOT EXISTS "overdue_at" TIMESTAMP;
+
+-- Group assignment of maintenance work and completion records
+ALTER TABLE "maintenance_plan" ADD COLUMN IF NOT EXISTS "assigned_group_id" INTEGER REFERENCES "worker_group"("group_id") ON DELETE SET NULL;
+ALTER TABLE "maintenance_schedule" ADD COLUMN IF NOT EXISTS "assigned_group_id" INTEGER REFERENCES "worker_group"("group_id") ON DELETE SET NULL;
+ALTER TABLE "maintenance_schedule" ALTER COLUMN "assigned_to" DROP NOT NULL;
+
+CREATE TABLE IF NOT EXISTS "maintenance_completion" (
+	"plan_id" INTEGER NOT NULL REFERENCES "maintenance_plan"("plan_id") ON DELETE CASCADE,
+	"completed_by" INTEGER NOT NULL REFERENCES "user"("user_id"),
+	"completed_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	"duration_minutes" INTEGER NOT NULL,
+	"notes" TEXT,
+	PRIMARY KEY("plan_id")
+);
+
+-- Structured inspections: checklist templates and typed answers
+CREATE TABLE IF NOT EXISTS "inspection_template" (
+	"template_id" SERIAL,
+	"name" VARCHAR(100) NOT NULL UNIQUE,
+	"description" TEXT,
+	"created_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL,
+	"active" BOOLEAN NOT NULL DEFAULT TRUE,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("template_id")
+);
+
+CREATE TABLE IF NOT EXISTS "inspection_question" (
+	"question_id" SERIAL,
+	"template_id" INTEGER NOT NULL REFERENCES "inspection_template"("template_id") ON DELETE CASCADE,
+	"key" VARCHAR(50) NOT NULL,
+	"label" VARCHAR(200) NOT NULL,
+	"answer_type" VARCHAR(20) NOT NULL,
+	"options" TEXT[],
+	"min_value" NUMERIC,
+	"max_value" NUMERIC,
+	"required" BOOLEAN NOT NULL DEFAULT FALSE,
+	"position" INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY("question_id"),
+	UNIQUE ("template_id", "key")
+);
+
+CREATE TABLE IF NOT EXISTS "inspection" (
+	"inspection_id" SERIAL,
+	"hive_id" INTEGER NOT NULL REFERENCES "hive"("hive_id") ON DELETE CASCADE,
+	"template_id" INTEGER NOT NULL REFERENCES "inspection_template"("template_id"),
+	"inspected_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL,
+	"inspection_date" DATE NOT NULL,
+	"notes" TEXT,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("inspection_id")
+);
+
+CREATE TABLE IF NOT EXISTS "inspection_answer" (
+	"inspection_id" INTEGER NOT NULL REFERENCES "inspection"("inspection_id") ON DELETE CASCADE,
+	"question_id" INTEGER NOT NULL REFERENCES "inspection_question"("question_id"),
+	"value_bool" BOOLEAN,
+	"value_number" NUMERIC,
+	"value_text" TEXT,
+	PRIMARY KEY("inspection_id", "question_id")
+);
+
+-- Queen registry: queens, their lineage and the communities they headed
+CREATE TABLE IF NOT EXISTS "queen" (
+	"queen_id" SERIAL,
+	"birth_date" DATE,
+	"breed" VARCHAR(100) NOT NULL,
+	"line" VARCHAR(100),
+	"marking_color" VARCHAR(20),
+	"mother_id" INTEGER REFERENCES "queen"("queen_id") ON DELETE SET NULL,
+	"source" VARCHAR(20) NOT NULL,
+	"status" VARCHAR(20) NOT NULL DEFAULT 'present',
+	"notes" TEXT,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("queen_id")
+);
+
+CREATE TABLE IF NOT EXISTS "queen_introduction" (
+	"introduction_id" SERIAL,
+	"queen_id" INTEGER NOT NULL REFERENCES "queen"("queen_id") ON DELETE CASCADE,
+	"community_id" INTEGER NOT NULL REFERENCES "bee_community"("community_id") ON DELETE CASCADE,
+	"introduced_date" DATE NOT NULL,
+	"removed_date" DATE,
+	"removal_reason" VARCHAR(20),
+	"notes" TEXT,
+	PRIMARY KEY("introduction_id")
+);
+
+-- Hive movements, colony splits and merges
+CREATE TABLE IF NOT EXISTS "hive_placement" (
+	"placement_id" SERIAL,
+	"hive_id" INTEGER NOT NULL REFERENCES "hive"("hive_id") ON DELETE CASCADE,
+	"apiary_id" INTEGER NOT NULL REFERENCES "apiary"("apiary_id") ON DELETE CASCADE,
+	"placed_date" DATE NOT NULL,
+	"removed_date" DATE,
+	PRIMARY KEY("placement_id")
+);
+
+-- Hives created before placements were tracked start at their current apiary
+INSERT INTO "hive_placement" ("hive_id", "apiary_id", "placed_date")
+SELECT h."hive_id", h."apiary_id", COALESCE(h."installation_date", CURRENT_DATE)
+FROM "hive" h
+WHERE h."apiary_id" IS NOT NULL
+	AND NOT EXISTS (SELECT 1 FROM "hive_placement" p WHERE p."hive_id" = h."hive_id");
+
+ALTER TABLE "bee_community" ADD COLUMN IF NOT EXISTS "dissolved_date" DATE;
+ALTER TABLE "bee_community" ADD COLUMN IF NOT EXISTS "merged_into" INTEGER REFERENCES "bee_community"("community_id") ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS "colony_event" (
+	"event_id" SERIAL,
+	"kind" VARCHAR(20) NOT NULL,
+	"event_date" DATE NOT NULL,
+	"source_hive_id" INTEGER REFERENCES "hive"("hive_id") ON DELETE SET NULL,
+	"target_hive_id" INTEGER REFERENCES "hive"("hive_id") ON DELETE SET NULL,
+	"source_community_id" INTEGER REFERENCES "bee_community"("community_id") ON DELETE SET NULL,
+	"target_community_id" INTEGER REFERENCES "bee_community"("community_id") ON DELETE SET NULL,
+	"from_apiary_id" INTEGER REFERENCES "apiary"("apiary_id") ON DELETE SET NULL,
+	"to_apiary_id" INTEGER REFERENCES "apiary"("apiary_id") ON DELETE SET NULL,
+	"population_moved" INTEGER,
+	"performed_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL,
+	"notes" TEXT,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("event_id")
+);
+
+-- Treatment catalogue, applications and withdrawal periods
+CREATE TABLE IF NOT EXISTS "treatment_product" (
+	"product_id" SERIAL,
+	"name" VARCHAR(100) NOT NULL UNIQUE,
+	"active_substance" VARCHAR(100) NOT NULL,
+	"dosage" VARCHAR(200) NOT NULL,
+	"withdrawal_days" INTEGER NOT NULL DEFAULT 0,
+	"target" VARCHAR(100),
+	"notes" TEXT,
+	"active" BOOLEAN NOT NULL DEFAULT TRUE,
+	PRIMARY KEY("product_id")
+);
+
+CREATE TABLE IF NOT EXISTS "treatment_application" (
+	"application_id" SERIAL,
+	"product_id" INTEGER NOT NULL REFERENCES "treatment_product"("product_id"),
+	"hive_id" INTEGER NOT NULL REFERENCES "hive"("hive_id") ON DELETE CASCADE,
+	"community_id" INTEGER REFERENCES "bee_community"("community_id") ON DELETE SET NULL,
+	"start_date" DATE NOT NULL,
+	"end_date" DATE,
+	"dosage_applied" VARCHAR(200),
+	"mite_count_before" INTEGER,
+	"mite_count_after" INTEGER,
+	"applied_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL,
+	"notes" TEXT,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("application_id")
+);
+
+ALTER TABLE "honey_harvest" ADD COLUMN IF NOT EXISTS "withdrawal_override_reason" TEXT;
+ALTER TABLE "honey_harvest" ADD COLUMN IF NOT EXISTS "overridden_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL;
+ALTER TABLE "veterinary_record" ADD COLUMN IF NOT EXISTS "application_id" INTEGER REFERENCES "treatment_application"("application_id") ON DELETE SET NULL;
+
+-- Notifiable diseases, outbreak declarations and the quarantine zones they impose
+ALTER TABLE "apiary" ADD COLUMN IF NOT EXISTS "latitude" DOUBLE PRECISION;
+ALTER TABLE "apiary" ADD COLUMN IF NOT EXISTS "longitude" DOUBLE PRECISION;
+
+CREATE TABLE IF NOT EXISTS "disease" (
+	"disease_id" SERIAL,
+	"name" VARCHAR(100) NOT NULL UNIQUE,
+	"notifiable" BOOLEAN NOT NULL DEFAULT FALSE,
+	"default_radius_km" NUMERIC(6, 2),
+	"description" TEXT,
+	PRIMARY KEY("disease_id")
+);
+
+ALTER TABLE "veterinary_record" ADD COLUMN IF NOT EXISTS "disease_id" INTEGER REFERENCES "disease"("disease_id") ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS "outbreak" (
+	"outbreak_id" SERIAL,
+	"disease_id" INTEGER NOT NULL REFERENCES "disease"("disease_id"),
+	"record_id" INTEGER REFERENCES "veterinary_record"("record_id") ON DELETE SET NULL,
+	"region_id" INTEGER REFERENCES "region"("region_id") ON DELETE CASCADE,
+	"apiary_id" INTEGER REFERENCES "apiary"("apiary_id") ON DELETE CASCADE,
+	"radius_km" NUMERIC(6, 2),
+	"declared_date" DATE NOT NULL,
+	"declared_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL,
+	"lifted_date" DATE,
+	"lifted_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL,
+	"description" TEXT,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("outbreak_id")
+);
+
+ALTER TABLE "notification" ADD COLUMN IF NOT EXISTS "outbreak_id" INTEGER REFERENCES "outbreak"("outbreak_id") ON DELETE SET NULL;
+
+-- Feeding events and stores estimated from hive weight
+ALTER TABLE "hive" ADD COLUMN IF NOT EXISTS "tare_weight_kg" NUMERIC(6, 2);
+-- Weight of the bees, brood and comb, which the weight above tare includes but are not stores
+ALTER TABLE "hive" ADD COLUMN IF NOT EXISTS "colony_allowance_kg" NUMERIC(6, 2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS "feeding" (
+	"feeding_id" SERIAL,
+	"hive_id" INTEGER NOT NULL REFERENCES "hive"("hive_id") ON DELETE CASCADE,
+	"feed_type" VARCHAR NOT NULL,
+	"quantity_kg" NUMERIC(8, 2) NOT NULL,
+	"cost_per_kg" NUMERIC(10, 2),
+	"feeding_date" DATE NOT NULL,
+	"fed_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL,
+	"notes" TEXT,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("feeding_id")
+);
+
+ALTER TABLE "production_report" ADD COLUMN IF NOT EXISTS "feed_used_kg" FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE "production_report" ADD COLUMN IF NOT EXISTS "feed_cost" FLOAT NOT NULL DEFAULT 0;
+
+-- Honey batches blended from harvests, their processing steps and bottled jar lots
+CREATE TABLE IF NOT EXISTS "honey_batch" (
+	"batch_id" SERIAL,
+	"lot_number" VARCHAR(50) NOT NULL UNIQUE,
+	"created_date" DATE NOT NULL,
+	"notes" TEXT,
+	"created_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("batch_id")
+);
+
+CREATE TABLE IF NOT EXISTS "batch_harvest" (
+	"batch_id" INTEGER NOT NULL REFERENCES "honey_batch"("batch_id") ON DELETE CASCADE,
+	"harvest_id" INTEGER NOT NULL REFERENCES "honey_harvest"("harvest_id"),
+	"quantity" FLOAT NOT NULL,
+	PRIMARY KEY("batch_id", "harvest_id")
+);
+
+CREATE TABLE IF NOT EXISTS "batch_step" (
+	"step_id" SERIAL,
+	"batch_id" INTEGER NOT NULL REFERENCES "honey_batch"("batch_id") ON DELETE CASCADE,
+	"step" VARCHAR NOT NULL,
+	"step_date" DATE NOT NULL,
+	"output_quantity" FLOAT,
+	"performed_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL,
+	"notes" TEXT,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("step_id")
+);
+
+CREATE TABLE IF NOT EXISTS "jar_lot" (
+	"jar_lot_id" SERIAL,
+	"batch_id" INTEGER NOT NULL REFERENCES "honey_batch"("batch_id") ON DELETE CASCADE,
+	"lot_number" VARCHAR(50) NOT NULL UNIQUE,
+	"jar_size_g" INTEGER NOT NULL,
+	"jar_count" INTEGER NOT NULL,
+	"bottled_date" DATE NOT NULL,
+	"best_before" DATE,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("jar_lot_id")
+);
+
+-- Lab analyses grade harvests and batches against grade_rule; out-of-spec results block release
+CREATE TABLE IF NOT EXISTS "grade_rule" (
+	"grade" VARCHAR(1) NOT NULL,
+	"max_moisture_percent" FLOAT,
+	"max_hmf_mg_kg" FLOAT,
+	"min_diastase_dn" FLOAT,
+	"description" TEXT,
+	PRIMARY KEY("grade")
+);
+
+-- Grade C holds the Codex Alimentarius limits for table honey, anything worse is out of spec
+INSERT INTO "grade_rule" ("grade", "max_moisture_percent", "max_hmf_mg_kg", "min_diastase_dn", "description")
+VALUES
+	('A', 17.5, 10, 15, 'Premium'),
+	('B', 18.5, 20, 10, 'Standard'),
+	('C', 20, 40, 8, 'Codex Alimentarius minimum')
+ON CONFLICT ("grade") DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS "lab_result" (
+	"result_id" SERIAL,
+	"harvest_id" INTEGER REFERENCES "honey_harvest"("harvest_id") ON DELETE CASCADE,
+	"batch_id" INTEGER REFERENCES "honey_batch"("batch_id") ON DELETE CASCADE,
+	"lab_name" VARCHAR(200),
+	"sample_reference" VARCHAR(100),
+	"sample_date" DATE NOT NULL,
+	"result_date" DATE,
+	"moisture_percent" FLOAT,
+	"hmf_mg_kg" FLOAT,
+	"diastase_dn" FLOAT,
+	"pollen_analysis" TEXT,
+	"residue_detected" BOOLEAN,
+	"residue_notes" TEXT,
+	"grade" VARCHAR(1),
+	"out_of_spec" BOOLEAN NOT NULL DEFAULT FALSE,
+	"recorded_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("result_id")
+);
+
+ALTER TABLE "honey_batch" ADD COLUMN IF NOT EXISTS "released_date" DATE;
+ALTER TABLE "honey_batch" ADD COLUMN IF NOT EXISTS "released_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL;
+ALTER TABLE "notification" ADD COLUMN IF NOT EXISTS "lab_result_id" INTEGER REFERENCES "lab_result"("result_id") ON DELETE CASCADE;
+
+-- Honey stock by lot, container and storage location, reserved and sold through sales orders
+CREATE TABLE IF NOT EXISTS "customer" (
+	"customer_id" SERIAL,
+	"name" VARCHAR(200) NOT NULL,
+	"email" VARCHAR(255),
+	"phone" VARCHAR(50),
+	"address" TEXT,
+	"tax_id" VARCHAR(50),
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("customer_id")
+);
+
+CREATE TABLE IF NOT EXISTS "stock_item" (
+	"stock_id" SERIAL,
+	"batch_id" INTEGER NOT NULL REFERENCES "honey_batch"("batch_id"),
+	"jar_lot_id" INTEGER REFERENCES "jar_lot"("jar_lot_id"),
+	"container_type" VARCHAR NOT NULL,
+	"unit_weight_kg" FLOAT NOT NULL,
+	"location" VARCHAR(200) NOT NULL,
+	"quantity_on_hand" INTEGER NOT NULL DEFAULT 0,
+	"quantity_reserved" INTEGER NOT NULL DEFAULT 0,
+	"unit_price" FLOAT,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("stock_id")
+);
+
+CREATE TABLE IF NOT EXISTS "sales_order" (
+	"order_id" SERIAL,
+	"customer_id" INTEGER NOT NULL REFERENCES "customer"("customer_id"),
+	"order_date" DATE NOT NULL,
+	"status" VARCHAR NOT NULL DEFAULT 'reserved',
+	"fulfilled_date" DATE,
+	"notes" TEXT,
+	"created_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("order_id")
+);
+
+CREATE TABLE IF NOT EXISTS "order_line" (
+	"line_id" SERIAL,
+	"order_id" INTEGER NOT NULL REFERENCES "sales_order"("order_id") ON DELETE CASCADE,
+	"stock_id" INTEGER NOT NULL REFERENCES "stock_item"("stock_id"),
+	"quantity" INTEGER NOT NULL,
+	"unit_price" FLOAT NOT NULL,
+	"tax_rate_percent" FLOAT NOT NULL DEFAULT 0,
+	PRIMARY KEY("line_id")
+);
+
+CREATE TABLE IF NOT EXISTS "stock_movement" (
+	"movement_id" SERIAL,
+	"stock_id" INTEGER NOT NULL REFERENCES "stock_item"("stock_id") ON DELETE CASCADE,
+	"quantity_change" INTEGER NOT NULL,
+	"reason" VARCHAR NOT NULL,
+	"order_id" INTEGER REFERENCES "sales_order"("order_id") ON DELETE SET NULL,
+	"moved_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL,
+	"moved_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	"notes" TEXT,
+	PRIMARY KEY("movement_id")
+);
+
+CREATE TABLE IF NOT EXISTS "invoice" (
+	"invoice_id" SERIAL,
+	"order_id" INTEGER NOT NULL UNIQUE REFERENCES "sales_order"("order_id"),
+	"invoice_number" VARCHAR(50) NOT NULL UNIQUE,
+	"issue_date" DATE NOT NULL,
+	"due_date" DATE NOT NULL,
+	"net_total" FLOAT NOT NULL,
+	"tax_total" FLOAT NOT NULL,
+	"total" FLOAT NOT NULL,
+	"paid_date" DATE,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("invoice_id")
+);
+
+CREATE TABLE IF NOT EXISTS "invoice_line" (
+	"invoice_line_id" SERIAL,
+	"invoice_id" INTEGER NOT NULL REFERENCES "invoice"("invoice_id") ON DELETE CASCADE,
+	"stock_id" INTEGER NOT NULL REFERENCES "stock_item"("stock_id"),
+	"description" VARCHAR(300) NOT NULL,
+	"quantity" INTEGER NOT NULL,
+	"unit_price" FLOAT NOT NULL,
+	"tax_rate_percent" FLOAT NOT NULL,
+	"net_amount" FLOAT NOT NULL,
+	"tax_amount" FLOAT NOT NULL,
+	PRIMARY KEY("invoice_line_id")
+);
+
+ALTER TABLE "production_report" ADD COLUMN IF NOT EXISTS "total_revenue" FLOAT NOT NULL DEFAULT 0;
+ALTER TABLE "production_report" ADD COLUMN IF NOT EXISTS "profit" FLOAT GENERATED ALWAYS AS ("total_revenue" - COALESCE("total_expenses", 0)) STORED;
+
+-- Equipment inventory: stock per apiary or warehouse, components on hives and check-outs to workers
+CREATE TABLE IF NOT EXISTS "equipment_type" (
+	"type_id" SERIAL,
+	"name" VARCHAR NOT NULL UNIQUE,
+	"category" VARCHAR NOT NULL,
+	"description" TEXT,
+	"min_stock" INTEGER NOT NULL DEFAULT 0,
+	"low_stock_alerted_on" DATE,
+	PRIMARY KEY("type_id")
+);
+
+CREATE TABLE IF NOT EXISTS "warehouse" (
+	"warehouse_id" SERIAL,
+	"name" VARCHAR NOT NULL UNIQUE,
+	"address" TEXT,
+	PRIMARY KEY("warehouse_id")
+);
+
+CREATE TABLE IF NOT EXISTS "equipment_stock" (
+	"equipment_stock_id" SERIAL,
+	"type_id" INTEGER NOT NULL REFERENCES "equipment_type"("type_id") ON DELETE CASCADE,
+	"apiary_id" INTEGER REFERENCES "apiary"("apiary_id") ON DELETE CASCADE,
+	"warehouse_id" INTEGER REFERENCES "warehouse"("warehouse_id") ON DELETE CASCADE,
+	"quantity" INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY("equipment_stock_id")
+);
+
+CREATE TABLE IF NOT EXISTS "hive_component" (
+	"hive_id" INTEGER NOT NULL REFERENCES "hive"("hive_id") ON DELETE CASCADE,
+	"type_id" INTEGER NOT NULL REFERENCES "equipment_type"("type_id") ON DELETE CASCADE,
+	"quantity" INTEGER NOT NULL,
+	"updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("hive_id", "type_id")
+);
+
+CREATE TABLE IF NOT EXISTS "equipment_checkout" (
+	"checkout_id" SERIAL,
+	"equipment_stock_id" INTEGER NOT NULL REFERENCES "equipment_stock"("equipment_stock_id") ON DELETE CASCADE,
+	"user_id" INTEGER NOT NULL REFERENCES "user"("user_id") ON DELETE CASCADE,
+	"quantity" INTEGER NOT NULL,
+	"checked_out_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	"due_date" DATE,
+	"checked_in_at" TIMESTAMP,
+	"returned_quantity" INTEGER,
+	"notes" TEXT,
+	PRIMARY KEY("checkout_id")
+);
+
+-- Pollination contracts: hives rented out to growers, placed at a field apiary for the contract period
+CREATE TABLE IF NOT EXISTS "pollination_contract" (
+	"contract_id" SERIAL,
+	"customer_id" INTEGER NOT NULL REFERENCES "customer"("customer_id"),
+	"crop" VARCHAR NOT NULL,
+	"field_apiary_id" INTEGER NOT NULL REFERENCES "apiary"("apiary_id"),
+	"hive_count" INTEGER NOT NULL,
+	"start_date" DATE NOT NULL,
+	"end_date" DATE NOT NULL,
+	"price" NUMERIC(12, 2) NOT NULL,
+	"status" VARCHAR NOT NULL DEFAULT 'planned',
+	"notes" TEXT,
+	"created_by" INTEGER REFERENCES "user"("user_id") ON DELETE SET NULL,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("contract_id")
+);
+
+CREATE TABLE IF NOT EXISTS "contract_hive" (
+	"contract_id" INTEGER NOT NULL REFERENCES "pollination_contract"("contract_id") ON DELETE CASCADE,
+	"hive_id" INTEGER NOT NULL REFERENCES "hive"("hive_id") ON DELETE CASCADE,
+	"home_apiary_id" INTEGER NOT NULL REFERENCES "apiary"("apiary_id"),
+	"deployed_date" DATE,
+	"returned_date" DATE,
+	PRIMARY KEY("contract_id", "hive_id")
+);
+
+ALTER TABLE "hive_placement" ADD COLUMN IF NOT EXISTS "contract_id" INTEGER REFERENCES "pollination_contract"("contract_id") ON DELETE SET NULL;
+
+-- Site outlines: apiary sites and region boundaries as polygons of (longitude, latitude) points
+ALTER TABLE "apiary" ADD COLUMN IF NOT EXISTS "site_polygon" POLYGON;
+ALTER TABLE "region" ADD COLUMN IF NOT EXISTS "boundary" POLYGON;
+
+-- Region hierarchy: a region may be nested in a parent region (country -> province -> district)
+ALTER TABLE "region" ADD COLUMN IF NOT EXISTS "parent_region_id" INTEGER REFERENCES "region"("region_id") ON DELETE RESTRICT;
+
+-- Weather ingestion: hourly readings next to daily ones (hour is NULL for a daily reading), where each came from,
+-- and weather stations pushing readings with a token
+ALTER TABLE "weather_data" ADD COLUMN IF NOT EXISTS "hour" SMALLINT;
+ALTER TABLE "weather_data" ADD COLUMN IF NOT EXISTS "source" VARCHAR NOT NULL DEFAULT 'manual';
+
+-- Keep only the latest reading per region, date and hour before they become unique
+DELETE FROM "weather_data" w
+USING "weather_data" d
+WHERE d.region_id = w.region_id
+AND d.date = w.date
+AND COALESCE(d.hour, -1) = COALESCE(w.hour, -1)
+AND d.weather_id > w.weather_id;
+
+CREATE TABLE IF NOT EXISTS "weather_station" (
+	"station_id" SERIAL,
+	"code" VARCHAR NOT NULL UNIQUE,
+	"name" VARCHAR NOT NULL,
+	"region_id" INTEGER NOT NULL REFERENCES "region"("region_id") ON DELETE CASCADE,
+	"token_hash" VARCHAR NOT NULL UNIQUE,
+	"last_seen_at" TIMESTAMP,
+	"created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("station_id")
+);
+
+-- Hive scale analysis: daily weight summaries per hive and the events read from them
+-- (nectar flow start and end, swarming, robbing)
+CREATE TABLE IF NOT EXISTS "hive_weight_day" (
+	"hive_id" INTEGER NOT NULL REFERENCES "hive"("hive_id") ON DELETE CASCADE,
+	"date" DATE NOT NULL,
+	"opening_kg" FLOAT NOT NULL,
+	"closing_kg" FLOAT NOT NULL,
+	"min_kg" FLOAT NOT NULL,
+	"max_kg" FLOAT NOT NULL,
+	"net_gain_kg" FLOAT NOT NULL,
+	"max_drop_kg" FLOAT NOT NULL DEFAULT 0,
+	"max_drop_at" TIMESTAMP,
+	"readings" INTEGER NOT NULL,
+	PRIMARY KEY("hive_id", "date")
+);
+
+CREATE TABLE IF NOT EXISTS "hive_weight_event" (
+	"event_id" SERIAL,
+	"hive_id" INTEGER NOT NULL REFERENCES "hive"("hive_id") ON DELETE CASCADE,
+	"apiary_id" INTEGER REFERENCES "apiary"("apiary_id") ON DELETE SET NULL,
+	"kind" VARCHAR NOT NULL,
+	"date" DATE NOT NULL,
+	"occurred_at" TIMESTAMP,
+	"change_kg" FLOAT NOT NULL,
+	"incident_id" INTEGER REFERENCES "incident"("incident_id") ON DELETE SET NULL,
+	"detected_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY("event_id"),
+	UNIQUE("hive_id", "kind", "date")
+);
@@ -67,11 +67,10 @@ CREATE OR REPLACE FUNCTION get_avg_temperature(
 DECLARE
     avg_temp DECIMAL;
 BEGIN
+    -- Каждый день учитывается один раз, сколько бы почасовых наблюдений за него ни было
     SELECT AVG(temperature::DECIMAL)
     INTO avg_temp
-    FROM weather_data
-    WHERE region_id = p_region_id
-    AND date >= CURRENT_DATE - p_days;
+    FROM region_daily_weather(p_region_id, CURRENT_DATE - p_days, CURRENT_DATE);
 
     RETURN COALESCE(avg_temp, 0);
 END;
@@ -84,12 +83,14 @@ CREATE OR REPLACE PROCEDURE assign_maintenance_plan(
 ) AS $$
 BEGIN
     UPDATE maintenance_plan
-    SET assigned_to = p_user_id
+    SET assigned_to = p_user_id,
+        assigned_group_id = NULL
     WHERE plan_id = p_plan_id;
 END;
 $$ LANGUAGE plpgsql;
 
 -- 7. Функция для проверки доступа пользователя к региону
+-- Доступ к региону дает и доступ ко всем вложенным в него регионам
 CREATE OR REPLACE FUNCTION has_region_access(
     p_user_id INTEGER,
     p_region_id INTEGER
@@ -99,8 +100,8 @@ DECLARE
 BEGIN
     SELECT EXISTS (
         SELECT 1
-        FROM allowed_region
-        WHERE user_id = p_user_id AND region_id = p_region_id
+        FROM user_region_ids(p_user_id)
+        WHERE user_region_ids = p_region_id
     ) INTO has_access;
 
     RETURN has_access;
@@ -143,20 +144,585 @@ CREATE OR REPLACE PROCEDURE create_production_report(
 DECLARE
     total_honey DECIMAL;
     total_expenses DECIMAL;
+    feed_used DECIMAL;
+    feed_cost DECIMAL;
+    total_revenue DECIMAL;
 BEGIN
     -- Расчет общего количества собранного меда
     SELECT COALESCE(SUM(quantity::DECIMAL), 0)
     INTO total_honey
     FROM honey_harvest hh
-    JOIN hive h ON hh.hive_id = h.hive_id
-    WHERE h.apiary_id = p_apiary_id
+    WHERE hive_apiary_on(hh.hive_id, hh.harvest_date) = p_apiary_id
     AND hh.harvest_date BETWEEN p_start_date AND p_end_date;
 
-    -- Здесь должен быть расчет общих расходов (пример)
-    total_expenses := 1000.00;
+    -- Расход корма за период; пока это единственные учитываемые расходы
+    SELECT fu.quantity_kg, fu.cost
+    INTO feed_used, feed_cost
+    FROM apiary_feed_usage(p_apiary_id, p_start_date, p_end_date) fu;
+    total_expenses := feed_cost;
+
+    -- Выручка от продаж меда и опыления за период
+    total_revenue := apiary_revenue(p_apiary_id, p_start_date, p_end_date);
 
     -- Создание отчета
-    INSERT INTO production_report (apiary_id, start_date, end_date, total_honey_produced, total_expenses)
-    VALUES (p_apiary_id, p_start_date, p_end_date, total_honey, total_expenses);
+    INSERT INTO production_report (apiary_id, start_date, end_date, total_honey_produced, total_expenses, feed_used_kg, feed_cost, total_revenue)
+    VALUES (p_apiary_id, p_start_date, p_end_date, total_honey, total_expenses, feed_used, feed_cost, total_revenue);
 END;
 $$ LANGUAGE plpgsql;
+
+-- 11. Функция для выбора наименее загруженного работника группы с доступом к региону пасеки
+-- Доступ к региону дает и доступ к объемлющему региону; если пасека не относится ни к одному
+-- региону, подходит любой участник группы
+CREATE OR REPLACE FUNCTION pick_group_assignee(
+    p_group_id INTEGER,
+    p_apiary_id INTEGER
+) RETURNS INTEGER AS $$
+BEGIN
+    RETURN (
+        SELECT wgm.worker_id
+        FROM worker_group_member wgm
+        WHERE wgm.group_id = p_group_id
+        AND (
+            NOT EXISTS (SELECT 1 FROM region_apiary ra WHERE ra.apiary_id = p_apiary_id)
+            OR EXISTS (
+                SELECT 1
+                FROM region_apiary ra
+                WHERE ra.apiary_id = p_apiary_id
+                AND ra.region_id IN (SELECT user_region_ids(wgm.worker_id))
+            )
+        )
+        ORDER BY (
+            SELECT COUNT(*)
+            FROM maintenance_plan mp
+            WHERE mp.assigned_to = wgm.worker_id
+            AND mp.status IN ('Pending', 'In Progress')
+        ), wgm.worker_id
+        LIMIT 1
+    );
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+-- 12. Процедура для назначения плана обслуживания группе работников
+CREATE OR REPLACE PROCEDURE assign_maintenance_plan_to_group(
+    p_plan_id INTEGER,
+    p_group_id INTEGER
+) AS $$
+BEGIN
+    UPDATE maintenance_plan
+    SET assigned_group_id = p_group_id,
+        assigned_to = pick_group_assignee(p_group_id, apiary_id)
+    WHERE plan_id = p_plan_id;
+END;
+$$ LANGUAGE plpgsql;
+
+-- 13. Процедура для записи структурированного осмотра улья по шаблону
+-- Ответы передаются как JSON-объект вида {"ключ вопроса": значение}
+CREATE OR REPLACE PROCEDURE record_inspection(
+    p_hive_id INTEGER,
+    p_template_id INTEGER,
+    p_inspected_by INTEGER,
+    p_inspection_date DATE,
+    p_notes TEXT,
+    p_answers JSONB,
+    INOUT p_inspection_id INTEGER DEFAULT NULL
+) AS $$
+BEGIN
+    INSERT INTO inspection (hive_id, template_id, inspected_by, inspection_date, notes)
+    VALUES (p_hive_id, p_template_id, p_inspected_by, p_inspection_date, p_notes)
+    RETURNING inspection_id INTO p_inspection_id;
+
+    INSERT INTO inspection_answer (inspection_id, question_id, value_bool, value_number, value_text)
+    SELECT p_inspection_id,
+        q.question_id,
+        CASE WHEN q.answer_type = 'boolean' THEN (a.value)::BOOLEAN END,
+        CASE WHEN q.answer_type = 'number' THEN (a.value)::NUMERIC END,
+        CASE WHEN q.answer_type IN ('choice', 'text') THEN a.value #>> '{}' END
+    FROM jsonb_each(p_answers) AS a(key, value)
+    JOIN inspection_question q ON q.template_id = p_template_id AND q.key = a.key;
+END;
+$$ LANGUAGE plpgsql;
+
+-- 14. Функция для расчета возраста матки в месяцах
+-- Если дата рождения неизвестна, возраст считается от первого подсаживания в семью
+CREATE OR REPLACE FUNCTION queen_age_months(
+    p_queen_id INTEGER
+) RETURNS INTEGER AS $$
+    SELECT (EXTRACT(YEAR FROM age(CURRENT_DATE, d.since)) * 12 + EXTRACT(MONTH FROM age(CURRENT_DATE, d.since)))::INTEGER
+    FROM (
+        SELECT COALESCE(
+            q.birth_date,
+            (SELECT MIN(qi.introduced_date) FROM queen_introduction qi WHERE qi.queen_id = q.queen_id)
+        ) AS since
+        FROM queen q
+        WHERE q.queen_id = p_queen_id
+    ) AS d;
+$$ LANGUAGE sql STABLE;
+
+-- 15. Функция для получения возраста текущей матки пчелиной семьи в месяцах
+CREATE OR REPLACE FUNCTION community_queen_age(
+    p_community_id INTEGER
+) RETURNS INTEGER AS $$
+    SELECT queen_age_months(qi.queen_id)
+    FROM queen_introduction qi
+    WHERE qi.community_id = p_community_id AND qi.removed_date IS NULL;
+$$ LANGUAGE sql STABLE;
+
+-- 16. Функция для определения пасеки, на которой стоял улей в указанную дату
+-- Для дат до первой известной стоянки используется самая ранняя стоянка улья
+CREATE OR REPLACE FUNCTION hive_apiary_on(
+    p_hive_id INTEGER,
+    p_date DATE
+) RETURNS INTEGER AS $$
+    SELECT COALESCE(
+        (
+            SELECT hp.apiary_id
+            FROM hive_placement hp
+            WHERE hp.hive_id = p_hive_id
+            AND hp.placed_date <= p_date
+            AND (hp.removed_date IS NULL OR hp.removed_date > p_date)
+            ORDER BY hp.placed_date DESC
+            LIMIT 1
+        ),
+        (
+            SELECT hp.apiary_id
+            FROM hive_placement hp
+            WHERE hp.hive_id = p_hive_id
+            ORDER BY hp.placed_date, hp.placement_id
+            LIMIT 1
+        ),
+        (SELECT h.apiary_id FROM hive h WHERE h.hive_id = p_hive_id)
+    );
+$$ LANGUAGE sql STABLE;
+
+-- 17. Функция для определения окончания периода ожидания после обработки
+-- Для незавершенной обработки возвращается NULL: период ожидания еще не закончился
+CREATE OR REPLACE FUNCTION treatment_withdrawal_end(
+    p_application_id INTEGER
+) RETURNS DATE AS $$
+    SELECT ta.end_date + tp.withdrawal_days
+    FROM treatment_application ta
+    JOIN treatment_product tp ON tp.product_id = ta.product_id
+    WHERE ta.application_id = p_application_id;
+$$ LANGUAGE sql STABLE;
+
+-- 18. Функция для получения обработок улья, период ожидания которых приходится на указанную дату
+CREATE OR REPLACE FUNCTION hive_withdrawal_applications(
+    p_hive_id INTEGER,
+    p_date DATE
+) RETURNS SETOF INTEGER AS $$
+    SELECT ta.application_id
+    FROM treatment_application ta
+    WHERE ta.hive_id = p_hive_id
+    AND ta.start_date <= p_date
+    AND (ta.end_date IS NULL OR p_date < treatment_withdrawal_end(ta.application_id));
+$$ LANGUAGE sql STABLE;
+
+-- 19. Функция для вычисления расстояния между двумя точками в километрах (формула гаверсинусов)
+CREATE OR REPLACE FUNCTION distance_km(
+    p_lat1 DOUBLE PRECISION,
+    p_lon1 DOUBLE PRECISION,
+    p_lat2 DOUBLE PRECISION,
+    p_lon2 DOUBLE PRECISION
+) RETURNS DOUBLE PRECISION AS $$
+    SELECT 2 * 6371 * asin(sqrt(
+        power(sin(radians(p_lat2 - p_lat1) / 2), 2)
+        + cos(radians(p_lat1)) * cos(radians(p_lat2)) * power(sin(radians(p_lon2 - p_lon1) / 2), 2)
+    ));
+$$ LANGUAGE sql IMMUTABLE;
+
+-- 20. Функция для получения пасек в зоне карантина вспышки
+-- Зона задается регионом вместе с вложенными в него регионами либо радиусом вокруг пасеки;
+-- пасеки без координат попадают в радиус, только если это сама центральная пасека
+CREATE OR REPLACE FUNCTION outbreak_zone_apiaries(
+    p_outbreak_id INTEGER
+) RETURNS SETOF INTEGER AS $$
+    WITH RECURSIVE zone_region AS (
+        SELECT o.region_id
+        FROM outbreak o
+        WHERE o.outbreak_id = p_outbreak_id
+        UNION
+        SELECT r.region_id
+        FROM region r
+        JOIN zone_region z ON r.parent_region_id = z.region_id
+    )
+    SELECT ra.apiary_id
+    FROM region_apiary ra
+    JOIN zone_region z ON z.region_id = ra.region_id
+    UNION
+    SELECT a.apiary_id
+    FROM outbreak o
+    JOIN apiary c ON c.apiary_id = o.apiary_id
+    JOIN apiary a ON a.apiary_id = c.apiary_id
+        OR distance_km(c.latitude, c.longitude, a.latitude, a.longitude) <= COALESCE(o.radius_km, 0)
+    WHERE o.outbreak_id = p_outbreak_id;
+$$ LANGUAGE sql STABLE;
+
+-- 21. Функция для получения действующих вспышек, в зону карантина которых входит пасека
+CREATE OR REPLACE FUNCTION apiary_quarantine_outbreaks(
+    p_apiary_id INTEGER
+) RETURNS SETOF INTEGER AS $$
+    SELECT o.outbreak_id
+    FROM outbreak o
+    WHERE o.lifted_date IS NULL
+    AND p_apiary_id IN (SELECT outbreak_zone_apiaries(o.outbreak_id));
+$$ LANGUAGE sql STABLE;
+
+-- 22. Функция для получения числового значения показания датчика
+-- Весовые датчики передают вес в килограммах десятичной строкой; нечисловые значения дают NULL
+-- Проверка регулярным выражением вместо перехвата исключений: функция встраивается в запрос и не открывает подтранзакцию на каждое показание
+CREATE OR REPLACE FUNCTION sensor_value_numeric(
+    p_value BYTEA
+) RETURNS DOUBLE PRECISION AS $$
+    -- Табуляция и переводы строк в escape-представлении заменяются пробелами; длина числа ограничена, чтобы приведение не переполнялось
+    SELECT CASE
+        WHEN regexp_replace(encode(p_value, 'escape'), '\\0(11|12|15)', ' ', 'g') ~ '^\s*[-+]?([0-9]{1,15}(\.[0-9]{0,15})?|\.[0-9]{1,15})([eE][-+]?[0-9]{1,2})?\s*$'
+        THEN regexp_replace(encode(p_value, 'escape'), '\\0(11|12|15)', ' ', 'g')::DOUBLE PRECISION
+    END;
+$$ LANGUAGE sql IMMUTABLE;
+
+-- 23. Функция для получения последнего показания веса улья
+-- Сначала отбираются весовые датчики улья, затем показания просматриваются от последнего к первому до первого числового
+CREATE OR REPLACE FUNCTION hive_latest_weight(
+    p_hive_id INTEGER
+) RETURNS TABLE (weight_kg DOUBLE PRECISION, measured_at TIMESTAMP) AS $$
+    SELECT r.weight_kg, r.timestamp
+    FROM sensor s
+    CROSS JOIN LATERAL (
+        SELECT sensor_value_numeric(sr.value) AS weight_kg, sr.timestamp
+        FROM sensor_reading sr
+        WHERE sr.sensor_id = s.sensor_id
+        AND sensor_value_numeric(sr.value) IS NOT NULL
+        ORDER BY sr.timestamp DESC
+        LIMIT 1
+    ) r
+    WHERE s.hive_id = p_hive_id
+    AND lower(s.sensor_type) = 'weight'
+    ORDER BY r.timestamp DESC
+    LIMIT 1;
+$$ LANGUAGE sql STABLE;
+
+-- 24. Функция для оценки запасов корма в улье: последний вес за вычетом веса тары и веса семьи (пчелы, расплод, соты)
+-- Возвращает NULL, если у улья нет весового датчика или не указан вес тары
+CREATE OR REPLACE FUNCTION hive_estimated_stores(
+    p_hive_id INTEGER
+) RETURNS DOUBLE PRECISION AS $$
+    SELECT GREATEST(w.weight_kg - h.tare_weight_kg - h.colony_allowance_kg, 0)
+    FROM hive h
+    CROSS JOIN hive_latest_weight(h.hive_id) w
+    WHERE h.hive_id = p_hive_id;
+$$ LANGUAGE sql STABLE;
+
+-- 25. Функция для расчета расхода корма пасеки за период
+-- Подкормка относится к пасеке, на которой стоял улей в день подкормки
+CREATE OR REPLACE FUNCTION apiary_feed_usage(
+    p_apiary_id INTEGER,
+    p_start_date DATE,
+    p_end_date DATE
+) RETURNS TABLE (quantity_kg DOUBLE PRECISION, cost DOUBLE PRECISION) AS $$
+    SELECT COALESCE(SUM(f.quantity_kg), 0)::DOUBLE PRECISION,
+        COALESCE(SUM(f.quantity_kg * f.cost_per_kg), 0)::DOUBLE PRECISION
+    FROM feeding f
+    WHERE hive_apiary_on(f.hive_id, f.feeding_date) = p_apiary_id
+    AND f.feeding_date BETWEEN p_start_date AND p_end_date;
+$$ LANGUAGE sql STABLE;
+
+-- 26. Функция для получения количества меда из сбора, еще не распределенного по партиям
+CREATE OR REPLACE FUNCTION harvest_unallocated(
+    p_harvest_id INTEGER
+) RETURNS DOUBLE PRECISION AS $$
+    SELECT hh.quantity - COALESCE((
+        SELECT SUM(bh.quantity) FROM batch_harvest bh WHERE bh.harvest_id = hh.harvest_id
+    ), 0)
+    FROM honey_harvest hh
+    WHERE hh.harvest_id = p_harvest_id;
+$$ LANGUAGE sql STABLE;
+
+-- 27. Функция для определения сорта меда по результатам анализа: лучший сорт, нормы которого выполнены
+CREATE OR REPLACE FUNCTION lab_grade(
+    p_moisture DOUBLE PRECISION,
+    p_hmf DOUBLE PRECISION,
+    p_diastase DOUBLE PRECISION
+) RETURNS VARCHAR AS $$
+    SELECT CASE
+        WHEN p_moisture IS NULL AND p_hmf IS NULL AND p_diastase IS NULL THEN NULL
+        ELSE COALESCE((
+            SELECT gr.grade FROM grade_rule gr
+            WHERE (gr.max_moisture_percent IS NULL OR p_moisture IS NULL OR p_moisture <= gr.max_moisture_percent)
+            AND (gr.max_hmf_mg_kg IS NULL OR p_hmf IS NULL OR p_hmf <= gr.max_hmf_mg_kg)
+            AND (gr.min_diastase_dn IS NULL OR p_diastase IS NULL OR p_diastase >= gr.min_diastase_dn)
+            ORDER BY gr.grade
+            LIMIT 1
+        ), 'D')
+    END;
+$$ LANGUAGE sql STABLE;
+
+-- 28. Функция для получения последних анализов партии и ее сборов, не соответствующих нормам
+CREATE OR REPLACE FUNCTION batch_blocking_results(
+    p_batch_id INTEGER
+) RETURNS SETOF INTEGER AS $$
+    SELECT latest.result_id FROM (
+        SELECT DISTINCT ON (lr.harvest_id, lr.batch_id) lr.result_id, lr.out_of_spec
+        FROM lab_result lr
+        WHERE lr.batch_id = p_batch_id
+        OR lr.harvest_id IN (SELECT bh.harvest_id FROM batch_harvest bh WHERE bh.batch_id = p_batch_id)
+        ORDER BY lr.harvest_id, lr.batch_id, lr.sample_date DESC, lr.result_id DESC
+    ) latest
+    WHERE latest.out_of_spec;
+$$ LANGUAGE sql STABLE;
+
+-- 29. Функция для получения доли меда пасеки в партии
+CREATE OR REPLACE FUNCTION batch_apiary_share(
+    p_batch_id INTEGER,
+    p_apiary_id INTEGER
+) RETURNS DOUBLE PRECISION AS $$
+    SELECT COALESCE(
+        SUM(bh.quantity) FILTER (WHERE hive_apiary_on(hh.hive_id, hh.harvest_date) = p_apiary_id)
+            / NULLIF(SUM(bh.quantity), 0),
+        0
+    )::DOUBLE PRECISION
+    FROM batch_harvest bh
+    JOIN honey_harvest hh ON hh.harvest_id = bh.harvest_id
+    WHERE bh.batch_id = p_batch_id;
+$$ LANGUAGE sql STABLE;
+
+-- 30. Функция для расчета выручки пасеки за период: продажи без налога пропорционально доле пасеки в партиях
+CREATE OR REPLACE FUNCTION apiary_sales_revenue(
+    p_apiary_id INTEGER,
+    p_start_date DATE,
+    p_end_date DATE
+) RETURNS DOUBLE PRECISION AS $$
+    SELECT COALESCE(SUM(il.net_amount * batch_apiary_share(si.batch_id, p_apiary_id)), 0)::DOUBLE PRECISION
+    FROM invoice_line il
+    JOIN invoice i ON i.invoice_id = il.invoice_id
+    JOIN stock_item si ON si.stock_id = il.stock_id
+    WHERE i.issue_date BETWEEN p_start_date AND p_end_date;
+$$ LANGUAGE sql STABLE;
+
+-- 31. Функция для получения активных вспышек, под карантин которых попадают пасеки, давшие мед партии
+CREATE OR REPLACE FUNCTION batch_quarantine_outbreaks(
+    p_batch_id INTEGER
+) RETURNS SETOF INTEGER AS $$
+    SELECT DISTINCT q.outbreak_id
+    FROM batch_harvest bh
+    JOIN honey_harvest hh ON hh.harvest_id = bh.harvest_id
+    CROSS JOIN LATERAL apiary_quarantine_outbreaks(hive_apiary_on(hh.hive_id, hh.harvest_date)) AS q(outbreak_id)
+    WHERE bh.batch_id = p_batch_id;
+$$ LANGUAGE sql STABLE;
+
+-- 32. Функция для расчета выручки пасеки от опыления за период: цена завершенных договоров пропорционально доле ульев пасеки
+CREATE OR REPLACE FUNCTION apiary_pollination_revenue(
+    p_apiary_id INTEGER,
+    p_start_date DATE,
+    p_end_date DATE
+) RETURNS DOUBLE PRECISION AS $$
+    SELECT COALESCE(SUM(pc.price * (
+        SELECT COUNT(*) FILTER (WHERE ch.home_apiary_id = p_apiary_id)::DOUBLE PRECISION / COUNT(*)
+        FROM contract_hive ch
+        WHERE ch.contract_id = pc.contract_id
+    )), 0)::DOUBLE PRECISION
+    FROM pollination_contract pc
+    WHERE pc.status = 'completed'
+    AND pc.end_date BETWEEN p_start_date AND p_end_date
+    AND EXISTS (SELECT 1 FROM contract_hive ch WHERE ch.contract_id = pc.contract_id AND ch.home_apiary_id = p_apiary_id);
+$$ LANGUAGE sql STABLE;
+
+-- 33. Функция для расчета всей выручки пасеки за период: продажи меда и опыление
+CREATE OR REPLACE FUNCTION apiary_revenue(
+    p_apiary_id INTEGER,
+    p_start_date DATE,
+    p_end_date DATE
+) RETURNS DOUBLE PRECISION AS $$
+    SELECT apiary_sales_revenue(p_apiary_id, p_start_date, p_end_date)
+        + apiary_pollination_revenue(p_apiary_id, p_start_date, p_end_date);
+$$ LANGUAGE sql STABLE;
+
+-- 34. Функция для привязки пасеки к регионам по их границам
+-- Пасека привязывается к каждому региону с границей, в которую попадает ее точка, и отвязывается
+-- от остальных регионов с границей; привязки к регионам без границы не меняются
+CREATE OR REPLACE FUNCTION sync_apiary_regions(
+    p_apiary_id INTEGER
+) RETURNS VOID AS $$
+    DELETE FROM region_apiary ra
+    USING region r, apiary a
+    WHERE ra.apiary_id = p_apiary_id
+    AND r.region_id = ra.region_id
+    AND a.apiary_id = ra.apiary_id
+    AND r.boundary IS NOT NULL
+    AND (a.latitude IS NULL OR NOT r.boundary @> point(a.longitude, a.latitude));
+
+    INSERT INTO region_apiary (apiary_id, region_id)
+    SELECT a.apiary_id, r.region_id
+    FROM apiary a
+    JOIN region r ON r.boundary @> point(a.longitude, a.latitude)
+    WHERE a.apiary_id = p_apiary_id
+    AND a.latitude IS NOT NULL
+    AND NOT EXISTS (
+        SELECT 1 FROM region_apiary ra
+        WHERE ra.apiary_id = a.apiary_id AND ra.region_id = r.region_id
+    );
+$$ LANGUAGE sql;
+
+-- 35. Функция для привязки к региону всех пасек внутри его границы
+-- Пасеки вне границы отвязываются; у региона без границы привязки не меняются
+CREATE OR REPLACE FUNCTION sync_region_apiaries(
+    p_region_id INTEGER
+) RETURNS VOID AS $$
+    DELETE FROM region_apiary ra
+    USING region r, apiary a
+    WHERE ra.region_id = p_region_id
+    AND r.region_id = ra.region_id
+    AND a.apiary_id = ra.apiary_id
+    AND r.boundary IS NOT NULL
+    AND (a.latitude IS NULL OR NOT r.boundary @> point(a.longitude, a.latitude));
+
+    INSERT INTO region_apiary (apiary_id, region_id)
+    SELECT a.apiary_id, r.region_id
+    FROM region r
+    JOIN apiary a ON a.latitude IS NOT NULL AND r.boundary @> point(a.longitude, a.latitude)
+    WHERE r.region_id = p_region_id
+    AND NOT EXISTS (
+        SELECT 1 FROM region_apiary ra
+        WHERE ra.apiary_id = a.apiary_id AND ra.region_id = r.region_id
+    );
+$$ LANGUAGE sql;
+
+-- 36. Функция для получения пасек вне зоны карантина вспышки в пределах расстояния от зоны
+-- Для каждой пасеки возвращает ближайшую пасеку зоны и расстояние до нее; пасеки без координат не учитываются
+CREATE OR REPLACE FUNCTION outbreak_neighbour_apiaries(
+    p_outbreak_id INTEGER,
+    p_range_km DOUBLE PRECISION
+) RETURNS TABLE (apiary_id INTEGER, nearest_zone_apiary_id INTEGER, distance_km DOUBLE PRECISION) AS $$
+    SELECT DISTINCT ON (a.apiary_id)
+        a.apiary_id,
+        z.apiary_id,
+        distance_km(z.latitude, z.longitude, a.latitude, a.longitude)
+    FROM apiary z
+    JOIN apiary a ON a.latitude IS NOT NULL
+        AND a.apiary_id NOT IN (SELECT outbreak_zone_apiaries(p_outbreak_id))
+        AND distance_km(z.latitude, z.longitude, a.latitude, a.longitude) <= p_range_km
+    WHERE z.apiary_id IN (SELECT outbreak_zone_apiaries(p_outbreak_id))
+    AND z.latitude IS NOT NULL
+    ORDER BY a.apiary_id, distance_km(z.latitude, z.longitude, a.latitude, a.longitude);
+$$ LANGUAGE sql STABLE;
+
+-- 37. Функция для получения региона и всех вложенных в него регионов
+CREATE OR REPLACE FUNCTION region_descendants(
+    p_region_id INTEGER
+) RETURNS SETOF INTEGER AS $$
+    WITH RECURSIVE subtree AS (
+        SELECT region_id FROM region WHERE region_id = p_region_id
+        UNION
+        SELECT r.region_id
+        FROM region r
+        JOIN subtree s ON r.parent_region_id = s.region_id
+    )
+    SELECT region_id FROM subtree;
+$$ LANGUAGE sql STABLE;
+
+-- 38. Функция для получения регионов, доступных пользователю: выданных ему и вложенных в них
+CREATE OR REPLACE FUNCTION user_region_ids(
+    p_user_id INTEGER
+) RETURNS SETOF INTEGER AS $$
+    SELECT DISTINCT region_descendants(ar.region_id)
+    FROM allowed_region ar
+    WHERE ar.user_id = p_user_id;
+$$ LANGUAGE sql STABLE;
+
+-- 39. Функция для получения пасек региона вместе с пасеками вложенных регионов
+CREATE OR REPLACE FUNCTION region_apiaries(
+    p_region_id INTEGER
+) RETURNS SETOF INTEGER AS $$
+    SELECT DISTINCT ra.apiary_id
+    FROM region_apiary ra
+    WHERE ra.region_id IN (SELECT region_descendants(p_region_id));
+$$ LANGUAGE sql STABLE;
+
+-- 40. Функция для получения глубины региона в иерархии; у регионов верхнего уровня она равна 0
+-- Пройденные регионы запоминаются, чтобы обход завершался даже при цикле в иерархии
+CREATE OR REPLACE FUNCTION region_depth(
+    p_region_id INTEGER
+) RETURNS INTEGER AS $$
+    WITH RECURSIVE ancestors AS (
+        SELECT region_id, parent_region_id, 0 AS depth, ARRAY[region_id] AS path FROM region WHERE region_id = p_region_id
+        UNION ALL
+        SELECT r.region_id, r.parent_region_id, a.depth + 1, a.path || r.region_id
+        FROM region r
+        JOIN ancestors a ON r.region_id = a.parent_region_id
+        WHERE r.region_id <> ALL(a.path)
+    )
+    SELECT MAX(depth) FROM ancestors;
+$$ LANGUAGE sql STABLE;
+
+-- 41. Функция для получения суточной погоды региона за период
+-- Суточное наблюдение берется как есть; за дни только с почасовыми наблюдениями температура, влажность
+-- и ветер усредняются, а осадки суммируются
+CREATE OR REPLACE FUNCTION region_daily_weather(
+    p_region_id INTEGER,
+    p_start_date DATE,
+    p_end_date DATE
+) RETURNS TABLE (
+    date DATE,
+    temperature DOUBLE PRECISION,
+    humidity DOUBLE PRECISION,
+    wind_speed DOUBLE PRECISION,
+    precipitation DOUBLE PRECISION,
+    hourly_readings INTEGER
+) AS $$
+    SELECT w.date,
+        COALESCE(MAX(w.temperature) FILTER (WHERE w.hour IS NULL), AVG(w.temperature)),
+        COALESCE(MAX(w.humidity) FILTER (WHERE w.hour IS NULL), AVG(w.humidity)),
+        COALESCE(MAX(w.wind_speed) FILTER (WHERE w.hour IS NULL), AVG(w.wind_speed)),
+        COALESCE(MAX(w.precipitation) FILTER (WHERE w.hour IS NULL), SUM(w.precipitation)),
+        (COUNT(*) FILTER (WHERE w.hour IS NOT NULL))::INTEGER
+    FROM weather_data w
+    WHERE w.region_id = p_region_id
+    AND w.date BETWEEN p_start_date AND p_end_date
+    GROUP BY w.date
+    ORDER BY w.date;
+$$ LANGUAGE sql STABLE;
+
+-- 42. Функция для получения суточной погоды и сбора меда региона за период
+-- Возвращается каждый день периода; погода берется из наблюдений самого региона и равна NULL
+-- в дни без наблюдений, а сбор меда учитывается по пасекам региона и вложенных регионов
+CREATE OR REPLACE FUNCTION region_weather_production(
+    p_region_id INTEGER,
+    p_start_date DATE,
+    p_end_date DATE
+) RETURNS TABLE (
+    date DATE,
+    temperature DOUBLE PRECISION,
+    wind_speed DOUBLE PRECISION,
+    precipitation DOUBLE PRECISION,
+    honey_harvested DOUBLE PRECISION,
+    harvest_count INTEGER
+) AS $$
+    WITH harvests AS (
+        SELECT hh.harvest_date, SUM(hh.quantity) AS quantity, COUNT(*) AS harvests
+        FROM honey_harvest hh
+        WHERE hh.harvest_date BETWEEN p_start_date AND p_end_date
+        AND hive_apiary_on(hh.hive_id, hh.harvest_date) IN (SELECT region_apiaries(p_region_id))
+        GROUP BY hh.harvest_date
+    )
+    SELECT d.day::DATE, w.temperature, w.wind_speed, w.precipitation,
+        COALESCE(h.quantity, 0), COALESCE(h.harvests, 0)::INTEGER
+    FROM generate_series(p_start_date, p_end_date, INTERVAL '1 day') AS d(day)
+    LEFT JOIN region_daily_weather(p_region_id, p_start_date, p_end_date) w ON w.date = d.day::DATE
+    LEFT JOIN harvests h ON h.harvest_date = d.day::DATE
+    ORDER BY d.day;
+$$ LANGUAGE sql STABLE;
+
+-- 43. Функция для получения показаний весовых датчиков ульев за период
+-- Показания, которые не удалось прочитать как число, пропускаются
+CREATE OR REPLACE FUNCTION hive_weight_readings(
+    p_start TIMESTAMP,
+    p_end TIMESTAMP
+) RETURNS TABLE (hive_id INTEGER, measured_at TIMESTAMP, weight_kg DOUBLE PRECISION) AS $$
+    SELECT s.hive_id, sr.timestamp, sensor_value_numeric(sr.value)
+    FROM sensor s
+    JOIN sensor_reading sr ON sr.sensor_id = s.sensor_id
+    WHERE lower(s.sensor_type) = 'weight'
+    AND sr.timestamp >= p_start
+    AND sr.timestamp < p_end
+    AND sensor_value_numeric(sr.value) IS NOT NULL
+    ORDER BY s.hive_id, sr.timestamp;
+$$ LANGUAGE sql STABLE;
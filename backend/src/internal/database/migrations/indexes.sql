@@ -33,3 +33,121 @@ CREATE INDEX IF NOT EXISTS idx_allowed_region_region ON "allowed_region"(region_
 CREATE INDEX IF NOT EXISTS idx_region_apiary_apiary ON "region_apiary"(apiary_id);
 
 CREATE INDEX IF NOT EXISTS idx_region_apiary_region ON "region_apiary"(region_id);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_token_user ON "password_reset_token"(user_id);
+
+CREATE INDEX IF NOT EXISTS idx_email_change_request_user ON "email_change_request"(user_id);
+
+CREATE INDEX IF NOT EXISTS idx_maintenance_plan_assigned_to ON "maintenance_plan"(assigned_to);
+
+CREATE INDEX IF NOT EXISTS idx_incident_status ON "incident"(status);
+
+CREATE INDEX IF NOT EXISTS idx_incident_assigned_user ON "incident"(assigned_user_id);
+
+CREATE INDEX IF NOT EXISTS idx_incident_assigned_group ON "incident"(assigned_group_id);
+
+CREATE INDEX IF NOT EXISTS idx_incident_activity_incident ON "incident_activity"(incident_id);
+
+CREATE INDEX IF NOT EXISTS idx_notification_user_unread ON "notification"(user_id) WHERE read_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_deferred_notification_due ON "deferred_notification"(deliver_after);
+
+CREATE INDEX IF NOT EXISTS idx_incident_escalation ON "incident"(created_at) WHERE status = 'open' AND escalated_at IS NULL;
+
+-- One plan per schedule occurrence (and hive), so generation can be repeated safely
+CREATE UNIQUE INDEX IF NOT EXISTS idx_maintenance_plan_occurrence ON "maintenance_plan"(schedule_id, occurrence_date, (COALESCE(hive_id, 0))) WHERE schedule_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_maintenance_plan_overdue ON "maintenance_plan"(planned_date) WHERE status IN ('Pending', 'In Progress');
+
+CREATE INDEX IF NOT EXISTS idx_maintenance_plan_assigned_group ON "maintenance_plan"(assigned_group_id) WHERE assigned_to IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_maintenance_completion_completed_by ON "maintenance_completion"(completed_by);
+
+CREATE INDEX IF NOT EXISTS idx_inspection_hive_date ON "inspection"(hive_id, inspection_date DESC);
+
+CREATE INDEX IF NOT EXISTS idx_inspection_answer_question ON "inspection_answer"(question_id);
+
+CREATE INDEX IF NOT EXISTS idx_inspection_question_key ON "inspection_question"(key);
+
+-- A community has at most one current queen, and a queen heads at most one community
+CREATE UNIQUE INDEX IF NOT EXISTS idx_queen_introduction_current_community ON "queen_introduction"(community_id) WHERE removed_date IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_queen_introduction_current_queen ON "queen_introduction"(queen_id) WHERE removed_date IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_queen_mother ON "queen"(mother_id);
+
+CREATE INDEX IF NOT EXISTS idx_queen_line ON "queen"(breed, line);
+
+-- A hive stands at one apiary at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_hive_placement_current ON "hive_placement"(hive_id) WHERE removed_date IS NULL;
+
+-- A hive holds one live community at a time; dissolved communities keep their hive_id
+CREATE UNIQUE INDEX IF NOT EXISTS idx_bee_community_live_hive ON "bee_community"(hive_id) WHERE dissolved_date IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_hive_placement_hive_date ON "hive_placement"(hive_id, placed_date);
+
+CREATE INDEX IF NOT EXISTS idx_colony_event_source_hive ON "colony_event"(source_hive_id, event_date DESC);
+
+CREATE INDEX IF NOT EXISTS idx_colony_event_target_hive ON "colony_event"(target_hive_id, event_date DESC);
+
+CREATE INDEX IF NOT EXISTS idx_treatment_application_hive ON "treatment_application"(hive_id, start_date DESC);
+
+CREATE INDEX IF NOT EXISTS idx_treatment_application_product ON "treatment_application"(product_id);
+
+CREATE INDEX IF NOT EXISTS idx_veterinary_record_disease ON "veterinary_record"(disease_id);
+
+CREATE INDEX IF NOT EXISTS idx_outbreak_active ON "outbreak"(disease_id) WHERE lifted_date IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_feeding_hive ON "feeding"(hive_id, feeding_date DESC);
+
+CREATE INDEX IF NOT EXISTS idx_feeding_date ON "feeding"(feeding_date);
+
+CREATE INDEX IF NOT EXISTS idx_batch_harvest_harvest ON "batch_harvest"(harvest_id);
+
+CREATE INDEX IF NOT EXISTS idx_batch_step_batch ON "batch_step"(batch_id, step_date);
+
+CREATE INDEX IF NOT EXISTS idx_jar_lot_batch ON "jar_lot"(batch_id);
+
+CREATE INDEX IF NOT EXISTS idx_lab_result_harvest ON "lab_result"(harvest_id, sample_date);
+
+CREATE INDEX IF NOT EXISTS idx_lab_result_batch ON "lab_result"(batch_id, sample_date);
+
+CREATE INDEX IF NOT EXISTS idx_lab_result_out_of_spec ON "lab_result"(sample_date) WHERE out_of_spec;
+
+CREATE INDEX IF NOT EXISTS idx_stock_item_batch ON "stock_item"(batch_id);
+
+CREATE INDEX IF NOT EXISTS idx_stock_item_jar_lot ON "stock_item"(jar_lot_id);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movement_stock ON "stock_movement"(stock_id, moved_at);
+
+CREATE INDEX IF NOT EXISTS idx_sales_order_customer ON "sales_order"(customer_id, order_date);
+
+CREATE INDEX IF NOT EXISTS idx_order_line_order ON "order_line"(order_id);
+
+CREATE INDEX IF NOT EXISTS idx_invoice_issue_date ON "invoice"(issue_date);
+
+CREATE INDEX IF NOT EXISTS idx_invoice_line_invoice ON "invoice_line"(invoice_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_equipment_stock_apiary ON "equipment_stock"(type_id, apiary_id) WHERE apiary_id IS NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_equipment_stock_warehouse ON "equipment_stock"(type_id, warehouse_id) WHERE warehouse_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_hive_component_type ON "hive_component"(type_id);
+
+CREATE INDEX IF NOT EXISTS idx_equipment_checkout_open ON "equipment_checkout"(user_id) WHERE checked_in_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_pollination_contract_period ON "pollination_contract"(start_date, end_date) WHERE status <> 'cancelled';
+
+CREATE INDEX IF NOT EXISTS idx_contract_hive_hive ON "contract_hive"(hive_id);
+
+CREATE INDEX IF NOT EXISTS idx_region_boundary ON "region" USING gist (boundary);
+
+CREATE INDEX IF NOT EXISTS idx_region_parent ON "region"(parent_region_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_weather_data_reading ON "weather_data"(region_id, date, COALESCE(hour, -1));
+
+CREATE INDEX IF NOT EXISTS idx_sensor_reading_timestamp ON "sensor_reading"(timestamp);
+
+-- Latest readings of a sensor, read backwards for the current hive weight
+CREATE INDEX IF NOT EXISTS idx_sensor_reading_sensor_time ON "sensor_reading"(sensor_id, timestamp DESC);
+
+CREATE INDEX IF NOT EXISTS idx_hive_weight_event_apiary ON "hive_weight_event"(apiary_id, date);
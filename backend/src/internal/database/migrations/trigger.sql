@@ -51,6 +51,7 @@ SELECT create_trigger_if_not_exists(
 );
 
 -- Create a function to grant access to all regions for admin users
+-- and to refuse demoting the last remaining admin
 CREATE OR REPLACE FUNCTION grant_admin_access()
 RETURNS TRIGGER AS $$
 BEGIN
@@ -59,6 +60,12 @@ BEGIN
         SELECT NEW.user_id, r.region_id
         FROM "region" r
         ON CONFLICT ("user_id", "region_id") DO NOTHING;
+    ELSIF TG_OP = 'UPDATE' AND OLD.role = 'ADMIN' THEN
+        -- Lock the remaining admins so concurrent demotions cannot both pass
+        PERFORM 1 FROM "user" WHERE role = 'ADMIN' FOR UPDATE;
+        IF NOT EXISTS (SELECT 1 FROM "user" WHERE role = 'ADMIN' AND user_id <> NEW.user_id) THEN
+            RAISE EXCEPTION 'The last admin cannot be demoted' USING ERRCODE = 'check_violation';
+        END IF;
     END IF;
     RETURN NEW;
 END;
@@ -72,17 +79,39 @@ SELECT create_trigger_if_not_exists(
     'grant_admin_access'
 );
 
+-- Refuse deleting the last remaining admin
+CREATE OR REPLACE FUNCTION prevent_last_admin_delete()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.role = 'ADMIN' THEN
+        PERFORM 1 FROM "user" WHERE role = 'ADMIN' FOR UPDATE;
+        IF NOT EXISTS (SELECT 1 FROM "user" WHERE role = 'ADMIN' AND user_id <> OLD.user_id) THEN
+            RAISE EXCEPTION 'The last admin cannot be deleted' USING ERRCODE = 'check_violation';
+        END IF;
+    END IF;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT create_trigger_if_not_exists(
+    'last_admin_delete_guard',
+    'user',
+    'BEFORE',
+    'DELETE',
+    'prevent_last_admin_delete'
+);
+
 CREATE OR REPLACE FUNCTION update_population_estimate()
 RETURNS TRIGGER AS $$
 BEGIN
     IF TG_OP = 'INSERT' THEN
         UPDATE "bee_community"
         SET population_estimate = population_estimate + NEW.quantity
-        WHERE community_id = (SELECT community_id FROM bee_community WHERE hive_id = NEW.hive_id);
+        WHERE hive_id = NEW.hive_id AND dissolved_date IS NULL;
     ELSIF TG_OP = 'DELETE' THEN
         UPDATE "bee_community"
         SET population_estimate = population_estimate - OLD.quantity
-        WHERE community_id = (SELECT community_id FROM bee_community WHERE hive_id = OLD.hive_id);
+        WHERE hive_id = OLD.hive_id AND dissolved_date IS NULL;
     END IF;
     RETURN NULL;
 END;
@@ -115,12 +144,13 @@ SELECT create_trigger_if_not_exists(
 );
 
 -- Trigger to update production report when new honey harvest is added
+-- The harvest is credited to the apiary the hive stood at on the harvest date
 CREATE OR REPLACE FUNCTION update_production_report()
 RETURNS TRIGGER AS $$
 BEGIN
     INSERT INTO "production_report" (apiary_id, start_date, end_date, total_honey_produced)
     VALUES (
-        (SELECT apiary_id FROM "hive" WHERE hive_id = NEW.hive_id),
+        hive_apiary_on(NEW.hive_id, NEW.harvest_date),
         DATE_TRUNC('month', NEW.harvest_date),
         DATE_TRUNC('month', NEW.harvest_date) + INTERVAL '1 month' - INTERVAL '1 day',
         NEW.quantity
@@ -138,3 +168,293 @@ SELECT create_trigger_if_not_exists(
     'INSERT',
     'update_production_report'
 );
+
+-- Keep the placement history of hives in step with hive.apiary_id
+-- Relocations record their own dated placement first, which this trigger then leaves alone
+CREATE OR REPLACE FUNCTION track_hive_placement()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.apiary_id IS NOT DISTINCT FROM OLD.apiary_id THEN
+        RETURN NEW;
+    END IF;
+    IF EXISTS (
+        SELECT 1 FROM "hive_placement"
+        WHERE hive_id = NEW.hive_id AND removed_date IS NULL AND apiary_id = NEW.apiary_id
+    ) THEN
+        RETURN NEW;
+    END IF;
+
+    UPDATE "hive_placement" SET removed_date = GREATEST(CURRENT_DATE, placed_date)
+    WHERE hive_id = NEW.hive_id AND removed_date IS NULL;
+
+    IF NEW.apiary_id IS NOT NULL THEN
+        INSERT INTO "hive_placement" (hive_id, apiary_id, placed_date)
+        VALUES (
+            NEW.hive_id,
+            NEW.apiary_id,
+            CASE WHEN TG_OP = 'INSERT' THEN COALESCE(NEW.installation_date, CURRENT_DATE) ELSE CURRENT_DATE END
+        );
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT create_trigger_if_not_exists(
+    'hive_placement_trigger',
+    'hive',
+    'AFTER',
+    'INSERT OR UPDATE',
+    'track_hive_placement'
+);
+
+-- Refuse harvests from hives inside a treatment withdrawal period unless overridden with a reason
+CREATE OR REPLACE FUNCTION check_harvest_withdrawal()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.hive_id = OLD.hive_id AND NEW.harvest_date = OLD.harvest_date THEN
+        RETURN NEW;
+    END IF;
+    IF NEW.withdrawal_override_reason IS NULL
+        AND EXISTS (SELECT 1 FROM hive_withdrawal_applications(NEW.hive_id, NEW.harvest_date)) THEN
+        RAISE EXCEPTION 'Hive % is inside a treatment withdrawal period on %', NEW.hive_id, NEW.harvest_date
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT create_trigger_if_not_exists(
+    'harvest_withdrawal_trigger',
+    'honey_harvest',
+    'BEFORE',
+    'INSERT OR UPDATE',
+    'check_harvest_withdrawal'
+);
+
+-- Refuse moving hives into or out of apiaries under quarantine
+CREATE OR REPLACE FUNCTION check_hive_quarantine()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.apiary_id IS DISTINCT FROM OLD.apiary_id
+        AND (EXISTS (SELECT 1 FROM apiary_quarantine_outbreaks(OLD.apiary_id))
+            OR EXISTS (SELECT 1 FROM apiary_quarantine_outbreaks(NEW.apiary_id))) THEN
+        RAISE EXCEPTION 'Hive % cannot be moved between apiaries % and % under quarantine', NEW.hive_id, OLD.apiary_id, NEW.apiary_id
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT create_trigger_if_not_exists(
+    'hive_quarantine_trigger',
+    'hive',
+    'BEFORE',
+    'UPDATE',
+    'check_hive_quarantine'
+);
+
+-- Keep lot numbers unique across honey batches and jar lots so a lot number traces to one thing
+CREATE OR REPLACE FUNCTION check_lot_number()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF (TG_TABLE_NAME = 'honey_batch' AND EXISTS (SELECT 1 FROM jar_lot WHERE lot_number = NEW.lot_number))
+        OR (TG_TABLE_NAME = 'jar_lot' AND EXISTS (SELECT 1 FROM honey_batch WHERE lot_number = NEW.lot_number)) THEN
+        RAISE EXCEPTION 'Lot number % is already used', NEW.lot_number
+            USING ERRCODE = 'unique_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT create_trigger_if_not_exists(
+    'honey_batch_lot_number_trigger',
+    'honey_batch',
+    'BEFORE',
+    'INSERT OR UPDATE',
+    'check_lot_number'
+);
+
+SELECT create_trigger_if_not_exists(
+    'jar_lot_lot_number_trigger',
+    'jar_lot',
+    'BEFORE',
+    'INSERT OR UPDATE',
+    'check_lot_number'
+);
+
+-- Derive the grade of a lab result from its measurements; residues or missing every grade put it out of spec
+CREATE OR REPLACE FUNCTION grade_lab_result()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.grade := lab_grade(NEW.moisture_percent, NEW.hmf_mg_kg, NEW.diastase_dn);
+    IF COALESCE(NEW.residue_detected, FALSE) THEN
+        NEW.grade := 'D';
+    END IF;
+    NEW.out_of_spec := COALESCE(NEW.grade = 'D', FALSE);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT create_trigger_if_not_exists(
+    'lab_result_grade_trigger',
+    'lab_result',
+    'BEFORE',
+    'INSERT OR UPDATE',
+    'grade_lab_result'
+);
+
+-- Grade a harvest by its latest lab result
+CREATE OR REPLACE FUNCTION apply_lab_grade()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.harvest_id IS NOT NULL AND NEW.grade IS NOT NULL
+        AND NOT EXISTS (
+            SELECT 1 FROM lab_result lr
+            WHERE lr.harvest_id = NEW.harvest_id AND lr.result_id <> NEW.result_id
+            AND (lr.sample_date, lr.result_id) > (NEW.sample_date, NEW.result_id)
+        ) THEN
+        UPDATE honey_harvest SET quality_grade = NEW.grade WHERE harvest_id = NEW.harvest_id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT create_trigger_if_not_exists(
+    'lab_result_apply_grade_trigger',
+    'lab_result',
+    'AFTER',
+    'INSERT OR UPDATE',
+    'apply_lab_grade'
+);
+
+-- Add invoiced revenue to the monthly production report of every apiary whose honey was sold
+CREATE OR REPLACE FUNCTION add_invoice_revenue()
+RETURNS TRIGGER AS $$
+DECLARE
+    v_issue_date DATE;
+BEGIN
+    SELECT issue_date INTO v_issue_date FROM invoice WHERE invoice_id = NEW.invoice_id;
+
+    INSERT INTO "production_report" (apiary_id, start_date, end_date, total_honey_produced, total_revenue)
+    SELECT share.apiary_id,
+        DATE_TRUNC('month', v_issue_date),
+        DATE_TRUNC('month', v_issue_date) + INTERVAL '1 month' - INTERVAL '1 day',
+        0,
+        NEW.net_amount * share.quantity / share.total
+    FROM (
+        SELECT hive_apiary_on(hh.hive_id, hh.harvest_date) AS apiary_id,
+            SUM(bh.quantity) AS quantity,
+            SUM(SUM(bh.quantity)) OVER () AS total
+        FROM stock_item si
+        JOIN batch_harvest bh ON bh.batch_id = si.batch_id
+        JOIN honey_harvest hh ON hh.harvest_id = bh.harvest_id
+        WHERE si.stock_id = NEW.stock_id
+        GROUP BY 1
+    ) share
+    WHERE share.apiary_id IS NOT NULL AND share.total > 0
+    ON CONFLICT (apiary_id, start_date, end_date)
+    DO UPDATE SET total_revenue = "production_report".total_revenue + EXCLUDED.total_revenue;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT create_trigger_if_not_exists(
+    'invoice_revenue_trigger',
+    'invoice_line',
+    'AFTER',
+    'INSERT',
+    'add_invoice_revenue'
+);
+
+-- Add the price of a completed pollination contract to the monthly production report of every apiary whose hives served it
+CREATE OR REPLACE FUNCTION add_pollination_revenue()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.status <> 'completed' OR OLD.status = 'completed' THEN
+        RETURN NEW;
+    END IF;
+
+    INSERT INTO "production_report" (apiary_id, start_date, end_date, total_honey_produced, total_revenue)
+    SELECT share.apiary_id,
+        DATE_TRUNC('month', NEW.end_date),
+        DATE_TRUNC('month', NEW.end_date) + INTERVAL '1 month' - INTERVAL '1 day',
+        0,
+        NEW.price * share.hives / share.total
+    FROM (
+        SELECT ch.home_apiary_id AS apiary_id,
+            COUNT(*)::DOUBLE PRECISION AS hives,
+            SUM(COUNT(*)) OVER () AS total
+        FROM contract_hive ch
+        WHERE ch.contract_id = NEW.contract_id
+        GROUP BY ch.home_apiary_id
+    ) share
+    ON CONFLICT (apiary_id, start_date, end_date)
+    DO UPDATE SET total_revenue = "production_report".total_revenue + EXCLUDED.total_revenue;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT create_trigger_if_not_exists(
+    'pollination_revenue_trigger',
+    'pollination_contract',
+    'AFTER',
+    'UPDATE',
+    'add_pollination_revenue'
+);
+
+-- Assign an apiary to the regions whose boundary contains it whenever its coordinates change
+CREATE OR REPLACE FUNCTION sync_apiary_regions_on_move()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM sync_apiary_regions(NEW.apiary_id);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT create_trigger_if_not_exists(
+    'apiary_region_trigger',
+    'apiary',
+    'AFTER',
+    'INSERT OR UPDATE OF latitude, longitude',
+    'sync_apiary_regions_on_move'
+);
+
+-- Reassign the apiaries of a region whenever its boundary is set or changed
+CREATE OR REPLACE FUNCTION sync_region_apiaries_on_boundary()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.boundary IS NULL THEN
+        RETURN NEW;
+    END IF;
+    PERFORM sync_region_apiaries(NEW.region_id);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT create_trigger_if_not_exists(
+    'region_boundary_trigger',
+    'region',
+    'AFTER',
+    'INSERT OR UPDATE OF boundary',
+    'sync_region_apiaries_on_boundary'
+);
+
+-- Refuse nesting a region inside itself or one of its descendants
+CREATE OR REPLACE FUNCTION check_region_parent()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.parent_region_id IS NOT NULL
+        AND NEW.parent_region_id IN (SELECT region_descendants(NEW.region_id)) THEN
+        RAISE EXCEPTION 'Region % cannot be nested inside its own descendant %', NEW.region_id, NEW.parent_region_id;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT create_trigger_if_not_exists(
+    'region_parent_trigger',
+    'region',
+    'BEFORE',
+    'UPDATE OF parent_region_id',
+    'check_region_parent'
+);
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+// migrationBlock returns the DO block of a migration file that contains stmt
+func migrationBlock(t *testing.T, filename, stmt string) string {
+	t.Helper()
+	content, err := migrations.ReadFile("migrations/" + filename)
+	if err != nil {
+		t.Fatalf("reading %s: %v", filename, err)
+	}
+	sql := string(content)
+	at := strings.Index(sql, stmt)
+	if at < 0 {
+		t.Fatalf("%s does not contain %q", filename, stmt)
+	}
+	start := strings.LastIndex(sql[:at], "DO $$")
+	end := strings.Index(sql[at:], "END $$;")
+	if start < 0 || end < 0 || strings.Contains(sql[start:at], "END $$;") {
+		t.Fatalf("%q is not inside a DO block of %s", stmt, filename)
+	}
+	return sql[start : at+end]
+}
+
+func TestLegacyIncidentsAreNotEscalated(t *testing.T) {
+	// Open incidents recorded before escalation existed must not be escalated on the first tick
+	block := migrationBlock(t, "definition.sql", `ALTER TABLE "incident" ADD COLUMN "escalated_at"`)
+	if !strings.Contains(block, `UPDATE "incident" SET "escalated_at"`) {
+		t.Errorf("adding incident.escalated_at does not mark existing incidents escalated:\n%s", block)
+	}
+	if !strings.Contains(block, "IF NOT EXISTS") {
+		t.Errorf("existing incidents are marked escalated on every migration, not only when the column is added:\n%s", block)
+	}
+}
@@ -0,0 +1,280 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// recipientColumns selects a user with their notification preferences, falling back to the defaults
+const recipientColumns = `
+	u.user_id, u.email, u.full_name,
+	COALESCE(np.email_enabled, TRUE) AS email_enabled,
+	COALESCE(np.inbox_enabled, TRUE) AS inbox_enabled,
+	np.webhook_url,
+	COALESCE(np.min_severity, 'low') AS min_severity,
+	np.quiet_hours_start,
+	np.quiet_hours_end,
+	COALESCE(np.timezone, 'UTC') AS timezone`
+
+func (db *DB) GetNotificationRule(id int) (types.NotificationRule, error) {
+	var rule types.NotificationRule
+	err := db.Get(&rule, "SELECT * FROM notification_rule WHERE rule_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting notification rule: ", err)
+		return types.NotificationRule{}, fmt.Errorf("error getting notification rule: %w", err)
+	}
+	return rule, nil
+}
+
+func (db *DB) CreateNotificationRule(rule types.NotificationRule) (types.NotificationRule, error) {
+	var createdRule types.NotificationRule
+	err := db.Get(&createdRule, `
+		INSERT INTO notification_rule (name, apiary_id, region_id, min_severity, user_id, group_id, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING *`,
+		rule.Name, rule.ApiaryID, rule.RegionID, rule.MinSeverity, rule.UserID, rule.GroupID, rule.Enabled)
+	if err != nil {
+		zap.S().Error("Error creating notification rule: ", err)
+		return types.NotificationRule{}, fmt.Errorf("error creating notification rule: %w", err)
+	}
+	return createdRule, nil
+}
+
+func (db *DB) UpdateNotificationRule(rule types.NotificationRule) (types.NotificationRule, error) {
+	var updatedRule types.NotificationRule
+	err := db.Get(&updatedRule, `
+		UPDATE notification_rule
+		SET name = $1,
+			apiary_id = $2,
+			region_id = $3,
+			min_severity = $4,
+			user_id = $5,
+			group_id = $6,
+			enabled = $7
+		WHERE rule_id = $8
+		RETURNING *`,
+		rule.Name, rule.ApiaryID, rule.RegionID, rule.MinSeverity, rule.UserID, rule.GroupID, rule.Enabled, rule.RuleID)
+	if err != nil {
+		zap.S().Error("Error updating notification rule: ", err)
+		return types.NotificationRule{}, fmt.Errorf("error updating notification rule: %w", err)
+	}
+	return updatedRule, nil
+}
+
+func (db *DB) DeleteNotificationRule(id int) error {
+	_, err := db.Exec("DELETE FROM notification_rule WHERE rule_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error deleting notification rule: ", err)
+		return fmt.Errorf("error deleting notification rule: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetAllNotificationRules() ([]types.NotificationRule, error) {
+	rules := []types.NotificationRule{}
+	err := db.Select(&rules, "SELECT * FROM notification_rule ORDER BY rule_id")
+	if err != nil {
+		zap.S().Error("Error getting all notification rules: ", err)
+		return []types.NotificationRule{}, fmt.Errorf("error getting all notification rules: %w", err)
+	}
+	return rules, nil
+}
+
+// GetIncidentRecipients resolves the enabled rules matching an incident's
+// apiary, regions and severity into the distinct users to notify
+func (db *DB) GetIncidentRecipients(incident types.Incident) ([]types.NotificationRecipient, error) {
+	recipients := []types.NotificationRecipient{}
+	err := db.Select(&recipients, `
+		WITH target AS (
+			SELECT h.apiary_id FROM hive h WHERE h.hive_id = $1
+		), matched AS (
+			SELECT nr.* FROM notification_rule nr, target t
+			WHERE nr.enabled
+				AND (nr.apiary_id IS NULL OR nr.apiary_id = t.apiary_id)
+				AND (nr.region_id IS NULL OR EXISTS (
+					SELECT 1 FROM region_apiary ra
+					WHERE ra.apiary_id = t.apiary_id AND ra.region_id = nr.region_id
+				))
+				AND nr.min_severity = ANY($2)
+		)
+		SELECT `+recipientColumns+`
+		FROM "user" u
+		LEFT JOIN notification_preference np ON np.user_id = u.user_id
+		WHERE u.user_id IN (
+			SELECT m.user_id FROM matched m WHERE m.user_id IS NOT NULL
+			UNION
+			SELECT wgm.worker_id FROM matched m JOIN worker_group_member wgm ON wgm.group_id = m.group_id
+		)`,
+		incident.HiveID, pq.Array(types.SeveritiesUpTo(incident.Severity)))
+	if err != nil {
+		zap.S().Error("Error getting incident recipients: ", err)
+		return nil, fmt.Errorf("error getting incident recipients: %w", err)
+	}
+	return recipients, nil
+}
+
+// GetNotificationRecipient returns a single user with their notification preferences
+func (db *DB) GetNotificationRecipient(userID int) (types.NotificationRecipient, error) {
+	var recipient types.NotificationRecipient
+	err := db.Get(&recipient, `
+		SELECT `+recipientColumns+`
+		FROM "user" u
+		LEFT JOIN notification_preference np ON np.user_id = u.user_id
+		WHERE u.user_id = $1`,
+		userID)
+	if err != nil {
+		zap.S().Error("Error getting notification recipient: ", err)
+		return types.NotificationRecipient{}, fmt.Errorf("error getting notification recipient: %w", err)
+	}
+	return recipient, nil
+}
+
+// GetNotificationPreference returns a user's preferences, or the defaults if none are stored
+func (db *DB) GetNotificationPreference(userID int) (types.NotificationPreference, error) {
+	recipient, err := db.GetNotificationRecipient(userID)
+	if err != nil {
+		return types.NotificationPreference{}, err
+	}
+	return recipient.NotificationPreference, nil
+}
+
+func (db *DB) UpsertNotificationPreference(pref types.NotificationPreference) (types.NotificationPreference, error) {
+	var savedPref types.NotificationPreference
+	err := db.Get(&savedPref, `
+		INSERT INTO notification_preference (
+			user_id, email_enabled, inbox_enabled, webhook_url, min_severity, quiet_hours_start, quiet_hours_end, timezone
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id) DO UPDATE SET
+			email_enabled = EXCLUDED.email_enabled,
+			inbox_enabled = EXCLUDED.inbox_enabled,
+			webhook_url = EXCLUDED.webhook_url,
+			min_severity = EXCLUDED.min_severity,
+			quiet_hours_start = EXCLUDED.quiet_hours_start,
+			quiet_hours_end = EXCLUDED.quiet_hours_end,
+			timezone = EXCLUDED.timezone
+		RETURNING *`,
+		pref.UserID, pref.EmailEnabled, pref.InboxEnabled, pref.WebhookURL, pref.MinSeverity, pref.QuietHoursStart, pref.QuietHoursEnd, pref.Timezone)
+	if err != nil {
+		zap.S().Error("Error saving notification preference: ", err)
+		return types.NotificationPreference{}, fmt.Errorf("error saving notification preference: %w", err)
+	}
+	return savedPref, nil
+}
+
+// CreateNotification stores a message in a user's inbox
+func (db *DB) CreateNotification(notification types.Notification) (types.Notification, error) {
+	var createdNotification types.Notification
+	err := db.Get(&createdNotification, `
+		INSERT INTO notification (user_id, incident_id, outbreak_id, lab_result_id, subject, body)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING *`,
+		notification.UserID, notification.IncidentID, notification.OutbreakID, notification.LabResultID, notification.Subject, notification.Body)
+	if err != nil {
+		zap.S().Error("Error creating notification: ", err)
+		return types.Notification{}, fmt.Errorf("error creating notification: %w", err)
+	}
+	return createdNotification, nil
+}
+
+// CreateDeferredNotification holds back an email or webhook notification until its delivery time
+func (db *DB) CreateDeferredNotification(notification types.DeferredNotification) error {
+	_, err := db.Exec(`
+		INSERT INTO deferred_notification (user_id, incident_id, subject, body, deliver_after)
+		VALUES ($1, $2, $3, $4, $5)`,
+		notification.UserID, notification.IncidentID, notification.Subject, notification.Body, notification.DeliverAfter)
+	if err != nil {
+		zap.S().Error("Error deferring notification: ", err)
+		return fmt.Errorf("error deferring notification: %w", err)
+	}
+	return nil
+}
+
+// TakeDueNotifications removes and returns the deferred notifications due by now
+//
+// Rows locked by another replica are skipped, so each notification is taken once.
+func (db *DB) TakeDueNotifications(now time.Time) ([]types.DeferredNotification, error) {
+	notifications := []types.DeferredNotification{}
+	err := db.Select(&notifications, `
+		DELETE FROM deferred_notification
+		WHERE deferred_id IN (
+			SELECT deferred_id FROM deferred_notification
+			WHERE deliver_after <= $1
+			ORDER BY deliver_after
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		now)
+	if err != nil {
+		zap.S().Error("Error taking due notifications: ", err)
+		return nil, fmt.Errorf("error taking due notifications: %w", err)
+	}
+	return notifications, nil
+}
+
+// GetNotifications retrieves a user's inbox, newest first
+func (db *DB) GetNotifications(userID int, unreadOnly bool) ([]types.Notification, error) {
+	notifications := []types.Notification{}
+	err := db.Select(&notifications, `
+		SELECT * FROM notification
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC, notification_id DESC`,
+		userID, unreadOnly)
+	if err != nil {
+		zap.S().Error("Error getting notifications: ", err)
+		return nil, fmt.Errorf("error getting notifications: %w", err)
+	}
+	return notifications, nil
+}
+
+// MarkNotificationRead marks a notification of the user as read
+func (db *DB) MarkNotificationRead(id, userID int) (types.Notification, error) {
+	var notification types.Notification
+	err := db.Get(&notification, `
+		UPDATE notification SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+		WHERE notification_id = $1 AND user_id = $2
+		RETURNING *`,
+		id, userID)
+	if err != nil {
+		zap.S().Error("Error marking notification read: ", err)
+		return types.Notification{}, fmt.Errorf("error marking notification read: %w", err)
+	}
+	return notification, nil
+}
+
+// GetIncidentsToEscalate returns open incidents created before the cutoff that
+// have not been escalated yet, together with their apiary manager
+func (db *DB) GetIncidentsToEscalate(cutoff time.Time) ([]types.IncidentEscalation, error) {
+	escalations := []types.IncidentEscalation{}
+	err := db.Select(&escalations, `
+		SELECT i.*, a.apiary_id, a.manager_id
+		FROM incident i
+		JOIN hive h ON h.hive_id = i.hive_id
+		JOIN apiary a ON a.apiary_id = h.apiary_id
+		WHERE i.status = 'open' AND i.escalated_at IS NULL AND i.created_at < $1`,
+		cutoff)
+	if err != nil {
+		zap.S().Error("Error getting incidents to escalate: ", err)
+		return nil, fmt.Errorf("error getting incidents to escalate: %w", err)
+	}
+	return escalations, nil
+}
+
+// MarkIncidentEscalated records that an incident was escalated, returning false
+// if it was acknowledged or escalated in the meantime
+func (db *DB) MarkIncidentEscalated(id int) (bool, error) {
+	result, err := db.Exec("UPDATE incident SET escalated_at = CURRENT_TIMESTAMP WHERE incident_id = $1 AND status = 'open' AND escalated_at IS NULL", id)
+	if err != nil {
+		zap.S().Error("Error marking incident escalated: ", err)
+		return false, fmt.Errorf("error marking incident escalated: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error marking incident escalated: %w", err)
+	}
+	return rows > 0, nil
+}
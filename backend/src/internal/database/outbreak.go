@@ -0,0 +1,346 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+var (
+	// ErrApiaryQuarantined is returned when bees would move into or out of a quarantine zone
+	ErrApiaryQuarantined = errors.New("apiary is under quarantine")
+	// ErrOutbreakLifted is returned when lifting an outbreak that was already lifted
+	ErrOutbreakLifted = errors.New("outbreak was already lifted")
+)
+
+// outbreakColumns selects an outbreak with its disease name
+const outbreakColumns = "o.*, d.name AS disease_name"
+
+// zoneColumns selects the apiaries of an outbreak zone with their hive and colony counts
+const zoneColumns = `
+	o.outbreak_id, a.apiary_id, a.location, a.manager_id, a.latitude, a.longitude,
+	distance_km(c.latitude, c.longitude, a.latitude, a.longitude) AS distance_km,
+	(SELECT COUNT(*) FROM hive h WHERE h.apiary_id = a.apiary_id) AS hive_count,
+	(SELECT COUNT(*) FROM bee_community bc JOIN hive h ON h.hive_id = bc.hive_id
+		WHERE h.apiary_id = a.apiary_id AND bc.dissolved_date IS NULL) AS colony_count`
+
+// checkQuarantine refuses moving bees between two different apiaries when either is quarantined
+func checkQuarantine(tx *sqlx.Tx, fromApiaryID, toApiaryID int) error {
+	if fromApiaryID == toApiaryID {
+		return nil
+	}
+	var quarantined bool
+	err := tx.Get(&quarantined, `
+		SELECT EXISTS (SELECT 1 FROM apiary_quarantine_outbreaks($1))
+			OR EXISTS (SELECT 1 FROM apiary_quarantine_outbreaks($2))`,
+		fromApiaryID, toApiaryID)
+	if err != nil {
+		zap.S().Error("Error checking quarantine: ", err)
+		return fmt.Errorf("error checking quarantine: %w", err)
+	}
+	if quarantined {
+		return ErrApiaryQuarantined
+	}
+	return nil
+}
+
+func (db *DB) GetDisease(id int) (types.Disease, error) {
+	var disease types.Disease
+	err := db.Get(&disease, "SELECT * FROM disease WHERE disease_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting disease: ", err)
+		return types.Disease{}, fmt.Errorf("error getting disease: %w", err)
+	}
+	return disease, nil
+}
+
+func (db *DB) CreateDisease(disease types.Disease) (types.Disease, error) {
+	var createdDisease types.Disease
+	err := db.Get(&createdDisease, `
+		INSERT INTO disease (name, notifiable, default_radius_km, description)
+		VALUES ($1, $2, $3, $4)
+		RETURNING *`,
+		disease.Name, disease.Notifiable, disease.DefaultRadiusKm, disease.Description)
+	if err != nil {
+		zap.S().Error("Error creating disease: ", err)
+		return types.Disease{}, fmt.Errorf("error creating disease: %w", err)
+	}
+	return createdDisease, nil
+}
+
+func (db *DB) UpdateDisease(disease types.Disease) (types.Disease, error) {
+	var updatedDisease types.Disease
+	err := db.Get(&updatedDisease, `
+		UPDATE disease
+		SET name = $1, notifiable = $2, default_radius_km = $3, description = $4
+		WHERE disease_id = $5
+		RETURNING *`,
+		disease.Name, disease.Notifiable, disease.DefaultRadiusKm, disease.Description, disease.DiseaseID)
+	if err != nil {
+		zap.S().Error("Error updating disease: ", err)
+		return types.Disease{}, fmt.Errorf("error updating disease: %w", err)
+	}
+	return updatedDisease, nil
+}
+
+func (db *DB) DeleteDisease(id int) error {
+	_, err := db.Exec("DELETE FROM disease WHERE disease_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error deleting disease: ", err)
+		return fmt.Errorf("error deleting disease: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetAllDiseases() ([]types.Disease, error) {
+	diseases := []types.Disease{}
+	err := db.Select(&diseases, "SELECT * FROM disease ORDER BY name")
+	if err != nil {
+		zap.S().Error("Error getting all diseases: ", err)
+		return []types.Disease{}, fmt.Errorf("error getting all diseases: %w", err)
+	}
+	return diseases, nil
+}
+
+func (db *DB) GetOutbreak(id int) (types.Outbreak, error) {
+	var outbreak types.Outbreak
+	err := db.Get(&outbreak, `
+		SELECT `+outbreakColumns+`
+		FROM outbreak o
+		JOIN disease d ON d.disease_id = o.disease_id
+		WHERE o.outbreak_id = $1`,
+		id)
+	if err != nil {
+		zap.S().Error("Error getting outbreak: ", err)
+		return types.Outbreak{}, fmt.Errorf("error getting outbreak: %w", err)
+	}
+	return outbreak, nil
+}
+
+// DeclareOutbreak records an outbreak, putting its zone under quarantine
+func (db *DB) DeclareOutbreak(outbreak types.Outbreak) (types.Outbreak, error) {
+	var outbreakID int
+	err := db.Get(&outbreakID, `
+		INSERT INTO outbreak (disease_id, record_id, region_id, apiary_id, radius_km, declared_date, declared_by, description)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING outbreak_id`,
+		outbreak.DiseaseID, outbreak.RecordID, outbreak.RegionID, outbreak.ApiaryID, outbreak.RadiusKm,
+		outbreak.DeclaredDate, outbreak.DeclaredBy, outbreak.Description)
+	if err != nil {
+		zap.S().Error("Error declaring outbreak: ", err)
+		return types.Outbreak{}, fmt.Errorf("error declaring outbreak: %w", err)
+	}
+	return db.GetOutbreak(outbreakID)
+}
+
+// declareRecordOutbreak declares an outbreak around the apiary of a veterinary
+// record diagnosing a notifiable disease, inside the record's transaction.
+// It returns a zero outbreak when the disease is not notifiable or an active
+// outbreak of it already quarantines the apiary.
+func declareRecordOutbreak(tx *sqlx.Tx, record types.VeterinaryRecord, declaredBy int) (types.Outbreak, error) {
+	if !record.DiseaseID.Valid {
+		return types.Outbreak{}, nil
+	}
+	// Locking the disease serializes declarations, so concurrent records don't both declare
+	var disease types.Disease
+	if err := tx.Get(&disease, "SELECT * FROM disease WHERE disease_id = $1 FOR UPDATE", record.DiseaseID); err != nil {
+		zap.S().Error("Error getting record disease: ", err)
+		return types.Outbreak{}, fmt.Errorf("error getting record disease: %w", err)
+	}
+	if !disease.Notifiable {
+		return types.Outbreak{}, nil
+	}
+
+	var apiaryID int
+	err := tx.Get(&apiaryID, `
+		SELECT hive_apiary_on(bc.hive_id, $2)
+		FROM veterinary_passport vp
+		JOIN bee_community bc ON bc.community_id = vp.bee_community_id
+		WHERE vp.passport_id = $1`,
+		record.PassportID, record.RecordDate)
+	if err != nil {
+		zap.S().Error("Error getting passport apiary: ", err)
+		return types.Outbreak{}, fmt.Errorf("error getting passport apiary: %w", err)
+	}
+
+	var covered bool
+	err = tx.Get(&covered, `
+		SELECT EXISTS (
+			SELECT 1 FROM apiary_quarantine_outbreaks($1) AS q(outbreak_id)
+			JOIN outbreak o ON o.outbreak_id = q.outbreak_id
+			WHERE o.disease_id = $2
+		)`,
+		apiaryID, disease.DiseaseID)
+	if err != nil {
+		zap.S().Error("Error checking active outbreaks: ", err)
+		return types.Outbreak{}, fmt.Errorf("error checking active outbreaks: %w", err)
+	}
+	if covered {
+		return types.Outbreak{}, nil
+	}
+
+	var outbreakID int
+	err = tx.Get(&outbreakID, `
+		INSERT INTO outbreak (disease_id, record_id, apiary_id, radius_km, declared_date, declared_by, description)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING outbreak_id`,
+		disease.DiseaseID, record.RecordID, apiaryID, disease.DefaultRadiusKm,
+		record.RecordDate, null.NewInt(int64(declaredBy), declaredBy > 0), record.Description)
+	if err != nil {
+		zap.S().Error("Error declaring outbreak: ", err)
+		return types.Outbreak{}, fmt.Errorf("error declaring outbreak: %w", err)
+	}
+
+	var outbreak types.Outbreak
+	err = tx.Get(&outbreak, `
+		SELECT `+outbreakColumns+`
+		FROM outbreak o
+		JOIN disease d ON d.disease_id = o.disease_id
+		WHERE o.outbreak_id = $1`,
+		outbreakID)
+	if err != nil {
+		zap.S().Error("Error getting outbreak: ", err)
+		return types.Outbreak{}, fmt.Errorf("error getting outbreak: %w", err)
+	}
+	return outbreak, nil
+}
+
+// LiftOutbreak ends an outbreak, releasing its zone from quarantine
+func (db *DB) LiftOutbreak(id int, liftedDate null.Time, liftedBy int) (types.Outbreak, error) {
+	outbreak, err := db.GetOutbreak(id)
+	if err != nil {
+		return types.Outbreak{}, err
+	}
+	if outbreak.LiftedDate.Valid {
+		return types.Outbreak{}, ErrOutbreakLifted
+	}
+	_, err = db.Exec(`
+		UPDATE outbreak SET lifted_date = $2, lifted_by = $3
+		WHERE outbreak_id = $1 AND lifted_date IS NULL`,
+		id, liftedDate, null.NewInt(int64(liftedBy), liftedBy > 0))
+	if err != nil {
+		zap.S().Error("Error lifting outbreak: ", err)
+		return types.Outbreak{}, fmt.Errorf("error lifting outbreak: %w", err)
+	}
+	return db.GetOutbreak(id)
+}
+
+// GetOutbreaks returns outbreaks, only those not yet lifted when activeOnly is set, the latest first
+func (db *DB) GetOutbreaks(activeOnly bool) ([]types.Outbreak, error) {
+	outbreaks := []types.Outbreak{}
+	err := db.Select(&outbreaks, `
+		SELECT `+outbreakColumns+`
+		FROM outbreak o
+		JOIN disease d ON d.disease_id = o.disease_id
+		WHERE NOT $1 OR o.lifted_date IS NULL
+		ORDER BY o.declared_date DESC, o.outbreak_id DESC`,
+		activeOnly)
+	if err != nil {
+		zap.S().Error("Error getting outbreaks: ", err)
+		return nil, fmt.Errorf("error getting outbreaks: %w", err)
+	}
+	return outbreaks, nil
+}
+
+// GetOutbreakZone returns the apiaries in an outbreak's quarantine zone, the closest first
+func (db *DB) GetOutbreakZone(id int) ([]types.QuarantinedApiary, error) {
+	zone := []types.QuarantinedApiary{}
+	err := db.Select(&zone, `
+		SELECT `+zoneColumns+`
+		FROM outbreak o
+		JOIN apiary a ON a.apiary_id IN (SELECT outbreak_zone_apiaries(o.outbreak_id))
+		LEFT JOIN apiary c ON c.apiary_id = o.apiary_id
+		WHERE o.outbreak_id = $1
+		ORDER BY distance_km NULLS LAST, a.apiary_id`,
+		id)
+	if err != nil {
+		zap.S().Error("Error getting outbreak zone: ", err)
+		return nil, fmt.Errorf("error getting outbreak zone: %w", err)
+	}
+	return zone, nil
+}
+
+// GetQuarantinedApiaries returns every apiary inside the zone of an outbreak not yet lifted
+func (db *DB) GetQuarantinedApiaries() ([]types.QuarantinedApiary, error) {
+	apiaries := []types.QuarantinedApiary{}
+	err := db.Select(&apiaries, `
+		SELECT `+zoneColumns+`
+		FROM outbreak o
+		JOIN apiary a ON a.apiary_id IN (SELECT outbreak_zone_apiaries(o.outbreak_id))
+		LEFT JOIN apiary c ON c.apiary_id = o.apiary_id
+		WHERE o.lifted_date IS NULL
+		ORDER BY a.apiary_id, o.outbreak_id`)
+	if err != nil {
+		zap.S().Error("Error getting quarantined apiaries: ", err)
+		return nil, fmt.Errorf("error getting quarantined apiaries: %w", err)
+	}
+	return apiaries, nil
+}
+
+// GetApiaryOutbreaks returns the outbreaks currently quarantining an apiary
+func (db *DB) GetApiaryOutbreaks(apiaryID int) ([]types.Outbreak, error) {
+	outbreaks := []types.Outbreak{}
+	err := db.Select(&outbreaks, `
+		SELECT `+outbreakColumns+`
+		FROM outbreak o
+		JOIN disease d ON d.disease_id = o.disease_id
+		WHERE o.outbreak_id IN (SELECT apiary_quarantine_outbreaks($1))
+		ORDER BY o.declared_date`,
+		apiaryID)
+	if err != nil {
+		zap.S().Error("Error getting apiary outbreaks: ", err)
+		return nil, fmt.Errorf("error getting apiary outbreaks: %w", err)
+	}
+	return outbreaks, nil
+}
+
+// GetOutbreakCases returns the record that triggered an outbreak and every later
+// record of its disease within the zone while the outbreak lasted
+func (db *DB) GetOutbreakCases(id int) ([]types.OutbreakCase, error) {
+	cases := []types.OutbreakCase{}
+	err := db.Select(&cases, `
+		SELECT vr.record_id, vr.record_date, hive_apiary_on(bc.hive_id, vr.record_date) AS apiary_id,
+			bc.hive_id, bc.community_id, COALESCE(vr.description, '') AS description, COALESCE(vr.treatment, '') AS treatment
+		FROM outbreak o
+		JOIN veterinary_record vr ON vr.record_id = o.record_id OR (
+			vr.disease_id = o.disease_id
+			AND vr.record_date >= o.declared_date
+			AND (o.lifted_date IS NULL OR vr.record_date <= o.lifted_date)
+		)
+		JOIN veterinary_passport vp ON vp.passport_id = vr.passport_id
+		JOIN bee_community bc ON bc.community_id = vp.bee_community_id
+		WHERE o.outbreak_id = $1
+			AND (vr.record_id = o.record_id
+				OR hive_apiary_on(bc.hive_id, vr.record_date) IN (SELECT outbreak_zone_apiaries(o.outbreak_id)))
+		ORDER BY vr.record_date, vr.record_id`,
+		id)
+	if err != nil {
+		zap.S().Error("Error getting outbreak cases: ", err)
+		return nil, fmt.Errorf("error getting outbreak cases: %w", err)
+	}
+	return cases, nil
+}
+
+// GetOutbreakRecipients returns the managers of the apiaries in an outbreak's zone
+func (db *DB) GetOutbreakRecipients(id int) ([]types.NotificationRecipient, error) {
+	recipients := []types.NotificationRecipient{}
+	err := db.Select(&recipients, `
+		SELECT `+recipientColumns+`
+		FROM "user" u
+		LEFT JOIN notification_preference np ON np.user_id = u.user_id
+		WHERE u.user_id IN (
+			SELECT a.manager_id FROM apiary a
+			WHERE a.apiary_id IN (SELECT outbreak_zone_apiaries($1))
+		)`,
+		id)
+	if err != nil {
+		zap.S().Error("Error getting outbreak recipients: ", err)
+		return nil, fmt.Errorf("error getting outbreak recipients: %w", err)
+	}
+	return recipients, nil
+}
@@ -0,0 +1,477 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+var (
+	// ErrContractClosed is returned when changing a contract in a status that does not allow it
+	ErrContractClosed = errors.New("contract does not allow this in its current status")
+	// ErrHiveBooked is returned when assigning a hive already booked for an overlapping contract
+	ErrHiveBooked = errors.New("hive is booked for another contract over the same period")
+	// ErrContractFull is returned when assigning more hives than a contract is for
+	ErrContractFull = errors.New("contract has all its hives")
+	// ErrHiveDeployed is returned when unassigning a hive that was already moved to the field
+	ErrHiveDeployed = errors.New("hive was already moved to the field")
+	// ErrNoHivesToMove is returned when deploying or returning a contract with no hive left to move
+	ErrNoHivesToMove = errors.New("no hives to move")
+)
+
+// PollinationContractFilter narrows down the contracts returned by GetPollinationContracts
+type PollinationContractFilter struct {
+	CustomerID int
+	Status     types.ContractStatus
+	From       null.Time
+	To         null.Time
+}
+
+// contractColumns selects contract pc with the location of its field apiary a
+const contractColumns = `
+	pc.*, a.location AS field_location, a.latitude AS field_latitude, a.longitude AS field_longitude`
+
+const contractFrom = `
+	FROM pollination_contract pc
+	JOIN apiary a ON a.apiary_id = pc.field_apiary_id`
+
+// CreatePollinationContract records a contract together with the apiary standing
+// for the grower's field, managed by the user creating the contract
+func (db *DB) CreatePollinationContract(contract types.PollinationContract, createdBy int) (types.PollinationContract, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var fieldApiaryID int
+	err = tx.Get(&fieldApiaryID, `
+		INSERT INTO apiary (location, manager_id, latitude, longitude)
+		VALUES ($1, $2, $3, $4)
+		RETURNING apiary_id`,
+		contract.FieldLocation, createdBy, contract.FieldLatitude, contract.FieldLongitude)
+	if err != nil {
+		zap.S().Error("Error creating field apiary: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error creating field apiary: %w", err)
+	}
+	var contractID int
+	err = tx.Get(&contractID, `
+		INSERT INTO pollination_contract (customer_id, crop, field_apiary_id, hive_count, start_date, end_date, price, status, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, 0))
+		RETURNING contract_id`,
+		contract.CustomerID, contract.Crop, fieldApiaryID, contract.HiveCount, contract.StartDate, contract.EndDate,
+		contract.Price, types.ContractPlanned, contract.Notes, createdBy)
+	if err != nil {
+		zap.S().Error("Error creating pollination contract: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error creating pollination contract: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetPollinationContract(contractID)
+}
+
+// lockContract locks a contract, failing with ErrContractClosed unless it has one of the statuses
+func lockContract(tx *sqlx.Tx, id int, statuses ...types.ContractStatus) (types.PollinationContract, error) {
+	var contract types.PollinationContract
+	if err := tx.Get(&contract, "SELECT "+contractColumns+contractFrom+" WHERE pc.contract_id = $1 FOR UPDATE OF pc", id); err != nil {
+		zap.S().Error("Error locking pollination contract: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error locking pollination contract: %w", err)
+	}
+	for _, status := range statuses {
+		if contract.Status == status {
+			return contract, nil
+		}
+	}
+	return types.PollinationContract{}, fmt.Errorf("%w: contract %d is %s", ErrContractClosed, id, contract.Status)
+}
+
+// checkHiveBookings fails with ErrHiveBooked when a hive is assigned to another
+// contract overlapping the period of contract
+func checkHiveBookings(tx *sqlx.Tx, hiveID int, contract types.PollinationContract) error {
+	var booked []int64
+	err := tx.Select(&booked, `
+		SELECT pc.contract_id
+		FROM contract_hive ch
+		JOIN pollination_contract pc ON pc.contract_id = ch.contract_id
+		WHERE ch.hive_id = $1 AND pc.contract_id <> $2 AND pc.status <> $3
+			AND pc.start_date <= $5 AND pc.end_date >= $4`,
+		hiveID, contract.ContractID, types.ContractCancelled, contract.StartDate, contract.EndDate)
+	if err != nil {
+		zap.S().Error("Error checking hive bookings: ", err)
+		return fmt.Errorf("error checking hive bookings: %w", err)
+	}
+	if len(booked) > 0 {
+		return fmt.Errorf("%w: hive %d, contracts %v", ErrHiveBooked, hiveID, booked)
+	}
+	return nil
+}
+
+// UpdatePollinationContract changes the terms and field of a contract whose hives were not moved yet
+func (db *DB) UpdatePollinationContract(contract types.PollinationContract) (types.PollinationContract, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	existing, err := lockContract(tx, contract.ContractID, types.ContractPlanned)
+	if err != nil {
+		return types.PollinationContract{}, err
+	}
+	var hiveIDs []int
+	if err := tx.Select(&hiveIDs, "SELECT hive_id FROM contract_hive WHERE contract_id = $1", contract.ContractID); err != nil {
+		zap.S().Error("Error getting contract hives: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error getting contract hives: %w", err)
+	}
+	if len(hiveIDs) > contract.HiveCount {
+		return types.PollinationContract{}, fmt.Errorf("%w: %d hives already assigned", ErrContractFull, len(hiveIDs))
+	}
+	for _, hiveID := range hiveIDs {
+		if err := checkHiveBookings(tx, hiveID, contract); err != nil {
+			return types.PollinationContract{}, err
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE apiary
+		SET location = $1,
+			latitude = $2,
+			longitude = $3
+		WHERE apiary_id = $4`,
+		contract.FieldLocation, contract.FieldLatitude, contract.FieldLongitude, existing.FieldApiaryID)
+	if err != nil {
+		zap.S().Error("Error updating field apiary: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error updating field apiary: %w", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE pollination_contract
+		SET customer_id = $1,
+			crop = $2,
+			hive_count = $3,
+			start_date = $4,
+			end_date = $5,
+			price = $6,
+			notes = $7
+		WHERE contract_id = $8`,
+		contract.CustomerID, contract.Crop, contract.HiveCount, contract.StartDate, contract.EndDate,
+		contract.Price, contract.Notes, contract.ContractID)
+	if err != nil {
+		zap.S().Error("Error updating pollination contract: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error updating pollination contract: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetPollinationContract(contract.ContractID)
+}
+
+// GetPollinationContract returns a contract with its hives
+func (db *DB) GetPollinationContract(id int) (types.PollinationContract, error) {
+	var contract types.PollinationContract
+	if err := db.Get(&contract, "SELECT "+contractColumns+contractFrom+" WHERE pc.contract_id = $1", id); err != nil {
+		zap.S().Error("Error getting pollination contract: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error getting pollination contract: %w", err)
+	}
+	contract.Hives = []types.ContractHive{}
+	if err := db.Select(&contract.Hives, "SELECT * FROM contract_hive WHERE contract_id = $1 ORDER BY hive_id", id); err != nil {
+		zap.S().Error("Error getting contract hives: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error getting contract hives: %w", err)
+	}
+	return contract, nil
+}
+
+// GetPollinationContracts returns the contracts matching the filter without their
+// hives, by start date; From and To select contracts overlapping that period
+func (db *DB) GetPollinationContracts(filter PollinationContractFilter) ([]types.PollinationContract, error) {
+	contracts := []types.PollinationContract{}
+	err := db.Select(&contracts, `
+		SELECT `+contractColumns+contractFrom+`
+		WHERE ($1 = 0 OR pc.customer_id = $1)
+			AND ($2 = '' OR pc.status = $2)
+			AND ($3::date IS NULL OR pc.end_date >= $3)
+			AND ($4::date IS NULL OR pc.start_date <= $4)
+		ORDER BY pc.start_date, pc.contract_id`,
+		filter.CustomerID, filter.Status, filter.From, filter.To)
+	if err != nil {
+		zap.S().Error("Error getting pollination contracts: ", err)
+		return nil, fmt.Errorf("error getting pollination contracts: %w", err)
+	}
+	return contracts, nil
+}
+
+// AssignContractHives assigns hives to a contract, remembering the apiary each one
+// stands at as its home
+func (db *DB) AssignContractHives(id int, hiveIDs []int) (types.PollinationContract, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	contract, err := lockContract(tx, id, types.ContractPlanned, types.ContractActive)
+	if err != nil {
+		return types.PollinationContract{}, err
+	}
+	for _, hiveID := range hiveIDs {
+		var homeApiaryID int
+		if err := tx.Get(&homeApiaryID, "SELECT apiary_id FROM hive WHERE hive_id = $1 FOR UPDATE", hiveID); err != nil {
+			zap.S().Error("Error locking hive: ", err)
+			return types.PollinationContract{}, fmt.Errorf("error locking hive %d: %w", hiveID, err)
+		}
+		if homeApiaryID == contract.FieldApiaryID {
+			return types.PollinationContract{}, fmt.Errorf("%w: hive %d", ErrHiveDeployed, hiveID)
+		}
+		if err := checkHiveBookings(tx, hiveID, contract); err != nil {
+			return types.PollinationContract{}, err
+		}
+		_, err := tx.Exec(`
+			INSERT INTO contract_hive (contract_id, hive_id, home_apiary_id)
+			VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING`,
+			id, hiveID, homeApiaryID)
+		if err != nil {
+			zap.S().Error("Error assigning contract hive: ", err)
+			return types.PollinationContract{}, fmt.Errorf("error assigning contract hive: %w", err)
+		}
+	}
+	var assigned int
+	if err := tx.Get(&assigned, "SELECT COUNT(*) FROM contract_hive WHERE contract_id = $1", id); err != nil {
+		zap.S().Error("Error counting contract hives: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error counting contract hives: %w", err)
+	}
+	if assigned > contract.HiveCount {
+		return types.PollinationContract{}, fmt.Errorf("%w: %d of %d hives", ErrContractFull, assigned, contract.HiveCount)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetPollinationContract(id)
+}
+
+// UnassignContractHives removes hives not moved to the field yet from a contract
+func (db *DB) UnassignContractHives(id int, hiveIDs []int) (types.PollinationContract, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockContract(tx, id, types.ContractPlanned, types.ContractActive); err != nil {
+		return types.PollinationContract{}, err
+	}
+	var deployed []int64
+	err = tx.Select(&deployed, `
+		SELECT hive_id FROM contract_hive
+		WHERE contract_id = $1 AND hive_id = ANY($2) AND deployed_date IS NOT NULL`,
+		id, pq.Array(hiveIDs))
+	if err != nil {
+		zap.S().Error("Error checking contract hives: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error checking contract hives: %w", err)
+	}
+	if len(deployed) > 0 {
+		return types.PollinationContract{}, fmt.Errorf("%w: hives %v", ErrHiveDeployed, deployed)
+	}
+	if _, err := tx.Exec("DELETE FROM contract_hive WHERE contract_id = $1 AND hive_id = ANY($2)", id, pq.Array(hiveIDs)); err != nil {
+		zap.S().Error("Error unassigning contract hives: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error unassigning contract hives: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetPollinationContract(id)
+}
+
+// DeployContractHives moves the assigned hives not at the field yet to the field
+// apiary and activates the contract
+func (db *DB) DeployContractHives(id int, move types.ContractMoveRequest, performedBy int) (types.PollinationContract, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	contract, err := lockContract(tx, id, types.ContractPlanned, types.ContractActive)
+	if err != nil {
+		return types.PollinationContract{}, err
+	}
+	var hiveIDs []int
+	if err := tx.Select(&hiveIDs, "SELECT hive_id FROM contract_hive WHERE contract_id = $1 AND deployed_date IS NULL ORDER BY hive_id", id); err != nil {
+		zap.S().Error("Error getting contract hives: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error getting contract hives: %w", err)
+	}
+	if len(hiveIDs) == 0 {
+		return types.PollinationContract{}, ErrNoHivesToMove
+	}
+	relocation := types.HiveRelocationRequest{ToApiaryID: contract.FieldApiaryID, EventDate: move.MoveDate, Notes: move.Notes}
+	for _, hiveID := range hiveIDs {
+		if _, err := relocateHive(tx, hiveID, relocation, null.IntFrom(int64(id)), performedBy); err != nil {
+			return types.PollinationContract{}, fmt.Errorf("error deploying hive %d: %w", hiveID, err)
+		}
+	}
+	_, err = tx.Exec("UPDATE contract_hive SET deployed_date = $1 WHERE contract_id = $2 AND hive_id = ANY($3)", move.MoveDate, id, pq.Array(hiveIDs))
+	if err != nil {
+		zap.S().Error("Error deploying contract hives: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error deploying contract hives: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE pollination_contract SET status = $1 WHERE contract_id = $2", types.ContractActive, id); err != nil {
+		zap.S().Error("Error activating pollination contract: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error activating pollination contract: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetPollinationContract(id)
+}
+
+// ReturnContractHives moves every hive at the field back to its home apiary and
+// completes the contract, which adds its price to the home apiaries' revenue
+func (db *DB) ReturnContractHives(id int, move types.ContractMoveRequest, performedBy int) (types.PollinationContract, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockContract(tx, id, types.ContractActive); err != nil {
+		return types.PollinationContract{}, err
+	}
+	var hives []types.ContractHive
+	err = tx.Select(&hives, `
+		SELECT * FROM contract_hive
+		WHERE contract_id = $1 AND deployed_date IS NOT NULL AND returned_date IS NULL
+		ORDER BY hive_id`,
+		id)
+	if err != nil {
+		zap.S().Error("Error getting contract hives: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error getting contract hives: %w", err)
+	}
+	if len(hives) == 0 {
+		return types.PollinationContract{}, ErrNoHivesToMove
+	}
+	for _, hive := range hives {
+		relocation := types.HiveRelocationRequest{ToApiaryID: hive.HomeApiaryID, EventDate: move.MoveDate, Notes: move.Notes}
+		if _, err := relocateHive(tx, hive.HiveID, relocation, null.Int{}, performedBy); err != nil {
+			return types.PollinationContract{}, fmt.Errorf("error returning hive %d: %w", hive.HiveID, err)
+		}
+	}
+	_, err = tx.Exec(`
+		UPDATE contract_hive SET returned_date = $1
+		WHERE contract_id = $2 AND deployed_date IS NOT NULL AND returned_date IS NULL`,
+		move.MoveDate, id)
+	if err != nil {
+		zap.S().Error("Error returning contract hives: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error returning contract hives: %w", err)
+	}
+	// Hives assigned but never deployed did not serve the contract
+	if _, err := tx.Exec("DELETE FROM contract_hive WHERE contract_id = $1 AND deployed_date IS NULL", id); err != nil {
+		zap.S().Error("Error removing undeployed contract hives: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error removing undeployed contract hives: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE pollination_contract SET status = $1 WHERE contract_id = $2", types.ContractCompleted, id); err != nil {
+		zap.S().Error("Error completing pollination contract: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error completing pollination contract: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetPollinationContract(id)
+}
+
+// CancelPollinationContract cancels a contract whose hives were not moved yet, freeing them
+func (db *DB) CancelPollinationContract(id int) (types.PollinationContract, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockContract(tx, id, types.ContractPlanned); err != nil {
+		return types.PollinationContract{}, err
+	}
+	if _, err := tx.Exec("UPDATE pollination_contract SET status = $1 WHERE contract_id = $2", types.ContractCancelled, id); err != nil {
+		zap.S().Error("Error cancelling pollination contract: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error cancelling pollination contract: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.PollinationContract{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetPollinationContract(id)
+}
+
+// GetHiveAvailability returns whether each hive, of an apiary or of all of them
+// for apiaryID 0, is free of contracts between from and to
+func (db *DB) GetHiveAvailability(from, to null.Time, apiaryID int) ([]types.HiveAvailability, error) {
+	var hives []struct {
+		HiveID   int `db:"hive_id"`
+		ApiaryID int `db:"apiary_id"`
+	}
+	err := db.Select(&hives, "SELECT hive_id, apiary_id FROM hive WHERE $1 = 0 OR apiary_id = $1 ORDER BY hive_id", apiaryID)
+	if err != nil {
+		zap.S().Error("Error getting hives: ", err)
+		return nil, fmt.Errorf("error getting hives: %w", err)
+	}
+	var bookings []struct {
+		HiveID int `db:"hive_id"`
+		types.HiveBooking
+	}
+	err = db.Select(&bookings, `
+		SELECT ch.hive_id, pc.contract_id, pc.crop, pc.start_date, pc.end_date, pc.status
+		FROM contract_hive ch
+		JOIN pollination_contract pc ON pc.contract_id = ch.contract_id
+		JOIN hive h ON h.hive_id = ch.hive_id
+		WHERE pc.status <> $1 AND pc.start_date <= $3 AND pc.end_date >= $2
+			AND ($4 = 0 OR h.apiary_id = $4)
+		ORDER BY pc.start_date, pc.contract_id`,
+		types.ContractCancelled, from, to, apiaryID)
+	if err != nil {
+		zap.S().Error("Error getting hive bookings: ", err)
+		return nil, fmt.Errorf("error getting hive bookings: %w", err)
+	}
+
+	byHive := make(map[int][]types.HiveBooking)
+	for _, booking := range bookings {
+		byHive[booking.HiveID] = append(byHive[booking.HiveID], booking.HiveBooking)
+	}
+	availability := make([]types.HiveAvailability, 0, len(hives))
+	for _, hive := range hives {
+		hiveBookings := byHive[hive.HiveID]
+		if hiveBookings == nil {
+			hiveBookings = []types.HiveBooking{}
+		}
+		availability = append(availability, types.HiveAvailability{
+			HiveID:    hive.HiveID,
+			ApiaryID:  hive.ApiaryID,
+			Available: len(hiveBookings) == 0,
+			Bookings:  hiveBookings,
+		})
+	}
+	return availability, nil
+}
@@ -0,0 +1,311 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// ErrLineageCycle is returned when a queen would become her own ancestor
+var ErrLineageCycle = errors.New("queen cannot descend from herself")
+
+// ErrQueenSuperseded is returned when introducing a queen that was superseded
+var ErrQueenSuperseded = errors.New("superseded queens cannot be introduced")
+
+// queenColumns selects a queen with her computed age and current community
+const queenColumns = `
+	q.*, queen_age_months(q.queen_id) AS age_months, qi.community_id, bc.hive_id`
+
+// queenJoins joins a queen to the community she currently heads
+const queenJoins = `
+	LEFT JOIN queen_introduction qi ON qi.queen_id = q.queen_id AND qi.removed_date IS NULL
+	LEFT JOIN bee_community bc ON bc.community_id = qi.community_id`
+
+// QueenFilter narrows down the queens returned by GetQueens
+type QueenFilter struct {
+	Breed  string
+	Line   string
+	Status string
+}
+
+func (db *DB) GetQueen(id int) (types.Queen, error) {
+	var queen types.Queen
+	err := db.Get(&queen, "SELECT "+queenColumns+" FROM queen q "+queenJoins+" WHERE q.queen_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting queen: ", err)
+		return types.Queen{}, fmt.Errorf("error getting queen: %w", err)
+	}
+	return queen, nil
+}
+
+// GetQueens returns the queens matching the filter
+func (db *DB) GetQueens(filter QueenFilter) ([]types.Queen, error) {
+	queens := []types.Queen{}
+	err := db.Select(&queens, `
+		SELECT `+queenColumns+` FROM queen q `+queenJoins+`
+		WHERE ($1 = '' OR q.breed = $1)
+			AND ($2 = '' OR q.line = $2)
+			AND ($3 = '' OR q.status = $3)
+		ORDER BY q.queen_id`,
+		filter.Breed, filter.Line, filter.Status)
+	if err != nil {
+		zap.S().Error("Error getting queens: ", err)
+		return nil, fmt.Errorf("error getting queens: %w", err)
+	}
+	return queens, nil
+}
+
+func (db *DB) CreateQueen(queen types.Queen) (types.Queen, error) {
+	var queenID int
+	err := db.Get(&queenID, `
+		INSERT INTO queen (birth_date, breed, line, marking_color, mother_id, source, status, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING queen_id`,
+		queen.BirthDate, queen.Breed, queen.Line, queen.MarkingColor, queen.MotherID, queen.Source, queen.Status, queen.Notes)
+	if err != nil {
+		zap.S().Error("Error creating queen: ", err)
+		return types.Queen{}, fmt.Errorf("error creating queen: %w", err)
+	}
+	return db.GetQueen(queenID)
+}
+
+// UpdateQueen updates a queen's details
+//
+// Her status is left alone; it changes through IntroduceQueen and ChangeQueenStatus
+// so her introductions stay consistent
+func (db *DB) UpdateQueen(queen types.Queen) (types.Queen, error) {
+	if queen.MotherID.Valid {
+		var cycle bool
+		err := db.Get(&cycle, `
+			WITH RECURSIVE descendants AS (
+				SELECT queen_id FROM queen WHERE queen_id = $1
+				UNION
+				SELECT q.queen_id FROM queen q JOIN descendants d ON q.mother_id = d.queen_id
+			)
+			SELECT EXISTS (SELECT 1 FROM descendants WHERE queen_id = $2)`,
+			queen.QueenID, queen.MotherID)
+		if err != nil {
+			zap.S().Error("Error checking queen lineage: ", err)
+			return types.Queen{}, fmt.Errorf("error checking queen lineage: %w", err)
+		}
+		if cycle {
+			return types.Queen{}, ErrLineageCycle
+		}
+	}
+
+	var queenID int
+	err := db.Get(&queenID, `
+		UPDATE queen
+		SET birth_date = $1,
+			breed = $2,
+			line = $3,
+			marking_color = $4,
+			mother_id = $5,
+			source = $6,
+			notes = $7
+		WHERE queen_id = $8
+		RETURNING queen_id`,
+		queen.BirthDate, queen.Breed, queen.Line, queen.MarkingColor, queen.MotherID, queen.Source, queen.Notes, queen.QueenID)
+	if err != nil {
+		zap.S().Error("Error updating queen: ", err)
+		return types.Queen{}, fmt.Errorf("error updating queen: %w", err)
+	}
+	return db.GetQueen(queenID)
+}
+
+func (db *DB) DeleteQueen(id int) error {
+	_, err := db.Exec("DELETE FROM queen WHERE queen_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error deleting queen: ", err)
+		return fmt.Errorf("error deleting queen: %w", err)
+	}
+	return nil
+}
+
+// IntroduceQueen makes a queen head a community from the introduction date
+//
+// The community's previous queen is marked replaced, and if the queen headed
+// another community she is moved out of it. The queen becomes present again;
+// superseded queens are rejected with ErrQueenSuperseded.
+func (db *DB) IntroduceQueen(introduction types.QueenIntroduction) (types.QueenIntroduction, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.QueenIntroduction{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status types.QueenStatus
+	if err := tx.Get(&status, "SELECT status FROM queen WHERE queen_id = $1 FOR UPDATE", introduction.QueenID); err != nil {
+		zap.S().Error("Error locking queen: ", err)
+		return types.QueenIntroduction{}, fmt.Errorf("error locking queen: %w", err)
+	}
+	if status == types.QueenSuperseded {
+		return types.QueenIntroduction{}, ErrQueenSuperseded
+	}
+
+	var current types.QueenIntroduction
+	err = tx.Get(&current, "SELECT * FROM queen_introduction WHERE queen_id = $1 AND removed_date IS NULL", introduction.QueenID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		zap.S().Error("Error getting current queen introduction: ", err)
+		return types.QueenIntroduction{}, fmt.Errorf("error getting current queen introduction: %w", err)
+	}
+	if err == nil && current.CommunityID == introduction.CommunityID {
+		return current, nil
+	}
+
+	_, err = tx.Exec(`
+		WITH replaced AS (
+			UPDATE queen_introduction
+			SET removed_date = GREATEST($2, introduced_date), removal_reason = 'replaced'
+			WHERE community_id = $1 AND removed_date IS NULL
+			RETURNING queen_id
+		)
+		UPDATE queen SET status = 'replaced' WHERE queen_id IN (SELECT queen_id FROM replaced)`,
+		introduction.CommunityID, introduction.IntroducedDate)
+	if err != nil {
+		zap.S().Error("Error replacing current queen: ", err)
+		return types.QueenIntroduction{}, fmt.Errorf("error replacing current queen: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE queen_introduction SET removed_date = GREATEST($2, introduced_date), removal_reason = $3
+		WHERE queen_id = $1 AND removed_date IS NULL`,
+		introduction.QueenID, introduction.IntroducedDate, types.QueenMoved)
+	if err != nil {
+		zap.S().Error("Error moving queen: ", err)
+		return types.QueenIntroduction{}, fmt.Errorf("error moving queen: %w", err)
+	}
+
+	var createdIntroduction types.QueenIntroduction
+	err = tx.Get(&createdIntroduction, `
+		INSERT INTO queen_introduction (queen_id, community_id, introduced_date, notes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING *`,
+		introduction.QueenID, introduction.CommunityID, introduction.IntroducedDate, introduction.Notes)
+	if err != nil {
+		zap.S().Error("Error introducing queen: ", err)
+		return types.QueenIntroduction{}, fmt.Errorf("error introducing queen: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE queen SET status = $2 WHERE queen_id = $1", introduction.QueenID, types.QueenPresent); err != nil {
+		zap.S().Error("Error updating queen status: ", err)
+		return types.QueenIntroduction{}, fmt.Errorf("error updating queen status: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.QueenIntroduction{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return createdIntroduction, nil
+}
+
+// ChangeQueenStatus records that a queen was superseded, went missing or was
+// replaced, ending her reign over her current community
+func (db *DB) ChangeQueenStatus(id int, change types.QueenStatusChange) (types.Queen, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.Queen{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var queenID int
+	if err := tx.Get(&queenID, "UPDATE queen SET status = $2 WHERE queen_id = $1 RETURNING queen_id", id, change.Status); err != nil {
+		zap.S().Error("Error updating queen status: ", err)
+		return types.Queen{}, fmt.Errorf("error updating queen status: %w", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE queen_introduction
+		SET removed_date = GREATEST($2, introduced_date), removal_reason = $3, notes = COALESCE($4, notes)
+		WHERE queen_id = $1 AND removed_date IS NULL`,
+		id, change.Date, change.Status, change.Notes)
+	if err != nil {
+		zap.S().Error("Error ending queen introduction: ", err)
+		return types.Queen{}, fmt.Errorf("error ending queen introduction: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.Queen{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetQueen(queenID)
+}
+
+// GetQueenIntroductions returns the communities a queen headed, the latest first
+func (db *DB) GetQueenIntroductions(queenID int) ([]types.QueenIntroduction, error) {
+	introductions := []types.QueenIntroduction{}
+	err := db.Select(&introductions, "SELECT * FROM queen_introduction WHERE queen_id = $1 ORDER BY introduced_date DESC, introduction_id DESC", queenID)
+	if err != nil {
+		zap.S().Error("Error getting queen introductions: ", err)
+		return nil, fmt.Errorf("error getting queen introductions: %w", err)
+	}
+	return introductions, nil
+}
+
+// GetRequeeningHistory returns the queens that headed the communities of a hive, the latest first
+func (db *DB) GetRequeeningHistory(hiveID int) ([]types.RequeeningEntry, error) {
+	history := []types.RequeeningEntry{}
+	err := db.Select(&history, `
+		SELECT qi.*, bc.hive_id, q.breed, q.line, q.marking_color, q.source
+		FROM queen_introduction qi
+		JOIN bee_community bc ON bc.community_id = qi.community_id
+		JOIN queen q ON q.queen_id = qi.queen_id
+		WHERE bc.hive_id = $1
+		ORDER BY qi.introduced_date DESC, qi.introduction_id DESC`,
+		hiveID)
+	if err != nil {
+		zap.S().Error("Error getting requeening history: ", err)
+		return nil, fmt.Errorf("error getting requeening history: %w", err)
+	}
+	return history, nil
+}
+
+// GetQueenLineage returns a queen with her ancestors and descendants up to depth generations away
+func (db *DB) GetQueenLineage(id, depth int) (types.QueenLineage, error) {
+	queen, err := db.GetQueen(id)
+	if err != nil {
+		return types.QueenLineage{}, err
+	}
+	lineage := types.QueenLineage{Queen: queen, Ancestors: []types.QueenRelative{}, Descendants: []types.QueenRelative{}}
+
+	err = db.Select(&lineage.Ancestors, `
+		WITH RECURSIVE ancestors AS (
+			SELECT mother_id AS queen_id, 1 AS generation FROM queen WHERE queen_id = $1 AND mother_id IS NOT NULL
+			UNION ALL
+			SELECT q.mother_id, a.generation + 1
+			FROM queen q JOIN ancestors a ON q.queen_id = a.queen_id
+			WHERE q.mother_id IS NOT NULL AND a.generation < $2
+		)
+		SELECT `+queenColumns+`, a.generation
+		FROM ancestors a
+		JOIN queen q ON q.queen_id = a.queen_id `+queenJoins+`
+		ORDER BY a.generation`,
+		id, depth)
+	if err != nil {
+		zap.S().Error("Error getting queen ancestors: ", err)
+		return types.QueenLineage{}, fmt.Errorf("error getting queen ancestors: %w", err)
+	}
+
+	err = db.Select(&lineage.Descendants, `
+		WITH RECURSIVE descendants AS (
+			SELECT queen_id, 1 AS generation FROM queen WHERE mother_id = $1
+			UNION ALL
+			SELECT q.queen_id, d.generation + 1
+			FROM queen q JOIN descendants d ON q.mother_id = d.queen_id
+			WHERE d.generation < $2
+		)
+		SELECT `+queenColumns+`, d.generation
+		FROM descendants d
+		JOIN queen q ON q.queen_id = d.queen_id `+queenJoins+`
+		ORDER BY d.generation, q.queen_id`,
+		id, depth)
+	if err != nil {
+		zap.S().Error("Error getting queen descendants: ", err)
+		return types.QueenLineage{}, fmt.Errorf("error getting queen descendants: %w", err)
+	}
+	return lineage, nil
+}
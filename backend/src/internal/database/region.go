@@ -1,13 +1,34 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/guregu/null"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 
 	types "github.com/orientallines/beesbiz/internal/types/db"
 )
 
+var (
+	// ErrRegionCycle is returned when nesting a region inside itself or one of its descendants
+	ErrRegionCycle = errors.New("region cannot be nested inside itself or its descendants")
+	// ErrRegionHasChildren is returned when deleting a region other regions are nested in
+	ErrRegionHasChildren = errors.New("region has nested regions")
+)
+
+// RegionStatsFilter narrows down the regions returned by GetRegionStats
+//
+// RegionID keeps the region and the regions directly nested in it; Depth keeps
+// one level of the tree; From and To bound harvest and incident dates
+type RegionStatsFilter struct {
+	RegionID int
+	Depth    null.Int
+	From     null.Time
+	To       null.Time
+}
+
 func (db *DB) GetRegion(id int) (types.Region, error) {
 	var region types.Region
 	err := db.Get(&region, "SELECT * FROM region WHERE region_id = $1", id)
@@ -20,7 +41,7 @@ func (db *DB) GetRegion(id int) (types.Region, error) {
 
 func (db *DB) CreateRegion(region types.Region) (types.Region, error) {
 	var createdRegion types.Region
-	err := db.Get(&createdRegion, "INSERT INTO region (name, climate_zone) VALUES ($1, $2) RETURNING *", region.Name, region.ClimateZone)
+	err := db.Get(&createdRegion, "INSERT INTO region (name, climate_zone, boundary, parent_region_id) VALUES ($1, $2, $3, $4) RETURNING *", region.Name, region.ClimateZone, region.Boundary, region.ParentRegionID)
 	if err != nil {
 		zap.S().Error("Error creating region: ", err)
 		return types.Region{}, fmt.Errorf("error creating region: %w", err)
@@ -28,18 +49,74 @@ func (db *DB) CreateRegion(region types.Region) (types.Region, error) {
 	return createdRegion, nil
 }
 
+// UpdateRegion updates a region, refusing to nest it inside its own subtree
+//
+// The region and the chain of regions above its new parent are locked before
+// the check, so two concurrent moves cannot together close a cycle.
 func (db *DB) UpdateRegion(region types.Region) (types.Region, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.Region{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked []int
+	err = tx.Select(&locked, `
+		WITH RECURSIVE ancestors AS (
+			SELECT region_id, parent_region_id, ARRAY[region_id] AS path FROM region WHERE region_id = $2
+			UNION ALL
+			SELECT r.region_id, r.parent_region_id, a.path || r.region_id
+			FROM region r
+			JOIN ancestors a ON r.region_id = a.parent_region_id
+			WHERE r.region_id <> ALL(a.path)
+		)
+		SELECT region_id FROM region
+		WHERE region_id = $1 OR region_id IN (SELECT region_id FROM ancestors)
+		ORDER BY region_id
+		FOR UPDATE`, region.RegionID, region.ParentRegionID)
+	if err != nil {
+		zap.S().Error("Error locking regions: ", err)
+		return types.Region{}, fmt.Errorf("error locking regions: %w", err)
+	}
+
+	if region.ParentRegionID.Valid {
+		var cycle bool
+		err := tx.Get(&cycle, "SELECT $2 IN (SELECT region_descendants($1))", region.RegionID, region.ParentRegionID)
+		if err != nil {
+			zap.S().Error("Error checking region parent: ", err)
+			return types.Region{}, fmt.Errorf("error checking region parent: %w", err)
+		}
+		if cycle {
+			return types.Region{}, ErrRegionCycle
+		}
+	}
 	var updatedRegion types.Region
-	err := db.Get(&updatedRegion, "UPDATE region SET name = $1, climate_zone = $2 WHERE region_id = $3 RETURNING *", region.Name, region.ClimateZone, region.RegionID)
+	err = tx.Get(&updatedRegion, "UPDATE region SET name = $1, climate_zone = $2, boundary = $3, parent_region_id = $4 WHERE region_id = $5 RETURNING *", region.Name, region.ClimateZone, region.Boundary, region.ParentRegionID, region.RegionID)
 	if err != nil {
 		zap.S().Error("Error updating region: ", err)
 		return types.Region{}, fmt.Errorf("error updating region: %w", err)
 	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.Region{}, fmt.Errorf("error committing transaction: %w", err)
+	}
 	return updatedRegion, nil
 }
 
+// DeleteRegion deletes a region no other region is nested in
 func (db *DB) DeleteRegion(id int) error {
-	_, err := db.Exec("DELETE FROM region WHERE region_id = $1", id)
+	var hasChildren bool
+	err := db.Get(&hasChildren, "SELECT EXISTS (SELECT 1 FROM region WHERE parent_region_id = $1)", id)
+	if err != nil {
+		zap.S().Error("Error checking nested regions: ", err)
+		return fmt.Errorf("error checking nested regions: %w", err)
+	}
+	if hasChildren {
+		return ErrRegionHasChildren
+	}
+	_, err = db.Exec("DELETE FROM region WHERE region_id = $1", id)
 	if err != nil {
 		zap.S().Error("Error deleting region: ", err)
 		return fmt.Errorf("error deleting region: %w", err)
@@ -67,6 +144,16 @@ func (db *DB) CreateAllowedRegion(allowedRegion types.AllowedRegion) (types.Allo
 	return createdAllowedRegion, nil
 }
 
+func (db *DB) GetAllowedRegion(id int) (types.AllowedRegion, error) {
+	var allowedRegion types.AllowedRegion
+	err := db.Get(&allowedRegion, "SELECT * FROM allowed_region WHERE id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting allowed region: ", err)
+		return types.AllowedRegion{}, fmt.Errorf("error getting allowed region: %w", err)
+	}
+	return allowedRegion, nil
+}
+
 func (db *DB) UpdateAllowedRegion(allowedRegion types.AllowedRegion) (types.AllowedRegion, error) {
 	var updatedAllowedRegion types.AllowedRegion
 	err := db.Get(&updatedAllowedRegion, "UPDATE allowed_region SET user_id = $1, region_id = $2 WHERE id = $3 RETURNING *", allowedRegion.UserID, allowedRegion.RegionID, allowedRegion.ID)
@@ -153,3 +240,161 @@ func (db *DB) DeleteAllowedRegionsForUser(userID int) error {
 	}
 	return nil
 }
+
+// GetAllowedRegionIDs returns the IDs of the regions a user has access to,
+// including every region nested in a region granted to them
+func (db *DB) GetAllowedRegionIDs(userID int) ([]int, error) {
+	var regionIDs []int
+	err := db.Select(&regionIDs, "SELECT user_region_ids($1)", userID)
+	if err != nil {
+		zap.S().Error("Error getting allowed region IDs: ", err)
+		return nil, fmt.Errorf("error getting allowed region IDs: %w", err)
+	}
+	return regionIDs, nil
+}
+
+// ReplaceAllowedRegions sets the regions of a user in one transaction
+//
+// When scope is not nil only regions inside scope are replaced, so a manager
+// editing a worker leaves the regions they do not hold untouched.
+func (db *DB) ReplaceAllowedRegions(userID int, regionIDs, scope []int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if scope == nil {
+		_, err = tx.Exec("DELETE FROM allowed_region WHERE user_id = $1", userID)
+	} else {
+		_, err = tx.Exec("DELETE FROM allowed_region WHERE user_id = $1 AND region_id = ANY($2)", userID, pq.Array(scope))
+	}
+	if err != nil {
+		zap.S().Error("Error deleting allowed regions for user: ", err)
+		return fmt.Errorf("error deleting allowed regions for user: %w", err)
+	}
+
+	for _, regionID := range regionIDs {
+		if _, err := tx.Exec("INSERT INTO allowed_region (user_id, region_id) VALUES ($1, $2) ON CONFLICT (user_id, region_id) DO NOTHING", userID, regionID); err != nil {
+			zap.S().Error("Error adding allowed region: ", err)
+			return fmt.Errorf("error adding allowed region: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// regionStatsColumns selects regions r with their statistics rolled up over
+// their subtree, harvest and incident dates bounded by $1 and $2
+const regionStatsColumns = `
+	r.region_id, r.name, r.parent_region_id, region_depth(r.region_id) AS depth,
+	(SELECT COUNT(*) FROM region_apiaries(r.region_id)) AS apiary_count,
+	(SELECT COUNT(*) FROM hive h WHERE h.apiary_id IN (SELECT region_apiaries(r.region_id))) AS hive_count,
+	(SELECT COUNT(*) FROM honey_harvest hh
+		WHERE hive_apiary_on(hh.hive_id, hh.harvest_date) IN (SELECT region_apiaries(r.region_id))
+		AND ($1::date IS NULL OR hh.harvest_date >= $1)
+		AND ($2::date IS NULL OR hh.harvest_date <= $2)) AS harvest_count,
+	(SELECT COALESCE(SUM(hh.quantity), 0) FROM honey_harvest hh
+		WHERE hive_apiary_on(hh.hive_id, hh.harvest_date) IN (SELECT region_apiaries(r.region_id))
+		AND ($1::date IS NULL OR hh.harvest_date >= $1)
+		AND ($2::date IS NULL OR hh.harvest_date <= $2)) AS honey_harvested,
+	(SELECT COUNT(*) FROM incident i
+		WHERE hive_apiary_on(i.hive_id, i.incident_date) IN (SELECT region_apiaries(r.region_id))
+		AND ($1::date IS NULL OR i.incident_date >= $1)
+		AND ($2::date IS NULL OR i.incident_date <= $2)) AS incident_count`
+
+// GetRegionTree returns the regions as a tree, rooted at rootID or at every
+// top-level region when rootID is 0
+//
+// When scope is not nil only regions inside scope are kept; a kept region whose
+// parent is outside scope becomes a root
+func (db *DB) GetRegionTree(rootID int, scope []int) ([]types.RegionNode, error) {
+	var regions []types.Region
+	err := db.Select(&regions, `
+		SELECT * FROM region
+		WHERE ($1 = 0 OR region_id IN (SELECT region_descendants($1)))
+		AND ($2::int[] IS NULL OR region_id = ANY($2))
+		ORDER BY name`,
+		rootID, pq.Array(scope))
+	if err != nil {
+		zap.S().Error("Error getting region tree: ", err)
+		return nil, fmt.Errorf("error getting region tree: %w", err)
+	}
+
+	kept := make(map[int]bool, len(regions))
+	for _, region := range regions {
+		kept[region.RegionID] = true
+	}
+	children := make(map[int][]types.Region)
+	var roots []types.Region
+	for _, region := range regions {
+		if region.RegionID == rootID || !region.ParentRegionID.Valid || !kept[int(region.ParentRegionID.Int64)] {
+			roots = append(roots, region)
+		} else {
+			parentID := int(region.ParentRegionID.Int64)
+			children[parentID] = append(children[parentID], region)
+		}
+	}
+
+	var build func(region types.Region) types.RegionNode
+	build = func(region types.Region) types.RegionNode {
+		node := types.RegionNode{Region: region, Children: []types.RegionNode{}}
+		for _, child := range children[region.RegionID] {
+			node.Children = append(node.Children, build(child))
+		}
+		return node
+	}
+	tree := []types.RegionNode{}
+	for _, root := range roots {
+		tree = append(tree, build(root))
+	}
+	return tree, nil
+}
+
+// GetAccessibleRegions returns the regions a user has access to, including every
+// region nested in a region granted to them
+func (db *DB) GetAccessibleRegions(userID int) ([]types.Region, error) {
+	regions := []types.Region{}
+	err := db.Select(&regions, "SELECT * FROM region WHERE region_id IN (SELECT user_region_ids($1)) ORDER BY name", userID)
+	if err != nil {
+		zap.S().Error("Error getting accessible regions: ", err)
+		return nil, fmt.Errorf("error getting accessible regions: %w", err)
+	}
+	return regions, nil
+}
+
+// GetRegionApiaries returns the apiaries of a region and of every region nested in it
+func (db *DB) GetRegionApiaries(regionID int) ([]types.Apiary, error) {
+	apiaries := []types.Apiary{}
+	err := db.Select(&apiaries, "SELECT * FROM apiary WHERE apiary_id IN (SELECT region_apiaries($1)) ORDER BY apiary_id", regionID)
+	if err != nil {
+		zap.S().Error("Error getting region apiaries: ", err)
+		return nil, fmt.Errorf("error getting region apiaries: %w", err)
+	}
+	return apiaries, nil
+}
+
+// GetRegionStats returns statistics rolled up per region over its subtree
+//
+// When scope is not nil only regions inside scope are returned
+func (db *DB) GetRegionStats(filter RegionStatsFilter, scope []int) ([]types.RegionStats, error) {
+	stats := []types.RegionStats{}
+	err := db.Select(&stats, `
+		SELECT `+regionStatsColumns+`
+		FROM region r
+		WHERE ($3 = 0 OR r.region_id = $3 OR r.parent_region_id = $3)
+		AND ($4::int IS NULL OR region_depth(r.region_id) = $4)
+		AND ($5::int[] IS NULL OR r.region_id = ANY($5))
+		ORDER BY depth, r.name`,
+		filter.From, filter.To, filter.RegionID, filter.Depth, pq.Array(scope))
+	if err != nil {
+		zap.S().Error("Error getting region statistics: ", err)
+		return nil, fmt.Errorf("error getting region statistics: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,749 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+var (
+	// ErrInsufficientStock is returned when reserving or removing more than a stock item has available
+	ErrInsufficientStock = errors.New("not enough stock available")
+	// ErrStockExceedsLot is returned when receiving more honey into stock than its lot has left
+	ErrStockExceedsLot = errors.New("stock exceeds what is left of the lot")
+	// ErrBatchNotReleased is returned when selling honey from a batch that was not released
+	ErrBatchNotReleased = errors.New("batch was not released for sale")
+	// ErrBatchQuarantined is returned when selling honey from an apiary under quarantine
+	ErrBatchQuarantined = errors.New("honey comes from an apiary under quarantine")
+	// ErrNoUnitPrice is returned when an order line has no price and its stock item has none either
+	ErrNoUnitPrice = errors.New("no unit price")
+	// ErrOrderClosed is returned when fulfilling or cancelling an order that is no longer reserved
+	ErrOrderClosed = errors.New("order is no longer reserved")
+	// ErrInvoicePaid is returned when paying an invoice twice
+	ErrInvoicePaid = errors.New("invoice was already paid")
+)
+
+// StockFilter narrows down the stock items returned by GetStockItems
+type StockFilter struct {
+	BatchID       int
+	Location      string
+	ContainerType types.ContainerType
+	InStockOnly   bool
+}
+
+// SalesOrderFilter narrows down the orders returned by GetSalesOrders
+type SalesOrderFilter struct {
+	CustomerID int
+	Status     types.OrderStatus
+}
+
+// InvoiceFilter narrows down the invoices returned by GetInvoices
+type InvoiceFilter struct {
+	CustomerID int
+	UnpaidOnly bool
+	From       null.Time
+	To         null.Time
+}
+
+// stockColumns selects stock item si with its lot number and available quantity
+const stockColumns = `
+	si.*,
+	COALESCE(jl.lot_number, hb.lot_number) AS lot_number,
+	si.quantity_on_hand - si.quantity_reserved AS available`
+
+const stockFrom = `
+	FROM stock_item si
+	JOIN honey_batch hb ON hb.batch_id = si.batch_id
+	LEFT JOIN jar_lot jl ON jl.jar_lot_id = si.jar_lot_id`
+
+// invoiceColumns selects invoice i with the customer of its order so
+const invoiceColumns = `i.*, so.customer_id`
+
+// roundMoney rounds an amount to cents
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// Customers
+
+func (db *DB) GetCustomer(id int) (types.Customer, error) {
+	var customer types.Customer
+	err := db.Get(&customer, "SELECT * FROM customer WHERE customer_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting customer: ", err)
+		return types.Customer{}, fmt.Errorf("error getting customer: %w", err)
+	}
+	return customer, nil
+}
+
+func (db *DB) CreateCustomer(customer types.Customer) (types.Customer, error) {
+	var createdCustomer types.Customer
+	err := db.Get(&createdCustomer, `
+		INSERT INTO customer (name, email, phone, address, tax_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING *`,
+		customer.Name, customer.Email, customer.Phone, customer.Address, customer.TaxID)
+	if err != nil {
+		zap.S().Error("Error creating customer: ", err)
+		return types.Customer{}, fmt.Errorf("error creating customer: %w", err)
+	}
+	return createdCustomer, nil
+}
+
+func (db *DB) UpdateCustomer(customer types.Customer) (types.Customer, error) {
+	var updatedCustomer types.Customer
+	err := db.Get(&updatedCustomer, `
+		UPDATE customer
+		SET name = $1,
+			email = $2,
+			phone = $3,
+			address = $4,
+			tax_id = $5
+		WHERE customer_id = $6
+		RETURNING *`,
+		customer.Name, customer.Email, customer.Phone, customer.Address, customer.TaxID, customer.CustomerID)
+	if err != nil {
+		zap.S().Error("Error updating customer: ", err)
+		return types.Customer{}, fmt.Errorf("error updating customer: %w", err)
+	}
+	return updatedCustomer, nil
+}
+
+func (db *DB) DeleteCustomer(id int) error {
+	_, err := db.Exec("DELETE FROM customer WHERE customer_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error deleting customer: ", err)
+		return fmt.Errorf("error deleting customer: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetAllCustomers() ([]types.Customer, error) {
+	customers := []types.Customer{}
+	err := db.Select(&customers, "SELECT * FROM customer ORDER BY name, customer_id")
+	if err != nil {
+		zap.S().Error("Error getting all customers: ", err)
+		return nil, fmt.Errorf("error getting all customers: %w", err)
+	}
+	return customers, nil
+}
+
+// Stock
+
+// checkSellable refuses honey from batches that were not released, that have
+// out-of-spec lab results or that came from an apiary now under quarantine
+//
+// Lab results are checked again because they can arrive after the release.
+func checkSellable(tx *sqlx.Tx, batchID int) error {
+	var released null.Time
+	if err := tx.Get(&released, "SELECT released_date FROM honey_batch WHERE batch_id = $1", batchID); err != nil {
+		zap.S().Error("Error getting honey batch: ", err)
+		return fmt.Errorf("error getting honey batch: %w", err)
+	}
+	if !released.Valid {
+		return fmt.Errorf("%w: batch %d", ErrBatchNotReleased, batchID)
+	}
+	var blocking []int64
+	if err := tx.Select(&blocking, "SELECT batch_blocking_results($1)", batchID); err != nil {
+		zap.S().Error("Error checking batch lab results: ", err)
+		return fmt.Errorf("error checking batch lab results: %w", err)
+	}
+	if len(blocking) > 0 {
+		return fmt.Errorf("%w: batch %d, lab results %v", ErrBatchOutOfSpec, batchID, blocking)
+	}
+	var outbreaks []int64
+	if err := tx.Select(&outbreaks, "SELECT batch_quarantine_outbreaks($1)", batchID); err != nil {
+		zap.S().Error("Error checking batch quarantine: ", err)
+		return fmt.Errorf("error checking batch quarantine: %w", err)
+	}
+	if len(outbreaks) > 0 {
+		return fmt.Errorf("%w: batch %d, outbreaks %v", ErrBatchQuarantined, batchID, outbreaks)
+	}
+	return nil
+}
+
+// lockStockItem locks a stock item for updating its quantities
+func lockStockItem(tx *sqlx.Tx, id int) (types.StockItem, error) {
+	var item types.StockItem
+	err := tx.Get(&item, "SELECT "+stockColumns+stockFrom+" WHERE si.stock_id = $1 FOR UPDATE OF si", id)
+	if err != nil {
+		zap.S().Error("Error locking stock item: ", err)
+		return types.StockItem{}, fmt.Errorf("error locking stock item %d: %w", id, err)
+	}
+	return item, nil
+}
+
+// addStockMovement records a change of a stock item's quantity on hand
+func addStockMovement(tx *sqlx.Tx, movement types.StockMovement) error {
+	_, err := tx.Exec(`
+		INSERT INTO stock_movement (stock_id, quantity_change, reason, order_id, moved_by, notes)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		movement.StockID, movement.QuantityChange, movement.Reason, movement.OrderID, movement.MovedBy, movement.Notes)
+	if err != nil {
+		zap.S().Error("Error recording stock movement: ", err)
+		return fmt.Errorf("error recording stock movement: %w", err)
+	}
+	return nil
+}
+
+// ReceiveStock puts honey from a jar lot, or from the unbottled part of a batch,
+// into stock at a location, adding to a matching stock item if there is one
+func (db *DB) ReceiveStock(receipt types.StockReceipt, receivedBy int) (types.StockItem, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.StockItem{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockBatch(tx, receipt.BatchID, false); err != nil {
+		return types.StockItem{}, err
+	}
+	if receipt.JarLotID.Valid {
+		var lot types.JarLot
+		err := tx.Get(&lot, "SELECT * FROM jar_lot WHERE jar_lot_id = $1 AND batch_id = $2", receipt.JarLotID, receipt.BatchID)
+		if err != nil {
+			zap.S().Error("Error getting jar lot: ", err)
+			return types.StockItem{}, fmt.Errorf("error getting jar lot %d of batch %d: %w", receipt.JarLotID.Int64, receipt.BatchID, err)
+		}
+		var stocked int
+		err = tx.Get(&stocked, `
+			SELECT COALESCE(SUM(sm.quantity_change), 0)
+			FROM stock_movement sm
+			JOIN stock_item si ON si.stock_id = sm.stock_id
+			WHERE si.jar_lot_id = $1 AND sm.reason = $2`,
+			lot.JarLotID, types.ReasonReceipt)
+		if err != nil {
+			zap.S().Error("Error getting stocked jars: ", err)
+			return types.StockItem{}, fmt.Errorf("error getting stocked jars: %w", err)
+		}
+		if receipt.Quantity > lot.JarCount-stocked {
+			return types.StockItem{}, fmt.Errorf("%w: %d jars of lot %s left", ErrStockExceedsLot, lot.JarCount-stocked, lot.LotNumber)
+		}
+		receipt.ContainerType = types.ContainerJar
+		receipt.UnitWeightKg = float64(lot.JarSizeG) / 1000
+	} else {
+		available, err := batchAvailableKg(tx, receipt.BatchID)
+		if err != nil {
+			return types.StockItem{}, err
+		}
+		if requested := float64(receipt.Quantity) * receipt.UnitWeightKg; requested > available+quantityTolerance {
+			return types.StockItem{}, fmt.Errorf("%w: %.2f kg left, %.2f kg requested", ErrStockExceedsLot, available, requested)
+		}
+	}
+
+	var stockID int
+	err = tx.Get(&stockID, `
+		SELECT stock_id FROM stock_item
+		WHERE batch_id = $1 AND jar_lot_id IS NOT DISTINCT FROM $2
+			AND container_type = $3 AND unit_weight_kg = $4 AND location = $5
+		ORDER BY stock_id
+		LIMIT 1
+		FOR UPDATE`,
+		receipt.BatchID, receipt.JarLotID, receipt.ContainerType, receipt.UnitWeightKg, receipt.Location)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = tx.Get(&stockID, `
+			INSERT INTO stock_item (batch_id, jar_lot_id, container_type, unit_weight_kg, location, quantity_on_hand, unit_price)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING stock_id`,
+			receipt.BatchID, receipt.JarLotID, receipt.ContainerType, receipt.UnitWeightKg, receipt.Location, receipt.Quantity, receipt.UnitPrice)
+	} else if err == nil {
+		_, err = tx.Exec(`
+			UPDATE stock_item
+			SET quantity_on_hand = quantity_on_hand + $1,
+				unit_price = COALESCE($2, unit_price)
+			WHERE stock_id = $3`,
+			receipt.Quantity, receipt.UnitPrice, stockID)
+	}
+	if err != nil {
+		zap.S().Error("Error receiving stock: ", err)
+		return types.StockItem{}, fmt.Errorf("error receiving stock: %w", err)
+	}
+	err = addStockMovement(tx, types.StockMovement{
+		StockID:        stockID,
+		QuantityChange: receipt.Quantity,
+		Reason:         types.ReasonReceipt,
+		MovedBy:        null.NewInt(int64(receivedBy), receivedBy > 0),
+		Notes:          receipt.Notes,
+	})
+	if err != nil {
+		return types.StockItem{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.StockItem{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetStockItem(stockID)
+}
+
+func (db *DB) GetStockItem(id int) (types.StockItem, error) {
+	var item types.StockItem
+	err := db.Get(&item, "SELECT "+stockColumns+stockFrom+" WHERE si.stock_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting stock item: ", err)
+		return types.StockItem{}, fmt.Errorf("error getting stock item: %w", err)
+	}
+	return item, nil
+}
+
+// GetStockItems returns the stock items matching the filter, by location and lot
+func (db *DB) GetStockItems(filter StockFilter) ([]types.StockItem, error) {
+	items := []types.StockItem{}
+	err := db.Select(&items, `
+		SELECT `+stockColumns+stockFrom+`
+		WHERE ($1 = 0 OR si.batch_id = $1)
+			AND ($2 = '' OR si.location = $2)
+			AND ($3 = '' OR si.container_type = $3)
+			AND (NOT $4 OR si.quantity_on_hand > 0)
+		ORDER BY si.location, lot_number, si.stock_id`,
+		filter.BatchID, filter.Location, filter.ContainerType, filter.InStockOnly)
+	if err != nil {
+		zap.S().Error("Error getting stock items: ", err)
+		return nil, fmt.Errorf("error getting stock items: %w", err)
+	}
+	return items, nil
+}
+
+// UpdateStockItem changes where a stock item is stored and its price; quantities
+// only change through receipts, adjustments and sales
+func (db *DB) UpdateStockItem(item types.StockItem) (types.StockItem, error) {
+	result, err := db.Exec(`
+		UPDATE stock_item
+		SET location = $1,
+			unit_price = $2
+		WHERE stock_id = $3`,
+		item.Location, item.UnitPrice, item.StockID)
+	if err != nil {
+		zap.S().Error("Error updating stock item: ", err)
+		return types.StockItem{}, fmt.Errorf("error updating stock item: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return types.StockItem{}, fmt.Errorf("error updating stock item: %w", sql.ErrNoRows)
+	}
+	return db.GetStockItem(item.StockID)
+}
+
+// AdjustStock corrects the quantity on hand of a stock item, never below what is reserved
+func (db *DB) AdjustStock(id int, adjustment types.StockAdjustment, movedBy int) (types.StockItem, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.StockItem{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	item, err := lockStockItem(tx, id)
+	if err != nil {
+		return types.StockItem{}, err
+	}
+	if item.Available+adjustment.QuantityChange < 0 {
+		return types.StockItem{}, fmt.Errorf("%w: %d available", ErrInsufficientStock, item.Available)
+	}
+	_, err = tx.Exec("UPDATE stock_item SET quantity_on_hand = quantity_on_hand + $1 WHERE stock_id = $2", adjustment.QuantityChange, id)
+	if err != nil {
+		zap.S().Error("Error adjusting stock: ", err)
+		return types.StockItem{}, fmt.Errorf("error adjusting stock: %w", err)
+	}
+	err = addStockMovement(tx, types.StockMovement{
+		StockID:        id,
+		QuantityChange: adjustment.QuantityChange,
+		Reason:         types.ReasonAdjustment,
+		MovedBy:        null.NewInt(int64(movedBy), movedBy > 0),
+		Notes:          null.StringFrom(adjustment.Notes),
+	})
+	if err != nil {
+		return types.StockItem{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.StockItem{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetStockItem(id)
+}
+
+// GetStockMovements returns the history of a stock item, the latest first
+func (db *DB) GetStockMovements(stockID int) ([]types.StockMovement, error) {
+	movements := []types.StockMovement{}
+	err := db.Select(&movements, "SELECT * FROM stock_movement WHERE stock_id = $1 ORDER BY moved_at DESC, movement_id DESC", stockID)
+	if err != nil {
+		zap.S().Error("Error getting stock movements: ", err)
+		return nil, fmt.Errorf("error getting stock movements: %w", err)
+	}
+	return movements, nil
+}
+
+// Sales orders
+
+// CreateSalesOrder records an order and reserves its stock
+//
+// Every line must come from a released batch with no contributing apiary under
+// quarantine, and have enough stock available
+func (db *DB) CreateSalesOrder(order types.SalesOrder, createdBy int) (types.SalesOrder, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.SalesOrder{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var orderID int
+	err = tx.Get(&orderID, `
+		INSERT INTO sales_order (customer_id, order_date, status, notes, created_by)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0))
+		RETURNING order_id`,
+		order.CustomerID, order.OrderDate, types.OrderReserved, order.Notes, createdBy)
+	if err != nil {
+		zap.S().Error("Error creating sales order: ", err)
+		return types.SalesOrder{}, fmt.Errorf("error creating sales order: %w", err)
+	}
+
+	// Lock stock in stock_id order, as fulfilment does, so concurrent orders can't deadlock
+	lines := append([]types.OrderLine(nil), order.Lines...)
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].StockID < lines[j].StockID })
+	for _, line := range lines {
+		item, err := lockStockItem(tx, line.StockID)
+		if err != nil {
+			return types.SalesOrder{}, err
+		}
+		if err := checkSellable(tx, item.BatchID); err != nil {
+			return types.SalesOrder{}, err
+		}
+		if line.Quantity > item.Available {
+			return types.SalesOrder{}, fmt.Errorf("%w: stock item %d has %d available", ErrInsufficientStock, item.StockID, item.Available)
+		}
+		if !line.UnitPrice.Valid {
+			if !item.UnitPrice.Valid {
+				return types.SalesOrder{}, fmt.Errorf("%w: stock item %d has no price", ErrNoUnitPrice, item.StockID)
+			}
+			line.UnitPrice = item.UnitPrice
+		}
+		_, err = tx.Exec("UPDATE stock_item SET quantity_reserved = quantity_reserved + $1 WHERE stock_id = $2", line.Quantity, item.StockID)
+		if err != nil {
+			zap.S().Error("Error reserving stock: ", err)
+			return types.SalesOrder{}, fmt.Errorf("error reserving stock: %w", err)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO order_line (order_id, stock_id, quantity, unit_price, tax_rate_percent)
+			VALUES ($1, $2, $3, $4, $5)`,
+			orderID, line.StockID, line.Quantity, line.UnitPrice, line.TaxRatePercent)
+		if err != nil {
+			zap.S().Error("Error adding order line: ", err)
+			return types.SalesOrder{}, fmt.Errorf("error adding order line: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.SalesOrder{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetSalesOrder(orderID)
+}
+
+// GetSalesOrder returns an order with its lines
+func (db *DB) GetSalesOrder(id int) (types.SalesOrder, error) {
+	var order types.SalesOrder
+	if err := db.Get(&order, "SELECT * FROM sales_order WHERE order_id = $1", id); err != nil {
+		zap.S().Error("Error getting sales order: ", err)
+		return types.SalesOrder{}, fmt.Errorf("error getting sales order: %w", err)
+	}
+	order.Lines = []types.OrderLine{}
+	if err := db.Select(&order.Lines, "SELECT * FROM order_line WHERE order_id = $1 ORDER BY line_id", id); err != nil {
+		zap.S().Error("Error getting order lines: ", err)
+		return types.SalesOrder{}, fmt.Errorf("error getting order lines: %w", err)
+	}
+	return order, nil
+}
+
+// GetSalesOrders returns the orders matching the filter without their lines, the latest first
+func (db *DB) GetSalesOrders(filter SalesOrderFilter) ([]types.SalesOrder, error) {
+	orders := []types.SalesOrder{}
+	err := db.Select(&orders, `
+		SELECT * FROM sales_order
+		WHERE ($1 = 0 OR customer_id = $1) AND ($2 = '' OR status = $2)
+		ORDER BY order_date DESC, order_id DESC`,
+		filter.CustomerID, filter.Status)
+	if err != nil {
+		zap.S().Error("Error getting sales orders: ", err)
+		return nil, fmt.Errorf("error getting sales orders: %w", err)
+	}
+	return orders, nil
+}
+
+// lockReservedOrder locks an order, failing with ErrOrderClosed unless it is still reserved
+func lockReservedOrder(tx *sqlx.Tx, id int) (types.SalesOrder, error) {
+	var order types.SalesOrder
+	if err := tx.Get(&order, "SELECT * FROM sales_order WHERE order_id = $1 FOR UPDATE", id); err != nil {
+		zap.S().Error("Error locking sales order: ", err)
+		return types.SalesOrder{}, fmt.Errorf("error locking sales order: %w", err)
+	}
+	if order.Status != types.OrderReserved {
+		return types.SalesOrder{}, fmt.Errorf("%w: order %d is %s", ErrOrderClosed, id, order.Status)
+	}
+	if err := tx.Select(&order.Lines, "SELECT * FROM order_line WHERE order_id = $1 ORDER BY stock_id", id); err != nil {
+		zap.S().Error("Error getting order lines: ", err)
+		return types.SalesOrder{}, fmt.Errorf("error getting order lines: %w", err)
+	}
+	return order, nil
+}
+
+// CancelSalesOrder cancels a reserved order, releasing its stock
+func (db *DB) CancelSalesOrder(id int) (types.SalesOrder, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.SalesOrder{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	order, err := lockReservedOrder(tx, id)
+	if err != nil {
+		return types.SalesOrder{}, err
+	}
+	for _, line := range order.Lines {
+		_, err := tx.Exec("UPDATE stock_item SET quantity_reserved = quantity_reserved - $1 WHERE stock_id = $2", line.Quantity, line.StockID)
+		if err != nil {
+			zap.S().Error("Error releasing stock: ", err)
+			return types.SalesOrder{}, fmt.Errorf("error releasing stock: %w", err)
+		}
+	}
+	if _, err := tx.Exec("UPDATE sales_order SET status = $1 WHERE order_id = $2", types.OrderCancelled, id); err != nil {
+		zap.S().Error("Error cancelling sales order: ", err)
+		return types.SalesOrder{}, fmt.Errorf("error cancelling sales order: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.SalesOrder{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetSalesOrder(id)
+}
+
+// FulfilSalesOrder ships a reserved order, taking its stock out, and issues its invoice
+//
+// Quarantine is checked again since an outbreak may have been declared after the
+// order was taken. Invoices are numbered INV-<year>-<invoice id>.
+func (db *DB) FulfilSalesOrder(id int, fulfilment types.FulfilmentRequest, movedBy int) (types.Invoice, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.Invoice{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	order, err := lockReservedOrder(tx, id)
+	if err != nil {
+		return types.Invoice{}, err
+	}
+	var invoiceID int
+	if err := tx.Get(&invoiceID, "SELECT nextval(pg_get_serial_sequence('invoice', 'invoice_id'))"); err != nil {
+		zap.S().Error("Error numbering invoice: ", err)
+		return types.Invoice{}, fmt.Errorf("error numbering invoice: %w", err)
+	}
+	issueDate := fulfilment.FulfilledDate.Time
+	dueDays := types.DefaultPaymentTermDays
+	if fulfilment.DueDays.Valid {
+		dueDays = int(fulfilment.DueDays.Int64)
+	}
+
+	invoice := types.Invoice{
+		InvoiceID:     invoiceID,
+		OrderID:       id,
+		InvoiceNumber: fmt.Sprintf("INV-%d-%06d", issueDate.Year(), invoiceID),
+		IssueDate:     fulfilment.FulfilledDate,
+		DueDate:       null.TimeFrom(issueDate.AddDate(0, 0, dueDays)),
+	}
+	for _, line := range order.Lines {
+		item, err := lockStockItem(tx, line.StockID)
+		if err != nil {
+			return types.Invoice{}, err
+		}
+		if err := checkSellable(tx, item.BatchID); err != nil {
+			return types.Invoice{}, err
+		}
+		_, err = tx.Exec(`
+			UPDATE stock_item
+			SET quantity_on_hand = quantity_on_hand - $1,
+				quantity_reserved = quantity_reserved - $1
+			WHERE stock_id = $2`,
+			line.Quantity, line.StockID)
+		if err != nil {
+			zap.S().Error("Error taking stock out: ", err)
+			return types.Invoice{}, fmt.Errorf("error taking stock out: %w", err)
+		}
+		err = addStockMovement(tx, types.StockMovement{
+			StockID:        line.StockID,
+			QuantityChange: -line.Quantity,
+			Reason:         types.ReasonSale,
+			OrderID:        null.IntFrom(int64(id)),
+			MovedBy:        null.NewInt(int64(movedBy), movedBy > 0),
+		})
+		if err != nil {
+			return types.Invoice{}, err
+		}
+
+		net := roundMoney(float64(line.Quantity) * line.UnitPrice.Float64)
+		invoiceLine := types.InvoiceLine{
+			InvoiceID:      invoiceID,
+			StockID:        line.StockID,
+			Description:    fmt.Sprintf("Honey, lot %s, %s of %g kg", item.LotNumber, item.ContainerType, item.UnitWeightKg),
+			Quantity:       line.Quantity,
+			UnitPrice:      line.UnitPrice.Float64,
+			TaxRatePercent: line.TaxRatePercent,
+			NetAmount:      net,
+			TaxAmount:      roundMoney(net * line.TaxRatePercent / 100),
+		}
+		invoice.Lines = append(invoice.Lines, invoiceLine)
+		invoice.NetTotal += invoiceLine.NetAmount
+		invoice.TaxTotal += invoiceLine.TaxAmount
+	}
+	invoice.NetTotal = roundMoney(invoice.NetTotal)
+	invoice.TaxTotal = roundMoney(invoice.TaxTotal)
+	invoice.Total = roundMoney(invoice.NetTotal + invoice.TaxTotal)
+
+	_, err = tx.Exec(`
+		INSERT INTO invoice (invoice_id, order_id, invoice_number, issue_date, due_date, net_total, tax_total, total)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		invoice.InvoiceID, invoice.OrderID, invoice.InvoiceNumber, invoice.IssueDate, invoice.DueDate,
+		invoice.NetTotal, invoice.TaxTotal, invoice.Total)
+	if err != nil {
+		zap.S().Error("Error creating invoice: ", err)
+		return types.Invoice{}, fmt.Errorf("error creating invoice: %w", err)
+	}
+	for _, line := range invoice.Lines {
+		_, err := tx.Exec(`
+			INSERT INTO invoice_line (invoice_id, stock_id, description, quantity, unit_price, tax_rate_percent, net_amount, tax_amount)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			line.InvoiceID, line.StockID, line.Description, line.Quantity, line.UnitPrice, line.TaxRatePercent, line.NetAmount, line.TaxAmount)
+		if err != nil {
+			zap.S().Error("Error creating invoice line: ", err)
+			return types.Invoice{}, fmt.Errorf("error creating invoice line: %w", err)
+		}
+	}
+	_, err = tx.Exec("UPDATE sales_order SET status = $1, fulfilled_date = $2 WHERE order_id = $3", types.OrderFulfilled, fulfilment.FulfilledDate, id)
+	if err != nil {
+		zap.S().Error("Error fulfilling sales order: ", err)
+		return types.Invoice{}, fmt.Errorf("error fulfilling sales order: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return types.Invoice{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return db.GetInvoice(invoiceID)
+}
+
+// Invoices
+
+// GetInvoice returns an invoice with its lines and their totals per tax rate
+func (db *DB) GetInvoice(id int) (types.Invoice, error) {
+	var invoice types.Invoice
+	err := db.Get(&invoice, `
+		SELECT `+invoiceColumns+`
+		FROM invoice i
+		JOIN sales_order so ON so.order_id = i.order_id
+		WHERE i.invoice_id = $1`,
+		id)
+	if err != nil {
+		zap.S().Error("Error getting invoice: ", err)
+		return types.Invoice{}, fmt.Errorf("error getting invoice: %w", err)
+	}
+	invoice.Lines = []types.InvoiceLine{}
+	if err := db.Select(&invoice.Lines, "SELECT * FROM invoice_line WHERE invoice_id = $1 ORDER BY invoice_line_id", id); err != nil {
+		zap.S().Error("Error getting invoice lines: ", err)
+		return types.Invoice{}, fmt.Errorf("error getting invoice lines: %w", err)
+	}
+	invoice.TaxLines = []types.TaxLine{}
+	err = db.Select(&invoice.TaxLines, `
+		SELECT tax_rate_percent, SUM(net_amount) AS net_amount, SUM(tax_amount) AS tax_amount
+		FROM invoice_line
+		WHERE invoice_id = $1
+		GROUP BY tax_rate_percent
+		ORDER BY tax_rate_percent`,
+		id)
+	if err != nil {
+		zap.S().Error("Error getting invoice tax lines: ", err)
+		return types.Invoice{}, fmt.Errorf("error getting invoice tax lines: %w", err)
+	}
+	return invoice, nil
+}
+
+// GetInvoices returns the invoices matching the filter without their lines, the latest first
+func (db *DB) GetInvoices(filter InvoiceFilter) ([]types.Invoice, error) {
+	invoices := []types.Invoice{}
+	err := db.Select(&invoices, `
+		SELECT `+invoiceColumns+`
+		FROM invoice i
+		JOIN sales_order so ON so.order_id = i.order_id
+		WHERE ($1 = 0 OR so.customer_id = $1)
+			AND (NOT $2 OR i.paid_date IS NULL)
+			AND ($3::date IS NULL OR i.issue_date >= $3)
+			AND ($4::date IS NULL OR i.issue_date <= $4)
+		ORDER BY i.issue_date DESC, i.invoice_id DESC`,
+		filter.CustomerID, filter.UnpaidOnly, filter.From, filter.To)
+	if err != nil {
+		zap.S().Error("Error getting invoices: ", err)
+		return nil, fmt.Errorf("error getting invoices: %w", err)
+	}
+	return invoices, nil
+}
+
+// PayInvoice records the payment of an unpaid invoice
+func (db *DB) PayInvoice(id int, paidDate null.Time) (types.Invoice, error) {
+	invoice, err := db.GetInvoice(id)
+	if err != nil {
+		return types.Invoice{}, err
+	}
+	if invoice.PaidDate.Valid {
+		return types.Invoice{}, ErrInvoicePaid
+	}
+	result, err := db.Exec("UPDATE invoice SET paid_date = $1 WHERE invoice_id = $2 AND paid_date IS NULL", paidDate, id)
+	if err != nil {
+		zap.S().Error("Error paying invoice: ", err)
+		return types.Invoice{}, fmt.Errorf("error paying invoice: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return types.Invoice{}, ErrInvoicePaid
+	}
+	return db.GetInvoice(id)
+}
+
+// GetApiaryProfits returns the honey harvested, the sales revenue and the expenses
+// of every apiary between from and to
+//
+// Revenue is the invoiced amount before tax, shared between the apiaries that
+// contributed to each batch by weight, plus the price of pollination contracts
+// completed in the period, shared by the number of hives each apiary sent. Feed
+// is the only expense recorded so far.
+func (db *DB) GetApiaryProfits(from, to time.Time) ([]types.ApiaryProfit, error) {
+	profits := []types.ApiaryProfit{}
+	err := db.Select(&profits, `
+		SELECT p.*, p.revenue - p.expenses AS profit
+		FROM (
+			SELECT a.apiary_id, a.location,
+				(SELECT COALESCE(SUM(hh.quantity), 0) FROM honey_harvest hh
+					WHERE hive_apiary_on(hh.hive_id, hh.harvest_date) = a.apiary_id
+					AND hh.harvest_date BETWEEN $1 AND $2) AS honey_kg,
+				apiary_revenue(a.apiary_id, $1, $2) AS revenue,
+				fu.cost AS expenses
+			FROM apiary a
+			CROSS JOIN LATERAL apiary_feed_usage(a.apiary_id, $1, $2) fu
+		) p
+		ORDER BY profit DESC, p.apiary_id`,
+		from, to)
+	if err != nil {
+		zap.S().Error("Error getting apiary profits: ", err)
+		return nil, fmt.Errorf("error getting apiary profits: %w", err)
+	}
+	return profits, nil
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// WeightEventFilter narrows down the events returned by GetHiveWeightEvents
+type WeightEventFilter struct {
+	HiveID   int
+	ApiaryID int
+	Kind     types.WeightEventKind
+	From     null.Time
+	To       null.Time
+}
+
+// GetWeightReadings gets the hive weight sensor readings taken from from until
+// before to, by hive and in time order
+func (db *DB) GetWeightReadings(from, to time.Time) ([]types.WeightReading, error) {
+	readings := []types.WeightReading{}
+	err := db.Select(&readings, "SELECT * FROM hive_weight_readings($1, $2)", from, to)
+	if err != nil {
+		zap.S().Error("Error getting weight readings: ", err)
+		return nil, fmt.Errorf("error getting weight readings: %w", err)
+	}
+	return readings, nil
+}
+
+// SaveScaleAnalysis saves the daily weight summaries of hives, replacing those
+// of the same hive and day, and the events read from them, in one transaction
+//
+// Events already recorded for the hive, kind and date are kept as they are;
+// only the newly recorded events are returned, tied to the apiary the hive stood
+// at that day.
+func (db *DB) SaveScaleAnalysis(days []types.HiveWeightDay, events []types.HiveWeightEvent) ([]types.HiveWeightEvent, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, day := range days {
+		_, err := tx.Exec(`
+			INSERT INTO hive_weight_day (hive_id, date, opening_kg, closing_kg, min_kg, max_kg, net_gain_kg, max_drop_kg, max_drop_at, readings)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (hive_id, date) DO UPDATE SET
+				opening_kg = EXCLUDED.opening_kg,
+				closing_kg = EXCLUDED.closing_kg,
+				min_kg = EXCLUDED.min_kg,
+				max_kg = EXCLUDED.max_kg,
+				net_gain_kg = EXCLUDED.net_gain_kg,
+				max_drop_kg = EXCLUDED.max_drop_kg,
+				max_drop_at = EXCLUDED.max_drop_at,
+				readings = EXCLUDED.readings`,
+			day.HiveID, day.Date, day.OpeningKg, day.ClosingKg, day.MinKg, day.MaxKg, day.NetGainKg, day.MaxDropKg, day.MaxDropAt, day.Readings)
+		if err != nil {
+			zap.S().Error("Error saving hive weight day: ", err)
+			return nil, fmt.Errorf("error saving hive weight day: %w", err)
+		}
+	}
+
+	recorded := []types.HiveWeightEvent{}
+	for _, event := range events {
+		var inserted []types.HiveWeightEvent
+		err := tx.Select(&inserted, `
+			INSERT INTO hive_weight_event (hive_id, apiary_id, kind, date, occurred_at, change_kg)
+			VALUES ($1, hive_apiary_on($1, $3), $2, $3, $4, $5)
+			ON CONFLICT (hive_id, kind, date) DO NOTHING
+			RETURNING *`,
+			event.HiveID, event.Kind, event.Date, event.OccurredAt, event.ChangeKg)
+		if err != nil {
+			zap.S().Error("Error saving hive weight event: ", err)
+			return nil, fmt.Errorf("error saving hive weight event: %w", err)
+		}
+		recorded = append(recorded, inserted...)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return recorded, nil
+}
+
+// SetWeightEventIncident ties a weight event to the incident raised for it
+func (db *DB) SetWeightEventIncident(eventID, incidentID int) error {
+	_, err := db.Exec("UPDATE hive_weight_event SET incident_id = $1 WHERE event_id = $2", incidentID, eventID)
+	if err != nil {
+		zap.S().Error("Error setting weight event incident: ", err)
+		return fmt.Errorf("error setting weight event incident: %w", err)
+	}
+	return nil
+}
+
+// GetHiveWeightDays gets a hive's daily weight summaries between from and to, oldest first
+func (db *DB) GetHiveWeightDays(hiveID int, from, to null.Time) ([]types.HiveWeightDay, error) {
+	days := []types.HiveWeightDay{}
+	err := db.Select(&days, `
+		SELECT * FROM hive_weight_day
+		WHERE hive_id = $1
+		AND ($2::date IS NULL OR date >= $2)
+		AND ($3::date IS NULL OR date <= $3)
+		ORDER BY date`,
+		hiveID, from, to)
+	if err != nil {
+		zap.S().Error("Error getting hive weight days: ", err)
+		return nil, fmt.Errorf("error getting hive weight days: %w", err)
+	}
+	return days, nil
+}
+
+// GetApiaryWeightDays gets the daily weight summaries of the hives standing at an
+// apiary on each day between from and to, by hive and oldest first
+func (db *DB) GetApiaryWeightDays(apiaryID int, from, to null.Time) ([]types.HiveWeightDay, error) {
+	days := []types.HiveWeightDay{}
+	err := db.Select(&days, `
+		SELECT * FROM hive_weight_day
+		WHERE hive_apiary_on(hive_id, date) = $1
+		AND ($2::date IS NULL OR date >= $2)
+		AND ($3::date IS NULL OR date <= $3)
+		ORDER BY hive_id, date`,
+		apiaryID, from, to)
+	if err != nil {
+		zap.S().Error("Error getting apiary weight days: ", err)
+		return nil, fmt.Errorf("error getting apiary weight days: %w", err)
+	}
+	return days, nil
+}
+
+// GetHiveWeightEvents gets the weight events matching filter, newest first
+func (db *DB) GetHiveWeightEvents(filter WeightEventFilter) ([]types.HiveWeightEvent, error) {
+	events := []types.HiveWeightEvent{}
+	err := db.Select(&events, `
+		SELECT * FROM hive_weight_event
+		WHERE ($1 = 0 OR hive_id = $1)
+		AND ($2 = 0 OR apiary_id = $2)
+		AND ($3 = '' OR kind = $3)
+		AND ($4::date IS NULL OR date >= $4)
+		AND ($5::date IS NULL OR date <= $5)
+		ORDER BY date DESC, event_id DESC`,
+		filter.HiveID, filter.ApiaryID, filter.Kind, filter.From, filter.To)
+	if err != nil {
+		zap.S().Error("Error getting hive weight events: ", err)
+		return nil, fmt.Errorf("error getting hive weight events: %w", err)
+	}
+	return events, nil
+}
+
+// GetScaleRecipients returns the managers of the apiaries, who are told about nectar flows
+func (db *DB) GetScaleRecipients(apiaryIDs []int) ([]types.NotificationRecipient, error) {
+	recipients := []types.NotificationRecipient{}
+	err := db.Select(&recipients, `
+		SELECT `+recipientColumns+`
+		FROM "user" u
+		LEFT JOIN notification_preference np ON np.user_id = u.user_id
+		WHERE u.user_id IN (SELECT a.manager_id FROM apiary a WHERE a.apiary_id = ANY($1))`,
+		pq.Array(apiaryIDs))
+	if err != nil {
+		zap.S().Error("Error getting scale recipients: ", err)
+		return nil, fmt.Errorf("error getting scale recipients: %w", err)
+	}
+	return recipients, nil
+}
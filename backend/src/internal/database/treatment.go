@@ -0,0 +1,206 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/guregu/null"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// applicationColumns selects a treatment application with its withdrawal end and efficacy
+const applicationColumns = `
+	ta.*,
+	treatment_withdrawal_end(ta.application_id) AS withdrawal_end,
+	CASE WHEN ta.mite_count_before > 0 AND ta.mite_count_after IS NOT NULL
+		THEN ROUND(100.0 * (ta.mite_count_before - ta.mite_count_after) / ta.mite_count_before, 1)
+	END AS efficacy_percent`
+
+// TreatmentFilter narrows down the applications returned by GetTreatmentApplications
+type TreatmentFilter struct {
+	HiveID    int
+	ProductID int
+	From      null.Time
+	To        null.Time
+}
+
+func (db *DB) GetTreatmentProduct(id int) (types.TreatmentProduct, error) {
+	var product types.TreatmentProduct
+	err := db.Get(&product, "SELECT * FROM treatment_product WHERE product_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting treatment product: ", err)
+		return types.TreatmentProduct{}, fmt.Errorf("error getting treatment product: %w", err)
+	}
+	return product, nil
+}
+
+func (db *DB) CreateTreatmentProduct(product types.TreatmentProduct) (types.TreatmentProduct, error) {
+	var createdProduct types.TreatmentProduct
+	err := db.Get(&createdProduct, `
+		INSERT INTO treatment_product (name, active_substance, dosage, withdrawal_days, target, notes, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING *`,
+		product.Name, product.ActiveSubstance, product.Dosage, product.WithdrawalDays, product.Target, product.Notes, product.Active)
+	if err != nil {
+		zap.S().Error("Error creating treatment product: ", err)
+		return types.TreatmentProduct{}, fmt.Errorf("error creating treatment product: %w", err)
+	}
+	return createdProduct, nil
+}
+
+func (db *DB) UpdateTreatmentProduct(product types.TreatmentProduct) (types.TreatmentProduct, error) {
+	var updatedProduct types.TreatmentProduct
+	err := db.Get(&updatedProduct, `
+		UPDATE treatment_product
+		SET name = $1,
+			active_substance = $2,
+			dosage = $3,
+			withdrawal_days = $4,
+			target = $5,
+			notes = $6,
+			active = $7
+		WHERE product_id = $8
+		RETURNING *`,
+		product.Name, product.ActiveSubstance, product.Dosage, product.WithdrawalDays, product.Target, product.Notes, product.Active, product.ProductID)
+	if err != nil {
+		zap.S().Error("Error updating treatment product: ", err)
+		return types.TreatmentProduct{}, fmt.Errorf("error updating treatment product: %w", err)
+	}
+	return updatedProduct, nil
+}
+
+func (db *DB) DeleteTreatmentProduct(id int) error {
+	_, err := db.Exec("DELETE FROM treatment_product WHERE product_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error deleting treatment product: ", err)
+		return fmt.Errorf("error deleting treatment product: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetAllTreatmentProducts() ([]types.TreatmentProduct, error) {
+	products := []types.TreatmentProduct{}
+	err := db.Select(&products, "SELECT * FROM treatment_product ORDER BY name")
+	if err != nil {
+		zap.S().Error("Error getting all treatment products: ", err)
+		return []types.TreatmentProduct{}, fmt.Errorf("error getting all treatment products: %w", err)
+	}
+	return products, nil
+}
+
+func (db *DB) GetTreatmentApplication(id int) (types.TreatmentApplication, error) {
+	var application types.TreatmentApplication
+	err := db.Get(&application, "SELECT "+applicationColumns+" FROM treatment_application ta WHERE ta.application_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting treatment application: ", err)
+		return types.TreatmentApplication{}, fmt.Errorf("error getting treatment application: %w", err)
+	}
+	return application, nil
+}
+
+func (db *DB) CreateTreatmentApplication(application types.TreatmentApplication) (types.TreatmentApplication, error) {
+	var applicationID int
+	err := db.Get(&applicationID, `
+		INSERT INTO treatment_application (
+			product_id, hive_id, community_id, start_date, end_date, dosage_applied,
+			mite_count_before, mite_count_after, applied_by, notes
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING application_id`,
+		application.ProductID, application.HiveID, application.CommunityID, application.StartDate, application.EndDate, application.DosageApplied,
+		application.MiteCountBefore, application.MiteCountAfter, application.AppliedBy, application.Notes)
+	if err != nil {
+		zap.S().Error("Error creating treatment application: ", err)
+		return types.TreatmentApplication{}, fmt.Errorf("error creating treatment application: %w", err)
+	}
+	return db.GetTreatmentApplication(applicationID)
+}
+
+// UpdateTreatmentApplication updates an application, e.g. to end it or record the mite count after
+func (db *DB) UpdateTreatmentApplication(application types.TreatmentApplication) (types.TreatmentApplication, error) {
+	var applicationID int
+	err := db.Get(&applicationID, `
+		UPDATE treatment_application
+		SET product_id = $1,
+			hive_id = $2,
+			community_id = $3,
+			start_date = $4,
+			end_date = $5,
+			dosage_applied = $6,
+			mite_count_before = $7,
+			mite_count_after = $8,
+			notes = $9
+		WHERE application_id = $10
+		RETURNING application_id`,
+		application.ProductID, application.HiveID, application.CommunityID, application.StartDate, application.EndDate, application.DosageApplied,
+		application.MiteCountBefore, application.MiteCountAfter, application.Notes, application.ApplicationID)
+	if err != nil {
+		zap.S().Error("Error updating treatment application: ", err)
+		return types.TreatmentApplication{}, fmt.Errorf("error updating treatment application: %w", err)
+	}
+	return db.GetTreatmentApplication(applicationID)
+}
+
+func (db *DB) DeleteTreatmentApplication(id int) error {
+	_, err := db.Exec("DELETE FROM treatment_application WHERE application_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error deleting treatment application: ", err)
+		return fmt.Errorf("error deleting treatment application: %w", err)
+	}
+	return nil
+}
+
+// GetTreatmentApplications returns the applications matching the filter, the latest first
+func (db *DB) GetTreatmentApplications(filter TreatmentFilter) ([]types.TreatmentApplication, error) {
+	applications := []types.TreatmentApplication{}
+	err := db.Select(&applications, `
+		SELECT `+applicationColumns+`
+		FROM treatment_application ta
+		WHERE ($1 = 0 OR ta.hive_id = $1)
+			AND ($2 = 0 OR ta.product_id = $2)
+			AND ($3::date IS NULL OR COALESCE(ta.end_date, CURRENT_DATE) >= $3)
+			AND ($4::date IS NULL OR ta.start_date <= $4)
+		ORDER BY ta.start_date DESC, ta.application_id DESC`,
+		filter.HiveID, filter.ProductID, filter.From, filter.To)
+	if err != nil {
+		zap.S().Error("Error getting treatment applications: ", err)
+		return nil, fmt.Errorf("error getting treatment applications: %w", err)
+	}
+	return applications, nil
+}
+
+// GetWithdrawalApplications returns the applications whose withdrawal period covers the hive on date
+func (db *DB) GetWithdrawalApplications(hiveID int, date null.Time) ([]types.TreatmentApplication, error) {
+	applications := []types.TreatmentApplication{}
+	err := db.Select(&applications, `
+		SELECT `+applicationColumns+`
+		FROM treatment_application ta
+		WHERE ta.application_id IN (SELECT hive_withdrawal_applications($1, $2))
+		ORDER BY ta.start_date`,
+		hiveID, date)
+	if err != nil {
+		zap.S().Error("Error getting withdrawal applications: ", err)
+		return nil, fmt.Errorf("error getting withdrawal applications: %w", err)
+	}
+	return applications, nil
+}
+
+// GetTreatmentEfficacy summarizes the mite count reduction of each product
+func (db *DB) GetTreatmentEfficacy() ([]types.TreatmentEfficacy, error) {
+	efficacy := []types.TreatmentEfficacy{}
+	err := db.Select(&efficacy, `
+		SELECT tp.product_id, tp.name,
+			COUNT(ta.application_id) AS applications,
+			COUNT(ta.application_id) FILTER (WHERE ta.mite_count_before > 0 AND ta.mite_count_after IS NOT NULL) AS measured,
+			ROUND(AVG(100.0 * (ta.mite_count_before - ta.mite_count_after) / ta.mite_count_before)
+				FILTER (WHERE ta.mite_count_before > 0 AND ta.mite_count_after IS NOT NULL), 1) AS average_efficacy
+		FROM treatment_product tp
+		LEFT JOIN treatment_application ta ON ta.product_id = tp.product_id
+		GROUP BY tp.product_id, tp.name
+		ORDER BY tp.name`)
+	if err != nil {
+		zap.S().Error("Error getting treatment efficacy: ", err)
+		return nil, fmt.Errorf("error getting treatment efficacy: %w", err)
+	}
+	return efficacy, nil
+}
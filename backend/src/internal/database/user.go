@@ -230,3 +230,84 @@ func (db *DB) GetAllWorkerGroups() ([]types.WorkerGroup, error) {
 	}
 	return groups, nil
 }
+
+// GetProfile returns the self-service view of a user, including an unconfirmed email change
+func (db *DB) GetProfile(userID int) (types.Profile, error) {
+	var profile types.Profile
+	err := db.Get(&profile, `
+		SELECT u.user_id, u.username, u.full_name, u.role, u.email, u.last_login,
+			(
+				SELECT ecr.new_email FROM email_change_request ecr
+				WHERE ecr.user_id = u.user_id AND ecr.used_at IS NULL AND ecr.expires_at > CURRENT_TIMESTAMP
+				ORDER BY ecr.created_at DESC
+				LIMIT 1
+			) AS pending_email
+		FROM "user" u
+		WHERE u.user_id = $1`,
+		userID)
+	if err != nil {
+		zap.S().Error("Error getting profile: ", err)
+		return types.Profile{}, fmt.Errorf("error getting profile: %w", err)
+	}
+	return profile, nil
+}
+
+// UpdateUserFullName changes only the full name of a user
+func (db *DB) UpdateUserFullName(userID int, fullName string) error {
+	_, err := db.Exec("UPDATE \"user\" SET full_name = $1 WHERE user_id = $2", fullName, userID)
+	if err != nil {
+		zap.S().Error("Error updating user full name: ", err)
+		return fmt.Errorf("error updating user full name: %w", err)
+	}
+	return nil
+}
+
+// GetApiariesByManager retrieves all apiaries managed by a user
+func (db *DB) GetApiariesByManager(managerID int) ([]types.Apiary, error) {
+	var apiaries []types.Apiary
+	err := db.Select(&apiaries, "SELECT * FROM apiary WHERE manager_id = $1", managerID)
+	if err != nil {
+		zap.S().Error("Error getting apiaries by manager: ", err)
+		return nil, fmt.Errorf("error getting apiaries by manager: %w", err)
+	}
+	return apiaries, nil
+}
+
+// IsManagedWorker reports whether a worker belongs to one of the manager's worker groups
+func (db *DB) IsManagedWorker(workerID, managerID int) (bool, error) {
+	var managed bool
+	err := db.Get(&managed, `
+		SELECT EXISTS (
+			SELECT 1 FROM worker_group_member wgm
+			JOIN worker_group wg ON wg.group_id = wgm.group_id
+			WHERE wgm.worker_id = $1 AND wg.manager_id = $2
+		)`,
+		workerID, managerID)
+	if err != nil {
+		zap.S().Error("Error checking managed worker: ", err)
+		return false, fmt.Errorf("error checking managed worker: %w", err)
+	}
+	return managed, nil
+}
+
+// IsGroupedWorker reports whether a worker belongs to any worker group
+func (db *DB) IsGroupedWorker(workerID int) (bool, error) {
+	var grouped bool
+	err := db.Get(&grouped, "SELECT EXISTS (SELECT 1 FROM worker_group_member WHERE worker_id = $1)", workerID)
+	if err != nil {
+		zap.S().Error("Error checking grouped worker: ", err)
+		return false, fmt.Errorf("error checking grouped worker: %w", err)
+	}
+	return grouped, nil
+}
+
+// CountAdmins returns the number of users with the ADMIN role
+func (db *DB) CountAdmins() (int, error) {
+	var count int
+	err := db.Get(&count, "SELECT COUNT(*) FROM \"user\" WHERE role = 'ADMIN'")
+	if err != nil {
+		zap.S().Error("Error counting admins: ", err)
+		return 0, fmt.Errorf("error counting admins: %w", err)
+	}
+	return count, nil
+}
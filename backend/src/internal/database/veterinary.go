@@ -3,6 +3,8 @@ package database
 import (
 	"fmt"
 
+	"github.com/guregu/null"
+
 	types "github.com/orientallines/beesbiz/internal/types/db"
 	"go.uber.org/zap"
 )
@@ -66,24 +68,71 @@ func (db *DB) GetVeterinaryRecord(id int) (types.VeterinaryRecord, error) {
 	return record, nil
 }
 
-func (db *DB) CreateVeterinaryRecord(record types.VeterinaryRecord) (types.VeterinaryRecord, error) {
+// CreateVeterinaryRecord creates a veterinary record, declaring an outbreak in the
+// same transaction when it diagnoses a notifiable disease. The outbreak is zero
+// when none was declared.
+func (db *DB) CreateVeterinaryRecord(record types.VeterinaryRecord, declaredBy int) (types.VeterinaryRecord, types.Outbreak, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.VeterinaryRecord{}, types.Outbreak{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	var createdRecord types.VeterinaryRecord
-	err := db.Get(&createdRecord, "INSERT INTO veterinary_record (passport_id, record_date, description, treatment) VALUES ($1, $2, $3, $4) RETURNING *", record.PassportID, record.RecordDate, record.Description, record.Treatment)
+	err = tx.Get(&createdRecord, "INSERT INTO veterinary_record (passport_id, record_date, description, treatment, application_id, disease_id) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *", record.PassportID, record.RecordDate, record.Description, record.Treatment, record.ApplicationID, record.DiseaseID)
 	if err != nil {
 		zap.S().Error("Error creating veterinary record: ", err)
-		return types.VeterinaryRecord{}, fmt.Errorf("error creating veterinary record: %w", err)
+		return types.VeterinaryRecord{}, types.Outbreak{}, fmt.Errorf("error creating veterinary record: %w", err)
 	}
-	return createdRecord, nil
+	outbreak, err := declareRecordOutbreak(tx, createdRecord, declaredBy)
+	if err != nil {
+		return types.VeterinaryRecord{}, types.Outbreak{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing veterinary record: ", err)
+		return types.VeterinaryRecord{}, types.Outbreak{}, fmt.Errorf("error committing veterinary record: %w", err)
+	}
+	return createdRecord, outbreak, nil
 }
 
-func (db *DB) UpdateVeterinaryRecord(record types.VeterinaryRecord) (types.VeterinaryRecord, error) {
+// UpdateVeterinaryRecord updates a veterinary record. Changing its disease to a
+// notifiable one declares an outbreak in the same transaction, as on creation.
+func (db *DB) UpdateVeterinaryRecord(record types.VeterinaryRecord, declaredBy int) (types.VeterinaryRecord, types.Outbreak, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return types.VeterinaryRecord{}, types.Outbreak{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previousDiseaseID null.Int
+	if err := tx.Get(&previousDiseaseID, "SELECT disease_id FROM veterinary_record WHERE record_id = $1 FOR UPDATE", record.RecordID); err != nil {
+		zap.S().Error("Error getting veterinary record: ", err)
+		return types.VeterinaryRecord{}, types.Outbreak{}, fmt.Errorf("error getting veterinary record: %w", err)
+	}
+
 	var updatedRecord types.VeterinaryRecord
-	err := db.Get(&updatedRecord, "UPDATE veterinary_record SET passport_id = $1, record_date = $2, description = $3, treatment = $4 WHERE record_id = $5 RETURNING *", record.PassportID, record.RecordDate, record.Description, record.Treatment, record.RecordID)
+	err = tx.Get(&updatedRecord, "UPDATE veterinary_record SET passport_id = $1, record_date = $2, description = $3, treatment = $4, application_id = $5, disease_id = $6 WHERE record_id = $7 RETURNING *", record.PassportID, record.RecordDate, record.Description, record.Treatment, record.ApplicationID, record.DiseaseID, record.RecordID)
 	if err != nil {
 		zap.S().Error("Error updating veterinary record: ", err)
-		return types.VeterinaryRecord{}, fmt.Errorf("error updating veterinary record: %w", err)
+		return types.VeterinaryRecord{}, types.Outbreak{}, fmt.Errorf("error updating veterinary record: %w", err)
+	}
+	// Other corrections leave the outbreak declared for the original diagnosis alone
+	var outbreak types.Outbreak
+	if updatedRecord.DiseaseID != previousDiseaseID {
+		outbreak, err = declareRecordOutbreak(tx, updatedRecord, declaredBy)
+		if err != nil {
+			return types.VeterinaryRecord{}, types.Outbreak{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing veterinary record: ", err)
+		return types.VeterinaryRecord{}, types.Outbreak{}, fmt.Errorf("error committing veterinary record: %w", err)
 	}
-	return updatedRecord, nil
+	return updatedRecord, outbreak, nil
 }
 
 func (db *DB) DeleteVeterinaryRecord(id int) error {
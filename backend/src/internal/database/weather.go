@@ -1,13 +1,49 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/guregu/null"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 
 	types "github.com/orientallines/beesbiz/internal/types/db"
 )
 
+// ErrWeatherExists is returned when moving a reading onto the region, date and hour of another
+var ErrWeatherExists = errors.New("a reading for this region, date and hour already exists")
+
+// WeatherFilter narrows down the readings returned by GetAllWeatherData
+//
+// Hourly keeps only hourly readings when true and only daily ones when false
+type WeatherFilter struct {
+	RegionID int
+	From     null.Time
+	To       null.Time
+	Hourly   null.Bool
+}
+
+// upsertWeather saves a reading, replacing the region's reading for the same date and hour
+const upsertWeather = `
+	INSERT INTO weather_data (region_id, date, hour, temperature, humidity, wind_speed, precipitation, source)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (region_id, date, COALESCE(hour, -1)) DO UPDATE SET
+		temperature = EXCLUDED.temperature,
+		humidity = EXCLUDED.humidity,
+		wind_speed = EXCLUDED.wind_speed,
+		precipitation = EXCLUDED.precipitation,
+		source = EXCLUDED.source
+	RETURNING *`
+
+// weatherSource defaults the source of a reading to manual entry
+func weatherSource(weather types.WeatherData) types.WeatherSource {
+	if weather.Source == "" {
+		return types.WeatherSourceManual
+	}
+	return weather.Source
+}
+
 // GetWeatherData gets weather data by ID
 //
 // It returns the weather data and an error
@@ -21,12 +57,13 @@ func (db *DB) GetWeatherData(id int) (types.WeatherData, error) {
 	return weather, nil
 }
 
-// CreateWeatherData creates a new weather data
+// CreateWeatherData creates a new weather data, replacing the region's reading
+// for the same date and hour
 //
 // It returns the created weather data and an error
 func (db *DB) CreateWeatherData(weather types.WeatherData) (types.WeatherData, error) {
 	var createdWeather types.WeatherData
-	err := db.Get(&createdWeather, "INSERT INTO weather_data (region_id, date, temperature, humidity, wind_speed, precipitation) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *", weather.RegionID, weather.Date, weather.Temperature, weather.Humidity, weather.WindSpeed, weather.Precipitation)
+	err := db.Get(&createdWeather, upsertWeather, weather.RegionID, weather.Date, weather.Hour, weather.Temperature, weather.Humidity, weather.WindSpeed, weather.Precipitation, weatherSource(weather))
 	if err != nil {
 		zap.S().Error("Error creating weather data: ", err)
 		return types.WeatherData{}, fmt.Errorf("error creating weather data: %w", err)
@@ -39,7 +76,11 @@ func (db *DB) CreateWeatherData(weather types.WeatherData) (types.WeatherData, e
 // It returns the updated weather data and an error
 func (db *DB) UpdateWeatherData(weather types.WeatherData) (types.WeatherData, error) {
 	var updatedWeather types.WeatherData
-	err := db.Get(&updatedWeather, "UPDATE weather_data SET region_id = $1, date = $2, temperature = $3, humidity = $4, wind_speed = $5, precipitation = $6 WHERE weather_id = $7 RETURNING *", weather.RegionID, weather.Date, weather.Temperature, weather.Humidity, weather.WindSpeed, weather.Precipitation, weather.WeatherID)
+	err := db.Get(&updatedWeather, "UPDATE weather_data SET region_id = $1, date = $2, hour = $3, temperature = $4, humidity = $5, wind_speed = $6, precipitation = $7, source = $8 WHERE weather_id = $9 RETURNING *", weather.RegionID, weather.Date, weather.Hour, weather.Temperature, weather.Humidity, weather.WindSpeed, weather.Precipitation, weatherSource(weather), weather.WeatherID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return types.WeatherData{}, ErrWeatherExists
+	}
 	if err != nil {
 		zap.S().Error("Error updating weather data: ", err)
 		return types.WeatherData{}, fmt.Errorf("error updating weather data: %w", err)
@@ -59,15 +100,199 @@ func (db *DB) DeleteWeatherData(id int) error {
 	return nil
 }
 
-// GetAllWeatherData gets all weather data
-// 
+// GetAllWeatherData gets the weather data matching filter, oldest first
+//
 // It returns a list of weather data and an error
-func (db *DB) GetAllWeatherData() ([]types.WeatherData, error) {
-	var weatherDataList []types.WeatherData
-	err := db.Select(&weatherDataList, "SELECT * FROM weather_data")
+func (db *DB) GetAllWeatherData(filter WeatherFilter) ([]types.WeatherData, error) {
+	weatherDataList := []types.WeatherData{}
+	err := db.Select(&weatherDataList, `
+		SELECT * FROM weather_data
+		WHERE ($1 = 0 OR region_id = $1)
+		AND ($2::date IS NULL OR date >= $2)
+		AND ($3::date IS NULL OR date <= $3)
+		AND ($4::boolean IS NULL OR (hour IS NOT NULL) = $4)
+		ORDER BY date, hour NULLS FIRST, region_id`,
+		filter.RegionID, filter.From, filter.To, filter.Hourly)
 	if err != nil {
 		zap.S().Error("Error getting all weather data: ", err)
 		return []types.WeatherData{}, fmt.Errorf("error getting all weather data: %w", err)
 	}
 	return weatherDataList, nil
 }
+
+// SaveWeatherReadings saves imported readings in one transaction, each replacing
+// the region's reading for the same date and hour
+//
+// It returns the saved readings and an error
+func (db *DB) SaveWeatherReadings(readings []types.WeatherData) ([]types.WeatherData, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.S().Error("Error starting transaction: ", err)
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	saved := make([]types.WeatherData, 0, len(readings))
+	for _, weather := range readings {
+		var savedWeather types.WeatherData
+		err := tx.Get(&savedWeather, upsertWeather, weather.RegionID, weather.Date, weather.Hour, weather.Temperature, weather.Humidity, weather.WindSpeed, weather.Precipitation, weatherSource(weather))
+		if err != nil {
+			zap.S().Error("Error saving weather reading: ", err)
+			return nil, fmt.Errorf("error saving weather reading: %w", err)
+		}
+		saved = append(saved, savedWeather)
+	}
+
+	if err := tx.Commit(); err != nil {
+		zap.S().Error("Error committing transaction: ", err)
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return saved, nil
+}
+
+// GetDailyWeather gets a region's weather per day between from and to, from its
+// daily readings or aggregated from its hourly ones
+//
+// It returns a list of daily weather and an error
+func (db *DB) GetDailyWeather(regionID int, from, to null.Time) ([]types.DailyWeather, error) {
+	days := []types.DailyWeather{}
+	err := db.Select(&days, "SELECT * FROM region_daily_weather($1, $2, $3)", regionID, from, to)
+	if err != nil {
+		zap.S().Error("Error getting daily weather: ", err)
+		return nil, fmt.Errorf("error getting daily weather: %w", err)
+	}
+	return days, nil
+}
+
+// GetWeatherProduction gets a region's daily weather and the honey harvested in
+// it and its subregions for every day between from and to
+//
+// It returns a list of days and an error
+func (db *DB) GetWeatherProduction(regionID int, from, to null.Time) ([]types.WeatherProductionDay, error) {
+	days := []types.WeatherProductionDay{}
+	err := db.Select(&days, "SELECT * FROM region_weather_production($1, $2, $3)", regionID, from, to)
+	if err != nil {
+		zap.S().Error("Error getting weather production: ", err)
+		return nil, fmt.Errorf("error getting weather production: %w", err)
+	}
+	return days, nil
+}
+
+// GetWeatherStation gets a weather station by ID
+//
+// It returns the weather station and an error
+func (db *DB) GetWeatherStation(id int) (types.WeatherStation, error) {
+	var station types.WeatherStation
+	err := db.Get(&station, "SELECT * FROM weather_station WHERE station_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error getting weather station: ", err)
+		return types.WeatherStation{}, fmt.Errorf("error getting weather station: %w", err)
+	}
+	return station, nil
+}
+
+// GetWeatherStationByToken gets the weather station a push token hash belongs to
+//
+// It returns the weather station and an error
+func (db *DB) GetWeatherStationByToken(tokenHash string) (types.WeatherStation, error) {
+	var station types.WeatherStation
+	err := db.Get(&station, "SELECT * FROM weather_station WHERE token_hash = $1", tokenHash)
+	if err != nil {
+		return types.WeatherStation{}, fmt.Errorf("error getting weather station by token: %w", err)
+	}
+	return station, nil
+}
+
+// GetWeatherStations gets all weather stations
+//
+// It returns a list of weather stations and an error
+func (db *DB) GetWeatherStations() ([]types.WeatherStation, error) {
+	stations := []types.WeatherStation{}
+	err := db.Select(&stations, "SELECT * FROM weather_station ORDER BY code")
+	if err != nil {
+		zap.S().Error("Error getting weather stations: ", err)
+		return nil, fmt.Errorf("error getting weather stations: %w", err)
+	}
+	return stations, nil
+}
+
+// GetWeatherStationRegions maps station codes to the regions of their stations,
+// leaving out unknown codes
+//
+// It returns the map and an error
+func (db *DB) GetWeatherStationRegions(codes []string) (map[string]int, error) {
+	var stations []types.WeatherStation
+	err := db.Select(&stations, "SELECT * FROM weather_station WHERE code = ANY($1)", pq.Array(codes))
+	if err != nil {
+		zap.S().Error("Error getting weather station regions: ", err)
+		return nil, fmt.Errorf("error getting weather station regions: %w", err)
+	}
+	regions := make(map[string]int, len(stations))
+	for _, station := range stations {
+		regions[station.Code] = station.RegionID
+	}
+	return regions, nil
+}
+
+// CreateWeatherStation registers a weather station with the hash of its push token
+//
+// It returns the created weather station and an error
+func (db *DB) CreateWeatherStation(station types.WeatherStation) (types.WeatherStation, error) {
+	var createdStation types.WeatherStation
+	err := db.Get(&createdStation, "INSERT INTO weather_station (code, name, region_id, token_hash) VALUES ($1, $2, $3, $4) RETURNING *", station.Code, station.Name, station.RegionID, station.TokenHash)
+	if err != nil {
+		zap.S().Error("Error creating weather station: ", err)
+		return types.WeatherStation{}, fmt.Errorf("error creating weather station: %w", err)
+	}
+	return createdStation, nil
+}
+
+// UpdateWeatherStation updates a weather station's code, name and region
+//
+// It returns the updated weather station and an error
+func (db *DB) UpdateWeatherStation(station types.WeatherStation) (types.WeatherStation, error) {
+	var updatedStation types.WeatherStation
+	err := db.Get(&updatedStation, "UPDATE weather_station SET code = $1, name = $2, region_id = $3 WHERE station_id = $4 RETURNING *", station.Code, station.Name, station.RegionID, station.StationID)
+	if err != nil {
+		zap.S().Error("Error updating weather station: ", err)
+		return types.WeatherStation{}, fmt.Errorf("error updating weather station: %w", err)
+	}
+	return updatedStation, nil
+}
+
+// SetWeatherStationToken replaces the push token hash of a weather station
+//
+// It returns the weather station and an error
+func (db *DB) SetWeatherStationToken(id int, tokenHash string) (types.WeatherStation, error) {
+	var station types.WeatherStation
+	err := db.Get(&station, "UPDATE weather_station SET token_hash = $1 WHERE station_id = $2 RETURNING *", tokenHash, id)
+	if err != nil {
+		zap.S().Error("Error setting weather station token: ", err)
+		return types.WeatherStation{}, fmt.Errorf("error setting weather station token: %w", err)
+	}
+	return station, nil
+}
+
+// MarkWeatherStationSeen records that a weather station just pushed readings
+//
+// It returns an error
+func (db *DB) MarkWeatherStationSeen(id int) error {
+	_, err := db.Exec("UPDATE weather_station SET last_seen_at = CURRENT_TIMESTAMP WHERE station_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error marking weather station seen: ", err)
+		return fmt.Errorf("error marking weather station seen: %w", err)
+	}
+	return nil
+}
+
+// DeleteWeatherStation deletes a weather station; its readings are kept
+//
+// It returns an error
+func (db *DB) DeleteWeatherStation(id int) error {
+	_, err := db.Exec("DELETE FROM weather_station WHERE station_id = $1", id)
+	if err != nil {
+		zap.S().Error("Error deleting weather station: ", err)
+		return fmt.Errorf("error deleting weather station: %w", err)
+	}
+	return nil
+}
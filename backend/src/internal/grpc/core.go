@@ -5,7 +5,9 @@ import (
 	"net"
 	"time"
 	"database/sql"
+	"errors"
 
+	"github.com/guregu/null"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -13,19 +15,26 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/orientallines/beesbiz/internal/database"
+	"github.com/orientallines/beesbiz/internal/rabbitmq"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+	"github.com/orientallines/beesbiz/internal/validation"
 	pb "github.com/orientallines/beesbiz/proto/pb"
 )
 
+const dateLayout = "2006-01-02"
+
 type Server struct {
 	server *grpc.Server
 	db     *database.DB
+	rmq    *rabbitmq.RabbitMQ
 	pb.UnimplementedBeeManagementServiceServer
 }
 
-func NewServer(db *database.DB) *Server {
+func NewServer(db *database.DB, rmq *rabbitmq.RabbitMQ) *Server {
 	return &Server{
 		server: grpc.NewServer(),
 		db:     db,
+		rmq:    rmq,
 	}
 }
 
@@ -46,7 +55,40 @@ func (s *Server) RegisterServices() {
 	pb.RegisterBeeManagementServiceServer(s.server, s)
 }
 
+// invalidArgument wraps a validation failure into an InvalidArgument status
+func invalidArgument(err error) error {
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// validateAll returns the first non-nil error of the given validation results
+func validateAll(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return invalidArgument(err)
+		}
+	}
+	return nil
+}
+
+// parseDate parses a YYYY-MM-DD request field
+func parseDate(field, value string) (null.Time, error) {
+	if err := validation.Var(field, value, "required,datetime="+dateLayout); err != nil {
+		return null.Time{}, err
+	}
+	date, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return null.Time{}, err
+	}
+	return null.TimeFrom(date), nil
+}
+
 func (s *Server) GetTotalHoneyHarvested(ctx context.Context, req *pb.GetTotalHoneyHarvestedRequest) (*pb.GetTotalHoneyHarvestedResponse, error) {
+	_, startErr := parseDate("start_date", req.StartDate)
+	_, endErr := parseDate("end_date", req.EndDate)
+	if err := validateAll(validation.Var("hive_id", req.HiveId, "gt=0"), startErr, endErr); err != nil {
+		return nil, err
+	}
+
 	var totalHoney float64
 	err := s.db.GetContext(ctx, &totalHoney, "SELECT get_total_honey_harvested($1, $2, $3)", req.HiveId, req.StartDate, req.EndDate)
 	if err != nil {
@@ -56,8 +98,25 @@ func (s *Server) GetTotalHoneyHarvested(ctx context.Context, req *pb.GetTotalHon
 	return &pb.GetTotalHoneyHarvestedResponse{TotalHoney: totalHoney}, nil
 }
 
+// AddObservation records a free text observation
+//
+// Deprecated: use RecordInspection
 func (s *Server) AddObservation(ctx context.Context, req *pb.AddObservationRequest) (*emptypb.Empty, error) {
-	_, err := s.db.ExecContext(ctx, "CALL add_observation($1, $2, $3, $4)", req.HiveId, req.ObservationDate, req.Description, req.Recommendations)
+	observationDate, err := parseDate("observation_date", req.ObservationDate)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	observation := types.ObservationLog{
+		HiveID:          int(req.HiveId),
+		ObservationDate: observationDate,
+		Description:     req.Description,
+		Recommendations: req.Recommendations,
+	}
+	if err := validation.Struct(observation); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	_, err = s.db.ExecContext(ctx, "CALL add_observation($1, $2, $3, $4)", req.HiveId, req.ObservationDate, req.Description, req.Recommendations)
 	if err != nil {
 		zap.S().Error("Error adding observation: ", err)
 		return nil, err
@@ -66,6 +125,10 @@ func (s *Server) AddObservation(ctx context.Context, req *pb.AddObservationReque
 }
 
 func (s *Server) GetCommunityHealthStatus(ctx context.Context, req *pb.GetCommunityHealthStatusRequest) (*pb.GetCommunityHealthStatusResponse, error) {
+	if err := validateAll(validation.Var("community_id", req.CommunityId, "gt=0")); err != nil {
+		return nil, err
+	}
+
 	var healthStatus string
 	err := s.db.GetContext(ctx, &healthStatus, "SELECT get_community_health_status($1)", req.CommunityId)
 	if err != nil {
@@ -76,6 +139,13 @@ func (s *Server) GetCommunityHealthStatus(ctx context.Context, req *pb.GetCommun
 }
 
 func (s *Server) UpdateHiveStatus(ctx context.Context, req *pb.UpdateHiveStatusRequest) (*emptypb.Empty, error) {
+	if err := validateAll(
+		validation.Var("hive_id", req.HiveId, "gt=0"),
+		validation.Var("new_status", types.HiveStatus(req.NewStatus), "required,enum"),
+	); err != nil {
+		return nil, err
+	}
+
 	_, err := s.db.ExecContext(ctx, "CALL update_hive_status($1, $2)", req.HiveId, req.NewStatus)
 	if err != nil {
 		zap.S().Error("Error updating hive status: ", err)
@@ -85,6 +155,13 @@ func (s *Server) UpdateHiveStatus(ctx context.Context, req *pb.UpdateHiveStatusR
 }
 
 func (s *Server) GetAvgTemperature(ctx context.Context, req *pb.GetAvgTemperatureRequest) (*pb.GetAvgTemperatureResponse, error) {
+	if err := validateAll(
+		validation.Var("region_id", req.RegionId, "gt=0"),
+		validation.Var("days", req.Days, "gt=0"),
+	); err != nil {
+		return nil, err
+	}
+
 	var avgTemp float64
 	err := s.db.GetContext(ctx, &avgTemp, "SELECT get_avg_temperature($1, $2)", req.RegionId, req.Days)
 	if err != nil {
@@ -95,6 +172,13 @@ func (s *Server) GetAvgTemperature(ctx context.Context, req *pb.GetAvgTemperatur
 }
 
 func (s *Server) AssignMaintenancePlan(ctx context.Context, req *pb.AssignMaintenancePlanRequest) (*emptypb.Empty, error) {
+	if err := validateAll(
+		validation.Var("plan_id", req.PlanId, "gt=0"),
+		validation.Var("user_id", req.UserId, "gt=0"),
+	); err != nil {
+		return nil, err
+	}
+
 	_, err := s.db.ExecContext(ctx, "CALL assign_maintenance_plan($1, $2)", req.PlanId, req.UserId)
 	if err != nil {
 		zap.S().Error("Error assigning maintenance plan: ", err)
@@ -104,6 +188,13 @@ func (s *Server) AssignMaintenancePlan(ctx context.Context, req *pb.AssignMainte
 }
 
 func (s *Server) HasRegionAccess(ctx context.Context, req *pb.HasRegionAccessRequest) (*pb.HasRegionAccessResponse, error) {
+	if err := validateAll(
+		validation.Var("user_id", req.UserId, "gt=0"),
+		validation.Var("region_id", req.RegionId, "gt=0"),
+	); err != nil {
+		return nil, err
+	}
+
 	var hasAccess bool
 	err := s.db.GetContext(ctx, &hasAccess, "SELECT has_region_access($1, $2)", req.UserId, req.RegionId)
 	if err != nil {
@@ -114,15 +205,42 @@ func (s *Server) HasRegionAccess(ctx context.Context, req *pb.HasRegionAccessReq
 }
 
 func (s *Server) RegisterIncident(ctx context.Context, req *pb.RegisterIncidentRequest) (*emptypb.Empty, error) {
-	_, err := s.db.ExecContext(ctx, "CALL register_incident($1, $2, $3, $4)", req.HiveId, req.IncidentDate, req.Description, req.Severity)
+	incidentDate, err := parseDate("incident_date", req.IncidentDate)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	incident := types.Incident{
+		HiveID:       int(req.HiveId),
+		IncidentDate: incidentDate,
+		Description:  req.Description,
+		Severity:     types.IncidentSeverity(req.Severity),
+	}
+	if err := validation.Struct(incident); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	createdIncident, err := s.db.CreateIncident(incident, 0)
 	if err != nil {
 		zap.S().Error("Error registering incident: ", err)
 		return nil, err
 	}
+
+	// Route the incident like the ones reported over REST
+	if err := s.rmq.PublishMessage(rabbitmq.IncidentQueue, createdIncident); err != nil {
+		// Log the error but don't fail the request
+		zap.L().Error("Failed to publish incident notification", zap.Error(err))
+	}
 	return &emptypb.Empty{}, nil
 }
 
 func (s *Server) GetLatestSensorReading(ctx context.Context, req *pb.GetLatestSensorReadingRequest) (*pb.GetLatestSensorReadingResponse, error) {
+	if err := validateAll(
+		validation.Var("hive_id", req.HiveId, "gt=0"),
+		validation.Var("sensor_type", req.SensorType, "required"),
+	); err != nil {
+		return nil, err
+	}
+
 	var value []byte
 	var timestamp time.Time
 	err := s.db.QueryRowContext(ctx, "SELECT * FROM get_latest_sensor_reading($1, $2)", req.HiveId, req.SensorType).
@@ -142,6 +260,15 @@ func (s *Server) GetLatestSensorReading(ctx context.Context, req *pb.GetLatestSe
 }
 
 func (s *Server) CreateProductionReport(ctx context.Context, req *pb.CreateProductionReportRequest) (*emptypb.Empty, error) {
+	startDate, startErr := parseDate("start_date", req.StartDate)
+	endDate, endErr := parseDate("end_date", req.EndDate)
+	if err := validateAll(validation.Var("apiary_id", req.ApiaryId, "gt=0"), startErr, endErr); err != nil {
+		return nil, err
+	}
+	if endDate.Time.Before(startDate.Time) {
+		return nil, status.Error(codes.InvalidArgument, "end_date must not be before start_date")
+	}
+
 	_, err := s.db.ExecContext(ctx, "CALL create_production_report($1, $2, $3)", req.ApiaryId, req.StartDate, req.EndDate)
 	if err != nil {
 		zap.S().Error("Error creating production report: ", err)
@@ -152,6 +279,13 @@ func (s *Server) CreateProductionReport(ctx context.Context, req *pb.CreateProdu
 
 // SetRegionAccess
 func (s *Server) SetRegionAccess(ctx context.Context, req *pb.SetRegionAccessRequest) (*emptypb.Empty, error) {
+	if err := validateAll(
+		validation.Var("user_id", req.UserId, "gt=0"),
+		validation.Var("region_id", req.RegionId, "gt=0"),
+	); err != nil {
+		return nil, err
+	}
+
 	_, err := s.db.ExecContext(ctx, "CALL set_region_access($1, $2)", req.UserId, req.RegionId)
 	if err != nil {
 		zap.S().Error("Error setting region access: ", err)
@@ -159,3 +293,55 @@ func (s *Server) SetRegionAccess(ctx context.Context, req *pb.SetRegionAccessReq
 	}
 	return &emptypb.Empty{}, nil
 }
+
+// RecordInspection records a structured inspection, checking its answers against the template
+func (s *Server) RecordInspection(ctx context.Context, req *pb.RecordInspectionRequest) (*pb.RecordInspectionResponse, error) {
+	inspectionDate, err := parseDate("inspection_date", req.InspectionDate)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	inspection := types.Inspection{
+		HiveID:         int(req.HiveId),
+		TemplateID:     int(req.TemplateId),
+		InspectionDate: inspectionDate,
+		Notes:          null.NewString(req.Notes, req.Notes != ""),
+		Answers:        make(map[string]interface{}, len(req.Answers)),
+	}
+	if req.InspectedBy > 0 {
+		inspection.InspectedBy = null.IntFrom(int64(req.InspectedBy))
+	}
+	if err := validation.Struct(inspection); err != nil {
+		return nil, invalidArgument(err)
+	}
+	for _, answer := range req.Answers {
+		switch value := answer.Value.(type) {
+		case *pb.InspectionAnswer_BoolValue:
+			inspection.Answers[answer.Key] = value.BoolValue
+		case *pb.InspectionAnswer_NumberValue:
+			inspection.Answers[answer.Key] = value.NumberValue
+		case *pb.InspectionAnswer_TextValue:
+			inspection.Answers[answer.Key] = value.TextValue
+		}
+	}
+
+	template, err := s.db.GetInspectionTemplate(inspection.TemplateID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Errorf(codes.NotFound, "inspection template %d not found", req.TemplateId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !template.Active {
+		return nil, status.Errorf(codes.FailedPrecondition, "inspection template %d is inactive", req.TemplateId)
+	}
+	answers, err := template.Answers(inspection.Answers)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	createdInspection, err := s.db.CreateInspection(inspection, answers)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RecordInspectionResponse{InspectionId: int32(createdInspection.InspectionID)}, nil
+}
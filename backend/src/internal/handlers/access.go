@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// actor returns the ID and role of the authenticated user, as set by the JWT middleware
+func actor(c *fiber.Ctx) (int, types.Role) {
+	userID, _ := c.Locals("user_id").(int)
+	role, _ := c.Locals("role").(string)
+	return userID, types.Role(role)
+}
+
+// forbidden responds with 403 Forbidden
+func forbidden(c *fiber.Ctx, reason string) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Access denied: " + reason})
+}
+
+// canManageUser reports whether the actor may modify or delete the target user
+//
+// Admins manage everyone; managers only manage workers in their own worker groups
+func canManageUser(db *database.DB, actorID int, actorRole types.Role, target types.User) (bool, error) {
+	switch actorRole {
+	case types.Admin:
+		return true, nil
+	case types.Manager:
+		if target.Role != types.Worker {
+			return false, nil
+		}
+		return db.IsManagedWorker(target.UserID, actorID)
+	default:
+		return false, nil
+	}
+}
+
+// canManageGroup reports whether the actor may modify the worker group
+func canManageGroup(actorID int, actorRole types.Role, group types.WorkerGroup) bool {
+	return actorRole == types.Admin || (actorRole == types.Manager && group.ManagerID == actorID)
+}
+
+// canAssignWorker reports whether the actor may add the worker to one of their groups.
+// Managers can only take on unassigned workers or workers already in their groups,
+// so they can't claim another manager's workers.
+func canAssignWorker(db *database.DB, actorID int, actorRole types.Role, worker types.User) (bool, error) {
+	if actorRole == types.Admin {
+		return true, nil
+	}
+	managed, err := db.IsManagedWorker(worker.UserID, actorID)
+	if err != nil || managed {
+		return managed, err
+	}
+	grouped, err := db.IsGroupedWorker(worker.UserID)
+	if err != nil {
+		return false, err
+	}
+	return !grouped, nil
+}
+
+// isLastAdmin reports whether the user is the only remaining admin
+func isLastAdmin(db *database.DB, user types.User) (bool, error) {
+	if user.Role != types.Admin {
+		return false, nil
+	}
+	count, err := db.CountAdmins()
+	if err != nil {
+		return false, err
+	}
+	return count <= 1, nil
+}
+
+// regionsSubset reports whether every region in regionIDs is in held
+func regionsSubset(regionIDs, held []int) bool {
+	heldSet := make(map[int]bool, len(held))
+	for _, id := range held {
+		heldSet[id] = true
+	}
+	for _, id := range regionIDs {
+		if !heldSet[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// canGrantRegion reports whether the actor may grant or revoke the region access
+//
+// Managers can only change access of their own workers, and only for regions they hold
+func canGrantRegion(db *database.DB, c *fiber.Ctx, grant types.AllowedRegion) (bool, error) {
+	actorID, actorRole := actor(c)
+	if actorRole == types.Admin {
+		return true, nil
+	}
+
+	target, err := db.GetUser(grant.UserID)
+	if err != nil {
+		return false, nil
+	}
+	allowed, err := canManageUser(db, actorID, actorRole, target)
+	if err != nil || !allowed {
+		return false, err
+	}
+
+	held, err := db.GetAllowedRegionIDs(actorID)
+	if err != nil {
+		return false, err
+	}
+	return regionsSubset([]int{grant.RegionID}, held), nil
+}
+
+// regionScope returns the regions the actor has access to, or nil for admins,
+// who have access to every region
+func regionScope(db *database.DB, c *fiber.Ctx) ([]int, error) {
+	actorID, actorRole := actor(c)
+	if actorRole == types.Admin {
+		return nil, nil
+	}
+	scope, err := db.GetAllowedRegionIDs(actorID)
+	if err != nil {
+		return nil, err
+	}
+	if scope == nil {
+		scope = []int{}
+	}
+	return scope, nil
+}
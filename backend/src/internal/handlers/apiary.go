@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/guregu/null"
 	"go.uber.org/zap"
 
 	"github.com/orientallines/beesbiz/internal/database"
@@ -25,8 +26,11 @@ func GetApiary(db *database.DB) fiber.Handler {
 func CreateApiary(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var apiary types.Apiary
-		if err := c.BodyParser(&apiary); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid apiary data: %v", err)})
+		if err := parseBody(c, &apiary); err != nil {
+			return invalidInput(c, "Invalid apiary data", err)
+		}
+		if apiary.Latitude.Valid != apiary.Longitude.Valid {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid apiary data: latitude and longitude must be given together"})
 		}
 		createdApiary, err := db.CreateApiary(apiary)
 		if err != nil {
@@ -39,8 +43,11 @@ func CreateApiary(db *database.DB) fiber.Handler {
 func UpdateApiary(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var apiary types.Apiary
-		if err := c.BodyParser(&apiary); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid apiary data: %v", err)})
+		if err := parseBody(c, &apiary); err != nil {
+			return invalidInput(c, "Invalid apiary data", err)
+		}
+		if apiary.Latitude.Valid != apiary.Longitude.Valid {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid apiary data: latitude and longitude must be given together"})
 		}
 		updatedApiary, err := db.UpdateApiary(apiary)
 		if err != nil {
@@ -87,8 +94,8 @@ func GetAllHives(db *database.DB) fiber.Handler {
 func CreateHive(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var hive types.Hive
-		if err := c.BodyParser(&hive); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid hive data: %v", err)})
+		if err := parseBody(c, &hive); err != nil {
+			return invalidInput(c, "Invalid hive data", err)
 		}
 		createdHive, err := db.CreateHive(hive)
 		if err != nil {
@@ -101,8 +108,8 @@ func CreateHive(db *database.DB) fiber.Handler {
 func UpdateHive(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var hive types.Hive
-		if err := c.BodyParser(&hive); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid hive data: %v", err)})
+		if err := parseBody(c, &hive); err != nil {
+			return invalidInput(c, "Invalid hive data", err)
 		}
 		updatedHive, err := db.UpdateHive(hive)
 		if err != nil {
@@ -183,12 +190,12 @@ func GetAllBeeCommunities(db *database.DB) fiber.Handler {
 func CreateBeeCommunity(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var community types.BeeCommunity
-		if err := c.BodyParser(&community); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid bee community data: %v", err)})
+		if err := parseBody(c, &community); err != nil {
+			return invalidInput(c, "Invalid bee community data", err)
 		}
 		createdCommunity, err := db.CreateBeeCommunity(community)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create bee community: %v", err)})
+			return colonyError(c, "create bee community", err)
 		}
 		return c.JSON(createdCommunity)
 	}
@@ -197,12 +204,12 @@ func CreateBeeCommunity(db *database.DB) fiber.Handler {
 func UpdateBeeCommunity(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var community types.BeeCommunity
-		if err := c.BodyParser(&community); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid bee community data: %v", err)})
+		if err := parseBody(c, &community); err != nil {
+			return invalidInput(c, "Invalid bee community data", err)
 		}
 		updatedCommunity, err := db.UpdateBeeCommunity(community)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update bee community: %v", err)})
+			return colonyError(c, "update bee community", err)
 		}
 		return c.JSON(updatedCommunity)
 	}
@@ -253,8 +260,11 @@ func GetHoneyHarvest(db *database.DB) fiber.Handler {
 func CreateHoneyHarvest(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var harvest types.HoneyHarvest
-		if err := c.BodyParser(&harvest); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid honey harvest data: %v", err)})
+		if err := parseBody(c, &harvest); err != nil {
+			return invalidInput(c, "Invalid honey harvest data", err)
+		}
+		if ok, err := checkWithdrawal(c, db, &harvest); !ok {
+			return err
 		}
 		createdHarvest, err := db.CreateHoneyHarvest(harvest)
 		if err != nil {
@@ -267,12 +277,32 @@ func CreateHoneyHarvest(db *database.DB) fiber.Handler {
 func UpdateHoneyHarvest(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var harvest types.HoneyHarvest
-		if err := c.BodyParser(&harvest); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid honey harvest data: %v", err)})
+		if err := parseBody(c, &harvest); err != nil {
+			return invalidInput(c, "Invalid honey harvest data", err)
+		}
+		existing, err := db.GetHoneyHarvest(harvest.HarvestID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update honey harvest: %v", err)})
+		}
+		// Only moving a harvest to another hive or date is checked against withdrawal periods;
+		// a changed override reason is recorded as given by the current user
+		if existing.HiveID == harvest.HiveID && existing.HarvestDate.Time.Equal(harvest.HarvestDate.Time) {
+			switch {
+			case !harvest.WithdrawalOverrideReason.Valid:
+				harvest.WithdrawalOverrideReason = existing.WithdrawalOverrideReason
+				harvest.OverriddenBy = existing.OverriddenBy
+			case harvest.WithdrawalOverrideReason != existing.WithdrawalOverrideReason:
+				userID, _ := actor(c)
+				harvest.OverriddenBy = null.IntFrom(int64(userID))
+			default:
+				harvest.OverriddenBy = existing.OverriddenBy
+			}
+		} else if ok, err := checkWithdrawal(c, db, &harvest); !ok {
+			return err
 		}
 		updatedHarvest, err := db.UpdateHoneyHarvest(harvest)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update honey harvest: %v", err)})
+			return batchError(c, "update honey harvest", err)
 		}
 		return c.JSON(updatedHarvest)
 	}
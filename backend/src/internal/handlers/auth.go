@@ -1,41 +1,113 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/guregu/null"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/orientallines/beesbiz/internal/config"
 	"github.com/orientallines/beesbiz/internal/database"
+	"github.com/orientallines/beesbiz/internal/notify"
 	types "github.com/orientallines/beesbiz/internal/types/db"
 )
 
 type LoginInput struct {
-	EmailOrUsername string `json:"email_or_username"`
-	Password        string `json:"password"`
+	EmailOrUsername string `json:"email_or_username" validate:"required"`
+	Password        string `json:"password" validate:"required"`
 }
 
 type RegisterInput struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	FullName string `json:"full_name"`
-	Username string `json:"username"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=10,max=72,password"`
+	FullName string `json:"full_name" validate:"required,max=255"`
+	Username string `json:"username" validate:"required,min=3,max=50"`
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=10,max=72,password"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=10,max=72,password"`
 }
 
 var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
 
+// Account lockout policy: after lockAfterFailures consecutive failed logins the
+// account is locked for baseLockDuration, doubling with each further failure.
+// A locked account answers like a wrong password so the lock does not reveal
+// that the account exists.
+const (
+	lockAfterFailures  = 5
+	baseLockDuration   = time.Minute
+	maxLockDuration    = time.Hour
+	failureResetAfter  = 24 * time.Hour
+	resetTokenLifetime = time.Hour
+	tokenByteCount     = 32
+)
+
+// passwordContainsIdentity reports whether the password contains the username or the email's local part
+func passwordContainsIdentity(password, username, email string) bool {
+	lowered := strings.ToLower(password)
+	for _, identity := range []string{username, strings.SplitN(email, "@", 2)[0]} {
+		if len(identity) >= 3 && strings.Contains(lowered, strings.ToLower(identity)) {
+			return true
+		}
+	}
+	return false
+}
+
+// generateToken returns a random hex token for single-use links
+func generateToken() (string, error) {
+	raw := make([]byte, tokenByteCount)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// hashToken returns the hex SHA-256 of a single-use token, which is what gets stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// dummyPasswordHash is compared against for unknown logins so they take as long
+// as a wrong password for an existing account
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not the password of any account"), bcrypt.DefaultCost)
+
+// invalidLogin responds with 401 Unauthorized, the same for unknown, locked and
+// wrongly authenticated accounts
+func invalidLogin(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid login or password"})
+}
+
 // Auth handlers
 
 // Login authenticates a user and returns a JWT token
 func Login(db *database.DB, jwtKey []byte) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var input LoginInput
-		if err := c.BodyParser(&input); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+		if err := parseBody(c, &input); err != nil {
+			return invalidInput(c, "Invalid input", err)
 		}
 
 		var user types.User
@@ -48,11 +120,29 @@ func Login(db *database.DB, jwtKey []byte) fiber.Handler {
 		}
 
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid login or password"})
+			bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(input.Password))
+			return invalidLogin(c)
 		}
 
-		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid login or password"})
+		failure, err := db.GetLoginFailure(user.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not check account lock"})
+		}
+		passwordErr := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
+		if failure.LockedUntil.Valid && failure.LockedUntil.Time.After(time.Now()) {
+			return invalidLogin(c)
+		}
+		if passwordErr != nil {
+			if _, err := db.RecordLoginFailure(user.UserID, lockAfterFailures, baseLockDuration, maxLockDuration, failureResetAfter); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not record failed login"})
+			}
+			return invalidLogin(c)
+		}
+
+		if failure.FailedAttempts > 0 {
+			if err := db.ClearLoginFailures(user.UserID); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not reset failed logins"})
+			}
 		}
 
 		// Update last login time
@@ -94,8 +184,11 @@ func Login(db *database.DB, jwtKey []byte) fiber.Handler {
 func Register(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var input RegisterInput
-		if err := c.BodyParser(&input); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+		if err := parseBody(c, &input); err != nil {
+			return invalidInput(c, "Invalid input", err)
+		}
+		if passwordContainsIdentity(input.Password, input.Username, input.Email) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input: password must not contain the username or email"})
 		}
 
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
@@ -119,3 +212,124 @@ func Register(db *database.DB) fiber.Handler {
 		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"message": "User with id " + strconv.Itoa(createdUser.UserID) + " created successfully"})
 	}
 }
+
+// ForgotPassword sends a single-use password reset token to the account's email
+//
+// It always answers 202, logging failures to issue or send the token, so the
+// endpoint cannot be used to discover accounts
+func ForgotPassword(db *database.DB, notifier notify.Notifier) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var input ForgotPasswordInput
+		if err := parseBody(c, &input); err != nil {
+			return invalidInput(c, "Invalid input", err)
+		}
+
+		accepted := fiber.Map{"message": "If the account exists, a password reset link has been sent"}
+
+		user, err := db.GetUserByEmail(input.Email)
+		if err != nil {
+			return c.Status(fiber.StatusAccepted).JSON(accepted)
+		}
+
+		token, err := generateToken()
+		if err != nil {
+			zap.L().Error("Failed to generate password reset token", zap.Error(err), zap.Int("user_id", user.UserID))
+			return c.Status(fiber.StatusAccepted).JSON(accepted)
+		}
+		expiresAt := time.Now().Add(resetTokenLifetime)
+
+		if err := db.CreatePasswordResetToken(user.UserID, hashToken(token), expiresAt); err != nil {
+			zap.L().Error("Failed to create password reset token", zap.Error(err), zap.Int("user_id", user.UserID))
+			return c.Status(fiber.StatusAccepted).JSON(accepted)
+		}
+
+		body := fmt.Sprintf("Use this token to reset your password: %s\nIt expires at %s.", token, expiresAt.Format(time.RFC1123))
+		if publicURL := config.GlobalConfig.App.PublicURL; publicURL != "" {
+			body = fmt.Sprintf("Open %s/reset-password?token=%s to reset your password.\nThe link expires at %s.", strings.TrimRight(publicURL, "/"), token, expiresAt.Format(time.RFC1123))
+		}
+
+		if err := notifier.Send(c.UserContext(), notify.Message{
+			To:      user.Email,
+			Subject: "BeesBiz password reset",
+			Body:    body,
+		}); err != nil {
+			zap.L().Error("Failed to send password reset token", zap.Error(err), zap.Int("user_id", user.UserID))
+		}
+
+		return c.Status(fiber.StatusAccepted).JSON(accepted)
+	}
+}
+
+// ResetPassword sets a new password using a token issued by ForgotPassword
+func ResetPassword(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var input ResetPasswordInput
+		if err := parseBody(c, &input); err != nil {
+			return invalidInput(c, "Invalid input", err)
+		}
+
+		userID, err := db.GetPasswordResetUser(hashToken(input.Token))
+		if err != nil {
+			if errors.Is(err, database.ErrInvalidResetToken) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired reset token"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not reset password"})
+		}
+		user, err := db.GetUser(userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not reset password"})
+		}
+		if passwordContainsIdentity(input.Password, user.Username, user.Email) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input: password must not contain the username or email"})
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not hash password"})
+		}
+
+		if _, err := db.ResetPassword(hashToken(input.Token), string(hashedPassword)); err != nil {
+			if errors.Is(err, database.ErrInvalidResetToken) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired reset token"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not reset password"})
+		}
+
+		return c.JSON(fiber.Map{"message": "Password has been reset"})
+	}
+}
+
+// ChangePassword lets the authenticated user change their own password
+func ChangePassword(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var input ChangePasswordInput
+		if err := parseBody(c, &input); err != nil {
+			return invalidInput(c, "Invalid input", err)
+		}
+
+		user, err := db.GetUser(c.Locals("user_id").(int))
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.CurrentPassword)); err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Current password is incorrect"})
+		}
+		if input.NewPassword == input.CurrentPassword {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input: new password must differ from the current one"})
+		}
+		if passwordContainsIdentity(input.NewPassword, user.Username, user.Email) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input: password must not contain the username or email"})
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not hash password"})
+		}
+		if err := db.UpdateUserPassword(user.UserID, string(hashedPassword)); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not change password"})
+		}
+
+		return c.JSON(fiber.Map{"message": "Password changed successfully"})
+	}
+}
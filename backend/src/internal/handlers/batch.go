@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/guregu/null"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// batchError maps batch errors to a response, 404 for unknown batches or
+// harvests and 409 for quantities that do not add up or blocked releases
+func batchError(c *fiber.Ctx, action string, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	case errors.Is(err, database.ErrHarvestOverAllocated),
+		errors.Is(err, database.ErrHarvestAllocated),
+		errors.Is(err, database.ErrHarvestBelowAllocated),
+		errors.Is(err, database.ErrBatchBottled),
+		errors.Is(err, database.ErrBatchOverBottled),
+		errors.Is(err, database.ErrBatchReleased),
+		errors.Is(err, database.ErrBatchOutOfSpec):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+}
+
+// Honey Batch Handlers
+func GetHoneyBatch(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid batch ID: %v", err)})
+		}
+		batch, err := db.GetHoneyBatch(id)
+		if err != nil {
+			return batchError(c, "get honey batch", err)
+		}
+		return c.JSON(batch)
+	}
+}
+
+func GetAllHoneyBatches(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		batches, err := db.GetHoneyBatches()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get honey batches: %v", err)})
+		}
+		return c.JSON(batches)
+	}
+}
+
+// CreateHoneyBatch blends parts of harvests into a new batch under a lot number
+func CreateHoneyBatch(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var request types.HoneyBatchRequest
+		if err := parseBody(c, &request); err != nil {
+			return invalidInput(c, "Invalid honey batch data", err)
+		}
+		userID, _ := actor(c)
+		batch, err := db.CreateHoneyBatch(request, userID)
+		if err != nil {
+			return batchError(c, "create honey batch", err)
+		}
+		return c.JSON(batch)
+	}
+}
+
+// AddBatchHarvests blends more harvests into a batch that was not bottled yet
+func AddBatchHarvests(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid batch ID: %v", err)})
+		}
+		var request types.BatchHarvestsRequest
+		if err := parseBody(c, &request); err != nil {
+			return invalidInput(c, "Invalid batch harvests", err)
+		}
+		batch, err := db.AddBatchHarvests(id, request.Harvests)
+		if err != nil {
+			return batchError(c, "add batch harvests", err)
+		}
+		return c.JSON(batch)
+	}
+}
+
+// DeleteHoneyBatch deletes a batch that was not bottled, releasing its harvests
+func DeleteHoneyBatch(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid batch ID: %v", err)})
+		}
+		if err := db.DeleteHoneyBatch(id); err != nil {
+			return batchError(c, "delete honey batch", err)
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// AddBatchStep records a processing step of a batch, such as extraction or filtering
+func AddBatchStep(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid batch ID: %v", err)})
+		}
+		var step types.BatchStep
+		if err := parseBody(c, &step); err != nil {
+			return invalidInput(c, "Invalid batch step", err)
+		}
+		if step.Step == types.StepBottling {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid batch step: bottling is recorded by creating a jar lot"})
+		}
+		if _, err := db.GetHoneyBatch(id); err != nil {
+			return batchError(c, "add batch step", err)
+		}
+		step.BatchID = id
+		userID, _ := actor(c)
+		step.PerformedBy = null.NewInt(int64(userID), userID > 0)
+		createdStep, err := db.AddBatchStep(step)
+		if err != nil {
+			return batchError(c, "add batch step", err)
+		}
+		return c.JSON(createdStep)
+	}
+}
+
+// CreateJarLot bottles part of a batch into jars under their own lot number
+func CreateJarLot(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid batch ID: %v", err)})
+		}
+		var lot types.JarLot
+		if err := parseBody(c, &lot); err != nil {
+			return invalidInput(c, "Invalid jar lot data", err)
+		}
+		if lot.BestBefore.Valid && !lot.BestBefore.Time.After(lot.BottledDate.Time) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid jar lot data: best_before must be after bottled_date"})
+		}
+		lot.BatchID = id
+		userID, _ := actor(c)
+		createdLot, err := db.CreateJarLot(lot, userID)
+		if err != nil {
+			return batchError(c, "create jar lot", err)
+		}
+		return c.JSON(createdLot)
+	}
+}
+
+// ReleaseHoneyBatch releases a batch for sale unless its latest lab results are out of spec
+func ReleaseHoneyBatch(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid batch ID: %v", err)})
+		}
+		var release types.BatchReleaseRequest
+		if err := parseBody(c, &release); err != nil {
+			return invalidInput(c, "Invalid batch release data", err)
+		}
+		userID, _ := actor(c)
+		batch, err := db.ReleaseHoneyBatch(id, release.ReleasedDate, userID)
+		if err != nil {
+			return batchError(c, "release honey batch", err)
+		}
+		return c.JSON(batch)
+	}
+}
+
+// TraceLot traces a batch or jar lot number back to its hives and apiaries, with
+// the treatments in the ?days before each harvest, the last inspections and outbreaks
+func TraceLot(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		days := c.QueryInt("days", types.TraceLookbackDays)
+		if days < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid lookback: days must not be negative"})
+		}
+		trace, err := db.TraceLot(c.Params("lotNumber"), days)
+		if err != nil {
+			return batchError(c, "trace lot", err)
+		}
+		return c.JSON(trace)
+	}
+}
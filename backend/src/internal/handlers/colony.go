@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// colonyError maps the errors of colony operations to responses
+func colonyError(c *fiber.Ctx, action string, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	case errors.Is(err, database.ErrCommunityDissolved),
+		errors.Is(err, database.ErrInsufficientPopulation),
+		errors.Is(err, database.ErrHiveOccupied),
+		errors.Is(err, database.ErrInvalidRelocation):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+}
+
+// SplitColony splits part of a community off into an empty or new hive
+func SplitColony(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var split types.ColonySplitRequest
+		if err := parseBody(c, &split); err != nil {
+			return invalidInput(c, "Invalid colony split data", err)
+		}
+		if !split.TargetHiveID.Valid && split.HiveType == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid colony split data: either target_hive_id or hive_type is required"})
+		}
+		userID, _ := actor(c)
+		event, err := db.SplitColony(split, userID)
+		if err != nil {
+			return colonyError(c, "split colony", err)
+		}
+		return c.JSON(event)
+	}
+}
+
+// MergeColonies combines two communities, keeping the target's queen unless told otherwise
+func MergeColonies(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		merge := types.ColonyMergeRequest{KeepQueen: types.KeepTargetQueen}
+		if err := parseBody(c, &merge); err != nil {
+			return invalidInput(c, "Invalid colony merge data", err)
+		}
+		userID, _ := actor(c)
+		event, err := db.MergeColonies(merge, userID)
+		if err != nil {
+			return colonyError(c, "merge colonies", err)
+		}
+		return c.JSON(event)
+	}
+}
+
+// GetColonyEvents gets splits, merges and relocations, optionally only those involving ?hive_id
+func GetColonyEvents(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		events, err := db.GetColonyEvents(c.QueryInt("hive_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get colony events: %v", err)})
+		}
+		return c.JSON(events)
+	}
+}
+
+// RelocateHive moves a hive to another apiary, recording the move in its history
+func RelocateHive(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid hive ID: %v", err)})
+		}
+		var relocation types.HiveRelocationRequest
+		if err := parseBody(c, &relocation); err != nil {
+			return invalidInput(c, "Invalid hive relocation data", err)
+		}
+		if _, err := db.GetApiary(relocation.ToApiaryID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid hive relocation data: unknown apiary %d", relocation.ToApiaryID)})
+		}
+		userID, _ := actor(c)
+		event, err := db.RelocateHive(id, relocation, userID)
+		if err != nil {
+			return colonyError(c, "relocate hive", err)
+		}
+		return c.JSON(event)
+	}
+}
+
+// GetHiveMovements gets the apiaries a hive stood at over time
+func GetHiveMovements(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid hive ID: %v", err)})
+		}
+		placements, err := db.GetHivePlacements(id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get hive movements: %v", err)})
+		}
+		return c.JSON(placements)
+	}
+}
+
+// GetHarvestTotalsByApiary gets honey harvested per apiary within an optional from/to range,
+// attributed to where each hive stood on the harvest date
+func GetHarvestTotalsByApiary(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, fromErr := parseQueryDate(c, "from")
+		to, toErr := parseQueryDate(c, "to")
+		if err := validateAll(fromErr, toErr); err != nil {
+			return invalidInput(c, "Invalid harvest range", err)
+		}
+		totals, err := db.GetHarvestTotalsByApiary(from, to)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get harvest totals: %v", err)})
+		}
+		return c.JSON(totals)
+	}
+}
@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/guregu/null"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// equipmentError maps inventory errors to their status codes
+func equipmentError(c *fiber.Ctx, action string, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	case errors.Is(err, database.ErrNotHiveComponent):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	case errors.Is(err, database.ErrInsufficientEquipment),
+		errors.Is(err, database.ErrCheckedIn):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+}
+
+// checkEquipmentLocation checks a location is exactly one existing apiary or warehouse
+func checkEquipmentLocation(db *database.DB, location types.EquipmentLocation) error {
+	if location.ApiaryID.Valid == location.WarehouseID.Valid {
+		return errors.New("exactly one of apiary_id or warehouse_id is required")
+	}
+	if location.ApiaryID.Valid {
+		if _, err := db.GetApiary(int(location.ApiaryID.Int64)); err != nil {
+			return fmt.Errorf("unknown apiary %d", location.ApiaryID.Int64)
+		}
+		return nil
+	}
+	if _, err := db.GetWarehouse(int(location.WarehouseID.Int64)); err != nil {
+		return fmt.Errorf("unknown warehouse %d", location.WarehouseID.Int64)
+	}
+	return nil
+}
+
+// Equipment Type Handlers
+func GetEquipmentType(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid equipment type ID: %v", err)})
+		}
+		equipmentType, err := db.GetEquipmentType(id)
+		if err != nil {
+			return equipmentError(c, "get equipment type", err)
+		}
+		return c.JSON(equipmentType)
+	}
+}
+
+func CreateEquipmentType(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var equipmentType types.EquipmentType
+		if err := parseBody(c, &equipmentType); err != nil {
+			return invalidInput(c, "Invalid equipment type data", err)
+		}
+		createdType, err := db.CreateEquipmentType(equipmentType)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create equipment type: %v", err)})
+		}
+		return c.JSON(createdType)
+	}
+}
+
+func UpdateEquipmentType(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var equipmentType types.EquipmentType
+		if err := parseBody(c, &equipmentType); err != nil {
+			return invalidInput(c, "Invalid equipment type data", err)
+		}
+		updatedType, err := db.UpdateEquipmentType(equipmentType)
+		if err != nil {
+			return equipmentError(c, "update equipment type", err)
+		}
+		return c.JSON(updatedType)
+	}
+}
+
+func DeleteEquipmentType(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid equipment type ID: %v", err)})
+		}
+		if err := db.DeleteEquipmentType(id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete equipment type: %v", err)})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func GetAllEquipmentTypes(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		equipmentTypes, err := db.GetAllEquipmentTypes()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get equipment types: %v", err)})
+		}
+		return c.JSON(equipmentTypes)
+	}
+}
+
+// Warehouse Handlers
+func GetWarehouse(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid warehouse ID: %v", err)})
+		}
+		warehouse, err := db.GetWarehouse(id)
+		if err != nil {
+			return equipmentError(c, "get warehouse", err)
+		}
+		return c.JSON(warehouse)
+	}
+}
+
+func CreateWarehouse(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var warehouse types.Warehouse
+		if err := parseBody(c, &warehouse); err != nil {
+			return invalidInput(c, "Invalid warehouse data", err)
+		}
+		createdWarehouse, err := db.CreateWarehouse(warehouse)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create warehouse: %v", err)})
+		}
+		return c.JSON(createdWarehouse)
+	}
+}
+
+func UpdateWarehouse(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var warehouse types.Warehouse
+		if err := parseBody(c, &warehouse); err != nil {
+			return invalidInput(c, "Invalid warehouse data", err)
+		}
+		updatedWarehouse, err := db.UpdateWarehouse(warehouse)
+		if err != nil {
+			return equipmentError(c, "update warehouse", err)
+		}
+		return c.JSON(updatedWarehouse)
+	}
+}
+
+func DeleteWarehouse(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid warehouse ID: %v", err)})
+		}
+		if err := db.DeleteWarehouse(id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete warehouse: %v", err)})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func GetAllWarehouses(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		warehouses, err := db.GetAllWarehouses()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get warehouses: %v", err)})
+		}
+		return c.JSON(warehouses)
+	}
+}
+
+// Equipment Stock Handlers
+
+// GetEquipmentStock gets stock filtered by ?type_id, ?apiary_id and ?warehouse_id
+func GetEquipmentStock(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		stock, err := db.GetEquipmentStock(database.EquipmentStockFilter{
+			TypeID:      c.QueryInt("type_id"),
+			ApiaryID:    c.QueryInt("apiary_id"),
+			WarehouseID: c.QueryInt("warehouse_id"),
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get equipment stock: %v", err)})
+		}
+		return c.JSON(stock)
+	}
+}
+
+// ChangeEquipmentStock adds equipment to or removes it from an apiary or a warehouse
+func ChangeEquipmentStock(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var change types.EquipmentStockChange
+		if err := parseBody(c, &change); err != nil {
+			return invalidInput(c, "Invalid equipment stock change", err)
+		}
+		if err := checkEquipmentLocation(db, change.EquipmentLocation); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid equipment stock change: %v", err)})
+		}
+		stock, err := db.ChangeEquipmentStock(change)
+		if err != nil {
+			return equipmentError(c, "change equipment stock", err)
+		}
+		return c.JSON(stock)
+	}
+}
+
+// TransferEquipment moves equipment between apiaries and warehouses
+func TransferEquipment(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var transfer types.EquipmentTransfer
+		if err := parseBody(c, &transfer); err != nil {
+			return invalidInput(c, "Invalid equipment transfer", err)
+		}
+		for _, location := range []types.EquipmentLocation{transfer.From, transfer.To} {
+			if err := checkEquipmentLocation(db, location); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid equipment transfer: %v", err)})
+			}
+		}
+		if transfer.From == transfer.To {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid equipment transfer: from and to are the same location"})
+		}
+		stock, err := db.TransferEquipment(transfer)
+		if err != nil {
+			return equipmentError(c, "transfer equipment", err)
+		}
+		return c.JSON(stock)
+	}
+}
+
+// GetEquipmentShortages gets the equipment types below their minimum stock
+func GetEquipmentShortages(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		shortages, err := db.GetEquipmentShortages(null.Time{})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get equipment shortages: %v", err)})
+		}
+		return c.JSON(shortages)
+	}
+}
+
+// Hive Component Handlers
+
+// GetHiveComponents gets the components on a hive
+func GetHiveComponents(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid hive ID: %v", err)})
+		}
+		components, err := db.GetHiveComponents(id, 0)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get hive components: %v", err)})
+		}
+		return c.JSON(components)
+	}
+}
+
+// GetApiaryHiveComponents gets the components on every hive of an apiary
+func GetApiaryHiveComponents(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid apiary ID: %v", err)})
+		}
+		components, err := db.GetHiveComponents(0, id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get hive components: %v", err)})
+		}
+		return c.JSON(components)
+	}
+}
+
+// ChangeHiveComponents puts components on a hive from its apiary's stock or takes them off
+func ChangeHiveComponents(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid hive ID: %v", err)})
+		}
+		var change types.HiveComponentChange
+		if err := parseBody(c, &change); err != nil {
+			return invalidInput(c, "Invalid hive component change", err)
+		}
+		components, err := db.ChangeHiveComponents(id, change)
+		if err != nil {
+			return equipmentError(c, "change hive components", err)
+		}
+		return c.JSON(components)
+	}
+}
+
+// Equipment Checkout Handlers
+func GetEquipmentCheckout(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid checkout ID: %v", err)})
+		}
+		checkout, err := db.GetEquipmentCheckout(id)
+		if err != nil {
+			return equipmentError(c, "get equipment checkout", err)
+		}
+		return c.JSON(checkout)
+	}
+}
+
+// GetEquipmentCheckouts gets check-outs filtered by ?user_id and ?open; workers
+// only see their own
+func GetEquipmentCheckouts(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, role := actor(c)
+		filter := database.CheckoutFilter{
+			UserID:   c.QueryInt("user_id"),
+			OpenOnly: c.QueryBool("open"),
+		}
+		if role == types.Worker {
+			filter.UserID = userID
+		}
+		checkouts, err := db.GetEquipmentCheckouts(filter)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get equipment checkouts: %v", err)})
+		}
+		return c.JSON(checkouts)
+	}
+}
+
+// CheckOutEquipment takes equipment from stores; workers can only check out for themselves
+func CheckOutEquipment(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var request types.CheckoutRequest
+		if err := parseBody(c, &request); err != nil {
+			return invalidInput(c, "Invalid equipment checkout", err)
+		}
+		if err := checkEquipmentLocation(db, request.EquipmentLocation); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid equipment checkout: %v", err)})
+		}
+		userID, role := actor(c)
+		if !request.UserID.Valid {
+			request.UserID = null.IntFrom(int64(userID))
+		} else if role == types.Worker && request.UserID.Int64 != int64(userID) {
+			return forbidden(c, "workers can only check out equipment for themselves")
+		}
+		checkout, err := db.CheckOutEquipment(request)
+		if err != nil {
+			return equipmentError(c, "check out equipment", err)
+		}
+		return c.JSON(checkout)
+	}
+}
+
+// CheckInEquipment returns checked out equipment; workers can only check in their own
+func CheckInEquipment(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid checkout ID: %v", err)})
+		}
+		var request types.CheckinRequest
+		if err := parseBody(c, &request); err != nil {
+			return invalidInput(c, "Invalid equipment checkin", err)
+		}
+		checkout, err := db.GetEquipmentCheckout(id)
+		if err != nil {
+			return equipmentError(c, "check in equipment", err)
+		}
+		if userID, role := actor(c); role == types.Worker && checkout.UserID != userID {
+			return forbidden(c, "workers can only check in their own equipment")
+		}
+		checkout, err = db.CheckInEquipment(id, request.ReturnedQuantity)
+		if err != nil {
+			return equipmentError(c, "check in equipment", err)
+		}
+		return c.JSON(checkout)
+	}
+}
@@ -30,8 +30,8 @@ func GetProductionReport(db *database.DB) fiber.Handler {
 func CreateProductionReport(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var report types.ProductionReport
-		if err := c.BodyParser(&report); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid production report data: %v", err)})
+		if err := parseBody(c, &report); err != nil {
+			return invalidInput(c, "Invalid production report data", err)
 		}
 		createdReport, err := db.CreateProductionReport(report)
 		if err != nil {
@@ -45,8 +45,8 @@ func CreateProductionReport(db *database.DB) fiber.Handler {
 func UpdateProductionReport(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var report types.ProductionReport
-		if err := c.BodyParser(&report); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid production report data: %v", err)})
+		if err := parseBody(c, &report); err != nil {
+			return invalidInput(c, "Invalid production report data", err)
 		}
 		updatedReport, err := db.UpdateProductionReport(report)
 		if err != nil {
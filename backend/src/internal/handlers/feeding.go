@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/guregu/null"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// Feeding Handlers
+func GetFeeding(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid feeding ID: %v", err)})
+		}
+		feeding, err := db.GetFeeding(id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get feeding: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get feeding: %v", err)})
+		}
+		return c.JSON(feeding)
+	}
+}
+
+func CreateFeeding(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var feeding types.Feeding
+		if err := parseBody(c, &feeding); err != nil {
+			return invalidInput(c, "Invalid feeding data", err)
+		}
+		userID, _ := actor(c)
+		feeding.FedBy = null.NewInt(int64(userID), userID > 0)
+		createdFeeding, err := db.CreateFeeding(feeding)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create feeding: %v", err)})
+		}
+		return c.JSON(createdFeeding)
+	}
+}
+
+func UpdateFeeding(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var feeding types.Feeding
+		if err := parseBody(c, &feeding); err != nil {
+			return invalidInput(c, "Invalid feeding data", err)
+		}
+		updatedFeeding, err := db.UpdateFeeding(feeding)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update feeding: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update feeding: %v", err)})
+		}
+		return c.JSON(updatedFeeding)
+	}
+}
+
+func DeleteFeeding(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid feeding ID: %v", err)})
+		}
+		if err := db.DeleteFeeding(id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete feeding: %v", err)})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// parseFeedingFilter reads ?hive_id, ?apiary_id and a from/to range
+func parseFeedingFilter(c *fiber.Ctx) (database.FeedingFilter, error) {
+	from, fromErr := parseQueryDate(c, "from")
+	to, toErr := parseQueryDate(c, "to")
+	return database.FeedingFilter{
+		HiveID:   c.QueryInt("hive_id"),
+		ApiaryID: c.QueryInt("apiary_id"),
+		From:     from,
+		To:       to,
+	}, validateAll(fromErr, toErr)
+}
+
+// GetFeedings gets feedings filtered by ?hive_id, ?apiary_id and a from/to range
+func GetFeedings(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		filter, err := parseFeedingFilter(c)
+		if err != nil {
+			return invalidInput(c, "Invalid feeding range", err)
+		}
+		feedings, err := db.GetFeedings(filter)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get feedings: %v", err)})
+		}
+		return c.JSON(feedings)
+	}
+}
+
+// GetFeedUsage gets the feed used per feed type, filtered like GetFeedings
+func GetFeedUsage(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		filter, err := parseFeedingFilter(c)
+		if err != nil {
+			return invalidInput(c, "Invalid feeding range", err)
+		}
+		usage, err := db.GetFeedUsage(filter)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get feed usage: %v", err)})
+		}
+		return c.JSON(usage)
+	}
+}
+
+// parseSeasonStart reads ?season_start, defaulting to August 1st of the current
+// season, when colonies start being prepared for winter
+func parseSeasonStart(c *fiber.Ctx) (null.Time, error) {
+	seasonStart, err := parseQueryDate(c, "season_start")
+	if err != nil || seasonStart.Valid {
+		return seasonStart, err
+	}
+	now := time.Now()
+	year := now.Year()
+	if now.Month() < time.August {
+		year--
+	}
+	return null.TimeFrom(time.Date(year, time.August, 1, 0, 0, 0, 0, time.UTC)), nil
+}
+
+// GetHiveStores estimates a hive's stores from its weight sensor
+func GetHiveStores(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		hiveID, err := c.ParamsInt("hiveID")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid hive ID: %v", err)})
+		}
+		seasonStart, err := parseSeasonStart(c)
+		if err != nil {
+			return invalidInput(c, "Invalid season start", err)
+		}
+		stores, err := db.GetHiveStores(hiveID, seasonStart)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get hive stores: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get hive stores: %v", err)})
+		}
+		return c.JSON(stores)
+	}
+}
+
+// GetWinterReadiness flags the active hives of an apiary whose stores are below
+// ?target_kg, or whose stores are unknown for lack of a weight sensor or tare weight
+func GetWinterReadiness(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		apiaryID, err := c.ParamsInt("apiaryID")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid apiary ID: %v", err)})
+		}
+		target := c.QueryFloat("target_kg", types.DefaultWinterStoresKg)
+		if target <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid target stores: target_kg must be positive"})
+		}
+		seasonStart, err := parseSeasonStart(c)
+		if err != nil {
+			return invalidInput(c, "Invalid season start", err)
+		}
+		hives, err := db.GetApiaryStores(apiaryID, seasonStart)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get winter readiness: %v", err)})
+		}
+
+		readiness := types.WinterReadiness{ApiaryID: apiaryID, TargetKg: target, SeasonStart: seasonStart, Hives: hives}
+		for i := range readiness.Hives {
+			stores := &readiness.Hives[i]
+			switch {
+			case !stores.StoresKg.Valid:
+				readiness.Unknown++
+			case stores.StoresKg.Float64 < target:
+				stores.BelowTarget = null.BoolFrom(true)
+				readiness.BelowTarget++
+			default:
+				stores.BelowTarget = null.BoolFrom(false)
+				readiness.Ready++
+			}
+		}
+		return c.JSON(readiness)
+	}
+}
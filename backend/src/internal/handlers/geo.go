@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// maxRangeKm bounds proximity queries
+const maxRangeKm = 1000
+
+// queryRange parses the ?km range of a proximity query, defaulting to def
+func queryRange(c *fiber.Ctx, def float64) (float64, error) {
+	km := c.QueryFloat("km", def)
+	if km <= 0 || km > maxRangeKm {
+		return 0, fmt.Errorf("km must be in (0, %d]", maxRangeKm)
+	}
+	return km, nil
+}
+
+// Geo Handlers
+
+// GetApiaryGeoJSON gets apiaries, their sites and their hives as a GeoJSON feature collection
+func GetApiaryGeoJSON(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		features, err := db.GetApiaryFeatures()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get apiary features: %v", err)})
+		}
+		return c.JSON(features, "application/geo+json")
+	}
+}
+
+// GetApiariesNear gets the apiaries within ?km of the ?lat/?lon point
+func GetApiariesNear(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		km, err := queryRange(c, types.ForagingRangeKm)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid range: %v", err)})
+		}
+		lat, lon := c.QueryFloat("lat", 1000), c.QueryFloat("lon", 1000)
+		if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid point: lat and lon are required and must be valid coordinates"})
+		}
+		apiaries, err := db.GetApiariesNear(lat, lon, km)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get apiaries nearby: %v", err)})
+		}
+		return c.JSON(apiaries)
+	}
+}
+
+// GetApiaryNeighbours gets the other apiaries within ?km of an apiary, foraging range by default
+func GetApiaryNeighbours(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid apiary ID"})
+		}
+		km, err := queryRange(c, types.ForagingRangeKm)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid range: %v", err)})
+		}
+		neighbours, err := db.GetApiaryNeighbours(id, km)
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get apiary neighbours: %v", err)})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get apiary neighbours: %v", err)})
+		}
+		return c.JSON(neighbours)
+	}
+}
+
+// GetOutbreakNeighbours gets the apiaries outside an outbreak's zone within ?km
+// of it, foraging range by default
+func GetOutbreakNeighbours(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid outbreak ID: %v", err)})
+		}
+		km, err := queryRange(c, types.ForagingRangeKm)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid range: %v", err)})
+		}
+		neighbours, err := db.GetOutbreakNeighbours(id, km)
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get outbreak neighbours: %v", err)})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get outbreak neighbours: %v", err)})
+		}
+		return c.JSON(neighbours)
+	}
+}
@@ -1,18 +1,20 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/guregu/null"
 	"go.uber.org/zap"
 
 	"github.com/orientallines/beesbiz/internal/database"
 	"github.com/orientallines/beesbiz/internal/rabbitmq"
 	types "github.com/orientallines/beesbiz/internal/types/db"
+	"github.com/orientallines/beesbiz/internal/validation"
 )
 
-const IncidentQueue = "incident_queue"
-
 // ObservationLog handlers
 
 // GetObservationLog gets an observation log by ID
@@ -34,8 +36,8 @@ func GetObservationLog(db *database.DB) fiber.Handler {
 func CreateObservationLog(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var log types.ObservationLog
-		if err := c.BodyParser(&log); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid observation log data: %v", err)})
+		if err := parseBody(c, &log); err != nil {
+			return invalidInput(c, "Invalid observation log data", err)
 		}
 		createdLog, err := db.CreateObservationLog(log)
 		if err != nil {
@@ -49,8 +51,8 @@ func CreateObservationLog(db *database.DB) fiber.Handler {
 func UpdateObservationLog(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var log types.ObservationLog
-		if err := c.BodyParser(&log); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid observation log data: %v", err)})
+		if err := parseBody(c, &log); err != nil {
+			return invalidInput(c, "Invalid observation log data", err)
 		}
 		updatedLog, err := db.UpdateObservationLog(log)
 		if err != nil {
@@ -105,9 +107,15 @@ func GetMaintenancePlan(db *database.DB) fiber.Handler {
 // CreateMaintenancePlan creates a new maintenance plan
 func CreateMaintenancePlan(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		var plan types.MaintenancePlan
-		if err := c.BodyParser(&plan); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid maintenance plan data: %v", err)})
+		plan := types.MaintenancePlan{Status: types.MaintenancePending}
+		if err := parseBody(c, &plan); err != nil {
+			return invalidInput(c, "Invalid maintenance plan data", err)
+		}
+		if !plan.AssignedTo.Valid && !plan.AssignedGroupID.Valid {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid maintenance plan data: assigned_to or assigned_group_id is required"})
+		}
+		if inPast(plan.PlannedDate) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid maintenance plan data: planned_date must not be in the past"})
 		}
 		createdPlan, err := db.CreateMaintenancePlan(plan)
 		if err != nil {
@@ -121,8 +129,11 @@ func CreateMaintenancePlan(db *database.DB) fiber.Handler {
 func UpdateMaintenancePlan(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var plan types.MaintenancePlan
-		if err := c.BodyParser(&plan); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid maintenance plan data: %v", err)})
+		if err := parseBody(c, &plan); err != nil {
+			return invalidInput(c, "Invalid maintenance plan data", err)
+		}
+		if !plan.AssignedTo.Valid && !plan.AssignedGroupID.Valid {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid maintenance plan data: assigned_to or assigned_group_id is required"})
 		}
 		updatedPlan, err := db.UpdateMaintenancePlan(plan)
 		if err != nil {
@@ -171,12 +182,10 @@ func UpdateMaintenancePlanStatus(db *database.DB) fiber.Handler {
 
 		// Parse request body
 		var updateData struct {
-			Status string `json:"status"`
+			Status types.MaintenanceStatus `json:"status" validate:"required,enum"`
 		}
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": fmt.Sprintf("Invalid request data: %v", err),
-			})
+		if err := parseBody(c, &updateData); err != nil {
+			return invalidInput(c, "Invalid request data", err)
 		}
 
 		// Get existing plan
@@ -223,16 +232,17 @@ func GetIncident(db *database.DB) fiber.Handler {
 func CreateIncident(db *database.DB, rmq *rabbitmq.RabbitMQ) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var incident types.Incident
-		if err := c.BodyParser(&incident); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid incident data: %v", err)})
+		if err := parseBody(c, &incident); err != nil {
+			return invalidInput(c, "Invalid incident data", err)
 		}
-		createdIncident, err := db.CreateIncident(incident)
+		reporterID, _ := actor(c)
+		createdIncident, err := db.CreateIncident(incident, reporterID)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create incident: %v", err)})
 		}
 
 		// Publish RabbitMQ notification
-		if err := rmq.PublishMessage("incident_queue", createdIncident); err != nil {
+		if err := rmq.PublishMessage(rabbitmq.IncidentQueue, createdIncident); err != nil {
 			// Log the error but don't fail the request
 			zap.L().Error("Failed to publish incident notification", zap.Error(err))
 		}
@@ -241,12 +251,14 @@ func CreateIncident(db *database.DB, rmq *rabbitmq.RabbitMQ) fiber.Handler {
 	}
 }
 
-// UpdateIncident updates an incident
+// UpdateIncident updates an incident's reported details
+//
+// Status and assignment are changed through their own endpoints
 func UpdateIncident(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var incident types.Incident
-		if err := c.BodyParser(&incident); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid incident data: %v", err)})
+		if err := parseBody(c, &incident); err != nil {
+			return invalidInput(c, "Invalid incident data", err)
 		}
 		updatedIncident, err := db.UpdateIncident(incident)
 		if err != nil {
@@ -271,9 +283,30 @@ func DeleteIncident(db *database.DB) fiber.Handler {
 }
 
 // GetAllIncidents gets all incidents
+//
+// Optional query filters: status and severity (comma separated lists),
+// hive_id, assigned_user_id and assigned_group_id
 func GetAllIncidents(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		incidents, err := db.GetAllIncidents()
+		var filter types.IncidentFilter
+		for _, status := range splitList(c.Query("status")) {
+			filter.Statuses = append(filter.Statuses, types.IncidentStatus(status))
+		}
+		for _, severity := range splitList(c.Query("severity")) {
+			filter.Severities = append(filter.Severities, types.IncidentSeverity(severity))
+		}
+		filter.HiveID = c.QueryInt("hive_id")
+		filter.AssigneeID = c.QueryInt("assigned_user_id")
+		filter.GroupID = c.QueryInt("assigned_group_id")
+
+		if err := validateAll(
+			validation.Var("status", filter.Statuses, "dive,enum"),
+			validation.Var("severity", filter.Severities, "dive,enum"),
+		); err != nil {
+			return invalidInput(c, "Invalid incident filter", err)
+		}
+
+		incidents, err := db.GetAllIncidents(filter)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get all incidents: %v", err)})
 		}
@@ -281,47 +314,179 @@ func GetAllIncidents(db *database.DB) fiber.Handler {
 	}
 }
 
-// Add this new handler
-// UpdateIncidentStatus updates an incident's status
+// UpdateIncidentStatus moves an incident through its lifecycle
+//
+// Only open → acknowledged → in_progress → resolved → closed is allowed,
+// plus reopening a resolved incident back to in_progress
 func UpdateIncidentStatus(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Get incident ID from params
 		id, err := c.ParamsInt("id")
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": fmt.Sprintf("Invalid incident ID: %v", err),
-			})
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid incident ID: %v", err)})
 		}
 
-		// Parse request body
 		var updateData struct {
-			Severity string `json:"severity"`
+			Status  types.IncidentStatus `json:"status" validate:"required,enum"`
+			Comment string               `json:"comment" validate:"max=5000"`
 		}
-		if err := c.BodyParser(&updateData); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": fmt.Sprintf("Invalid request data: %v", err),
-			})
+		if err := parseBody(c, &updateData); err != nil {
+			return invalidInput(c, "Invalid request data", err)
+		}
+
+		userID, _ := actor(c)
+		updatedIncident, err := db.TransitionIncident(id, updateData.Status, userID, updateData.Comment)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Incident not found"})
+			case errors.Is(err, database.ErrInvalidTransition):
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update incident status: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update incident status: %v", err)})
+		}
+
+		return c.JSON(updatedIncident)
+	}
+}
+
+// UpdateIncidentSeverity changes an incident's severity
+func UpdateIncidentSeverity(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid incident ID: %v", err)})
+		}
+
+		var updateData struct {
+			Severity types.IncidentSeverity `json:"severity" validate:"required,enum"`
+		}
+		if err := parseBody(c, &updateData); err != nil {
+			return invalidInput(c, "Invalid request data", err)
 		}
 
-		// Get existing incident
 		incident, err := db.GetIncident(id)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": fmt.Sprintf("Failed to get incident: %v", err),
-			})
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Incident not found"})
 		}
 
-		// Update severity
 		incident.Severity = updateData.Severity
-
-		// Save updated incident
 		updatedIncident, err := db.UpdateIncident(incident)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": fmt.Sprintf("Failed to update incident status: %v", err),
-			})
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update incident severity: %v", err)})
+		}
+
+		return c.JSON(updatedIncident)
+	}
+}
+
+// AssignIncident assigns an incident to a user or a worker group
+//
+// Sending neither user_id nor group_id clears the assignment
+func AssignIncident(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid incident ID: %v", err)})
+		}
+
+		var assignment struct {
+			UserID  null.Int `json:"user_id" validate:"omitempty,gt=0"`
+			GroupID null.Int `json:"group_id" validate:"omitempty,gt=0"`
+		}
+		if err := parseBody(c, &assignment); err != nil {
+			return invalidInput(c, "Invalid assignment data", err)
+		}
+		if assignment.UserID.Valid && assignment.GroupID.Valid {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid assignment data: assign either a user or a worker group, not both"})
+		}
+		if assignment.UserID.Valid {
+			if _, err := db.GetUser(int(assignment.UserID.Int64)); err != nil {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+			}
+		}
+		if assignment.GroupID.Valid {
+			if _, err := db.GetWorkerGroup(int(assignment.GroupID.Int64)); err != nil {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Worker group not found"})
+			}
 		}
 
+		userID, _ := actor(c)
+		updatedIncident, err := db.AssignIncident(id, assignment.UserID, assignment.GroupID, userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Incident not found"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to assign incident: %v", err)})
+		}
 		return c.JSON(updatedIncident)
 	}
 }
+
+// GetIncidentActivity gets an incident's comment and activity thread
+func GetIncidentActivity(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid incident ID: %v", err)})
+		}
+		activity, err := db.GetIncidentActivity(id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get incident activity: %v", err)})
+		}
+		return c.JSON(activity)
+	}
+}
+
+// AddIncidentComment adds a comment to an incident's activity thread
+func AddIncidentComment(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid incident ID: %v", err)})
+		}
+
+		var comment struct {
+			Body string `json:"body" validate:"required,max=5000"`
+		}
+		if err := parseBody(c, &comment); err != nil {
+			return invalidInput(c, "Invalid comment", err)
+		}
+
+		if _, err := db.GetIncident(id); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Incident not found"})
+		}
+
+		userID, _ := actor(c)
+		activity, err := db.AddIncidentComment(id, userID, comment.Body)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to add comment: %v", err)})
+		}
+		return c.Status(fiber.StatusCreated).JSON(activity)
+	}
+}
+
+// GetIncidentMetrics gets incident counts and time-to-acknowledge/resolve statistics
+//
+// The optional from and to query parameters (YYYY-MM-DD) limit the incidents by creation date
+func GetIncidentMetrics(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, err := parseQueryDate(c, "from")
+		if err != nil {
+			return invalidInput(c, "Invalid metrics range", err)
+		}
+		to, err := parseQueryDate(c, "to")
+		if err != nil {
+			return invalidInput(c, "Invalid metrics range", err)
+		}
+		// Make the end date inclusive
+		if to.Valid {
+			to = null.TimeFrom(to.Time.AddDate(0, 0, 1))
+		}
+
+		metrics, err := db.GetIncidentMetrics(from, to)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get incident metrics: %v", err)})
+		}
+		return c.JSON(metrics)
+	}
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/guregu/null"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// InspectionTemplate handlers
+
+// GetInspectionTemplate gets an inspection template by ID with its questions
+func GetInspectionTemplate(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid inspection template ID: %v", err)})
+		}
+		template, err := db.GetInspectionTemplate(id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get inspection template: %v", err)})
+		}
+		return c.JSON(template)
+	}
+}
+
+// CreateInspectionTemplate creates an inspection template
+func CreateInspectionTemplate(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		template := types.InspectionTemplate{Active: true}
+		if err := parseBody(c, &template); err != nil {
+			return invalidInput(c, "Invalid inspection template data", err)
+		}
+		userID, _ := actor(c)
+		template.CreatedBy = null.IntFrom(int64(userID))
+		createdTemplate, err := db.CreateInspectionTemplate(template)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create inspection template: %v", err)})
+		}
+		return c.JSON(createdTemplate)
+	}
+}
+
+// UpdateInspectionTemplate updates an inspection template
+//
+// Questions of a template that was already used cannot be changed
+func UpdateInspectionTemplate(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var template types.InspectionTemplate
+		if err := parseBody(c, &template); err != nil {
+			return invalidInput(c, "Invalid inspection template data", err)
+		}
+		updatedTemplate, err := db.UpdateInspectionTemplate(template)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Inspection template not found"})
+			case errors.Is(err, database.ErrTemplateInUse):
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Questions of an inspection template with inspections cannot be changed; create a new template instead"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update inspection template: %v", err)})
+		}
+		return c.JSON(updatedTemplate)
+	}
+}
+
+// DeleteInspectionTemplate deletes an inspection template that has no inspections
+func DeleteInspectionTemplate(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid inspection template ID: %v", err)})
+		}
+		err = db.DeleteInspectionTemplate(id)
+		if errors.Is(err, database.ErrTemplateInUse) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Inspection template has inspections; deactivate it instead"})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete inspection template: %v", err)})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// GetAllInspectionTemplates gets all inspection templates
+func GetAllInspectionTemplates(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		templates, err := db.GetAllInspectionTemplates()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get all inspection templates: %v", err)})
+		}
+		return c.JSON(templates)
+	}
+}
+
+// Inspection handlers
+
+// GetInspection gets an inspection by ID with its answers
+func GetInspection(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid inspection ID: %v", err)})
+		}
+		inspection, err := db.GetInspection(id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get inspection: %v", err)})
+		}
+		return c.JSON(inspection)
+	}
+}
+
+// CreateInspection records an inspection, checking its answers against the template
+func CreateInspection(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var inspection types.Inspection
+		if err := parseBody(c, &inspection); err != nil {
+			return invalidInput(c, "Invalid inspection data", err)
+		}
+		template, err := db.GetInspectionTemplate(inspection.TemplateID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid inspection data: unknown template %d", inspection.TemplateID)})
+		}
+		if !template.Active {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid inspection data: template is inactive"})
+		}
+		answers, err := template.Answers(inspection.Answers)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid inspection answers: %v", err)})
+		}
+
+		userID, _ := actor(c)
+		inspection.InspectedBy = null.IntFrom(int64(userID))
+		createdInspection, err := db.CreateInspection(inspection, answers)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create inspection: %v", err)})
+		}
+		return c.JSON(createdInspection)
+	}
+}
+
+// DeleteInspection deletes an inspection
+func DeleteInspection(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid inspection ID: %v", err)})
+		}
+		if err := db.DeleteInspection(id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete inspection: %v", err)})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// GetAllInspections gets inspections, optionally filtered by hive_id, template_id and a from/to date range
+func GetAllInspections(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, fromErr := parseQueryDate(c, "from")
+		to, toErr := parseQueryDate(c, "to")
+		if err := validateAll(fromErr, toErr); err != nil {
+			return invalidInput(c, "Invalid inspection filter", err)
+		}
+		inspections, err := db.GetInspections(database.InspectionFilter{
+			HiveID:     c.QueryInt("hive_id"),
+			TemplateID: c.QueryInt("template_id"),
+			From:       from,
+			To:         to,
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get inspections: %v", err)})
+		}
+		return c.JSON(inspections)
+	}
+}
+
+// SearchInspections finds hives by their answer to a question,
+// e.g. ?question=queen_seen&op=eq&value=false&latest=true
+func SearchInspections(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Query("question")
+		value := c.Query("value")
+		if key == "" || value == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid inspection search: question and value are required"})
+		}
+		matches, err := db.SearchInspections(database.InspectionSearch{
+			Key:        key,
+			Op:         c.Query("op", "eq"),
+			Value:      value,
+			LatestOnly: c.QueryBool("latest", true),
+		})
+		if err != nil {
+			if errors.Is(err, database.ErrInvalidSearch) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to search inspections: %v", err)})
+		}
+		return c.JSON(matches)
+	}
+}
+
+// GetHiveInspectionTrends gets a hive's boolean, number and choice answers over time,
+// optionally for a single question and a from/to date range
+func GetHiveInspectionTrends(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		hiveID, err := c.ParamsInt("hiveID")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid hive ID: %v", err)})
+		}
+		from, fromErr := parseQueryDate(c, "from")
+		to, toErr := parseQueryDate(c, "to")
+		if err := validateAll(fromErr, toErr); err != nil {
+			return invalidInput(c, "Invalid inspection trend range", err)
+		}
+		trends, err := db.GetInspectionTrends(hiveID, c.Query("question"), from, to)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get inspection trends: %v", err)})
+		}
+		return c.JSON(trends)
+	}
+}
@@ -32,8 +32,8 @@ func GetSensor(db *database.DB) fiber.Handler {
 func CreateSensor(db *database.DB, rmq *rabbitmq.RabbitMQ) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var sensor types.Sensor
-		if err := c.BodyParser(&sensor); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid sensor data: %v", err)})
+		if err := parseBody(c, &sensor); err != nil {
+			return invalidInput(c, "Invalid sensor data", err)
 		}
 
 		// Create sensor in database
@@ -64,8 +64,8 @@ func CreateSensor(db *database.DB, rmq *rabbitmq.RabbitMQ) fiber.Handler {
 func UpdateSensor(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var sensor types.Sensor
-		if err := c.BodyParser(&sensor); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid sensor data: %v", err)})
+		if err := parseBody(c, &sensor); err != nil {
+			return invalidInput(c, "Invalid sensor data", err)
 		}
 		updatedSensor, err := db.UpdateSensor(sensor)
 		if err != nil {
@@ -135,8 +135,8 @@ func GetSensorReading(db *database.DB) fiber.Handler {
 func CreateSensorReading(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var reading types.SensorReading
-		if err := c.BodyParser(&reading); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid sensor reading data: %v", err)})
+		if err := parseBody(c, &reading); err != nil {
+			return invalidInput(c, "Invalid sensor reading data", err)
 		}
 		createdReading, err := db.CreateSensorReading(reading)
 		if err != nil {
@@ -150,8 +150,8 @@ func CreateSensorReading(db *database.DB) fiber.Handler {
 func UpdateSensorReading(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var reading types.SensorReading
-		if err := c.BodyParser(&reading); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid sensor reading data: %v", err)})
+		if err := parseBody(c, &reading); err != nil {
+			return invalidInput(c, "Invalid sensor reading data", err)
 		}
 		updatedReading, err := db.UpdateSensorReading(reading)
 		if err != nil {
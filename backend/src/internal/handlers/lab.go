@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/guregu/null"
+	"go.uber.org/zap"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	"github.com/orientallines/beesbiz/internal/rabbitmq"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+	"github.com/orientallines/beesbiz/internal/validation"
+)
+
+// Grade Rule Handlers
+func GetGradeRules(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rules, err := db.GetGradeRules()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get grade rules: %v", err)})
+		}
+		return c.JSON(rules)
+	}
+}
+
+// SaveGradeRule sets the limits of a grade for lab results recorded from now on
+func SaveGradeRule(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rule types.GradeRule
+		if err := parseBody(c, &rule); err != nil {
+			return invalidInput(c, "Invalid grade rule", err)
+		}
+		savedRule, err := db.SaveGradeRule(rule)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to save grade rule: %v", err)})
+		}
+		return c.JSON(savedRule)
+	}
+}
+
+// labLookup resolves the harvests and batches lab results refer to; *database.DB implements it
+type labLookup interface {
+	GetHoneyHarvest(id int) (types.HoneyHarvest, error)
+	GetHoneyBatch(id int) (types.HoneyBatch, error)
+	GetBatchIDByLotNumber(lotNumber string) (int, error)
+}
+
+// checkLabResult checks a lab result is about exactly one existing harvest or batch
+func checkLabResult(db labLookup, result types.LabResult) error {
+	if result.HarvestID.Valid == result.BatchID.Valid {
+		return errors.New("exactly one of harvest_id or batch_id is required")
+	}
+	if result.ResultDate.Valid && result.ResultDate.Time.Before(result.SampleDate.Time) {
+		return errors.New("result_date must not precede sample_date")
+	}
+	if result.HarvestID.Valid {
+		if _, err := db.GetHoneyHarvest(int(result.HarvestID.Int64)); err != nil {
+			return fmt.Errorf("unknown harvest %d", result.HarvestID.Int64)
+		}
+		return nil
+	}
+	if _, err := db.GetHoneyBatch(int(result.BatchID.Int64)); err != nil {
+		return fmt.Errorf("unknown batch %d", result.BatchID.Int64)
+	}
+	return nil
+}
+
+// alertOutOfSpec queues notifications about out-of-spec results, logging failures so they never fail the request
+func alertOutOfSpec(rmq *rabbitmq.RabbitMQ, results ...types.LabResult) {
+	for _, result := range results {
+		if !result.OutOfSpec {
+			continue
+		}
+		if err := rmq.PublishMessage(rabbitmq.LabResultQueue, result); err != nil {
+			zap.L().Error("Failed to publish out-of-spec lab result notification", zap.Error(err), zap.Int("lab_result_id", result.ResultID))
+		}
+	}
+}
+
+// Lab Result Handlers
+func GetLabResult(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid lab result ID: %v", err)})
+		}
+		result, err := db.GetLabResult(id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get lab result: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get lab result: %v", err)})
+		}
+		return c.JSON(result)
+	}
+}
+
+// CreateLabResult records a lab result, grading it and alerting when it is out of spec
+func CreateLabResult(db *database.DB, rmq *rabbitmq.RabbitMQ) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var result types.LabResult
+		if err := parseBody(c, &result); err != nil {
+			return invalidInput(c, "Invalid lab result data", err)
+		}
+		if err := checkLabResult(db, result); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid lab result data: %v", err)})
+		}
+		userID, _ := actor(c)
+		result.RecordedBy = null.NewInt(int64(userID), userID > 0)
+		createdResult, err := db.CreateLabResult(result)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create lab result: %v", err)})
+		}
+		alertOutOfSpec(rmq, createdResult)
+		return c.JSON(createdResult)
+	}
+}
+
+// UpdateLabResult corrects a lab result, alerting when the correction puts it out of spec
+func UpdateLabResult(db *database.DB, rmq *rabbitmq.RabbitMQ) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var result types.LabResult
+		if err := parseBody(c, &result); err != nil {
+			return invalidInput(c, "Invalid lab result data", err)
+		}
+		if err := checkLabResult(db, result); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid lab result data: %v", err)})
+		}
+		existing, err := db.GetLabResult(result.ResultID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update lab result: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update lab result: %v", err)})
+		}
+		updatedResult, err := db.UpdateLabResult(result)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update lab result: %v", err)})
+		}
+		if !existing.OutOfSpec {
+			alertOutOfSpec(rmq, updatedResult)
+		}
+		return c.JSON(updatedResult)
+	}
+}
+
+func DeleteLabResult(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid lab result ID: %v", err)})
+		}
+		if err := db.DeleteLabResult(id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete lab result: %v", err)})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// GetLabResults gets lab results filtered by ?harvest_id, ?batch_id, ?out_of_spec and a from/to range
+func GetLabResults(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, fromErr := parseQueryDate(c, "from")
+		to, toErr := parseQueryDate(c, "to")
+		if err := validateAll(fromErr, toErr); err != nil {
+			return invalidInput(c, "Invalid lab result range", err)
+		}
+		results, err := db.GetLabResults(database.LabResultFilter{
+			HarvestID:     c.QueryInt("harvest_id"),
+			BatchID:       c.QueryInt("batch_id"),
+			OutOfSpecOnly: c.QueryBool("out_of_spec"),
+			From:          from,
+			To:            to,
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get lab results: %v", err)})
+		}
+		return c.JSON(results)
+	}
+}
+
+// labCSVColumns are the columns a lab result CSV may have; the header row names them in any order
+var labCSVColumns = []string{
+	"harvest_id", "lot_number", "lab_name", "sample_reference", "sample_date", "result_date",
+	"moisture_percent", "hmf_mg_kg", "diastase_dn", "pollen_analysis", "residue_detected", "residue_notes",
+}
+
+// parseLabRow reads one CSV record into a lab result, resolving lot numbers to batches
+func parseLabRow(db labLookup, get func(string) string) (types.LabResult, error) {
+	var result types.LabResult
+	var errs []error
+	parseFloat := func(column string) null.Float {
+		value := get(column)
+		if value == "" {
+			return null.Float{}
+		}
+		number, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid number %q", column, value))
+		}
+		return null.NewFloat(number, err == nil)
+	}
+	parseDate := func(column string) null.Time {
+		value := get(column)
+		if value == "" {
+			return null.Time{}
+		}
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid date %q, expected YYYY-MM-DD", column, value))
+		}
+		return null.NewTime(date, err == nil)
+	}
+	optString := func(column string) null.String {
+		value := get(column)
+		return null.NewString(value, value != "")
+	}
+
+	if value := get("harvest_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("harvest_id: invalid ID %q", value))
+		}
+		result.HarvestID = null.NewInt(int64(id), err == nil)
+	}
+	if lot := get("lot_number"); lot != "" {
+		batchID, err := db.GetBatchIDByLotNumber(lot)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("lot_number: unknown lot %q", lot))
+		}
+		result.BatchID = null.NewInt(int64(batchID), err == nil)
+	}
+	if value := get("residue_detected"); value != "" {
+		detected, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("residue_detected: invalid boolean %q", value))
+		}
+		result.ResidueDetected = null.NewBool(detected, err == nil)
+	}
+	result.LabName = optString("lab_name")
+	result.SampleReference = optString("sample_reference")
+	result.SampleDate = parseDate("sample_date")
+	result.ResultDate = parseDate("result_date")
+	result.MoisturePercent = parseFloat("moisture_percent")
+	result.HMFMgKg = parseFloat("hmf_mg_kg")
+	result.DiastaseDN = parseFloat("diastase_dn")
+	result.PollenAnalysis = optString("pollen_analysis")
+	result.ResidueNotes = optString("residue_notes")
+
+	if err := errors.Join(errs...); err != nil {
+		return types.LabResult{}, err
+	}
+	if err := validation.Struct(&result); err != nil {
+		return types.LabResult{}, err
+	}
+	return result, checkLabResult(db, result)
+}
+
+// parseLabCSV reads lab results from a CSV with a header row, returning every row error
+func parseLabCSV(db labLookup, r io.Reader) ([]types.LabResult, []types.LabImportError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := index["sample_date"]; !ok {
+		return nil, nil, fmt.Errorf("header must name sample_date and any of %s", strings.Join(labCSVColumns, ", "))
+	}
+
+	var results []types.LabResult
+	var rowErrs []types.LabImportError
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rowErrs = append(rowErrs, types.LabImportError{Row: row, Error: err.Error()})
+			continue
+		}
+		get := func(column string) string {
+			if i, ok := index[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		result, err := parseLabRow(db, get)
+		if err != nil {
+			rowErrs = append(rowErrs, types.LabImportError{Row: row, Error: err.Error()})
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, rowErrs, nil
+}
+
+// ImportLabResults imports lab results from a CSV uploaded as the "file" form field
+// or sent as the request body
+//
+// Rows reference a harvest by harvest_id or a batch by lot_number. Nothing is
+// imported unless every row is valid; out-of-spec results are alerted.
+func ImportLabResults(db *database.DB, rmq *rabbitmq.RabbitMQ) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body io.Reader = bytes.NewReader(c.Body())
+		if file, err := c.FormFile("file"); err == nil {
+			upload, err := file.Open()
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid lab result file: %v", err)})
+			}
+			defer upload.Close()
+			body = upload
+		}
+
+		results, rowErrs, err := parseLabCSV(db, body)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid lab result file: %v", err)})
+		}
+		if len(rowErrs) > 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid lab result file: some rows are invalid", "rows": rowErrs})
+		}
+		if len(results) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid lab result file: no rows"})
+		}
+
+		userID, _ := actor(c)
+		for i := range results {
+			results[i].RecordedBy = null.NewInt(int64(userID), userID > 0)
+		}
+		createdResults, err := db.CreateLabResults(results)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to import lab results: %v", err)})
+		}
+		alertOutOfSpec(rmq, createdResults...)
+
+		summary := types.LabImportResult{Imported: len(createdResults), Results: createdResults}
+		for _, result := range createdResults {
+			if result.OutOfSpec {
+				summary.OutOfSpec++
+			}
+		}
+		return c.JSON(summary)
+	}
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// fakeLabLookup knows harvests by ID and batches by lot number
+type fakeLabLookup struct {
+	harvests map[int]bool
+	lots     map[string]int
+}
+
+func (f fakeLabLookup) GetHoneyHarvest(id int) (types.HoneyHarvest, error) {
+	if !f.harvests[id] {
+		return types.HoneyHarvest{}, sql.ErrNoRows
+	}
+	return types.HoneyHarvest{HarvestID: id}, nil
+}
+
+func (f fakeLabLookup) GetHoneyBatch(id int) (types.HoneyBatch, error) {
+	for _, batchID := range f.lots {
+		if batchID == id {
+			return types.HoneyBatch{BatchID: id}, nil
+		}
+	}
+	return types.HoneyBatch{}, sql.ErrNoRows
+}
+
+func (f fakeLabLookup) GetBatchIDByLotNumber(lotNumber string) (int, error) {
+	batchID, ok := f.lots[lotNumber]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return batchID, nil
+}
+
+var testLabLookup = fakeLabLookup{
+	harvests: map[int]bool{7: true},
+	lots:     map[string]int{"B-2024-001": 3, "J-2024-001-12": 3},
+}
+
+func labDate(year int, month time.Month, day int) null.Time {
+	return null.TimeFrom(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+}
+
+// labRow serves the columns of a CSV row to parseLabRow
+func labRow(columns map[string]string) func(string) string {
+	return func(column string) string {
+		return columns[column]
+	}
+}
+
+func TestParseLabRow(t *testing.T) {
+	tests := []struct {
+		name string
+		row  map[string]string
+		want types.LabResult
+	}{
+		{
+			name: "harvest with every measurement",
+			row: map[string]string{
+				"harvest_id": "7", "lab_name": "Apiary Lab", "sample_reference": "S-1",
+				"sample_date": "2024-06-01", "result_date": "2024-06-05",
+				"moisture_percent": "17.8", "hmf_mg_kg": "12.5", "diastase_dn": "10",
+				"pollen_analysis": "lime, clover", "residue_detected": "true", "residue_notes": "trace of amitraz",
+			},
+			want: types.LabResult{
+				HarvestID:       null.IntFrom(7),
+				LabName:         null.StringFrom("Apiary Lab"),
+				SampleReference: null.StringFrom("S-1"),
+				SampleDate:      labDate(2024, time.June, 1),
+				ResultDate:      labDate(2024, time.June, 5),
+				MoisturePercent: null.FloatFrom(17.8),
+				HMFMgKg:         null.FloatFrom(12.5),
+				DiastaseDN:      null.FloatFrom(10),
+				PollenAnalysis:  null.StringFrom("lime, clover"),
+				ResidueDetected: null.BoolFrom(true),
+				ResidueNotes:    null.StringFrom("trace of amitraz"),
+			},
+		},
+		{
+			name: "batch by lot number with decimal commas",
+			row:  map[string]string{"lot_number": "B-2024-001", "sample_date": "2024-06-01", "moisture_percent": "18,2", "hmf_mg_kg": "4,0"},
+			want: types.LabResult{
+				BatchID:         null.IntFrom(3),
+				SampleDate:      labDate(2024, time.June, 1),
+				MoisturePercent: null.FloatFrom(18.2),
+				HMFMgKg:         null.FloatFrom(4),
+			},
+		},
+		{
+			name: "batch by jar lot number",
+			row:  map[string]string{"lot_number": "J-2024-001-12", "sample_date": "2024-06-01", "residue_detected": "0"},
+			want: types.LabResult{
+				BatchID:         null.IntFrom(3),
+				SampleDate:      labDate(2024, time.June, 1),
+				ResidueDetected: null.BoolFrom(false),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLabRow(testLabLookup, labRow(tt.row))
+			if err != nil {
+				t.Fatalf("parseLabRow returned error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLabRow = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLabRowErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		row  map[string]string
+		want []string
+	}{
+		{
+			name: "invalid values",
+			row: map[string]string{
+				"harvest_id": "seven", "sample_date": "01.06.2024", "result_date": "2024/06/05",
+				"moisture_percent": "high", "residue_detected": "maybe",
+			},
+			want: []string{
+				"harvest_id: invalid ID", "sample_date: invalid date", "result_date: invalid date",
+				"moisture_percent: invalid number", "residue_detected: invalid boolean",
+			},
+		},
+		{
+			name: "unknown lot",
+			row:  map[string]string{"lot_number": "B-1999-001", "sample_date": "2024-06-01"},
+			want: []string{`lot_number: unknown lot "B-1999-001"`},
+		},
+		{
+			name: "unknown harvest",
+			row:  map[string]string{"harvest_id": "99", "sample_date": "2024-06-01"},
+			want: []string{"unknown harvest 99"},
+		},
+		{
+			name: "harvest and lot",
+			row:  map[string]string{"harvest_id": "7", "lot_number": "B-2024-001", "sample_date": "2024-06-01"},
+			want: []string{"exactly one of harvest_id or batch_id"},
+		},
+		{
+			name: "neither harvest nor lot",
+			row:  map[string]string{"sample_date": "2024-06-01"},
+			want: []string{"exactly one of harvest_id or batch_id"},
+		},
+		{
+			name: "missing sample date",
+			row:  map[string]string{"harvest_id": "7"},
+			want: []string{"sample_date is required"},
+		},
+		{
+			name: "result before sample",
+			row:  map[string]string{"harvest_id": "7", "sample_date": "2024-06-05", "result_date": "2024-06-01"},
+			want: []string{"result_date must not precede sample_date"},
+		},
+		{
+			name: "moisture over 100",
+			row:  map[string]string{"harvest_id": "7", "sample_date": "2024-06-01", "moisture_percent": "120"},
+			want: []string{"moisture_percent must be less than or equal to 100"},
+		},
+		{
+			name: "sample in the future",
+			row:  map[string]string{"harvest_id": "7", "sample_date": time.Now().AddDate(0, 0, 2).Format("2006-01-02")},
+			want: []string{"sample_date must not be in the future"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseLabRow(testLabLookup, labRow(tt.row))
+			if err == nil {
+				t.Fatalf("parseLabRow = %+v, want an error", result)
+			}
+			for _, part := range tt.want {
+				if !strings.Contains(err.Error(), part) {
+					t.Errorf("error %q does not mention %q", err, part)
+				}
+			}
+		})
+	}
+}
+
+func TestParseLabCSV(t *testing.T) {
+	input := "\ufeffHarvest_ID, Lot_Number, Sample_Date, Moisture_Percent\n" +
+		"7,,2024-06-01,\"17,8\"\n" +
+		",B-2024-001,2024-06-02,18.2\n" +
+		"99,,2024-06-03,18\n" +
+		"7,,2024-06-04\n" +
+		",J-2024-001-12,2024-06-05,\n"
+
+	results, rowErrs, err := parseLabCSV(testLabLookup, strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseLabCSV returned error: %v", err)
+	}
+	want := []types.LabResult{
+		{HarvestID: null.IntFrom(7), SampleDate: labDate(2024, time.June, 1), MoisturePercent: null.FloatFrom(17.8)},
+		{BatchID: null.IntFrom(3), SampleDate: labDate(2024, time.June, 2), MoisturePercent: null.FloatFrom(18.2)},
+		{BatchID: null.IntFrom(3), SampleDate: labDate(2024, time.June, 5)},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("results = %+v, want %+v", results, want)
+	}
+	wantErrs := []struct {
+		row  int
+		text string
+	}{
+		{4, "unknown harvest 99"},
+		{5, "wrong number of fields"},
+	}
+	if len(rowErrs) != len(wantErrs) {
+		t.Fatalf("got %d row errors, want %d: %+v", len(rowErrs), len(wantErrs), rowErrs)
+	}
+	for i, w := range wantErrs {
+		if rowErrs[i].Row != w.row || !strings.Contains(rowErrs[i].Error, w.text) {
+			t.Errorf("row error %d = %+v, want row %d mentioning %q", i, rowErrs[i], w.row, w.text)
+		}
+	}
+}
+
+func TestParseLabCSVHeaderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"missing sample date", "harvest_id,moisture_percent\n7,18\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := parseLabCSV(testLabLookup, strings.NewReader(tt.input)); err == nil {
+				t.Errorf("parseLabCSV(%q) returned no error", tt.input)
+			}
+		})
+	}
+}
@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/guregu/null"
+	"go.uber.org/zap"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	"github.com/orientallines/beesbiz/internal/recurrence"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// defaultHorizonDays is how far ahead plans are generated when a schedule doesn't say
+const defaultHorizonDays = 28
+
+// previewDays is the default range of GetScheduleOccurrences
+const previewDays = 90
+
+// today returns the current date at midnight UTC
+func today() time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// inPast reports whether date is before today
+func inPast(date null.Time) bool {
+	return date.Valid && date.Time.Before(today())
+}
+
+// checkScheduleAssignee responds with 400 unless a schedule targets exactly one of a user or a worker group
+func checkScheduleAssignee(c *fiber.Ctx, schedule types.MaintenanceSchedule) error {
+	if schedule.AssignedTo.Valid == schedule.AssignedGroupID.Valid {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid maintenance schedule data: exactly one of assigned_to and assigned_group_id is required"})
+	}
+	return nil
+}
+
+// checkScheduleHives responds with 400 unless every hive of the schedule belongs to its apiary
+func checkScheduleHives(c *fiber.Ctx, db *database.DB, schedule types.MaintenanceSchedule) error {
+	if len(schedule.HiveIDs) == 0 {
+		return nil
+	}
+	count, err := db.CountHivesInApiary(schedule.ApiaryID, schedule.HiveIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to check schedule hives: %v", err)})
+	}
+	if count != len(schedule.HiveIDs) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid maintenance schedule data: every hive must belong to the schedule's apiary"})
+	}
+	return nil
+}
+
+// generatePlans expands a schedule right away instead of waiting for the next scheduler run
+//
+// Failures are logged and leave the plans to the scheduler, so they never fail the request
+func generatePlans(db *database.DB, schedule types.MaintenanceSchedule) []types.MaintenancePlan {
+	log := zap.L().With(zap.Int("schedule_id", schedule.ScheduleID))
+	if _, err := db.GenerateSchedulePlans(schedule, today()); err != nil {
+		log.Error("Failed to generate maintenance plans", zap.Error(err))
+		return nil
+	}
+	if _, err := db.DistributeGroupPlans(); err != nil {
+		log.Error("Failed to distribute group maintenance plans", zap.Error(err))
+		return nil
+	}
+	plans, err := db.GetSchedulePlans(schedule.ScheduleID)
+	if err != nil {
+		log.Error("Failed to get maintenance plans", zap.Error(err))
+		return nil
+	}
+	return plans
+}
+
+// MaintenanceSchedule handlers
+
+// GetMaintenanceSchedule gets a maintenance schedule by ID with its generated plans
+func GetMaintenanceSchedule(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid maintenance schedule ID: %v", err)})
+		}
+		schedule, err := db.GetMaintenanceSchedule(id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get maintenance schedule: %v", err)})
+		}
+		plans, err := db.GetSchedulePlans(id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get schedule plans: %v", err)})
+		}
+		return c.JSON(fiber.Map{
+			"schedule": schedule,
+			"plans":    plans,
+		})
+	}
+}
+
+// CreateMaintenanceSchedule creates a recurring maintenance schedule and generates its first plans
+func CreateMaintenanceSchedule(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		schedule := types.MaintenanceSchedule{HorizonDays: defaultHorizonDays, Active: true}
+		if err := parseBody(c, &schedule); err != nil {
+			return invalidInput(c, "Invalid maintenance schedule data", err)
+		}
+		if err := checkScheduleAssignee(c, schedule); err != nil {
+			return err
+		}
+		if err := checkScheduleHives(c, db, schedule); err != nil {
+			return err
+		}
+		createdSchedule, err := db.CreateMaintenanceSchedule(schedule)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create maintenance schedule: %v", err)})
+		}
+		return c.JSON(fiber.Map{
+			"schedule": createdSchedule,
+			"plans":    generatePlans(db, createdSchedule),
+		})
+	}
+}
+
+// UpdateMaintenanceSchedule updates a schedule and regenerates its upcoming untouched plans
+func UpdateMaintenanceSchedule(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		schedule := types.MaintenanceSchedule{HorizonDays: defaultHorizonDays, Active: true}
+		if err := parseBody(c, &schedule); err != nil {
+			return invalidInput(c, "Invalid maintenance schedule data", err)
+		}
+		if err := checkScheduleAssignee(c, schedule); err != nil {
+			return err
+		}
+		if err := checkScheduleHives(c, db, schedule); err != nil {
+			return err
+		}
+		updatedSchedule, err := db.UpdateMaintenanceSchedule(schedule)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update maintenance schedule: %v", err)})
+		}
+		return c.JSON(fiber.Map{
+			"schedule": updatedSchedule,
+			"plans":    generatePlans(db, updatedSchedule),
+		})
+	}
+}
+
+// DeleteMaintenanceSchedule deletes a schedule together with its upcoming untouched plans
+func DeleteMaintenanceSchedule(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid maintenance schedule ID: %v", err)})
+		}
+		if err := db.DeleteMaintenanceSchedule(id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete maintenance schedule: %v", err)})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// GetAllMaintenanceSchedules gets all maintenance schedules
+func GetAllMaintenanceSchedules(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		schedules, err := db.GetAllMaintenanceSchedules()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get all maintenance schedules: %v", err)})
+		}
+		return c.JSON(schedules)
+	}
+}
+
+// GetScheduleOccurrences lists the dates a schedule falls on between ?from and ?to
+//
+// The range defaults to the next 90 days
+func GetScheduleOccurrences(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid maintenance schedule ID: %v", err)})
+		}
+		from, err := parseQueryDate(c, "from")
+		if err != nil {
+			return invalidInput(c, "Invalid date range", err)
+		}
+		to, err := parseQueryDate(c, "to")
+		if err != nil {
+			return invalidInput(c, "Invalid date range", err)
+		}
+		if !from.Valid {
+			from = null.TimeFrom(today())
+		}
+		if !to.Valid {
+			to = null.TimeFrom(from.Time.AddDate(0, 0, previewDays))
+		}
+
+		schedule, err := db.GetMaintenanceSchedule(id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get maintenance schedule: %v", err)})
+		}
+		rule, err := recurrence.Parse(schedule.RRule)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to parse recurrence rule: %v", err)})
+		}
+
+		occurrences := []string{}
+		for _, occurrence := range rule.Between(schedule.StartDate.Time, from.Time, to.Time) {
+			occurrences = append(occurrences, occurrence.Format("2006-01-02"))
+		}
+		return c.JSON(occurrences)
+	}
+}
+
+// ChangeScheduleOccurrence skips or reschedules a single occurrence of a schedule
+//
+// This works ahead of plan generation: the occurrence's plans are created in
+// their final state, and the scheduler never recreates them
+func ChangeScheduleOccurrence(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid maintenance schedule ID: %v", err)})
+		}
+		var change types.OccurrenceChange
+		if err := parseBody(c, &change); err != nil {
+			return invalidInput(c, "Invalid occurrence change", err)
+		}
+		if change.Action == types.OccurrenceReschedule && !change.PlannedDate.Valid {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid occurrence change: planned_date is required to reschedule"})
+		}
+		if change.Action == types.OccurrenceReschedule && inPast(change.PlannedDate) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid occurrence change: planned_date must not be in the past"})
+		}
+
+		schedule, err := db.GetMaintenanceSchedule(id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get maintenance schedule: %v", err)})
+		}
+		rule, err := recurrence.Parse(schedule.RRule)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to parse recurrence rule: %v", err)})
+		}
+		if !rule.Includes(schedule.StartDate.Time, change.OccurrenceDate.Time) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid occurrence change: the schedule has no occurrence on that date"})
+		}
+
+		plans, err := db.ChangeOccurrence(id, change)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to change occurrence: %v", err)})
+		}
+		if change.Action == types.OccurrenceReschedule && schedule.AssignedGroupID.Valid {
+			if _, err := db.DistributeGroupPlans(); err == nil {
+				plans, _ = db.GetSchedulePlans(id)
+			}
+		}
+		return c.JSON(plans)
+	}
+}
+
+// GetOverdueMaintenancePlans lists open plans flagged as overdue
+func GetOverdueMaintenancePlans(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		plans, err := db.GetOverduePlans()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get overdue maintenance plans: %v", err)})
+		}
+		return c.JSON(plans)
+	}
+}
+
+// RescheduleMaintenancePlan moves a single plan to a new date, clearing its overdue flag
+func RescheduleMaintenancePlan(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid maintenance plan ID: %v", err)})
+		}
+		var input struct {
+			PlannedDate null.Time `json:"planned_date" validate:"required"`
+		}
+		if err := parseBody(c, &input); err != nil {
+			return invalidInput(c, "Invalid request data", err)
+		}
+		if inPast(input.PlannedDate) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request data: planned_date must not be in the past"})
+		}
+
+		plan, err := db.GetMaintenancePlan(id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get maintenance plan: %v", err)})
+		}
+		if plan.Status == types.MaintenanceCompleted {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Completed maintenance plans cannot be rescheduled"})
+		}
+
+		plan.PlannedDate = input.PlannedDate
+		if plan.Status == types.MaintenanceSkipped {
+			plan.Status = types.MaintenancePending
+		}
+		updatedPlan, err := db.UpdateMaintenancePlan(plan)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to reschedule maintenance plan: %v", err)})
+		}
+		return c.JSON(updatedPlan)
+	}
+}
+
+// Assignment and completion handlers
+
+// AssignmentInput assigns a plan to exactly one of a user or a worker group
+type AssignmentInput struct {
+	UserID  null.Int `json:"user_id" validate:"omitempty,gt=0"`
+	GroupID null.Int `json:"group_id" validate:"omitempty,gt=0"`
+}
+
+// CompletionInput records how a plan was completed
+type CompletionInput struct {
+	DurationMinutes int         `json:"duration_minutes" validate:"gte=0,lte=10080"`
+	Notes           null.String `json:"notes" validate:"omitempty,max=2000"`
+}
+
+// AssignMaintenancePlan assigns a plan to a user, or to a worker group whose
+// least loaded member with access to the apiary's region takes it
+func AssignMaintenancePlan(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid maintenance plan ID: %v", err)})
+		}
+		var input AssignmentInput
+		if err := parseBody(c, &input); err != nil {
+			return invalidInput(c, "Invalid assignment", err)
+		}
+		if input.UserID.Valid == input.GroupID.Valid {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid assignment: exactly one of user_id and group_id is required"})
+		}
+
+		plan, err := db.AssignMaintenancePlan(id, input.UserID, input.GroupID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to assign maintenance plan: %v", err)})
+		}
+		return c.JSON(plan)
+	}
+}
+
+// CompleteMaintenancePlan marks a plan completed with its actual duration and notes
+//
+// Workers can only complete their own plans or undistributed plans of their groups
+func CompleteMaintenancePlan(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid maintenance plan ID: %v", err)})
+		}
+		var input CompletionInput
+		if err := parseBody(c, &input); err != nil {
+			return invalidInput(c, "Invalid completion data", err)
+		}
+
+		plan, err := db.GetMaintenancePlan(id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get maintenance plan: %v", err)})
+		}
+
+		actorID, actorRole := actor(c)
+		if actorRole == types.Worker {
+			allowed := plan.AssignedTo.Valid && int(plan.AssignedTo.Int64) == actorID
+			if !plan.AssignedTo.Valid && plan.AssignedGroupID.Valid {
+				allowed, err = db.IsGroupMember(int(plan.AssignedGroupID.Int64), actorID)
+				if err != nil {
+					return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to check group membership: %v", err)})
+				}
+			}
+			if !allowed {
+				return forbidden(c, "the maintenance plan is assigned to someone else")
+			}
+		}
+
+		completion, err := db.CompleteMaintenancePlan(types.MaintenanceCompletion{
+			PlanID:          id,
+			CompletedBy:     actorID,
+			DurationMinutes: input.DurationMinutes,
+			Notes:           input.Notes,
+		})
+		if errors.Is(err, database.ErrPlanNotOpen) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Maintenance plan is already completed or skipped"})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to complete maintenance plan: %v", err)})
+		}
+		return c.JSON(completion)
+	}
+}
+
+// GetMaintenanceCompletion returns who completed a plan, when and how long it took
+func GetMaintenanceCompletion(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid maintenance plan ID: %v", err)})
+		}
+		completion, err := db.GetMaintenanceCompletion(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Maintenance plan has no completion record"})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get maintenance completion: %v", err)})
+		}
+		return c.JSON(completion)
+	}
+}
+
+// GetMyTasks lists the authenticated worker's open plans, including
+// undistributed plans of their groups
+func GetMyTasks(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := actor(c)
+		plans, err := db.GetMyTasks(userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get tasks: %v", err)})
+		}
+		return c.JSON(plans)
+	}
+}
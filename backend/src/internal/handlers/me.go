@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/orientallines/beesbiz/internal/config"
+	"github.com/orientallines/beesbiz/internal/database"
+	"github.com/orientallines/beesbiz/internal/notify"
+)
+
+// emailTokenLifetime is how long an email change stays confirmable
+const emailTokenLifetime = 24 * time.Hour
+
+type ProfileUpdateInput struct {
+	FullName *string `json:"full_name" validate:"omitempty,min=1,max=255"`
+	Email    *string `json:"email" validate:"omitempty,email"`
+}
+
+type ConfirmEmailInput struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// Me handlers
+
+// GetMe returns the authenticated user's profile together with their allowed
+// regions, worker groups, assigned maintenance plans and managed apiaries
+func GetMe(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("user_id").(int)
+
+		profile, err := db.GetProfile(userID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+		allowedRegions, err := db.GetAllowedRegions(userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get allowed regions: %v", err)})
+		}
+		workerGroups, err := db.GetWorkerGroups(userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get worker groups: %v", err)})
+		}
+		maintenancePlans, err := db.GetMaintenancePlansByAssignee(userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get maintenance plans: %v", err)})
+		}
+		managedApiaries, err := db.GetApiariesByManager(userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get managed apiaries: %v", err)})
+		}
+
+		return c.JSON(fiber.Map{
+			"profile":           profile,
+			"allowed_regions":   allowedRegions,
+			"worker_groups":     workerGroups,
+			"maintenance_plans": maintenancePlans,
+			"managed_apiaries":  managedApiaries,
+		})
+	}
+}
+
+// UpdateMe edits the authenticated user's own profile
+//
+// Only the full name and email can be changed here, so a user cannot touch
+// their role. A new email only takes effect once confirmed through the token
+// sent to that address.
+func UpdateMe(db *database.DB, notifier notify.Notifier) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Locals("user_id").(int)
+
+		var input ProfileUpdateInput
+		if err := parseBody(c, &input); err != nil {
+			return invalidInput(c, "Invalid profile data", err)
+		}
+
+		profile, err := db.GetProfile(userID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+
+		if input.FullName != nil && strings.TrimSpace(*input.FullName) != profile.FullName {
+			if err := db.UpdateUserFullName(userID, strings.TrimSpace(*input.FullName)); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update profile: %v", err)})
+			}
+		}
+
+		if input.Email != nil && !strings.EqualFold(*input.Email, profile.Email) {
+			if _, err := db.GetUserByEmail(*input.Email); err == nil {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Email is already in use"})
+			}
+
+			token, err := generateToken()
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not generate confirmation token"})
+			}
+			expiresAt := time.Now().Add(emailTokenLifetime)
+			if err := db.CreateEmailChangeRequest(userID, *input.Email, hashToken(token), expiresAt); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to request email change: %v", err)})
+			}
+
+			body := fmt.Sprintf("Use this token to confirm your new email address: %s\nIt expires at %s.", token, expiresAt.Format(time.RFC1123))
+			if publicURL := config.GlobalConfig.App.PublicURL; publicURL != "" {
+				body = fmt.Sprintf("Open %s/confirm-email?token=%s to confirm your new email address.\nThe link expires at %s.", strings.TrimRight(publicURL, "/"), token, expiresAt.Format(time.RFC1123))
+			}
+			if err := notifier.Send(c.UserContext(), notify.Message{
+				To:      *input.Email,
+				Subject: "Confirm your new BeesBiz email",
+				Body:    body,
+			}); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not send confirmation email"})
+			}
+		}
+
+		updated, err := db.GetProfile(userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get profile: %v", err)})
+		}
+		return c.JSON(updated)
+	}
+}
+
+// ConfirmEmailChange applies a pending email change using the token sent to the new address
+func ConfirmEmailChange(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var input ConfirmEmailInput
+		if err := parseBody(c, &input); err != nil {
+			return invalidInput(c, "Invalid input", err)
+		}
+
+		request, err := db.ConfirmEmailChange(hashToken(input.Token))
+		if err != nil {
+			if errors.Is(err, database.ErrInvalidEmailToken) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid or expired confirmation token"})
+			}
+			if errors.Is(err, database.ErrEmailTaken) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Email is already in use"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not confirm email change"})
+		}
+
+		return c.JSON(fiber.Map{"message": "Email changed to " + request.NewEmail})
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	"github.com/orientallines/beesbiz/internal/notify"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// NotificationRule handlers
+
+// validateRule checks that a rule targets exactly one of a user or a worker group
+func validateRule(c *fiber.Ctx, rule types.NotificationRule) error {
+	if rule.UserID.Valid == rule.GroupID.Valid {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid notification rule data: exactly one of user_id and group_id is required"})
+	}
+	return nil
+}
+
+// GetNotificationRule gets a notification rule by ID
+func GetNotificationRule(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid notification rule ID: %v", err)})
+		}
+		rule, err := db.GetNotificationRule(id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get notification rule: %v", err)})
+		}
+		return c.JSON(rule)
+	}
+}
+
+// CreateNotificationRule creates a new notification rule
+func CreateNotificationRule(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rule := types.NotificationRule{Enabled: true}
+		if err := parseBody(c, &rule); err != nil {
+			return invalidInput(c, "Invalid notification rule data", err)
+		}
+		if err := validateRule(c, rule); err != nil {
+			return err
+		}
+		createdRule, err := db.CreateNotificationRule(rule)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create notification rule: %v", err)})
+		}
+		return c.JSON(createdRule)
+	}
+}
+
+// UpdateNotificationRule updates a notification rule
+func UpdateNotificationRule(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rule types.NotificationRule
+		if err := parseBody(c, &rule); err != nil {
+			return invalidInput(c, "Invalid notification rule data", err)
+		}
+		if err := validateRule(c, rule); err != nil {
+			return err
+		}
+		updatedRule, err := db.UpdateNotificationRule(rule)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update notification rule: %v", err)})
+		}
+		return c.JSON(updatedRule)
+	}
+}
+
+// DeleteNotificationRule deletes a notification rule
+func DeleteNotificationRule(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid notification rule ID: %v", err)})
+		}
+		if err := db.DeleteNotificationRule(id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete notification rule: %v", err)})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// GetAllNotificationRules gets all notification rules
+func GetAllNotificationRules(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rules, err := db.GetAllNotificationRules()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get notification rules: %v", err)})
+		}
+		return c.JSON(rules)
+	}
+}
+
+// Inbox and preference handlers
+
+// GetMyNotifications lists the authenticated user's inbox, optionally only unread messages
+func GetMyNotifications(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := actor(c)
+		notifications, err := db.GetNotifications(userID, c.QueryBool("unread"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get notifications: %v", err)})
+		}
+		return c.JSON(notifications)
+	}
+}
+
+// MarkNotificationRead marks one of the authenticated user's notifications as read
+func MarkNotificationRead(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid notification ID: %v", err)})
+		}
+		userID, _ := actor(c)
+		notification, err := db.MarkNotificationRead(id, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Notification not found"})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to mark notification read: %v", err)})
+		}
+		return c.JSON(notification)
+	}
+}
+
+// GetMyNotificationPreference returns the authenticated user's notification preferences
+func GetMyNotificationPreference(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := actor(c)
+		pref, err := db.GetNotificationPreference(userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get notification preferences: %v", err)})
+		}
+		return c.JSON(pref)
+	}
+}
+
+// UpdateMyNotificationPreference replaces the authenticated user's notification preferences
+func UpdateMyNotificationPreference(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var pref types.NotificationPreference
+		if err := parseBody(c, &pref); err != nil {
+			return invalidInput(c, "Invalid notification preferences", err)
+		}
+		if pref.QuietHoursStart.Valid != pref.QuietHoursEnd.Valid {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid notification preferences: quiet hours need both a start and an end"})
+		}
+		if pref.WebhookURL.Valid && pref.WebhookURL.String != "" {
+			if err := notify.ValidateWebhookURL(c.UserContext(), pref.WebhookURL.String); err != nil {
+				return invalidInput(c, "Invalid notification preferences", err)
+			}
+		}
+		pref.UserID, _ = actor(c)
+
+		savedPref, err := db.UpsertNotificationPreference(pref)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to save notification preferences: %v", err)})
+		}
+		return c.JSON(savedPref)
+	}
+}
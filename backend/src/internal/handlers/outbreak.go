@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/guregu/null"
+	"go.uber.org/zap"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	"github.com/orientallines/beesbiz/internal/rabbitmq"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// Disease Handlers
+func GetDisease(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid disease ID: %v", err)})
+		}
+		disease, err := db.GetDisease(id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get disease: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get disease: %v", err)})
+		}
+		return c.JSON(disease)
+	}
+}
+
+func CreateDisease(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var disease types.Disease
+		if err := parseBody(c, &disease); err != nil {
+			return invalidInput(c, "Invalid disease data", err)
+		}
+		createdDisease, err := db.CreateDisease(disease)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create disease: %v", err)})
+		}
+		return c.JSON(createdDisease)
+	}
+}
+
+func UpdateDisease(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var disease types.Disease
+		if err := parseBody(c, &disease); err != nil {
+			return invalidInput(c, "Invalid disease data", err)
+		}
+		updatedDisease, err := db.UpdateDisease(disease)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update disease: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update disease: %v", err)})
+		}
+		return c.JSON(updatedDisease)
+	}
+}
+
+func DeleteDisease(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid disease ID: %v", err)})
+		}
+		if err := db.DeleteDisease(id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete disease: %v", err)})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func GetAllDiseases(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		diseases, err := db.GetAllDiseases()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get all diseases: %v", err)})
+		}
+		return c.JSON(diseases)
+	}
+}
+
+// Outbreak Handlers
+func GetOutbreak(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid outbreak ID: %v", err)})
+		}
+		outbreak, err := db.GetOutbreak(id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get outbreak: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get outbreak: %v", err)})
+		}
+		return c.JSON(outbreak)
+	}
+}
+
+// GetAllOutbreaks gets outbreaks, only those still in force with ?active=true
+func GetAllOutbreaks(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		outbreaks, err := db.GetOutbreaks(c.QueryBool("active"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get outbreaks: %v", err)})
+		}
+		return c.JSON(outbreaks)
+	}
+}
+
+// DeclareOutbreak declares an outbreak over a region or a radius around an apiary
+// and notifies the managers of the apiaries it quarantines
+func DeclareOutbreak(db *database.DB, rmq *rabbitmq.RabbitMQ) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var outbreak types.Outbreak
+		if err := parseBody(c, &outbreak); err != nil {
+			return invalidInput(c, "Invalid outbreak data", err)
+		}
+		if outbreak.RegionID.Valid == outbreak.ApiaryID.Valid {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid outbreak data: exactly one of region_id or apiary_id is required"})
+		}
+		if outbreak.RadiusKm.Valid && !outbreak.ApiaryID.Valid {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid outbreak data: radius_km requires apiary_id"})
+		}
+		disease, err := db.GetDisease(outbreak.DiseaseID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid outbreak data: unknown disease %d", outbreak.DiseaseID)})
+		}
+		if outbreak.ApiaryID.Valid && !outbreak.RadiusKm.Valid {
+			outbreak.RadiusKm = disease.DefaultRadiusKm
+		}
+		userID, _ := actor(c)
+		outbreak.DeclaredBy = null.NewInt(int64(userID), userID > 0)
+
+		createdOutbreak, err := db.DeclareOutbreak(outbreak)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to declare outbreak: %v", err)})
+		}
+		publishOutbreak(rmq, createdOutbreak)
+		return c.JSON(createdOutbreak)
+	}
+}
+
+// LiftOutbreak ends an outbreak, releasing its quarantine zone
+func LiftOutbreak(db *database.DB, rmq *rabbitmq.RabbitMQ) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid outbreak ID: %v", err)})
+		}
+		var lift types.OutbreakLiftRequest
+		if err := parseBody(c, &lift); err != nil {
+			return invalidInput(c, "Invalid outbreak lift data", err)
+		}
+		userID, _ := actor(c)
+		outbreak, err := db.LiftOutbreak(id, lift.LiftedDate, userID)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to lift outbreak: %v", err)})
+			case errors.Is(err, database.ErrOutbreakLifted):
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fmt.Sprintf("Failed to lift outbreak: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to lift outbreak: %v", err)})
+		}
+		publishOutbreak(rmq, outbreak)
+		return c.JSON(outbreak)
+	}
+}
+
+// GetOutbreakZone gets the apiaries quarantined by an outbreak
+func GetOutbreakZone(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid outbreak ID: %v", err)})
+		}
+		zone, err := db.GetOutbreakZone(id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get outbreak zone: %v", err)})
+		}
+		return c.JSON(zone)
+	}
+}
+
+// GetOutbreakReport gets the outbreak, its zone and its cases in one regulator-ready report
+func GetOutbreakReport(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid outbreak ID: %v", err)})
+		}
+		outbreak, err := db.GetOutbreak(id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get outbreak report: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get outbreak report: %v", err)})
+		}
+		disease, diseaseErr := db.GetDisease(outbreak.DiseaseID)
+		zone, zoneErr := db.GetOutbreakZone(id)
+		cases, casesErr := db.GetOutbreakCases(id)
+		if err := validateAll(diseaseErr, zoneErr, casesErr); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get outbreak report: %v", err)})
+		}
+
+		report := types.OutbreakReport{
+			Outbreak:    outbreak,
+			Disease:     disease,
+			Zone:        zone,
+			Cases:       cases,
+			ApiaryCount: len(zone),
+			GeneratedAt: time.Now(),
+		}
+		for _, apiary := range zone {
+			report.HiveCount += apiary.HiveCount
+			report.ColonyCount += apiary.ColonyCount
+		}
+		affected := map[int]bool{}
+		for _, outbreakCase := range cases {
+			affected[outbreakCase.HiveID] = true
+		}
+		report.AffectedHives = len(affected)
+		return c.JSON(report)
+	}
+}
+
+// GetQuarantinedApiaries gets every apiary under quarantine with the outbreak imposing it
+func GetQuarantinedApiaries(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		apiaries, err := db.GetQuarantinedApiaries()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get quarantined apiaries: %v", err)})
+		}
+		return c.JSON(apiaries)
+	}
+}
+
+// GetApiaryQuarantine reports whether an apiary is quarantined and by which outbreaks
+func GetApiaryQuarantine(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid apiary ID: %v", err)})
+		}
+		outbreaks, err := db.GetApiaryOutbreaks(id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get apiary quarantine: %v", err)})
+		}
+		return c.JSON(fiber.Map{
+			"apiary_id":   id,
+			"quarantined": len(outbreaks) > 0,
+			"outbreaks":   outbreaks,
+		})
+	}
+}
+
+// publishOutbreak queues the notifications of a declared or lifted outbreak,
+// logging failures so they never fail the request
+func publishOutbreak(rmq *rabbitmq.RabbitMQ, outbreak types.Outbreak) {
+	if err := rmq.PublishMessage(rabbitmq.OutbreakQueue, outbreak); err != nil {
+		zap.L().Error("Failed to publish outbreak notification", zap.Error(err), zap.Int("outbreak_id", outbreak.OutbreakID))
+	}
+}
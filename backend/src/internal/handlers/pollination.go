@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// contractError maps pollination contract errors to their status codes
+func contractError(c *fiber.Ctx, action string, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	case errors.Is(err, database.ErrContractClosed),
+		errors.Is(err, database.ErrHiveBooked),
+		errors.Is(err, database.ErrContractFull),
+		errors.Is(err, database.ErrHiveDeployed),
+		errors.Is(err, database.ErrNoHivesToMove),
+		errors.Is(err, database.ErrInvalidRelocation),
+		errors.Is(err, database.ErrApiaryQuarantined):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+}
+
+// checkContract checks a contract's period and grower
+func checkContract(db *database.DB, contract types.PollinationContract) error {
+	if contract.EndDate.Time.Before(contract.StartDate.Time) {
+		return errors.New("end_date must not precede start_date")
+	}
+	if _, err := db.GetCustomer(contract.CustomerID); err != nil {
+		return fmt.Errorf("unknown customer %d", contract.CustomerID)
+	}
+	return nil
+}
+
+// Pollination Contract Handlers
+func GetPollinationContract(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid contract ID: %v", err)})
+		}
+		contract, err := db.GetPollinationContract(id)
+		if err != nil {
+			return contractError(c, "get pollination contract", err)
+		}
+		return c.JSON(contract)
+	}
+}
+
+// GetPollinationContracts gets contracts filtered by ?customer_id, ?status and a
+// from/to range they overlap
+func GetPollinationContracts(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, fromErr := parseQueryDate(c, "from")
+		to, toErr := parseQueryDate(c, "to")
+		if err := validateAll(fromErr, toErr); err != nil {
+			return invalidInput(c, "Invalid contract range", err)
+		}
+		status := types.ContractStatus(c.Query("status"))
+		if status != "" && !status.IsValid() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid contract status %q", status)})
+		}
+		contracts, err := db.GetPollinationContracts(database.PollinationContractFilter{
+			CustomerID: c.QueryInt("customer_id"),
+			Status:     status,
+			From:       from,
+			To:         to,
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get pollination contracts: %v", err)})
+		}
+		return c.JSON(contracts)
+	}
+}
+
+// CreatePollinationContract records a contract and the apiary standing for the grower's field
+func CreatePollinationContract(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var contract types.PollinationContract
+		if err := parseBody(c, &contract); err != nil {
+			return invalidInput(c, "Invalid pollination contract data", err)
+		}
+		if err := checkContract(db, contract); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid pollination contract data: %v", err)})
+		}
+		userID, _ := actor(c)
+		createdContract, err := db.CreatePollinationContract(contract, userID)
+		if err != nil {
+			return contractError(c, "create pollination contract", err)
+		}
+		return c.JSON(createdContract)
+	}
+}
+
+// UpdatePollinationContract changes a contract whose hives were not moved yet
+func UpdatePollinationContract(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var contract types.PollinationContract
+		if err := parseBody(c, &contract); err != nil {
+			return invalidInput(c, "Invalid pollination contract data", err)
+		}
+		if err := checkContract(db, contract); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid pollination contract data: %v", err)})
+		}
+		updatedContract, err := db.UpdatePollinationContract(contract)
+		if err != nil {
+			return contractError(c, "update pollination contract", err)
+		}
+		return c.JSON(updatedContract)
+	}
+}
+
+// CancelPollinationContract cancels a contract whose hives were not moved yet
+func CancelPollinationContract(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid contract ID: %v", err)})
+		}
+		contract, err := db.CancelPollinationContract(id)
+		if err != nil {
+			return contractError(c, "cancel pollination contract", err)
+		}
+		return c.JSON(contract)
+	}
+}
+
+// AssignContractHives assigns hives to a contract
+func AssignContractHives(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid contract ID: %v", err)})
+		}
+		var request types.ContractHivesRequest
+		if err := parseBody(c, &request); err != nil {
+			return invalidInput(c, "Invalid contract hives", err)
+		}
+		contract, err := db.AssignContractHives(id, request.HiveIDs)
+		if err != nil {
+			return contractError(c, "assign contract hives", err)
+		}
+		return c.JSON(contract)
+	}
+}
+
+// UnassignContractHives removes hives not moved to the field yet from a contract
+func UnassignContractHives(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid contract ID: %v", err)})
+		}
+		var request types.ContractHivesRequest
+		if err := parseBody(c, &request); err != nil {
+			return invalidInput(c, "Invalid contract hives", err)
+		}
+		contract, err := db.UnassignContractHives(id, request.HiveIDs)
+		if err != nil {
+			return contractError(c, "unassign contract hives", err)
+		}
+		return c.JSON(contract)
+	}
+}
+
+// DeployContractHives moves a contract's hives to the grower's field
+func DeployContractHives(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid contract ID: %v", err)})
+		}
+		var move types.ContractMoveRequest
+		if err := parseBody(c, &move); err != nil {
+			return invalidInput(c, "Invalid hive move", err)
+		}
+		userID, _ := actor(c)
+		contract, err := db.DeployContractHives(id, move, userID)
+		if err != nil {
+			return contractError(c, "deploy contract hives", err)
+		}
+		return c.JSON(contract)
+	}
+}
+
+// ReturnContractHives moves a contract's hives back home and completes it
+func ReturnContractHives(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid contract ID: %v", err)})
+		}
+		var move types.ContractMoveRequest
+		if err := parseBody(c, &move); err != nil {
+			return invalidInput(c, "Invalid hive move", err)
+		}
+		userID, _ := actor(c)
+		contract, err := db.ReturnContractHives(id, move, userID)
+		if err != nil {
+			return contractError(c, "return contract hives", err)
+		}
+		return c.JSON(contract)
+	}
+}
+
+// GetHiveAvailability gets which hives are free of contracts over a required
+// from/to range, optionally for one ?apiary_id
+func GetHiveAvailability(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, fromErr := parseQueryDate(c, "from")
+		to, toErr := parseQueryDate(c, "to")
+		if err := validateAll(fromErr, toErr); err != nil {
+			return invalidInput(c, "Invalid availability range", err)
+		}
+		if !from.Valid || !to.Valid || to.Time.Before(from.Time) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid availability range: from and to are required and to must not precede from"})
+		}
+		availability, err := db.GetHiveAvailability(from, to, c.QueryInt("apiary_id"))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get hive availability: %v", err)})
+		}
+		return c.JSON(availability)
+	}
+}
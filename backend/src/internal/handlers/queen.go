@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// defaultLineageDepth and maxLineageDepth bound how many generations GetQueenLineage follows
+const (
+	defaultLineageDepth = 5
+	maxLineageDepth     = 20
+)
+
+// checkQueenMother responds with 400 if a queen names herself as her mother
+func checkQueenMother(c *fiber.Ctx, queen types.Queen) error {
+	if queen.MotherID.Valid && queen.QueenID != 0 && int(queen.MotherID.Int64) == queen.QueenID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid queen data: a queen cannot be her own mother"})
+	}
+	return nil
+}
+
+// Queen handlers
+
+// GetQueen gets a queen by ID with the communities she headed
+func GetQueen(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid queen ID: %v", err)})
+		}
+		queen, err := db.GetQueen(id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get queen: %v", err)})
+		}
+		introductions, err := db.GetQueenIntroductions(id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get queen introductions: %v", err)})
+		}
+		return c.JSON(fiber.Map{
+			"queen":         queen,
+			"introductions": introductions,
+		})
+	}
+}
+
+// CreateQueen registers a queen
+func CreateQueen(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		queen := types.Queen{Status: types.QueenPresent}
+		if err := parseBody(c, &queen); err != nil {
+			return invalidInput(c, "Invalid queen data", err)
+		}
+		createdQueen, err := db.CreateQueen(queen)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create queen: %v", err)})
+		}
+		return c.JSON(createdQueen)
+	}
+}
+
+// UpdateQueen updates a queen's details; her status changes through the introduce and status endpoints
+func UpdateQueen(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		queen := types.Queen{Status: types.QueenPresent}
+		if err := parseBody(c, &queen); err != nil {
+			return invalidInput(c, "Invalid queen data", err)
+		}
+		if err := checkQueenMother(c, queen); err != nil {
+			return err
+		}
+		updatedQueen, err := db.UpdateQueen(queen)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Queen not found"})
+			case errors.Is(err, database.ErrLineageCycle):
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid queen data: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update queen: %v", err)})
+		}
+		return c.JSON(updatedQueen)
+	}
+}
+
+// DeleteQueen deletes a queen
+func DeleteQueen(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid queen ID: %v", err)})
+		}
+		if err := db.DeleteQueen(id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete queen: %v", err)})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// GetAllQueens gets queens, optionally filtered by breed, line and status
+func GetAllQueens(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		status := c.Query("status")
+		if status != "" && !types.QueenStatus(status).IsValid() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid queen status: %s", status)})
+		}
+		queens, err := db.GetQueens(database.QueenFilter{
+			Breed:  c.Query("breed"),
+			Line:   c.Query("line"),
+			Status: status,
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get queens: %v", err)})
+		}
+		return c.JSON(queens)
+	}
+}
+
+// IntroduceQueen makes a queen head a community, replacing its current queen
+func IntroduceQueen(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid queen ID: %v", err)})
+		}
+		introduction := types.QueenIntroduction{QueenID: id}
+		if err := parseBody(c, &introduction); err != nil {
+			return invalidInput(c, "Invalid queen introduction data", err)
+		}
+		introduction.QueenID = id
+
+		createdIntroduction, err := db.IntroduceQueen(introduction)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Queen not found"})
+			case errors.Is(err, database.ErrQueenSuperseded):
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fmt.Sprintf("Failed to introduce queen: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to introduce queen: %v", err)})
+		}
+		return c.JSON(createdIntroduction)
+	}
+}
+
+// UpdateQueenStatus records that a queen was superseded, went missing or was replaced
+func UpdateQueenStatus(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid queen ID: %v", err)})
+		}
+		var change types.QueenStatusChange
+		if err := parseBody(c, &change); err != nil {
+			return invalidInput(c, "Invalid queen status data", err)
+		}
+		queen, err := db.ChangeQueenStatus(id, change)
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Queen not found"})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update queen status: %v", err)})
+		}
+		return c.JSON(queen)
+	}
+}
+
+// GetQueenLineage gets a queen's ancestors and descendants, up to ?depth generations away
+func GetQueenLineage(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid queen ID: %v", err)})
+		}
+		depth := c.QueryInt("depth", defaultLineageDepth)
+		if depth < 1 || depth > maxLineageDepth {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid lineage depth: must be between 1 and %d", maxLineageDepth)})
+		}
+		lineage, err := db.GetQueenLineage(id, depth)
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Queen not found"})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get queen lineage: %v", err)})
+		}
+		return c.JSON(lineage)
+	}
+}
+
+// GetHiveRequeeningHistory gets the queens that headed a hive's communities, the latest first
+func GetHiveRequeeningHistory(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		hiveID, err := c.ParamsInt("hiveID")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid hive ID: %v", err)})
+		}
+		history, err := db.GetRequeeningHistory(hiveID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get requeening history: %v", err)})
+		}
+		return c.JSON(history)
+	}
+}
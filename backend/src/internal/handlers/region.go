@@ -1,14 +1,40 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/guregu/null"
 
 	"github.com/orientallines/beesbiz/internal/database"
 	types "github.com/orientallines/beesbiz/internal/types/db"
 )
 
+// regionError maps region errors to their status codes
+func regionError(c *fiber.Ctx, action string, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	case errors.Is(err, database.ErrRegionCycle),
+		errors.Is(err, database.ErrRegionHasChildren):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+}
+
+// checkRegionParent checks that a region's parent exists
+func checkRegionParent(db *database.DB, region types.Region) error {
+	if !region.ParentRegionID.Valid {
+		return nil
+	}
+	if _, err := db.GetRegion(int(region.ParentRegionID.Int64)); err != nil {
+		return fmt.Errorf("unknown parent region %d", region.ParentRegionID.Int64)
+	}
+	return nil
+}
+
 // Region handlers
 func GetRegion(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -27,7 +53,10 @@ func GetRegion(db *database.DB) fiber.Handler {
 func CreateRegion(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var region types.Region
-		if err := c.BodyParser(&region); err != nil {
+		if err := parseBody(c, &region); err != nil {
+			return invalidInput(c, "Invalid region data", err)
+		}
+		if err := checkRegionParent(db, region); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid region data: %v", err)})
 		}
 		createdRegion, err := db.CreateRegion(region)
@@ -41,12 +70,15 @@ func CreateRegion(db *database.DB) fiber.Handler {
 func UpdateRegion(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var region types.Region
-		if err := c.BodyParser(&region); err != nil {
+		if err := parseBody(c, &region); err != nil {
+			return invalidInput(c, "Invalid region data", err)
+		}
+		if err := checkRegionParent(db, region); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid region data: %v", err)})
 		}
 		updatedRegion, err := db.UpdateRegion(region)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update region: %v", err)})
+			return regionError(c, "update region", err)
 		}
 		return c.JSON(updatedRegion)
 	}
@@ -59,7 +91,7 @@ func DeleteRegion(db *database.DB) fiber.Handler {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid region ID: %v", err)})
 		}
 		if err := db.DeleteRegion(id); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete region: %v", err)})
+			return regionError(c, "delete region", err)
 		}
 		return c.SendStatus(fiber.StatusNoContent)
 	}
@@ -75,12 +107,105 @@ func GetAllRegions(db *database.DB) fiber.Handler {
 	}
 }
 
+// GetRegionTree gets the regions the actor has access to as a tree, optionally
+// rooted at ?root_id
+func GetRegionTree(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scope, err := regionScope(db, c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get your allowed regions: %v", err)})
+		}
+		tree, err := db.GetRegionTree(c.QueryInt("root_id"), scope)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get region tree: %v", err)})
+		}
+		return c.JSON(tree)
+	}
+}
+
+// GetAccessibleRegions gets the regions the authenticated user has access to,
+// granted directly or nested in a granted region
+func GetAccessibleRegions(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, role := actor(c)
+		if role == types.Admin {
+			regions, err := db.GetAllRegions()
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get all regions: %v", err)})
+			}
+			return c.JSON(regions)
+		}
+		regions, err := db.GetAccessibleRegions(userID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get accessible regions: %v", err)})
+		}
+		return c.JSON(regions)
+	}
+}
+
+// GetRegionApiaries gets the apiaries of a region and the regions nested in it
+func GetRegionApiaries(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid region ID: %v", err)})
+		}
+		scope, err := regionScope(db, c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get your allowed regions: %v", err)})
+		}
+		if scope != nil && !regionsSubset([]int{id}, scope) {
+			return forbidden(c, "you do not have access to this region")
+		}
+		apiaries, err := db.GetRegionApiaries(id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get region apiaries: %v", err)})
+		}
+		return c.JSON(apiaries)
+	}
+}
+
+// GetRegionStats gets hive, harvest and incident statistics rolled up per region
+// the actor has access to, filtered by ?region_id (the region and its direct
+// children), ?depth and a from/to range
+func GetRegionStats(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, fromErr := parseQueryDate(c, "from")
+		to, toErr := parseQueryDate(c, "to")
+		if err := validateAll(fromErr, toErr); err != nil {
+			return invalidInput(c, "Invalid statistics range", err)
+		}
+		filter := database.RegionStatsFilter{RegionID: c.QueryInt("region_id"), From: from, To: to}
+		if c.Query("depth") != "" {
+			depth := c.QueryInt("depth", -1)
+			if depth < 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid depth: must be a non-negative integer"})
+			}
+			filter.Depth = null.IntFrom(int64(depth))
+		}
+		scope, err := regionScope(db, c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get your allowed regions: %v", err)})
+		}
+		stats, err := db.GetRegionStats(filter, scope)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get region statistics: %v", err)})
+		}
+		return c.JSON(stats)
+	}
+}
+
 // AllowedRegion handlers
 func CreateAllowedRegion(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var allowedRegion types.AllowedRegion
-		if err := c.BodyParser(&allowedRegion); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid allowed region data: %v", err)})
+		if err := parseBody(c, &allowedRegion); err != nil {
+			return invalidInput(c, "Invalid allowed region data", err)
+		}
+		if ok, err := canGrantRegion(db, c, allowedRegion); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to check permissions: %v", err)})
+		} else if !ok {
+			return forbidden(c, "you can only grant regions you hold to workers in your own groups")
 		}
 
 		createdAllowedRegion, err := db.CreateAllowedRegion(allowedRegion)
@@ -94,8 +219,19 @@ func CreateAllowedRegion(db *database.DB) fiber.Handler {
 func UpdateAllowedRegion(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var allowedRegion types.AllowedRegion
-		if err := c.BodyParser(&allowedRegion); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid allowed region data: %v", err)})
+		if err := parseBody(c, &allowedRegion); err != nil {
+			return invalidInput(c, "Invalid allowed region data", err)
+		}
+		existing, err := db.GetAllowedRegion(allowedRegion.ID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Allowed region not found"})
+		}
+		for _, grant := range []types.AllowedRegion{existing, allowedRegion} {
+			if ok, err := canGrantRegion(db, c, grant); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to check permissions: %v", err)})
+			} else if !ok {
+				return forbidden(c, "you can only grant regions you hold to workers in your own groups")
+			}
 		}
 
 		updatedAllowedRegion, err := db.UpdateAllowedRegion(allowedRegion)
@@ -112,6 +248,15 @@ func DeleteAllowedRegion(db *database.DB) fiber.Handler {
 		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid allowed region ID: %v", err)})
 		}
+		existing, err := db.GetAllowedRegion(id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Allowed region not found"})
+		}
+		if ok, err := canGrantRegion(db, c, existing); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to check permissions: %v", err)})
+		} else if !ok {
+			return forbidden(c, "you can only grant regions you hold to workers in your own groups")
+		}
 		if err := db.DeleteAllowedRegion(id); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete allowed region: %v", err)})
 		}
@@ -162,8 +307,8 @@ func GetRegionApiary(db *database.DB) fiber.Handler {
 func CreateRegionApiary(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var regionApiary types.RegionApiary
-		if err := c.BodyParser(&regionApiary); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid region apiary data: %v", err)})
+		if err := parseBody(c, &regionApiary); err != nil {
+			return invalidInput(c, "Invalid region apiary data", err)
 		}
 
 		createdRegionApiary, err := db.CreateRegionApiary(regionApiary)
@@ -177,8 +322,8 @@ func CreateRegionApiary(db *database.DB) fiber.Handler {
 func UpdateRegionApiary(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var regionApiary types.RegionApiary
-		if err := c.BodyParser(&regionApiary); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid region apiary data: %v", err)})
+		if err := parseBody(c, &regionApiary); err != nil {
+			return invalidInput(c, "Invalid region apiary data", err)
 		}
 
 		updatedRegionApiary, err := db.UpdateRegionApiary(regionApiary)
@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// salesError maps stock, order and invoice errors to their status codes
+func salesError(c *fiber.Ctx, action string, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	case errors.Is(err, database.ErrNoUnitPrice):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	case errors.Is(err, database.ErrInsufficientStock),
+		errors.Is(err, database.ErrStockExceedsLot),
+		errors.Is(err, database.ErrBatchNotReleased),
+		errors.Is(err, database.ErrBatchOutOfSpec),
+		errors.Is(err, database.ErrBatchQuarantined),
+		errors.Is(err, database.ErrOrderClosed),
+		errors.Is(err, database.ErrInvoicePaid),
+		errors.Is(err, database.ErrBatchBottled):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+}
+
+// Customer Handlers
+func GetCustomer(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid customer ID: %v", err)})
+		}
+		customer, err := db.GetCustomer(id)
+		if err != nil {
+			return salesError(c, "get customer", err)
+		}
+		return c.JSON(customer)
+	}
+}
+
+func CreateCustomer(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var customer types.Customer
+		if err := parseBody(c, &customer); err != nil {
+			return invalidInput(c, "Invalid customer data", err)
+		}
+		createdCustomer, err := db.CreateCustomer(customer)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create customer: %v", err)})
+		}
+		return c.JSON(createdCustomer)
+	}
+}
+
+func UpdateCustomer(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var customer types.Customer
+		if err := parseBody(c, &customer); err != nil {
+			return invalidInput(c, "Invalid customer data", err)
+		}
+		updatedCustomer, err := db.UpdateCustomer(customer)
+		if err != nil {
+			return salesError(c, "update customer", err)
+		}
+		return c.JSON(updatedCustomer)
+	}
+}
+
+func DeleteCustomer(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid customer ID: %v", err)})
+		}
+		if err := db.DeleteCustomer(id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete customer: %v", err)})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func GetAllCustomers(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		customers, err := db.GetAllCustomers()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get customers: %v", err)})
+		}
+		return c.JSON(customers)
+	}
+}
+
+// Stock Handlers
+func GetStockItem(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid stock ID: %v", err)})
+		}
+		item, err := db.GetStockItem(id)
+		if err != nil {
+			return salesError(c, "get stock item", err)
+		}
+		return c.JSON(item)
+	}
+}
+
+// GetStockItems gets stock filtered by ?batch_id, ?location, ?container_type and ?in_stock
+func GetStockItems(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		containerType := types.ContainerType(c.Query("container_type"))
+		if containerType != "" && !containerType.IsValid() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid container type %q", containerType)})
+		}
+		items, err := db.GetStockItems(database.StockFilter{
+			BatchID:       c.QueryInt("batch_id"),
+			Location:      c.Query("location"),
+			ContainerType: containerType,
+			InStockOnly:   c.QueryBool("in_stock"),
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get stock items: %v", err)})
+		}
+		return c.JSON(items)
+	}
+}
+
+// ReceiveStock puts honey from a jar lot or the unbottled part of a batch into stock
+func ReceiveStock(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var receipt types.StockReceipt
+		if err := parseBody(c, &receipt); err != nil {
+			return invalidInput(c, "Invalid stock receipt", err)
+		}
+		if !receipt.JarLotID.Valid && receipt.UnitWeightKg <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid stock receipt: unit_weight_kg is required for bulk stock"})
+		}
+		userID, _ := actor(c)
+		item, err := db.ReceiveStock(receipt, userID)
+		if err != nil {
+			return salesError(c, "receive stock", err)
+		}
+		return c.JSON(item)
+	}
+}
+
+// UpdateStockItem moves a stock item or changes its price
+func UpdateStockItem(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var item types.StockItem
+		if err := parseBody(c, &item); err != nil {
+			return invalidInput(c, "Invalid stock item data", err)
+		}
+		updatedItem, err := db.UpdateStockItem(item)
+		if err != nil {
+			return salesError(c, "update stock item", err)
+		}
+		return c.JSON(updatedItem)
+	}
+}
+
+// AdjustStock corrects the quantity on hand of a stock item
+func AdjustStock(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid stock ID: %v", err)})
+		}
+		var adjustment types.StockAdjustment
+		if err := parseBody(c, &adjustment); err != nil {
+			return invalidInput(c, "Invalid stock adjustment", err)
+		}
+		userID, _ := actor(c)
+		item, err := db.AdjustStock(id, adjustment, userID)
+		if err != nil {
+			return salesError(c, "adjust stock", err)
+		}
+		return c.JSON(item)
+	}
+}
+
+func GetStockMovements(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid stock ID: %v", err)})
+		}
+		movements, err := db.GetStockMovements(id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get stock movements: %v", err)})
+		}
+		return c.JSON(movements)
+	}
+}
+
+// Sales Order Handlers
+func GetSalesOrder(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid order ID: %v", err)})
+		}
+		order, err := db.GetSalesOrder(id)
+		if err != nil {
+			return salesError(c, "get sales order", err)
+		}
+		return c.JSON(order)
+	}
+}
+
+// GetSalesOrders gets orders filtered by ?customer_id and ?status
+func GetSalesOrders(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		status := types.OrderStatus(c.Query("status"))
+		if status != "" && !status.IsValid() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid order status %q", status)})
+		}
+		orders, err := db.GetSalesOrders(database.SalesOrderFilter{
+			CustomerID: c.QueryInt("customer_id"),
+			Status:     status,
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get sales orders: %v", err)})
+		}
+		return c.JSON(orders)
+	}
+}
+
+// CreateSalesOrder takes an order, reserving its stock
+func CreateSalesOrder(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var order types.SalesOrder
+		if err := parseBody(c, &order); err != nil {
+			return invalidInput(c, "Invalid sales order data", err)
+		}
+		if _, err := db.GetCustomer(order.CustomerID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid sales order data: unknown customer %d", order.CustomerID)})
+		}
+		userID, _ := actor(c)
+		createdOrder, err := db.CreateSalesOrder(order, userID)
+		if err != nil {
+			return salesError(c, "create sales order", err)
+		}
+		return c.JSON(createdOrder)
+	}
+}
+
+// CancelSalesOrder cancels a reserved order, releasing its stock
+func CancelSalesOrder(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid order ID: %v", err)})
+		}
+		order, err := db.CancelSalesOrder(id)
+		if err != nil {
+			return salesError(c, "cancel sales order", err)
+		}
+		return c.JSON(order)
+	}
+}
+
+// FulfilSalesOrder ships a reserved order and returns its invoice
+func FulfilSalesOrder(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid order ID: %v", err)})
+		}
+		var fulfilment types.FulfilmentRequest
+		if err := parseBody(c, &fulfilment); err != nil {
+			return invalidInput(c, "Invalid fulfilment", err)
+		}
+		order, err := db.GetSalesOrder(id)
+		if err != nil {
+			return salesError(c, "fulfil sales order", err)
+		}
+		if fulfilment.FulfilledDate.Time.Before(order.OrderDate.Time) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid fulfilment: fulfilled_date must not precede the order date"})
+		}
+		userID, _ := actor(c)
+		invoice, err := db.FulfilSalesOrder(id, fulfilment, userID)
+		if err != nil {
+			return salesError(c, "fulfil sales order", err)
+		}
+		return c.JSON(invoice)
+	}
+}
+
+// Invoice Handlers
+func GetInvoice(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid invoice ID: %v", err)})
+		}
+		invoice, err := db.GetInvoice(id)
+		if err != nil {
+			return salesError(c, "get invoice", err)
+		}
+		return c.JSON(invoice)
+	}
+}
+
+// GetInvoices gets invoices filtered by ?customer_id, ?unpaid and a from/to issue date range
+func GetInvoices(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, fromErr := parseQueryDate(c, "from")
+		to, toErr := parseQueryDate(c, "to")
+		if err := validateAll(fromErr, toErr); err != nil {
+			return invalidInput(c, "Invalid invoice range", err)
+		}
+		invoices, err := db.GetInvoices(database.InvoiceFilter{
+			CustomerID: c.QueryInt("customer_id"),
+			UnpaidOnly: c.QueryBool("unpaid"),
+			From:       from,
+			To:         to,
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get invoices: %v", err)})
+		}
+		return c.JSON(invoices)
+	}
+}
+
+// PayInvoice records the payment of an invoice
+func PayInvoice(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid invoice ID: %v", err)})
+		}
+		var payment types.InvoicePayment
+		if err := parseBody(c, &payment); err != nil {
+			return invalidInput(c, "Invalid payment", err)
+		}
+		invoice, err := db.PayInvoice(id, payment.PaidDate)
+		if err != nil {
+			return salesError(c, "pay invoice", err)
+		}
+		return c.JSON(invoice)
+	}
+}
+
+// GetApiaryProfits gets each apiary's revenue, expenses and profit over a from/to
+// range, from the start of the current year to today by default
+func GetApiaryProfits(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, fromErr := parseQueryDate(c, "from")
+		to, toErr := parseQueryDate(c, "to")
+		if err := validateAll(fromErr, toErr); err != nil {
+			return invalidInput(c, "Invalid profit range", err)
+		}
+		now := time.Now()
+		if !from.Valid {
+			from.SetValid(time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.UTC))
+		}
+		if !to.Valid {
+			to.SetValid(now)
+		}
+		if to.Time.Before(from.Time) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid profit range: to must not precede from"})
+		}
+		profits, err := db.GetApiaryProfits(from.Time, to.Time)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get apiary profits: %v", err)})
+		}
+		return c.JSON(profits)
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/guregu/null"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	"github.com/orientallines/beesbiz/internal/scale"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+	"github.com/orientallines/beesbiz/internal/weather"
+)
+
+// Hive scale handlers
+
+// parseFlowRange reads the from/to range flows are looked for in, from the start
+// of the current season to yesterday by default, the last complete day
+func parseFlowRange(c *fiber.Ctx) (time.Time, time.Time, error) {
+	from, fromErr := parseQueryDate(c, "from")
+	to, toErr := parseQueryDate(c, "to")
+	if err := validateAll(fromErr, toErr); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	through := today.AddDate(0, 0, -1)
+	if to.Valid && to.Time.Before(through) {
+		through = to.Time.UTC()
+	}
+	start, _ := weather.SeasonBounds(weather.CurrentSeason(today), today)
+	if from.Valid {
+		start = from.Time.UTC()
+	}
+	if start.After(through) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must not be after to or today")
+	}
+	return start, through, nil
+}
+
+// GetHiveWeightDays gets a hive's daily weight summaries over a from/to range
+func GetHiveWeightDays(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		hiveID, err := c.ParamsInt("hiveID")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid hive ID: %v", err)})
+		}
+		from, fromErr := parseQueryDate(c, "from")
+		to, toErr := parseQueryDate(c, "to")
+		if err := validateAll(fromErr, toErr); err != nil {
+			return invalidInput(c, "Invalid weight range", err)
+		}
+		days, err := db.GetHiveWeightDays(hiveID, from, to)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get hive weight days: %v", err)})
+		}
+		return c.JSON(days)
+	}
+}
+
+// GetHiveFlows gets the nectar flows of a hive over a from/to range, the current
+// season by default
+func GetHiveFlows(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		hiveID, err := c.ParamsInt("hiveID")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid hive ID: %v", err)})
+		}
+		from, through, err := parseFlowRange(c)
+		if err != nil {
+			return invalidInput(c, "Invalid flow range", err)
+		}
+		days, err := db.GetHiveWeightDays(hiveID, null.TimeFrom(from), null.TimeFrom(through))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get hive flows: %v", err)})
+		}
+		return c.JSON(scale.Flows(days, through))
+	}
+}
+
+// GetApiaryFlows gets the nectar flows of an apiary, aggregated over its weighed
+// hives, over a from/to range, the current season by default
+func GetApiaryFlows(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		apiaryID, err := c.ParamsInt("apiaryID")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid apiary ID: %v", err)})
+		}
+		from, through, err := parseFlowRange(c)
+		if err != nil {
+			return invalidInput(c, "Invalid flow range", err)
+		}
+		days, err := db.GetApiaryWeightDays(apiaryID, null.TimeFrom(from), null.TimeFrom(through))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get apiary flows: %v", err)})
+		}
+		return c.JSON(scale.ApiaryFlows(apiaryID, days, from, through))
+	}
+}
+
+// GetHiveWeightEvents gets the events read from hive weights, filtered by
+// ?hive_id, ?apiary_id, ?kind and a from/to range
+func GetHiveWeightEvents(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, fromErr := parseQueryDate(c, "from")
+		to, toErr := parseQueryDate(c, "to")
+		if err := validateAll(fromErr, toErr); err != nil {
+			return invalidInput(c, "Invalid event range", err)
+		}
+		kind := types.WeightEventKind(c.Query("kind"))
+		if kind != "" && !kind.IsValid() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid weight event kind %q", kind)})
+		}
+		events, err := db.GetHiveWeightEvents(database.WeightEventFilter{
+			HiveID:   c.QueryInt("hive_id"),
+			ApiaryID: c.QueryInt("apiary_id"),
+			Kind:     kind,
+			From:     from,
+			To:       to,
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get hive weight events: %v", err)})
+		}
+		return c.JSON(events)
+	}
+}
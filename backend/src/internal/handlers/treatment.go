@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/guregu/null"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// TreatmentProduct Handlers
+func GetTreatmentProduct(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid treatment product ID: %v", err)})
+		}
+		product, err := db.GetTreatmentProduct(id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get treatment product: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get treatment product: %v", err)})
+		}
+		return c.JSON(product)
+	}
+}
+
+func CreateTreatmentProduct(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		product := types.TreatmentProduct{Active: true}
+		if err := parseBody(c, &product); err != nil {
+			return invalidInput(c, "Invalid treatment product data", err)
+		}
+		createdProduct, err := db.CreateTreatmentProduct(product)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create treatment product: %v", err)})
+		}
+		return c.JSON(createdProduct)
+	}
+}
+
+func UpdateTreatmentProduct(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var product types.TreatmentProduct
+		if err := parseBody(c, &product); err != nil {
+			return invalidInput(c, "Invalid treatment product data", err)
+		}
+		updatedProduct, err := db.UpdateTreatmentProduct(product)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update treatment product: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update treatment product: %v", err)})
+		}
+		return c.JSON(updatedProduct)
+	}
+}
+
+func DeleteTreatmentProduct(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid treatment product ID: %v", err)})
+		}
+		if err := db.DeleteTreatmentProduct(id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete treatment product: %v", err)})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func GetAllTreatmentProducts(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		products, err := db.GetAllTreatmentProducts()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get all treatment products: %v", err)})
+		}
+		return c.JSON(products)
+	}
+}
+
+// TreatmentApplication Handlers
+func GetTreatmentApplication(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid treatment application ID: %v", err)})
+		}
+		application, err := db.GetTreatmentApplication(id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get treatment application: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get treatment application: %v", err)})
+		}
+		return c.JSON(application)
+	}
+}
+
+// checkTreatmentApplication verifies the product exists and the community, if given, lives in the hive
+func checkTreatmentApplication(db *database.DB, application types.TreatmentApplication) error {
+	if _, err := db.GetTreatmentProduct(application.ProductID); err != nil {
+		return fmt.Errorf("unknown treatment product %d", application.ProductID)
+	}
+	if !application.CommunityID.Valid {
+		return nil
+	}
+	communities, err := db.GetAllBeeCommunitiesByHiveID(application.HiveID)
+	if err != nil {
+		return err
+	}
+	for _, community := range communities {
+		if int64(community.CommunityID) == application.CommunityID.Int64 {
+			return nil
+		}
+	}
+	return fmt.Errorf("community %d is not in hive %d", application.CommunityID.Int64, application.HiveID)
+}
+
+func CreateTreatmentApplication(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var application types.TreatmentApplication
+		if err := parseBody(c, &application); err != nil {
+			return invalidInput(c, "Invalid treatment application data", err)
+		}
+		if err := checkTreatmentApplication(db, application); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid treatment application data: %v", err)})
+		}
+		userID, _ := actor(c)
+		application.AppliedBy = null.IntFrom(int64(userID))
+		createdApplication, err := db.CreateTreatmentApplication(application)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create treatment application: %v", err)})
+		}
+		return c.JSON(createdApplication)
+	}
+}
+
+func UpdateTreatmentApplication(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var application types.TreatmentApplication
+		if err := parseBody(c, &application); err != nil {
+			return invalidInput(c, "Invalid treatment application data", err)
+		}
+		if err := checkTreatmentApplication(db, application); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid treatment application data: %v", err)})
+		}
+		updatedApplication, err := db.UpdateTreatmentApplication(application)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update treatment application: %v", err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update treatment application: %v", err)})
+		}
+		return c.JSON(updatedApplication)
+	}
+}
+
+func DeleteTreatmentApplication(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid treatment application ID: %v", err)})
+		}
+		if err := db.DeleteTreatmentApplication(id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete treatment application: %v", err)})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// GetTreatmentApplications gets applications filtered by ?hive_id, ?product_id and a from/to range
+func GetTreatmentApplications(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, fromErr := parseQueryDate(c, "from")
+		to, toErr := parseQueryDate(c, "to")
+		if err := validateAll(fromErr, toErr); err != nil {
+			return invalidInput(c, "Invalid treatment range", err)
+		}
+		applications, err := db.GetTreatmentApplications(database.TreatmentFilter{
+			HiveID:    c.QueryInt("hive_id"),
+			ProductID: c.QueryInt("product_id"),
+			From:      from,
+			To:        to,
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get treatment applications: %v", err)})
+		}
+		return c.JSON(applications)
+	}
+}
+
+// GetHiveTreatments gets the treatment history of a hive
+func GetHiveTreatments(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		hiveID, err := c.ParamsInt("hiveID")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid hive ID: %v", err)})
+		}
+		applications, err := db.GetTreatmentApplications(database.TreatmentFilter{HiveID: hiveID})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get hive treatments: %v", err)})
+		}
+		return c.JSON(applications)
+	}
+}
+
+// GetHiveWithdrawalStatus reports whether honey may be harvested from a hive on ?date (today by default)
+func GetHiveWithdrawalStatus(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		hiveID, err := c.ParamsInt("hiveID")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid hive ID: %v", err)})
+		}
+		date, err := parseQueryDate(c, "date")
+		if err != nil {
+			return invalidInput(c, "Invalid withdrawal date", err)
+		}
+		if !date.Valid {
+			date = null.TimeFrom(time.Now())
+		}
+		applications, err := db.GetWithdrawalApplications(hiveID, date)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get withdrawal status: %v", err)})
+		}
+		return c.JSON(fiber.Map{
+			"hive_id":       hiveID,
+			"date":          date,
+			"in_withdrawal": len(applications) > 0,
+			"applications":  applications,
+		})
+	}
+}
+
+// GetTreatmentEfficacy gets the average mite count reduction per product
+func GetTreatmentEfficacy(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		efficacy, err := db.GetTreatmentEfficacy()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get treatment efficacy: %v", err)})
+		}
+		return c.JSON(efficacy)
+	}
+}
+
+// checkWithdrawal rejects harvests from hives within a treatment withdrawal period
+// unless the harvest carries an override reason, in which case the overriding user
+// is recorded. It reports false once it has responded, with the error to return.
+func checkWithdrawal(c *fiber.Ctx, db *database.DB, harvest *types.HoneyHarvest) (bool, error) {
+	if harvest.WithdrawalOverrideReason.Valid {
+		userID, _ := actor(c)
+		harvest.OverriddenBy = null.IntFrom(int64(userID))
+		return true, nil
+	}
+	harvest.OverriddenBy = null.Int{}
+	applications, err := db.GetWithdrawalApplications(harvest.HiveID, harvest.HarvestDate)
+	if err != nil {
+		return false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to check withdrawal period: %v", err)})
+	}
+	if len(applications) > 0 {
+		return false, c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":        fmt.Sprintf("Hive %d is within a treatment withdrawal period; provide withdrawal_override_reason to harvest anyway", harvest.HiveID),
+			"applications": applications,
+		})
+	}
+	return true, nil
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/orientallines/beesbiz/internal/database"
+	"github.com/orientallines/beesbiz/internal/validation"
 	types "github.com/orientallines/beesbiz/internal/types/db"
 )
 
@@ -36,9 +38,24 @@ func GetUser(db *database.DB) fiber.Handler {
 func CreateUser(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var user types.User
-		if err := c.BodyParser(&user); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid user data: %v", err)})
+		if err := parseBody(c, &user); err != nil {
+			return invalidInput(c, "Invalid user data", err)
 		}
+
+		// Managers can only onboard workers, creating privileged accounts requires an admin
+		if _, actorRole := actor(c); actorRole != types.Admin && user.Role != types.Worker {
+			return forbidden(c, "only admins can create managers or admins")
+		}
+
+		if err := validation.Var("password", user.Password, "min=10,max=72,password"); err != nil {
+			return invalidInput(c, "Invalid user data", err)
+		}
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not hash password"})
+		}
+		user.Password = string(hashedPassword)
+
 		createdUser, err := db.CreateUser(user)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create user: %v", err)})
@@ -50,9 +67,46 @@ func CreateUser(db *database.DB) fiber.Handler {
 func UpdateUser(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var user types.User
-		if err := c.BodyParser(&user); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid user data: %v", err)})
+		if err := parseBody(c, &user); err != nil {
+			return invalidInput(c, "Invalid user data", err)
+		}
+
+		existing, err := db.GetUser(user.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+
+		actorID, actorRole := actor(c)
+		allowed, err := canManageUser(db, actorID, actorRole, existing)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to check permissions: %v", err)})
+		}
+		if !allowed {
+			return forbidden(c, "you can only manage workers in your own groups")
+		}
+		if user.Role != existing.Role {
+			if actorRole != types.Admin {
+				return forbidden(c, "only admins can change roles")
+			}
+			if lastAdmin, err := isLastAdmin(db, existing); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to check admins: %v", err)})
+			} else if lastAdmin {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "The last admin cannot be demoted"})
+			}
+		}
+
+		// The stored hash is sent back unchanged unless a new password is set
+		if user.Password != existing.Password {
+			if err := validation.Var("password", user.Password, "min=10,max=72,password"); err != nil {
+				return invalidInput(c, "Invalid user data", err)
+			}
+			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not hash password"})
+			}
+			user.Password = string(hashedPassword)
 		}
+
 		updatedUser, err := db.UpdateUser(user)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update user: %v", err)})
@@ -67,6 +121,26 @@ func DeleteUser(db *database.DB) fiber.Handler {
 		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid user ID: %v", err)})
 		}
+
+		target, err := db.GetUser(id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+
+		actorID, actorRole := actor(c)
+		allowed, err := canManageUser(db, actorID, actorRole, target)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to check permissions: %v", err)})
+		}
+		if !allowed {
+			return forbidden(c, "you can only manage workers in your own groups")
+		}
+		if lastAdmin, err := isLastAdmin(db, target); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to check admins: %v", err)})
+		} else if lastAdmin {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "The last admin cannot be deleted"})
+		}
+
 		if err := db.DeleteUser(id); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete user: %v", err)})
 		}
@@ -101,13 +175,17 @@ func GetUserAllowedRegions(db *database.DB) fiber.Handler {
 func ModifyUserRole(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		type RoleUpdate struct {
-			UserID int        `json:"user_id"`
-			Role   types.Role `json:"role"`
+			UserID int        `json:"user_id" validate:"gt=0"`
+			Role   types.Role `json:"role" validate:"required,enum"`
+		}
+
+		if _, actorRole := actor(c); actorRole != types.Admin {
+			return forbidden(c, "only admins can change roles")
 		}
 
 		var update RoleUpdate
-		if err := c.BodyParser(&update); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid request data: %v", err)})
+		if err := parseBody(c, &update); err != nil {
+			return invalidInput(c, "Invalid request data", err)
 		}
 
 		// Get existing user
@@ -116,6 +194,14 @@ func ModifyUserRole(db *database.DB) fiber.Handler {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 		}
 
+		if update.Role != types.Admin {
+			if lastAdmin, err := isLastAdmin(db, user); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to check admins: %v", err)})
+			} else if lastAdmin {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "The last admin cannot be demoted"})
+			}
+		}
+
 		// Update role
 		user.Role = update.Role
 		updatedUser, err := db.UpdateUser(user)
@@ -130,29 +216,40 @@ func ModifyUserRole(db *database.DB) fiber.Handler {
 func ModifyUserAllowedRegions(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		type RegionsUpdate struct {
-			UserID    int   `json:"user_id"`
-			RegionIDs []int `json:"region_ids"`
+			UserID    int   `json:"user_id" validate:"gt=0"`
+			RegionIDs []int `json:"region_ids" validate:"dive,gt=0"`
 		}
 
 		var update RegionsUpdate
-		if err := c.BodyParser(&update); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid request data: %v", err)})
+		if err := parseBody(c, &update); err != nil {
+			return invalidInput(c, "Invalid request data", err)
 		}
 
-		// First, delete existing allowed regions
-		if err := db.DeleteAllowedRegionsForUser(update.UserID); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update allowed regions: %v", err)})
+		target, err := db.GetUser(update.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 		}
 
-		// Then add new allowed regions
-		for _, regionID := range update.RegionIDs {
-			allowedRegion := types.AllowedRegion{
-				UserID:   update.UserID,
-				RegionID: regionID,
-			}
-			if _, err := db.CreateAllowedRegion(allowedRegion); err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to add allowed region: %v", err)})
-			}
+		actorID, actorRole := actor(c)
+		allowed, err := canManageUser(db, actorID, actorRole, target)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to check permissions: %v", err)})
+		}
+		if !allowed {
+			return forbidden(c, "you can only manage workers in your own groups")
+		}
+
+		// Managers can only hand out, and take back, regions they hold themselves
+		scope, err := regionScope(db, c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get your allowed regions: %v", err)})
+		}
+		if scope != nil && !regionsSubset(update.RegionIDs, scope) {
+			return forbidden(c, "you can only assign regions you have access to")
+		}
+
+		if err := db.ReplaceAllowedRegions(update.UserID, update.RegionIDs, scope); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update allowed regions: %v", err)})
 		}
 
 		// Return updated list of allowed regions
@@ -195,8 +292,13 @@ func GetWorkerGroup(db *database.DB) fiber.Handler {
 func CreateWorkerGroup(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var group types.WorkerGroup
-		if err := c.BodyParser(&group); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid worker group data: %v", err)})
+		if err := parseBody(c, &group); err != nil {
+			return invalidInput(c, "Invalid worker group data", err)
+		}
+
+		// Managers always create groups for themselves
+		if actorID, actorRole := actor(c); actorRole != types.Admin {
+			group.ManagerID = actorID
 		}
 		
 		createdGroup, err := db.CreateWorkerGroup(group)
@@ -212,13 +314,36 @@ func CreateWorkerGroup(db *database.DB) fiber.Handler {
 func AddGroupMember(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		type AddMemberRequest struct {
-			GroupID  int `json:"group_id"`
-			WorkerID int `json:"worker_id"`
+			GroupID  int `json:"group_id" validate:"gt=0"`
+			WorkerID int `json:"worker_id" validate:"gt=0"`
 		}
 		
 		var req AddMemberRequest
-		if err := c.BodyParser(&req); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid request data: %v", err)})
+		if err := parseBody(c, &req); err != nil {
+			return invalidInput(c, "Invalid request data", err)
+		}
+
+		group, err := db.GetWorkerGroup(req.GroupID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Worker group not found"})
+		}
+		actorID, actorRole := actor(c)
+		if !canManageGroup(actorID, actorRole, group) {
+			return forbidden(c, "you can only manage your own worker groups")
+		}
+		worker, err := db.GetUser(req.WorkerID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+		if worker.Role != types.Worker {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Only workers can be added to a worker group"})
+		}
+		allowed, err := canAssignWorker(db, actorID, actorRole, worker)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to check worker groups: %v", err)})
+		}
+		if !allowed {
+			return forbidden(c, "this worker belongs to another manager's group")
 		}
 		
 		if err := db.AddWorkerToGroup(req.GroupID, req.WorkerID); err != nil {
@@ -241,6 +366,14 @@ func RemoveGroupMember(db *database.DB) fiber.Handler {
 		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid worker ID"})
 		}
+
+		group, err := db.GetWorkerGroup(groupID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Worker group not found"})
+		}
+		if actorID, actorRole := actor(c); !canManageGroup(actorID, actorRole, group) {
+			return forbidden(c, "you can only manage your own worker groups")
+		}
 		
 		if err := db.RemoveWorkerFromGroup(groupID, workerID); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to remove worker from group: %v", err)})
@@ -322,6 +455,13 @@ func DeleteWorkerGroup(db *database.DB) fiber.Handler {
 		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid worker group ID: %v", err)})
 		}
+		group, err := db.GetWorkerGroup(id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Worker group not found"})
+		}
+		if actorID, actorRole := actor(c); !canManageGroup(actorID, actorRole, group) {
+			return forbidden(c, "you can only manage your own worker groups")
+		}
 		if err := db.DeleteWorkerGroup(id); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete worker group: %v", err)})
 		}
@@ -351,9 +491,20 @@ func UpdateWorkerGroup(db *database.DB) fiber.Handler {
 		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid worker group ID: %v", err)})
 		}
+		existing, err := db.GetWorkerGroup(id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Worker group not found"})
+		}
+		if actorID, actorRole := actor(c); !canManageGroup(actorID, actorRole, existing) {
+			return forbidden(c, "you can only manage your own worker groups")
+		}
 		var group types.WorkerGroup
 		if err := c.BodyParser(&group); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid worker group data: %v", err)})
+			return invalidInput(c, "Invalid worker group data", err)
+		}
+		// Only the name can be changed, so the remaining fields are not validated
+		if err := validation.Var("group_name", group.GroupName, "required,max=100"); err != nil {
+			return invalidInput(c, "Invalid worker group data", err)
 		}
 		updatedGroup, err := db.UpdateWorkerGroup(id, group)
 		if err != nil {
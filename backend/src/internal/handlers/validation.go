@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/guregu/null"
+
+	"github.com/orientallines/beesbiz/internal/validation"
+)
+
+// normalizer is implemented by payloads that tidy their fields before validation
+type normalizer interface {
+	Normalize()
+}
+
+// parseBody parses the request body into out, normalizes it and validates it
+// against its `validate` tags
+func parseBody(c *fiber.Ctx, out interface{}) error {
+	if err := c.BodyParser(out); err != nil {
+		return err
+	}
+	if n, ok := out.(normalizer); ok {
+		n.Normalize()
+	}
+	return validation.Struct(out)
+}
+
+// invalidInput responds with 400 Bad Request, listing the failed fields for validation errors
+func invalidInput(c *fiber.Ctx, message string, err error) error {
+	body := fiber.Map{"error": fmt.Sprintf("%s: %v", message, err)}
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		body["fields"] = fieldErrs
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(body)
+}
+
+// validateAll returns the first non-nil error of the given validation results
+func validateAll(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma separated query value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseQueryDate parses an optional YYYY-MM-DD query parameter
+func parseQueryDate(c *fiber.Ctx, name string) (null.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return null.Time{}, nil
+	}
+	if err := validation.Var(name, value, "datetime=2006-01-02"); err != nil {
+		return null.Time{}, err
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return null.Time{}, err
+	}
+	return null.TimeFrom(date), nil
+}
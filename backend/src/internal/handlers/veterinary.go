@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/orientallines/beesbiz/internal/database"
+	"github.com/orientallines/beesbiz/internal/rabbitmq"
 	types "github.com/orientallines/beesbiz/internal/types/db"
 )
 
@@ -30,8 +33,8 @@ func GetVeterinaryPassport(db *database.DB) fiber.Handler {
 func CreateVeterinaryPassport(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var passport types.VeterinaryPassport
-		if err := c.BodyParser(&passport); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid passport data: %v", err)})
+		if err := parseBody(c, &passport); err != nil {
+			return invalidInput(c, "Invalid passport data", err)
 		}
 		createdPassport, err := db.CreateVeterinaryPassport(passport)
 		if err != nil {
@@ -45,8 +48,8 @@ func CreateVeterinaryPassport(db *database.DB) fiber.Handler {
 func UpdateVeterinaryPassport(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var passport types.VeterinaryPassport
-		if err := c.BodyParser(&passport); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid passport data: %v", err)})
+		if err := parseBody(c, &passport); err != nil {
+			return invalidInput(c, "Invalid passport data", err)
 		}
 		updatedPassport, err := db.UpdateVeterinaryPassport(passport)
 		if err != nil {
@@ -99,31 +102,46 @@ func GetVeterinaryRecord(db *database.DB) fiber.Handler {
 }
 
 // CreateVeterinaryRecord creates a new veterinary record
-func CreateVeterinaryRecord(db *database.DB) fiber.Handler {
+//
+// Notifiable diseases put the surrounding apiaries under quarantine straight away,
+// unless an active outbreak of the disease already covers the apiary.
+func CreateVeterinaryRecord(db *database.DB, rmq *rabbitmq.RabbitMQ) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var record types.VeterinaryRecord
-		if err := c.BodyParser(&record); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid record data: %v", err)})
+		if err := parseBody(c, &record); err != nil {
+			return invalidInput(c, "Invalid record data", err)
 		}
-		createdRecord, err := db.CreateVeterinaryRecord(record)
+		userID, _ := actor(c)
+		createdRecord, outbreak, err := db.CreateVeterinaryRecord(record, userID)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to create veterinary record: %v", err)})
 		}
+		if outbreak.OutbreakID != 0 {
+			publishOutbreak(rmq, outbreak)
+		}
 		return c.JSON(createdRecord)
 	}
 }
 
-// UpdateVeterinaryRecord updates a veterinary record
-func UpdateVeterinaryRecord(db *database.DB) fiber.Handler {
+// UpdateVeterinaryRecord updates a veterinary record, declaring an outbreak when
+// the correction diagnoses a notifiable disease
+func UpdateVeterinaryRecord(db *database.DB, rmq *rabbitmq.RabbitMQ) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var record types.VeterinaryRecord
-		if err := c.BodyParser(&record); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid record data: %v", err)})
+		if err := parseBody(c, &record); err != nil {
+			return invalidInput(c, "Invalid record data", err)
 		}
-		updatedRecord, err := db.UpdateVeterinaryRecord(record)
+		userID, _ := actor(c)
+		updatedRecord, outbreak, err := db.UpdateVeterinaryRecord(record, userID)
 		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Veterinary record not found"})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update veterinary record: %v", err)})
 		}
+		if outbreak.OutbreakID != 0 {
+			publishOutbreak(rmq, outbreak)
+		}
 		return c.JSON(updatedRecord)
 	}
 }
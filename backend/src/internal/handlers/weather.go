@@ -1,14 +1,48 @@
 package handlers
 
 import (
+	"bytes"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/guregu/null"
 
 	"github.com/orientallines/beesbiz/internal/database"
 	types "github.com/orientallines/beesbiz/internal/types/db"
+	"github.com/orientallines/beesbiz/internal/validation"
+	"github.com/orientallines/beesbiz/internal/weather"
 )
 
+// stationTokenHeader carries a weather station's push token
+const stationTokenHeader = "X-Station-Token"
+
+// defaultWeatherDays is how far back daily weather goes when no range is given
+const defaultWeatherDays = 30
+
+// minWeatherSeason and maxWeatherSeasons bound the seasons weather production
+// is asked for
+const (
+	minWeatherSeason  = 1900
+	maxWeatherSeasons = 10
+)
+
+// weatherError maps weather errors to their status codes
+func weatherError(c *fiber.Ctx, action string, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	case errors.Is(err, database.ErrWeatherExists):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+}
+
 // WeatherData handlers
 
 // GetWeatherData gets weather data by ID
@@ -30,8 +64,8 @@ func GetWeatherData(db *database.DB) fiber.Handler {
 func CreateWeatherData(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var weatherData types.WeatherData
-		if err := c.BodyParser(&weatherData); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid weather data: %v", err)})
+		if err := parseBody(c, &weatherData); err != nil {
+			return invalidInput(c, "Invalid weather data", err)
 		}
 		createdWeatherData, err := db.CreateWeatherData(weatherData)
 		if err != nil {
@@ -45,12 +79,12 @@ func CreateWeatherData(db *database.DB) fiber.Handler {
 func UpdateWeatherData(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var weatherData types.WeatherData
-		if err := c.BodyParser(&weatherData); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid weather data: %v", err)})
+		if err := parseBody(c, &weatherData); err != nil {
+			return invalidInput(c, "Invalid weather data", err)
 		}
 		updatedWeatherData, err := db.UpdateWeatherData(weatherData)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update weather data: %v", err)})
+			return weatherError(c, "update weather data", err)
 		}
 		return c.JSON(updatedWeatherData)
 	}
@@ -70,13 +104,370 @@ func DeleteWeatherData(db *database.DB) fiber.Handler {
 	}
 }
 
-// GetAllWeatherData gets all weather data
+// GetAllWeatherData gets weather data filtered by ?region_id, a from/to range and
+// ?granularity (hourly or daily)
 func GetAllWeatherData(db *database.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		weatherDataList, err := db.GetAllWeatherData()
+		from, fromErr := parseQueryDate(c, "from")
+		to, toErr := parseQueryDate(c, "to")
+		if err := validateAll(fromErr, toErr); err != nil {
+			return invalidInput(c, "Invalid weather range", err)
+		}
+		filter := database.WeatherFilter{RegionID: c.QueryInt("region_id"), From: from, To: to}
+		switch granularity := c.Query("granularity"); granularity {
+		case "":
+		case "hourly", "daily":
+			filter.Hourly = null.BoolFrom(granularity == "hourly")
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid granularity %q: expected hourly or daily", granularity)})
+		}
+		weatherDataList, err := db.GetAllWeatherData(filter)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get all weather data: %v", err)})
 		}
 		return c.JSON(weatherDataList)
 	}
 }
+
+// GetDailyWeather gets a required ?region_id's weather per day over a from/to
+// range, the last 30 days by default, aggregating hourly readings where a day has
+// no daily one
+func GetDailyWeather(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		regionID := c.QueryInt("region_id")
+		if regionID <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid region ID: region_id is required"})
+		}
+		from, fromErr := parseQueryDate(c, "from")
+		to, toErr := parseQueryDate(c, "to")
+		if err := validateAll(fromErr, toErr); err != nil {
+			return invalidInput(c, "Invalid weather range", err)
+		}
+		if !to.Valid {
+			to = null.TimeFrom(time.Now().UTC().Truncate(24 * time.Hour))
+		}
+		if !from.Valid {
+			from = null.TimeFrom(to.Time.AddDate(0, 0, -defaultWeatherDays))
+		}
+		days, err := db.GetDailyWeather(regionID, from, to)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get daily weather: %v", err)})
+		}
+		return c.JSON(days)
+	}
+}
+
+// GetWeatherForecast gets provider's daily forecast for a required ?region_id,
+// ?days ahead (7 by default)
+func GetWeatherForecast(db *database.DB, provider weather.Provider) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		days := c.QueryInt("days", 7)
+		if days < 1 || days > weather.MaxForecastDays {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid days: must be between 1 and %d", weather.MaxForecastDays)})
+		}
+		region, err := db.GetRegion(c.QueryInt("region_id"))
+		if err != nil {
+			return weatherError(c, "get region", err)
+		}
+		forecast, err := provider.Forecast(c.UserContext(), region, days)
+		if err != nil {
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get weather forecast: %v", err)})
+		}
+		return c.JSON(types.WeatherForecast{RegionID: region.RegionID, Provider: provider.Name(), Days: forecast})
+	}
+}
+
+// GetWeatherProduction relates a required ?region_id's weather to the honey
+// harvested in it for each of ?seasons (years, the current season by default),
+// as a time series by ?interval (day or week, weekly by default)
+func GetWeatherProduction(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		interval := types.AnalyticsInterval(c.Query("interval", string(types.IntervalWeek)))
+		if !interval.IsValid() {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid interval %q: must be day or week", interval)})
+		}
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		current := weather.CurrentSeason(today)
+		var seasons []int
+		for _, value := range splitList(c.Query("seasons")) {
+			season, err := strconv.Atoi(value)
+			if err != nil || season < minWeatherSeason || season > current {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid season %q: must be a year from %d to %d", value, minWeatherSeason, current)})
+			}
+			seasons = append(seasons, season)
+		}
+		if len(seasons) == 0 {
+			seasons = []int{current}
+		}
+		if len(seasons) > maxWeatherSeasons {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid seasons: at most %d at a time", maxWeatherSeasons)})
+		}
+
+		region, err := db.GetRegion(c.QueryInt("region_id"))
+		if err != nil {
+			return weatherError(c, "get region", err)
+		}
+		scope, err := regionScope(db, c)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get your allowed regions: %v", err)})
+		}
+		if scope != nil && !regionsSubset([]int{region.RegionID}, scope) {
+			return forbidden(c, "you do not have access to this region")
+		}
+
+		productions := make([]types.WeatherProduction, 0, len(seasons))
+		for _, season := range seasons {
+			start, end := weather.SeasonBounds(season, today)
+			days, err := db.GetWeatherProduction(region.RegionID, null.TimeFrom(start), null.TimeFrom(end))
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get weather production: %v", err)})
+			}
+			production := weather.Production(days, interval)
+			production.RegionID = region.RegionID
+			production.Season = season
+			production.Start = null.TimeFrom(start)
+			production.End = null.TimeFrom(end)
+			productions = append(productions, production)
+		}
+		return c.JSON(productions)
+	}
+}
+
+// resolveReadings ties parsed readings to the regions of their stations and
+// validates them, returning every line error
+func resolveReadings(db *database.DB, readings []weather.Reading) ([]types.WeatherData, []types.WeatherImportError, error) {
+	var codes []string
+	regionIDs := map[int]bool{}
+	for _, reading := range readings {
+		if reading.RegionID == 0 {
+			codes = append(codes, reading.Station)
+		} else {
+			regionIDs[reading.RegionID] = true
+		}
+	}
+	stationRegions, err := db.GetWeatherStationRegions(codes)
+	if err != nil {
+		return nil, nil, err
+	}
+	for regionID := range regionIDs {
+		if _, err := db.GetRegion(regionID); err != nil {
+			regionIDs[regionID] = false
+		}
+	}
+
+	resolved := make([]types.WeatherData, 0, len(readings))
+	var lineErrs []types.WeatherImportError
+	for _, reading := range readings {
+		if reading.RegionID == 0 {
+			regionID, ok := stationRegions[reading.Station]
+			if !ok {
+				lineErrs = append(lineErrs, types.WeatherImportError{Line: reading.Line, Error: fmt.Sprintf("unknown weather station %q", reading.Station)})
+				continue
+			}
+			reading.RegionID = regionID
+		} else if !regionIDs[reading.RegionID] {
+			lineErrs = append(lineErrs, types.WeatherImportError{Line: reading.Line, Error: fmt.Sprintf("unknown region %d", reading.RegionID)})
+			continue
+		}
+		if err := validation.Struct(&reading.WeatherData); err != nil {
+			lineErrs = append(lineErrs, types.WeatherImportError{Line: reading.Line, Error: err.Error()})
+			continue
+		}
+		resolved = append(resolved, reading.WeatherData)
+	}
+	sort.SliceStable(lineErrs, func(i, j int) bool { return lineErrs[i].Line < lineErrs[j].Line })
+	return resolved, lineErrs, nil
+}
+
+// ImportWeatherData imports readings from a file uploaded as the "file" form field
+// or sent as the request body, in the ?format csv (the default), metar or synop
+//
+// METAR and SYNOP reports name registered weather stations. Nothing is imported
+// unless every line is valid; a reading replaces the region's reading for the
+// same date and hour.
+func ImportWeatherData(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body io.Reader = bytes.NewReader(c.Body())
+		if file, err := c.FormFile("file"); err == nil {
+			upload, err := file.Open()
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid weather file: %v", err)})
+			}
+			defer upload.Close()
+			body = upload
+		}
+
+		var readings []weather.Reading
+		var lineErrs []types.WeatherImportError
+		var err error
+		switch format := c.Query("format", "csv"); format {
+		case "csv":
+			readings, lineErrs, err = weather.ParseCSV(body)
+		case "metar":
+			readings, lineErrs = weather.ParseMETAR(body, time.Now())
+		case "synop":
+			readings, lineErrs, err = weather.ParseSYNOP(body, time.Now())
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid format %q: expected csv, metar or synop", format)})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid weather file: %v", err)})
+		}
+
+		resolved, resolveErrs, err := resolveReadings(db, readings)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to resolve weather stations: %v", err)})
+		}
+		lineErrs = append(lineErrs, resolveErrs...)
+		if len(lineErrs) > 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid weather file: some lines are invalid", "lines": lineErrs})
+		}
+		if len(resolved) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid weather file: no readings"})
+		}
+
+		saved, err := db.SaveWeatherReadings(resolved)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to import weather data: %v", err)})
+		}
+		return c.JSON(types.WeatherImportResult{Imported: len(saved), Readings: saved})
+	}
+}
+
+// Weather Station Handlers
+
+// GetWeatherStations gets all weather stations
+func GetWeatherStations(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		stations, err := db.GetWeatherStations()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to get weather stations: %v", err)})
+		}
+		return c.JSON(stations)
+	}
+}
+
+// CreateWeatherStation registers a weather station, returning its push token once
+func CreateWeatherStation(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var station types.WeatherStation
+		if err := parseBody(c, &station); err != nil {
+			return invalidInput(c, "Invalid weather station data", err)
+		}
+		if _, err := db.GetRegion(station.RegionID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid weather station data: unknown region %d", station.RegionID)})
+		}
+		token, err := generateToken()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to generate station token: %v", err)})
+		}
+		station.TokenHash = hashToken(token)
+		createdStation, err := db.CreateWeatherStation(station)
+		if err != nil {
+			return weatherError(c, "create weather station", err)
+		}
+		createdStation.Token = token
+		return c.JSON(createdStation)
+	}
+}
+
+// UpdateWeatherStation changes a weather station's code, name and region
+func UpdateWeatherStation(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var station types.WeatherStation
+		if err := parseBody(c, &station); err != nil {
+			return invalidInput(c, "Invalid weather station data", err)
+		}
+		if _, err := db.GetRegion(station.RegionID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid weather station data: unknown region %d", station.RegionID)})
+		}
+		updatedStation, err := db.UpdateWeatherStation(station)
+		if err != nil {
+			return weatherError(c, "update weather station", err)
+		}
+		return c.JSON(updatedStation)
+	}
+}
+
+// RotateWeatherStationToken replaces a weather station's push token, returning the new one once
+func RotateWeatherStationToken(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid weather station ID: %v", err)})
+		}
+		token, err := generateToken()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to generate station token: %v", err)})
+		}
+		station, err := db.SetWeatherStationToken(id, hashToken(token))
+		if err != nil {
+			return weatherError(c, "rotate weather station token", err)
+		}
+		station.Token = token
+		return c.JSON(station)
+	}
+}
+
+// DeleteWeatherStation deletes a weather station
+func DeleteWeatherStation(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid weather station ID: %v", err)})
+		}
+		if err := db.DeleteWeatherStation(id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to delete weather station: %v", err)})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// PushStationReadings saves readings pushed by a weather station as hourly
+// readings of its region
+//
+// The station authenticates with its token in the X-Station-Token header instead of a user session
+func PushStationReadings(db *database.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token := c.Get(stationTokenHeader)
+		if token == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Missing station token"})
+		}
+		station, err := db.GetWeatherStationByToken(hashToken(token))
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid station token"})
+		}
+
+		var push types.StationPush
+		if err := parseBody(c, &push); err != nil {
+			return invalidInput(c, "Invalid station readings", err)
+		}
+		now := time.Now()
+		readings := make([]types.WeatherData, 0, len(push.Readings))
+		for i, pushed := range push.Readings {
+			if pushed.ObservedAt.After(now) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid station readings: reading %d is in the future", i)})
+			}
+			observedAt := pushed.ObservedAt.UTC()
+			readings = append(readings, types.WeatherData{
+				RegionID:      station.RegionID,
+				Date:          null.TimeFrom(observedAt.Truncate(24 * time.Hour)),
+				Hour:          null.IntFrom(int64(observedAt.Hour())),
+				Temperature:   pushed.Temperature,
+				Humidity:      pushed.Humidity,
+				WindSpeed:     pushed.WindSpeed,
+				Precipitation: pushed.Precipitation,
+				Source:        types.WeatherSourceStation,
+			})
+		}
+
+		saved, err := db.SaveWeatherReadings(readings)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to save station readings: %v", err)})
+		}
+		if err := db.MarkWeatherStationSeen(station.StationID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fmt.Sprintf("Failed to update weather station: %v", err)})
+		}
+		return c.JSON(types.WeatherImportResult{Imported: len(saved), Readings: saved})
+	}
+}
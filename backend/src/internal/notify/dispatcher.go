@@ -0,0 +1,266 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/guregu/null"
+	"go.uber.org/zap"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// Dispatcher routes incident notifications to users over their preferred channels
+// and escalates incidents nobody acknowledged in time
+type Dispatcher struct {
+	db            *database.DB
+	email         Notifier
+	webhook       Notifier
+	escalateAfter time.Duration
+}
+
+// NewDispatcher creates a dispatcher sending emails through email
+func NewDispatcher(db *database.DB, email Notifier, escalateAfter time.Duration) *Dispatcher {
+	return &Dispatcher{
+		db:            db,
+		email:         email,
+		webhook:       NewWebhookNotifier(10 * time.Second),
+		escalateAfter: escalateAfter,
+	}
+}
+
+// HandleIncident notifies every user matched by the notification rules of a new incident
+func (d *Dispatcher) HandleIncident(ctx context.Context, incident types.Incident) error {
+	recipients, err := d.db.GetIncidentRecipients(incident)
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("[%s] Incident #%d on hive %d", incident.Severity, incident.IncidentID, incident.HiveID)
+	for _, recipient := range recipients {
+		d.deliver(ctx, recipient, incident, subject)
+	}
+	return nil
+}
+
+// EscalateOverdue notifies apiary managers of open incidents older than the escalation window
+func (d *Dispatcher) EscalateOverdue(ctx context.Context) error {
+	escalations, err := d.db.GetIncidentsToEscalate(time.Now().Add(-d.escalateAfter))
+	if err != nil {
+		return err
+	}
+
+	for _, escalation := range escalations {
+		// Claim the incident first so concurrent replicas don't escalate it twice
+		claimed, err := d.db.MarkIncidentEscalated(escalation.IncidentID)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		manager, err := d.db.GetNotificationRecipient(escalation.ManagerID)
+		if err != nil {
+			zap.L().Error("Failed to get apiary manager for escalation",
+				zap.Error(err),
+				zap.Int("incident_id", escalation.IncidentID))
+			continue
+		}
+
+		incident := escalation.Incident
+		subject := fmt.Sprintf("[%s] Escalated: incident #%d on hive %d not acknowledged", incident.Severity, incident.IncidentID, incident.HiveID)
+		// Escalations always reach the manager, regardless of their severity threshold
+		manager.MinSeverity = types.SeverityLow
+		d.deliver(ctx, manager, incident, subject)
+	}
+	return nil
+}
+
+// DeliverDeferred sends the notifications held back by quiet hours that have ended
+func (d *Dispatcher) DeliverDeferred(ctx context.Context) error {
+	notifications, err := d.db.TakeDueNotifications(time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, notification := range notifications {
+		// Preferences are read again, the recipient may have changed them meanwhile
+		recipient, err := d.db.GetNotificationRecipient(notification.UserID)
+		if err != nil {
+			zap.L().Error("Failed to get recipient of deferred notification",
+				zap.Error(err),
+				zap.Int("user_id", notification.UserID))
+			continue
+		}
+		d.send(ctx, recipient, notification.IncidentID, notification.Subject, notification.Body, false)
+	}
+	return nil
+}
+
+// Run escalates overdue incidents and sends deferred notifications every
+// interval until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.EscalateOverdue(ctx); err != nil {
+				zap.L().Error("Failed to escalate incidents", zap.Error(err))
+			}
+			if err := d.DeliverDeferred(ctx); err != nil {
+				zap.L().Error("Failed to send deferred notifications", zap.Error(err))
+			}
+		}
+	}
+}
+
+// deliver sends an incident notification over every channel the recipient enabled
+//
+// Delivery failures are logged so one broken channel doesn't stop the others
+func (d *Dispatcher) deliver(ctx context.Context, recipient types.NotificationRecipient, incident types.Incident, subject string) {
+	if !incident.Severity.AtLeast(recipient.MinSeverity) {
+		return
+	}
+
+	body := fmt.Sprintf("%s\n\nSeverity: %s\nStatus: %s\nHive: %d", incident.Description, incident.Severity, incident.Status, incident.HiveID)
+	log := zap.L().With(zap.Int("user_id", recipient.UserID), zap.Int("incident_id", incident.IncidentID))
+
+	if recipient.InboxEnabled {
+		_, err := d.db.CreateNotification(types.Notification{
+			UserID:     recipient.UserID,
+			IncidentID: null.IntFrom(int64(incident.IncidentID)),
+			Subject:    subject,
+			Body:       body,
+		})
+		if err != nil {
+			log.Error("Failed to store inbox notification", zap.Error(err))
+		}
+	}
+
+	d.send(ctx, recipient, null.IntFrom(int64(incident.IncidentID)), subject, body, incident.Severity == types.SeverityCritical)
+}
+
+// send emails the notification and posts it to the recipient's webhook
+//
+// During quiet hours the notification is deferred until they end instead,
+// unless it is urgent.
+func (d *Dispatcher) send(ctx context.Context, recipient types.NotificationRecipient, incidentID null.Int, subject, body string, urgent bool) {
+	log := zap.L().With(zap.Int("user_id", recipient.UserID), zap.Int64("incident_id", incidentID.Int64))
+
+	hasEmail := recipient.EmailEnabled && recipient.Email != ""
+	hasWebhook := recipient.WebhookURL.Valid && recipient.WebhookURL.String != ""
+	if !hasEmail && !hasWebhook {
+		return
+	}
+
+	now := time.Now()
+	if quietUntil, quiet := quietHoursEnd(recipient.NotificationPreference, now); quiet && !urgent {
+		err := d.db.CreateDeferredNotification(types.DeferredNotification{
+			UserID:       recipient.UserID,
+			IncidentID:   incidentID,
+			Subject:      subject,
+			Body:         body,
+			DeliverAfter: quietUntil,
+		})
+		if err != nil {
+			log.Error("Failed to defer notification", zap.Error(err))
+		}
+		return
+	}
+
+	msg := Message{Subject: subject, Body: body, SentAt: now}
+	if hasEmail {
+		msg.To = recipient.Email
+		if err := d.email.Send(ctx, msg); err != nil {
+			log.Error("Failed to send email notification", zap.Error(err))
+		}
+	}
+	if hasWebhook {
+		msg.To = recipient.WebhookURL.String
+		if err := d.webhook.Send(ctx, msg); err != nil {
+			log.Error("Failed to send webhook notification", zap.Error(err))
+		}
+	}
+}
+
+// quietHoursEnd reports whether now falls within the user's quiet hours and
+// when they end
+//
+// Quiet hours may wrap past midnight, e.g. 22:00 to 07:00
+func quietHoursEnd(pref types.NotificationPreference, now time.Time) (time.Time, bool) {
+	if !pref.QuietHoursStart.Valid || !pref.QuietHoursEnd.Valid {
+		return time.Time{}, false
+	}
+	start, err := time.Parse("15:04", pref.QuietHoursStart.String)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := time.Parse("15:04", pref.QuietHoursEnd.String)
+	if err != nil {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(pref.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+
+	var quiet bool
+	if from <= to {
+		quiet = minute >= from && minute < to
+	} else {
+		quiet = minute >= from || minute < to
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	// The end is later today, or tomorrow when the quiet hours wrap past midnight
+	until := time.Date(local.Year(), local.Month(), local.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+	if !until.After(local) {
+		until = time.Date(local.Year(), local.Month(), local.Day()+1, end.Hour(), end.Minute(), 0, 0, loc)
+	}
+	return until, true
+}
+
+// broadcast delivers a notification to every recipient over the channels they
+// enabled, ignoring severity thresholds and quiet hours
+//
+// Delivery failures are logged so one broken channel doesn't stop the others.
+func (d *Dispatcher) broadcast(ctx context.Context, recipients []types.NotificationRecipient, notification types.Notification, fields ...zap.Field) {
+	for _, recipient := range recipients {
+		log := zap.L().With(fields...).With(zap.Int("user_id", recipient.UserID))
+
+		if recipient.InboxEnabled {
+			notification.UserID = recipient.UserID
+			if _, err := d.db.CreateNotification(notification); err != nil {
+				log.Error("Failed to store inbox notification", zap.Error(err))
+			}
+		}
+
+		msg := Message{Subject: notification.Subject, Body: notification.Body, SentAt: time.Now()}
+		if recipient.EmailEnabled && recipient.Email != "" {
+			msg.To = recipient.Email
+			if err := d.email.Send(ctx, msg); err != nil {
+				log.Error("Failed to send email notification", zap.Error(err))
+			}
+		}
+		if recipient.WebhookURL.Valid && recipient.WebhookURL.String != "" {
+			msg.To = recipient.WebhookURL.String
+			if err := d.webhook.Send(ctx, msg); err != nil {
+				log.Error("Failed to send webhook notification", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+func TestQuietHoursEnd(t *testing.T) {
+	pref := func(start, end string) types.NotificationPreference {
+		return types.NotificationPreference{
+			QuietHoursStart: null.StringFrom(start),
+			QuietHoursEnd:   null.StringFrom(end),
+			Timezone:        "UTC",
+		}
+	}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.March, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		pref      types.NotificationPreference
+		now       time.Time
+		wantQuiet bool
+		wantUntil time.Time
+	}{
+		{
+			name: "no quiet hours",
+			pref: types.NotificationPreference{Timezone: "UTC"},
+			now:  at(10, 23, 0),
+		},
+		{
+			name:      "same day",
+			pref:      pref("12:00", "14:00"),
+			now:       at(10, 13, 30),
+			wantQuiet: true,
+			wantUntil: at(10, 14, 0),
+		},
+		{
+			name: "after same day quiet hours",
+			pref: pref("12:00", "14:00"),
+			now:  at(10, 14, 0),
+		},
+		{
+			name:      "before midnight ends tomorrow",
+			pref:      pref("22:00", "07:00"),
+			now:       at(10, 23, 15),
+			wantQuiet: true,
+			wantUntil: at(11, 7, 0),
+		},
+		{
+			name:      "after midnight ends today",
+			pref:      pref("22:00", "07:00"),
+			now:       at(11, 3, 0),
+			wantQuiet: true,
+			wantUntil: at(11, 7, 0),
+		},
+		{
+			name: "outside wrapping quiet hours",
+			pref: pref("22:00", "07:00"),
+			now:  at(11, 12, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, quiet := quietHoursEnd(tt.pref, tt.now)
+			if quiet != tt.wantQuiet {
+				t.Fatalf("quiet = %v, want %v", quiet, tt.wantQuiet)
+			}
+			if quiet && !until.Equal(tt.wantUntil) {
+				t.Errorf("until = %v, want %v", until, tt.wantUntil)
+			}
+		})
+	}
+}
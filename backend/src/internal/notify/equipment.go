@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// NotifyLowStock tells the admins and managers which equipment to order before the
+// season starts on seasonStart
+func (d *Dispatcher) NotifyLowStock(ctx context.Context, shortages []types.EquipmentShortage, seasonStart time.Time) error {
+	recipients, err := d.db.GetEquipmentRecipients()
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("[equipment] %d item types below minimum stock before the season", len(shortages))
+	lines := make([]string, 0, len(shortages))
+	for _, shortage := range shortages {
+		lines = append(lines, fmt.Sprintf("- %s: %d in stores, minimum %d, short by %d (%d on hives, %d checked out)",
+			shortage.Name, shortage.InStock, shortage.MinStock, shortage.Shortfall, shortage.OnHives, shortage.CheckedOut))
+	}
+	body := fmt.Sprintf("The season starts on %s. The following equipment is below its minimum stock:\n\n%s",
+		seasonStart.Format("2006-01-02"), strings.Join(lines, "\n"))
+
+	d.broadcast(ctx, recipients, types.Notification{
+		Subject: subject,
+		Body:    body,
+	}, zap.Int("shortages", len(shortages)))
+	return nil
+}
@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/guregu/null"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// HandleOutOfSpec alerts the admins and the managers of the apiaries the honey came
+// from that a lab result is out of spec, which blocks its batches from release
+func (d *Dispatcher) HandleOutOfSpec(ctx context.Context, result types.LabResult) error {
+	recipients, err := d.db.GetLabResultRecipients(result.ResultID)
+	if err != nil {
+		return err
+	}
+
+	sample := fmt.Sprintf("harvest #%d", result.HarvestID.Int64)
+	if result.BatchID.Valid {
+		sample = fmt.Sprintf("batch #%d", result.BatchID.Int64)
+	}
+	subject := fmt.Sprintf("[lab] Out-of-spec result #%d for %s", result.ResultID, sample)
+
+	var findings []string
+	if result.MoisturePercent.Valid {
+		findings = append(findings, fmt.Sprintf("moisture %.1f%%", result.MoisturePercent.Float64))
+	}
+	if result.HMFMgKg.Valid {
+		findings = append(findings, fmt.Sprintf("HMF %.1f mg/kg", result.HMFMgKg.Float64))
+	}
+	if result.DiastaseDN.Valid {
+		findings = append(findings, fmt.Sprintf("diastase %.1f DN", result.DiastaseDN.Float64))
+	}
+	if result.ResidueDetected.Bool {
+		findings = append(findings, "residues detected")
+	}
+	body := fmt.Sprintf("The sample of %s taken on %s is out of spec (%s). "+
+		"Batches containing this honey cannot be released until a new sample passes.",
+		sample, result.SampleDate.Time.Format("2006-01-02"), strings.Join(findings, ", "))
+	if result.ResidueNotes.Valid {
+		body += "\n\n" + result.ResidueNotes.String
+	}
+
+	d.broadcast(ctx, recipients, types.Notification{
+		LabResultID: null.IntFrom(int64(result.ResultID)),
+		Subject:     subject,
+		Body:        body,
+	}, zap.Int("lab_result_id", result.ResultID))
+	return nil
+}
@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/orientallines/beesbiz/internal/config"
+)
+
+// Message is a notification addressed to a single recipient
+type Message struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// Notifier delivers messages to users
+//
+// Production deployments deliver email over SMTP; the log and file notifiers
+// below are meant for development
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New creates the notifier selected by the configured sink ("log", "file" or "smtp")
+func New(cfg config.NotifyConfig) (Notifier, error) {
+	switch cfg.Sink {
+	case "", "log":
+		return LogNotifier{}, nil
+	case "file":
+		if cfg.FilePath == "" {
+			return nil, fmt.Errorf("file notifier requires a file path")
+		}
+		return NewFileNotifier(cfg.FilePath), nil
+	case "smtp":
+		if cfg.SMTPHost == "" || cfg.SMTPFrom == "" {
+			return nil, fmt.Errorf("smtp notifier requires a host and a sender address")
+		}
+		return NewSMTPNotifier(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier sink: %s", cfg.Sink)
+	}
+}
+
+// LogNotifier writes messages to the application log
+type LogNotifier struct{}
+
+// Send logs the message
+func (LogNotifier) Send(ctx context.Context, msg Message) error {
+	zap.L().Info("Notification",
+		zap.String("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.String("body", msg.Body))
+	return nil
+}
+
+// FileNotifier appends messages as JSON lines to a file
+type FileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileNotifier creates a notifier writing to path
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+// Send appends the message to the file
+func (n *FileNotifier) Send(ctx context.Context, msg Message) error {
+	if msg.SentAt.IsZero() {
+		msg.SentAt = time.Now()
+	}
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("error encoding notification: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error opening notification file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing notification: %w", err)
+	}
+	return nil
+}
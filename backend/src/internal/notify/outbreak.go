@@ -0,0 +1,42 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/guregu/null"
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// HandleOutbreak tells the managers of every apiary in an outbreak's zone that it
+// was declared or lifted
+//
+// Outbreaks bypass severity thresholds and quiet hours.
+func (d *Dispatcher) HandleOutbreak(ctx context.Context, outbreak types.Outbreak) error {
+	recipients, err := d.db.GetOutbreakRecipients(outbreak.OutbreakID)
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("[quarantine] %s outbreak #%d declared", outbreak.DiseaseName, outbreak.OutbreakID)
+	body := fmt.Sprintf("An outbreak of %s was declared on %s. Your apiary is inside its quarantine zone: "+
+		"hives may not be moved into or out of it until the outbreak is lifted.",
+		outbreak.DiseaseName, outbreak.DeclaredDate.Time.Format("2006-01-02"))
+	if outbreak.LiftedDate.Valid {
+		subject = fmt.Sprintf("[quarantine] %s outbreak #%d lifted", outbreak.DiseaseName, outbreak.OutbreakID)
+		body = fmt.Sprintf("The outbreak of %s declared on %s was lifted on %s; its quarantine no longer applies.",
+			outbreak.DiseaseName, outbreak.DeclaredDate.Time.Format("2006-01-02"), outbreak.LiftedDate.Time.Format("2006-01-02"))
+	}
+	if outbreak.Description.Valid {
+		body += "\n\n" + outbreak.Description.String
+	}
+
+	d.broadcast(ctx, recipients, types.Notification{
+		OutbreakID: null.IntFrom(int64(outbreak.OutbreakID)),
+		Subject:    subject,
+		Body:       body,
+	}, zap.Int("outbreak_id", outbreak.OutbreakID))
+	return nil
+}
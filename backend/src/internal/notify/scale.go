@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// weightIncidentSeverity is how serious the incident raised for a weight event is
+var weightIncidentSeverity = map[types.WeightEventKind]types.IncidentSeverity{
+	types.WeightSwarm:   types.SeverityHigh,
+	types.WeightRobbing: types.SeverityMedium,
+}
+
+// HandleWeightEvent raises an incident on the hive for a swarm or robbing read
+// from its weight and notifies the users matched by the notification rules
+//
+// Other events raise no incident and are ignored.
+func (d *Dispatcher) HandleWeightEvent(ctx context.Context, event types.HiveWeightEvent) error {
+	severity, ok := weightIncidentSeverity[event.Kind]
+	if !ok {
+		return nil
+	}
+
+	description := fmt.Sprintf("The hive scale shows steady robbing: the hive lost %.1f kg over the day without any sudden drop.", -event.ChangeKg)
+	if event.Kind == types.WeightSwarm {
+		description = fmt.Sprintf("The hive scale shows a sudden drop of %.1f kg, likely a swarm leaving.", -event.ChangeKg)
+		if event.OccurredAt.Valid {
+			description = fmt.Sprintf("The hive scale shows a sudden drop of %.1f kg at %s UTC, likely a swarm leaving.",
+				-event.ChangeKg, event.OccurredAt.Time.UTC().Format("15:04"))
+		}
+	}
+	incident, err := d.db.CreateIncident(types.Incident{
+		HiveID:       event.HiveID,
+		IncidentDate: event.Date,
+		Description:  description,
+		Severity:     severity,
+	}, 0)
+	if err != nil {
+		return err
+	}
+	if err := d.db.SetWeightEventIncident(event.EventID, incident.IncidentID); err != nil {
+		return err
+	}
+	return d.HandleIncident(ctx, incident)
+}
+
+// NotifyNectarFlows tells the managers of the apiaries where hives started or
+// ended a nectar flow, one notification per apiary
+func (d *Dispatcher) NotifyNectarFlows(ctx context.Context, events []types.HiveWeightEvent) error {
+	byApiary := map[int][]types.HiveWeightEvent{}
+	for _, event := range events {
+		if (event.Kind == types.WeightFlowStart || event.Kind == types.WeightFlowEnd) && event.ApiaryID.Valid {
+			apiaryID := int(event.ApiaryID.Int64)
+			byApiary[apiaryID] = append(byApiary[apiaryID], event)
+		}
+	}
+
+	for apiaryID, apiaryEvents := range byApiary {
+		recipients, err := d.db.GetScaleRecipients([]int{apiaryID})
+		if err != nil {
+			return err
+		}
+		sort.Slice(apiaryEvents, func(i, j int) bool {
+			return apiaryEvents[i].Date.Time.Before(apiaryEvents[j].Date.Time)
+		})
+
+		starts := 0
+		lines := make([]string, 0, len(apiaryEvents))
+		for _, event := range apiaryEvents {
+			if event.Kind == types.WeightFlowStart {
+				starts++
+				lines = append(lines, fmt.Sprintf("- hive %d started a flow on %s, gaining %.1f kg in its first %d days",
+					event.HiveID, event.Date.Time.Format("2006-01-02"), event.ChangeKg, types.FlowMinDays))
+			} else {
+				lines = append(lines, fmt.Sprintf("- hive %d ended a flow on %s, having gained %.1f kg",
+					event.HiveID, event.Date.Time.Format("2006-01-02"), event.ChangeKg))
+			}
+		}
+		subject := fmt.Sprintf("[scale] Nectar flow changes at apiary %d", apiaryID)
+		if starts == len(apiaryEvents) {
+			subject = fmt.Sprintf("[scale] Nectar flow started at apiary %d", apiaryID)
+		}
+		body := fmt.Sprintf("The hive scales at apiary %d show nectar flow changes:\n\n%s", apiaryID, strings.Join(lines, "\n"))
+
+		d.broadcast(ctx, recipients, types.Notification{
+			Subject: subject,
+			Body:    body,
+		}, zap.Int("apiary_id", apiaryID), zap.Int("events", len(apiaryEvents)))
+	}
+	return nil
+}
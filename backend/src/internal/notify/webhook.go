@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrWebhookForbidden is returned for webhook URLs that are not https or that
+// point at loopback, private, link-local or otherwise internal addresses
+var ErrWebhookForbidden = errors.New("webhook URL must be https and point at a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), internal like the private ranges
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP reports whether webhooks may be delivered to ip
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified() &&
+		!sharedAddressSpace.Contains(ip)
+}
+
+// ValidateWebhookURL checks that a webhook URL is https and that every address
+// its host resolves to is public
+func ValidateWebhookURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" {
+		return ErrWebhookForbidden
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("error resolving webhook host: %w", err)
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return ErrWebhookForbidden
+		}
+	}
+	return nil
+}
+
+// dialPublic refuses connections to addresses webhooks may not reach
+//
+// It runs on the address actually dialled, after name resolution, so a host
+// that resolves differently at send time than when it was checked is still refused.
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return ErrWebhookForbidden
+	}
+	return nil
+}
+
+// WebhookNotifier posts messages as JSON to the URL in msg.To
+//
+// Only https URLs on public addresses are called, and redirects are not followed.
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a webhook notifier giving up on slow endpoints after timeout
+func NewWebhookNotifier(timeout time.Duration) *WebhookNotifier {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialPublic}
+	return &WebhookNotifier{client: &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: timeout},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// Send posts the message, failing on any non-2xx response
+func (n *WebhookNotifier) Send(ctx context.Context, msg Message) error {
+	if err := ValidateWebhookURL(ctx, msg.To); err != nil {
+		return err
+	}
+	if msg.SentAt.IsZero() {
+		msg.SentAt = time.Now()
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("error encoding notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.To, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -8,12 +8,14 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/orientallines/beesbiz/internal/database"
+	"github.com/orientallines/beesbiz/internal/notify"
 	types "github.com/orientallines/beesbiz/internal/types/db"
 )
 
 type Server struct {
-	rmq *RabbitMQ
-	db  *database.DB
+	rmq        *RabbitMQ
+	db         *database.DB
+	dispatcher *notify.Dispatcher
 }
 
 const (
@@ -21,18 +23,22 @@ const (
 	SensorQueue        = "sensor_queue"
 	SensorReadingQueue = "sensor_reading_queue"
 	DeleteSensorQueue  = "sensor_delete_queue"
+	IncidentQueue      = "incident_queue"
+	OutbreakQueue      = "outbreak_queue"
+	LabResultQueue     = "lab_result_queue"
 )
 
 // NewServer creates a new RabbitMQ server
-func NewServer(rmq *RabbitMQ, db *database.DB) (*Server, error) {
+func NewServer(rmq *RabbitMQ, db *database.DB, dispatcher *notify.Dispatcher) (*Server, error) {
 	server := &Server{
-		rmq: rmq,
-		db:  db,
+		rmq:        rmq,
+		db:         db,
+		dispatcher: dispatcher,
 	}
 
 	// Declare queues
 	ch := rmq.GetChannel()
-	for _, queue := range []string{SensorQueue, SensorReadingQueue} {
+	for _, queue := range []string{SensorQueue, SensorReadingQueue, IncidentQueue, OutbreakQueue, LabResultQueue} {
 		_, err := ch.QueueDeclare(
 			queue, // name
 			true,  // durable
@@ -54,6 +60,9 @@ func (s *Server) Run() error {
 	// Start consumers
 	go s.consumeSensorData()
 	go s.consumeSensorReadingData()
+	go s.consumeIncidents()
+	go s.consumeOutbreaks()
+	go s.consumeLabResults()
 
 	return nil
 }
@@ -170,3 +179,122 @@ func (s *Server) consumeSensorReadingData() {
 			zap.String("sensor_value", string(reading.Value)))
 	}
 }
+
+// consumeIncidents routes newly created incidents to the notification dispatcher
+func (s *Server) consumeIncidents() {
+	ch := s.rmq.GetChannel()
+	msgs, err := ch.Consume(
+		IncidentQueue, // queue
+		"",            // consumer
+		false,         // auto-ack
+		false,         // exclusive
+		false,         // no-local
+		false,         // no-wait
+		nil,           // args
+	)
+	if err != nil {
+		zap.L().Error("Failed to register incident consumer", zap.Error(err))
+		return
+	}
+
+	for msg := range msgs {
+		var incident types.Incident
+		if err := sonic.Unmarshal(msg.Body, &incident); err != nil {
+			zap.L().Error("Failed to unmarshal incident", zap.Error(err))
+			msg.Nack(false, false)
+			continue
+		}
+
+		if err := s.dispatcher.HandleIncident(context.Background(), incident); err != nil {
+			zap.L().Error("Failed to dispatch incident notifications",
+				zap.Error(err),
+				zap.Int("incident_id", incident.IncidentID))
+			msg.Nack(false, false)
+			continue
+		}
+
+		msg.Ack(false)
+		zap.L().Info("Successfully dispatched incident notifications",
+			zap.Int("incident_id", incident.IncidentID),
+			zap.String("severity", string(incident.Severity)))
+	}
+}
+
+// consumeOutbreaks routes declared and lifted outbreaks to the notification dispatcher
+func (s *Server) consumeOutbreaks() {
+	ch := s.rmq.GetChannel()
+	msgs, err := ch.Consume(
+		OutbreakQueue, // queue
+		"",            // consumer
+		false,         // auto-ack
+		false,         // exclusive
+		false,         // no-local
+		false,         // no-wait
+		nil,           // args
+	)
+	if err != nil {
+		zap.L().Error("Failed to register outbreak consumer", zap.Error(err))
+		return
+	}
+
+	for msg := range msgs {
+		var outbreak types.Outbreak
+		if err := sonic.Unmarshal(msg.Body, &outbreak); err != nil {
+			zap.L().Error("Failed to unmarshal outbreak", zap.Error(err))
+			msg.Nack(false, false)
+			continue
+		}
+
+		if err := s.dispatcher.HandleOutbreak(context.Background(), outbreak); err != nil {
+			zap.L().Error("Failed to dispatch outbreak notifications",
+				zap.Error(err),
+				zap.Int("outbreak_id", outbreak.OutbreakID))
+			msg.Nack(false, false)
+			continue
+		}
+
+		msg.Ack(false)
+		zap.L().Info("Successfully dispatched outbreak notifications",
+			zap.Int("outbreak_id", outbreak.OutbreakID),
+			zap.Bool("lifted", outbreak.LiftedDate.Valid))
+	}
+}
+
+// consumeLabResults routes out-of-spec lab results to the notification dispatcher
+func (s *Server) consumeLabResults() {
+	ch := s.rmq.GetChannel()
+	msgs, err := ch.Consume(
+		LabResultQueue, // queue
+		"",             // consumer
+		false,          // auto-ack
+		false,          // exclusive
+		false,          // no-local
+		false,          // no-wait
+		nil,            // args
+	)
+	if err != nil {
+		zap.L().Error("Failed to register lab result consumer", zap.Error(err))
+		return
+	}
+
+	for msg := range msgs {
+		var result types.LabResult
+		if err := sonic.Unmarshal(msg.Body, &result); err != nil {
+			zap.L().Error("Failed to unmarshal lab result", zap.Error(err))
+			msg.Nack(false, false)
+			continue
+		}
+
+		if err := s.dispatcher.HandleOutOfSpec(context.Background(), result); err != nil {
+			zap.L().Error("Failed to dispatch out-of-spec lab result notifications",
+				zap.Error(err),
+				zap.Int("lab_result_id", result.ResultID))
+			msg.Nack(false, false)
+			continue
+		}
+
+		msg.Ack(false)
+		zap.L().Info("Successfully dispatched out-of-spec lab result notifications",
+			zap.Int("lab_result_id", result.ResultID))
+	}
+}
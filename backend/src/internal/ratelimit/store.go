@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Store keeps fixed-window hit counters for the rate limiter
+//
+// Implementations must be safe for concurrent use
+type Store interface {
+	// Increment records a hit for key and returns the hits in the current
+	// window together with the time the window resets
+	Increment(key string, window time.Duration) (hits int, reset time.Time, err error)
+}
+
+// sweepInterval is how often the memory store drops expired windows
+const sweepInterval = time.Minute
+
+type counter struct {
+	hits  int
+	reset time.Time
+}
+
+// MemoryStore is an in-process Store, suitable for a single node
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]*counter
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters:  make(map[string]*counter),
+		lastSweep: time.Now(),
+	}
+}
+
+// Increment records a hit for key in the current window
+func (s *MemoryStore) Increment(key string, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		for k, c := range s.counters {
+			if !now.Before(c.reset) {
+				delete(s.counters, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.reset) {
+		c = &counter{reset: now.Add(window)}
+		s.counters[key] = c
+	}
+	c.hits++
+
+	return c.hits, c.reset, nil
+}
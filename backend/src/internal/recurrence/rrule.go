@@ -0,0 +1,262 @@
+// Package recurrence implements the subset of iCalendar recurrence rules
+// (RFC 5545 RRULE) needed for day-granular maintenance schedules
+package recurrence
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the base period of a rule
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxOccurrences bounds expansion so a malformed range cannot run away
+const maxOccurrences = 1000
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Rule is a parsed recurrence rule
+//
+// Supported parts are FREQ, INTERVAL, BYDAY (without ordinals), BYMONTHDAY
+// (negative values count from the end of the month), BYMONTH, COUNT and UNTIL.
+// Occurrences are whole days; times of day are ignored.
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	ByMonth    []time.Month
+	Count      int
+	Until      time.Time
+}
+
+// Parse parses a rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;BYMONTH=4,5,6"
+//
+// A leading "RRULE:" prefix is accepted
+func Parse(value string) (Rule, error) {
+	rule := Rule{Interval: 1}
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return Rule{}, fmt.Errorf("empty recurrence rule")
+	}
+
+	for _, part := range strings.Split(value, ";") {
+		name, arg, ok := strings.Cut(part, "=")
+		if !ok || arg == "" {
+			return Rule{}, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			switch freq := Frequency(strings.ToUpper(arg)); freq {
+			case Daily, Weekly, Monthly, Yearly:
+				rule.Freq = freq
+			default:
+				return Rule{}, fmt.Errorf("unsupported frequency %q", arg)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(arg)
+			if err != nil || interval < 1 {
+				return Rule{}, fmt.Errorf("invalid interval %q", arg)
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(arg)
+			if err != nil || count < 1 {
+				return Rule{}, fmt.Errorf("invalid count %q", arg)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseUntil(arg)
+			if err != nil {
+				return Rule{}, err
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, code := range strings.Split(arg, ",") {
+				day, ok := weekdays[strings.ToUpper(code)]
+				if !ok {
+					return Rule{}, fmt.Errorf("unsupported weekday %q", code)
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, item := range strings.Split(arg, ",") {
+				day, err := strconv.Atoi(item)
+				if err != nil || day == 0 || day < -31 || day > 31 {
+					return Rule{}, fmt.Errorf("invalid month day %q", item)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, day)
+			}
+		case "BYMONTH":
+			for _, item := range strings.Split(arg, ",") {
+				month, err := strconv.Atoi(item)
+				if err != nil || month < 1 || month > 12 {
+					return Rule{}, fmt.Errorf("invalid month %q", item)
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(month))
+			}
+		default:
+			return Rule{}, fmt.Errorf("unsupported recurrence rule part %q", name)
+		}
+	}
+
+	if rule.Freq == "" {
+		return Rule{}, fmt.Errorf("recurrence rule requires FREQ")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return Rule{}, fmt.Errorf("COUNT and UNTIL cannot be combined")
+	}
+	return rule, nil
+}
+
+// parseUntil accepts the date and UTC date-time forms of UNTIL
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102", "20060102T150405Z"} {
+		if until, err := time.Parse(layout, value); err == nil {
+			return day(until), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid until %q", value)
+}
+
+// Between returns the occurrences of a rule starting on start that fall within [from, to]
+//
+// start is always the first occurrence, matching RFC 5545 DTSTART semantics
+func (r Rule) Between(start, from, to time.Time) []time.Time {
+	start, from, to = day(start), day(from), day(to)
+	if !r.Until.IsZero() && r.Until.Before(to) {
+		to = r.Until
+	}
+
+	var occurrences []time.Time
+	seen := 0
+	for d := start; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if !d.Equal(start) && !r.matches(start, d) {
+			continue
+		}
+		seen++
+		if r.Count > 0 && seen > r.Count {
+			break
+		}
+		if !d.Before(from) {
+			occurrences = append(occurrences, d)
+			if len(occurrences) >= maxOccurrences {
+				break
+			}
+		}
+	}
+	return occurrences
+}
+
+// Includes reports whether date is an occurrence of the rule starting on start
+func (r Rule) Includes(start, date time.Time) bool {
+	date = day(date)
+	occurrences := r.Between(start, date, date)
+	return len(occurrences) == 1
+}
+
+// matches reports whether d, a day after start, belongs to the rule
+func (r Rule) matches(start, d time.Time) bool {
+	if len(r.ByMonth) > 0 && !containsMonth(r.ByMonth, d.Month()) {
+		return false
+	}
+
+	switch r.Freq {
+	case Daily:
+		if int(d.Sub(start).Hours()/24)%r.Interval != 0 {
+			return false
+		}
+		return len(r.ByDay) == 0 || containsWeekday(r.ByDay, d.Weekday())
+	case Weekly:
+		if weeksBetween(start, d)%r.Interval != 0 {
+			return false
+		}
+		if len(r.ByDay) == 0 {
+			return d.Weekday() == start.Weekday()
+		}
+		return containsWeekday(r.ByDay, d.Weekday())
+	case Monthly:
+		months := (d.Year()-start.Year())*12 + int(d.Month()-start.Month())
+		if months%r.Interval != 0 {
+			return false
+		}
+		return r.matchesDayOfMonth(start, d)
+	case Yearly:
+		if (d.Year()-start.Year())%r.Interval != 0 {
+			return false
+		}
+		if len(r.ByMonth) == 0 && d.Month() != start.Month() {
+			return false
+		}
+		return r.matchesDayOfMonth(start, d)
+	}
+	return false
+}
+
+// matchesDayOfMonth applies BYMONTHDAY and BYDAY within a month, defaulting to start's day
+func (r Rule) matchesDayOfMonth(start, d time.Time) bool {
+	if len(r.ByMonthDay) > 0 {
+		last := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		for _, monthDay := range r.ByMonthDay {
+			if monthDay == d.Day() || (monthDay < 0 && last+monthDay+1 == d.Day()) {
+				return len(r.ByDay) == 0 || containsWeekday(r.ByDay, d.Weekday())
+			}
+		}
+		return false
+	}
+	if len(r.ByDay) > 0 {
+		return containsWeekday(r.ByDay, d.Weekday())
+	}
+	return d.Day() == start.Day()
+}
+
+// weeksBetween counts the Monday-based weeks from start's week to d's week
+func weeksBetween(start, d time.Time) int {
+	return int(weekStart(d).Sub(weekStart(start)).Hours() / 24 / 7)
+}
+
+func weekStart(d time.Time) time.Time {
+	offset := (int(d.Weekday()) + 6) % 7
+	return d.AddDate(0, 0, -offset)
+}
+
+// day truncates t to midnight UTC of its calendar date
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func containsWeekday(days []time.Weekday, d time.Weekday) bool {
+	for _, day := range days {
+		if day == d {
+			return true
+		}
+	}
+	return false
+}
+
+func containsMonth(months []time.Month, m time.Month) bool {
+	for _, month := range months {
+		if month == m {
+			return true
+		}
+	}
+	return false
+}
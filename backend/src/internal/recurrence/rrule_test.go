@@ -0,0 +1,226 @@
+package recurrence
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  Rule
+	}{
+		{
+			name:  "daily",
+			value: "FREQ=DAILY",
+			want:  Rule{Freq: Daily, Interval: 1},
+		},
+		{
+			name:  "prefix and lowercase",
+			value: "RRULE:freq=weekly;interval=2;byday=mo,th",
+			want:  Rule{Freq: Weekly, Interval: 2, ByDay: []time.Weekday{time.Monday, time.Thursday}},
+		},
+		{
+			name:  "month days and months",
+			value: "FREQ=YEARLY;BYMONTH=4,5;BYMONTHDAY=1,-1",
+			want:  Rule{Freq: Yearly, Interval: 1, ByMonth: []time.Month{time.April, time.May}, ByMonthDay: []int{1, -1}},
+		},
+		{
+			name:  "count",
+			value: "FREQ=MONTHLY;COUNT=3",
+			want:  Rule{Freq: Monthly, Interval: 1, Count: 3},
+		},
+		{
+			name:  "until date",
+			value: "FREQ=DAILY;UNTIL=20240115",
+			want:  Rule{Freq: Daily, Interval: 1, Until: date(2024, time.January, 15)},
+		},
+		{
+			name:  "until date-time is truncated to its day",
+			value: "FREQ=DAILY;UNTIL=20240115T120000Z",
+			want:  Rule{Freq: Daily, Interval: 1, Until: date(2024, time.January, 15)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.value, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"prefix only", "RRULE:"},
+		{"missing frequency", "INTERVAL=2"},
+		{"unsupported frequency", "FREQ=HOURLY"},
+		{"part without value", "FREQ"},
+		{"zero interval", "FREQ=DAILY;INTERVAL=0"},
+		{"zero count", "FREQ=DAILY;COUNT=0"},
+		{"weekday with ordinal", "FREQ=MONTHLY;BYDAY=1MO"},
+		{"zero month day", "FREQ=MONTHLY;BYMONTHDAY=0"},
+		{"month day out of range", "FREQ=MONTHLY;BYMONTHDAY=32"},
+		{"month out of range", "FREQ=YEARLY;BYMONTH=13"},
+		{"dashed until", "FREQ=DAILY;UNTIL=2024-01-15"},
+		{"count and until", "FREQ=DAILY;COUNT=2;UNTIL=20240115"},
+		{"unsupported part", "FREQ=DAILY;BYHOUR=9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rule, err := Parse(tt.value); err == nil {
+				t.Errorf("Parse(%q) = %+v, want an error", tt.value, rule)
+			}
+		})
+	}
+}
+
+func TestBetween(t *testing.T) {
+	// 2024-01-01 is a Monday
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		from  time.Time
+		to    time.Time
+		want  []time.Time
+	}{
+		{
+			name:  "every other day",
+			rule:  "FREQ=DAILY;INTERVAL=2",
+			start: date(2024, time.January, 1),
+			from:  date(2024, time.January, 1),
+			to:    date(2024, time.January, 7),
+			want:  []time.Time{date(2024, time.January, 1), date(2024, time.January, 3), date(2024, time.January, 5), date(2024, time.January, 7)},
+		},
+		{
+			name:  "every other week on two days",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH",
+			start: date(2024, time.January, 1),
+			from:  date(2024, time.January, 1),
+			to:    date(2024, time.January, 21),
+			want:  []time.Time{date(2024, time.January, 1), date(2024, time.January, 4), date(2024, time.January, 15), date(2024, time.January, 18)},
+		},
+		{
+			name:  "start is an occurrence even off the rule",
+			rule:  "FREQ=WEEKLY;BYDAY=FR",
+			start: date(2024, time.January, 1),
+			from:  date(2024, time.January, 1),
+			to:    date(2024, time.January, 12),
+			want:  []time.Time{date(2024, time.January, 1), date(2024, time.January, 5), date(2024, time.January, 12)},
+		},
+		{
+			name:  "last day of the month",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start: date(2024, time.January, 31),
+			from:  date(2024, time.January, 1),
+			to:    date(2024, time.April, 30),
+			want:  []time.Time{date(2024, time.January, 31), date(2024, time.February, 29), date(2024, time.March, 31), date(2024, time.April, 30)},
+		},
+		{
+			name:  "count",
+			rule:  "FREQ=MONTHLY;COUNT=3",
+			start: date(2024, time.January, 15),
+			from:  date(2024, time.January, 1),
+			to:    date(2024, time.December, 31),
+			want:  []time.Time{date(2024, time.January, 15), date(2024, time.February, 15), date(2024, time.March, 15)},
+		},
+		{
+			name:  "count includes occurrences before from",
+			rule:  "FREQ=DAILY;COUNT=5",
+			start: date(2024, time.January, 1),
+			from:  date(2024, time.January, 4),
+			to:    date(2024, time.January, 31),
+			want:  []time.Time{date(2024, time.January, 4), date(2024, time.January, 5)},
+		},
+		{
+			name:  "until",
+			rule:  "FREQ=WEEKLY;UNTIL=20240115",
+			start: date(2024, time.January, 1),
+			from:  date(2024, time.January, 1),
+			to:    date(2024, time.February, 28),
+			want:  []time.Time{date(2024, time.January, 1), date(2024, time.January, 8), date(2024, time.January, 15)},
+		},
+		{
+			name:  "from after start",
+			rule:  "FREQ=WEEKLY",
+			start: date(2024, time.January, 1),
+			from:  date(2024, time.January, 10),
+			to:    date(2024, time.January, 31),
+			want:  []time.Time{date(2024, time.January, 15), date(2024, time.January, 22), date(2024, time.January, 29)},
+		},
+		{
+			name:  "yearly in one month",
+			rule:  "FREQ=YEARLY;BYMONTH=4;BYMONTHDAY=1",
+			start: date(2024, time.April, 1),
+			from:  date(2024, time.January, 1),
+			to:    date(2026, time.December, 31),
+			want:  []time.Time{date(2024, time.April, 1), date(2025, time.April, 1), date(2026, time.April, 1)},
+		},
+		{
+			name:  "times of day are ignored",
+			rule:  "FREQ=DAILY",
+			start: time.Date(2024, time.January, 1, 18, 30, 0, 0, time.UTC),
+			from:  time.Date(2024, time.January, 2, 9, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, time.January, 3, 6, 0, 0, 0, time.UTC),
+			want:  []time.Time{date(2024, time.January, 2), date(2024, time.January, 3)},
+		},
+		{
+			name:  "range before start",
+			rule:  "FREQ=DAILY",
+			start: date(2024, time.January, 10),
+			from:  date(2024, time.January, 1),
+			to:    date(2024, time.January, 9),
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.rule, err)
+			}
+			got := rule.Between(tt.start, tt.from, tt.to)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Between = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	rule, err := Parse("FREQ=WEEKLY;INTERVAL=2")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	start := date(2024, time.January, 1)
+	tests := []struct {
+		date time.Time
+		want bool
+	}{
+		{date(2024, time.January, 1), true},
+		{date(2024, time.January, 8), false},
+		{date(2024, time.January, 15), true},
+		{time.Date(2024, time.January, 15, 23, 59, 0, 0, time.UTC), true},
+		{date(2023, time.December, 18), false},
+	}
+	for _, tt := range tests {
+		if got := rule.Includes(start, tt.date); got != tt.want {
+			t.Errorf("Includes(%s) = %v, want %v", tt.date.Format(time.RFC3339), got, tt.want)
+		}
+	}
+}
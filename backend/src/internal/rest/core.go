@@ -2,6 +2,7 @@ package rest
 
 import (
 	"context"
+	"time"
 
 	"github.com/ansrivas/fiberprometheus/v2"
 	"github.com/gofiber/fiber/v2"
@@ -13,28 +14,50 @@ import (
 	"github.com/orientallines/beesbiz/internal/config"
 	"github.com/orientallines/beesbiz/internal/database"
 	"github.com/orientallines/beesbiz/internal/handlers"
+	"github.com/orientallines/beesbiz/internal/notify"
 	"github.com/orientallines/beesbiz/internal/rabbitmq"
+	"github.com/orientallines/beesbiz/internal/ratelimit"
 	types "github.com/orientallines/beesbiz/internal/types/db"
+	"github.com/orientallines/beesbiz/internal/weather"
 )
 
 // Server is a wrapper around fiber.App
 type Server struct {
-	app    *fiber.App
-	db     *database.DB
-	rmq    *rabbitmq.RabbitMQ
-	jwtKey []byte
+	app          *fiber.App
+	db           *database.DB
+	rmq          *rabbitmq.RabbitMQ
+	limiterStore ratelimit.Store
+	notifier     notify.Notifier
+	forecaster   weather.Provider
+	jwtKey       []byte
 }
 
 // NewServer creates a new Server
-func NewServer(db *database.DB, rmq *rabbitmq.RabbitMQ) *Server {
+func NewServer(db *database.DB, rmq *rabbitmq.RabbitMQ, limiterStore ratelimit.Store, notifier notify.Notifier) *Server {
 	return &Server{
-		app:    fiber.New(),
-		db:     db,
-		rmq:    rmq,
-		jwtKey: []byte(config.GlobalConfig.JwtSecret),
+		app: fiber.New(fiber.Config{
+			// Needed for per-IP limits when running behind a load balancer
+			ProxyHeader: config.GlobalConfig.API.ProxyHeader,
+		}),
+		db:           db,
+		rmq:          rmq,
+		limiterStore: limiterStore,
+		notifier:     notifier,
+		forecaster:   weather.NewLocalProvider(db),
+		jwtKey:       []byte(config.GlobalConfig.JwtSecret),
 	}
 }
 
+// limiter returns a rate limiting middleware, or a pass-through one when limiting is disabled
+func (s *Server) limiter(limit rateLimit) fiber.Handler {
+	if !config.GlobalConfig.API.LimitEnabled || s.limiterStore == nil {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+	return rateLimitMiddleware(s.limiterStore, limit)
+}
+
 // SetupRoutes sets up the routes for the server
 func (s *Server) SetupRoutes() {
 	s.app.Use(requestid.New())
@@ -63,23 +86,54 @@ func (s *Server) SetupRoutes() {
 		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
 		AllowMethods:     "GET,POST,HEAD,PUT,DELETE,PATCH",
 		AllowCredentials: false,
+		ExposeHeaders:    "RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After",
 	}))
 
+	apiLimits := config.GlobalConfig.API
+	window := time.Duration(apiLimits.LimitExpiration) * time.Second
+	authWindow := time.Duration(apiLimits.AuthLimitExpiration) * time.Second
+
+	s.app.Use(s.limiter(rateLimit{name: "ip", max: apiLimits.IPLimitAmount, window: window, key: ipKey}))
+
 	auth := s.app.Group("/auth")
 
-	auth.Post("/login", handlers.Login(s.db, s.jwtKey))
-	auth.Post("/register", handlers.Register(s.db))
+	// Stricter limits to slow down credential brute-forcing and mass sign-ups
+	auth.Post("/login", s.limiter(rateLimit{name: "login", max: apiLimits.AuthLimitAmount, window: authWindow, key: ipKey}), handlers.Login(s.db, s.jwtKey))
+	auth.Post("/register", s.limiter(rateLimit{name: "register", max: apiLimits.AuthLimitAmount, window: authWindow, key: ipKey}), handlers.Register(s.db))
+	auth.Post("/password/forgot", s.limiter(rateLimit{name: "password_forgot", max: apiLimits.AuthLimitAmount, window: authWindow, key: ipKey}), handlers.ForgotPassword(s.db, s.notifier))
+	auth.Post("/password/reset", s.limiter(rateLimit{name: "password_reset", max: apiLimits.AuthLimitAmount, window: authWindow, key: ipKey}), handlers.ResetPassword(s.db))
+	auth.Post("/email/confirm", s.limiter(rateLimit{name: "email_confirm", max: apiLimits.AuthLimitAmount, window: authWindow, key: ipKey}), handlers.ConfirmEmailChange(s.db))
+
+	// Weather stations push readings with their own token rather than a user session
+	s.app.Post("/station/readings", handlers.PushStationReadings(s.db))
+
+	api := s.app.Group("/api", jwtMiddleware(s.jwtKey), s.limiter(rateLimit{name: "user", max: apiLimits.LimitAmount, window: window, key: userKey}))
+
+	// Self-service account routes, available to every authenticated user
+	me := api.Group("/me")
 
-	api := s.app.Group("/api", jwtMiddleware(s.jwtKey))
+	me.Get("/", handlers.GetMe(s.db))
+	me.Put("/", handlers.UpdateMe(s.db, s.notifier))
+	me.Put("/password", handlers.ChangePassword(s.db))
+	me.Get("/tasks", handlers.GetMyTasks(s.db))
+	me.Get("/notifications", handlers.GetMyNotifications(s.db))
+	me.Post("/notifications/:id/read", handlers.MarkNotificationRead(s.db))
+	me.Get("/notification-preferences", handlers.GetMyNotificationPreference(s.db))
+	me.Put("/notification-preferences", handlers.UpdateMyNotificationPreference(s.db))
 
 	// Apiary routes
 	apiary := api.Group("/apiary", roleMiddleware(types.Worker, types.Manager, types.Admin))
 
+	apiary.Get("/geojson", handlers.GetApiaryGeoJSON(s.db))
+	apiary.Get("/nearby", handlers.GetApiariesNear(s.db))
 	apiary.Get("/:id", handlers.GetApiary(s.db))
 	apiary.Post("/", handlers.CreateApiary(s.db))
 	apiary.Put("/", handlers.UpdateApiary(s.db))
 	apiary.Delete("/:id", handlers.DeleteApiary(s.db))
 	apiary.Get("/", handlers.GetAllApiaries(s.db))
+	apiary.Get("/:id/quarantine", handlers.GetApiaryQuarantine(s.db))
+	apiary.Get("/:id/components", handlers.GetApiaryHiveComponents(s.db))
+	apiary.Get("/:id/nearby", handlers.GetApiaryNeighbours(s.db))
 
 	// Hive routes
 	hive := api.Group("/hive", roleMiddleware(types.Worker, types.Manager, types.Admin))
@@ -89,6 +143,39 @@ func (s *Server) SetupRoutes() {
 	hive.Put("/", handlers.UpdateHive(s.db))
 	hive.Delete("/:id", handlers.DeleteHive(s.db, s.rmq))
 	hive.Get("/:apiaryID/hives", handlers.GetAllHivesByApiaryID(s.db))
+	hive.Post("/:id/relocate", roleMiddleware(types.Manager, types.Admin), handlers.RelocateHive(s.db))
+	hive.Get("/:id/movements", handlers.GetHiveMovements(s.db))
+	hive.Get("/:id/components", handlers.GetHiveComponents(s.db))
+	hive.Post("/:id/components", handlers.ChangeHiveComponents(s.db))
+
+	// Equipment routes
+	equipment := api.Group("/equipment", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	equipment.Get("/type/:id", handlers.GetEquipmentType(s.db))
+	equipment.Post("/type", roleMiddleware(types.Manager, types.Admin), handlers.CreateEquipmentType(s.db))
+	equipment.Put("/type", roleMiddleware(types.Manager, types.Admin), handlers.UpdateEquipmentType(s.db))
+	equipment.Delete("/type/:id", roleMiddleware(types.Admin), handlers.DeleteEquipmentType(s.db))
+	equipment.Get("/type", handlers.GetAllEquipmentTypes(s.db))
+	equipment.Get("/warehouse/:id", handlers.GetWarehouse(s.db))
+	equipment.Post("/warehouse", roleMiddleware(types.Manager, types.Admin), handlers.CreateWarehouse(s.db))
+	equipment.Put("/warehouse", roleMiddleware(types.Manager, types.Admin), handlers.UpdateWarehouse(s.db))
+	equipment.Delete("/warehouse/:id", roleMiddleware(types.Admin), handlers.DeleteWarehouse(s.db))
+	equipment.Get("/warehouse", handlers.GetAllWarehouses(s.db))
+	equipment.Get("/stock", handlers.GetEquipmentStock(s.db))
+	equipment.Post("/stock", roleMiddleware(types.Manager, types.Admin), handlers.ChangeEquipmentStock(s.db))
+	equipment.Post("/transfer", handlers.TransferEquipment(s.db))
+	equipment.Get("/shortages", handlers.GetEquipmentShortages(s.db))
+	equipment.Get("/checkout/:id", handlers.GetEquipmentCheckout(s.db))
+	equipment.Post("/checkout", handlers.CheckOutEquipment(s.db))
+	equipment.Post("/checkout/:id/checkin", handlers.CheckInEquipment(s.db))
+	equipment.Get("/checkout", handlers.GetEquipmentCheckouts(s.db))
+
+	// Colony routes
+	colony := api.Group("/colony", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	colony.Post("/split", handlers.SplitColony(s.db))
+	colony.Post("/merge", handlers.MergeColonies(s.db))
+	colony.Get("/events", handlers.GetColonyEvents(s.db))
 
 	// BeeCommunity routes
 	beeCommunity := api.Group("/bee-community", roleMiddleware(types.Worker, types.Manager, types.Admin))
@@ -99,23 +186,115 @@ func (s *Server) SetupRoutes() {
 	beeCommunity.Delete("/:id", handlers.DeleteBeeCommunity(s.db))
 	beeCommunity.Get("/:hiveID/bee-communities", handlers.GetAllBeeCommunitiesByHiveID(s.db))
 
+	// Queen routes
+	queen := api.Group("/queen", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	queen.Get("/hive/:hiveID/history", handlers.GetHiveRequeeningHistory(s.db))
+	queen.Get("/:id", handlers.GetQueen(s.db))
+	queen.Post("/", handlers.CreateQueen(s.db))
+	queen.Put("/", handlers.UpdateQueen(s.db))
+	queen.Delete("/:id", roleMiddleware(types.Manager, types.Admin), handlers.DeleteQueen(s.db))
+	queen.Get("/", handlers.GetAllQueens(s.db))
+	queen.Post("/:id/introduce", handlers.IntroduceQueen(s.db))
+	queen.Put("/:id/status", handlers.UpdateQueenStatus(s.db))
+	queen.Get("/:id/lineage", handlers.GetQueenLineage(s.db))
+
 	// HoneyHarvest routes
 	honeyHarvest := api.Group("/honey-harvest", roleMiddleware(types.Worker, types.Manager, types.Admin))
 
+	honeyHarvest.Get("/by-apiary", handlers.GetHarvestTotalsByApiary(s.db))
 	honeyHarvest.Get("/:id", handlers.GetHoneyHarvest(s.db))
 	honeyHarvest.Post("/", handlers.CreateHoneyHarvest(s.db))
 	honeyHarvest.Put("/", handlers.UpdateHoneyHarvest(s.db))
 	honeyHarvest.Delete("/:id", handlers.DeleteHoneyHarvest(s.db))
 	honeyHarvest.Get("/", handlers.GetAllHoneyHarvests(s.db))
 
+	// Honey batch routes
+	honeyBatch := api.Group("/honey-batch", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	honeyBatch.Get("/trace/:lotNumber", handlers.TraceLot(s.db))
+	honeyBatch.Get("/:id", handlers.GetHoneyBatch(s.db))
+	honeyBatch.Post("/", handlers.CreateHoneyBatch(s.db))
+	honeyBatch.Post("/:id/harvests", handlers.AddBatchHarvests(s.db))
+	honeyBatch.Post("/:id/steps", handlers.AddBatchStep(s.db))
+	honeyBatch.Post("/:id/jar-lots", handlers.CreateJarLot(s.db))
+	honeyBatch.Post("/:id/release", roleMiddleware(types.Manager, types.Admin), handlers.ReleaseHoneyBatch(s.db))
+	honeyBatch.Delete("/:id", roleMiddleware(types.Manager, types.Admin), handlers.DeleteHoneyBatch(s.db))
+	honeyBatch.Get("/", handlers.GetAllHoneyBatches(s.db))
+
+	// Lab result routes
+	labResult := api.Group("/lab-result", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	labResult.Get("/grade-rule", handlers.GetGradeRules(s.db))
+	labResult.Put("/grade-rule", roleMiddleware(types.Admin), handlers.SaveGradeRule(s.db))
+	labResult.Post("/import", roleMiddleware(types.Manager, types.Admin), handlers.ImportLabResults(s.db, s.rmq))
+	labResult.Get("/:id", handlers.GetLabResult(s.db))
+	labResult.Post("/", handlers.CreateLabResult(s.db, s.rmq))
+	labResult.Put("/", handlers.UpdateLabResult(s.db, s.rmq))
+	labResult.Delete("/:id", roleMiddleware(types.Manager, types.Admin), handlers.DeleteLabResult(s.db))
+	labResult.Get("/", handlers.GetLabResults(s.db))
+
+	// Customer routes
+	customer := api.Group("/customer", roleMiddleware(types.Manager, types.Admin))
+
+	customer.Get("/:id", handlers.GetCustomer(s.db))
+	customer.Post("/", handlers.CreateCustomer(s.db))
+	customer.Put("/", handlers.UpdateCustomer(s.db))
+	customer.Delete("/:id", roleMiddleware(types.Admin), handlers.DeleteCustomer(s.db))
+	customer.Get("/", handlers.GetAllCustomers(s.db))
+
+	// Stock routes
+	stock := api.Group("/stock", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	stock.Get("/:id", handlers.GetStockItem(s.db))
+	stock.Get("/:id/movements", handlers.GetStockMovements(s.db))
+	stock.Post("/", handlers.ReceiveStock(s.db))
+	stock.Put("/", handlers.UpdateStockItem(s.db))
+	stock.Post("/:id/adjust", roleMiddleware(types.Manager, types.Admin), handlers.AdjustStock(s.db))
+	stock.Get("/", handlers.GetStockItems(s.db))
+
+	// Sales order routes
+	salesOrder := api.Group("/sales-order", roleMiddleware(types.Manager, types.Admin))
+
+	salesOrder.Get("/:id", handlers.GetSalesOrder(s.db))
+	salesOrder.Post("/", handlers.CreateSalesOrder(s.db))
+	salesOrder.Post("/:id/fulfil", handlers.FulfilSalesOrder(s.db))
+	salesOrder.Post("/:id/cancel", handlers.CancelSalesOrder(s.db))
+	salesOrder.Get("/", handlers.GetSalesOrders(s.db))
+
+	// Invoice routes
+	invoice := api.Group("/invoice", roleMiddleware(types.Manager, types.Admin))
+
+	invoice.Get("/:id", handlers.GetInvoice(s.db))
+	invoice.Post("/:id/pay", handlers.PayInvoice(s.db))
+	invoice.Get("/", handlers.GetInvoices(s.db))
+
+	// Pollination contract routes
+	pollination := api.Group("/pollination-contract", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	pollination.Get("/availability", handlers.GetHiveAvailability(s.db))
+	pollination.Get("/:id", handlers.GetPollinationContract(s.db))
+	pollination.Post("/", roleMiddleware(types.Manager, types.Admin), handlers.CreatePollinationContract(s.db))
+	pollination.Put("/", roleMiddleware(types.Manager, types.Admin), handlers.UpdatePollinationContract(s.db))
+	pollination.Post("/:id/cancel", roleMiddleware(types.Manager, types.Admin), handlers.CancelPollinationContract(s.db))
+	pollination.Post("/:id/hives", roleMiddleware(types.Manager, types.Admin), handlers.AssignContractHives(s.db))
+	pollination.Delete("/:id/hives", roleMiddleware(types.Manager, types.Admin), handlers.UnassignContractHives(s.db))
+	pollination.Post("/:id/deploy", handlers.DeployContractHives(s.db))
+	pollination.Post("/:id/return", handlers.ReturnContractHives(s.db))
+	pollination.Get("/", handlers.GetPollinationContracts(s.db))
+
 	// Region routes
 	region := api.Group("/region", roleMiddleware(types.Worker, types.Manager, types.Admin))
 
+	region.Get("/tree", handlers.GetRegionTree(s.db))
+	region.Get("/accessible", handlers.GetAccessibleRegions(s.db))
+	region.Get("/stats", handlers.GetRegionStats(s.db))
 	region.Get("/:id", handlers.GetRegion(s.db))
 	region.Post("/", handlers.CreateRegion(s.db))
 	region.Put("/", handlers.UpdateRegion(s.db))
 	region.Delete("/:id", handlers.DeleteRegion(s.db))
 	region.Get("/", handlers.GetAllRegions(s.db))
+	region.Get("/:id/apiaries", handlers.GetRegionApiaries(s.db))
 
 	// AllowedRegion routes
 	allowedRegion := api.Group("/allowed-region", roleMiddleware(types.Manager, types.Admin))
@@ -164,6 +343,7 @@ func (s *Server) SetupRoutes() {
 	// ProductionReport routes
 	productionReport := api.Group("/production-report", roleMiddleware(types.Manager, types.Worker, types.Admin))
 
+	productionReport.Get("/profit", roleMiddleware(types.Manager, types.Admin), handlers.GetApiaryProfits(s.db))
 	productionReport.Get("/:id", handlers.GetProductionReport(s.db))
 	productionReport.Post("/", handlers.CreateProductionReport(s.db))
 	productionReport.Put("/", handlers.UpdateProductionReport(s.db))
@@ -192,21 +372,48 @@ func (s *Server) SetupRoutes() {
 	// WeatherData routes
 	weatherData := api.Group("/weather-data", roleMiddleware(types.Admin, types.Manager, types.Worker))
 
+	weatherData.Get("/daily", handlers.GetDailyWeather(s.db))
+	weatherData.Get("/forecast", handlers.GetWeatherForecast(s.db, s.forecaster))
+	weatherData.Get("/production", handlers.GetWeatherProduction(s.db))
+	weatherData.Post("/import", roleMiddleware(types.Manager, types.Admin), handlers.ImportWeatherData(s.db))
 	weatherData.Get("/:id", handlers.GetWeatherData(s.db))
 	weatherData.Post("/", handlers.CreateWeatherData(s.db))
 	weatherData.Put("/", handlers.UpdateWeatherData(s.db))
 	weatherData.Delete("/:id", handlers.DeleteWeatherData(s.db))
 	weatherData.Get("/", handlers.GetAllWeatherData(s.db))
 
+	// WeatherStation routes
+	weatherStation := api.Group("/weather-station", roleMiddleware(types.Manager, types.Admin))
+
+	weatherStation.Get("/", handlers.GetWeatherStations(s.db))
+	weatherStation.Post("/", handlers.CreateWeatherStation(s.db))
+	weatherStation.Put("/", handlers.UpdateWeatherStation(s.db))
+	weatherStation.Delete("/:id", handlers.DeleteWeatherStation(s.db))
+	weatherStation.Post("/:id/token", handlers.RotateWeatherStationToken(s.db))
+
 	// Incident routes
 	incident := api.Group("/incident", roleMiddleware(types.Worker, types.Manager, types.Admin))
 
+	incident.Get("/metrics", handlers.GetIncidentMetrics(s.db))
 	incident.Get("/:id", handlers.GetIncident(s.db))
 	incident.Post("/", handlers.CreateIncident(s.db, s.rmq))
 	incident.Put("/", handlers.UpdateIncident(s.db))
 	incident.Delete("/:id", handlers.DeleteIncident(s.db))
 	incident.Get("/", handlers.GetAllIncidents(s.db))
 	incident.Put("/:id/status", handlers.UpdateIncidentStatus(s.db))
+	incident.Put("/:id/severity", handlers.UpdateIncidentSeverity(s.db))
+	incident.Put("/:id/assignment", handlers.AssignIncident(s.db))
+	incident.Get("/:id/activity", handlers.GetIncidentActivity(s.db))
+	incident.Post("/:id/comments", handlers.AddIncidentComment(s.db))
+
+	// NotificationRule routes
+	notificationRule := api.Group("/notification-rule", roleMiddleware(types.Manager, types.Admin))
+
+	notificationRule.Get("/:id", handlers.GetNotificationRule(s.db))
+	notificationRule.Post("/", handlers.CreateNotificationRule(s.db))
+	notificationRule.Put("/", handlers.UpdateNotificationRule(s.db))
+	notificationRule.Delete("/:id", handlers.DeleteNotificationRule(s.db))
+	notificationRule.Get("/", handlers.GetAllNotificationRules(s.db))
 
 	// Observation routes
 	observation := api.Group("/observation", roleMiddleware(types.Worker, types.Manager, types.Admin))
@@ -217,15 +424,130 @@ func (s *Server) SetupRoutes() {
 	observation.Delete("/:id", handlers.DeleteObservationLog(s.db))
 	observation.Get("/", handlers.GetAllObservationLogs(s.db))
 
+	// InspectionTemplate routes
+	inspectionTemplate := api.Group("/inspection-template", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	inspectionTemplate.Get("/:id", handlers.GetInspectionTemplate(s.db))
+	inspectionTemplate.Post("/", roleMiddleware(types.Manager, types.Admin), handlers.CreateInspectionTemplate(s.db))
+	inspectionTemplate.Put("/", roleMiddleware(types.Manager, types.Admin), handlers.UpdateInspectionTemplate(s.db))
+	inspectionTemplate.Delete("/:id", roleMiddleware(types.Manager, types.Admin), handlers.DeleteInspectionTemplate(s.db))
+	inspectionTemplate.Get("/", handlers.GetAllInspectionTemplates(s.db))
+
+	// Inspection routes
+	inspection := api.Group("/inspection", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	inspection.Get("/search", handlers.SearchInspections(s.db))
+	inspection.Get("/hive/:hiveID/trends", handlers.GetHiveInspectionTrends(s.db))
+	inspection.Get("/:id", handlers.GetInspection(s.db))
+	inspection.Post("/", handlers.CreateInspection(s.db))
+	inspection.Delete("/:id", roleMiddleware(types.Manager, types.Admin), handlers.DeleteInspection(s.db))
+	inspection.Get("/", handlers.GetAllInspections(s.db))
+
+	// Feeding routes
+	feeding := api.Group("/feeding", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	feeding.Get("/usage", handlers.GetFeedUsage(s.db))
+	feeding.Get("/hive/:hiveID/stores", handlers.GetHiveStores(s.db))
+	feeding.Get("/winter-readiness/:apiaryID", handlers.GetWinterReadiness(s.db))
+	feeding.Get("/:id", handlers.GetFeeding(s.db))
+	feeding.Post("/", handlers.CreateFeeding(s.db))
+	feeding.Put("/", handlers.UpdateFeeding(s.db))
+	feeding.Delete("/:id", roleMiddleware(types.Manager, types.Admin), handlers.DeleteFeeding(s.db))
+	feeding.Get("/", handlers.GetFeedings(s.db))
+
+	// HiveScale routes
+	hiveScale := api.Group("/hive-scale", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	hiveScale.Get("/events", handlers.GetHiveWeightEvents(s.db))
+	hiveScale.Get("/hive/:hiveID/days", handlers.GetHiveWeightDays(s.db))
+	hiveScale.Get("/hive/:hiveID/flows", handlers.GetHiveFlows(s.db))
+	hiveScale.Get("/apiary/:apiaryID/flows", handlers.GetApiaryFlows(s.db))
+
+	// TreatmentProduct routes
+	treatmentProduct := api.Group("/treatment-product", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	treatmentProduct.Get("/:id", handlers.GetTreatmentProduct(s.db))
+	treatmentProduct.Post("/", roleMiddleware(types.Manager, types.Admin), handlers.CreateTreatmentProduct(s.db))
+	treatmentProduct.Put("/", roleMiddleware(types.Manager, types.Admin), handlers.UpdateTreatmentProduct(s.db))
+	treatmentProduct.Delete("/:id", roleMiddleware(types.Manager, types.Admin), handlers.DeleteTreatmentProduct(s.db))
+	treatmentProduct.Get("/", handlers.GetAllTreatmentProducts(s.db))
+
+	// Treatment routes
+	treatment := api.Group("/treatment", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	treatment.Get("/efficacy", handlers.GetTreatmentEfficacy(s.db))
+	treatment.Get("/hive/:hiveID", handlers.GetHiveTreatments(s.db))
+	treatment.Get("/hive/:hiveID/withdrawal", handlers.GetHiveWithdrawalStatus(s.db))
+	treatment.Get("/:id", handlers.GetTreatmentApplication(s.db))
+	treatment.Post("/", handlers.CreateTreatmentApplication(s.db))
+	treatment.Put("/", handlers.UpdateTreatmentApplication(s.db))
+	treatment.Delete("/:id", roleMiddleware(types.Manager, types.Admin), handlers.DeleteTreatmentApplication(s.db))
+	treatment.Get("/", handlers.GetTreatmentApplications(s.db))
+
+	// VeterinaryPassport routes
+	veterinaryPassport := api.Group("/veterinary-passport", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	veterinaryPassport.Get("/:id", handlers.GetVeterinaryPassport(s.db))
+	veterinaryPassport.Post("/", handlers.CreateVeterinaryPassport(s.db))
+	veterinaryPassport.Put("/", handlers.UpdateVeterinaryPassport(s.db))
+	veterinaryPassport.Delete("/:id", roleMiddleware(types.Manager, types.Admin), handlers.DeleteVeterinaryPassport(s.db))
+	veterinaryPassport.Get("/", handlers.GetAllVeterinaryPassports(s.db))
+
+	// VeterinaryRecord routes
+	veterinaryRecord := api.Group("/veterinary-record", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	veterinaryRecord.Get("/:id", handlers.GetVeterinaryRecord(s.db))
+	veterinaryRecord.Post("/", handlers.CreateVeterinaryRecord(s.db, s.rmq))
+	veterinaryRecord.Put("/", handlers.UpdateVeterinaryRecord(s.db, s.rmq))
+	veterinaryRecord.Delete("/:id", roleMiddleware(types.Manager, types.Admin), handlers.DeleteVeterinaryRecord(s.db))
+	veterinaryRecord.Get("/", handlers.GetAllVeterinaryRecords(s.db))
+
+	// Disease routes
+	disease := api.Group("/disease", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	disease.Get("/:id", handlers.GetDisease(s.db))
+	disease.Post("/", roleMiddleware(types.Manager, types.Admin), handlers.CreateDisease(s.db))
+	disease.Put("/", roleMiddleware(types.Manager, types.Admin), handlers.UpdateDisease(s.db))
+	disease.Delete("/:id", roleMiddleware(types.Manager, types.Admin), handlers.DeleteDisease(s.db))
+	disease.Get("/", handlers.GetAllDiseases(s.db))
+
+	// Outbreak routes
+	outbreak := api.Group("/outbreak", roleMiddleware(types.Worker, types.Manager, types.Admin))
+
+	outbreak.Get("/quarantine", handlers.GetQuarantinedApiaries(s.db))
+	outbreak.Get("/:id", handlers.GetOutbreak(s.db))
+	outbreak.Post("/", roleMiddleware(types.Manager, types.Admin), handlers.DeclareOutbreak(s.db, s.rmq))
+	outbreak.Get("/", handlers.GetAllOutbreaks(s.db))
+	outbreak.Post("/:id/lift", roleMiddleware(types.Manager, types.Admin), handlers.LiftOutbreak(s.db, s.rmq))
+	outbreak.Get("/:id/zone", handlers.GetOutbreakZone(s.db))
+	outbreak.Get("/:id/report", handlers.GetOutbreakReport(s.db))
+	outbreak.Get("/:id/neighbours", handlers.GetOutbreakNeighbours(s.db))
+
 	// Maintenance routes
 	maintenance := api.Group("/maintenance", roleMiddleware(types.Worker, types.Manager, types.Admin))
 
+	maintenance.Get("/overdue", handlers.GetOverdueMaintenancePlans(s.db))
 	maintenance.Get("/:id", handlers.GetMaintenancePlan(s.db))
 	maintenance.Post("/", handlers.CreateMaintenancePlan(s.db))
 	maintenance.Put("/", handlers.UpdateMaintenancePlan(s.db))
 	maintenance.Delete("/:id", handlers.DeleteMaintenancePlan(s.db))
 	maintenance.Get("/", handlers.GetAllMaintenancePlans(s.db))
 	maintenance.Put("/:id/status", handlers.UpdateMaintenancePlanStatus(s.db))
+	maintenance.Put("/:id/reschedule", handlers.RescheduleMaintenancePlan(s.db))
+	maintenance.Put("/:id/assignment", roleMiddleware(types.Manager, types.Admin), handlers.AssignMaintenancePlan(s.db))
+	maintenance.Post("/:id/complete", handlers.CompleteMaintenancePlan(s.db))
+	maintenance.Get("/:id/completion", handlers.GetMaintenanceCompletion(s.db))
+
+	// MaintenanceSchedule routes
+	maintenanceSchedule := api.Group("/maintenance-schedule", roleMiddleware(types.Manager, types.Admin))
+
+	maintenanceSchedule.Get("/:id", handlers.GetMaintenanceSchedule(s.db))
+	maintenanceSchedule.Post("/", handlers.CreateMaintenanceSchedule(s.db))
+	maintenanceSchedule.Put("/", handlers.UpdateMaintenanceSchedule(s.db))
+	maintenanceSchedule.Delete("/:id", handlers.DeleteMaintenanceSchedule(s.db))
+	maintenanceSchedule.Get("/", handlers.GetAllMaintenanceSchedules(s.db))
+	maintenanceSchedule.Get("/:id/occurrences", handlers.GetScheduleOccurrences(s.db))
+	maintenanceSchedule.Put("/:id/occurrences", handlers.ChangeScheduleOccurrence(s.db))
 
 }
 
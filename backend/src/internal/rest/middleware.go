@@ -55,7 +55,13 @@ func jwtMiddleware(jwtKey []byte) fiber.Handler {
 				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token claims: missing role"})
 			}
 
+			userID, ok := claims["user_id"].(float64)
+			if !ok {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token claims: missing user id"})
+			}
+
 			c.Locals("role", role)
+			c.Locals("user_id", int(userID))
 
 			return c.Next()
 		}
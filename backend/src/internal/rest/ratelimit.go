@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+
+	"github.com/orientallines/beesbiz/internal/ratelimit"
+)
+
+// throttledRequests counts requests rejected by a rate limiter, exposed at /metrics
+var throttledRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "beesbiz",
+	Name:      "rate_limited_requests_total",
+	Help:      "Number of requests rejected by a rate limiter",
+}, []string{"limiter"})
+
+// rateLimit describes a single fixed-window limit
+type rateLimit struct {
+	name   string
+	max    int
+	window time.Duration
+	key    func(c *fiber.Ctx) string
+}
+
+// ipKey identifies a client by its IP address
+func ipKey(c *fiber.Ctx) string {
+	return c.IP()
+}
+
+// userKey identifies a client by the authenticated user, falling back to the IP
+//
+// It must run after jwtMiddleware
+func userKey(c *fiber.Ctx) string {
+	if userID, ok := c.Locals("user_id").(int); ok {
+		return "user:" + strconv.Itoa(userID)
+	}
+	return "ip:" + c.IP()
+}
+
+// rateLimitMiddleware is a middleware that throttles clients exceeding the limit
+//
+// It sets the RateLimit-* headers on every response and Retry-After on rejected ones
+func rateLimitMiddleware(store ratelimit.Store, limit rateLimit) fiber.Handler {
+	policy := fmt.Sprintf("%d;w=%d", limit.max, int(limit.window.Seconds()))
+
+	return func(c *fiber.Ctx) error {
+		hits, reset, err := store.Increment(limit.name+":"+limit.key(c), limit.window)
+		if err != nil {
+			// Fail open: an unavailable store must not take the API down with it
+			zap.S().Warn("Error checking rate limit: ", err)
+			return c.Next()
+		}
+
+		remaining := limit.max - hits
+		if remaining < 0 {
+			remaining = 0
+		}
+		resetIn := int(math.Ceil(time.Until(reset).Seconds()))
+		if resetIn < 0 {
+			resetIn = 0
+		}
+
+		c.Set("RateLimit-Limit", strconv.Itoa(limit.max))
+		c.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(resetIn))
+		c.Set("RateLimit-Policy", policy)
+
+		if hits > limit.max {
+			throttledRequests.WithLabelValues(limit.name).Inc()
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(resetIn))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "Too many requests, please try again later",
+			})
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,251 @@
+// Package scale reads hive weight sensor readings: daily net gain, nectar flows,
+// swarms leaving and robbing
+package scale
+
+import (
+	"math"
+	"time"
+
+	"github.com/guregu/null"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+const day = 24 * time.Hour
+
+// Summarize groups a hive's readings, in time order, into UTC days
+func Summarize(hiveID int, readings []types.WeightReading) []types.HiveWeightDay {
+	var days []types.HiveWeightDay
+	var previous *types.WeightReading
+	for i := range readings {
+		reading := &readings[i]
+		date := reading.MeasuredAt.UTC().Truncate(day)
+		if len(days) == 0 || !days[len(days)-1].Date.Time.Equal(date) {
+			days = append(days, types.HiveWeightDay{
+				HiveID:    hiveID,
+				Date:      null.TimeFrom(date),
+				OpeningKg: reading.WeightKg,
+				MinKg:     reading.WeightKg,
+				MaxKg:     reading.WeightKg,
+			})
+		}
+		summary := &days[len(days)-1]
+		summary.ClosingKg = reading.WeightKg
+		summary.MinKg = math.Min(summary.MinKg, reading.WeightKg)
+		summary.MaxKg = math.Max(summary.MaxKg, reading.WeightKg)
+		summary.Readings++
+
+		// The gain runs on from the previous day's last reading, but not across a gap in the readings
+		if previous != nil && !previous.MeasuredAt.UTC().Before(date.Add(-day)) {
+			step := reading.WeightKg - previous.WeightKg
+			if math.Abs(step) < types.ManipulationMinKg {
+				summary.NetGainKg += step
+				sudden := reading.MeasuredAt.Sub(previous.MeasuredAt) <= types.SwarmWindow
+				if sudden && -step > summary.MaxDropKg {
+					summary.MaxDropKg = -step
+					summary.MaxDropAt = null.TimeFrom(reading.MeasuredAt)
+				}
+			}
+		}
+		previous = reading
+	}
+	return days
+}
+
+// Flows finds the nectar flows in a hive's days, in date order, up to through
+//
+// Days missing from the record count as days without a flow. A flow still
+// running at through has no end date.
+func Flows(days []types.HiveWeightDay, through time.Time) []types.NectarFlow {
+	flows := []types.NectarFlow{}
+	var current *types.NectarFlow
+	var streak []types.HiveWeightDay
+	var quiet int
+	var quietGain float64
+
+	// step moves on by a day, summary being nil for a day without readings
+	step := func(summary *types.HiveWeightDay) {
+		onFlow := summary != nil && summary.NetGainKg >= types.FlowMinGainKg
+		var gain float64
+		if summary != nil {
+			gain = summary.NetGainKg
+		}
+		switch {
+		case current != nil && onFlow:
+			current.Days += quiet + 1
+			current.GainKg += quietGain + gain
+			current.PeakGainKg = math.Max(current.PeakGainKg, gain)
+			quiet, quietGain = 0, 0
+		case current != nil:
+			quiet++
+			quietGain += gain
+			if quiet >= types.FlowEndDays {
+				current.EndDate = null.TimeFrom(current.StartDate.Time.AddDate(0, 0, current.Days-1))
+				flows = append(flows, *current)
+				current = nil
+			}
+		case onFlow:
+			streak = append(streak, *summary)
+			if len(streak) >= types.FlowMinDays {
+				current = &types.NectarFlow{HiveID: streak[0].HiveID, StartDate: streak[0].Date, Days: len(streak)}
+				for _, flowDay := range streak {
+					current.GainKg += flowDay.NetGainKg
+					current.PeakGainKg = math.Max(current.PeakGainKg, flowDay.NetGainKg)
+				}
+				streak, quiet, quietGain = nil, 0, 0
+			}
+		default:
+			streak = nil
+		}
+	}
+
+	var next time.Time
+	for i := range days {
+		date := days[i].Date.Time
+		if date.After(through) {
+			break
+		}
+		for ; !next.IsZero() && next.Before(date); next = next.Add(day) {
+			step(nil)
+		}
+		step(&days[i])
+		next = date.Add(day)
+	}
+	for ; !next.IsZero() && !next.After(through); next = next.Add(day) {
+		step(nil)
+	}
+	if current != nil {
+		flows = append(flows, *current)
+	}
+	return flows
+}
+
+// Detect reads the events in a hive's days, in date order, up to through
+//
+// A day with a sudden drop is a swarm leaving; a day losing weight steadily is
+// robbing. Flows already running on the first day are left out, their start
+// having been seen before.
+func Detect(days []types.HiveWeightDay, through time.Time) []types.HiveWeightEvent {
+	var events []types.HiveWeightEvent
+	if len(days) == 0 {
+		return events
+	}
+	for _, summary := range days {
+		switch {
+		case summary.MaxDropKg >= types.SwarmMinDropKg:
+			events = append(events, types.HiveWeightEvent{
+				HiveID:     summary.HiveID,
+				Kind:       types.WeightSwarm,
+				Date:       summary.Date,
+				OccurredAt: summary.MaxDropAt,
+				ChangeKg:   -summary.MaxDropKg,
+			})
+		case summary.NetGainKg <= -types.RobbingMinLossKg:
+			events = append(events, types.HiveWeightEvent{
+				HiveID:   summary.HiveID,
+				Kind:     types.WeightRobbing,
+				Date:     summary.Date,
+				ChangeKg: summary.NetGainKg,
+			})
+		}
+	}
+
+	first := days[0].Date.Time
+	for _, flow := range Flows(days, through) {
+		if flow.StartDate.Time.After(first) {
+			events = append(events, types.HiveWeightEvent{
+				HiveID:   flow.HiveID,
+				Kind:     types.WeightFlowStart,
+				Date:     flow.StartDate,
+				ChangeKg: startGain(days, flow.StartDate.Time),
+			})
+		}
+		if flow.EndDate.Valid {
+			events = append(events, types.HiveWeightEvent{
+				HiveID:   flow.HiveID,
+				Kind:     types.WeightFlowEnd,
+				Date:     flow.EndDate,
+				ChangeKg: flow.GainKg,
+			})
+		}
+	}
+	return events
+}
+
+// startGain returns the gain over the FlowMinDays days that started a flow, so a
+// flow start reads the same however far the flow has gone on
+func startGain(days []types.HiveWeightDay, start time.Time) float64 {
+	end := start.AddDate(0, 0, types.FlowMinDays)
+	var gain float64
+	for _, summary := range days {
+		if !summary.Date.Time.Before(start) && summary.Date.Time.Before(end) {
+			gain += summary.NetGainKg
+		}
+	}
+	return gain
+}
+
+// ApiaryFlows aggregates the flows of an apiary's hives from their days between
+// from and through: the apiary is on a flow while at least half of the hives
+// weighed that day are
+func ApiaryFlows(apiaryID int, days []types.HiveWeightDay, from, through time.Time) []types.ApiaryFlow {
+	hiveDays := map[int][]types.HiveWeightDay{}
+	weighed := map[time.Time]int{}
+	gains := map[int]map[time.Time]float64{}
+	for _, summary := range days {
+		hiveDays[summary.HiveID] = append(hiveDays[summary.HiveID], summary)
+		date := summary.Date.Time.UTC()
+		weighed[date]++
+		if gains[summary.HiveID] == nil {
+			gains[summary.HiveID] = map[time.Time]float64{}
+		}
+		gains[summary.HiveID][date] = summary.NetGainKg
+	}
+	var hiveFlows []types.NectarFlow
+	for _, summaries := range hiveDays {
+		hiveFlows = append(hiveFlows, Flows(summaries, through)...)
+	}
+
+	flows := []types.ApiaryFlow{}
+	var current *types.ApiaryFlow
+	var hiveDaysOnFlow int
+	for date := from.UTC(); !date.After(through); date = date.Add(day) {
+		onFlow := 0
+		var gain float64
+		for _, flow := range hiveFlows {
+			if !date.Before(flow.StartDate.Time) && (!flow.EndDate.Valid || !date.After(flow.EndDate.Time)) {
+				onFlow++
+				gain += gains[flow.HiveID][date]
+			}
+		}
+		if onFlow == 0 || onFlow*2 < weighed[date] {
+			if current != nil {
+				current.EndDate = null.TimeFrom(date.Add(-day))
+				flows = append(flows, closeApiaryFlow(current, hiveDaysOnFlow))
+				current = nil
+			}
+			continue
+		}
+		if current == nil {
+			current = &types.ApiaryFlow{ApiaryID: apiaryID, StartDate: null.TimeFrom(date)}
+			hiveDaysOnFlow = 0
+		}
+		current.Days++
+		current.WeighedHives = max(current.WeighedHives, weighed[date])
+		current.PeakHives = max(current.PeakHives, onFlow)
+		current.GainKg += gain
+		hiveDaysOnFlow += onFlow
+	}
+	if current != nil {
+		flows = append(flows, closeApiaryFlow(current, hiveDaysOnFlow))
+	}
+	return flows
+}
+
+// closeApiaryFlow sets the mean gain per hive and day of a flow
+func closeApiaryFlow(flow *types.ApiaryFlow, hiveDaysOnFlow int) types.ApiaryFlow {
+	if hiveDaysOnFlow > 0 {
+		flow.MeanGainKg = flow.GainKg / float64(hiveDaysOnFlow)
+	}
+	return *flow
+}
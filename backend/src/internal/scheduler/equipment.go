@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/guregu/null"
+	"go.uber.org/zap"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	"github.com/orientallines/beesbiz/internal/notify"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// EquipmentScheduler alerts about equipment below its minimum stock in the weeks
+// before the season, once per equipment type and season
+type EquipmentScheduler struct {
+	db         *database.DB
+	dispatcher *notify.Dispatcher
+}
+
+// NewEquipmentScheduler creates a new equipment scheduler alerting through dispatcher
+func NewEquipmentScheduler(db *database.DB, dispatcher *notify.Dispatcher) *EquipmentScheduler {
+	return &EquipmentScheduler{db: db, dispatcher: dispatcher}
+}
+
+// nextSeasonStart returns the start of the season on or after today
+func nextSeasonStart(today time.Time) time.Time {
+	start := time.Date(today.Year(), types.SeasonStartMonth, types.SeasonStartDay, 0, 0, 0, 0, time.UTC)
+	if start.Before(today.Truncate(24 * time.Hour)) {
+		start = start.AddDate(1, 0, 0)
+	}
+	return start
+}
+
+// Tick alerts about shortages not alerted about yet this season once the season
+// is less than LowStockLeadDays away
+func (s *EquipmentScheduler) Tick(ctx context.Context) {
+	today := time.Now().UTC()
+	seasonStart := nextSeasonStart(today)
+	windowStart := seasonStart.AddDate(0, 0, -types.LowStockLeadDays)
+	if today.Before(windowStart) {
+		return
+	}
+
+	shortages, err := s.db.GetEquipmentShortages(null.TimeFrom(windowStart))
+	if err != nil {
+		zap.L().Error("Failed to get equipment shortages", zap.Error(err))
+		return
+	}
+	if len(shortages) == 0 {
+		return
+	}
+
+	if err := s.dispatcher.NotifyLowStock(ctx, shortages, seasonStart); err != nil {
+		zap.L().Error("Failed to notify low equipment stock", zap.Error(err))
+		return
+	}
+	typeIDs := make([]int, len(shortages))
+	for i, shortage := range shortages {
+		typeIDs[i] = shortage.TypeID
+	}
+	if err := s.db.MarkLowStockAlerted(typeIDs, today); err != nil {
+		zap.L().Error("Failed to mark low equipment stock alerted", zap.Error(err))
+		return
+	}
+	zap.L().Info("Alerted low equipment stock", zap.Int("types", len(shortages)))
+}
+
+// Run ticks immediately and then every interval until ctx is cancelled
+func (s *EquipmentScheduler) Run(ctx context.Context, interval time.Duration) {
+	s.Tick(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Tick(ctx)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Package scheduler runs the background maintenance jobs
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/orientallines/beesbiz/internal/database"
+)
+
+// MaintenanceScheduler expands recurring maintenance schedules into plans, hands
+// group plans to members and flags plans that were not done in time
+type MaintenanceScheduler struct {
+	db *database.DB
+}
+
+// NewMaintenanceScheduler creates a new maintenance scheduler
+func NewMaintenanceScheduler(db *database.DB) *MaintenanceScheduler {
+	return &MaintenanceScheduler{db: db}
+}
+
+// Tick generates upcoming plans for every active schedule, distributes group
+// plans to members and flags overdue plans
+func (s *MaintenanceScheduler) Tick() {
+	today := time.Now().UTC()
+
+	schedules, err := s.db.GetActiveMaintenanceSchedules()
+	if err != nil {
+		zap.L().Error("Failed to get maintenance schedules", zap.Error(err))
+		return
+	}
+
+	for _, schedule := range schedules {
+		created, err := s.db.GenerateSchedulePlans(schedule, today)
+		if err != nil {
+			// One broken schedule must not hold back the others
+			zap.L().Error("Failed to generate maintenance plans",
+				zap.Error(err),
+				zap.Int("schedule_id", schedule.ScheduleID))
+			continue
+		}
+		if created > 0 {
+			zap.L().Info("Generated maintenance plans",
+				zap.Int("schedule_id", schedule.ScheduleID),
+				zap.Int64("plans", created))
+		}
+	}
+
+	assigned, err := s.db.DistributeGroupPlans()
+	if err != nil {
+		zap.L().Error("Failed to distribute group maintenance plans", zap.Error(err))
+	} else if assigned > 0 {
+		zap.L().Info("Distributed group maintenance plans", zap.Int("plans", assigned))
+	}
+
+	flagged, err := s.db.FlagOverduePlans()
+	if err != nil {
+		zap.L().Error("Failed to flag overdue maintenance plans", zap.Error(err))
+		return
+	}
+	if flagged > 0 {
+		zap.L().Info("Flagged overdue maintenance plans", zap.Int64("plans", flagged))
+	}
+}
+
+// Run ticks immediately and then every interval until ctx is cancelled
+func (s *MaintenanceScheduler) Run(ctx context.Context, interval time.Duration) {
+	s.Tick()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Tick()
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/orientallines/beesbiz/internal/database"
+	"github.com/orientallines/beesbiz/internal/notify"
+	"github.com/orientallines/beesbiz/internal/scale"
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// scaleAlertDays is how many days back newly read events are still alerted about;
+// older ones, from late readings or a first analysis, are only recorded
+const scaleAlertDays = 3
+
+// ScaleScheduler analyses hive weight readings once a day: it summarises each
+// hive's days, reads nectar flows, swarms and robbing from them and alerts on
+// the new events
+type ScaleScheduler struct {
+	db         *database.DB
+	dispatcher *notify.Dispatcher
+}
+
+// NewScaleScheduler creates a new scale scheduler raising incidents and alerting
+// through dispatcher
+func NewScaleScheduler(db *database.DB, dispatcher *notify.Dispatcher) *ScaleScheduler {
+	return &ScaleScheduler{db: db, dispatcher: dispatcher}
+}
+
+// Tick analyses the last ScaleLookbackDays complete days again
+func (s *ScaleScheduler) Tick(ctx context.Context) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	through := today.AddDate(0, 0, -1)
+	since := today.AddDate(0, 0, -types.ScaleLookbackDays)
+
+	// The day before since is read too, for the gain of since to run on from it
+	readings, err := s.db.GetWeightReadings(since.AddDate(0, 0, -1), today)
+	if err != nil {
+		zap.L().Error("Failed to get weight readings", zap.Error(err))
+		return
+	}
+
+	var days []types.HiveWeightDay
+	var events []types.HiveWeightEvent
+	for start := 0; start < len(readings); {
+		end := start
+		for end < len(readings) && readings[end].HiveID == readings[start].HiveID {
+			end++
+		}
+		hiveDays := scale.Summarize(readings[start].HiveID, readings[start:end])
+		for len(hiveDays) > 0 && hiveDays[0].Date.Time.Before(since) {
+			hiveDays = hiveDays[1:]
+		}
+		days = append(days, hiveDays...)
+		events = append(events, scale.Detect(hiveDays, through)...)
+		start = end
+	}
+	if len(days) == 0 {
+		return
+	}
+
+	recorded, err := s.db.SaveScaleAnalysis(days, events)
+	if err != nil {
+		zap.L().Error("Failed to save scale analysis", zap.Error(err))
+		return
+	}
+
+	alertSince := today.AddDate(0, 0, -scaleAlertDays)
+	var flows []types.HiveWeightEvent
+	for _, event := range recorded {
+		if event.Date.Time.Before(alertSince) {
+			continue
+		}
+		if event.Kind == types.WeightFlowStart || event.Kind == types.WeightFlowEnd {
+			flows = append(flows, event)
+			continue
+		}
+		if err := s.dispatcher.HandleWeightEvent(ctx, event); err != nil {
+			// One failed incident must not hold back the others
+			zap.L().Error("Failed to raise weight event incident",
+				zap.Error(err),
+				zap.Int("event_id", event.EventID))
+		}
+	}
+	if err := s.dispatcher.NotifyNectarFlows(ctx, flows); err != nil {
+		zap.L().Error("Failed to notify nectar flows", zap.Error(err))
+	}
+	zap.L().Info("Analysed hive scales",
+		zap.Int("days", len(days)),
+		zap.Int("events", len(recorded)))
+}
+
+// Run ticks immediately and then every interval until ctx is cancelled
+func (s *ScaleScheduler) Run(ctx context.Context, interval time.Duration) {
+	s.Tick(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Tick(ctx)
+		}
+	}
+}
@@ -3,24 +3,45 @@ package server
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/orientallines/beesbiz/internal/database"
 	"github.com/orientallines/beesbiz/internal/grpc"
+	"github.com/orientallines/beesbiz/internal/notify"
 	"github.com/orientallines/beesbiz/internal/rabbitmq"
+	"github.com/orientallines/beesbiz/internal/ratelimit"
 	"github.com/orientallines/beesbiz/internal/rest"
+	"github.com/orientallines/beesbiz/internal/scheduler"
 )
 
 type Server struct {
 	grpcServer   *grpc.Server
 	restServer   *rest.Server
 	rabbitServer *rabbitmq.Server
+	dispatcher   *notify.Dispatcher
+	maintenance  *scheduler.MaintenanceScheduler
+	equipment    *scheduler.EquipmentScheduler
+	scale        *scheduler.ScaleScheduler
+	stopJobs     context.CancelFunc
 	// tikvServer   *tikv.Server
 }
 
+const (
+	// escalationInterval is how often overdue incidents are checked for escalation
+	escalationInterval = time.Minute
+	// maintenanceInterval is how often schedules are expanded and late plans flagged
+	maintenanceInterval = time.Hour
+	// equipmentInterval is how often equipment stock is checked before the season
+	equipmentInterval = 6 * time.Hour
+	// scaleInterval is how often hive weight readings are analysed
+	scaleInterval = 24 * time.Hour
+)
+
 // NewServer creates a new Server
 // func NewServer(db *database.DB, rmq *rabbitmq.RabbitMQ, tikvClient *tikv.TiKV) (*Server, error) {
-func NewServer(db *database.DB, rmq *rabbitmq.RabbitMQ) (*Server, error) {
-	rabbitServer, err := rabbitmq.NewServer(rmq, db)
+func NewServer(db *database.DB, rmq *rabbitmq.RabbitMQ, limiterStore ratelimit.Store, notifier notify.Notifier, escalateAfter time.Duration) (*Server, error) {
+	dispatcher := notify.NewDispatcher(db, notifier, escalateAfter)
+	rabbitServer, err := rabbitmq.NewServer(rmq, db, dispatcher)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create RabbitMQ server: %w", err)
 	}
@@ -28,9 +49,13 @@ func NewServer(db *database.DB, rmq *rabbitmq.RabbitMQ) (*Server, error) {
 	// tikvServer := tikv.NewServer(tikvClient.GetClient(), db)
 
 	return &Server{
-		grpcServer:   grpc.NewServer(db),
-		restServer:   rest.NewServer(db, rmq),
+		grpcServer:   grpc.NewServer(db, rmq),
+		restServer:   rest.NewServer(db, rmq, limiterStore, notifier),
 		rabbitServer: rabbitServer,
+		dispatcher:   dispatcher,
+		maintenance:  scheduler.NewMaintenanceScheduler(db),
+		equipment:    scheduler.NewEquipmentScheduler(db, dispatcher),
+		scale:        scheduler.NewScaleScheduler(db, dispatcher),
 		// tikvServer:   tikvServer,
 	}, nil
 }
@@ -40,6 +65,14 @@ func (s *Server) SetupAndRun(grpcAddress, restAddress string) error {
 	s.grpcServer.RegisterServices()
 	s.restServer.SetupRoutes()
 
+	// Background jobs stop on shutdown
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	s.stopJobs = stopJobs
+	go s.dispatcher.Run(jobsCtx, escalationInterval)
+	go s.maintenance.Run(jobsCtx, maintenanceInterval)
+	go s.equipment.Run(jobsCtx, equipmentInterval)
+	go s.scale.Run(jobsCtx, scaleInterval)
+
 	errChan := make(chan error, 3)
 
 	go func() {
@@ -80,6 +113,10 @@ func (s *Server) SetupAndRun(grpcAddress, restAddress string) error {
 
 // Shutdown shuts down the servers
 func (s *Server) Shutdown(ctx context.Context) error {
+	if s.stopJobs != nil {
+		s.stopJobs()
+	}
+
 	errChan := make(chan error, 3)
 
 	go func() {
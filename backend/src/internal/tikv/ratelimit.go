@@ -0,0 +1,144 @@
+package tikv
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tikv/client-go/v2/rawkv"
+	"go.uber.org/zap"
+)
+
+const rateLimitKeyPrefix = "ratelimit/"
+
+// rateLimitKeyEnd is the first key after every rate limit counter
+const rateLimitKeyEnd = "ratelimit0"
+
+const (
+	// casAttempts bounds the retries of an increment racing other replicas
+	casAttempts = 16
+	// sweepInterval is how often a replica drops expired counters
+	sweepInterval = time.Minute
+	// sweepPage is how many counters a sweep reads at a time
+	sweepPage = 512
+)
+
+// RateLimitStore is a rate limiter store shared by all replicas through TiKV
+//
+// Counters are incremented with compare-and-swap, so concurrent hits from
+// different replicas are all counted. CAS writes carry no TTL, so each replica
+// periodically deletes counters whose window has ended. The client is put in
+// atomic mode, which every writer of these keys must share.
+type RateLimitStore struct {
+	client  *rawkv.Client
+	timeout time.Duration
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewRateLimitStore creates a rate limiter store on top of a raw TiKV client
+func NewRateLimitStore(client *rawkv.Client) *RateLimitStore {
+	return &RateLimitStore{client: client.SetAtomicForCAS(true), timeout: time.Second, lastSweep: time.Now()}
+}
+
+// encodeCounter packs the hits of a window and the time it resets
+func encodeCounter(hits int, reset time.Time) []byte {
+	encoded := make([]byte, 16)
+	binary.BigEndian.PutUint64(encoded[:8], uint64(hits))
+	binary.BigEndian.PutUint64(encoded[8:], uint64(reset.UnixNano()))
+	return encoded
+}
+
+// decodeCounter unpacks a counter, reporting false for malformed values
+func decodeCounter(value []byte) (int, time.Time, bool) {
+	if len(value) != 16 {
+		return 0, time.Time{}, false
+	}
+	return int(binary.BigEndian.Uint64(value[:8])), time.Unix(0, int64(binary.BigEndian.Uint64(value[8:]))), true
+}
+
+// Increment records a hit for key in the current window
+func (s *RateLimitStore) Increment(key string, window time.Duration) (int, time.Time, error) {
+	s.maybeSweep()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	storeKey := []byte(rateLimitKeyPrefix + key)
+	previous, err := s.client.Get(ctx, storeKey)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("error reading rate limit counter: %w", err)
+	}
+
+	for attempt := 0; attempt < casAttempts; attempt++ {
+		now := time.Now()
+		hits, reset := 0, now.Add(window)
+		if storedHits, storedReset, ok := decodeCounter(previous); ok && now.Before(storedReset) {
+			hits, reset = storedHits, storedReset
+		}
+		hits++
+
+		current, swapped, err := s.client.CompareAndSwap(ctx, storeKey, previous, encodeCounter(hits, reset))
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("error writing rate limit counter: %w", err)
+		}
+		if swapped {
+			return hits, reset, nil
+		}
+		// Another replica counted a hit first; retry on top of its value
+		previous = current
+	}
+	return 0, time.Time{}, fmt.Errorf("error writing rate limit counter: too much contention on %q", key)
+}
+
+// maybeSweep starts a sweep of expired counters when the last one is old enough
+func (s *RateLimitStore) maybeSweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = time.Now()
+	go s.sweep()
+}
+
+// sweep deletes counters whose window ended before the last sweep interval
+//
+// Deleting races a replica opening a new window on the same key, in which case
+// that one hit is forgotten. The grace period makes this rare and it can only
+// happen once per key and sweep.
+func (s *RateLimitStore) sweep() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*s.timeout)
+	defer cancel()
+
+	cutoff := time.Now().Add(-sweepInterval)
+	startKey := []byte(rateLimitKeyPrefix)
+	for {
+		keys, values, err := s.client.Scan(ctx, startKey, []byte(rateLimitKeyEnd), sweepPage)
+		if err != nil {
+			zap.L().Error("Failed to scan rate limit counters", zap.Error(err))
+			return
+		}
+		var expired [][]byte
+		for i, value := range values {
+			if _, reset, ok := decodeCounter(value); !ok || reset.Before(cutoff) {
+				expired = append(expired, keys[i])
+			}
+		}
+		if len(expired) > 0 {
+			if err := s.client.BatchDelete(ctx, expired); err != nil {
+				zap.L().Error("Failed to delete expired rate limit counters", zap.Error(err))
+				return
+			}
+		}
+		if len(keys) < sweepPage {
+			return
+		}
+		// Continue right after the last key of this page
+		startKey = append(bytes.Clone(keys[len(keys)-1]), 0)
+	}
+}
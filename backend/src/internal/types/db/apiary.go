@@ -1,34 +1,109 @@
 package types
 
-import "github.com/guregu/null"
+import (
+	"strings"
 
+	"github.com/guregu/null"
+)
+
+// HiveStatus is the operational state of a hive
+type HiveStatus string
+
+const (
+	HiveActive      HiveStatus = "active"
+	HiveInactive    HiveStatus = "inactive"
+	HiveMaintenance HiveStatus = "maintenance"
+)
+
+// IsValid reports whether s is a known hive status
+func (s HiveStatus) IsValid() bool {
+	switch s {
+	case HiveActive, HiveInactive, HiveMaintenance:
+		return true
+	}
+	return false
+}
+
+// QualityGrade is the grade assigned to a honey harvest
+type QualityGrade string
+
+const (
+	GradeA QualityGrade = "A"
+	GradeB QualityGrade = "B"
+	GradeC QualityGrade = "C"
+	GradeD QualityGrade = "D"
+)
+
+// IsValid reports whether g is a known quality grade
+func (g QualityGrade) IsValid() bool {
+	switch g {
+	case GradeA, GradeB, GradeC, GradeD:
+		return true
+	}
+	return false
+}
+
+// Apiary is a site hives stand at
+//
+// Latitude and Longitude are optional and given together; radius-based
+// quarantine zones only reach apiaries with coordinates. They also assign the
+// apiary to the regions whose boundary contains it. SitePolygon optionally
+// outlines the site.
 type Apiary struct {
-	ApiaryID          int       `json:"apiary_id,omitempty" db:"apiary_id"`
-	Location          string    `json:"location" db:"location"`
-	ManagerID         int       `json:"manager_id" db:"manager_id"`
-	EstablishmentDate null.Time `json:"establishment_date" db:"establishment_date"`
+	ApiaryID          int        `json:"apiary_id,omitempty" db:"apiary_id"`
+	Location          string     `json:"location" db:"location" validate:"required,max=255"`
+	ManagerID         int        `json:"manager_id" db:"manager_id" validate:"gt=0"`
+	EstablishmentDate null.Time  `json:"establishment_date" db:"establishment_date" validate:"omitempty,notfuture"`
+	Latitude          null.Float `json:"latitude" db:"latitude" validate:"omitempty,gte=-90,lte=90"`
+	Longitude         null.Float `json:"longitude" db:"longitude" validate:"omitempty,gte=-180,lte=180"`
+	SitePolygon       Polygon    `json:"site_polygon" db:"site_polygon"`
 }
 
+// Hive is a beehive standing at an apiary
+//
+// TareWeightKg is the weight of the hive without stores, used to estimate stores from weight sensors.
+// ColonyAllowanceKg is the weight of the bees, brood and comb, also subtracted from the estimate.
 type Hive struct {
-	HiveID           int       `json:"hive_id,omitempty" db:"hive_id"`
-	ApiaryID         int       `json:"apiary_id" db:"apiary_id"`
-	HiveType         string    `json:"hive_type" db:"hive_type"`
-	InstallationDate null.Time `json:"installation_date" db:"installation_date"`
-	CurrentStatus    string    `json:"current_status" db:"current_status"`
+	HiveID            int        `json:"hive_id,omitempty" db:"hive_id"`
+	ApiaryID          int        `json:"apiary_id" db:"apiary_id" validate:"gt=0"`
+	HiveType          string     `json:"hive_type" db:"hive_type" validate:"required,max=100"`
+	InstallationDate  null.Time  `json:"installation_date" db:"installation_date" validate:"omitempty,notfuture"`
+	CurrentStatus     HiveStatus `json:"current_status" db:"current_status" validate:"required,enum"`
+	TareWeightKg      null.Float `json:"tare_weight_kg" db:"tare_weight_kg" validate:"omitempty,gt=0,lte=500"`
+	ColonyAllowanceKg float64    `json:"colony_allowance_kg" db:"colony_allowance_kg" validate:"gte=0,lte=500"`
 }
 
+// BeeCommunity is the colony living in a hive
+//
+// QueenAge is in months. It is computed from the registered queen heading the
+// community, falling back to the stored value for communities without one.
+// Communities merged into another one keep their record with a DissolvedDate.
 type BeeCommunity struct {
-	CommunityID        int    `json:"community_id,omitempty" db:"community_id"`
-	HiveID             int    `json:"hive_id" db:"hive_id"`
-	QueenAge           int    `json:"queen_age" db:"queen_age"`
-	PopulationEstimate int    `json:"population_estimate" db:"population_estimate"`
-	HealthStatus       string `json:"health_status" db:"health_status"`
+	CommunityID        int       `json:"community_id,omitempty" db:"community_id"`
+	HiveID             int       `json:"hive_id" db:"hive_id" validate:"gt=0"`
+	QueenAge           int       `json:"queen_age" db:"queen_age" validate:"gte=0"`
+	PopulationEstimate int       `json:"population_estimate" db:"population_estimate" validate:"gte=0"`
+	HealthStatus       string    `json:"health_status" db:"health_status" validate:"max=100"`
+	DissolvedDate      null.Time `json:"dissolved_date" db:"dissolved_date"`
+	MergedInto         null.Int  `json:"merged_into" db:"merged_into"`
 }
 
+// HoneyHarvest is honey taken from a hive
+//
+// Harvests during a treatment withdrawal period are only accepted with a
+// WithdrawalOverrideReason, recorded together with who gave it
 type HoneyHarvest struct {
-	HarvestID    int       `json:"harvest_id,omitempty" db:"harvest_id"`
-	HiveID       int       `json:"hive_id" db:"hive_id"`
-	HarvestDate  null.Time `json:"harvest_date" db:"harvest_date"`
-	Quantity     float32   `json:"quantity" db:"quantity"`
-	QualityGrade string    `json:"quality_grade" db:"quality_grade"`
+	HarvestID                int          `json:"harvest_id,omitempty" db:"harvest_id"`
+	HiveID                   int          `json:"hive_id" db:"hive_id" validate:"gt=0"`
+	HarvestDate              null.Time    `json:"harvest_date" db:"harvest_date" validate:"required,notfuture"`
+	Quantity                 float32      `json:"quantity" db:"quantity" validate:"gte=0"`
+	QualityGrade             QualityGrade `json:"quality_grade" db:"quality_grade" validate:"required,enum"`
+	WithdrawalOverrideReason null.String  `json:"withdrawal_override_reason" db:"withdrawal_override_reason" validate:"omitempty,min=10,max=500"`
+	OverriddenBy             null.Int     `json:"overridden_by" db:"overridden_by"`
+}
+
+// Normalize trims the override reason, dropping one that is only whitespace
+func (h *HoneyHarvest) Normalize() {
+	reason := strings.TrimSpace(h.WithdrawalOverrideReason.String)
+	h.WithdrawalOverrideReason = null.NewString(reason, h.WithdrawalOverrideReason.Valid && reason != "")
 }
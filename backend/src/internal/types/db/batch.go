@@ -0,0 +1,142 @@
+package types
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// BatchStepKind is a processing step a honey batch goes through
+type BatchStepKind string
+
+const (
+	StepExtraction BatchStepKind = "extraction"
+	StepFiltering  BatchStepKind = "filtering"
+	StepSettling   BatchStepKind = "settling"
+	StepCreaming   BatchStepKind = "creaming"
+	StepBottling   BatchStepKind = "bottling"
+	StepOther      BatchStepKind = "other"
+)
+
+// IsValid reports whether k is a known batch step
+func (k BatchStepKind) IsValid() bool {
+	switch k {
+	case StepExtraction, StepFiltering, StepSettling, StepCreaming, StepBottling, StepOther:
+		return true
+	}
+	return false
+}
+
+// TraceLookbackDays is how long before a harvest treatments are reported by a trace by default
+const TraceLookbackDays = 90
+
+// HoneyBatch is honey blended from one or more harvests under a lot number
+//
+// Quantity is the sum of the contributions. QualityGrade is the grade of the batch's
+// latest lab result, or else the worst grade among its harvests.
+type HoneyBatch struct {
+	BatchID       int                 `json:"batch_id" db:"batch_id"`
+	LotNumber     string              `json:"lot_number" db:"lot_number"`
+	CreatedDate   null.Time           `json:"created_date" db:"created_date"`
+	Notes         null.String         `json:"notes" db:"notes"`
+	CreatedBy     null.Int            `json:"created_by" db:"created_by"`
+	CreatedAt     time.Time           `json:"created_at" db:"created_at"`
+	ReleasedDate  null.Time           `json:"released_date" db:"released_date"`
+	ReleasedBy    null.Int            `json:"released_by" db:"released_by"`
+	Quantity      float64             `json:"quantity" db:"quantity"`
+	QualityGrade  null.String         `json:"quality_grade" db:"quality_grade"`
+	Contributions []BatchContribution `json:"contributions,omitempty" db:"-"`
+	Steps         []BatchStep         `json:"steps,omitempty" db:"-"`
+	JarLots       []JarLot            `json:"jar_lots,omitempty" db:"-"`
+}
+
+// BatchContribution is the part of a harvest blended into a batch
+type BatchContribution struct {
+	BatchID     int          `json:"batch_id" db:"batch_id"`
+	HarvestID   int          `json:"harvest_id" db:"harvest_id" validate:"gt=0"`
+	Quantity    float64      `json:"quantity" db:"quantity" validate:"gt=0"`
+	HiveID      int          `json:"hive_id" db:"hive_id"`
+	HarvestDate null.Time    `json:"harvest_date" db:"harvest_date"`
+	Grade       QualityGrade `json:"quality_grade" db:"quality_grade"`
+}
+
+// HoneyBatchRequest creates a batch from parts of harvests
+type HoneyBatchRequest struct {
+	LotNumber   string              `json:"lot_number" validate:"required,max=50"`
+	CreatedDate null.Time           `json:"created_date" validate:"required,notfuture"`
+	Notes       null.String         `json:"notes" validate:"omitempty,max=2000"`
+	Harvests    []BatchContribution `json:"harvests" validate:"required,min=1,dive"`
+}
+
+// BatchHarvestsRequest blends more harvests into an existing batch
+type BatchHarvestsRequest struct {
+	Harvests []BatchContribution `json:"harvests" validate:"required,min=1,dive"`
+}
+
+// BatchStep is a processing step applied to a batch
+//
+// OutputQuantity is the honey left after the step, e.g. after filtering losses
+type BatchStep struct {
+	StepID         int           `json:"step_id" db:"step_id"`
+	BatchID        int           `json:"batch_id" db:"batch_id"`
+	Step           BatchStepKind `json:"step" db:"step" validate:"required,enum"`
+	StepDate       null.Time     `json:"step_date" db:"step_date" validate:"required,notfuture"`
+	OutputQuantity null.Float    `json:"output_quantity" db:"output_quantity" validate:"omitempty,gt=0"`
+	PerformedBy    null.Int      `json:"performed_by" db:"performed_by"`
+	Notes          null.String   `json:"notes" db:"notes" validate:"omitempty,max=2000"`
+	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
+}
+
+// JarLot is a bottling run of a batch into jars sharing a lot number
+type JarLot struct {
+	JarLotID    int       `json:"jar_lot_id" db:"jar_lot_id"`
+	BatchID     int       `json:"batch_id" db:"batch_id"`
+	LotNumber   string    `json:"lot_number" db:"lot_number" validate:"required,max=50"`
+	JarSizeG    int       `json:"jar_size_g" db:"jar_size_g" validate:"gt=0,lte=50000"`
+	JarCount    int       `json:"jar_count" db:"jar_count" validate:"gt=0"`
+	BottledDate null.Time `json:"bottled_date" db:"bottled_date" validate:"required,notfuture"`
+	BestBefore  null.Time `json:"best_before" db:"best_before"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// TracedHarvest is a harvest contributing to a traced lot
+type TracedHarvest struct {
+	HarvestID                int          `json:"harvest_id" db:"harvest_id"`
+	HiveID                   int          `json:"hive_id" db:"hive_id"`
+	ApiaryID                 int          `json:"apiary_id" db:"apiary_id"`
+	Location                 string       `json:"location" db:"location"`
+	HarvestDate              null.Time    `json:"harvest_date" db:"harvest_date"`
+	Quantity                 float64      `json:"quantity" db:"quantity"`
+	QualityGrade             QualityGrade `json:"quality_grade" db:"quality_grade"`
+	WithdrawalOverrideReason null.String  `json:"withdrawal_override_reason" db:"withdrawal_override_reason"`
+}
+
+// TracedTreatment is a treatment of a contributing hive before its harvest
+type TracedTreatment struct {
+	TreatmentApplication
+	ProductName     string `json:"product_name" db:"product_name"`
+	ActiveSubstance string `json:"active_substance" db:"active_substance"`
+}
+
+// TracedInspection is the last inspection of a contributing hive before its harvest
+type TracedInspection struct {
+	HiveID            int         `json:"hive_id" db:"hive_id"`
+	HarvestDate       null.Time   `json:"harvest_date" db:"harvest_date"`
+	InspectionID      null.Int    `json:"inspection_id" db:"inspection_id"`
+	InspectionDate    null.Time   `json:"inspection_date" db:"inspection_date"`
+	DaysBeforeHarvest null.Int    `json:"days_before_harvest" db:"days_before_harvest"`
+	Notes             null.String `json:"notes" db:"notes"`
+}
+
+// LotTrace is everything known upstream of a lot number, for food safety recalls
+type LotTrace struct {
+	LotNumber   string             `json:"lot_number"`
+	Batch       HoneyBatch         `json:"batch"`
+	JarLot      *JarLot            `json:"jar_lot,omitempty"`
+	Harvests    []TracedHarvest    `json:"harvests"`
+	Apiaries    []int              `json:"apiaries"`
+	Treatments  []TracedTreatment  `json:"treatments"`
+	Inspections []TracedInspection `json:"inspections"`
+	LabResults  []LabResult        `json:"lab_results"`
+	Outbreaks   []Outbreak         `json:"outbreaks"`
+}
@@ -0,0 +1,109 @@
+package types
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// ColonyEventKind is the kind of a colony operation
+type ColonyEventKind string
+
+const (
+	ColonySplit    ColonyEventKind = "split"
+	ColonyMerge    ColonyEventKind = "merge"
+	ColonyRelocate ColonyEventKind = "relocate"
+)
+
+// IsValid reports whether k is a known colony event kind
+func (k ColonyEventKind) IsValid() bool {
+	switch k {
+	case ColonySplit, ColonyMerge, ColonyRelocate:
+		return true
+	}
+	return false
+}
+
+// KeepQueen selects which queen heads a merged colony
+type KeepQueen string
+
+const (
+	KeepTargetQueen KeepQueen = "target"
+	KeepSourceQueen KeepQueen = "source"
+)
+
+// IsValid reports whether k is a known choice of queen
+func (k KeepQueen) IsValid() bool {
+	return k == KeepTargetQueen || k == KeepSourceQueen
+}
+
+// HivePlacement is a period during which a hive stood at an apiary, or at a
+// grower's field under a pollination contract
+type HivePlacement struct {
+	PlacementID int       `json:"placement_id" db:"placement_id"`
+	HiveID      int       `json:"hive_id" db:"hive_id"`
+	ApiaryID    int       `json:"apiary_id" db:"apiary_id"`
+	Location    string    `json:"location" db:"location"`
+	PlacedDate  null.Time `json:"placed_date" db:"placed_date"`
+	RemovedDate null.Time `json:"removed_date" db:"removed_date"`
+	ContractID  null.Int  `json:"contract_id" db:"contract_id"`
+}
+
+// ColonyEvent records a split, merge or relocation
+type ColonyEvent struct {
+	EventID           int             `json:"event_id" db:"event_id"`
+	Kind              ColonyEventKind `json:"kind" db:"kind"`
+	EventDate         null.Time       `json:"event_date" db:"event_date"`
+	SourceHiveID      null.Int        `json:"source_hive_id" db:"source_hive_id"`
+	TargetHiveID      null.Int        `json:"target_hive_id" db:"target_hive_id"`
+	SourceCommunityID null.Int        `json:"source_community_id" db:"source_community_id"`
+	TargetCommunityID null.Int        `json:"target_community_id" db:"target_community_id"`
+	FromApiaryID      null.Int        `json:"from_apiary_id" db:"from_apiary_id"`
+	ToApiaryID        null.Int        `json:"to_apiary_id" db:"to_apiary_id"`
+	PopulationMoved   null.Int        `json:"population_moved" db:"population_moved"`
+	PerformedBy       null.Int        `json:"performed_by" db:"performed_by"`
+	Notes             null.String     `json:"notes" db:"notes"`
+	CreatedAt         time.Time       `json:"created_at" db:"created_at"`
+}
+
+// ColonySplitRequest moves part of a community into a new community
+//
+// The new community goes into TargetHiveID, which must be empty, or into a new
+// hive of HiveType placed at ApiaryID (the source hive's apiary by default)
+type ColonySplitRequest struct {
+	SourceCommunityID int         `json:"source_community_id" validate:"gt=0"`
+	TargetHiveID      null.Int    `json:"target_hive_id" validate:"omitempty,gt=0"`
+	HiveType          string      `json:"hive_type" validate:"max=100"`
+	ApiaryID          null.Int    `json:"apiary_id" validate:"omitempty,gt=0"`
+	PopulationMoved   int         `json:"population_moved" validate:"gt=0"`
+	EventDate         null.Time   `json:"event_date" validate:"required,notfuture"`
+	Notes             null.String `json:"notes" validate:"omitempty,max=2000"`
+}
+
+// ColonyMergeRequest combines the source community into the target one
+//
+// KeepQueen picks the queen heading the merged colony; the other one is
+// recorded as replaced
+type ColonyMergeRequest struct {
+	SourceCommunityID int         `json:"source_community_id" validate:"gt=0"`
+	TargetCommunityID int         `json:"target_community_id" validate:"gt=0,nefield=SourceCommunityID"`
+	KeepQueen         KeepQueen   `json:"keep_queen" validate:"required,enum"`
+	EventDate         null.Time   `json:"event_date" validate:"required,notfuture"`
+	Notes             null.String `json:"notes" validate:"omitempty,max=2000"`
+}
+
+// HiveRelocationRequest moves a hive to another apiary
+type HiveRelocationRequest struct {
+	ToApiaryID int         `json:"to_apiary_id" validate:"gt=0"`
+	EventDate  null.Time   `json:"event_date" validate:"required,notfuture"`
+	Notes      null.String `json:"notes" validate:"omitempty,max=2000"`
+}
+
+// ApiaryHarvestTotal is the honey harvested at an apiary, attributed by where
+// each hive stood on the harvest date
+type ApiaryHarvestTotal struct {
+	ApiaryID      int     `json:"apiary_id" db:"apiary_id"`
+	Location      string  `json:"location" db:"location"`
+	TotalQuantity float64 `json:"total_quantity" db:"total_quantity"`
+	HarvestCount  int     `json:"harvest_count" db:"harvest_count"`
+}
@@ -0,0 +1,169 @@
+package types
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// EquipmentCategory groups equipment types; some of them are hive components
+type EquipmentCategory string
+
+const (
+	EquipmentBroodBox       EquipmentCategory = "brood_box"
+	EquipmentSuper          EquipmentCategory = "super"
+	EquipmentFrame          EquipmentCategory = "frame"
+	EquipmentExcluder       EquipmentCategory = "excluder"
+	EquipmentFeeder         EquipmentCategory = "feeder"
+	EquipmentExtractor      EquipmentCategory = "extractor"
+	EquipmentProtectiveGear EquipmentCategory = "protective_gear"
+	EquipmentTool           EquipmentCategory = "tool"
+	EquipmentOther          EquipmentCategory = "other"
+)
+
+// IsValid reports whether c is a known equipment category
+func (c EquipmentCategory) IsValid() bool {
+	switch c {
+	case EquipmentBroodBox, EquipmentSuper, EquipmentFrame, EquipmentExcluder, EquipmentFeeder,
+		EquipmentExtractor, EquipmentProtectiveGear, EquipmentTool, EquipmentOther:
+		return true
+	}
+	return false
+}
+
+// IsHiveComponent reports whether equipment of category c can be put on a hive
+func (c EquipmentCategory) IsHiveComponent() bool {
+	switch c {
+	case EquipmentBroodBox, EquipmentSuper, EquipmentFrame, EquipmentExcluder, EquipmentFeeder:
+		return true
+	}
+	return false
+}
+
+const (
+	// SeasonStartMonth and SeasonStartDay mark when hives are opened up and
+	// supers go on in spring
+	SeasonStartMonth = time.March
+	SeasonStartDay   = 1
+	// LowStockLeadDays is how long before the season equipment below its minimum stock is reported
+	LowStockLeadDays = 60
+)
+
+// EquipmentType is a kind of equipment kept in stock
+//
+// MinStock is how many should be in stores, i.e. not on hives nor checked out,
+// when the season starts
+type EquipmentType struct {
+	TypeID            int               `json:"type_id" db:"type_id"`
+	Name              string            `json:"name" db:"name" validate:"required,max=100"`
+	Category          EquipmentCategory `json:"category" db:"category" validate:"required,enum"`
+	Description       null.String       `json:"description" db:"description" validate:"omitempty,max=2000"`
+	MinStock          int               `json:"min_stock" db:"min_stock" validate:"gte=0"`
+	LowStockAlertedOn null.Time         `json:"low_stock_alerted_on" db:"low_stock_alerted_on"`
+}
+
+type Warehouse struct {
+	WarehouseID int         `json:"warehouse_id" db:"warehouse_id"`
+	Name        string      `json:"name" db:"name" validate:"required,max=100"`
+	Address     null.String `json:"address" db:"address" validate:"omitempty,max=1000"`
+}
+
+// EquipmentStock is how many items of a type are stored at an apiary or a warehouse
+//
+// Quantity excludes items on hives and items checked out, which are shown in CheckedOut
+type EquipmentStock struct {
+	EquipmentStockID int               `json:"equipment_stock_id" db:"equipment_stock_id"`
+	TypeID           int               `json:"type_id" db:"type_id"`
+	TypeName         string            `json:"type_name" db:"type_name"`
+	Category         EquipmentCategory `json:"category" db:"category"`
+	ApiaryID         null.Int          `json:"apiary_id" db:"apiary_id"`
+	WarehouseID      null.Int          `json:"warehouse_id" db:"warehouse_id"`
+	Quantity         int               `json:"quantity" db:"quantity"`
+	CheckedOut       int               `json:"checked_out" db:"checked_out"`
+}
+
+// EquipmentLocation is either an apiary or a warehouse
+type EquipmentLocation struct {
+	ApiaryID    null.Int `json:"apiary_id"`
+	WarehouseID null.Int `json:"warehouse_id"`
+}
+
+// EquipmentStockChange adds items to or removes them from a location, e.g. after
+// buying equipment or writing off broken items
+type EquipmentStockChange struct {
+	EquipmentLocation
+	TypeID         int `json:"type_id" validate:"gt=0"`
+	QuantityChange int `json:"quantity_change" validate:"ne=0"`
+}
+
+// EquipmentTransfer moves items between apiaries and warehouses
+type EquipmentTransfer struct {
+	TypeID   int               `json:"type_id" validate:"gt=0"`
+	From     EquipmentLocation `json:"from"`
+	To       EquipmentLocation `json:"to"`
+	Quantity int               `json:"quantity" validate:"gt=0"`
+}
+
+// HiveComponent is how many items of a type are currently on a hive, e.g. two
+// brood boxes and three supers
+type HiveComponent struct {
+	HiveID    int               `json:"hive_id" db:"hive_id"`
+	TypeID    int               `json:"type_id" db:"type_id"`
+	TypeName  string            `json:"type_name" db:"type_name"`
+	Category  EquipmentCategory `json:"category" db:"category"`
+	Quantity  int               `json:"quantity" db:"quantity"`
+	UpdatedAt time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// HiveComponentChange puts components on a hive, taking them from its apiary's
+// stock, or takes them off when negative, returning them to it
+type HiveComponentChange struct {
+	TypeID         int `json:"type_id" validate:"gt=0"`
+	QuantityChange int `json:"quantity_change" validate:"ne=0"`
+}
+
+// EquipmentCheckout is equipment a worker took from stores
+type EquipmentCheckout struct {
+	CheckoutID       int         `json:"checkout_id" db:"checkout_id"`
+	EquipmentStockID int         `json:"equipment_stock_id" db:"equipment_stock_id"`
+	TypeID           int         `json:"type_id" db:"type_id"`
+	TypeName         string      `json:"type_name" db:"type_name"`
+	UserID           int         `json:"user_id" db:"user_id"`
+	Quantity         int         `json:"quantity" db:"quantity"`
+	CheckedOutAt     time.Time   `json:"checked_out_at" db:"checked_out_at"`
+	DueDate          null.Time   `json:"due_date" db:"due_date"`
+	CheckedInAt      null.Time   `json:"checked_in_at" db:"checked_in_at"`
+	ReturnedQuantity null.Int    `json:"returned_quantity" db:"returned_quantity"`
+	Notes            null.String `json:"notes" db:"notes"`
+}
+
+// CheckoutRequest takes equipment from an apiary or a warehouse
+//
+// UserID defaults to the user checking the equipment out
+type CheckoutRequest struct {
+	EquipmentLocation
+	TypeID   int         `json:"type_id" validate:"gt=0"`
+	UserID   null.Int    `json:"user_id"`
+	Quantity int         `json:"quantity" validate:"gt=0"`
+	DueDate  null.Time   `json:"due_date"`
+	Notes    null.String `json:"notes" validate:"omitempty,max=2000"`
+}
+
+// CheckinRequest returns checked out equipment
+//
+// ReturnedQuantity defaults to everything that was checked out; the rest is written off
+type CheckinRequest struct {
+	ReturnedQuantity null.Int `json:"returned_quantity" validate:"omitempty,gte=0"`
+}
+
+// EquipmentShortage is an equipment type with fewer items in stores than its minimum
+type EquipmentShortage struct {
+	TypeID     int               `json:"type_id" db:"type_id"`
+	Name       string            `json:"name" db:"name"`
+	Category   EquipmentCategory `json:"category" db:"category"`
+	MinStock   int               `json:"min_stock" db:"min_stock"`
+	InStock    int               `json:"in_stock" db:"in_stock"`
+	OnHives    int               `json:"on_hives" db:"on_hives"`
+	CheckedOut int               `json:"checked_out" db:"checked_out"`
+	Shortfall  int               `json:"shortfall" db:"shortfall"`
+}
@@ -4,10 +4,14 @@ import "github.com/guregu/null"
 
 type ProductionReport struct {
 	ReportID      int       `json:"report_id,omitempty" db:"report_id"`
-	ApiaryID      int       `json:"apiary_id" db:"apiary_id"`
-	StartDate     null.Time `json:"start_date" db:"start_date"`
-	EndDate       null.Time `json:"end_date" db:"end_date"`
-	TotalHoney    int       `json:"total_honey_produced" db:"total_honey_produced"`
-	TotalExpenses int       `json:"total_expenses" db:"total_expenses"`
-	CuratedBy     int       `json:"curated_by" db:"curated_by"`
+	ApiaryID      int       `json:"apiary_id" db:"apiary_id" validate:"gt=0"`
+	StartDate     null.Time `json:"start_date" db:"start_date" validate:"required"`
+	EndDate       null.Time `json:"end_date" db:"end_date" validate:"required,gtefield=StartDate"`
+	TotalHoney    int       `json:"total_honey_produced" db:"total_honey_produced" validate:"gte=0"`
+	TotalExpenses int       `json:"total_expenses" db:"total_expenses" validate:"gte=0"`
+	CuratedBy     int       `json:"curated_by" db:"curated_by" validate:"gt=0"`
+	FeedUsedKg    float64   `json:"feed_used_kg" db:"feed_used_kg"`
+	FeedCost      float64   `json:"feed_cost" db:"feed_cost"`
+	TotalRevenue  float64   `json:"total_revenue" db:"total_revenue"`
+	Profit        float64   `json:"profit" db:"profit"`
 }
@@ -0,0 +1,82 @@
+package types
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// FeedType is the kind of feed given to a hive
+type FeedType string
+
+const (
+	FeedSyrup11     FeedType = "syrup_1_1"
+	FeedSyrup21     FeedType = "syrup_2_1"
+	FeedFondant     FeedType = "fondant"
+	FeedPollenPatty FeedType = "pollen_patty"
+	FeedHoney       FeedType = "honey"
+	FeedOther       FeedType = "other"
+)
+
+// IsValid reports whether t is a known feed type
+func (t FeedType) IsValid() bool {
+	switch t {
+	case FeedSyrup11, FeedSyrup21, FeedFondant, FeedPollenPatty, FeedHoney, FeedOther:
+		return true
+	}
+	return false
+}
+
+// WeightSensorType is the sensor type whose readings are the hive's gross weight in kg
+const WeightSensorType = "Weight"
+
+// DefaultWinterStoresKg is the stores a hive needs to winter when no target is given
+const DefaultWinterStoresKg = 20.0
+
+// Feeding is feed given to a hive
+type Feeding struct {
+	FeedingID   int         `json:"feeding_id,omitempty" db:"feeding_id"`
+	HiveID      int         `json:"hive_id" db:"hive_id" validate:"gt=0"`
+	FeedType    FeedType    `json:"feed_type" db:"feed_type" validate:"required,enum"`
+	QuantityKg  float64     `json:"quantity_kg" db:"quantity_kg" validate:"gt=0,lte=1000"`
+	CostPerKg   null.Float  `json:"cost_per_kg" db:"cost_per_kg" validate:"omitempty,gte=0"`
+	FeedingDate null.Time   `json:"feeding_date" db:"feeding_date" validate:"required,notfuture"`
+	FedBy       null.Int    `json:"fed_by" db:"fed_by"`
+	Notes       null.String `json:"notes" db:"notes" validate:"omitempty,max=2000"`
+	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
+}
+
+// FeedUsage is the feed of one type given within a period
+type FeedUsage struct {
+	FeedType   FeedType `json:"feed_type" db:"feed_type"`
+	Feedings   int      `json:"feedings" db:"feedings"`
+	QuantityKg float64  `json:"quantity_kg" db:"quantity_kg"`
+	Cost       float64  `json:"cost" db:"cost"`
+}
+
+// HiveStores is the estimated food stores of a hive
+//
+// StoresKg is the latest weight sensor reading minus the hive's tare weight and
+// colony allowance, and stays empty without a reading or tare weight. FedKg is the feed given since the season start.
+type HiveStores struct {
+	HiveID            int        `json:"hive_id" db:"hive_id"`
+	ApiaryID          int        `json:"apiary_id" db:"apiary_id"`
+	TareWeightKg      null.Float `json:"tare_weight_kg" db:"tare_weight_kg"`
+	ColonyAllowanceKg float64    `json:"colony_allowance_kg" db:"colony_allowance_kg"`
+	WeightKg          null.Float `json:"weight_kg" db:"weight_kg"`
+	MeasuredAt        null.Time  `json:"measured_at" db:"measured_at"`
+	StoresKg          null.Float `json:"stores_kg" db:"stores_kg"`
+	FedKg             float64    `json:"fed_kg" db:"fed_kg"`
+	BelowTarget       null.Bool  `json:"below_target" db:"below_target"`
+}
+
+// WinterReadiness summarizes whether the hives of an apiary have enough stores to winter
+type WinterReadiness struct {
+	ApiaryID    int          `json:"apiary_id"`
+	TargetKg    float64      `json:"target_kg"`
+	SeasonStart null.Time    `json:"season_start"`
+	Ready       int          `json:"ready"`
+	BelowTarget int          `json:"below_target"`
+	Unknown     int          `json:"unknown"`
+	Hives       []HiveStores `json:"hives"`
+}
@@ -0,0 +1,186 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ForagingRangeKm is how far bees forage from their hive; apiaries this close to
+// an outbreak zone share forage with it
+const ForagingRangeKm = 3.0
+
+// Position is a [longitude, latitude] pair, in GeoJSON order
+type Position [2]float64
+
+// Polygon is an outline of a site, stored as a PostgreSQL polygon and exchanged
+// as a GeoJSON Polygon geometry
+//
+// Only the outer ring is kept; holes are not supported. Ring is open: the closing
+// position GeoJSON requires is added on output and dropped on input.
+type Polygon struct {
+	Ring  []Position
+	Valid bool
+}
+
+// polygonGeometry is the GeoJSON form of a Polygon
+type polygonGeometry struct {
+	Type        string       `json:"type"`
+	Coordinates [][]Position `json:"coordinates"`
+}
+
+// Value stores p as "((lon,lat),...)"
+func (p Polygon) Value() (driver.Value, error) {
+	if !p.Valid {
+		return nil, nil
+	}
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, pos := range p.Ring {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString("(" + strconv.FormatFloat(pos[0], 'f', -1, 64) + "," + strconv.FormatFloat(pos[1], 'f', -1, 64) + ")")
+	}
+	b.WriteByte(')')
+	return b.String(), nil
+}
+
+// Scan reads a PostgreSQL polygon
+func (p *Polygon) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*p = Polygon{}
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("cannot scan %T into Polygon", src)
+	}
+
+	numbers := strings.Split(strings.NewReplacer("(", "", ")", "").Replace(s), ",")
+	if len(numbers)%2 != 0 {
+		return fmt.Errorf("invalid polygon %q", s)
+	}
+	ring := make([]Position, 0, len(numbers)/2)
+	for i := 0; i < len(numbers); i += 2 {
+		lon, lonErr := strconv.ParseFloat(strings.TrimSpace(numbers[i]), 64)
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(numbers[i+1]), 64)
+		if err := errors.Join(lonErr, latErr); err != nil {
+			return fmt.Errorf("invalid polygon %q: %w", s, err)
+		}
+		ring = append(ring, Position{lon, lat})
+	}
+	*p = Polygon{Ring: ring, Valid: true}
+	return nil
+}
+
+// MarshalJSON writes p as a GeoJSON Polygon, or null
+func (p Polygon) MarshalJSON() ([]byte, error) {
+	if !p.Valid || len(p.Ring) == 0 {
+		return []byte("null"), nil
+	}
+	ring := append(append([]Position{}, p.Ring...), p.Ring[0])
+	return json.Marshal(polygonGeometry{Type: "Polygon", Coordinates: [][]Position{ring}})
+}
+
+// UnmarshalJSON reads a GeoJSON Polygon with a single ring of at least three
+// positions, or null
+func (p *Polygon) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*p = Polygon{}
+		return nil
+	}
+	var geometry polygonGeometry
+	if err := json.Unmarshal(data, &geometry); err != nil {
+		return err
+	}
+	if geometry.Type != "Polygon" {
+		return fmt.Errorf("geometry type must be Polygon, got %q", geometry.Type)
+	}
+	if len(geometry.Coordinates) != 1 {
+		return errors.New("polygon must have exactly one ring; holes are not supported")
+	}
+
+	ring := geometry.Coordinates[0]
+	if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+		ring = ring[:len(ring)-1]
+	}
+	if len(ring) < 3 {
+		return errors.New("polygon ring must have at least three distinct positions")
+	}
+	for _, pos := range ring {
+		if pos[0] < -180 || pos[0] > 180 || pos[1] < -90 || pos[1] > 90 {
+			return fmt.Errorf("position %v is out of range", pos)
+		}
+	}
+	*p = Polygon{Ring: ring, Valid: true}
+	return nil
+}
+
+// Point is a GeoJSON Point geometry
+type Point struct {
+	Type        string   `json:"type"`
+	Coordinates Position `json:"coordinates"`
+}
+
+// NewPoint creates a Point at longitude lon and latitude lat
+func NewPoint(lon, lat float64) Point {
+	return Point{Type: "Point", Coordinates: Position{lon, lat}}
+}
+
+// FeatureKind tells what a GeoJSON feature stands for
+type FeatureKind string
+
+const (
+	FeatureApiary FeatureKind = "apiary"
+	// FeatureSite is the outline of an apiary's site
+	FeatureSite FeatureKind = "site"
+	// FeatureHive is a hive, drawn at its apiary's coordinates
+	FeatureHive FeatureKind = "hive"
+)
+
+// Feature is a GeoJSON feature; Properties always carries its "kind"
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   interface{}            `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// NewFeature creates a feature of kind with geometry and properties
+func NewFeature(kind FeatureKind, geometry interface{}, properties map[string]interface{}) Feature {
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	properties["kind"] = kind
+	return Feature{Type: "Feature", Geometry: geometry, Properties: properties}
+}
+
+// FeatureCollection is a GeoJSON feature collection
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// ApiaryDistance is an apiary and its distance from a point
+type ApiaryDistance struct {
+	ApiaryID   int     `json:"apiary_id" db:"apiary_id"`
+	Location   string  `json:"location" db:"location"`
+	ManagerID  int     `json:"manager_id" db:"manager_id"`
+	Latitude   float64 `json:"latitude" db:"latitude"`
+	Longitude  float64 `json:"longitude" db:"longitude"`
+	DistanceKm float64 `json:"distance_km" db:"distance_km"`
+}
+
+// OutbreakNeighbour is an apiary outside an outbreak's quarantine zone but within
+// range of it, with the zone apiary nearest to it
+type OutbreakNeighbour struct {
+	ApiaryDistance
+	NearestZoneApiaryID int `json:"nearest_zone_apiary_id" db:"nearest_zone_apiary_id"`
+}
@@ -1,29 +1,194 @@
 package types
 
-import "github.com/guregu/null"
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// MaintenanceStatus is the progress state of a maintenance plan
+type MaintenanceStatus string
+
+const (
+	MaintenancePending    MaintenanceStatus = "Pending"
+	MaintenanceInProgress MaintenanceStatus = "In Progress"
+	MaintenanceCompleted  MaintenanceStatus = "Completed"
+	MaintenanceSkipped    MaintenanceStatus = "Skipped"
+)
+
+// IsValid reports whether s is a known maintenance status
+func (s MaintenanceStatus) IsValid() bool {
+	switch s {
+	case MaintenancePending, MaintenanceInProgress, MaintenanceCompleted, MaintenanceSkipped:
+		return true
+	}
+	return false
+}
+
+// IncidentSeverity is how serious an incident is
+type IncidentSeverity string
+
+const (
+	SeverityLow      IncidentSeverity = "low"
+	SeverityMedium   IncidentSeverity = "medium"
+	SeverityHigh     IncidentSeverity = "high"
+	SeverityCritical IncidentSeverity = "critical"
+)
+
+// IsValid reports whether s is a known incident severity
+func (s IncidentSeverity) IsValid() bool {
+	switch s {
+	case SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical:
+		return true
+	}
+	return false
+}
+
+// severityRanks orders severities from least to most serious
+var severityRanks = map[IncidentSeverity]int{
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     3,
+	SeverityCritical: 4,
+}
+
+// AtLeast reports whether s is as serious as min or more
+func (s IncidentSeverity) AtLeast(min IncidentSeverity) bool {
+	return severityRanks[s] >= severityRanks[min]
+}
+
+// SeveritiesUpTo returns the severities not more serious than s
+func SeveritiesUpTo(s IncidentSeverity) []string {
+	var severities []string
+	for severity, rank := range severityRanks {
+		if rank <= severityRanks[s] {
+			severities = append(severities, string(severity))
+		}
+	}
+	return severities
+}
+
+// IncidentStatus is the lifecycle stage of an incident
+type IncidentStatus string
+
+const (
+	IncidentOpen         IncidentStatus = "open"
+	IncidentAcknowledged IncidentStatus = "acknowledged"
+	IncidentInProgress   IncidentStatus = "in_progress"
+	IncidentResolved     IncidentStatus = "resolved"
+	IncidentClosed       IncidentStatus = "closed"
+)
+
+// incidentTransitions lists the statuses an incident may move to from each status.
+// A resolved incident can be reopened while it is not yet closed.
+var incidentTransitions = map[IncidentStatus][]IncidentStatus{
+	IncidentOpen:         {IncidentAcknowledged},
+	IncidentAcknowledged: {IncidentInProgress},
+	IncidentInProgress:   {IncidentResolved},
+	IncidentResolved:     {IncidentClosed, IncidentInProgress},
+}
+
+// IsValid reports whether s is a known incident status
+func (s IncidentStatus) IsValid() bool {
+	switch s {
+	case IncidentOpen, IncidentAcknowledged, IncidentInProgress, IncidentResolved, IncidentClosed:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo reports whether an incident in status s may move to next
+func (s IncidentStatus) CanTransitionTo(next IncidentStatus) bool {
+	for _, allowed := range incidentTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IncidentActivityKind is the type of an entry in an incident's activity thread
+type IncidentActivityKind string
+
+const (
+	ActivityCreated      IncidentActivityKind = "created"
+	ActivityComment      IncidentActivityKind = "comment"
+	ActivityStatusChange IncidentActivityKind = "status_change"
+	ActivityAssignment   IncidentActivityKind = "assignment"
+)
 
 type ObservationLog struct {
 	LogID           int       `json:"log_id,omitempty" db:"log_id"`
-	HiveID          int       `json:"hive_id" db:"hive_id"`
-	ObservationDate null.Time `json:"observation_date" db:"observation_date"`
-	Description     string    `json:"description" db:"description"`
+	HiveID          int       `json:"hive_id" db:"hive_id" validate:"gt=0"`
+	ObservationDate null.Time `json:"observation_date" db:"observation_date" validate:"required,notfuture"`
+	Description     string    `json:"description" db:"description" validate:"required"`
 	Recommendations string    `json:"recommendations" db:"recommendations"`
 }
 
+// MaintenancePlan is a single dated maintenance task
+//
+// Plans generated from a MaintenanceSchedule keep the schedule and the
+// occurrence they were generated for, even after being rescheduled. Plans
+// assigned to a worker group are handed to one of its members by workload.
 type MaintenancePlan struct {
-	PlanID      int       `json:"plan_id,omitempty" db:"plan_id"`
-	ApiaryID    int       `json:"apiary_id" db:"apiary_id"`
-	PlannedDate null.Time `json:"planned_date" db:"planned_date"`
-	WorkType    string    `json:"work_type" db:"work_type"`
-	AssignedTo  int       `json:"assigned_to" db:"assigned_to"`
-	Status      string    `json:"status" db:"status"`
+	PlanID          int               `json:"plan_id,omitempty" db:"plan_id"`
+	ApiaryID        int               `json:"apiary_id" db:"apiary_id" validate:"gt=0"`
+	HiveID          null.Int          `json:"hive_id" db:"hive_id" validate:"omitempty,gt=0"`
+	PlannedDate     null.Time         `json:"planned_date" db:"planned_date" validate:"required"`
+	WorkType        string            `json:"work_type" db:"work_type" validate:"required,max=100"`
+	AssignedTo      null.Int          `json:"assigned_to" db:"assigned_to" validate:"omitempty,gt=0"`
+	AssignedGroupID null.Int          `json:"assigned_group_id" db:"assigned_group_id" validate:"omitempty,gt=0"`
+	Status          MaintenanceStatus `json:"status" db:"status" validate:"required,enum"`
+	ScheduleID      null.Int          `json:"schedule_id" db:"schedule_id"`
+	OccurrenceDate  null.Time         `json:"occurrence_date" db:"occurrence_date"`
+	OverdueAt       null.Time         `json:"overdue_at" db:"overdue_at"`
 }
 
 type Incident struct {
-	IncidentID   int       `json:"incident_id,omitempty" db:"incident_id"`
-	HiveID       int       `json:"hive_id" db:"hive_id"`
-	IncidentDate null.Time `json:"incident_date" db:"incident_date"`
-	Description  string    `json:"description" db:"description"`
-	Severity     string    `json:"severity" db:"severity"`
-	ActionsTaken string    `json:"actions_taken" db:"actions_taken"`
+	IncidentID      int              `json:"incident_id,omitempty" db:"incident_id"`
+	HiveID          int              `json:"hive_id" db:"hive_id" validate:"gt=0"`
+	IncidentDate    null.Time        `json:"incident_date" db:"incident_date" validate:"required,notfuture"`
+	Description     string           `json:"description" db:"description" validate:"required"`
+	Severity        IncidentSeverity `json:"severity" db:"severity" validate:"required,enum"`
+	ActionsTaken    null.String      `json:"actions_taken" db:"actions_taken"`
+	Status          IncidentStatus   `json:"status" db:"status" validate:"omitempty,enum"`
+	AssignedUserID  null.Int         `json:"assigned_user_id" db:"assigned_user_id"`
+	AssignedGroupID null.Int         `json:"assigned_group_id" db:"assigned_group_id"`
+	CreatedAt       null.Time        `json:"created_at" db:"created_at"`
+	AcknowledgedAt  null.Time        `json:"acknowledged_at" db:"acknowledged_at"`
+	StartedAt       null.Time        `json:"started_at" db:"started_at"`
+	ResolvedAt      null.Time        `json:"resolved_at" db:"resolved_at"`
+	ClosedAt        null.Time        `json:"closed_at" db:"closed_at"`
+	EscalatedAt     null.Time        `json:"escalated_at" db:"escalated_at"`
+}
+
+// IncidentActivity is an entry in an incident's comment and activity thread
+type IncidentActivity struct {
+	ActivityID int                  `json:"activity_id" db:"activity_id"`
+	IncidentID int                  `json:"incident_id" db:"incident_id"`
+	UserID     null.Int             `json:"user_id" db:"user_id"`
+	Kind       IncidentActivityKind `json:"kind" db:"kind"`
+	FromStatus null.String          `json:"from_status" db:"from_status"`
+	ToStatus   null.String          `json:"to_status" db:"to_status"`
+	Body       null.String          `json:"body" db:"body"`
+	CreatedAt  time.Time            `json:"created_at" db:"created_at"`
+}
+
+// IncidentFilter narrows the incident list, empty fields match everything
+type IncidentFilter struct {
+	Statuses   []IncidentStatus
+	Severities []IncidentSeverity
+	HiveID     int
+	AssigneeID int
+	GroupID    int
+}
+
+// IncidentMetrics summarizes how quickly incidents are handled, durations are in seconds
+type IncidentMetrics struct {
+	Total                   int            `json:"total" db:"total"`
+	ByStatus                map[string]int `json:"by_status" db:"-"`
+	AvgTimeToAcknowledge    null.Float     `json:"avg_time_to_acknowledge" db:"avg_time_to_acknowledge"`
+	MedianTimeToAcknowledge null.Float     `json:"median_time_to_acknowledge" db:"median_time_to_acknowledge"`
+	AvgTimeToResolve        null.Float     `json:"avg_time_to_resolve" db:"avg_time_to_resolve"`
+	MedianTimeToResolve     null.Float     `json:"median_time_to_resolve" db:"median_time_to_resolve"`
 }
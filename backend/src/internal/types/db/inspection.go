@@ -0,0 +1,219 @@
+package types
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/lib/pq"
+)
+
+// AnswerType is the kind of value an inspection question takes
+type AnswerType string
+
+const (
+	AnswerBoolean AnswerType = "boolean"
+	AnswerNumber  AnswerType = "number"
+	AnswerChoice  AnswerType = "choice"
+	AnswerText    AnswerType = "text"
+)
+
+// IsValid reports whether t is a known answer type
+func (t AnswerType) IsValid() bool {
+	switch t {
+	case AnswerBoolean, AnswerNumber, AnswerChoice, AnswerText:
+		return true
+	}
+	return false
+}
+
+// maxTextAnswer is the longest accepted free text answer
+const maxTextAnswer = 2000
+
+// InspectionTemplate is a manager-defined checklist for hive inspections
+type InspectionTemplate struct {
+	TemplateID  int                  `json:"template_id,omitempty" db:"template_id"`
+	Name        string               `json:"name" db:"name" validate:"required,max=100"`
+	Description null.String          `json:"description" db:"description"`
+	CreatedBy   null.Int             `json:"created_by" db:"created_by"`
+	Active      bool                 `json:"active" db:"active"`
+	CreatedAt   null.Time            `json:"created_at" db:"created_at"`
+	Questions   []InspectionQuestion `json:"questions" db:"-" validate:"required,min=1,dive"`
+}
+
+// InspectionQuestion is a single checklist item of a template
+//
+// Key identifies the question in answers and queries, e.g. "queen_seen".
+// Options lists the allowed values of choice questions; MinValue and MaxValue
+// bound number answers.
+type InspectionQuestion struct {
+	QuestionID int            `json:"question_id,omitempty" db:"question_id"`
+	TemplateID int            `json:"template_id,omitempty" db:"template_id"`
+	Key        string         `json:"key" db:"key" validate:"required,max=50,lowercase,excludesall= -"`
+	Label      string         `json:"label" db:"label" validate:"required,max=200"`
+	AnswerType AnswerType     `json:"answer_type" db:"answer_type" validate:"required,enum"`
+	Options    pq.StringArray `json:"options" db:"options" validate:"omitempty,dive,required,max=100"`
+	MinValue   null.Float     `json:"min_value" db:"min_value"`
+	MaxValue   null.Float     `json:"max_value" db:"max_value"`
+	Required   bool           `json:"required" db:"required"`
+	Position   int            `json:"position" db:"position"`
+}
+
+// Inspection is a structured inspection of a hive against a template
+//
+// Answers maps question keys to booleans, numbers or strings depending on the
+// question's answer type
+type Inspection struct {
+	InspectionID   int                    `json:"inspection_id,omitempty" db:"inspection_id"`
+	HiveID         int                    `json:"hive_id" db:"hive_id" validate:"gt=0"`
+	TemplateID     int                    `json:"template_id" db:"template_id" validate:"gt=0"`
+	InspectedBy    null.Int               `json:"inspected_by" db:"inspected_by"`
+	InspectionDate null.Time              `json:"inspection_date" db:"inspection_date" validate:"required,notfuture"`
+	Notes          null.String            `json:"notes" db:"notes" validate:"omitempty,max=4000"`
+	CreatedAt      null.Time              `json:"created_at" db:"created_at"`
+	Answers        map[string]interface{} `json:"answers" db:"-"`
+}
+
+// InspectionAnswer is the stored answer to one question, in the column matching its type
+type InspectionAnswer struct {
+	InspectionID int         `json:"inspection_id" db:"inspection_id"`
+	QuestionID   int         `json:"question_id" db:"question_id"`
+	Key          string      `json:"key" db:"key"`
+	ValueBool    null.Bool   `json:"-" db:"value_bool"`
+	ValueNumber  null.Float  `json:"-" db:"value_number"`
+	ValueText    null.String `json:"-" db:"value_text"`
+}
+
+// Value returns the answer as a boolean, number or string
+func (a InspectionAnswer) Value() interface{} {
+	switch {
+	case a.ValueBool.Valid:
+		return a.ValueBool.Bool
+	case a.ValueNumber.Valid:
+		return a.ValueNumber.Float64
+	case a.ValueText.Valid:
+		return a.ValueText.String
+	}
+	return nil
+}
+
+// Answer checks a raw answer against the question and converts it to its stored form
+func (q InspectionQuestion) Answer(value interface{}) (InspectionAnswer, error) {
+	answer := InspectionAnswer{QuestionID: q.QuestionID, Key: q.Key}
+
+	switch q.AnswerType {
+	case AnswerBoolean:
+		b, ok := value.(bool)
+		if !ok {
+			return answer, fmt.Errorf("%s must be true or false", q.Key)
+		}
+		answer.ValueBool = null.BoolFrom(b)
+	case AnswerNumber:
+		n, ok := toNumber(value)
+		if !ok {
+			return answer, fmt.Errorf("%s must be a number", q.Key)
+		}
+		if q.MinValue.Valid && n < q.MinValue.Float64 {
+			return answer, fmt.Errorf("%s must be at least %v", q.Key, q.MinValue.Float64)
+		}
+		if q.MaxValue.Valid && n > q.MaxValue.Float64 {
+			return answer, fmt.Errorf("%s must be at most %v", q.Key, q.MaxValue.Float64)
+		}
+		answer.ValueNumber = null.FloatFrom(n)
+	case AnswerChoice:
+		s, ok := value.(string)
+		if !ok || !contains(q.Options, s) {
+			return answer, fmt.Errorf("%s must be one of: %v", q.Key, []string(q.Options))
+		}
+		answer.ValueText = null.StringFrom(s)
+	case AnswerText:
+		s, ok := value.(string)
+		if !ok || len(s) > maxTextAnswer {
+			return answer, fmt.Errorf("%s must be a text of at most %d characters", q.Key, maxTextAnswer)
+		}
+		answer.ValueText = null.StringFrom(s)
+	default:
+		return answer, fmt.Errorf("%s has unsupported answer type %q", q.Key, q.AnswerType)
+	}
+	return answer, nil
+}
+
+// Answers checks raw answers keyed by question key against the template
+//
+// Unknown keys and unanswered required questions are rejected
+func (t InspectionTemplate) Answers(values map[string]interface{}) ([]InspectionAnswer, error) {
+	questions := make(map[string]InspectionQuestion, len(t.Questions))
+	for _, question := range t.Questions {
+		questions[question.Key] = question
+	}
+	for key := range values {
+		if _, ok := questions[key]; !ok {
+			return nil, fmt.Errorf("%s is not a question of template %q", key, t.Name)
+		}
+	}
+
+	var answers []InspectionAnswer
+	for _, question := range t.Questions {
+		value, ok := values[question.Key]
+		if !ok || value == nil {
+			if question.Required {
+				return nil, fmt.Errorf("%s is required", question.Key)
+			}
+			continue
+		}
+		answer, err := question.Answer(value)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, answer)
+	}
+	return answers, nil
+}
+
+// InspectionMatch is a hive whose inspection answered a question as searched for
+type InspectionMatch struct {
+	HiveID         int       `json:"hive_id" db:"hive_id"`
+	InspectionID   int       `json:"inspection_id" db:"inspection_id"`
+	InspectionDate time.Time `json:"inspection_date" db:"inspection_date"`
+	InspectionAnswer
+	Value interface{} `json:"value" db:"-"`
+}
+
+// InspectionTrendPoint is one answer of a hive to a question over time
+type InspectionTrendPoint struct {
+	InspectionID   int       `json:"inspection_id" db:"inspection_id"`
+	InspectionDate time.Time `json:"inspection_date" db:"inspection_date"`
+	InspectionAnswer
+	Value interface{} `json:"value" db:"-"`
+}
+
+// InspectionTrend is the series of a hive's answers to one question
+type InspectionTrend struct {
+	Key    string                 `json:"key"`
+	Points []InspectionTrendPoint `json:"points"`
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package types
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// GradeRule holds the limits a lab result must meet for a grade
+//
+// Unset limits are not checked. A result meeting no rule is graded D and out of spec.
+type GradeRule struct {
+	Grade              QualityGrade `json:"grade" db:"grade" validate:"required,oneof=A B C"`
+	MaxMoisturePercent null.Float   `json:"max_moisture_percent" db:"max_moisture_percent" validate:"omitempty,gte=0,lte=100"`
+	MaxHMFMgKg         null.Float   `json:"max_hmf_mg_kg" db:"max_hmf_mg_kg" validate:"omitempty,gte=0"`
+	MinDiastaseDN      null.Float   `json:"min_diastase_dn" db:"min_diastase_dn" validate:"omitempty,gte=0"`
+	Description        null.String  `json:"description" db:"description" validate:"omitempty,max=500"`
+}
+
+// LabResult is a lab analysis of a sample taken from a harvest or a honey batch
+//
+// Grade and OutOfSpec are derived from the measurements by the database
+type LabResult struct {
+	ResultID        int         `json:"result_id" db:"result_id"`
+	HarvestID       null.Int    `json:"harvest_id" db:"harvest_id"`
+	BatchID         null.Int    `json:"batch_id" db:"batch_id"`
+	LabName         null.String `json:"lab_name" db:"lab_name" validate:"omitempty,max=200"`
+	SampleReference null.String `json:"sample_reference" db:"sample_reference" validate:"omitempty,max=100"`
+	SampleDate      null.Time   `json:"sample_date" db:"sample_date" validate:"required,notfuture"`
+	ResultDate      null.Time   `json:"result_date" db:"result_date" validate:"omitempty,notfuture"`
+	MoisturePercent null.Float  `json:"moisture_percent" db:"moisture_percent" validate:"omitempty,gte=0,lte=100"`
+	HMFMgKg         null.Float  `json:"hmf_mg_kg" db:"hmf_mg_kg" validate:"omitempty,gte=0"`
+	DiastaseDN      null.Float  `json:"diastase_dn" db:"diastase_dn" validate:"omitempty,gte=0"`
+	PollenAnalysis  null.String `json:"pollen_analysis" db:"pollen_analysis" validate:"omitempty,max=2000"`
+	ResidueDetected null.Bool   `json:"residue_detected" db:"residue_detected"`
+	ResidueNotes    null.String `json:"residue_notes" db:"residue_notes" validate:"omitempty,max=2000"`
+	Grade           null.String `json:"grade" db:"grade"`
+	OutOfSpec       bool        `json:"out_of_spec" db:"out_of_spec"`
+	RecordedBy      null.Int    `json:"recorded_by" db:"recorded_by"`
+	CreatedAt       time.Time   `json:"created_at" db:"created_at"`
+}
+
+// LabImportError is a CSV row that could not be imported as a lab result
+type LabImportError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// LabImportResult summarises a CSV import of lab results
+type LabImportResult struct {
+	Imported  int         `json:"imported"`
+	OutOfSpec int         `json:"out_of_spec"`
+	Results   []LabResult `json:"results"`
+}
+
+// BatchReleaseRequest releases a batch for sale
+type BatchReleaseRequest struct {
+	ReleasedDate null.Time `json:"released_date" validate:"required,notfuture"`
+}
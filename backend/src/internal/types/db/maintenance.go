@@ -0,0 +1,55 @@
+package types
+
+import (
+	"github.com/guregu/null"
+	"github.com/lib/pq"
+)
+
+// MaintenanceSchedule is a recurring maintenance task expanded into concrete
+// maintenance plans ahead of time
+//
+// Without hives a single apiary-wide plan is generated per occurrence,
+// otherwise one plan per listed hive
+type MaintenanceSchedule struct {
+	ScheduleID      int           `json:"schedule_id,omitempty" db:"schedule_id"`
+	ApiaryID        int           `json:"apiary_id" db:"apiary_id" validate:"gt=0"`
+	HiveIDs         pq.Int64Array `json:"hive_ids" db:"hive_ids" validate:"omitempty,dive,gt=0"`
+	WorkType        string        `json:"work_type" db:"work_type" validate:"required,max=100"`
+	AssignedTo      null.Int      `json:"assigned_to" db:"assigned_to" validate:"omitempty,gt=0"`
+	AssignedGroupID null.Int      `json:"assigned_group_id" db:"assigned_group_id" validate:"omitempty,gt=0"`
+	RRule           string        `json:"rrule" db:"rrule" validate:"required,rrule,max=500"`
+	StartDate       null.Time     `json:"start_date" db:"start_date" validate:"required"`
+	HorizonDays     int           `json:"horizon_days" db:"horizon_days" validate:"gte=1,lte=365"`
+	Active          bool          `json:"active" db:"active"`
+	CreatedAt       null.Time     `json:"created_at" db:"created_at"`
+}
+
+// OccurrenceAction is what to do with a single occurrence of a schedule
+type OccurrenceAction string
+
+const (
+	OccurrenceSkip       OccurrenceAction = "skip"
+	OccurrenceReschedule OccurrenceAction = "reschedule"
+)
+
+// IsValid reports whether a is a known occurrence action
+func (a OccurrenceAction) IsValid() bool {
+	return a == OccurrenceSkip || a == OccurrenceReschedule
+}
+
+// OccurrenceChange skips or moves one occurrence of a schedule, whether or not
+// its plans were generated yet
+type OccurrenceChange struct {
+	OccurrenceDate null.Time        `json:"occurrence_date" validate:"required"`
+	Action         OccurrenceAction `json:"action" validate:"required,enum"`
+	PlannedDate    null.Time        `json:"planned_date"`
+}
+
+// MaintenanceCompletion records who completed a maintenance plan and how long it took
+type MaintenanceCompletion struct {
+	PlanID          int         `json:"plan_id" db:"plan_id"`
+	CompletedBy     int         `json:"completed_by" db:"completed_by"`
+	CompletedAt     null.Time   `json:"completed_at" db:"completed_at"`
+	DurationMinutes int         `json:"duration_minutes" db:"duration_minutes" validate:"gte=0,lte=10080"`
+	Notes           null.String `json:"notes" db:"notes" validate:"omitempty,max=2000"`
+}
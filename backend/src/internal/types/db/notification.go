@@ -0,0 +1,87 @@
+package types
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// NotificationChannel is a way of delivering notifications to a user
+type NotificationChannel string
+
+const (
+	ChannelEmail   NotificationChannel = "email"
+	ChannelWebhook NotificationChannel = "webhook"
+	ChannelInbox   NotificationChannel = "inbox"
+)
+
+// NotificationRule routes incidents to a user or a worker group
+//
+// Empty apiary and region match every apiary and region; only incidents at or
+// above MinSeverity are routed
+type NotificationRule struct {
+	RuleID      int              `json:"rule_id,omitempty" db:"rule_id"`
+	Name        string           `json:"name" db:"name" validate:"required,max=100"`
+	ApiaryID    null.Int         `json:"apiary_id" db:"apiary_id" validate:"omitempty,gt=0"`
+	RegionID    null.Int         `json:"region_id" db:"region_id" validate:"omitempty,gt=0"`
+	MinSeverity IncidentSeverity `json:"min_severity" db:"min_severity" validate:"required,enum"`
+	UserID      null.Int         `json:"user_id" db:"user_id" validate:"omitempty,gt=0"`
+	GroupID     null.Int         `json:"group_id" db:"group_id" validate:"omitempty,gt=0"`
+	Enabled     bool             `json:"enabled" db:"enabled"`
+	CreatedAt   null.Time        `json:"created_at" db:"created_at"`
+}
+
+// NotificationPreference holds how and when a user wants to be notified
+//
+// Quiet hours are local "HH:MM" times in Timezone; during them only the inbox
+// receives notifications, except for critical incidents, and emails and webhooks
+// are sent once they end
+type NotificationPreference struct {
+	UserID          int              `json:"user_id" db:"user_id"`
+	EmailEnabled    bool             `json:"email_enabled" db:"email_enabled"`
+	InboxEnabled    bool             `json:"inbox_enabled" db:"inbox_enabled"`
+	WebhookURL      null.String      `json:"webhook_url" db:"webhook_url" validate:"omitempty,url,startswith=https://,max=500"`
+	MinSeverity     IncidentSeverity `json:"min_severity" db:"min_severity" validate:"required,enum"`
+	QuietHoursStart null.String      `json:"quiet_hours_start" db:"quiet_hours_start" validate:"omitempty,datetime=15:04"`
+	QuietHoursEnd   null.String      `json:"quiet_hours_end" db:"quiet_hours_end" validate:"omitempty,datetime=15:04"`
+	Timezone        string           `json:"timezone" db:"timezone" validate:"required,timezone"`
+}
+
+// NotificationRecipient is a user to notify together with their preferences
+type NotificationRecipient struct {
+	NotificationPreference
+	Email    string `db:"email"`
+	FullName string `db:"full_name"`
+}
+
+// Notification is a message in a user's in-app inbox
+type Notification struct {
+	NotificationID int       `json:"notification_id" db:"notification_id"`
+	UserID         int       `json:"user_id" db:"user_id"`
+	IncidentID     null.Int  `json:"incident_id" db:"incident_id"`
+	OutbreakID     null.Int  `json:"outbreak_id" db:"outbreak_id"`
+	LabResultID    null.Int  `json:"lab_result_id" db:"lab_result_id"`
+	Subject        string    `json:"subject" db:"subject"`
+	Body           string    `json:"body" db:"body"`
+	ReadAt         null.Time `json:"read_at" db:"read_at"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// DeferredNotification is an email or webhook notification held back by the
+// recipient's quiet hours, sent once DeliverAfter has passed
+type DeferredNotification struct {
+	DeferredID   int       `json:"deferred_id" db:"deferred_id"`
+	UserID       int       `json:"user_id" db:"user_id"`
+	IncidentID   null.Int  `json:"incident_id" db:"incident_id"`
+	Subject      string    `json:"subject" db:"subject"`
+	Body         string    `json:"body" db:"body"`
+	DeliverAfter time.Time `json:"deliver_after" db:"deliver_after"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// IncidentEscalation is an unacknowledged incident due for escalation to its apiary manager
+type IncidentEscalation struct {
+	Incident
+	ApiaryID  int `db:"apiary_id"`
+	ManagerID int `db:"manager_id"`
+}
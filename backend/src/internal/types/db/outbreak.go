@@ -0,0 +1,83 @@
+package types
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// Disease is a catalogue entry for a bee disease
+//
+// Recording a notifiable disease declares an outbreak around the affected
+// apiary, DefaultRadiusKm wide
+type Disease struct {
+	DiseaseID       int         `json:"disease_id,omitempty" db:"disease_id"`
+	Name            string      `json:"name" db:"name" validate:"required,max=100"`
+	Notifiable      bool        `json:"notifiable" db:"notifiable"`
+	DefaultRadiusKm null.Float  `json:"default_radius_km" db:"default_radius_km" validate:"omitempty,gt=0,lte=1000"`
+	Description     null.String `json:"description" db:"description" validate:"omitempty,max=2000"`
+}
+
+// Outbreak is a declared disease outbreak and its quarantine zone
+//
+// The zone is either every apiary of RegionID or the apiaries within RadiusKm
+// of ApiaryID. Apiaries in the zone stay quarantined until the outbreak is lifted.
+type Outbreak struct {
+	OutbreakID   int         `json:"outbreak_id,omitempty" db:"outbreak_id"`
+	DiseaseID    int         `json:"disease_id" db:"disease_id" validate:"gt=0"`
+	DiseaseName  string      `json:"disease_name" db:"disease_name"`
+	RecordID     null.Int    `json:"record_id" db:"record_id" validate:"omitempty,gt=0"`
+	RegionID     null.Int    `json:"region_id" db:"region_id" validate:"omitempty,gt=0"`
+	ApiaryID     null.Int    `json:"apiary_id" db:"apiary_id" validate:"omitempty,gt=0"`
+	RadiusKm     null.Float  `json:"radius_km" db:"radius_km" validate:"omitempty,gte=0,lte=1000"`
+	DeclaredDate null.Time   `json:"declared_date" db:"declared_date" validate:"required,notfuture"`
+	DeclaredBy   null.Int    `json:"declared_by" db:"declared_by"`
+	LiftedDate   null.Time   `json:"lifted_date" db:"lifted_date"`
+	LiftedBy     null.Int    `json:"lifted_by" db:"lifted_by"`
+	Description  null.String `json:"description" db:"description" validate:"omitempty,max=2000"`
+	CreatedAt    time.Time   `json:"created_at" db:"created_at"`
+}
+
+// OutbreakLiftRequest ends an outbreak and the quarantine it imposed
+type OutbreakLiftRequest struct {
+	LiftedDate null.Time `json:"lifted_date" validate:"required,notfuture"`
+}
+
+// QuarantinedApiary is an apiary inside the quarantine zone of an outbreak
+//
+// DistanceKm is measured from the centre of radius-based zones
+type QuarantinedApiary struct {
+	OutbreakID  int        `json:"outbreak_id" db:"outbreak_id"`
+	ApiaryID    int        `json:"apiary_id" db:"apiary_id"`
+	Location    string     `json:"location" db:"location"`
+	ManagerID   null.Int   `json:"manager_id" db:"manager_id"`
+	Latitude    null.Float `json:"latitude" db:"latitude"`
+	Longitude   null.Float `json:"longitude" db:"longitude"`
+	DistanceKm  null.Float `json:"distance_km" db:"distance_km"`
+	HiveCount   int        `json:"hive_count" db:"hive_count"`
+	ColonyCount int        `json:"colony_count" db:"colony_count"`
+}
+
+// OutbreakCase is a veterinary record of the outbreak's disease within its zone
+type OutbreakCase struct {
+	RecordID    int       `json:"record_id" db:"record_id"`
+	RecordDate  null.Time `json:"record_date" db:"record_date"`
+	ApiaryID    int       `json:"apiary_id" db:"apiary_id"`
+	HiveID      int       `json:"hive_id" db:"hive_id"`
+	CommunityID int       `json:"community_id" db:"community_id"`
+	Description string    `json:"description" db:"description"`
+	Treatment   string    `json:"treatment" db:"treatment"`
+}
+
+// OutbreakReport gathers what a regulator needs to know about an outbreak
+type OutbreakReport struct {
+	Outbreak      Outbreak            `json:"outbreak"`
+	Disease       Disease             `json:"disease"`
+	Zone          []QuarantinedApiary `json:"zone"`
+	Cases         []OutbreakCase      `json:"cases"`
+	ApiaryCount   int                 `json:"apiary_count"`
+	HiveCount     int                 `json:"hive_count"`
+	ColonyCount   int                 `json:"colony_count"`
+	AffectedHives int                 `json:"affected_hives"`
+	GeneratedAt   time.Time           `json:"generated_at"`
+}
@@ -0,0 +1,92 @@
+package types
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// ContractStatus is where a pollination contract is in its lifecycle
+type ContractStatus string
+
+const (
+	// ContractPlanned contracts have hives assigned but not moved yet
+	ContractPlanned ContractStatus = "planned"
+	// ContractActive contracts have hives at the grower's field
+	ContractActive ContractStatus = "active"
+	// ContractCompleted contracts had all their hives returned; their price counts as revenue
+	ContractCompleted ContractStatus = "completed"
+	ContractCancelled ContractStatus = "cancelled"
+)
+
+// IsValid reports whether s is a known contract status
+func (s ContractStatus) IsValid() bool {
+	switch s {
+	case ContractPlanned, ContractActive, ContractCompleted, ContractCancelled:
+		return true
+	}
+	return false
+}
+
+// PollinationContract rents hives out to a grower for pollinating a crop
+//
+// The grower's field is an apiary of its own, created with the contract, so hives
+// placed there keep a regular placement history. Price is for the whole contract.
+type PollinationContract struct {
+	ContractID     int            `json:"contract_id" db:"contract_id"`
+	CustomerID     int            `json:"customer_id" db:"customer_id" validate:"gt=0"`
+	Crop           string         `json:"crop" db:"crop" validate:"required,max=100"`
+	FieldApiaryID  int            `json:"field_apiary_id" db:"field_apiary_id"`
+	FieldLocation  string         `json:"field_location" db:"field_location" validate:"required,max=255"`
+	FieldLatitude  null.Float     `json:"field_latitude" db:"field_latitude" validate:"omitempty,gte=-90,lte=90"`
+	FieldLongitude null.Float     `json:"field_longitude" db:"field_longitude" validate:"omitempty,gte=-180,lte=180"`
+	HiveCount      int            `json:"hive_count" db:"hive_count" validate:"gt=0"`
+	StartDate      null.Time      `json:"start_date" db:"start_date" validate:"required"`
+	EndDate        null.Time      `json:"end_date" db:"end_date" validate:"required"`
+	Price          float64        `json:"price" db:"price" validate:"gte=0"`
+	Status         ContractStatus `json:"status" db:"status"`
+	Notes          null.String    `json:"notes" db:"notes" validate:"omitempty,max=2000"`
+	CreatedBy      null.Int       `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
+	Hives          []ContractHive `json:"hives,omitempty" db:"-"`
+}
+
+// ContractHive is a hive assigned to a pollination contract
+//
+// HomeApiaryID is where the hive stood when it was assigned and where it returns to
+type ContractHive struct {
+	ContractID   int       `json:"contract_id" db:"contract_id"`
+	HiveID       int       `json:"hive_id" db:"hive_id"`
+	HomeApiaryID int       `json:"home_apiary_id" db:"home_apiary_id"`
+	DeployedDate null.Time `json:"deployed_date" db:"deployed_date"`
+	ReturnedDate null.Time `json:"returned_date" db:"returned_date"`
+}
+
+// ContractHivesRequest assigns hives to a contract or unassigns them
+type ContractHivesRequest struct {
+	HiveIDs []int `json:"hive_ids" validate:"required,min=1,dive,gt=0"`
+}
+
+// ContractMoveRequest moves a contract's hives to the field or back home
+type ContractMoveRequest struct {
+	MoveDate null.Time   `json:"move_date" validate:"required,notfuture"`
+	Notes    null.String `json:"notes" validate:"omitempty,max=2000"`
+}
+
+// HiveBooking is a contract a hive is assigned to
+type HiveBooking struct {
+	ContractID int            `json:"contract_id" db:"contract_id"`
+	Crop       string         `json:"crop" db:"crop"`
+	StartDate  null.Time      `json:"start_date" db:"start_date"`
+	EndDate    null.Time      `json:"end_date" db:"end_date"`
+	Status     ContractStatus `json:"status" db:"status"`
+}
+
+// HiveAvailability tells whether a hive is free for a new contract over a period
+// and lists the contracts it is already booked for then
+type HiveAvailability struct {
+	HiveID    int           `json:"hive_id"`
+	ApiaryID  int           `json:"apiary_id"`
+	Available bool          `json:"available"`
+	Bookings  []HiveBooking `json:"bookings"`
+}
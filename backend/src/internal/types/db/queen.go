@@ -0,0 +1,109 @@
+package types
+
+import "github.com/guregu/null"
+
+// QueenSource is where a queen came from
+type QueenSource string
+
+const (
+	QueenBred      QueenSource = "bred"
+	QueenPurchased QueenSource = "purchased"
+	QueenSwarm     QueenSource = "swarm"
+)
+
+// IsValid reports whether s is a known queen source
+func (s QueenSource) IsValid() bool {
+	switch s {
+	case QueenBred, QueenPurchased, QueenSwarm:
+		return true
+	}
+	return false
+}
+
+// QueenStatus is the state of a queen in the registry
+type QueenStatus string
+
+const (
+	QueenPresent    QueenStatus = "present"
+	QueenSuperseded QueenStatus = "superseded"
+	QueenMissing    QueenStatus = "missing"
+	QueenReplaced   QueenStatus = "replaced"
+)
+
+// IsValid reports whether s is a known queen status
+func (s QueenStatus) IsValid() bool {
+	switch s {
+	case QueenPresent, QueenSuperseded, QueenMissing, QueenReplaced:
+		return true
+	}
+	return false
+}
+
+// QueenMoved is the removal reason of an introduction ended by moving the queen
+// to another community; her status stays present
+const QueenMoved = "moved"
+
+// Queen is a queen in the registry
+//
+// AgeMonths is computed from the birth date, or the first introduction when it
+// is unknown. CommunityID and HiveID point to where she currently heads a
+// community, if anywhere.
+type Queen struct {
+	QueenID      int         `json:"queen_id,omitempty" db:"queen_id"`
+	BirthDate    null.Time   `json:"birth_date" db:"birth_date" validate:"omitempty,notfuture"`
+	Breed        string      `json:"breed" db:"breed" validate:"required,max=100"`
+	Line         null.String `json:"line" db:"line" validate:"omitempty,max=100"`
+	MarkingColor null.String `json:"marking_color" db:"marking_color" validate:"omitempty,oneof=white yellow red green blue"`
+	MotherID     null.Int    `json:"mother_id" db:"mother_id"`
+	Source       QueenSource `json:"source" db:"source" validate:"required,enum"`
+	Status       QueenStatus `json:"status" db:"status" validate:"required,enum"`
+	Notes        null.String `json:"notes" db:"notes" validate:"omitempty,max=2000"`
+	CreatedAt    null.Time   `json:"created_at" db:"created_at"`
+	AgeMonths    null.Int    `json:"age_months" db:"age_months"`
+	CommunityID  null.Int    `json:"community_id" db:"community_id"`
+	HiveID       null.Int    `json:"hive_id" db:"hive_id"`
+}
+
+// QueenIntroduction is a period during which a queen headed a community
+type QueenIntroduction struct {
+	IntroductionID int         `json:"introduction_id,omitempty" db:"introduction_id"`
+	QueenID        int         `json:"queen_id" db:"queen_id" validate:"gt=0"`
+	CommunityID    int         `json:"community_id" db:"community_id" validate:"gt=0"`
+	IntroducedDate null.Time   `json:"introduced_date" db:"introduced_date" validate:"required,notfuture"`
+	RemovedDate    null.Time   `json:"removed_date" db:"removed_date"`
+	RemovalReason  null.String `json:"removal_reason" db:"removal_reason"`
+	Notes          null.String `json:"notes" db:"notes" validate:"omitempty,max=2000"`
+}
+
+// QueenStatusChange ends a queen's reign with a status other than present
+type QueenStatusChange struct {
+	Status QueenStatus `json:"status" validate:"required,enum,ne=present"`
+	Date   null.Time   `json:"date" validate:"required,notfuture"`
+	Notes  null.String `json:"notes" validate:"omitempty,max=2000"`
+}
+
+// RequeeningEntry is one queen of a hive's requeening history
+type RequeeningEntry struct {
+	QueenIntroduction
+	HiveID       int         `json:"hive_id" db:"hive_id"`
+	Breed        string      `json:"breed" db:"breed"`
+	Line         null.String `json:"line" db:"line"`
+	MarkingColor null.String `json:"marking_color" db:"marking_color"`
+	Source       QueenSource `json:"source" db:"source"`
+}
+
+// QueenRelative is a queen related to another one in a lineage query
+//
+// Generation counts the steps from the queried queen: 1 for her mother or
+// daughters, 2 for grandmothers or granddaughters, and so on
+type QueenRelative struct {
+	Queen
+	Generation int `json:"generation" db:"generation"`
+}
+
+// QueenLineage is a queen with her ancestors and descendants
+type QueenLineage struct {
+	Queen       Queen           `json:"queen"`
+	Ancestors   []QueenRelative `json:"ancestors"`
+	Descendants []QueenRelative `json:"descendants"`
+}
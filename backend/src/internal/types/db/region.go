@@ -1,23 +1,56 @@
 package types
 
+import "github.com/guregu/null"
+
+// Region is an area apiaries belong to
+//
+// Regions nest into a tree through ParentRegionID (country -> province ->
+// district); access to a region extends to every region nested in it. A region
+// with a Boundary owns exactly the apiaries whose coordinates fall inside it;
+// apiaries are assigned to regions without one by hand
 type Region struct {
-	RegionID    int    `json:"region_id,omitempty" db:"region_id"`
-	Name        string `json:"name" db:"name"`
-	ClimateZone string `json:"climate_zone" db:"climate_zone"`
+	RegionID       int      `json:"region_id,omitempty" db:"region_id"`
+	Name           string   `json:"name" db:"name" validate:"required,max=255"`
+	ClimateZone    string   `json:"climate_zone" db:"climate_zone" validate:"max=100"`
+	Boundary       Polygon  `json:"boundary" db:"boundary"`
+	ParentRegionID null.Int `json:"parent_region_id" db:"parent_region_id" validate:"omitempty,gt=0"`
+}
+
+// RegionNode is a region with the regions nested in it
+type RegionNode struct {
+	Region
+	Children []RegionNode `json:"children"`
+}
+
+// RegionStats rolls up the apiaries, hives, harvests and incidents of a region
+// and every region nested in it
+//
+// Depth is 0 for top-level regions. Harvests and incidents count towards the
+// apiary their hive stood at on the day
+type RegionStats struct {
+	RegionID       int      `json:"region_id" db:"region_id"`
+	Name           string   `json:"name" db:"name"`
+	ParentRegionID null.Int `json:"parent_region_id" db:"parent_region_id"`
+	Depth          int      `json:"depth" db:"depth"`
+	ApiaryCount    int      `json:"apiary_count" db:"apiary_count"`
+	HiveCount      int      `json:"hive_count" db:"hive_count"`
+	HarvestCount   int      `json:"harvest_count" db:"harvest_count"`
+	HoneyHarvested float64  `json:"honey_harvested" db:"honey_harvested"`
+	IncidentCount  int      `json:"incident_count" db:"incident_count"`
 }
 
 type RegionApiary struct {
 	ID       int    `json:"id,omitempty" db:"id"`
-	ApiaryID int    `json:"apiary_id" db:"apiary_id"`
-	RegionID int    `json:"region_id" db:"region_id"`
-	Region   Region `json:"region" db:"region"`
-	Apiary   Apiary `json:"apiary" db:"apiary"`
+	ApiaryID int    `json:"apiary_id" db:"apiary_id" validate:"gt=0"`
+	RegionID int    `json:"region_id" db:"region_id" validate:"gt=0"`
+	Region   Region `json:"region" db:"region" validate:"-"`
+	Apiary   Apiary `json:"apiary" db:"apiary" validate:"-"`
 }
 
 type AllowedRegion struct {
 	ID       int    `json:"id,omitempty" db:"id"`
-	UserID   int    `json:"user_id" db:"user_id"`
-	RegionID int    `json:"region_id" db:"region_id"`
-	Region   Region `json:"region" db:"region"`
-	User     User   `json:"user" db:"user"`
+	UserID   int    `json:"user_id" db:"user_id" validate:"gt=0"`
+	RegionID int    `json:"region_id" db:"region_id" validate:"gt=0"`
+	Region   Region `json:"region" db:"region" validate:"-"`
+	User     User   `json:"user" db:"user" validate:"-"`
 }
@@ -0,0 +1,198 @@
+package types
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// ContainerType is the kind of container honey is stocked in
+type ContainerType string
+
+const (
+	ContainerJar    ContainerType = "jar"
+	ContainerBucket ContainerType = "bucket"
+	ContainerDrum   ContainerType = "drum"
+	ContainerComb   ContainerType = "comb"
+	ContainerOther  ContainerType = "other"
+)
+
+// IsValid reports whether t is a known container type
+func (t ContainerType) IsValid() bool {
+	switch t {
+	case ContainerJar, ContainerBucket, ContainerDrum, ContainerComb, ContainerOther:
+		return true
+	}
+	return false
+}
+
+// StockReason is why a stock item's quantity changed
+type StockReason string
+
+const (
+	ReasonReceipt    StockReason = "receipt"
+	ReasonSale       StockReason = "sale"
+	ReasonAdjustment StockReason = "adjustment"
+)
+
+// OrderStatus is where a sales order is in its lifecycle
+type OrderStatus string
+
+const (
+	OrderReserved  OrderStatus = "reserved"
+	OrderFulfilled OrderStatus = "fulfilled"
+	OrderCancelled OrderStatus = "cancelled"
+)
+
+// IsValid reports whether s is a known order status
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case OrderReserved, OrderFulfilled, OrderCancelled:
+		return true
+	}
+	return false
+}
+
+// DefaultPaymentTermDays is how long after issue an invoice is due by default
+const DefaultPaymentTermDays = 30
+
+type Customer struct {
+	CustomerID int         `json:"customer_id" db:"customer_id"`
+	Name       string      `json:"name" db:"name" validate:"required,max=200"`
+	Email      null.String `json:"email" db:"email" validate:"omitempty,email,max=255"`
+	Phone      null.String `json:"phone" db:"phone" validate:"omitempty,max=50"`
+	Address    null.String `json:"address" db:"address" validate:"omitempty,max=1000"`
+	TaxID      null.String `json:"tax_id" db:"tax_id" validate:"omitempty,max=50"`
+	CreatedAt  time.Time   `json:"created_at" db:"created_at"`
+}
+
+// StockItem is honey of one lot in one kind of container at one location
+//
+// Jar stock comes from a jar lot; bulk stock from the unbottled part of a batch.
+// Available is what is on hand and not reserved by open sales orders.
+type StockItem struct {
+	StockID          int           `json:"stock_id" db:"stock_id"`
+	BatchID          int           `json:"batch_id" db:"batch_id"`
+	JarLotID         null.Int      `json:"jar_lot_id" db:"jar_lot_id"`
+	LotNumber        string        `json:"lot_number" db:"lot_number"`
+	ContainerType    ContainerType `json:"container_type" db:"container_type" validate:"required,enum"`
+	UnitWeightKg     float64       `json:"unit_weight_kg" db:"unit_weight_kg" validate:"gte=0"`
+	Location         string        `json:"location" db:"location" validate:"required,max=200"`
+	QuantityOnHand   int           `json:"quantity_on_hand" db:"quantity_on_hand"`
+	QuantityReserved int           `json:"quantity_reserved" db:"quantity_reserved"`
+	Available        int           `json:"available" db:"available"`
+	UnitPrice        null.Float    `json:"unit_price" db:"unit_price" validate:"omitempty,gte=0"`
+	CreatedAt        time.Time     `json:"created_at" db:"created_at"`
+}
+
+// StockReceipt puts honey from a batch or one of its jar lots into stock
+//
+// For jar lots the container is a jar of the lot's size, so UnitWeightKg is ignored
+type StockReceipt struct {
+	BatchID       int           `json:"batch_id" validate:"gt=0"`
+	JarLotID      null.Int      `json:"jar_lot_id"`
+	ContainerType ContainerType `json:"container_type" validate:"required,enum"`
+	UnitWeightKg  float64       `json:"unit_weight_kg" validate:"gte=0"`
+	Location      string        `json:"location" validate:"required,max=200"`
+	Quantity      int           `json:"quantity" validate:"gt=0"`
+	UnitPrice     null.Float    `json:"unit_price" validate:"omitempty,gte=0"`
+	Notes         null.String   `json:"notes" validate:"omitempty,max=2000"`
+}
+
+// StockAdjustment corrects the quantity on hand, e.g. after a stock count or breakage
+type StockAdjustment struct {
+	QuantityChange int    `json:"quantity_change" validate:"ne=0"`
+	Notes          string `json:"notes" validate:"required,max=2000"`
+}
+
+type StockMovement struct {
+	MovementID     int         `json:"movement_id" db:"movement_id"`
+	StockID        int         `json:"stock_id" db:"stock_id"`
+	QuantityChange int         `json:"quantity_change" db:"quantity_change"`
+	Reason         StockReason `json:"reason" db:"reason"`
+	OrderID        null.Int    `json:"order_id" db:"order_id"`
+	MovedBy        null.Int    `json:"moved_by" db:"moved_by"`
+	MovedAt        time.Time   `json:"moved_at" db:"moved_at"`
+	Notes          null.String `json:"notes" db:"notes"`
+}
+
+// OrderLine is a quantity of a stock item on a sales order
+//
+// UnitPrice defaults to the stock item's price when not given
+type OrderLine struct {
+	LineID         int        `json:"line_id" db:"line_id"`
+	OrderID        int        `json:"order_id" db:"order_id"`
+	StockID        int        `json:"stock_id" db:"stock_id" validate:"gt=0"`
+	Quantity       int        `json:"quantity" db:"quantity" validate:"gt=0"`
+	UnitPrice      null.Float `json:"unit_price" db:"unit_price" validate:"omitempty,gte=0"`
+	TaxRatePercent float64    `json:"tax_rate_percent" db:"tax_rate_percent" validate:"gte=0,lte=100"`
+}
+
+// SalesOrder reserves stock for a customer until it is fulfilled or cancelled
+type SalesOrder struct {
+	OrderID       int         `json:"order_id" db:"order_id"`
+	CustomerID    int         `json:"customer_id" db:"customer_id" validate:"gt=0"`
+	OrderDate     null.Time   `json:"order_date" db:"order_date" validate:"required,notfuture"`
+	Status        OrderStatus `json:"status" db:"status"`
+	FulfilledDate null.Time   `json:"fulfilled_date" db:"fulfilled_date"`
+	Notes         null.String `json:"notes" db:"notes" validate:"omitempty,max=2000"`
+	CreatedBy     null.Int    `json:"created_by" db:"created_by"`
+	CreatedAt     time.Time   `json:"created_at" db:"created_at"`
+	Lines         []OrderLine `json:"lines" db:"-" validate:"required,min=1,dive"`
+}
+
+// FulfilmentRequest ships a sales order and invoices it
+type FulfilmentRequest struct {
+	FulfilledDate null.Time `json:"fulfilled_date" validate:"required,notfuture"`
+	DueDays       null.Int  `json:"due_days" validate:"omitempty,gte=0,lte=365"`
+}
+
+type InvoiceLine struct {
+	InvoiceLineID  int     `json:"invoice_line_id" db:"invoice_line_id"`
+	InvoiceID      int     `json:"invoice_id" db:"invoice_id"`
+	StockID        int     `json:"stock_id" db:"stock_id"`
+	Description    string  `json:"description" db:"description"`
+	Quantity       int     `json:"quantity" db:"quantity"`
+	UnitPrice      float64 `json:"unit_price" db:"unit_price"`
+	TaxRatePercent float64 `json:"tax_rate_percent" db:"tax_rate_percent"`
+	NetAmount      float64 `json:"net_amount" db:"net_amount"`
+	TaxAmount      float64 `json:"tax_amount" db:"tax_amount"`
+}
+
+// TaxLine totals an invoice's lines sharing a tax rate
+type TaxLine struct {
+	TaxRatePercent float64 `json:"tax_rate_percent" db:"tax_rate_percent"`
+	NetAmount      float64 `json:"net_amount" db:"net_amount"`
+	TaxAmount      float64 `json:"tax_amount" db:"tax_amount"`
+}
+
+type Invoice struct {
+	InvoiceID     int           `json:"invoice_id" db:"invoice_id"`
+	OrderID       int           `json:"order_id" db:"order_id"`
+	InvoiceNumber string        `json:"invoice_number" db:"invoice_number"`
+	CustomerID    int           `json:"customer_id" db:"customer_id"`
+	IssueDate     null.Time     `json:"issue_date" db:"issue_date"`
+	DueDate       null.Time     `json:"due_date" db:"due_date"`
+	NetTotal      float64       `json:"net_total" db:"net_total"`
+	TaxTotal      float64       `json:"tax_total" db:"tax_total"`
+	Total         float64       `json:"total" db:"total"`
+	PaidDate      null.Time     `json:"paid_date" db:"paid_date"`
+	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+	Lines         []InvoiceLine `json:"lines,omitempty" db:"-"`
+	TaxLines      []TaxLine     `json:"tax_lines,omitempty" db:"-"`
+}
+
+// InvoicePayment records when an invoice was paid
+type InvoicePayment struct {
+	PaidDate null.Time `json:"paid_date" validate:"required,notfuture"`
+}
+
+// ApiaryProfit is an apiary's honey sales and pollination revenue against its expenses over a period
+type ApiaryProfit struct {
+	ApiaryID int     `json:"apiary_id" db:"apiary_id"`
+	Location string  `json:"location" db:"location"`
+	HoneyKg  float64 `json:"honey_kg" db:"honey_kg"`
+	Revenue  float64 `json:"revenue" db:"revenue"`
+	Expenses float64 `json:"expenses" db:"expenses"`
+	Profit   float64 `json:"profit" db:"profit"`
+}
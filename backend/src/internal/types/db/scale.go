@@ -0,0 +1,116 @@
+package types
+
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// WeightEventKind is what a change in a hive's weight points to
+type WeightEventKind string
+
+const (
+	WeightFlowStart WeightEventKind = "flow_start"
+	WeightFlowEnd   WeightEventKind = "flow_end"
+	WeightSwarm     WeightEventKind = "swarm"
+	WeightRobbing   WeightEventKind = "robbing"
+)
+
+// IsValid reports whether k is a known weight event kind
+func (k WeightEventKind) IsValid() bool {
+	switch k {
+	case WeightFlowStart, WeightFlowEnd, WeightSwarm, WeightRobbing:
+		return true
+	}
+	return false
+}
+
+const (
+	// FlowMinGainKg is the net daily gain of a hive on a nectar flow day
+	FlowMinGainKg = 0.5
+	// FlowMinDays consecutive flow days start a nectar flow and FlowEndDays
+	// consecutive days without one end it
+	FlowMinDays = 3
+	FlowEndDays = 2
+	// SwarmMinDropKg is the smallest sudden drop read as a swarm leaving; drops of
+	// ManipulationMinKg or more are supers or frames being taken off
+	SwarmMinDropKg    = 1.0
+	ManipulationMinKg = 4.0
+	// SwarmWindow is how far apart two readings may be for a drop between them to be sudden
+	SwarmWindow = time.Hour
+	// RobbingMinLossKg is the steady daily loss, without any sudden drop, read as robbing
+	RobbingMinLossKg = 1.0
+	// ScaleLookbackDays is how many past days each scale analysis goes over again,
+	// so that late readings are taken into account
+	ScaleLookbackDays = 30
+)
+
+// WeightReading is a hive weight sensor reading in kg
+type WeightReading struct {
+	HiveID     int       `db:"hive_id"`
+	MeasuredAt time.Time `db:"measured_at"`
+	WeightKg   float64   `db:"weight_kg"`
+}
+
+// HiveWeightDay summarises a hive's weight readings over a UTC day
+//
+// NetGainKg is the change since the previous day's last reading, or since the
+// day's first one without it, leaving out steps of ManipulationMinKg or more.
+// MaxDropKg is the largest sudden drop below that size.
+type HiveWeightDay struct {
+	HiveID    int       `json:"hive_id" db:"hive_id"`
+	Date      null.Time `json:"date" db:"date"`
+	OpeningKg float64   `json:"opening_kg" db:"opening_kg"`
+	ClosingKg float64   `json:"closing_kg" db:"closing_kg"`
+	MinKg     float64   `json:"min_kg" db:"min_kg"`
+	MaxKg     float64   `json:"max_kg" db:"max_kg"`
+	NetGainKg float64   `json:"net_gain_kg" db:"net_gain_kg"`
+	MaxDropKg float64   `json:"max_drop_kg" db:"max_drop_kg"`
+	MaxDropAt null.Time `json:"max_drop_at" db:"max_drop_at"`
+	Readings  int       `json:"readings" db:"readings"`
+}
+
+// HiveWeightEvent is an event read from a hive's weight
+//
+// ChangeKg is the drop for a swarm, the day's loss for robbing and the gain over
+// the flow so far for its start and end. Swarms and robbing are raised as
+// incidents on the hive.
+type HiveWeightEvent struct {
+	EventID    int             `json:"event_id" db:"event_id"`
+	HiveID     int             `json:"hive_id" db:"hive_id"`
+	ApiaryID   null.Int        `json:"apiary_id" db:"apiary_id"`
+	Kind       WeightEventKind `json:"kind" db:"kind"`
+	Date       null.Time       `json:"date" db:"date"`
+	OccurredAt null.Time       `json:"occurred_at" db:"occurred_at"`
+	ChangeKg   float64         `json:"change_kg" db:"change_kg"`
+	IncidentID null.Int        `json:"incident_id" db:"incident_id"`
+	DetectedAt time.Time       `json:"detected_at" db:"detected_at"`
+}
+
+// NectarFlow is a run of days a hive gained weight on
+//
+// EndDate is null while the flow may still be under way.
+type NectarFlow struct {
+	HiveID     int       `json:"hive_id"`
+	StartDate  null.Time `json:"start_date"`
+	EndDate    null.Time `json:"end_date"`
+	Days       int       `json:"days"`
+	GainKg     float64   `json:"gain_kg"`
+	PeakGainKg float64   `json:"peak_gain_kg"`
+}
+
+// ApiaryFlow is a run of days at least half the weighed hives of an apiary were
+// on a nectar flow
+//
+// GainKg is what the hives on the flow gained over it and MeanGainKg the mean
+// per hive and day. EndDate is null while the flow may still be under way.
+type ApiaryFlow struct {
+	ApiaryID     int       `json:"apiary_id"`
+	StartDate    null.Time `json:"start_date"`
+	EndDate      null.Time `json:"end_date"`
+	Days         int       `json:"days"`
+	WeighedHives int       `json:"weighed_hives"`
+	PeakHives    int       `json:"peak_hives"`
+	GainKg       float64   `json:"gain_kg"`
+	MeanGainKg   float64   `json:"mean_gain_kg"`
+}
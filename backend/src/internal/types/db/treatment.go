@@ -0,0 +1,48 @@
+package types
+
+import "github.com/guregu/null"
+
+// TreatmentProduct is a catalogue entry for a varroa or disease treatment
+//
+// WithdrawalDays is how long after a treatment ends honey must not be harvested
+type TreatmentProduct struct {
+	ProductID       int         `json:"product_id,omitempty" db:"product_id"`
+	Name            string      `json:"name" db:"name" validate:"required,max=100"`
+	ActiveSubstance string      `json:"active_substance" db:"active_substance" validate:"required,max=100"`
+	Dosage          string      `json:"dosage" db:"dosage" validate:"required,max=200"`
+	WithdrawalDays  int         `json:"withdrawal_days" db:"withdrawal_days" validate:"gte=0,lte=365"`
+	Target          null.String `json:"target" db:"target" validate:"omitempty,max=100"`
+	Notes           null.String `json:"notes" db:"notes" validate:"omitempty,max=2000"`
+	Active          bool        `json:"active" db:"active"`
+}
+
+// TreatmentApplication is a treatment applied to a hive
+//
+// MiteCountBefore and MiteCountAfter measure its efficacy; an application
+// without an end date is still ongoing. WithdrawalEnd and EfficacyPercent are
+// computed when reading.
+type TreatmentApplication struct {
+	ApplicationID   int         `json:"application_id,omitempty" db:"application_id"`
+	ProductID       int         `json:"product_id" db:"product_id" validate:"gt=0"`
+	HiveID          int         `json:"hive_id" db:"hive_id" validate:"gt=0"`
+	CommunityID     null.Int    `json:"community_id" db:"community_id" validate:"omitempty,gt=0"`
+	StartDate       null.Time   `json:"start_date" db:"start_date" validate:"required,notfuture"`
+	EndDate         null.Time   `json:"end_date" db:"end_date" validate:"omitempty,notfuture,gtefield=StartDate"`
+	DosageApplied   null.String `json:"dosage_applied" db:"dosage_applied" validate:"omitempty,max=200"`
+	MiteCountBefore null.Int    `json:"mite_count_before" db:"mite_count_before" validate:"omitempty,gte=0"`
+	MiteCountAfter  null.Int    `json:"mite_count_after" db:"mite_count_after" validate:"omitempty,gte=0"`
+	AppliedBy       null.Int    `json:"applied_by" db:"applied_by"`
+	Notes           null.String `json:"notes" db:"notes" validate:"omitempty,max=2000"`
+	CreatedAt       null.Time   `json:"created_at" db:"created_at"`
+	WithdrawalEnd   null.Time   `json:"withdrawal_end" db:"withdrawal_end"`
+	EfficacyPercent null.Float  `json:"efficacy_percent" db:"efficacy_percent"`
+}
+
+// TreatmentEfficacy summarizes how well a product reduced mite counts
+type TreatmentEfficacy struct {
+	ProductID       int        `json:"product_id" db:"product_id"`
+	Name            string     `json:"name" db:"name"`
+	Applications    int        `json:"applications" db:"applications"`
+	Measured        int        `json:"measured" db:"measured"`
+	AverageEfficacy null.Float `json:"average_efficacy" db:"average_efficacy"`
+}
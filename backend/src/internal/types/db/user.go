@@ -1,6 +1,10 @@
 package types
 
-import "github.com/guregu/null"
+import (
+	"time"
+
+	"github.com/guregu/null"
+)
 
 type Role string
 
@@ -10,21 +14,70 @@ const (
 	Manager Role = "MANAGER"
 )
 
+// IsValid reports whether r is a known role
+func (r Role) IsValid() bool {
+	switch r {
+	case Admin, Worker, Manager:
+		return true
+	}
+	return false
+}
+
 type User struct {
 	UserID    int       `json:"user_id,omitempty" db:"user_id"`
-	Username  string    `json:"username" db:"username"`
-	FullName  string    `json:"full_name" db:"full_name"`
-	Role      Role      `json:"role" db:"role"`
-	Email     string    `json:"email" db:"email"`
-	Password  string    `json:"password" db:"password"`
+	Username  string    `json:"username" db:"username" validate:"required,min=3,max=50"`
+	FullName  string    `json:"full_name" db:"full_name" validate:"required,max=255"`
+	Role      Role      `json:"role" db:"role" validate:"required,enum"`
+	Email     string    `json:"email" db:"email" validate:"required,email"`
+	Password  string    `json:"password" db:"password" validate:"required"`
 	LastLogin null.Time `json:"last_login" db:"last_login"`
 }
 
 type WorkerGroup struct {
 	GroupID   int       `db:"group_id" json:"group_id"`
-	ManagerID int       `db:"manager_id" json:"manager_id"`
+	ManagerID int       `db:"manager_id" json:"manager_id" validate:"gt=0"`
 	WorkerID  int       `db:"worker_id" json:"worker_id"`
-	GroupName string    `db:"group_name" json:"group_name"`
+	GroupName string    `db:"group_name" json:"group_name" validate:"required,max=100"`
 	CreatedAt null.Time `db:"created_at" json:"created_at"`
 	UpdatedAt null.Time `db:"updated_at" json:"updated_at"`
 }
+
+// LoginFailure tracks consecutive failed logins of an account
+type LoginFailure struct {
+	UserID         int       `db:"user_id" json:"user_id"`
+	FailedAttempts int       `db:"failed_attempts" json:"failed_attempts"`
+	LastFailedAt   time.Time `db:"last_failed_at" json:"last_failed_at"`
+	LockedUntil    null.Time `db:"locked_until" json:"locked_until"`
+}
+
+// PasswordResetToken is a single-use token, only its SHA-256 hash is stored
+type PasswordResetToken struct {
+	TokenID   int       `db:"token_id" json:"token_id"`
+	UserID    int       `db:"user_id" json:"user_id"`
+	TokenHash string    `db:"token_hash" json:"-"`
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+	UsedAt    null.Time `db:"used_at" json:"used_at"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Profile is the self-service view of a user, without credentials
+type Profile struct {
+	UserID       int         `json:"user_id" db:"user_id"`
+	Username     string      `json:"username" db:"username"`
+	FullName     string      `json:"full_name" db:"full_name"`
+	Role         Role        `json:"role" db:"role"`
+	Email        string      `json:"email" db:"email"`
+	LastLogin    null.Time   `json:"last_login" db:"last_login"`
+	PendingEmail null.String `json:"pending_email" db:"pending_email"`
+}
+
+// EmailChangeRequest holds a new email address until it is confirmed through a single-use token
+type EmailChangeRequest struct {
+	RequestID int       `db:"request_id" json:"request_id"`
+	UserID    int       `db:"user_id" json:"user_id"`
+	NewEmail  string    `db:"new_email" json:"new_email"`
+	TokenHash string    `db:"token_hash" json:"-"`
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+	UsedAt    null.Time `db:"used_at" json:"used_at"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
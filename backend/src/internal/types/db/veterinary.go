@@ -4,16 +4,22 @@ import "github.com/guregu/null"
 
 type VeterinaryPassport struct {
 	PassportID         int       `json:"passport_id,omitempty" db:"passport_id"`
-	BeeCommunityID     int       `json:"bee_community_id" db:"bee_community_id"`
-	IssueDate          null.Time `json:"issue_date" db:"issue_date"`
-	HealthStatus       string    `json:"health_status" db:"health_status"`
-	LastInspectionDate null.Time `json:"last_inspection_date" db:"last_inspection_date"`
+	BeeCommunityID     int       `json:"bee_community_id" db:"bee_community_id" validate:"gt=0"`
+	IssueDate          null.Time `json:"issue_date" db:"issue_date" validate:"omitempty,notfuture"`
+	HealthStatus       string    `json:"health_status" db:"health_status" validate:"max=100"`
+	LastInspectionDate null.Time `json:"last_inspection_date" db:"last_inspection_date" validate:"omitempty,notfuture"`
 }
 
+// VeterinaryRecord is an entry of a veterinary passport
+//
+// ApplicationID optionally links it to a structured treatment application and
+// DiseaseID to the diagnosed disease; recording a notifiable disease declares an outbreak
 type VeterinaryRecord struct {
-	RecordID    int       `json:"record_id,omitempty" db:"record_id"`
-	PassportID  int       `json:"passport_id" db:"passport_id"`
-	RecordDate  null.Time `json:"record_date" db:"record_date"`
-	Description string    `json:"description" db:"description"`
-	Treatment   string    `json:"treatment" db:"treatment"`
+	RecordID      int       `json:"record_id,omitempty" db:"record_id"`
+	PassportID    int       `json:"passport_id" db:"passport_id" validate:"gt=0"`
+	RecordDate    null.Time `json:"record_date" db:"record_date" validate:"required,notfuture"`
+	Description   string    `json:"description" db:"description" validate:"required"`
+	Treatment     string    `json:"treatment" db:"treatment"`
+	ApplicationID null.Int  `json:"application_id" db:"application_id"`
+	DiseaseID     null.Int  `json:"disease_id" db:"disease_id" validate:"omitempty,gt=0"`
 }
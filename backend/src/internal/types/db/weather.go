@@ -1,13 +1,184 @@
 package types
 
-import "github.com/guregu/null"
+import (
+	"time"
 
+	"github.com/guregu/null"
+)
+
+// WeatherSource is where a weather reading came from
+type WeatherSource string
+
+const (
+	WeatherSourceManual  WeatherSource = "manual"
+	WeatherSourceCSV     WeatherSource = "csv"
+	WeatherSourceMETAR   WeatherSource = "metar"
+	WeatherSourceSYNOP   WeatherSource = "synop"
+	WeatherSourceStation WeatherSource = "station"
+)
+
+// IsValid reports whether s is a known weather source
+func (s WeatherSource) IsValid() bool {
+	switch s {
+	case WeatherSourceManual, WeatherSourceCSV, WeatherSourceMETAR, WeatherSourceSYNOP, WeatherSourceStation:
+		return true
+	}
+	return false
+}
+
+// WeatherData is a weather reading for a region
+//
+// A reading without Hour covers the whole day; an hourly reading covers the hour
+// starting at Hour UTC. A region has at most one reading per date and hour, a
+// newer one replacing it. Wind speed is in m/s and precipitation in mm.
 type WeatherData struct {
-	WeatherID     int       `json:"weather_id,omitempty" db:"weather_id"`
-	RegionID      int       `json:"region_id" db:"region_id"`
-	Date          null.Time `json:"date" db:"date"`
-	Temperature   float32   `json:"temperature" db:"temperature"`
-	Humidity      float32   `json:"humidity" db:"humidity"`
-	WindSpeed     float32   `json:"wind_speed" db:"wind_speed"`
-	Precipitation float32   `json:"precipitation" db:"precipitation"`
+	WeatherID     int           `json:"weather_id,omitempty" db:"weather_id"`
+	RegionID      int           `json:"region_id" db:"region_id" validate:"gt=0"`
+	Date          null.Time     `json:"date" db:"date" validate:"required,notfuture"`
+	Hour          null.Int      `json:"hour" db:"hour" validate:"omitempty,gte=0,lte=23"`
+	Temperature   float32       `json:"temperature" db:"temperature" validate:"gte=-90,lte=60"`
+	Humidity      float32       `json:"humidity" db:"humidity" validate:"gte=0,lte=100"`
+	WindSpeed     float32       `json:"wind_speed" db:"wind_speed" validate:"gte=0"`
+	Precipitation float32       `json:"precipitation" db:"precipitation" validate:"gte=0"`
+	Source        WeatherSource `json:"source" db:"source" validate:"omitempty,enum"`
+}
+
+// DailyWeather is a region's weather for a day, taken from its daily reading or
+// aggregated from its hourly ones
+type DailyWeather struct {
+	Date           null.Time `json:"date" db:"date"`
+	Temperature    float64   `json:"temperature" db:"temperature"`
+	Humidity       float64   `json:"humidity" db:"humidity"`
+	WindSpeed      float64   `json:"wind_speed" db:"wind_speed"`
+	Precipitation  float64   `json:"precipitation" db:"precipitation"`
+	HourlyReadings int       `json:"hourly_readings" db:"hourly_readings"`
+}
+
+// WeatherForecast is the daily weather a provider expects for a region
+type WeatherForecast struct {
+	RegionID int            `json:"region_id"`
+	Provider string         `json:"provider"`
+	Days     []DailyWeather `json:"days"`
+}
+
+// WeatherStation is a station pushing readings for a region
+//
+// Code is also the identifier METAR and SYNOP reports use for the station
+// (ICAO location indicator or WMO index number). Token is only returned when
+// the station is created or its token is rotated.
+type WeatherStation struct {
+	StationID  int       `json:"station_id" db:"station_id"`
+	Code       string    `json:"code" db:"code" validate:"required,max=20"`
+	Name       string    `json:"name" db:"name" validate:"required,max=255"`
+	RegionID   int       `json:"region_id" db:"region_id" validate:"gt=0"`
+	TokenHash  string    `json:"-" db:"token_hash"`
+	Token      string    `json:"token,omitempty" db:"-"`
+	LastSeenAt null.Time `json:"last_seen_at" db:"last_seen_at"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// StationReading is a reading pushed by a weather station
+type StationReading struct {
+	ObservedAt    time.Time `json:"observed_at" validate:"required"`
+	Temperature   float32   `json:"temperature" validate:"gte=-90,lte=60"`
+	Humidity      float32   `json:"humidity" validate:"gte=0,lte=100"`
+	WindSpeed     float32   `json:"wind_speed" validate:"gte=0"`
+	Precipitation float32   `json:"precipitation" validate:"gte=0"`
+}
+
+// StationPush is a batch of readings pushed by a weather station
+type StationPush struct {
+	Readings []StationReading `json:"readings" validate:"required,min=1,max=1000,dive"`
+}
+
+// WeatherImportError is a line of a weather file that could not be imported
+type WeatherImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// WeatherImportResult summarises a weather file import
+type WeatherImportResult struct {
+	Imported int           `json:"imported"`
+	Readings []WeatherData `json:"readings"`
+}
+
+const (
+	// SeasonEndMonth and SeasonEndDay mark the end of the foraging season that
+	// begins at SeasonStartMonth and SeasonStartDay
+	SeasonEndMonth = time.October
+	SeasonEndDay   = 31
+	// DegreeDayBaseC is the mean daily temperature degree days accumulate above
+	DegreeDayBaseC = 10.0
+	// ForagingMinTempC, ForagingMaxPrecipitationMm and ForagingMaxWindMPS bound
+	// the daily weather bees forage well in
+	ForagingMinTempC           = 13.0
+	ForagingMaxPrecipitationMm = 1.0
+	ForagingMaxWindMPS         = 7.0
+)
+
+// AnalyticsInterval is the period an analytics time series is grouped by
+type AnalyticsInterval string
+
+const (
+	IntervalDay  AnalyticsInterval = "day"
+	IntervalWeek AnalyticsInterval = "week"
+)
+
+// IsValid reports whether i is a known interval
+func (i AnalyticsInterval) IsValid() bool {
+	switch i {
+	case IntervalDay, IntervalWeek:
+		return true
+	}
+	return false
+}
+
+// WeatherProductionDay is a region's weather and honey harvested on a day; the
+// weather is null on days without readings
+type WeatherProductionDay struct {
+	Date           null.Time  `db:"date"`
+	Temperature    null.Float `db:"temperature"`
+	WindSpeed      null.Float `db:"wind_speed"`
+	Precipitation  null.Float `db:"precipitation"`
+	HoneyHarvested float64    `db:"honey_harvested"`
+	HarvestCount   int        `db:"harvest_count"`
+}
+
+// WeatherProductionPoint is a period of a weather-production time series
+//
+// Temperature is the mean over the days with weather readings, null when there
+// are none; degree days and foraging days only count days with readings.
+type WeatherProductionPoint struct {
+	PeriodStart          null.Time  `json:"period_start"`
+	WeatherDays          int        `json:"weather_days"`
+	Temperature          null.Float `json:"temperature"`
+	Precipitation        float64    `json:"precipitation"`
+	DegreeDays           float64    `json:"degree_days"`
+	CumulativeDegreeDays float64    `json:"cumulative_degree_days"`
+	GoodForagingDays     int        `json:"good_foraging_days"`
+	HoneyHarvested       float64    `json:"honey_harvested"`
+	HarvestCount         int        `json:"harvest_count"`
+}
+
+// WeatherProduction relates a region's weather to its honey harvest over a season
+//
+// The correlations are Pearson coefficients between each period's weather and
+// the honey harvested in it, taken over the periods with weather readings; they
+// are null with fewer than three such periods or when either side never varies.
+type WeatherProduction struct {
+	RegionID                 int                      `json:"region_id"`
+	Season                   int                      `json:"season"`
+	Start                    null.Time                `json:"start"`
+	End                      null.Time                `json:"end"`
+	Interval                 AnalyticsInterval        `json:"interval"`
+	WeatherDays              int                      `json:"weather_days"`
+	DegreeDays               float64                  `json:"degree_days"`
+	GoodForagingDays         int                      `json:"good_foraging_days"`
+	HoneyHarvested           float64                  `json:"honey_harvested"`
+	HarvestCount             int                      `json:"harvest_count"`
+	TemperatureCorrelation   null.Float               `json:"temperature_yield_correlation"`
+	PrecipitationCorrelation null.Float               `json:"precipitation_yield_correlation"`
+	ForagingDaysCorrelation  null.Float               `json:"foraging_days_yield_correlation"`
+	Series                   []WeatherProductionPoint `json:"series"`
 }
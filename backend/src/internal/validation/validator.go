@@ -0,0 +1,241 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/guregu/null"
+
+	"github.com/orientallines/beesbiz/internal/recurrence"
+)
+
+// Enum is implemented by string enumerations that can check their own value
+type Enum interface {
+	IsValid() bool
+}
+
+// FieldError describes a single failed validation rule
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Errors is a list of field errors returned by Struct and Var
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report fields by their JSON name so errors match the request payload
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	// Validate null types by their underlying value, treating invalid ones as empty
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		switch value := field.Interface().(type) {
+		case null.Time:
+			if value.Valid {
+				return value.Time
+			}
+		case null.String:
+			if value.Valid {
+				return value.String
+			}
+		case null.Int:
+			if value.Valid {
+				return value.Int64
+			}
+		case null.Float:
+			if value.Valid {
+				return value.Float64
+			}
+		}
+		return nil
+	}, null.Time{}, null.String{}, null.Int{}, null.Float{})
+
+	v.RegisterValidation("enum", func(fl validator.FieldLevel) bool {
+		enum, ok := fl.Field().Interface().(Enum)
+		return ok && enum.IsValid()
+	})
+
+	v.RegisterValidation("notfuture", func(fl validator.FieldLevel) bool {
+		date, ok := fl.Field().Interface().(time.Time)
+		if !ok {
+			return false
+		}
+		now := time.Now()
+		endOfToday := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+		return date.Before(endOfToday)
+	})
+
+	v.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+		return StrongPassword(fl.Field().String())
+	})
+
+	v.RegisterValidation("rrule", func(fl validator.FieldLevel) bool {
+		_, err := recurrence.Parse(fl.Field().String())
+		return err == nil
+	})
+
+	return v
+}
+
+// commonPasswords are rejected even when they satisfy the character class rule
+var commonPasswords = map[string]bool{
+	"password1!":  true,
+	"password123": true,
+	"passw0rd!":   true,
+	"qwerty123!":  true,
+	"welcome1!":   true,
+	"letmein123":  true,
+	"admin12345":  true,
+	"iloveyou1!":  true,
+}
+
+// StrongPassword reports whether password mixes at least three of lowercase
+// letters, uppercase letters, digits and symbols and is not a well-known one
+func StrongPassword(password string) bool {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	return classes >= 3 && !commonPasswords[strings.ToLower(password)]
+}
+
+// Struct validates a struct according to its `validate` tags
+func Struct(s interface{}) error {
+	return translate(validate.Struct(s))
+}
+
+// Var validates a single value against the given tag, reporting it under name
+func Var(name string, value interface{}, tag string) error {
+	err := translate(validate.Var(value, tag))
+	var errs Errors
+	if errors.As(err, &errs) {
+		for i := range errs {
+			errs[i].Field = name
+			errs[i].Message = name + errs[i].Message
+		}
+		return errs
+	}
+	return err
+}
+
+func translate(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err
+	}
+
+	errs := make(Errors, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		errs = append(errs, FieldError{
+			Field:   fieldErr.Field(),
+			Rule:    fieldErr.Tag(),
+			Param:   fieldErr.Param(),
+			Message: fieldErr.Field() + describe(fieldErr),
+		})
+	}
+	return errs
+}
+
+func describe(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return " is required"
+	case "email":
+		return " must be a valid email address"
+	case "enum":
+		return fmt.Sprintf(" has unsupported value %q", fmt.Sprint(fieldErr.Value()))
+	case "oneof":
+		return " must be one of: " + fieldErr.Param()
+	case "password":
+		return " must mix at least three of lowercase letters, uppercase letters, digits and symbols and must not be a common password"
+	case "rrule":
+		return " must be a supported recurrence rule, e.g. FREQ=WEEKLY;BYDAY=MO"
+	case "notfuture":
+		return " must not be in the future"
+	case "datetime":
+		return " must be a date formatted as " + fieldErr.Param()
+	case "min":
+		if fieldErr.Kind() == reflect.String {
+			return " must be at least " + fieldErr.Param() + " characters long"
+		}
+		return " must be at least " + fieldErr.Param()
+	case "max":
+		if fieldErr.Kind() == reflect.String {
+			return " must be at most " + fieldErr.Param() + " characters long"
+		}
+		return " must be at most " + fieldErr.Param()
+	case "gt":
+		return " must be greater than " + fieldErr.Param()
+	case "gte":
+		return " must be greater than or equal to " + fieldErr.Param()
+	case "lte":
+		return " must be less than or equal to " + fieldErr.Param()
+	case "gtefield":
+		return " must not be before " + snakeCase(fieldErr.Param())
+	default:
+		return " failed the " + fieldErr.Tag() + " rule"
+	}
+}
+
+// snakeCase turns a Go field name such as StartDate into its JSON name start_date
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,115 @@
+package weather
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/guregu/null"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// csvColumns are the columns a weather CSV must have; the header row names them in
+// any order, along with region_id or station and an optional hour. Rows without
+// an hour are daily readings.
+var csvColumns = []string{"date", "temperature", "humidity", "wind_speed", "precipitation"}
+
+// ParseCSV reads readings from a CSV export with a header row, returning every row error
+func ParseCSV(r io.Reader) ([]Reading, []types.WeatherImportError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	_, hasRegion := index["region_id"]
+	_, hasStation := index["station"]
+	for _, column := range csvColumns {
+		if _, ok := index[column]; !ok || !hasRegion && !hasStation {
+			return nil, nil, fmt.Errorf("header must name %s and region_id or station", strings.Join(csvColumns, ", "))
+		}
+	}
+
+	var readings []Reading
+	var lineErrs []types.WeatherImportError
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			lineErrs = append(lineErrs, types.WeatherImportError{Line: line, Error: err.Error()})
+			continue
+		}
+		get := func(column string) string {
+			if i, ok := index[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		reading, err := parseCSVRow(get)
+		if err != nil {
+			lineErrs = append(lineErrs, types.WeatherImportError{Line: line, Error: err.Error()})
+			continue
+		}
+		reading.Line = line
+		readings = append(readings, reading)
+	}
+	return readings, lineErrs, nil
+}
+
+// parseCSVRow reads one CSV record into a reading
+func parseCSVRow(get func(string) string) (Reading, error) {
+	reading := Reading{Station: get("station")}
+	reading.Source = types.WeatherSourceCSV
+	var errs []error
+	parseFloat := func(column string) float32 {
+		value := get(column)
+		number, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 32)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid number %q", column, value))
+		}
+		return float32(number)
+	}
+
+	if value := get("region_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("region_id: invalid ID %q", value))
+		}
+		reading.RegionID = id
+	}
+	if reading.RegionID == 0 && reading.Station == "" {
+		errs = append(errs, errors.New("region_id or station is required"))
+	}
+	date, err := time.Parse("2006-01-02", get("date"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("date: invalid date %q, expected YYYY-MM-DD", get("date")))
+	}
+	reading.Date = null.NewTime(date, err == nil)
+	if value := get("hour"); value != "" {
+		hour, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("hour: invalid hour %q", value))
+		}
+		reading.Hour = null.NewInt(int64(hour), err == nil)
+	}
+	reading.Temperature = parseFloat("temperature")
+	reading.Humidity = parseFloat("humidity")
+	reading.WindSpeed = parseFloat("wind_speed")
+	reading.Precipitation = parseFloat("precipitation")
+
+	if err := errors.Join(errs...); err != nil {
+		return Reading{}, err
+	}
+	return reading, nil
+}
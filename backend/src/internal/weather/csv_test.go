@@ -0,0 +1,140 @@
+package weather
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// csvReading builds the reading expected for a CSV row
+func csvReading(regionID int, station string, date time.Time, hour null.Int, temperature, humidity, windSpeed, precipitation float64) Reading {
+	reading := Reading{Station: station}
+	reading.Source = types.WeatherSourceCSV
+	reading.RegionID = regionID
+	reading.Date = null.TimeFrom(date)
+	reading.Hour = hour
+	reading.Temperature = float32(temperature)
+	reading.Humidity = float32(humidity)
+	reading.WindSpeed = float32(windSpeed)
+	reading.Precipitation = float32(precipitation)
+	return reading
+}
+
+func TestParseCSV(t *testing.T) {
+	june15 := time.Date(2024, time.June, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		input string
+		want  []Reading
+	}{
+		{
+			name:  "daily readings by region",
+			input: "region_id,date,temperature,humidity,wind_speed,precipitation\n3,2024-06-15,18.5,60,3.2,0\n",
+			want:  []Reading{csvReading(3, "", june15, null.Int{}, 18.5, 60, 3.2, 0)},
+		},
+		{
+			name:  "hourly readings by station",
+			input: "station,date,hour,temperature,humidity,wind_speed,precipitation\nEDDF,2024-06-15,10,-2.5,90,1,0.4\n",
+			want:  []Reading{csvReading(0, "EDDF", june15, null.IntFrom(10), -2.5, 90, 1, 0.4)},
+		},
+		{
+			name:  "decimal commas",
+			input: "station,date,hour,temperature,humidity,wind_speed,precipitation\nEDDF,2024-06-15,10,\"18,5\",60,\"3,2\",\"0,4\"\n",
+			want:  []Reading{csvReading(0, "EDDF", june15, null.IntFrom(10), 18.5, 60, 3.2, 0.4)},
+		},
+		{
+			name:  "byte order mark, column order, case and spacing",
+			input: "\ufeffPrecipitation, Wind_Speed, Humidity, Temperature, Date, Region_ID\n0, 3, 60, 18, 2024-06-15, 3\n",
+			want:  []Reading{csvReading(3, "", june15, null.Int{}, 18, 60, 3, 0)},
+		},
+		{
+			name:  "empty hour is a daily reading",
+			input: "region_id,date,hour,temperature,humidity,wind_speed,precipitation\n3,2024-06-15,,18,60,3,0\n",
+			want:  []Reading{csvReading(3, "", june15, null.Int{}, 18, 60, 3, 0)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readings, lineErrs, err := ParseCSV(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ParseCSV returned error: %v", err)
+			}
+			if len(lineErrs) > 0 {
+				t.Fatalf("ParseCSV returned row errors: %+v", lineErrs)
+			}
+			if len(readings) != len(tt.want) {
+				t.Fatalf("got %d readings, want %d", len(readings), len(tt.want))
+			}
+			for i := range readings {
+				if readings[i].Line != i+2 {
+					t.Errorf("reading %d on line %d, want %d", i, readings[i].Line, i+2)
+				}
+				checkReading(t, readings[i], tt.want[i])
+			}
+		})
+	}
+}
+
+func TestParseCSVHeaderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"missing column", "region_id,date,temperature,humidity,wind_speed\n"},
+		{"missing region and station", "date,temperature,humidity,wind_speed,precipitation\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ParseCSV(strings.NewReader(tt.input)); err == nil {
+				t.Errorf("ParseCSV(%q) returned no error", tt.input)
+			}
+		})
+	}
+}
+
+func TestParseCSVRowErrors(t *testing.T) {
+	input := "region_id,station,date,hour,temperature,humidity,wind_speed,precipitation\n" +
+		"3,,2024-06-15,10,18,60,3,0\n" +
+		"x,,2024-06-15,10,18,60,3,0\n" +
+		",,2024-06-15,10,18,60,3,0\n" +
+		"3,,15.06.2024,10,18,60,3,0\n" +
+		"3,,2024-06-15,ten,warm,60,3,0\n" +
+		"3,,2024-06-15,10,18,60,3\n"
+
+	readings, lineErrs, err := ParseCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseCSV returned error: %v", err)
+	}
+	if len(readings) != 1 || readings[0].Line != 2 {
+		t.Errorf("readings = %+v, want only line 2", readings)
+	}
+
+	want := []struct {
+		line     int
+		contains []string
+	}{
+		{3, []string{"region_id: invalid ID"}},
+		{4, []string{"region_id or station is required"}},
+		{5, []string{"date: invalid date"}},
+		{6, []string{"hour: invalid hour", "temperature: invalid number"}},
+		{7, []string{"wrong number of fields"}},
+	}
+	if len(lineErrs) != len(want) {
+		t.Fatalf("got %d row errors, want %d: %+v", len(lineErrs), len(want), lineErrs)
+	}
+	for i, w := range want {
+		if lineErrs[i].Line != w.line {
+			t.Errorf("error %d on line %d, want %d", i, lineErrs[i].Line, w.line)
+		}
+		for _, part := range w.contains {
+			if !strings.Contains(lineErrs[i].Error, part) {
+				t.Errorf("line %d error %q does not mention %q", w.line, lineErrs[i].Error, part)
+			}
+		}
+	}
+}
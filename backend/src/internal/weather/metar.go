@@ -0,0 +1,124 @@
+package weather
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+var (
+	metarStation = regexp.MustCompile(`^[A-Z][A-Z0-9]{3}$`)
+	metarTime    = regexp.MustCompile(`^(\d{2})(\d{2})(\d{2})Z$`)
+	metarWind    = regexp.MustCompile(`^(\d{3}|VRB)(\d{2,3})(G\d{2,3})?(KT|MPS|KMH)$`)
+	metarTemp    = regexp.MustCompile(`^(M?\d{2})/(M?\d{2})$`)
+	// metarPrecip is the US remark for the precipitation of the past hour, in hundredths of an inch
+	metarPrecip = regexp.MustCompile(`^P(\d{4})$`)
+)
+
+// ParseMETAR reads METAR or SPECI reports, one per line, as hourly readings
+//
+// Report days are resolved against now. Humidity is derived from the dew point;
+// precipitation is only reported in the US hourly remark and is 0 otherwise.
+func ParseMETAR(r io.Reader, now time.Time) ([]Reading, []types.WeatherImportError) {
+	var readings []Reading
+	var lineErrs []types.WeatherImportError
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		report := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(scanner.Text()), "="))
+		if report == "" {
+			continue
+		}
+		reading, err := parseMETAR(report, now)
+		if err != nil {
+			lineErrs = append(lineErrs, types.WeatherImportError{Line: line, Error: err.Error()})
+			continue
+		}
+		reading.Line = line
+		readings = append(readings, reading)
+	}
+	if err := scanner.Err(); err != nil {
+		lineErrs = append(lineErrs, types.WeatherImportError{Error: err.Error()})
+	}
+	return readings, lineErrs
+}
+
+// parseMETAR reads a single METAR report
+func parseMETAR(report string, now time.Time) (Reading, error) {
+	groups := strings.Fields(report)
+	if len(groups) > 0 && (groups[0] == "METAR" || groups[0] == "SPECI") {
+		groups = groups[1:]
+	}
+	if len(groups) < 2 || !metarStation.MatchString(groups[0]) {
+		return Reading{}, errors.New("report must start with a station identifier")
+	}
+	reading := Reading{Station: groups[0]}
+	reading.Source = types.WeatherSourceMETAR
+
+	match := metarTime.FindStringSubmatch(groups[1])
+	if match == nil {
+		return Reading{}, fmt.Errorf("invalid report time %q", groups[1])
+	}
+	day, _ := strconv.Atoi(match[1])
+	hour, _ := strconv.Atoi(match[2])
+	minute, _ := strconv.Atoi(match[3])
+	observedAt, err := reportTime(day, hour, minute, now)
+	if err != nil {
+		return Reading{}, err
+	}
+	hourly(&reading.WeatherData, observedAt)
+
+	var hasWind, hasTemp, remarks bool
+	for _, group := range groups[2:] {
+		switch {
+		case group == "NIL":
+			return Reading{}, errors.New("missing report")
+		case group == "RMK":
+			remarks = true
+		case remarks:
+			if match := metarPrecip.FindStringSubmatch(group); match != nil {
+				hundredths, _ := strconv.Atoi(match[1])
+				reading.Precipitation = float32(float64(hundredths) * 0.254)
+			}
+		case !hasWind && metarWind.MatchString(group):
+			match := metarWind.FindStringSubmatch(group)
+			speed, _ := strconv.ParseFloat(match[2], 64)
+			switch match[4] {
+			case "KT":
+				speed *= knotsToMPS
+			case "KMH":
+				speed /= 3.6
+			}
+			reading.WindSpeed = float32(speed)
+			hasWind = true
+		case !hasTemp && metarTemp.MatchString(group):
+			match := metarTemp.FindStringSubmatch(group)
+			temperature := metarDegrees(match[1])
+			reading.Temperature = float32(temperature)
+			reading.Humidity = relativeHumidity(temperature, metarDegrees(match[2]))
+			hasTemp = true
+		}
+	}
+	if !hasWind {
+		return Reading{}, errors.New("missing wind group")
+	}
+	if !hasTemp {
+		return Reading{}, errors.New("missing temperature and dew point group")
+	}
+	return reading, nil
+}
+
+// metarDegrees reads a METAR temperature, M marking it negative
+func metarDegrees(value string) float64 {
+	degrees, _ := strconv.Atoi(strings.TrimPrefix(value, "M"))
+	if strings.HasPrefix(value, "M") {
+		degrees = -degrees
+	}
+	return float64(degrees)
+}
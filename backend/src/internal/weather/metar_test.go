@@ -0,0 +1,112 @@
+package weather
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+func TestParseMETARReport(t *testing.T) {
+	now := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		report string
+		now    time.Time
+		want   Reading
+	}{
+		{
+			name:   "knots",
+			report: "METAR EDDF 151050Z 24010KT 9999 FEW030 18/09 Q1015",
+			now:    now,
+			want: hourlyReading("EDDF", types.WeatherSourceMETAR, time.Date(2024, time.June, 15, 10, 50, 0, 0, time.UTC),
+				18, float64(relativeHumidity(18, 9)), 10*knotsToMPS, 0),
+		},
+		{
+			name:   "metres per second with gusts",
+			report: "UUEE 151100Z 18005G10MPS CAVOK 22/12 Q1012",
+			now:    now,
+			want: hourlyReading("UUEE", types.WeatherSourceMETAR, time.Date(2024, time.June, 15, 11, 0, 0, 0, time.UTC),
+				22, float64(relativeHumidity(22, 12)), 5, 0),
+		},
+		{
+			name:   "variable wind in km/h below zero",
+			report: "SPECI UUWW 141200Z VRB036KMH 2000 SN M05/M10 Q1020",
+			now:    now,
+			want: hourlyReading("UUWW", types.WeatherSourceMETAR, time.Date(2024, time.June, 14, 12, 0, 0, 0, time.UTC),
+				-5, float64(relativeHumidity(-5, -10)), 10, 0),
+		},
+		{
+			name:   "US hourly precipitation remark",
+			report: "KJFK 151051Z 18012KT 10SM BKN040 22/M02 A2992 RMK AO2 SLP132 P0012 T02221017",
+			now:    now,
+			want: hourlyReading("KJFK", types.WeatherSourceMETAR, time.Date(2024, time.June, 15, 10, 51, 0, 0, time.UTC),
+				22, float64(relativeHumidity(22, -2)), 12*knotsToMPS, 3.048),
+		},
+		{
+			name:   "temperature-like remark is ignored",
+			report: "KJFK 151051Z 00000KT 10SM 20/10 A2992 RMK 18/09",
+			now:    now,
+			want: hourlyReading("KJFK", types.WeatherSourceMETAR, time.Date(2024, time.June, 15, 10, 51, 0, 0, time.UTC),
+				20, float64(relativeHumidity(20, 10)), 0, 0),
+		},
+		{
+			name:   "report from the last day of the previous month",
+			report: "EDDF 302350Z 00000KT 12/08",
+			now:    time.Date(2024, time.July, 1, 0, 10, 0, 0, time.UTC),
+			want: hourlyReading("EDDF", types.WeatherSourceMETAR, time.Date(2024, time.June, 30, 23, 50, 0, 0, time.UTC),
+				12, float64(relativeHumidity(12, 8)), 0, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMETAR(tt.report, tt.now)
+			if err != nil {
+				t.Fatalf("parseMETAR(%q) returned error: %v", tt.report, err)
+			}
+			checkReading(t, got, tt.want)
+		})
+	}
+}
+
+func TestParseMETARReportErrors(t *testing.T) {
+	now := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		report string
+		want   string
+	}{
+		{"no station", "METAR", "station identifier"},
+		{"lowercase station", "eddf 151050Z 24010KT 18/09", "station identifier"},
+		{"time without Z", "EDDF 151050 24010KT 18/09", "invalid report time"},
+		{"invalid hour", "EDDF 152450Z 24010KT 18/09", "invalid report time"},
+		{"missing report", "EDDF 151050Z NIL", "missing report"},
+		{"missing wind", "EDDF 151050Z 9999 18/09", "missing wind"},
+		{"missing temperature", "EDDF 151050Z 24010KT 9999", "missing temperature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseMETAR(tt.report, now)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseMETAR(%q) error = %v, want one mentioning %q", tt.report, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMETAR(t *testing.T) {
+	now := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+	input := "EDDF 151050Z 24010KT 18/09 Q1015=\n\n  EDDF 151120Z 24012KT NIL\nEDDF 151150Z 24012KT 19/09 Q1015=\n"
+
+	readings, lineErrs := ParseMETAR(strings.NewReader(input), now)
+	if len(readings) != 2 || readings[0].Line != 1 || readings[1].Line != 4 {
+		t.Fatalf("readings = %+v, want lines 1 and 4", readings)
+	}
+	if readings[1].Hour.Int64 != 11 {
+		t.Errorf("second reading hour = %v, want 11", readings[1].Hour)
+	}
+	if len(lineErrs) != 1 || lineErrs[0].Line != 3 {
+		t.Errorf("errors = %+v, want one on line 3", lineErrs)
+	}
+}
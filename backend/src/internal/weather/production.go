@@ -0,0 +1,147 @@
+package weather
+
+import (
+	"math"
+	"time"
+
+	"github.com/guregu/null"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// minCorrelationPoints is how many periods a correlation needs to be reported
+const minCorrelationPoints = 3
+
+// CurrentSeason returns the latest season that has begun by now
+func CurrentSeason(now time.Time) int {
+	year := now.Year()
+	if now.Before(time.Date(year, types.SeasonStartMonth, types.SeasonStartDay, 0, 0, 0, 0, now.Location())) {
+		year--
+	}
+	return year
+}
+
+// SeasonBounds returns the first and last day of a season, the last day being
+// today while the season is under way
+func SeasonBounds(season int, today time.Time) (time.Time, time.Time) {
+	start := time.Date(season, types.SeasonStartMonth, types.SeasonStartDay, 0, 0, 0, 0, time.UTC)
+	end := time.Date(season, types.SeasonEndMonth, types.SeasonEndDay, 0, 0, 0, 0, time.UTC)
+	if today.Before(end) {
+		end = today
+	}
+	return start, end
+}
+
+// DegreeDays returns the degree days a day with mean temperature accumulates
+func DegreeDays(temperature float64) float64 {
+	return math.Max(temperature-types.DegreeDayBaseC, 0)
+}
+
+// GoodForaging reports whether bees forage well in a day's weather
+func GoodForaging(temperature, precipitation, windSpeed float64) bool {
+	return temperature >= types.ForagingMinTempC &&
+		precipitation <= types.ForagingMaxPrecipitationMm &&
+		windSpeed <= types.ForagingMaxWindMPS
+}
+
+// Production groups a region's days, in date order, into a weather-production
+// series by interval and correlates each period's weather with its harvest
+//
+// Weeks are counted from the first day. The region, season and bounds are left
+// to the caller.
+func Production(days []types.WeatherProductionDay, interval types.AnalyticsInterval) types.WeatherProduction {
+	production := types.WeatherProduction{Interval: interval, Series: []types.WeatherProductionPoint{}}
+	periodDays := 1
+	if interval == types.IntervalWeek {
+		periodDays = 7
+	}
+
+	var temperatureSum float64
+	for i, day := range days {
+		if i%periodDays == 0 {
+			if i > 0 {
+				closePeriod(&production, temperatureSum)
+			}
+			production.Series = append(production.Series, types.WeatherProductionPoint{
+				PeriodStart:          day.Date,
+				CumulativeDegreeDays: production.DegreeDays,
+			})
+			temperatureSum = 0
+		}
+		point := &production.Series[len(production.Series)-1]
+		point.HoneyHarvested += day.HoneyHarvested
+		point.HarvestCount += day.HarvestCount
+		production.HoneyHarvested += day.HoneyHarvested
+		production.HarvestCount += day.HarvestCount
+		if !day.Temperature.Valid {
+			continue
+		}
+
+		degreeDays := DegreeDays(day.Temperature.Float64)
+		point.WeatherDays++
+		point.Precipitation += day.Precipitation.Float64
+		point.DegreeDays += degreeDays
+		point.CumulativeDegreeDays += degreeDays
+		temperatureSum += day.Temperature.Float64
+		production.WeatherDays++
+		production.DegreeDays += degreeDays
+		if GoodForaging(day.Temperature.Float64, day.Precipitation.Float64, day.WindSpeed.Float64) {
+			point.GoodForagingDays++
+			production.GoodForagingDays++
+		}
+	}
+	if len(production.Series) > 0 {
+		closePeriod(&production, temperatureSum)
+	}
+
+	var temperatures, precipitations, foragingDays, yields []float64
+	for _, point := range production.Series {
+		if point.WeatherDays == 0 {
+			continue
+		}
+		temperatures = append(temperatures, point.Temperature.Float64)
+		precipitations = append(precipitations, point.Precipitation)
+		foragingDays = append(foragingDays, float64(point.GoodForagingDays))
+		yields = append(yields, point.HoneyHarvested)
+	}
+	production.TemperatureCorrelation = Correlation(temperatures, yields)
+	production.PrecipitationCorrelation = Correlation(precipitations, yields)
+	production.ForagingDaysCorrelation = Correlation(foragingDays, yields)
+	return production
+}
+
+// closePeriod sets the mean temperature of the last period of the series
+func closePeriod(production *types.WeatherProduction, temperatureSum float64) {
+	point := &production.Series[len(production.Series)-1]
+	if point.WeatherDays > 0 {
+		point.Temperature = null.FloatFrom(temperatureSum / float64(point.WeatherDays))
+	}
+}
+
+// Correlation returns the Pearson correlation coefficient of x and y, null with
+// fewer than three pairs or when either never varies
+func Correlation(x, y []float64) null.Float {
+	n := len(x)
+	if n != len(y) || n < minCorrelationPoints {
+		return null.Float{}
+	}
+	var meanX, meanY float64
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+
+	var covariance, varianceX, varianceY float64
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		covariance += dx * dy
+		varianceX += dx * dx
+		varianceY += dy * dy
+	}
+	if varianceX == 0 || varianceY == 0 {
+		return null.Float{}
+	}
+	return null.FloatFrom(covariance / math.Sqrt(varianceX*varianceY))
+}
@@ -0,0 +1,83 @@
+package weather
+
+import (
+	"context"
+	"time"
+
+	"github.com/guregu/null"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// MaxForecastDays bounds how far ahead a forecast may be asked for
+const MaxForecastDays = 14
+
+// Provider forecasts the weather of a region
+//
+// An external forecast API is added by implementing Provider and passing it to
+// the REST server in place of the local one.
+type Provider interface {
+	// Name identifies the provider in forecasts
+	Name() string
+	// Forecast returns the daily weather expected over the days after today,
+	// at most days of them
+	Forecast(ctx context.Context, region types.Region, days int) ([]types.DailyWeather, error)
+}
+
+// History gives the daily weather recorded for a region
+type History interface {
+	GetDailyWeather(regionID int, from, to null.Time) ([]types.DailyWeather, error)
+}
+
+// localWindowDays is how many recorded days the local provider averages
+const localWindowDays = 7
+
+// LocalProvider is a stand-in for a forecast API: it expects every coming day
+// to look like the average of the region's last week on record
+type LocalProvider struct {
+	history History
+}
+
+// NewLocalProvider creates a local provider forecasting from history
+func NewLocalProvider(history History) *LocalProvider {
+	return &LocalProvider{history: history}
+}
+
+func (p *LocalProvider) Name() string {
+	return "local"
+}
+
+// Forecast repeats the average of the region's last week on record for each
+// coming day; it forecasts nothing for a region without recent records
+func (p *LocalProvider) Forecast(ctx context.Context, region types.Region, days int) ([]types.DailyWeather, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	recorded, err := p.history.GetDailyWeather(region.RegionID,
+		null.TimeFrom(today.AddDate(0, 0, -localWindowDays)), null.TimeFrom(today))
+	if err != nil {
+		return nil, err
+	}
+	forecast := []types.DailyWeather{}
+	if len(recorded) == 0 {
+		return forecast, nil
+	}
+
+	var average types.DailyWeather
+	for _, day := range recorded {
+		average.Temperature += day.Temperature
+		average.Humidity += day.Humidity
+		average.WindSpeed += day.WindSpeed
+		average.Precipitation += day.Precipitation
+	}
+	n := float64(len(recorded))
+	average.Temperature /= n
+	average.Humidity /= n
+	average.WindSpeed /= n
+	average.Precipitation /= n
+
+	for i := 1; i <= days; i++ {
+		day := average
+		day.Date = null.TimeFrom(today.AddDate(0, 0, i))
+		forecast = append(forecast, day)
+	}
+	return forecast, nil
+}
@@ -0,0 +1,60 @@
+// Package weather parses weather reports and files into readings and defines the
+// providers weather forecasts come from
+package weather
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/guregu/null"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// knotsToMPS converts wind speeds in knots to m/s
+const knotsToMPS = 0.514444
+
+// Reading is a weather reading parsed from a report or a file
+//
+// Reports name the station that made them rather than a region; Station is
+// resolved to the station's region on import. CSV rows may name the region directly.
+type Reading struct {
+	Line    int
+	Station string
+	types.WeatherData
+}
+
+// hourly fills the date and hour of a reading made at t
+func hourly(reading *types.WeatherData, t time.Time) {
+	t = t.UTC()
+	reading.Date = null.TimeFrom(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+	reading.Hour = null.IntFrom(int64(t.Hour()))
+}
+
+// reportTime resolves the day of month and time of a report to the latest such
+// moment not after now, allowing for clock skew of an hour
+func reportTime(day, hour, minute int, now time.Time) (time.Time, error) {
+	if day < 1 || day > 31 || hour > 23 || minute > 59 {
+		return time.Time{}, fmt.Errorf("invalid report time %02d%02d%02d", day, hour, minute)
+	}
+	now = now.UTC()
+	for back := 0; back < 3; back++ {
+		month := time.Date(now.Year(), now.Month()-time.Month(back), 1, 0, 0, 0, 0, time.UTC)
+		t := time.Date(month.Year(), month.Month(), day, hour, minute, 0, 0, time.UTC)
+		if t.Day() != day {
+			continue
+		}
+		if !t.After(now.Add(time.Hour)) {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid report day %d", day)
+}
+
+// relativeHumidity derives relative humidity in percent from the temperature and
+// dew point in °C, using the Magnus formula
+func relativeHumidity(temperature, dewPoint float64) float32 {
+	magnus := func(t float64) float64 { return math.Exp(17.625 * t / (243.04 + t)) }
+	return float32(math.Min(100, math.Round(1000*magnus(dewPoint)/magnus(temperature))/10))
+}
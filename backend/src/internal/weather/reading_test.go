@@ -0,0 +1,151 @@
+package weather
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+// hourlyReading builds the reading expected for a report made at t
+func hourlyReading(station string, source types.WeatherSource, t time.Time, temperature, humidity, windSpeed, precipitation float64) Reading {
+	reading := Reading{Station: station}
+	reading.Source = source
+	hourly(&reading.WeatherData, t)
+	reading.Temperature = float32(temperature)
+	reading.Humidity = float32(humidity)
+	reading.WindSpeed = float32(windSpeed)
+	reading.Precipitation = float32(precipitation)
+	return reading
+}
+
+// checkReading compares readings, allowing for rounding in the measured values
+func checkReading(t *testing.T, got, want Reading) {
+	t.Helper()
+	if got.Station != want.Station || got.RegionID != want.RegionID || got.Source != want.Source {
+		t.Errorf("reading of station %q, region %d, source %q; want station %q, region %d, source %q",
+			got.Station, got.RegionID, got.Source, want.Station, want.RegionID, want.Source)
+	}
+	if got.Date != want.Date || got.Hour != want.Hour {
+		t.Errorf("reading on %v hour %v, want %v hour %v", got.Date, got.Hour, want.Date, want.Hour)
+	}
+	values := []struct {
+		name      string
+		got, want float32
+	}{
+		{"temperature", got.Temperature, want.Temperature},
+		{"humidity", got.Humidity, want.Humidity},
+		{"wind speed", got.WindSpeed, want.WindSpeed},
+		{"precipitation", got.Precipitation, want.Precipitation},
+	}
+	for _, value := range values {
+		if math.Abs(float64(value.got-value.want)) > 1e-3 {
+			t.Errorf("%s = %v, want %v", value.name, value.got, value.want)
+		}
+	}
+}
+
+func TestReportTime(t *testing.T) {
+	tests := []struct {
+		name              string
+		day, hour, minute int
+		now               time.Time
+		want              time.Time
+		wantErr           bool
+	}{
+		{
+			name: "same day",
+			day:  15, hour: 10, minute: 50,
+			now:  time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC),
+			want: time.Date(2024, time.June, 15, 10, 50, 0, 0, time.UTC),
+		},
+		{
+			name: "within the hour of clock skew",
+			day:  1, hour: 1, minute: 20,
+			now:  time.Date(2024, time.March, 1, 0, 30, 0, 0, time.UTC),
+			want: time.Date(2024, time.March, 1, 1, 20, 0, 0, time.UTC),
+		},
+		{
+			name: "later today is last month",
+			day:  1, hour: 2, minute: 0,
+			now:  time.Date(2024, time.March, 1, 0, 30, 0, 0, time.UTC),
+			want: time.Date(2024, time.February, 1, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "end of the previous month",
+			day:  29, hour: 23, minute: 0,
+			now:  time.Date(2024, time.March, 1, 0, 30, 0, 0, time.UTC),
+			want: time.Date(2024, time.February, 29, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "skips a month without the day",
+			day:  31, hour: 12, minute: 0,
+			now:  time.Date(2024, time.March, 1, 0, 30, 0, 0, time.UTC),
+			want: time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "previous year",
+			day:  31, hour: 18, minute: 0,
+			now:  time.Date(2024, time.January, 2, 10, 0, 0, 0, time.UTC),
+			want: time.Date(2023, time.December, 31, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "now in another time zone",
+			day:  30, hour: 23, minute: 0,
+			now:  time.Date(2024, time.July, 1, 2, 30, 0, 0, time.FixedZone("UTC+3", 3*60*60)),
+			want: time.Date(2024, time.June, 30, 23, 0, 0, 0, time.UTC),
+		},
+		{name: "day 0", day: 0, hour: 10, now: time.Now(), wantErr: true},
+		{name: "day 32", day: 32, hour: 10, now: time.Now(), wantErr: true},
+		{name: "hour 24", day: 1, hour: 24, now: time.Now(), wantErr: true},
+		{name: "minute 60", day: 1, hour: 0, minute: 60, now: time.Now(), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := reportTime(tt.day, tt.hour, tt.minute, tt.now)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("reportTime = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("reportTime returned error: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("reportTime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelativeHumidity(t *testing.T) {
+	tests := []struct {
+		temperature, dewPoint float64
+		want                  float32
+	}{
+		{20, 20, 100},
+		{25, 30, 100},
+		{20, 10, 52.6},
+		{-5, -10, 68.0},
+	}
+	for _, tt := range tests {
+		if got := relativeHumidity(tt.temperature, tt.dewPoint); math.Abs(float64(got-tt.want)) > 0.5 {
+			t.Errorf("relativeHumidity(%v, %v) = %v, want about %v", tt.temperature, tt.dewPoint, got, tt.want)
+		}
+	}
+}
+
+func TestHourly(t *testing.T) {
+	var reading types.WeatherData
+	hourly(&reading, time.Date(2024, time.June, 15, 1, 30, 0, 0, time.FixedZone("UTC+3", 3*60*60)))
+	if want := null.TimeFrom(time.Date(2024, time.June, 14, 0, 0, 0, 0, time.UTC)); reading.Date != want {
+		t.Errorf("date = %v, want %v", reading.Date, want)
+	}
+	if want := null.IntFrom(22); reading.Hour != want {
+		t.Errorf("hour = %v, want %v", reading.Hour, want)
+	}
+}
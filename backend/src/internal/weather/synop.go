@@ -0,0 +1,188 @@
+package weather
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+var (
+	synopHeader  = regexp.MustCompile(`^\d{4}[0-9/]$`)
+	synopGroup   = regexp.MustCompile(`^[0-9/]{5}$`)
+	synopStation = regexp.MustCompile(`^\d{5}$`)
+)
+
+// ParseSYNOP reads land station SYNOP (FM 12) reports as hourly readings
+//
+// Reports end with "=". An AAXX section header sets the day, hour and wind unit
+// of the reports that follow it, so whole bulletins can be imported. Only
+// sections 1 and 3 are read; Line is the report's position in the text.
+func ParseSYNOP(r io.Reader, now time.Time) ([]Reading, []types.WeatherImportError, error) {
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var readings []Reading
+	var reportErrs []types.WeatherImportError
+	header := ""
+	for i, report := range strings.Split(string(text), "=") {
+		groups := strings.Fields(report)
+		if len(groups) == 0 {
+			continue
+		}
+		if groups[0] == "AAXX" {
+			if len(groups) < 2 {
+				reportErrs = append(reportErrs, types.WeatherImportError{Line: i + 1, Error: "AAXX without day and hour"})
+				continue
+			}
+			header, groups = groups[1], groups[2:]
+		}
+		if len(groups) == 0 {
+			continue
+		}
+		reading, err := parseSYNOP(header, groups, now)
+		if err != nil {
+			reportErrs = append(reportErrs, types.WeatherImportError{Line: i + 1, Error: err.Error()})
+			continue
+		}
+		reading.Line = i + 1
+		readings = append(readings, reading)
+	}
+	return readings, reportErrs, nil
+}
+
+// parseSYNOP reads a single report given the YYGGi header of its section
+func parseSYNOP(header string, groups []string, now time.Time) (Reading, error) {
+	if header == "" {
+		return Reading{}, errors.New("report without AAXX header")
+	}
+	if !synopHeader.MatchString(header) {
+		return Reading{}, fmt.Errorf("invalid AAXX day and hour %q", header)
+	}
+	day, _ := strconv.Atoi(header[:2])
+	hour, _ := strconv.Atoi(header[2:4])
+	// iw 3 and 4 give wind speeds in knots, 0 and 1 in m/s
+	windInKnots := header[4] == '3' || header[4] == '4'
+	observedAt, err := reportTime(day, hour, 0, now)
+	if err != nil {
+		return Reading{}, err
+	}
+
+	if len(groups) < 3 || !synopStation.MatchString(groups[0]) {
+		return Reading{}, errors.New("report must start with a WMO station index number")
+	}
+	if groups[1] == "NIL" {
+		return Reading{}, errors.New("missing report")
+	}
+	for _, group := range groups {
+		if group != "333" && group != "555" && !synopGroup.MatchString(group) {
+			return Reading{}, fmt.Errorf("invalid group %q", group)
+		}
+	}
+	reading := Reading{Station: groups[0]}
+	reading.Source = types.WeatherSourceSYNOP
+	hourly(&reading.WeatherData, observedAt)
+
+	// Nddff: wind direction and speed, speeds over 99 in a following 00fff group
+	wind := groups[2]
+	rest := groups[3:]
+	speed, err := strconv.Atoi(wind[3:5])
+	if err != nil {
+		return Reading{}, fmt.Errorf("missing wind speed in %q", wind)
+	}
+	if speed == 99 && len(rest) > 0 && strings.HasPrefix(rest[0], "00") {
+		if speed, err = strconv.Atoi(rest[0][2:]); err != nil {
+			return Reading{}, fmt.Errorf("invalid wind speed group %q", rest[0])
+		}
+		rest = rest[1:]
+	}
+	if windInKnots {
+		reading.WindSpeed = float32(float64(speed) * knotsToMPS)
+	} else {
+		reading.WindSpeed = float32(speed)
+	}
+
+	var temperature, dewPoint *float64
+	var humidity, precipitation *float64
+	section := 1
+	for _, group := range rest {
+		switch {
+		case group == "333":
+			section = 3
+		case group == "555":
+			section = 5
+		case section == 1 && group[0] == '1' && temperature == nil:
+			temperature = synopTenths(group)
+		case section == 1 && group[0] == '2' && group[1] == '9':
+			humidity = synopNumber(group[2:])
+		case section == 1 && group[0] == '2' && dewPoint == nil:
+			dewPoint = synopTenths(group)
+		case (section == 1 || section == 3) && group[0] == '6' && precipitation == nil:
+			precipitation = synopPrecipitation(group)
+		}
+	}
+
+	if temperature == nil {
+		return Reading{}, errors.New("missing air temperature group")
+	}
+	reading.Temperature = float32(*temperature)
+	switch {
+	case humidity != nil:
+		reading.Humidity = float32(*humidity)
+	case dewPoint != nil:
+		reading.Humidity = relativeHumidity(*temperature, *dewPoint)
+	default:
+		return Reading{}, errors.New("missing dew point or humidity group")
+	}
+	if precipitation != nil {
+		reading.Precipitation = float32(*precipitation)
+	}
+	return reading, nil
+}
+
+// synopNumber reads a group's digits, nil when not observed
+func synopNumber(digits string) *float64 {
+	value, err := strconv.Atoi(digits)
+	if err != nil {
+		return nil
+	}
+	number := float64(value)
+	return &number
+}
+
+// synopTenths reads an snTTT group: a sign digit and tenths of a degree
+func synopTenths(group string) *float64 {
+	value := synopNumber(group[2:])
+	if value == nil || (group[1] != '0' && group[1] != '1') {
+		return nil
+	}
+	degrees := *value / 10
+	if group[1] == '1' {
+		degrees = -degrees
+	}
+	return &degrees
+}
+
+// synopPrecipitation reads a 6RRRt group in mm: 990 is a trace and 991-999 are
+// tenths of a millimetre
+func synopPrecipitation(group string) *float64 {
+	value := synopNumber(group[1:4])
+	if value == nil {
+		return nil
+	}
+	mm := *value
+	switch {
+	case mm == 990:
+		mm = 0
+	case mm > 990:
+		mm = (mm - 990) / 10
+	}
+	return &mm
+}
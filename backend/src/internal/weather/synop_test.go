@@ -0,0 +1,154 @@
+package weather
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	types "github.com/orientallines/beesbiz/internal/types/db"
+)
+
+func TestParseSYNOPReport(t *testing.T) {
+	now := time.Date(2024, time.June, 15, 14, 0, 0, 0, time.UTC)
+	observed := time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header string
+		report string
+		now    time.Time
+		want   Reading
+	}{
+		{
+			name:   "metres per second with dew point",
+			header: "15121",
+			report: "10637 12970 52110 10215 20119 40102 60121",
+			now:    now,
+			want:   hourlyReading("10637", types.WeatherSourceSYNOP, observed, 21.5, float64(relativeHumidity(21.5, 11.9)), 10, 12),
+		},
+		{
+			name:   "knots over 99 in a 00fff group",
+			header: "15124",
+			report: "10637 12970 52199 00120 11052 29085",
+			now:    now,
+			want:   hourlyReading("10637", types.WeatherSourceSYNOP, observed, -5.2, 85, 120*knotsToMPS, 0),
+		},
+		{
+			name:   "99 m/s without a 00fff group",
+			header: "15121",
+			report: "10637 12970 52199 10215 29050",
+			now:    now,
+			want:   hourlyReading("10637", types.WeatherSourceSYNOP, observed, 21.5, 50, 99, 0),
+		},
+		{
+			name:   "trace of precipitation",
+			header: "15121",
+			report: "10637 12970 52110 10215 29050 69901",
+			now:    now,
+			want:   hourlyReading("10637", types.WeatherSourceSYNOP, observed, 21.5, 50, 10, 0),
+		},
+		{
+			name:   "tenths of a millimetre",
+			header: "15121",
+			report: "10637 12970 52110 10215 29050 69921",
+			now:    now,
+			want:   hourlyReading("10637", types.WeatherSourceSYNOP, observed, 21.5, 50, 10, 0.2),
+		},
+		{
+			name:   "precipitation in section 3",
+			header: "15121",
+			report: "10637 12970 52110 10215 29050 333 60051 555 60099",
+			now:    now,
+			want:   hourlyReading("10637", types.WeatherSourceSYNOP, observed, 21.5, 50, 10, 5),
+		},
+		{
+			name:   "unobserved dew point falls back to humidity",
+			header: "15121",
+			report: "10637 12970 52110 10215 2//// 29070",
+			now:    now,
+			want:   hourlyReading("10637", types.WeatherSourceSYNOP, observed, 21.5, 70, 10, 0),
+		},
+		{
+			name:   "report from the last day of the previous month",
+			header: "30231",
+			report: "10637 12970 52110 10215 29050",
+			now:    time.Date(2024, time.July, 1, 0, 30, 0, 0, time.UTC),
+			want:   hourlyReading("10637", types.WeatherSourceSYNOP, time.Date(2024, time.June, 30, 23, 0, 0, 0, time.UTC), 21.5, 50, 10, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSYNOP(tt.header, strings.Fields(tt.report), tt.now)
+			if err != nil {
+				t.Fatalf("parseSYNOP(%q, %q) returned error: %v", tt.header, tt.report, err)
+			}
+			checkReading(t, got, tt.want)
+		})
+	}
+}
+
+func TestParseSYNOPReportErrors(t *testing.T) {
+	now := time.Date(2024, time.June, 15, 14, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header string
+		report string
+		want   string
+	}{
+		{"no header", "", "10637 12970 52110 10215 20119", "without AAXX header"},
+		{"invalid header", "1512X", "10637 12970 52110 10215 20119", "invalid AAXX"},
+		{"invalid hour", "15251", "10637 12970 52110 10215 20119", "invalid report time"},
+		{"invalid station", "15121", "EDDF 12970 52110 10215 20119", "WMO station"},
+		{"missing report", "15121", "10637 NIL 52110", "missing report"},
+		{"invalid group", "15121", "10637 12970 52110 1021 20119", "invalid group"},
+		{"unobserved wind speed", "15121", "10637 12970 521// 10215 20119", "missing wind speed"},
+		{"invalid 00fff group", "15121", "10637 12970 52199 00/// 10215 20119", "invalid wind speed group"},
+		{"missing temperature", "15121", "10637 12970 52110 20119", "missing air temperature"},
+		{"missing humidity", "15121", "10637 12970 52110 10215", "missing dew point or humidity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSYNOP(tt.header, strings.Fields(tt.report), now)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseSYNOP(%q, %q) error = %v, want one mentioning %q", tt.header, tt.report, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSYNOP(t *testing.T) {
+	now := time.Date(2024, time.June, 15, 14, 0, 0, 0, time.UTC)
+	bulletin := `AAXX 15121
+10637 12970 52110 10215 20119=
+10638 12970 52110 1//// 20119=
+10639 12970 52110 10180 29060=
+AAXX 15094
+10637 12970 52110 10150 29080=
+`
+	readings, reportErrs, err := ParseSYNOP(strings.NewReader(bulletin), now)
+	if err != nil {
+		t.Fatalf("ParseSYNOP returned error: %v", err)
+	}
+	if len(readings) != 3 {
+		t.Fatalf("got %d readings, want 3: %+v", len(readings), readings)
+	}
+	want := []struct {
+		line    int
+		station string
+		hour    int64
+		wind    float32
+	}{
+		{1, "10637", 12, 10},
+		{3, "10639", 12, 10},
+		{4, "10637", 9, float32(10 * knotsToMPS)},
+	}
+	for i, w := range want {
+		got := readings[i]
+		if got.Line != w.line || got.Station != w.station || got.Hour.Int64 != w.hour || got.WindSpeed != w.wind {
+			t.Errorf("reading %d = line %d, station %s, hour %d, wind %v; want line %d, station %s, hour %d, wind %v",
+				i, got.Line, got.Station, got.Hour.Int64, got.WindSpeed, w.line, w.station, w.hour, w.wind)
+		}
+	}
+	if len(reportErrs) != 1 || reportErrs[0].Line != 2 {
+		t.Errorf("errors = %+v, want one for report 2", reportErrs)
+	}
+}
@@ -888,6 +888,239 @@ func (x *SetRegionAccessRequest) GetRegionId() int32 {
 	return 0
 }
 
+// 12. RecordInspection
+type RecordInspectionRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	HiveId         int32               `protobuf:"varint,1,opt,name=hive_id,json=hiveId,proto3" json:"hive_id,omitempty"`
+	TemplateId     int32               `protobuf:"varint,2,opt,name=template_id,json=templateId,proto3" json:"template_id,omitempty"`
+	InspectedBy    int32               `protobuf:"varint,3,opt,name=inspected_by,json=inspectedBy,proto3" json:"inspected_by,omitempty"`
+	InspectionDate string              `protobuf:"bytes,4,opt,name=inspection_date,json=inspectionDate,proto3" json:"inspection_date,omitempty"` // Format: YYYY-MM-DD
+	Notes          string              `protobuf:"bytes,5,opt,name=notes,proto3" json:"notes,omitempty"`
+	Answers        []*InspectionAnswer `protobuf:"bytes,6,rep,name=answers,proto3" json:"answers,omitempty"`
+}
+
+func (x *RecordInspectionRequest) Reset() {
+	*x = RecordInspectionRequest{}
+	mi := &file_bee_management_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordInspectionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordInspectionRequest) ProtoMessage() {}
+
+func (x *RecordInspectionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bee_management_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordInspectionRequest.ProtoReflect.Descriptor instead.
+func (*RecordInspectionRequest) Descriptor() ([]byte, []int) {
+	return file_bee_management_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *RecordInspectionRequest) GetHiveId() int32 {
+	if x != nil {
+		return x.HiveId
+	}
+	return 0
+}
+
+func (x *RecordInspectionRequest) GetTemplateId() int32 {
+	if x != nil {
+		return x.TemplateId
+	}
+	return 0
+}
+
+func (x *RecordInspectionRequest) GetInspectedBy() int32 {
+	if x != nil {
+		return x.InspectedBy
+	}
+	return 0
+}
+
+func (x *RecordInspectionRequest) GetInspectionDate() string {
+	if x != nil {
+		return x.InspectionDate
+	}
+	return ""
+}
+
+func (x *RecordInspectionRequest) GetNotes() string {
+	if x != nil {
+		return x.Notes
+	}
+	return ""
+}
+
+func (x *RecordInspectionRequest) GetAnswers() []*InspectionAnswer {
+	if x != nil {
+		return x.Answers
+	}
+	return nil
+}
+
+// Answer to one template question, typed by the question's answer type
+// (choice answers are sent as text_value)
+type InspectionAnswer struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// Types that are assignable to Value:
+	//	*InspectionAnswer_BoolValue
+	//	*InspectionAnswer_NumberValue
+	//	*InspectionAnswer_TextValue
+	Value isInspectionAnswer_Value `protobuf_oneof:"value"`
+}
+
+func (x *InspectionAnswer) Reset() {
+	*x = InspectionAnswer{}
+	mi := &file_bee_management_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InspectionAnswer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InspectionAnswer) ProtoMessage() {}
+
+func (x *InspectionAnswer) ProtoReflect() protoreflect.Message {
+	mi := &file_bee_management_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InspectionAnswer.ProtoReflect.Descriptor instead.
+func (*InspectionAnswer) Descriptor() ([]byte, []int) {
+	return file_bee_management_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *InspectionAnswer) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (m *InspectionAnswer) GetValue() isInspectionAnswer_Value {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (x *InspectionAnswer) GetBoolValue() bool {
+	if x, ok := x.GetValue().(*InspectionAnswer_BoolValue); ok {
+		return x.BoolValue
+	}
+	return false
+}
+
+func (x *InspectionAnswer) GetNumberValue() float64 {
+	if x, ok := x.GetValue().(*InspectionAnswer_NumberValue); ok {
+		return x.NumberValue
+	}
+	return 0
+}
+
+func (x *InspectionAnswer) GetTextValue() string {
+	if x, ok := x.GetValue().(*InspectionAnswer_TextValue); ok {
+		return x.TextValue
+	}
+	return ""
+}
+
+type isInspectionAnswer_Value interface {
+	isInspectionAnswer_Value()
+}
+
+type InspectionAnswer_BoolValue struct {
+	BoolValue bool `protobuf:"varint,2,opt,name=bool_value,json=boolValue,proto3,oneof"`
+}
+
+type InspectionAnswer_NumberValue struct {
+	NumberValue float64 `protobuf:"fixed64,3,opt,name=number_value,json=numberValue,proto3,oneof"`
+}
+
+type InspectionAnswer_TextValue struct {
+	TextValue string `protobuf:"bytes,4,opt,name=text_value,json=textValue,proto3,oneof"`
+}
+
+func (*InspectionAnswer_BoolValue) isInspectionAnswer_Value() {}
+
+func (*InspectionAnswer_NumberValue) isInspectionAnswer_Value() {}
+
+func (*InspectionAnswer_TextValue) isInspectionAnswer_Value() {}
+
+type RecordInspectionResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	InspectionId int32 `protobuf:"varint,1,opt,name=inspection_id,json=inspectionId,proto3" json:"inspection_id,omitempty"`
+}
+
+func (x *RecordInspectionResponse) Reset() {
+	*x = RecordInspectionResponse{}
+	mi := &file_bee_management_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordInspectionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordInspectionResponse) ProtoMessage() {}
+
+func (x *RecordInspectionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bee_management_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordInspectionResponse.ProtoReflect.Descriptor instead.
+func (*RecordInspectionResponse) Descriptor() ([]byte, []int) {
+	return file_bee_management_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *RecordInspectionResponse) GetInspectionId() int32 {
+	if x != nil {
+		return x.InspectionId
+	}
+	return 0
+}
+
 var File_bee_management_proto protoreflect.FileDescriptor
 
 var file_bee_management_proto_rawDesc = []byte{
@@ -987,82 +1220,118 @@ var file_bee_management_proto_rawDesc = []byte{
 	0x12, 0x17, 0x0a, 0x07, 0x75, 0x73, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
 	0x05, 0x52, 0x06, 0x75, 0x73, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x72, 0x65, 0x67,
 	0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x72, 0x65,
-	0x67, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x32, 0xf9, 0x08, 0x0a, 0x14, 0x42, 0x65, 0x65, 0x4d, 0x61,
-	0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12,
-	0x79, 0x0a, 0x16, 0x47, 0x65, 0x74, 0x54, 0x6f, 0x74, 0x61, 0x6c, 0x48, 0x6f, 0x6e, 0x65, 0x79,
-	0x48, 0x61, 0x72, 0x76, 0x65, 0x73, 0x74, 0x65, 0x64, 0x12, 0x2d, 0x2e, 0x62, 0x65, 0x65, 0x5f,
-	0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x54, 0x6f,
-	0x74, 0x61, 0x6c, 0x48, 0x6f, 0x6e, 0x65, 0x79, 0x48, 0x61, 0x72, 0x76, 0x65, 0x73, 0x74, 0x65,
-	0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2e, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d,
-	0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x54, 0x6f, 0x74,
-	0x61, 0x6c, 0x48, 0x6f, 0x6e, 0x65, 0x79, 0x48, 0x61, 0x72, 0x76, 0x65, 0x73, 0x74, 0x65, 0x64,
-	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x51, 0x0a, 0x0e, 0x41, 0x64,
-	0x64, 0x4f, 0x62, 0x73, 0x65, 0x72, 0x76, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x25, 0x2e, 0x62,
-	0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x41, 0x64,
-	0x64, 0x4f, 0x62, 0x73, 0x65, 0x72, 0x76, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75,
-	0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x7f, 0x0a,
-	0x18, 0x47, 0x65, 0x74, 0x43, 0x6f, 0x6d, 0x6d, 0x75, 0x6e, 0x69, 0x74, 0x79, 0x48, 0x65, 0x61,
-	0x6c, 0x74, 0x68, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x2f, 0x2e, 0x62, 0x65, 0x65, 0x5f,
+	0x67, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x22, 0xf1, 0x01, 0x0a, 0x17, 0x52, 0x65, 0x63, 0x6f, 0x72,
+	0x64, 0x49, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x68, 0x69, 0x76, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x06, 0x68, 0x69, 0x76, 0x65, 0x49, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x74,
+	0x65, 0x6d, 0x70, 0x6c, 0x61, 0x74, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x0a, 0x74, 0x65, 0x6d, 0x70, 0x6c, 0x61, 0x74, 0x65, 0x49, 0x64, 0x12, 0x21, 0x0a, 0x0c,
+	0x69, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x5f, 0x62, 0x79, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x0b, 0x69, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x42, 0x79, 0x12,
+	0x27, 0x0a, 0x0f, 0x69, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x64, 0x61,
+	0x74, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x69, 0x6e, 0x73, 0x70, 0x65, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x44, 0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x6e, 0x6f, 0x74, 0x65,
+	0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6e, 0x6f, 0x74, 0x65, 0x73, 0x12, 0x3a,
+	0x0a, 0x07, 0x61, 0x6e, 0x73, 0x77, 0x65, 0x72, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x20, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74,
+	0x2e, 0x49, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x41, 0x6e, 0x73, 0x77, 0x65,
+	0x72, 0x52, 0x07, 0x61, 0x6e, 0x73, 0x77, 0x65, 0x72, 0x73, 0x22, 0x94, 0x01, 0x0a, 0x10, 0x49,
+	0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x41, 0x6e, 0x73, 0x77, 0x65, 0x72, 0x12,
+	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65,
+	0x79, 0x12, 0x1f, 0x0a, 0x0a, 0x62, 0x6f, 0x6f, 0x6c, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x08, 0x48, 0x00, 0x52, 0x09, 0x62, 0x6f, 0x6f, 0x6c, 0x56, 0x61, 0x6c,
+	0x75, 0x65, 0x12, 0x23, 0x0a, 0x0c, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x5f, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52, 0x0b, 0x6e, 0x75, 0x6d, 0x62,
+	0x65, 0x72, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1f, 0x0a, 0x0a, 0x74, 0x65, 0x78, 0x74, 0x5f,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x09, 0x74,
+	0x65, 0x78, 0x74, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x07, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x22, 0x3f, 0x0a, 0x18, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x49, 0x6e, 0x73, 0x70, 0x65,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x23, 0x0a,
+	0x0d, 0x69, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x0c, 0x69, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x49, 0x64, 0x32, 0xe5, 0x09, 0x0a, 0x14, 0x42, 0x65, 0x65, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65,
+	0x6d, 0x65, 0x6e, 0x74, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x79, 0x0a, 0x16, 0x47,
+	0x65, 0x74, 0x54, 0x6f, 0x74, 0x61, 0x6c, 0x48, 0x6f, 0x6e, 0x65, 0x79, 0x48, 0x61, 0x72, 0x76,
+	0x65, 0x73, 0x74, 0x65, 0x64, 0x12, 0x2d, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61,
+	0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x54, 0x6f, 0x74, 0x61, 0x6c, 0x48,
+	0x6f, 0x6e, 0x65, 0x79, 0x48, 0x61, 0x72, 0x76, 0x65, 0x73, 0x74, 0x65, 0x64, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x2e, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67,
+	0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x54, 0x6f, 0x74, 0x61, 0x6c, 0x48, 0x6f,
+	0x6e, 0x65, 0x79, 0x48, 0x61, 0x72, 0x76, 0x65, 0x73, 0x74, 0x65, 0x64, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x54, 0x0a, 0x0e, 0x41, 0x64, 0x64, 0x4f, 0x62, 0x73,
+	0x65, 0x72, 0x76, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x25, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d,
+	0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x41, 0x64, 0x64, 0x4f, 0x62, 0x73,
+	0x65, 0x72, 0x76, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x03, 0x88, 0x02, 0x01, 0x12, 0x7f, 0x0a, 0x18,
+	0x47, 0x65, 0x74, 0x43, 0x6f, 0x6d, 0x6d, 0x75, 0x6e, 0x69, 0x74, 0x79, 0x48, 0x65, 0x61, 0x6c,
+	0x74, 0x68, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x2f, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d,
+	0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x43, 0x6f, 0x6d,
+	0x6d, 0x75, 0x6e, 0x69, 0x74, 0x79, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x53, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x30, 0x2e, 0x62, 0x65, 0x65, 0x5f,
 	0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x43, 0x6f,
 	0x6d, 0x6d, 0x75, 0x6e, 0x69, 0x74, 0x79, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x53, 0x74, 0x61,
-	0x74, 0x75, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x30, 0x2e, 0x62, 0x65, 0x65,
-	0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x43,
-	0x6f, 0x6d, 0x6d, 0x75, 0x6e, 0x69, 0x74, 0x79, 0x48, 0x65, 0x61, 0x6c, 0x74, 0x68, 0x53, 0x74,
-	0x61, 0x74, 0x75, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x55,
-	0x0a, 0x10, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x48, 0x69, 0x76, 0x65, 0x53, 0x74, 0x61, 0x74,
-	0x75, 0x73, 0x12, 0x27, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d,
-	0x65, 0x6e, 0x74, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x48, 0x69, 0x76, 0x65, 0x53, 0x74,
-	0x61, 0x74, 0x75, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f,
-	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d,
-	0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x6a, 0x0a, 0x11, 0x47, 0x65, 0x74, 0x41, 0x76, 0x67, 0x54,
-	0x65, 0x6d, 0x70, 0x65, 0x72, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12, 0x28, 0x2e, 0x62, 0x65, 0x65,
-	0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x41,
-	0x76, 0x67, 0x54, 0x65, 0x6d, 0x70, 0x65, 0x72, 0x61, 0x74, 0x75, 0x72, 0x65, 0x52, 0x65, 0x71,
-	0x75, 0x65, 0x73, 0x74, 0x1a, 0x29, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67,
-	0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x41, 0x76, 0x67, 0x54, 0x65, 0x6d, 0x70,
-	0x65, 0x72, 0x61, 0x74, 0x75, 0x72, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22,
-	0x00, 0x12, 0x5f, 0x0a, 0x15, 0x41, 0x73, 0x73, 0x69, 0x67, 0x6e, 0x4d, 0x61, 0x69, 0x6e, 0x74,
-	0x65, 0x6e, 0x61, 0x6e, 0x63, 0x65, 0x50, 0x6c, 0x61, 0x6e, 0x12, 0x2c, 0x2e, 0x62, 0x65, 0x65,
-	0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x41, 0x73, 0x73, 0x69,
-	0x67, 0x6e, 0x4d, 0x61, 0x69, 0x6e, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x63, 0x65, 0x50, 0x6c, 0x61,
-	0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
-	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79,
-	0x22, 0x00, 0x12, 0x64, 0x0a, 0x0f, 0x48, 0x61, 0x73, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x41,
-	0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x26, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61,
-	0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x48, 0x61, 0x73, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e,
-	0x41, 0x63, 0x63, 0x65, 0x73, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x27, 0x2e,
-	0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x48,
-	0x61, 0x73, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x41, 0x63, 0x63, 0x65, 0x73, 0x73, 0x52, 0x65,
-	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x55, 0x0a, 0x10, 0x52, 0x65, 0x67, 0x69,
-	0x73, 0x74, 0x65, 0x72, 0x49, 0x6e, 0x63, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x12, 0x27, 0x2e, 0x62,
-	0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x52, 0x65,
-	0x67, 0x69, 0x73, 0x74, 0x65, 0x72, 0x49, 0x6e, 0x63, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12,
-	0x79, 0x0a, 0x16, 0x47, 0x65, 0x74, 0x4c, 0x61, 0x74, 0x65, 0x73, 0x74, 0x53, 0x65, 0x6e, 0x73,
-	0x6f, 0x72, 0x52, 0x65, 0x61, 0x64, 0x69, 0x6e, 0x67, 0x12, 0x2d, 0x2e, 0x62, 0x65, 0x65, 0x5f,
-	0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x4c, 0x61,
-	0x74, 0x65, 0x73, 0x74, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x52, 0x65, 0x61, 0x64, 0x69, 0x6e,
-	0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2e, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d,
+	0x74, 0x75, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x55, 0x0a,
+	0x10, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x48, 0x69, 0x76, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x12, 0x27, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65,
+	0x6e, 0x74, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x48, 0x69, 0x76, 0x65, 0x53, 0x74, 0x61,
+	0x74, 0x75, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70,
+	0x74, 0x79, 0x22, 0x00, 0x12, 0x6a, 0x0a, 0x11, 0x47, 0x65, 0x74, 0x41, 0x76, 0x67, 0x54, 0x65,
+	0x6d, 0x70, 0x65, 0x72, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12, 0x28, 0x2e, 0x62, 0x65, 0x65, 0x5f,
+	0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x41, 0x76,
+	0x67, 0x54, 0x65, 0x6d, 0x70, 0x65, 0x72, 0x61, 0x74, 0x75, 0x72, 0x65, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x29, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65,
+	0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x41, 0x76, 0x67, 0x54, 0x65, 0x6d, 0x70, 0x65,
+	0x72, 0x61, 0x74, 0x75, 0x72, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00,
+	0x12, 0x5f, 0x0a, 0x15, 0x41, 0x73, 0x73, 0x69, 0x67, 0x6e, 0x4d, 0x61, 0x69, 0x6e, 0x74, 0x65,
+	0x6e, 0x61, 0x6e, 0x63, 0x65, 0x50, 0x6c, 0x61, 0x6e, 0x12, 0x2c, 0x2e, 0x62, 0x65, 0x65, 0x5f,
+	0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x41, 0x73, 0x73, 0x69, 0x67,
+	0x6e, 0x4d, 0x61, 0x69, 0x6e, 0x74, 0x65, 0x6e, 0x61, 0x6e, 0x63, 0x65, 0x50, 0x6c, 0x61, 0x6e,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x22,
+	0x00, 0x12, 0x64, 0x0a, 0x0f, 0x48, 0x61, 0x73, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x41, 0x63,
+	0x63, 0x65, 0x73, 0x73, 0x12, 0x26, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67,
+	0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x48, 0x61, 0x73, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x41,
+	0x63, 0x63, 0x65, 0x73, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x27, 0x2e, 0x62,
+	0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x48, 0x61,
+	0x73, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x41, 0x63, 0x63, 0x65, 0x73, 0x73, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x55, 0x0a, 0x10, 0x52, 0x65, 0x67, 0x69, 0x73,
+	0x74, 0x65, 0x72, 0x49, 0x6e, 0x63, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x12, 0x27, 0x2e, 0x62, 0x65,
+	0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x52, 0x65, 0x67,
+	0x69, 0x73, 0x74, 0x65, 0x72, 0x49, 0x6e, 0x63, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x79,
+	0x0a, 0x16, 0x47, 0x65, 0x74, 0x4c, 0x61, 0x74, 0x65, 0x73, 0x74, 0x53, 0x65, 0x6e, 0x73, 0x6f,
+	0x72, 0x52, 0x65, 0x61, 0x64, 0x69, 0x6e, 0x67, 0x12, 0x2d, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d,
 	0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x4c, 0x61, 0x74,
 	0x65, 0x73, 0x74, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x52, 0x65, 0x61, 0x64, 0x69, 0x6e, 0x67,
-	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x61, 0x0a, 0x16, 0x43, 0x72,
-	0x65, 0x61, 0x74, 0x65, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65,
-	0x70, 0x6f, 0x72, 0x74, 0x12, 0x2d, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67,
-	0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x50, 0x72, 0x6f, 0x64,
-	0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x65, 0x71, 0x75,
-	0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x53, 0x0a,
-	0x0f, 0x53, 0x65, 0x74, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x41, 0x63, 0x63, 0x65, 0x73, 0x73,
-	0x12, 0x26, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e,
-	0x74, 0x2e, 0x53, 0x65, 0x74, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x41, 0x63, 0x63, 0x65, 0x73,
-	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
-	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79,
-	0x22, 0x00, 0x42, 0x31, 0x5a, 0x2f, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d,
-	0x2f, 0x6f, 0x72, 0x69, 0x65, 0x6e, 0x74, 0x61, 0x6c, 0x6c, 0x69, 0x6e, 0x65, 0x73, 0x2f, 0x62,
-	0x65, 0x65, 0x73, 0x62, 0x69, 0x7a, 0x2f, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67,
-	0x65, 0x6d, 0x65, 0x6e, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2e, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61,
+	0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x47, 0x65, 0x74, 0x4c, 0x61, 0x74, 0x65,
+	0x73, 0x74, 0x53, 0x65, 0x6e, 0x73, 0x6f, 0x72, 0x52, 0x65, 0x61, 0x64, 0x69, 0x6e, 0x67, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x61, 0x0a, 0x16, 0x43, 0x72, 0x65,
+	0x61, 0x74, 0x65, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x70,
+	0x6f, 0x72, 0x74, 0x12, 0x2d, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65,
+	0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x50, 0x72, 0x6f, 0x64, 0x75,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x53, 0x0a, 0x0f,
+	0x53, 0x65, 0x74, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x41, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12,
+	0x26, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74,
+	0x2e, 0x53, 0x65, 0x74, 0x52, 0x65, 0x67, 0x69, 0x6f, 0x6e, 0x41, 0x63, 0x63, 0x65, 0x73, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x22,
+	0x00, 0x12, 0x67, 0x0a, 0x10, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x49, 0x6e, 0x73, 0x70, 0x65,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x27, 0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61,
+	0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x49, 0x6e, 0x73,
+	0x70, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x28,
+	0x2e, 0x62, 0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e,
+	0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x49, 0x6e, 0x73, 0x70, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x42, 0x31, 0x5a, 0x2f, 0x67, 0x69,
+	0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6f, 0x72, 0x69, 0x65, 0x6e, 0x74, 0x61,
+	0x6c, 0x6c, 0x69, 0x6e, 0x65, 0x73, 0x2f, 0x62, 0x65, 0x65, 0x73, 0x62, 0x69, 0x7a, 0x2f, 0x62,
+	0x65, 0x65, 0x5f, 0x6d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -1077,7 +1346,7 @@ func file_bee_management_proto_rawDescGZIP() []byte {
 	return file_bee_management_proto_rawDescData
 }
 
-var file_bee_management_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
+var file_bee_management_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
 var file_bee_management_proto_goTypes = []any{
 	(*GetTotalHoneyHarvestedRequest)(nil),    // 0: bee_management.GetTotalHoneyHarvestedRequest
 	(*GetTotalHoneyHarvestedResponse)(nil),   // 1: bee_management.GetTotalHoneyHarvestedResponse
@@ -1095,36 +1364,42 @@ var file_bee_management_proto_goTypes = []any{
 	(*GetLatestSensorReadingResponse)(nil),   // 13: bee_management.GetLatestSensorReadingResponse
 	(*CreateProductionReportRequest)(nil),    // 14: bee_management.CreateProductionReportRequest
 	(*SetRegionAccessRequest)(nil),           // 15: bee_management.SetRegionAccessRequest
-	(*emptypb.Empty)(nil),                    // 16: google.protobuf.Empty
+	(*RecordInspectionRequest)(nil),          // 16: bee_management.RecordInspectionRequest
+	(*InspectionAnswer)(nil),                 // 17: bee_management.InspectionAnswer
+	(*RecordInspectionResponse)(nil),         // 18: bee_management.RecordInspectionResponse
+	(*emptypb.Empty)(nil),                    // 19: google.protobuf.Empty
 }
 var file_bee_management_proto_depIdxs = []int32{
-	0,  // 0: bee_management.BeeManagementService.GetTotalHoneyHarvested:input_type -> bee_management.GetTotalHoneyHarvestedRequest
-	2,  // 1: bee_management.BeeManagementService.AddObservation:input_type -> bee_management.AddObservationRequest
-	3,  // 2: bee_management.BeeManagementService.GetCommunityHealthStatus:input_type -> bee_management.GetCommunityHealthStatusRequest
-	5,  // 3: bee_management.BeeManagementService.UpdateHiveStatus:input_type -> bee_management.UpdateHiveStatusRequest
-	6,  // 4: bee_management.BeeManagementService.GetAvgTemperature:input_type -> bee_management.GetAvgTemperatureRequest
-	8,  // 5: bee_management.BeeManagementService.AssignMaintenancePlan:input_type -> bee_management.AssignMaintenancePlanRequest
-	9,  // 6: bee_management.BeeManagementService.HasRegionAccess:input_type -> bee_management.HasRegionAccessRequest
-	11, // 7: bee_management.BeeManagementService.RegisterIncident:input_type -> bee_management.RegisterIncidentRequest
-	12, // 8: bee_management.BeeManagementService.GetLatestSensorReading:input_type -> bee_management.GetLatestSensorReadingRequest
-	14, // 9: bee_management.BeeManagementService.CreateProductionReport:input_type -> bee_management.CreateProductionReportRequest
-	15, // 10: bee_management.BeeManagementService.SetRegionAccess:input_type -> bee_management.SetRegionAccessRequest
-	1,  // 11: bee_management.BeeManagementService.GetTotalHoneyHarvested:output_type -> bee_management.GetTotalHoneyHarvestedResponse
-	16, // 12: bee_management.BeeManagementService.AddObservation:output_type -> google.protobuf.Empty
-	4,  // 13: bee_management.BeeManagementService.GetCommunityHealthStatus:output_type -> bee_management.GetCommunityHealthStatusResponse
-	16, // 14: bee_management.BeeManagementService.UpdateHiveStatus:output_type -> google.protobuf.Empty
-	7,  // 15: bee_management.BeeManagementService.GetAvgTemperature:output_type -> bee_management.GetAvgTemperatureResponse
-	16, // 16: bee_management.BeeManagementService.AssignMaintenancePlan:output_type -> google.protobuf.Empty
-	10, // 17: bee_management.BeeManagementService.HasRegionAccess:output_type -> bee_management.HasRegionAccessResponse
-	16, // 18: bee_management.BeeManagementService.RegisterIncident:output_type -> google.protobuf.Empty
-	13, // 19: bee_management.BeeManagementService.GetLatestSensorReading:output_type -> bee_management.GetLatestSensorReadingResponse
-	16, // 20: bee_management.BeeManagementService.CreateProductionReport:output_type -> google.protobuf.Empty
-	16, // 21: bee_management.BeeManagementService.SetRegionAccess:output_type -> google.protobuf.Empty
-	11, // [11:22] is the sub-list for method output_type
-	0,  // [0:11] is the sub-list for method input_type
-	0,  // [0:0] is the sub-list for extension type_name
-	0,  // [0:0] is the sub-list for extension extendee
-	0,  // [0:0] is the sub-list for field type_name
+	17, // 0: bee_management.RecordInspectionRequest.answers:type_name -> bee_management.InspectionAnswer
+	0,  // 1: bee_management.BeeManagementService.GetTotalHoneyHarvested:input_type -> bee_management.GetTotalHoneyHarvestedRequest
+	2,  // 2: bee_management.BeeManagementService.AddObservation:input_type -> bee_management.AddObservationRequest
+	3,  // 3: bee_management.BeeManagementService.GetCommunityHealthStatus:input_type -> bee_management.GetCommunityHealthStatusRequest
+	5,  // 4: bee_management.BeeManagementService.UpdateHiveStatus:input_type -> bee_management.UpdateHiveStatusRequest
+	6,  // 5: bee_management.BeeManagementService.GetAvgTemperature:input_type -> bee_management.GetAvgTemperatureRequest
+	8,  // 6: bee_management.BeeManagementService.AssignMaintenancePlan:input_type -> bee_management.AssignMaintenancePlanRequest
+	9,  // 7: bee_management.BeeManagementService.HasRegionAccess:input_type -> bee_management.HasRegionAccessRequest
+	11, // 8: bee_management.BeeManagementService.RegisterIncident:input_type -> bee_management.RegisterIncidentRequest
+	12, // 9: bee_management.BeeManagementService.GetLatestSensorReading:input_type -> bee_management.GetLatestSensorReadingRequest
+	14, // 10: bee_management.BeeManagementService.CreateProductionReport:input_type -> bee_management.CreateProductionReportRequest
+	15, // 11: bee_management.BeeManagementService.SetRegionAccess:input_type -> bee_management.SetRegionAccessRequest
+	16, // 12: bee_management.BeeManagementService.RecordInspection:input_type -> bee_management.RecordInspectionRequest
+	1,  // 13: bee_management.BeeManagementService.GetTotalHoneyHarvested:output_type -> bee_management.GetTotalHoneyHarvestedResponse
+	19, // 14: bee_management.BeeManagementService.AddObservation:output_type -> google.protobuf.Empty
+	4,  // 15: bee_management.BeeManagementService.GetCommunityHealthStatus:output_type -> bee_management.GetCommunityHealthStatusResponse
+	19, // 16: bee_management.BeeManagementService.UpdateHiveStatus:output_type -> google.protobuf.Empty
+	7,  // 17: bee_management.BeeManagementService.GetAvgTemperature:output_type -> bee_management.GetAvgTemperatureResponse
+	19, // 18: bee_management.BeeManagementService.AssignMaintenancePlan:output_type -> google.protobuf.Empty
+	10, // 19: bee_management.BeeManagementService.HasRegionAccess:output_type -> bee_management.HasRegionAccessResponse
+	19, // 20: bee_management.BeeManagementService.RegisterIncident:output_type -> google.protobuf.Empty
+	13, // 21: bee_management.BeeManagementService.GetLatestSensorReading:output_type -> bee_management.GetLatestSensorReadingResponse
+	19, // 22: bee_management.BeeManagementService.CreateProductionReport:output_type -> google.protobuf.Empty
+	19, // 23: bee_management.BeeManagementService.SetRegionAccess:output_type -> google.protobuf.Empty
+	18, // 24: bee_management.BeeManagementService.RecordInspection:output_type -> bee_management.RecordInspectionResponse
+	13, // [13:25] is the sub-list for method output_type
+	1,  // [1:13] is the sub-list for method input_type
+	1,  // [1:1] is the sub-list for extension type_name
+	1,  // [1:1] is the sub-list for extension extendee
+	0,  // [0:1] is the sub-list for field type_name
 }
 
 func init() { file_bee_management_proto_init() }
@@ -1132,13 +1407,18 @@ func file_bee_management_proto_init() {
 	if File_bee_management_proto != nil {
 		return
 	}
+	file_bee_management_proto_msgTypes[17].OneofWrappers = []any{
+		(*InspectionAnswer_BoolValue)(nil),
+		(*InspectionAnswer_NumberValue)(nil),
+		(*InspectionAnswer_TextValue)(nil),
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_bee_management_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   16,
+			NumMessages:   19,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
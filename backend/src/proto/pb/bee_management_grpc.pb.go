@@ -31,6 +31,7 @@ const (
 	BeeManagementService_GetLatestSensorReading_FullMethodName   = "/bee_management.BeeManagementService/GetLatestSensorReading"
 	BeeManagementService_CreateProductionReport_FullMethodName   = "/bee_management.BeeManagementService/CreateProductionReport"
 	BeeManagementService_SetRegionAccess_FullMethodName          = "/bee_management.BeeManagementService/SetRegionAccess"
+	BeeManagementService_RecordInspection_FullMethodName         = "/bee_management.BeeManagementService/RecordInspection"
 )
 
 // BeeManagementServiceClient is the client API for BeeManagementService service.
@@ -41,7 +42,9 @@ const (
 type BeeManagementServiceClient interface {
 	// 1. Get Total Honey Harvested
 	GetTotalHoneyHarvested(ctx context.Context, in *GetTotalHoneyHarvestedRequest, opts ...grpc.CallOption) (*GetTotalHoneyHarvestedResponse, error)
+	// Deprecated: Do not use.
 	// 2. Add Observation
+	// Deprecated: use RecordInspection, which stores structured answers
 	AddObservation(ctx context.Context, in *AddObservationRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	// 3. Get Community Health Status
 	GetCommunityHealthStatus(ctx context.Context, in *GetCommunityHealthStatusRequest, opts ...grpc.CallOption) (*GetCommunityHealthStatusResponse, error)
@@ -61,6 +64,8 @@ type BeeManagementServiceClient interface {
 	CreateProductionReport(ctx context.Context, in *CreateProductionReportRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	// 11. Set Region Access
 	SetRegionAccess(ctx context.Context, in *SetRegionAccessRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// 12. Record Inspection
+	RecordInspection(ctx context.Context, in *RecordInspectionRequest, opts ...grpc.CallOption) (*RecordInspectionResponse, error)
 }
 
 type beeManagementServiceClient struct {
@@ -81,6 +86,7 @@ func (c *beeManagementServiceClient) GetTotalHoneyHarvested(ctx context.Context,
 	return out, nil
 }
 
+// Deprecated: Do not use.
 func (c *beeManagementServiceClient) AddObservation(ctx context.Context, in *AddObservationRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(emptypb.Empty)
@@ -181,6 +187,16 @@ func (c *beeManagementServiceClient) SetRegionAccess(ctx context.Context, in *Se
 	return out, nil
 }
 
+func (c *beeManagementServiceClient) RecordInspection(ctx context.Context, in *RecordInspectionRequest, opts ...grpc.CallOption) (*RecordInspectionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RecordInspectionResponse)
+	err := c.cc.Invoke(ctx, BeeManagementService_RecordInspection_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // BeeManagementServiceServer is the server API for BeeManagementService service.
 // All implementations must embed UnimplementedBeeManagementServiceServer
 // for forward compatibility.
@@ -189,7 +205,9 @@ func (c *beeManagementServiceClient) SetRegionAccess(ctx context.Context, in *Se
 type BeeManagementServiceServer interface {
 	// 1. Get Total Honey Harvested
 	GetTotalHoneyHarvested(context.Context, *GetTotalHoneyHarvestedRequest) (*GetTotalHoneyHarvestedResponse, error)
+	// Deprecated: Do not use.
 	// 2. Add Observation
+	// Deprecated: use RecordInspection, which stores structured answers
 	AddObservation(context.Context, *AddObservationRequest) (*emptypb.Empty, error)
 	// 3. Get Community Health Status
 	GetCommunityHealthStatus(context.Context, *GetCommunityHealthStatusRequest) (*GetCommunityHealthStatusResponse, error)
@@ -209,6 +227,8 @@ type BeeManagementServiceServer interface {
 	CreateProductionReport(context.Context, *CreateProductionReportRequest) (*emptypb.Empty, error)
 	// 11. Set Region Access
 	SetRegionAccess(context.Context, *SetRegionAccessRequest) (*emptypb.Empty, error)
+	// 12. Record Inspection
+	RecordInspection(context.Context, *RecordInspectionRequest) (*RecordInspectionResponse, error)
 	mustEmbedUnimplementedBeeManagementServiceServer()
 }
 
@@ -252,6 +272,9 @@ func (UnimplementedBeeManagementServiceServer) CreateProductionReport(context.Co
 func (UnimplementedBeeManagementServiceServer) SetRegionAccess(context.Context, *SetRegionAccessRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SetRegionAccess not implemented")
 }
+func (UnimplementedBeeManagementServiceServer) RecordInspection(context.Context, *RecordInspectionRequest) (*RecordInspectionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecordInspection not implemented")
+}
 func (UnimplementedBeeManagementServiceServer) mustEmbedUnimplementedBeeManagementServiceServer() {}
 func (UnimplementedBeeManagementServiceServer) testEmbeddedByValue()                              {}
 
@@ -471,6 +494,24 @@ func _BeeManagementService_SetRegionAccess_Handler(srv interface{}, ctx context.
 	return interceptor(ctx, in, info, handler)
 }
 
+func _BeeManagementService_RecordInspection_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecordInspectionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BeeManagementServiceServer).RecordInspection(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BeeManagementService_RecordInspection_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BeeManagementServiceServer).RecordInspection(ctx, req.(*RecordInspectionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // BeeManagementService_ServiceDesc is the grpc.ServiceDesc for BeeManagementService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -522,6 +563,10 @@ var BeeManagementService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SetRegionAccess",
 			Handler:    _BeeManagementService_SetRegionAccess_Handler,
 		},
+		{
+			MethodName: "RecordInspection",
+			Handler:    _BeeManagementService_RecordInspection_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "bee_management.proto",
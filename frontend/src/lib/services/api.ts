@@ -12,6 +12,7 @@ import type {
 	ObservationLog,
 	User,
 	Incident,
+	IncidentStatus,
 	CreateIncidentInput,
 	CreateObservationInput,
 	Role,
@@ -562,10 +563,19 @@ export async function getAverageTemperature(regionId: number, days: number): Pro
 }
 
 export async function assignMaintenancePlan(planId: number, userId: number): Promise<void> {
-	const response = await fetch(`${API_BASE_URL}/api/maintenance`, {
+	const response = await fetch(`${API_BASE_URL}/api/maintenance/${planId}/assignment`, {
 		method: 'PUT',
 		headers: getAuthHeaders(),
-		body: JSON.stringify({ id: planId, assigned_to: userId })
+		body: JSON.stringify({ user_id: userId })
+	});
+	handleResponse(response);
+}
+
+export async function assignMaintenancePlanToGroup(planId: number, groupId: number): Promise<void> {
+	const response = await fetch(`${API_BASE_URL}/api/maintenance/${planId}/assignment`, {
+		method: 'PUT',
+		headers: getAuthHeaders(),
+		body: JSON.stringify({ group_id: groupId })
 	});
 	handleResponse(response);
 }
@@ -630,13 +640,28 @@ export async function createIncident(data: CreateIncidentInput): Promise<Inciden
 	return response.json();
 }
 
-export async function updateIncidentStatus(incidentId: string, status: string): Promise<void> {
+export async function updateIncidentStatus(
+	incidentId: number,
+	status: IncidentStatus,
+	comment?: string
+): Promise<Incident> {
 	const response = await fetch(`${API_BASE_URL}/api/incident/${incidentId}/status`, {
 		method: 'PUT',
 		headers: getAuthHeaders(),
-		body: JSON.stringify({ severity: status })
+		body: JSON.stringify({ status, comment })
+	});
+	handleResponse(response);
+	return response.json();
+}
+
+export async function updateIncidentSeverity(incidentId: number, severity: string): Promise<Incident> {
+	const response = await fetch(`${API_BASE_URL}/api/incident/${incidentId}/severity`, {
+		method: 'PUT',
+		headers: getAuthHeaders(),
+		body: JSON.stringify({ severity })
 	});
 	handleResponse(response);
+	return response.json();
 }
 
 export async function updateIncident(data: Incident): Promise<Incident> {
@@ -50,6 +50,8 @@ export interface Apiary {
 	location: string;
 	manager_id: number;
 	establishment_date: Time;
+	latitude?: number | null;
+	longitude?: number | null;
 }
 
 export interface Hive {
@@ -58,6 +60,7 @@ export interface Hive {
 	hive_type: string;
 	installation_date: Time;
 	current_status: string;
+	tare_weight_kg?: number | null;
 }
 
 export interface BeeCommunity {
@@ -66,6 +69,8 @@ export interface BeeCommunity {
 	queen_age: number;
 	population_estimate: number;
 	health_status: string;
+	dissolved_date?: string | null;
+	merged_into?: number | null;
 }
 
 export interface HoneyHarvest {
@@ -74,6 +79,8 @@ export interface HoneyHarvest {
 	harvest_date: Time;
 	quantity: number;
 	quality_grade: string;
+	withdrawal_override_reason?: string | null;
+	overridden_by?: number | null;
 }
 
 export interface ObservationLog {
@@ -94,21 +101,36 @@ export interface CreateObservationInput {
 export interface MaintenancePlan {
 	plan_id: number;
 	apiary_id: number;
+	hive_id?: number | null;
 	planned_date: Date | null;
 	work_type: string;
-	assigned_to: number;
-	status: 'Pending' | 'In Progress' | 'Completed';
+	assigned_to: number | null;
+	assigned_group_id?: number | null;
+	status: 'Pending' | 'In Progress' | 'Completed' | 'Skipped';
+	schedule_id?: number | null;
+	occurrence_date?: Date | null;
+	overdue_at?: Date | null;
 	priority: 'High' | 'Medium' | 'Low';
 	description: string;
 }
 
+export type IncidentStatus = 'open' | 'acknowledged' | 'in_progress' | 'resolved' | 'closed';
+
 export interface Incident {
 	incident_id: number;
 	hive_id: number;
 	incident_date: Time;
 	description: string;
 	severity: string;
-	actions_taken?: string;
+	actions_taken?: string | null;
+	status: IncidentStatus;
+	assigned_user_id: number | null;
+	assigned_group_id: number | null;
+	created_at: string;
+	acknowledged_at: string | null;
+	started_at: string | null;
+	resolved_at: string | null;
+	closed_at: string | null;
 }
 
 export interface CreateIncidentInput {
@@ -127,6 +149,8 @@ export interface ProductionReport {
 	total_honey_produced: number;
 	total_expenses: number;
 	curated_by: number;
+	feed_used_kg: number;
+	feed_cost: number;
 }
 
 export interface VeterinaryPassport {